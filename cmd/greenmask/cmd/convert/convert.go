@@ -0,0 +1,89 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package convert
+
+import (
+	"context"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	cmdInternals "github.com/greenmaskio/greenmask/internal/db/postgres/cmd"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/storages/builder"
+	"github.com/greenmaskio/greenmask/internal/utils/logger"
+)
+
+const stdoutFileName = "-"
+
+var (
+	Cmd = &cobra.Command{
+		Use:   "convert [flags] dumpId|latest",
+		Args:  cobra.ExactArgs(1),
+		Short: "convert the dump into the plain SQL script or pg_dump custom format archive",
+		Run:   run,
+	}
+	Config = domains.NewConfig()
+	opt    = &cmdInternals.ConvertOptions{}
+	output string
+)
+
+func run(cmd *cobra.Command, args []string) {
+	if err := logger.SetLogLevel(Config.Log.Level, Config.Log.Format); err != nil {
+		log.Fatal().Err(err).Msg("error setting up logger")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	st, err := builder.GetStorage(ctx, &Config.Storage, &Config.Log)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error building storage")
+	}
+
+	c := cmdInternals.NewConvert(st, args[0], opt)
+	if output == stdoutFileName {
+		err = c.Run(ctx, os.Stdout)
+	} else {
+		err = c.RunToStorage(ctx, st, output)
+	}
+	if err != nil {
+		log.Fatal().Err(err).Msg("cannot convert dump")
+	}
+	if output != stdoutFileName {
+		log.Info().
+			Str("FileName", output).
+			Msg("dump is converted")
+	}
+}
+
+func init() {
+	Cmd.Flags().StringVarP(&opt.Format, "to", "", cmdInternals.ConvertFormatSql, "output format [sql, custom]")
+	Cmd.Flags().StringVarP(
+		&output, "output", "o", stdoutFileName,
+		"name of the file in the storage (- for stdout). The sql script is compressed if the file has the codec extension",
+	)
+	Cmd.Flags().BoolVarP(
+		&opt.Inserts, "inserts", "", false, "write the table data as INSERT statements instead of COPY",
+	)
+	Cmd.Flags().BoolVarP(
+		&opt.OverridingSystemValue, "overriding-system-value", "", false,
+		"add OVERRIDING SYSTEM VALUE clause to the INSERT statements",
+	)
+	Cmd.Flags().IntVarP(
+		&opt.CompressionLevel, "compress", "Z", -1,
+		"zlib compression level of the custom format archive data (0 - no compression, -1 - default level)",
+	)
+}
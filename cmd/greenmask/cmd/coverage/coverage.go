@@ -0,0 +1,74 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coverage
+
+import (
+	"context"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	cmdInternals "github.com/greenmaskio/greenmask/internal/db/postgres/cmd"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/utils/logger"
+)
+
+var (
+	Cmd = &cobra.Command{
+		Use:   "coverage",
+		Short: "report dumped columns that are not transformed and check sensitive columns coverage",
+		Run:   run,
+	}
+	Config = domains.NewConfig()
+)
+
+func run(cmd *cobra.Command, args []string) {
+	if err := logger.SetLogLevel(Config.Log.Level, Config.Log.Format); err != nil {
+		log.Err(err).Msg("")
+	}
+
+	if Config.Coverage.Format != cmdInternals.JsonFormat &&
+		Config.Coverage.Format != cmdInternals.TextFormat {
+		log.Fatal().
+			Str("RequestedFormat", Config.Coverage.Format).
+			Msg("unknown --format value")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	coverageCmd := cmdInternals.NewCoverage(Config, utils.DefaultTransformerRegistry)
+	exitCode, err := coverageCmd.Run(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
+
+func init() {
+	formatFlagName := "format"
+	Cmd.Flags().String(
+		formatFlagName, "text", "Format of output. possible values [text|json]",
+	)
+	flag := Cmd.Flags().Lookup(formatFlagName)
+	if err := viper.BindPFlag("coverage.format", flag); err != nil {
+		log.Fatal().Err(err).Msg("fatal")
+	}
+}
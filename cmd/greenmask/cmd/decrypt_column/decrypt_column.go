@@ -0,0 +1,82 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package decrypt_column
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	cmdInternals "github.com/greenmaskio/greenmask/internal/db/postgres/cmd"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/utils/logger"
+)
+
+var (
+	Cmd = &cobra.Command{
+		Use:   "decrypt-column [flags] [value...]",
+		Short: "decrypt the values of the column encrypted by the Fpe transformer",
+		Long: "decrypt the values of the column encrypted by the Fpe transformer using the dump transformation " +
+			"config. The values are read from the stdin line by line if they are not provided as arguments",
+		Run: run,
+	}
+	Config = domains.NewConfig()
+	table  string
+	column string
+)
+
+func run(cmd *cobra.Command, args []string) {
+	if err := logger.SetLogLevel(Config.Log.Level, Config.Log.Format); err != nil {
+		log.Fatal().Err(err).Msg("error setting up logger")
+	}
+
+	schema, name := "public", table
+	if s, n, ok := strings.Cut(table, "."); ok {
+		schema, name = s, n
+	}
+	dc, err := cmdInternals.NewDecryptColumn(Config.Dump.Transformation, schema, name, column)
+	if err != nil {
+		log.Fatal().Err(err).Msg("cannot initialize decryption")
+	}
+
+	if len(args) == 0 {
+		if err = dc.Run(context.Background(), os.Stdin, os.Stdout); err != nil {
+			log.Fatal().Err(err).Msg("cannot decrypt values")
+		}
+		return
+	}
+	for _, v := range args {
+		res, err := dc.Decrypt(v)
+		if err != nil {
+			log.Fatal().Err(err).Msg("cannot decrypt value")
+		}
+		fmt.Println(res)
+	}
+}
+
+func init() {
+	Cmd.Flags().StringVarP(&table, "table", "t", "", "table name in format schema.table (default schema public)")
+	Cmd.Flags().StringVarP(&column, "column", "c", "", "column name")
+	if err := Cmd.MarkFlagRequired("table"); err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+	if err := Cmd.MarkFlagRequired("column"); err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+}
@@ -26,9 +26,11 @@ import (
 	"github.com/spf13/cobra"
 	gostr "github.com/xhit/go-str2duration/v2"
 
+	cmdInternals "github.com/greenmaskio/greenmask/internal/db/postgres/cmd"
 	pgDomains "github.com/greenmaskio/greenmask/internal/domains"
 	"github.com/greenmaskio/greenmask/internal/storages"
 	"github.com/greenmaskio/greenmask/internal/storages/builder"
+	tarStorage "github.com/greenmaskio/greenmask/internal/storages/tar"
 	"github.com/greenmaskio/greenmask/internal/utils/dumpstatus"
 	"github.com/greenmaskio/greenmask/internal/utils/logger"
 )
@@ -115,18 +117,28 @@ func deleteDump(dumpId string) error {
 		log.Fatal().Err(err).Msg("")
 	}
 
-	_, dirs, err := st.ListDir(ctx)
+	dumps, err := cmdInternals.ListDumpStorages(ctx, st)
 	if err != nil {
 		log.Fatal().Err(err).Msg("")
 	}
 
-	if !slices.ContainsFunc(dirs, func(sst storages.Storager) bool {
+	idx := slices.IndexFunc(dumps, func(sst storages.Storager) bool {
 		return dumpId == sst.Dirname()
-	}) {
+	})
+	if idx == -1 {
 		return fmt.Errorf("dump with id %s was not found", dumpId)
 	}
 
-	if err = st.DeleteAll(ctx, dumpId); err != nil {
+	sr, err := getSortedBackupWithStatuses(ctx, st)
+	if err != nil {
+		return fmt.Errorf("could not get sorted dumps: %s", err)
+	}
+	if referencedBy := findReferencingDump(dumpId, sr.Valid); referencedBy != nil {
+		return fmt.Errorf("dump %s is referenced by incremental dump %s", dumpId, referencedBy.DumpId)
+	}
+
+	d := &Dump{DumpId: dumpId, IsTar: cmdInternals.IsTarDumpStorage(dumps[idx])}
+	if err = deleteDumpObjects(ctx, st, d); err != nil {
 		return fmt.Errorf("storage error: %s", err)
 	}
 
@@ -170,11 +182,14 @@ func deleteBeforeDate(ctx context.Context, st storages.Storager, dateStr string)
 	if err != nil {
 		return fmt.Errorf("could not get sorted dumps: %s", err)
 	}
+	var kept []*Dump
 	for _, d := range sr.Valid {
-		if d.Date.Before(dt) {
-			if err = deleteDumpById(ctx, st, d, dryRun); err != nil {
-				return fmt.Errorf("could not delete dump %s: %s", d.DumpId, err)
-			}
+		if !d.Date.Before(dt) || isReferencedByKeptDump(d, kept) {
+			kept = append(kept, d)
+			continue
+		}
+		if err = deleteDumpById(ctx, st, d, dryRun); err != nil {
+			return fmt.Errorf("could not delete dump %s: %s", d.DumpId, err)
 		}
 	}
 	return nil
@@ -197,8 +212,10 @@ func retainForDumps(ctx context.Context, st storages.Storager, retainFor string)
 	if err != nil {
 		return fmt.Errorf("could not get sorted dumps: %s", err)
 	}
+	var kept []*Dump
 	for _, d := range sr.Valid {
-		if time.Since(d.Date) < dur {
+		if time.Since(d.Date) < dur || isReferencedByKeptDump(d, kept) {
+			kept = append(kept, d)
 			continue
 		}
 		if err = deleteDumpById(ctx, st, d, dryRun); err != nil {
@@ -219,8 +236,10 @@ func retainRecentNDumps(ctx context.Context, st storages.Storager) error {
 		Bool("DryRun", dryRun).
 		Msg("retaining the most recent N dumps")
 
+	var kept []*Dump
 	for idx, d := range sr.Valid {
-		if idx < retainRecent {
+		if idx < retainRecent || isReferencedByKeptDump(d, kept) {
+			kept = append(kept, d)
 			continue
 		}
 		if err = deleteDumpById(ctx, st, d, dryRun); err != nil {
@@ -232,7 +251,7 @@ func retainRecentNDumps(ctx context.Context, st storages.Storager) error {
 
 func getSortedBackupWithStatuses(ctx context.Context, st storages.Storager) (*StorageResponse, error) {
 	var valid, failed, unknownOrFailed []*Dump
-	_, backups, err := st.ListDir(ctx)
+	backups, err := cmdInternals.ListDumpStorages(ctx, st)
 	if err != nil {
 		return nil, err
 	}
@@ -247,10 +266,12 @@ func getSortedBackupWithStatuses(ctx context.Context, st storages.Storager) (*St
 		d := Dump{
 			DumpId: backup.Dirname(),
 			Status: status,
+			IsTar:  cmdInternals.IsTarDumpStorage(backup),
 		}
 		if status == dumpstatus.DoneStatusName {
 			d.Date = md.StartedAt
 			d.Database = md.Header.DbName
+			d.ReferencedDumpIds = md.GetReferencedDumpIds()
 		}
 		switch status {
 		case dumpstatus.DoneStatusName:
@@ -281,6 +302,30 @@ func getSortedBackupWithStatuses(ctx context.Context, st storages.Storager) (*St
 	}, nil
 }
 
+// findReferencingDump - finds the incremental dump that references the objects of the dump with the provided id
+func findReferencingDump(dumpId string, dumps []*Dump) *Dump {
+	for _, d := range dumps {
+		if slices.Contains(d.ReferencedDumpIds, dumpId) {
+			return d
+		}
+	}
+	return nil
+}
+
+// isReferencedByKeptDump - checks that the dump must be kept because the retained incremental dump references its
+// objects
+func isReferencedByKeptDump(d *Dump, kept []*Dump) bool {
+	referencedBy := findReferencingDump(d.DumpId, kept)
+	if referencedBy == nil {
+		return false
+	}
+	log.Info().
+		Str("DumpId", d.DumpId).
+		Str("ReferencedBy", referencedBy.DumpId).
+		Msg("dump is kept because it is referenced by incremental dump")
+	return true
+}
+
 func deleteDumpById(ctx context.Context, st storages.Storager, d *Dump, dryRun bool) error {
 	if d.DumpId == "" {
 		panic("empty dump id")
@@ -305,10 +350,15 @@ func deleteDumpById(ctx context.Context, st storages.Storager, d *Dump, dryRun b
 	if dryRun {
 		return nil
 	}
-	if err := st.DeleteAll(ctx, d.DumpId); err != nil {
-		return err
+	return deleteDumpObjects(ctx, st, d)
+}
+
+// deleteDumpObjects - deletes the tar object or the directory of the dump
+func deleteDumpObjects(ctx context.Context, st storages.Storager, d *Dump) error {
+	if d.IsTar {
+		return st.Delete(ctx, d.DumpId+tarStorage.Extension)
 	}
-	return nil
+	return st.DeleteAll(ctx, d.DumpId)
 }
 
 func init() {
@@ -13,4 +13,8 @@ type Dump struct {
 	Date     time.Time
 	Status   string
 	Database string
+	// ReferencedDumpIds - ids of the dumps that are referenced by the incremental dump
+	ReferencedDumpIds []string
+	// IsTar - the dump is stored as the single tar object <DumpId>.tar
+	IsTar bool
 }
@@ -28,6 +28,7 @@ import (
 	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
 	pgDomains "github.com/greenmaskio/greenmask/internal/domains"
 	"github.com/greenmaskio/greenmask/internal/storages/builder"
+	tarStorage "github.com/greenmaskio/greenmask/internal/storages/tar"
 	"github.com/greenmaskio/greenmask/internal/utils/logger"
 )
 
@@ -46,21 +47,68 @@ var (
 			if err != nil {
 				log.Fatal().Err(err).Msg("fatal")
 			}
-			st = st.SubStorage(strconv.FormatInt(time.Now().UnixMilli(), 10), true)
-
+			dumpId := strconv.FormatInt(time.Now().UnixMilli(), 10)
+			if resumeDumpId != "" {
+				dumpId = resumeDumpId
+			}
 			if Config.Common.TempDirectory == "" {
 				log.Fatal().Msg("common.tmp_dir cannot be empty")
 			}
+			if err := cmdInternals.ValidateDumpOutputFormat(Config.Dump.OutputFormat); err != nil {
+				log.Fatal().Err(err).Msg("")
+			}
+
+			dumpsSt := st
+			// The custom format archive is restored by pg_restore, so it is not read by the other commands
+			if Config.Dump.OutputFormat == cmdInternals.DumpOutputFormatCustom {
+				if resumeDumpId != "" || incrementalFrom != "" {
+					log.Fatal().Msg("--resume and --incremental-from are not supported by the custom output format")
+				}
+				dump := cmdInternals.NewCustomDump(Config, dumpsSt, dumpId, utils.DefaultTransformerRegistry)
+				if err := dump.Run(ctx); err != nil {
+					log.Fatal().Err(err).Msg("cannot make a backup")
+				}
+				return
+			}
+			// The tar dump is streamed into the single object while the dump runs
+			var tarSt *tarStorage.Writer
+			if Config.Dump.OutputFormat == cmdInternals.DumpOutputFormatTar {
+				if resumeDumpId != "" {
+					log.Fatal().Msg("--resume is not supported by the tar output format: the archive is streamed into the storage")
+				}
+				tarSt, err = cmdInternals.NewTarDumpStorage(ctx, dumpsSt, Config.Common.TempDirectory, dumpId)
+				if err != nil {
+					log.Fatal().Err(err).Msg("")
+				}
+				st = tarSt
+			} else {
+				st = st.SubStorage(dumpId, true)
+			}
 
 			dump := cmdInternals.NewDump(Config, st, utils.DefaultTransformerRegistry)
+			dump.SetResume(resumeDumpId != "")
+			dump.SetDumpsStorage(dumpsSt)
+			if incrementalFrom != "" {
+				dump.SetIncrementalFrom(incrementalFrom)
+			}
 
 			if err := dump.Run(ctx); err != nil {
+				if tarSt != nil {
+					tarSt.Abort(err)
+				}
 				log.Fatal().Err(err).Msg("cannot make a backup")
 			}
+			if tarSt != nil {
+				if err := tarSt.Close(); err != nil {
+					log.Fatal().Err(err).Msg("cannot upload tar dump")
+				}
+			}
 
 		},
 	}
-	Config = pgDomains.NewConfig()
+	Config          = pgDomains.NewConfig()
+	resumeDumpId    string
+	incrementalFrom string
 )
 
 // TODO: Check how does work mixed options - use-list + tables, etc.
@@ -87,6 +135,37 @@ func init() {
 	// Description options
 	Cmd.Flags().StringVarP(&Config.Dump.PgDumpOptions.Description, "description", "", "", "add a description for this dump")
 
+	// Resume options
+	Cmd.Flags().StringVarP(
+		&resumeDumpId, "resume", "", "", "resume the interrupted dump with the provided id, dumping only the tables that were not completed",
+	)
+
+	// Incremental options
+	Cmd.Flags().StringVarP(
+		&incrementalFrom, "incremental-from", "", "", "reuse the data of the tables that were not changed since the dump with the provided id",
+	)
+	Cmd.Flags().BoolP(
+		"require-coverage", "", false, "fail the dump if any sensitive column from the coverage section is not transformed",
+	)
+	if err := viper.BindPFlag("dump.require_coverage", Cmd.Flags().Lookup("require-coverage")); err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+	Cmd.Flags().StringP(
+		"schema-drift-policy", "", "",
+		"action on the schema changes since the previous dump: ignore, warn, fail or auto_anonymize",
+	)
+	if err := viper.BindPFlag("dump.schema_drift_policy", Cmd.Flags().Lookup("schema-drift-policy")); err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+	Cmd.Flags().StringP(
+		"output-format", "", cmdInternals.DumpOutputFormatDirectory,
+		"layout of the dump in the storage: directory, tar (single <dumpId>.tar object) or custom "+
+			"(pg_dump custom format <dumpId>.dump archive)",
+	)
+	if err := viper.BindPFlag("dump.output_format", Cmd.Flags().Lookup("output-format")); err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+
 	// Options controlling the output content:
 	Cmd.Flags().BoolP("data-only", "a", false, "dump only the data, not the schema")
 	Cmd.Flags().BoolP("blobs", "b", true, "include large objects in dump")
@@ -151,6 +230,14 @@ func init() {
 		"pgzip", "", false,
 		"use pgzip compression instead of gzip",
 	)
+	Cmd.Flags().StringP(
+		"compression-codec", "", "gzip",
+		"compression codec of the data files [gzip|zstd|lz4|none]",
+	)
+	Cmd.Flags().IntP(
+		"compression-level", "", 0,
+		"compression level of the codec (0 - the codec default level)",
+	)
 
 	// Connection options:
 	Cmd.Flags().StringP("dbname", "d", "postgres", "database to dump")
@@ -169,6 +256,7 @@ func init() {
 		"no-subscriptions", "no-synchronized-snapshots", "no-tablespaces", "no-toast-compression",
 		"no-unlogged-table-data", "quote-all-identifiers", "section",
 		"serializable-deferrable", "snapshot", "strict-names", "use-set-session-authorization", "pgzip",
+		"compression-codec", "compression-level",
 
 		"dbname", "host", "port", "username",
 	} {
@@ -0,0 +1,94 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	cmdInternals "github.com/greenmaskio/greenmask/internal/db/postgres/cmd"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/export"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/storages/builder"
+	"github.com/greenmaskio/greenmask/internal/utils/logger"
+)
+
+var (
+	Cmd = &cobra.Command{
+		Use:   "export",
+		Short: "dump the database, transform data, and write the tables in Parquet, CSV or JSON Lines format",
+		Run:   run,
+	}
+	Config = domains.NewConfig()
+	format string
+	jobs   int
+)
+
+func run(cmd *cobra.Command, args []string) {
+	if err := logger.SetLogLevel(Config.Log.Level, Config.Log.Format); err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+
+	if Config.Common.TempDirectory == "" {
+		log.Fatal().Msg("common.tmp_dir cannot be empty")
+	}
+
+	// The format and jobs are read from the config. The flags override them
+	if cmd.Flags().Changed("format") || Config.Export.Format == "" {
+		Config.Export.Format = format
+	}
+	if cmd.Flags().Changed("jobs") || Config.Dump.PgDumpOptions.Jobs < 1 {
+		Config.Dump.PgDumpOptions.Jobs = jobs
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The exports are written into the separate storage if it is set
+	storageCfg := &Config.Storage
+	if Config.Export.Storage != nil {
+		storageCfg = Config.Export.Storage
+	}
+	exportsSt, err := builder.GetStorage(ctx, storageCfg, &Config.Log)
+	if err != nil {
+		log.Fatal().Err(err).Msg("fatal")
+	}
+	exportId := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	st := exportsSt.SubStorage(exportId, true)
+
+	if err := cmdInternals.NewExport(Config, st, utils.DefaultTransformerRegistry).Run(ctx); err != nil {
+		log.Fatal().Err(err).Msg("cannot export database")
+	}
+	log.Info().
+		Str("exportId", exportId).
+		Str("format", Config.Export.Format).
+		Msg("export is done")
+}
+
+func init() {
+	Cmd.Flags().StringVarP(
+		&format, "format", "", export.FormatParquet,
+		"export format: one of "+strings.Join(export.GetFormatNames(), ", "),
+	)
+	Cmd.Flags().IntVarP(
+		&jobs, "jobs", "j", 1, "use this many parallel jobs to dump",
+	)
+}
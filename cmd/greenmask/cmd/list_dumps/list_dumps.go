@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/olekukonko/tablewriter"
@@ -29,6 +30,7 @@ import (
 	"github.com/greenmaskio/greenmask/internal/domains"
 	"github.com/greenmaskio/greenmask/internal/storages"
 	"github.com/greenmaskio/greenmask/internal/storages/builder"
+	tarStorage "github.com/greenmaskio/greenmask/internal/storages/tar"
 	"github.com/greenmaskio/greenmask/internal/utils/dumpstatus"
 	"github.com/greenmaskio/greenmask/internal/utils/logger"
 )
@@ -77,10 +79,16 @@ func listDumps(quiet bool) error {
 		log.Fatal().Err(err).Msg("")
 	}
 
-	_, dirs, err := st.ListDir(ctx)
+	files, dirs, err := st.ListDir(ctx)
 	if err != nil {
 		return err
 	}
+	// The dumps in tar output format are stored as the single objects
+	for _, f := range files {
+		if strings.HasSuffix(f, tarStorage.Extension) {
+			dirs = append(dirs, tarStorage.NewStorage(st, f))
+		}
+	}
 
 	if quiet {
 		return printDumpIDsSorted(dirs)
@@ -17,8 +17,6 @@ package restore
 import (
 	"context"
 	"fmt"
-	"path"
-	"slices"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
@@ -26,6 +24,8 @@ import (
 	"github.com/spf13/viper"
 
 	cmdInternals "github.com/greenmaskio/greenmask/internal/db/postgres/cmd"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/custom"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
 	pgDomains "github.com/greenmaskio/greenmask/internal/domains"
 	"github.com/greenmaskio/greenmask/internal/storages"
 	"github.com/greenmaskio/greenmask/internal/storages/builder"
@@ -61,12 +61,24 @@ var (
 				log.Fatal().Err(err).Msg("")
 			}
 
-			st = st.SubStorage(dumpId, true)
+			dumpsSt := st
+			st, err = cmdInternals.GetDumpStorage(ctx, dumpsSt, dumpId)
+			if err != nil {
+				log.Fatal().Err(err).Msg("")
+			}
 
 			restore := cmdInternals.NewRestore(
 				Config.Common.PgBinPath, st, &Config.Restore, Config.Restore.Scripts,
 				Config.Common.TempDirectory,
 			)
+			restore.SetDumpsStorage(dumpsSt)
+			if len(Config.Restore.Transformation) > 0 {
+				err = custom.BootstrapCustomTransformers(ctx, utils.DefaultTransformerRegistry, Config.CustomTransformers)
+				if err != nil {
+					log.Fatal().Err(err).Msg("error bootstraping custom transformers")
+				}
+			}
+			restore.SetTransformerRegistry(utils.DefaultTransformerRegistry)
 
 			log.Info().
 				Str("dumpId", dumpId).
@@ -80,49 +92,16 @@ var (
 )
 
 func getDumpId(ctx context.Context, st storages.Storager, dumpId string) (string, error) {
-	if dumpId == latestDumpName {
-		var backupNames []string
-
-		_, dirs, err := st.ListDir(ctx)
-		if err != nil {
-			log.Fatal().Err(err).Msg("cannot walk through directory")
-		}
-		if len(dirs) == 0 {
-			return "", errNoDumpFoundInStorage
-		}
-
-		for _, dir := range dirs {
-			exists, err := dir.Exists(ctx, cmdInternals.MetadataJsonFileName)
-			if err != nil {
-				log.Fatal().Err(err).Msg("cannot check file existence")
-			}
-			if exists {
-				backupNames = append(backupNames, dir.Dirname())
-			}
-		}
-
-		slices.SortFunc(
-			backupNames, func(a, b string) int {
-				if a > b {
-					return -1
-				}
-				return 1
-			},
-		)
-		dumpId = backupNames[0]
-	} else {
-		exists, err := st.Exists(ctx, path.Join(dumpId, cmdInternals.MetadataJsonFileName))
-		if err != nil {
-			log.Fatal().
-				Err(err).
-				Msg("cannot check file existence")
-		}
-		if !exists {
-			log.Fatal().
-				Err(err).
-				Str("DumpId", dumpId).
-				Msg("dump with provided id is not found")
-		}
+	if dumpId != latestDumpName {
+		return dumpId, nil
+	}
+	// The latest dump is either the directory or the tar object
+	dumpId, err := cmdInternals.GetLatestDumpId(ctx, st)
+	if err != nil {
+		return "", err
+	}
+	if dumpId == "" {
+		return "", errNoDumpFoundInStorage
 	}
 	return dumpId, nil
 }
@@ -195,6 +174,13 @@ func init() {
 		"use OVERRIDING SYSTEM VALUE clause for INSERTs",
 	)
 	Cmd.Flags().BoolP("no-blobs", "B", false, "exclude large objects from restoration (large objects will be created as empty placeholders)")
+	Cmd.Flags().StringP(
+		"verify-checksums", "", "",
+		"verify checksums of the dump objects: before - before the restoration, during - while restoring, both - before and during",
+	)
+	if err := viper.BindPFlag("restore.verify_checksums", Cmd.Flags().Lookup("verify-checksums")); err != nil {
+		log.Fatal().Err(err).Msg("fatal")
+	}
 
 	// Connection options:
 	Cmd.Flags().StringP("host", "h", "/var/run/postgres", "database server host or socket directory")
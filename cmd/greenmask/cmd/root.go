@@ -25,14 +25,22 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/greenmaskio/greenmask/cmd/greenmask/cmd/convert"
+	"github.com/greenmaskio/greenmask/cmd/greenmask/cmd/coverage"
+	"github.com/greenmaskio/greenmask/cmd/greenmask/cmd/decrypt_column"
 	"github.com/greenmaskio/greenmask/cmd/greenmask/cmd/delete"
 	"github.com/greenmaskio/greenmask/cmd/greenmask/cmd/dump"
+	"github.com/greenmaskio/greenmask/cmd/greenmask/cmd/export"
 	"github.com/greenmaskio/greenmask/cmd/greenmask/cmd/list_dumps"
 	"github.com/greenmaskio/greenmask/cmd/greenmask/cmd/list_transformers"
 	"github.com/greenmaskio/greenmask/cmd/greenmask/cmd/restore"
+	"github.com/greenmaskio/greenmask/cmd/greenmask/cmd/scan"
 	"github.com/greenmaskio/greenmask/cmd/greenmask/cmd/show_dump"
 	"github.com/greenmaskio/greenmask/cmd/greenmask/cmd/show_transformer"
+	"github.com/greenmaskio/greenmask/cmd/greenmask/cmd/sync"
+	"github.com/greenmaskio/greenmask/cmd/greenmask/cmd/transform"
 	"github.com/greenmaskio/greenmask/cmd/greenmask/cmd/validate"
+	"github.com/greenmaskio/greenmask/cmd/greenmask/cmd/verify"
 	pgDomains "github.com/greenmaskio/greenmask/internal/domains"
 	configUtils "github.com/greenmaskio/greenmask/internal/utils/config"
 )
@@ -99,6 +107,14 @@ func init() {
 	RootCmd.AddCommand(list_transformers.Cmd)
 	RootCmd.AddCommand(validate.Cmd)
 	RootCmd.AddCommand(show_transformer.Cmd)
+	RootCmd.AddCommand(scan.Cmd)
+	RootCmd.AddCommand(coverage.Cmd)
+	RootCmd.AddCommand(verify.Cmd)
+	RootCmd.AddCommand(sync.Cmd)
+	RootCmd.AddCommand(transform.Cmd)
+	RootCmd.AddCommand(export.Cmd)
+	RootCmd.AddCommand(convert.Cmd)
+	RootCmd.AddCommand(decrypt_column.Cmd)
 
 	if err := viper.BindPFlag("log.format", RootCmd.PersistentFlags().Lookup("log-format")); err != nil {
 		log.Fatal().Err(err).Msg("")
@@ -0,0 +1,99 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scan
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	cmdInternals "github.com/greenmaskio/greenmask/internal/db/postgres/cmd"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/utils/logger"
+)
+
+var (
+	Cmd = &cobra.Command{
+		Use:   "scan",
+		Short: "sample tables data, detect personal data and propose transformation config",
+		Run:   run,
+	}
+	Config = domains.NewConfig()
+)
+
+func run(cmd *cobra.Command, args []string) {
+	if err := logger.SetLogLevel(Config.Log.Level, Config.Log.Format); err != nil {
+		log.Err(err).Msg("")
+	}
+
+	if Config.Scan.RowsLimit <= 0 {
+		log.Fatal().
+			Msgf("--rows-limit must be greater than 0 got %d", Config.Scan.RowsLimit)
+	}
+
+	if Config.Scan.MinConfidence < 0 || Config.Scan.MinConfidence > 1 {
+		log.Fatal().
+			Msgf("--min-confidence must be in range [0, 1] got %f", Config.Scan.MinConfidence)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	scanCmd := cmdInternals.NewScan(Config, utils.DefaultTransformerRegistry)
+	if err := scanCmd.Run(ctx); err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+}
+
+func init() {
+	tableFlagName := "table"
+	Cmd.Flags().StringSlice(
+		tableFlagName, nil, "Scan only specific tables",
+	)
+	flag := Cmd.Flags().Lookup(tableFlagName)
+	if err := viper.BindPFlag("scan.tables", flag); err != nil {
+		log.Fatal().Err(err).Msg("fatal")
+	}
+
+	rowsLimitFlagName := "rows-limit"
+	Cmd.Flags().Uint64(
+		rowsLimitFlagName, 100, "Number of rows sampled from each table",
+	)
+	flag = Cmd.Flags().Lookup(rowsLimitFlagName)
+	if err := viper.BindPFlag("scan.rows_limit", flag); err != nil {
+		log.Fatal().Err(err).Msg("fatal")
+	}
+
+	minConfidenceFlagName := "min-confidence"
+	Cmd.Flags().Float64(
+		minConfidenceFlagName, 0.5, "Minimal detection confidence in range [0, 1] for proposing the transformer",
+	)
+	flag = Cmd.Flags().Lookup(minConfidenceFlagName)
+	if err := viper.BindPFlag("scan.min_confidence", flag); err != nil {
+		log.Fatal().Err(err).Msg("fatal")
+	}
+
+	outputFlagName := "output"
+	Cmd.Flags().String(
+		outputFlagName, "-", "File for the proposed transformation config. Use - for stdout",
+	)
+	flag = Cmd.Flags().Lookup(outputFlagName)
+	if err := viper.BindPFlag("scan.output", flag); err != nil {
+		log.Fatal().Err(err).Msg("fatal")
+	}
+}
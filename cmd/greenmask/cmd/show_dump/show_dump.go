@@ -16,8 +16,6 @@ package show_dump
 
 import (
 	"context"
-	"path"
-	"slices"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
@@ -56,38 +54,15 @@ var (
 				log.Fatal().Err(err).Msg("error building storage")
 			}
 
-			if args[0] == latestDumpName {
-				var backupNames []string
-
-				_, dirs, err := st.ListDir(ctx)
-				if err != nil {
-					log.Fatal().Err(err).Msg("cannot walk through directory")
-				}
-				for _, dir := range dirs {
-					exists, err := dir.Exists(ctx, cmdInternals.MetadataJsonFileName)
-					if err != nil {
-						log.Fatal().Err(err).Msg("cannot check file existence")
-					}
-					if exists {
-						backupNames = append(backupNames, dir.Dirname())
-					}
-				}
-
-				slices.SortFunc(backupNames, func(a, b string) int {
-					if a > b {
-						return -1
-					}
-					return 1
-				})
-				dumpId = backupNames[0]
-			} else {
-				dumpId = args[0]
-				exists, err := st.Exists(ctx, path.Join(dumpId, cmdInternals.MetadataJsonFileName))
+			dumpId = args[0]
+			if dumpId == latestDumpName {
+				// The latest dump is either the directory or the tar object
+				dumpId, err = cmdInternals.GetLatestDumpId(ctx, st)
 				if err != nil {
-					log.Fatal().Err(err).Msg("cannot check file existence")
+					log.Fatal().Err(err).Msg("")
 				}
-				if !exists {
-					log.Fatal().Msgf("choose another dump %s is failed", dumpId)
+				if dumpId == "" {
+					log.Fatal().Msg("no dumps found in storage")
 				}
 			}
 
@@ -0,0 +1,92 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sync
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	cmdInternals "github.com/greenmaskio/greenmask/internal/db/postgres/cmd"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/storages/builder"
+	"github.com/greenmaskio/greenmask/internal/utils/logger"
+)
+
+var (
+	Cmd = &cobra.Command{
+		Use:   "sync",
+		Short: "dump the database, transform data, and stream it straight into the target database",
+		Run:   run,
+	}
+	Config = domains.NewConfig()
+	save   bool
+	jobs   int
+)
+
+func run(cmd *cobra.Command, args []string) {
+	if err := logger.SetLogLevel(Config.Log.Level, Config.Log.Format); err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+
+	if Config.Common.TempDirectory == "" {
+		log.Fatal().Msg("common.tmp_dir cannot be empty")
+	}
+
+	// The dump and restore options are read from the config. The jobs flag overrides both of them
+	if cmd.Flags().Changed("jobs") || Config.Dump.PgDumpOptions.Jobs < 1 {
+		Config.Dump.PgDumpOptions.Jobs = jobs
+	}
+	if cmd.Flags().Changed("jobs") || Config.Restore.PgRestoreOptions.Jobs < 1 {
+		Config.Restore.PgRestoreOptions.Jobs = jobs
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var st storages.Storager
+	if save {
+		dumpsSt, err := builder.GetStorage(ctx, &Config.Storage, &Config.Log)
+		if err != nil {
+			log.Fatal().Err(err).Msg("fatal")
+		}
+		dumpId := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		st = dumpsSt.SubStorage(dumpId, true)
+		log.Info().
+			Str("dumpId", dumpId).
+			Msg("the dump is saved into the storage")
+	}
+
+	if err := cmdInternals.NewSync(Config, st, utils.DefaultTransformerRegistry).Run(ctx); err != nil {
+		log.Fatal().Err(err).Msg("cannot sync database")
+	}
+}
+
+func init() {
+	Cmd.Flags().BoolVarP(
+		&save, "save", "", false, "write the dump into the storage at the same time",
+	)
+	Cmd.Flags().IntVarP(
+		&jobs, "jobs", "j", 1, "use this many parallel jobs to dump and restore",
+	)
+	Cmd.Flags().StringVarP(
+		&Config.Dump.PgDumpOptions.Description, "description", "", "", "add a description for the saved dump",
+	)
+}
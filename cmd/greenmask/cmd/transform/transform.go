@@ -0,0 +1,81 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transform
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	cmdInternals "github.com/greenmaskio/greenmask/internal/db/postgres/cmd"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/storages/builder"
+	"github.com/greenmaskio/greenmask/internal/utils/logger"
+)
+
+var (
+	Cmd = &cobra.Command{
+		Use:   "transform",
+		Short: "apply the transformation config to an existing dump and save the result as a new dump",
+		Run:   run,
+	}
+	Config = domains.NewConfig()
+	fromId string
+	jobs   int
+)
+
+func run(cmd *cobra.Command, args []string) {
+	if err := logger.SetLogLevel(Config.Log.Level, Config.Log.Format); err != nil {
+		log.Fatal().Err(err).Msg("error setting up logger")
+	}
+
+	// The jobs flag overrides the dump jobs from the config
+	if cmd.Flags().Changed("jobs") || Config.Dump.PgDumpOptions.Jobs < 1 {
+		Config.Dump.PgDumpOptions.Jobs = jobs
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	st, err := builder.GetStorage(ctx, &Config.Storage, &Config.Log)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error building storage")
+	}
+
+	dumpId := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	transform := cmdInternals.NewTransform(Config, st, fromId, dumpId, utils.DefaultTransformerRegistry)
+	if err = transform.Run(ctx); err != nil {
+		log.Fatal().Err(err).Msg("cannot transform dump")
+	}
+	log.Info().
+		Str("DumpId", dumpId).
+		Msg("dump is transformed")
+}
+
+func init() {
+	Cmd.Flags().StringVarP(&fromId, "from", "", "", "id of the dump to transform or \"latest\"")
+	if err := Cmd.MarkFlagRequired("from"); err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+	Cmd.Flags().IntVarP(
+		&jobs, "jobs", "j", 1, "number of data files transformed in parallel",
+	)
+	Cmd.Flags().StringVarP(
+		&Config.Dump.PgDumpOptions.Description, "description", "", "", "add a description for the new dump",
+	)
+}
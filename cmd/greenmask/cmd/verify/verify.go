@@ -0,0 +1,104 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	cmdInternals "github.com/greenmaskio/greenmask/internal/db/postgres/cmd"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/storages/builder"
+	"github.com/greenmaskio/greenmask/internal/utils/logger"
+)
+
+const nonZeroExitCode = 1
+
+var (
+	Cmd = &cobra.Command{
+		Use:   "verify [flags] dumpId|latest",
+		Args:  cobra.ExactArgs(1),
+		Short: "re-read every object of the dump from the storage and verify its checksum",
+		Run:   run,
+	}
+	Config = domains.NewConfig()
+	format string
+	jobs   int
+)
+
+func run(cmd *cobra.Command, args []string) {
+	if err := logger.SetLogLevel(Config.Log.Level, Config.Log.Format); err != nil {
+		log.Fatal().Err(err).Msg("error setting up logger")
+	}
+	if format != cmdInternals.TextFormat && format != cmdInternals.JsonFormat {
+		log.Fatal().Str("RequestedFormat", format).Msg("unknown --format value")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	st, err := builder.GetStorage(ctx, &Config.Storage, &Config.Log)
+	if err != nil {
+		log.Fatal().Err(err).Msg("error building storage")
+	}
+
+	results, err := cmdInternals.NewVerify(st, args[0], jobs).Run(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("")
+	}
+
+	failed := cmdInternals.GetFailedObjects(results)
+	switch format {
+	case cmdInternals.JsonFormat:
+		if err = json.NewEncoder(os.Stdout).Encode(results); err != nil {
+			log.Fatal().Err(err).Msg("json render error")
+		}
+	default:
+		printFailedObjects(failed)
+	}
+
+	if len(failed) > 0 {
+		log.Error().
+			Int("ObjectsCount", len(results)).
+			Int("FailedCount", len(failed)).
+			Msg("dump is corrupted")
+		os.Exit(nonZeroExitCode)
+	}
+	log.Info().
+		Int("ObjectsCount", len(results)).
+		Msg("dump is valid")
+}
+
+func printFailedObjects(failed []*cmdInternals.ObjectVerification) {
+	if len(failed) == 0 {
+		return
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"dump id", "stored in", "file name", "status", "error"})
+	for _, f := range failed {
+		table.Append([]string{fmt.Sprintf("%d", f.DumpId), f.StoredIn, f.FileName, f.Status, f.Error})
+	}
+	table.Render()
+}
+
+func init() {
+	Cmd.Flags().StringVarP(&format, "format", "f", cmdInternals.TextFormat, "output format [text|json]")
+	Cmd.Flags().IntVarP(&jobs, "jobs", "j", 1, "number of objects verified in parallel")
+}
@@ -0,0 +1,558 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/pgcopy"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/restorers"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/toc"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
+	"github.com/greenmaskio/greenmask/internal/utils/reader"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+const (
+	// ConvertFormatSql - the plain SQL script that can be executed by psql
+	ConvertFormatSql = "sql"
+	// ConvertFormatCustom - the pg_dump custom format archive that can be restored by pg_restore
+	ConvertFormatCustom = "custom"
+)
+
+const databaseDesc = "DATABASE"
+
+// customCopyDataTerminator - the termination sequence of the table data block written by pg_dump
+var customCopyDataTerminator = []byte("\\.\n\n\n")
+
+var (
+	ErrUnsupportedConvertFormat = errors.New("unsupported output format")
+	ErrCompressedCustomArchive  = errors.New("custom format archive cannot be compressed by the codec")
+)
+
+// sqlScriptHeader - the session settings of the plain SQL script. The string literals of the INSERT statements and
+// large objects require standard_conforming_strings to be on
+const sqlScriptHeader = `SET statement_timeout = 0;
+SET lock_timeout = 0;
+SET idle_in_transaction_session_timeout = 0;
+SET standard_conforming_strings = on;
+SELECT pg_catalog.set_config('search_path', '', false);
+SET check_function_bodies = false;
+SET xmloption = content;
+SET client_min_messages = warning;
+SET row_security = off;
+
+`
+
+// ConvertOptions - the options of the dump conversion
+type ConvertOptions struct {
+	// Format - the output format
+	Format string
+	// Inserts - write the table data as INSERT statements instead of COPY
+	Inserts bool
+	// OverridingSystemValue - add OVERRIDING SYSTEM VALUE clause to the INSERT statements
+	OverridingSystemValue bool
+	// CompressionLevel - zlib compression level of the custom format archive data. 0 disables the compression and
+	// -1 is the default level
+	CompressionLevel int
+}
+
+// Convert - converts the dump into another format. The dump is read from the storage and the result is streamed into
+// the writer
+type Convert struct {
+	dumpsSt storages.Storager
+	st      storages.Storager
+	dumpId  string
+	opt     *ConvertOptions
+	// restore - the restore command that is used for reading the dump and resolving the table data files
+	restore *Restore
+}
+
+// NewConvert - creates the convert command of the dump with the id in the dumps storage. The dumps storage is
+// required for the incremental dumps that reference the table data of the previous dumps
+func NewConvert(dumpsSt storages.Storager, dumpId string, opt *ConvertOptions) *Convert {
+	return &Convert{
+		dumpsSt: dumpsSt,
+		dumpId:  dumpId,
+		opt:     opt,
+	}
+}
+
+// Run - writes the converted dump into w. The dump id "latest" is resolved to the latest completed dump
+func (c *Convert) Run(ctx context.Context, w io.Writer) error {
+	switch c.opt.Format {
+	case ConvertFormatSql:
+	case ConvertFormatCustom:
+		if c.opt.CompressionLevel < -1 || c.opt.CompressionLevel > 9 {
+			return fmt.Errorf("invalid compression level %d: expected value from -1 to 9", c.opt.CompressionLevel)
+		}
+	default:
+		return fmt.Errorf("%w \"%s\"", ErrUnsupportedConvertFormat, c.opt.Format)
+	}
+	if c.dumpId == latestDumpId {
+		dumpId, err := getLatestDumpId(ctx, c.dumpsSt)
+		if err != nil {
+			return err
+		}
+		if dumpId == "" {
+			return errors.New("no dumps found in storage")
+		}
+		c.dumpId = dumpId
+	}
+	st, err := GetDumpStorage(ctx, c.dumpsSt, c.dumpId)
+	if err != nil {
+		return err
+	}
+	c.st = st
+	c.restore = NewRestore("", c.st, &domains.Restore{}, nil, "")
+	c.restore.SetDumpsStorage(c.dumpsSt)
+
+	if err := c.restore.readMetadata(ctx); err != nil {
+		return fmt.Errorf("cannot read metadata: %w", err)
+	}
+	if err := c.restore.readTocDatFile(ctx); err != nil {
+		return fmt.Errorf("cannot read toc: %w", err)
+	}
+
+	if c.opt.Format == ConvertFormatCustom {
+		if err := c.writeCustomArchive(ctx, w); err != nil {
+			return fmt.Errorf("cannot write custom archive: %w", err)
+		}
+		return nil
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := c.writeSqlScript(ctx, bw); err != nil {
+		return fmt.Errorf("cannot write sql script: %w", err)
+	}
+	return bw.Flush()
+}
+
+// RunToStorage - writes the converted dump into the file in the storage. The file is compressed if its name has the
+// codec extension, for instance "dump.sql.gz". The custom format archive is written as a single object. The codec
+// extension is rejected for it because the compressed archive cannot be read by pg_restore
+func (c *Convert) RunToStorage(ctx context.Context, st storages.Storager, fileName string) error {
+	codec := ioutils.GetCodecByFileName(fileName)
+	if c.opt.Format == ConvertFormatCustom && codec != ioutils.CodecNone {
+		return fmt.Errorf(
+			"%w: file %s has the %s codec extension: use --compress for the custom format archive",
+			ErrCompressedCustomArchive, fileName, codec,
+		)
+	}
+	w, pr, err := ioutils.NewCompressionPipe(&ioutils.CompressionSettings{
+		Codec: codec,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot create compression pipe: %w", err)
+	}
+	eg, gtx := errgroup.WithContext(ctx)
+	eg.Go(func() error {
+		defer func() {
+			if err := pr.Close(); err != nil {
+				log.Warn().Err(err).Msg("error closing convert reader")
+			}
+		}()
+		if err := st.PutObject(gtx, fileName, pr); err != nil {
+			return fmt.Errorf("cannot write object: %w", err)
+		}
+		return nil
+	})
+	eg.Go(func() error {
+		defer func() {
+			if err := w.Close(); err != nil {
+				log.Warn().Err(err).Msg("error closing convert writer")
+			}
+		}()
+		return c.Run(gtx, w)
+	})
+	return eg.Wait()
+}
+
+// writeSqlScript - writes the TOC entries in the section order: the pre-data DDL, the data and the post-data DDL
+func (c *Convert) writeSqlScript(ctx context.Context, w *bufio.Writer) error {
+	h := c.restore.tocObj.Header
+	if _, err := w.WriteString("--\n-- PostgreSQL database dump\n--\n\n"); err != nil {
+		return err
+	}
+	if h.ArchiveRemoteVersion != nil {
+		if _, err := fmt.Fprintf(w, "-- Dumped from database version %s\n", *h.ArchiveRemoteVersion); err != nil {
+			return err
+		}
+	}
+	if _, err := w.WriteString("-- Converted by greenmask\n\n" + sqlScriptHeader); err != nil {
+		return err
+	}
+
+	sections := [][]int32{{toc.SectionNone, toc.SectionPreData}, {toc.SectionData}, {toc.SectionPostData}}
+	for _, section := range sections {
+		for _, entry := range c.restore.tocObj.Entries {
+			if !slices.Contains(section, entry.Section) {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+			if err := c.writeSqlEntry(ctx, w, entry); err != nil {
+				return fmt.Errorf("cannot write entry %s: %w", getEntryDebugInfo(entry), err)
+			}
+		}
+	}
+
+	_, err := w.WriteString("--\n-- PostgreSQL database dump complete\n--\n\n")
+	return err
+}
+
+func (c *Convert) writeSqlEntry(ctx context.Context, w *bufio.Writer, entry *toc.Entry) error {
+	if entry.Desc == nil {
+		return nil
+	}
+	switch *entry.Desc {
+	case toc.TableDataDesc:
+		dataEntries, st, err := c.restore.resolveTableData(ctx, entry)
+		if err != nil {
+			return fmt.Errorf("cannot resolve table data: %w", err)
+		}
+		for _, dataEntry := range dataEntries {
+			if err = c.writeTableData(ctx, w, dataEntry, st); err != nil {
+				return err
+			}
+		}
+		return nil
+	case toc.BlobsDesc:
+		if err := writeEntryComment(w, entry, "Data for "); err != nil {
+			return err
+		}
+		br := restorers.NewBlobsRestorer(entry, c.st, c.restore.metadata.Header.Codec, false)
+		return br.WriteScript(ctx, w)
+	case databaseDesc, "DATABASE PROPERTIES":
+		// The script is executed in the current database
+		log.Debug().
+			Int32("DumpId", entry.DumpId).
+			Msg("database definition is skipped")
+		return nil
+	}
+
+	if entry.Defn == nil || *entry.Defn == "" {
+		return nil
+	}
+	if err := writeEntryComment(w, entry, ""); err != nil {
+		return err
+	}
+	if _, err := w.WriteString(*entry.Defn + "\n"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// writeTableData - writes the table data file as COPY block or INSERT statements
+func (c *Convert) writeTableData(ctx context.Context, w *bufio.Writer, entry *toc.Entry, st storages.Storager) error {
+	if entry.FileName == nil || entry.CopyStmt == nil {
+		return errors.New("table data entry does not have file name or copy statement")
+	}
+	var t *toolkit.Table
+	if c.opt.Inserts {
+		var err error
+		if t, err = c.restore.getTableDefinitionFromMeta(entry.DumpId); err != nil {
+			return fmt.Errorf("cannot get table definition from meta: %w", err)
+		}
+	}
+
+	r, err := openTableData(ctx, st, *entry.FileName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			log.Warn().Err(err).Msg("error closing table data file")
+		}
+	}()
+
+	if err = writeEntryComment(w, entry, "Data for "); err != nil {
+		return err
+	}
+	if t != nil {
+		err = writeInsertStatements(w, entry, t, r, c.opt.OverridingSystemValue)
+	} else {
+		err = writeCopyBlock(w, entry, r)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot write table data %s: %w", *entry.FileName, err)
+	}
+	return nil
+}
+
+// writeCustomArchive - writes the dump as the pg_dump custom format archive. If w is seekable the TOC of the archive
+// is rewritten with the data offsets after the data is written. Otherwise the offsets are left unset in the same way
+// as pg_dump does when it writes into the pipe
+func (c *Convert) writeCustomArchive(ctx context.Context, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	var out io.Writer = bw
+	if ws, ok := w.(io.WriteSeeker); ok {
+		out = newBufferedWriteSeeker(ws, bw)
+	}
+	cw := toc.NewCustomWriter(out, c.restore.tocObj, c.opt.CompressionLevel)
+	if err := cw.WriteToc(); err != nil {
+		return err
+	}
+	// pg_dump writes the data blocks in the TOC order
+	for _, entry := range c.restore.tocObj.Entries {
+		if entry.Desc == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		var err error
+		switch *entry.Desc {
+		case toc.TableDataDesc:
+			err = c.writeCustomTableData(ctx, cw, entry)
+		case toc.BlobsDesc:
+			err = writeCustomBlobs(ctx, cw, entry, c.st, c.restore.metadata.Header.Codec)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("cannot write entry %s: %w", getEntryDebugInfo(entry), err)
+		}
+	}
+	if err := cw.Close(); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("cannot flush archive: %w", err)
+	}
+	return nil
+}
+
+// writeCustomTableData - writes the table data as a single data block. The chunks of the table are concatenated and
+// terminated once
+func (c *Convert) writeCustomTableData(ctx context.Context, cw *toc.CustomWriter, entry *toc.Entry) error {
+	dataEntries, st, err := c.restore.resolveTableData(ctx, entry)
+	if err != nil {
+		return fmt.Errorf("cannot resolve table data: %w", err)
+	}
+	w, err := cw.StartData(entry.DumpId)
+	if err != nil {
+		return err
+	}
+	for _, dataEntry := range dataEntries {
+		if dataEntry.FileName == nil {
+			return errors.New("table data entry does not have file name")
+		}
+		r, err := openTableData(ctx, st, *dataEntry.FileName)
+		if err != nil {
+			return err
+		}
+		err = writeCustomCopyData(w, r)
+		if closeErr := r.Close(); closeErr != nil {
+			log.Warn().Err(closeErr).Msg("error closing table data file")
+		}
+		if err != nil {
+			return fmt.Errorf("cannot write table data %s: %w", *dataEntry.FileName, err)
+		}
+	}
+	if _, err = w.Write(customCopyDataTerminator); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// writeCustomCopyData - writes the COPY data lines without the termination sequence, so the data of the table chunks
+// can be concatenated into a single data block
+func writeCustomCopyData(w io.Writer, r io.Reader) error {
+	return readCopyData(r, func(line []byte) error {
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+		_, err := w.Write([]byte{'\n'})
+		return err
+	}, func() error {
+		return nil
+	})
+}
+
+// writeCustomBlobs - writes the large objects of the entry stored in st as the blobs block
+func writeCustomBlobs(
+	ctx context.Context, cw *toc.CustomWriter, entry *toc.Entry, st storages.Storager, codec string,
+) error {
+	if err := cw.StartBlobs(entry.DumpId); err != nil {
+		return err
+	}
+	br := restorers.NewBlobsRestorer(entry, st, codec, false)
+	err := br.ReadLargeObjects(ctx, func(oid uint32, r io.Reader) error {
+		w, err := cw.StartBlob(toc.Oid(oid))
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(w, r); err != nil {
+			return err
+		}
+		return w.Close()
+	})
+	if err != nil {
+		return err
+	}
+	return cw.EndBlobs()
+}
+
+// openTableData - opens the table data file and decompresses it using the codec of the file extension
+func openTableData(ctx context.Context, st storages.Storager, fileName string) (io.ReadCloser, error) {
+	src, err := st.GetObject(ctx, fileName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open %s: %w", fileName, err)
+	}
+	r, err := ioutils.NewCompressionReader(src, ioutils.GetCodecByFileName(fileName), false)
+	if err != nil {
+		if err := src.Close(); err != nil {
+			log.Warn().Err(err).Msg("error closing table data file")
+		}
+		return nil, fmt.Errorf("cannot create decompression reader: %w", err)
+	}
+	return r, nil
+}
+
+// bufferedWriteSeeker - buffers the writes into the output and flushes the buffer before seeking
+type bufferedWriteSeeker struct {
+	ws io.WriteSeeker
+	bw *bufio.Writer
+}
+
+func newBufferedWriteSeeker(ws io.WriteSeeker, bw *bufio.Writer) *bufferedWriteSeeker {
+	return &bufferedWriteSeeker{ws: ws, bw: bw}
+}
+
+func (b *bufferedWriteSeeker) Write(p []byte) (int, error) {
+	return b.bw.Write(p)
+}
+
+func (b *bufferedWriteSeeker) Seek(offset int64, whence int) (int64, error) {
+	if err := b.bw.Flush(); err != nil {
+		return 0, err
+	}
+	return b.ws.Seek(offset, whence)
+}
+
+// writeCopyBlock - writes COPY ... FROM stdin statement followed by the table data and the termination sequence
+func writeCopyBlock(w *bufio.Writer, entry *toc.Entry, r io.Reader) error {
+	if _, err := w.WriteString(*entry.CopyStmt); err != nil {
+		return err
+	}
+	return readCopyData(r, func(line []byte) error {
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+		return w.WriteByte('\n')
+	}, func() error {
+		_, err := w.WriteString("\\.\n\n\n")
+		return err
+	})
+}
+
+// writeInsertStatements - writes the INSERT statement for each row of the table data
+func writeInsertStatements(
+	w *bufio.Writer, entry *toc.Entry, t *toolkit.Table, r io.Reader, overridingSystemValue bool,
+) error {
+	var rowIdx int
+	columnsCount := len(slices.DeleteFunc(slices.Clone(t.Columns), func(c *toolkit.Column) bool {
+		return c.IsGenerated
+	}))
+	row := pgcopy.NewRow(pgcopy.UseDynamicSize)
+	return readCopyData(r, func(line []byte) error {
+		rowIdx++
+		// The row must be checked before decoding, because the dynamic row keeps the columns of the previous rows
+		if n := bytes.Count(line, []byte{pgcopy.DefaultCopyDelimiter}) + 1; n != columnsCount {
+			return fmt.Errorf("row %d has %d columns but the table has %d columns", rowIdx, n, columnsCount)
+		}
+		if err := row.Decode(line); err != nil {
+			return fmt.Errorf("cannot decode row %d: %w", rowIdx, err)
+		}
+		stmt, err := restorers.GenerateInsertStmt(entry, t, row, overridingSystemValue)
+		if err != nil {
+			return fmt.Errorf("row %d: %w", rowIdx, err)
+		}
+		if _, err = w.WriteString(stmt + "\n"); err != nil {
+			return err
+		}
+		return nil
+	}, func() error {
+		_, err := w.WriteString("\n\n")
+		return err
+	})
+}
+
+// readCopyData - calls processLine for each line of the table data until the termination sequence and then calls
+// complete
+func readCopyData(r io.Reader, processLine func(line []byte) error, complete func() error) error {
+	buf := bufio.NewReader(r)
+	var line []byte
+	var err error
+	for {
+		line, err = reader.ReadLine(buf, line)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("error reading table data: %w", err)
+		}
+		if slices.Equal(line, pgcopy.DefaultCopyTerminationSeq) {
+			break
+		}
+		if err = processLine(line); err != nil {
+			return err
+		}
+	}
+	return complete()
+}
+
+// writeEntryComment - writes the comment that describes the TOC entry in the same way as pg_dump does
+func writeEntryComment(w *bufio.Writer, entry *toc.Entry, prefix string) error {
+	value := func(v *string) string {
+		if v == nil || *v == "" {
+			return "-"
+		}
+		return removeEscapeQuotes(*v)
+	}
+	_, err := fmt.Fprintf(
+		w, "--\n-- %sName: %s; Type: %s; Schema: %s; Owner: %s\n--\n\n",
+		prefix, value(entry.Tag), value(entry.Desc), value(entry.Namespace), value(entry.Owner),
+	)
+	return err
+}
+
+func getEntryDebugInfo(entry *toc.Entry) string {
+	var desc, tag string
+	if entry.Desc != nil {
+		desc = *entry.Desc
+	}
+	if entry.Tag != nil {
+		tag = *entry.Tag
+	}
+	return fmt.Sprintf("%d %s %s", entry.DumpId, desc, tag)
+}
@@ -0,0 +1,171 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/toc"
+)
+
+func TestConvert_Run(t *testing.T) {
+	ctx := context.Background()
+	st := newTransformTestDumps(t)
+
+	buf := new(bytes.Buffer)
+	c := NewConvert(st, latestDumpId, &ConvertOptions{Format: ConvertFormatSql})
+	require.NoError(t, c.Run(ctx, buf))
+	assert.Equal(t, "2", c.dumpId)
+	assert.Contains(t, buf.String(), "-- Dumped from database version 16.0\n")
+	assert.Contains(t, buf.String(), sqlScriptHeader)
+	// The data of table users is stored in the referenced dump
+	assert.Contains(
+		t, buf.String(),
+		"--\n-- Data for Name: users; Type: TABLE DATA; Schema: public; Owner: postgres\n--\n\n"+
+			"COPY public.users (id, email) FROM stdin;\n1\talice@example.com\n2\t\\N\n\\.\n\n\n",
+	)
+	assert.Contains(
+		t, buf.String(),
+		"COPY public.orders (id, email) FROM stdin;\n1\torder@example.com\n\\.\n\n\n",
+	)
+}
+
+func TestConvert_Run_Inserts(t *testing.T) {
+	ctx := context.Background()
+	st := newTransformTestDumps(t)
+
+	buf := new(bytes.Buffer)
+	c := NewConvert(st, "2", &ConvertOptions{Format: ConvertFormatSql, Inserts: true})
+	require.NoError(t, c.Run(ctx, buf))
+	assert.Contains(
+		t, buf.String(),
+		`INSERT INTO public.users ("id", "email") VALUES('1', 'alice@example.com');`+"\n"+
+			`INSERT INTO public.users ("id", "email") VALUES('2', NULL);`+"\n",
+	)
+	assert.NotContains(t, buf.String(), "COPY ")
+}
+
+func TestConvert_Run_UnsupportedFormat(t *testing.T) {
+	c := NewConvert(nil, "1", &ConvertOptions{Format: "xml"})
+	require.ErrorIs(t, c.Run(context.Background(), new(bytes.Buffer)), ErrUnsupportedConvertFormat)
+}
+
+func TestConvert_RunToStorage(t *testing.T) {
+	ctx := context.Background()
+	st := newTransformTestDumps(t)
+
+	c := NewConvert(st, "2", &ConvertOptions{Format: ConvertFormatSql})
+	require.NoError(t, c.RunToStorage(ctx, st, "dump.sql.gz"))
+	res := readGzipObject(t, st, "dump.sql.gz")
+	assert.Contains(t, res, "COPY public.orders (id, email) FROM stdin;\n")
+	assert.True(t, strings.HasSuffix(res, "-- PostgreSQL database dump complete\n--\n\n"))
+}
+
+func TestConvert_Run_Custom(t *testing.T) {
+	ctx := context.Background()
+	st := newTransformTestDumps(t)
+
+	// The archive is written into the seekable file, so the TOC is rewritten with the data offsets
+	f, err := os.Create(filepath.Join(t.TempDir(), "dump.custom"))
+	require.NoError(t, err)
+	defer f.Close()
+	c := NewConvert(st, "2", &ConvertOptions{Format: ConvertFormatCustom})
+	require.NoError(t, c.Run(ctx, f))
+
+	archive, err := os.ReadFile(f.Name())
+	require.NoError(t, err)
+	res, err := toc.NewReader(bytes.NewReader(archive)).Read()
+	require.NoError(t, err)
+	assert.Equal(t, toc.ArchCustom, res.Header.Format)
+	assert.Equal(t, toc.PgCompressionNone, res.Header.CompressionSpec.Algorithm)
+	require.Len(t, res.Entries, 2)
+
+	// Each data block starts with the block type and the dumpId followed by the data chunks
+	expected := map[int32]string{
+		10: "1\talice@example.com\n2\t\\N\n\\.\n\n\n",
+		11: "1\torder@example.com\n\\.\n\n\n",
+	}
+	for _, entry := range res.Entries {
+		assert.Nil(t, entry.FileName)
+		require.Equal(t, toc.OffsetPosSet, entry.DataState)
+		block := archive[entry.DataOffset:]
+		assert.Equal(t, toc.BlockData, block[0])
+		assert.Equal(t, []byte{0, byte(entry.DumpId), 0, 0, 0}, block[1:6])
+		data := expected[entry.DumpId]
+		assert.Equal(t, []byte{0, byte(len(data)), 0, 0, 0}, block[6:11])
+		assert.Equal(t, data, string(block[11:11+len(data)]))
+		// The zero length chunk terminates the data
+		assert.Equal(t, []byte{0, 0, 0, 0, 0}, block[11+len(data):16+len(data)])
+	}
+}
+
+func TestConvert_Run_CustomCompressed(t *testing.T) {
+	ctx := context.Background()
+	st := newTransformTestDumps(t)
+
+	require.NoError(t, NewConvert(
+		st, "2", &ConvertOptions{Format: ConvertFormatCustom, CompressionLevel: -1},
+	).RunToStorage(ctx, st, "dump.custom"))
+
+	f, err := st.GetObject(ctx, "dump.custom")
+	require.NoError(t, err)
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	r := bytes.NewReader(data)
+	res, err := toc.NewReader(r).Read()
+	require.NoError(t, err)
+	assert.Equal(t, toc.PgCompressionGzip, res.Header.CompressionSpec.Algorithm)
+	require.Len(t, res.Entries, 2)
+	// The archive is streamed into the storage, so the data offsets are not set and the data blocks follow the TOC
+	for _, entry := range res.Entries {
+		require.Equal(t, toc.OffsetPosNotSet, entry.DataState)
+	}
+	// The data of the single chunk block is the zlib stream
+	block := data[len(data)-r.Len():]
+	assert.Equal(t, []byte{toc.BlockData, 0, byte(res.Entries[0].DumpId), 0, 0, 0}, block[:6])
+	l := int(block[7])
+	zr, err := zlib.NewReader(bytes.NewReader(block[11 : 11+l]))
+	require.NoError(t, err)
+	tableData, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, "1\talice@example.com\n2\t\\N\n\\.\n\n\n", string(tableData))
+}
+
+func TestConvert_RunToStorage_CustomCodecExtension(t *testing.T) {
+	ctx := context.Background()
+	st := newTransformTestDumps(t)
+
+	c := NewConvert(st, "2", &ConvertOptions{Format: ConvertFormatCustom})
+	require.ErrorIs(t, c.RunToStorage(ctx, st, "dump.custom.gz"), ErrCompressedCustomArchive)
+	exists, err := st.Exists(ctx, "dump.custom.gz")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestConvert_Run_CustomInvalidLevel(t *testing.T) {
+	c := NewConvert(nil, "1", &ConvertOptions{Format: ConvertFormatCustom, CompressionLevel: 10})
+	require.ErrorContains(t, c.Run(context.Background(), new(bytes.Buffer)), "invalid compression level")
+}
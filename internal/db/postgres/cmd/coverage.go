@@ -0,0 +1,317 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/rs/zerolog/log"
+
+	runtimeContext "github.com/greenmaskio/greenmask/internal/db/postgres/context"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/entries"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/custom"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+const (
+	// UntransformedColumnStatus - the column is not affected by any transformer
+	UntransformedColumnStatus = "untransformed"
+	// SkippedColumnStatus - the column is listed in skip_auto_anonymize of the table config
+	SkippedColumnStatus = "skipped"
+)
+
+var (
+	ErrSensitiveColumnsNotCovered = errors.New("sensitive columns are not transformed")
+	ErrNoSensitiveColumnSelectors = errors.New("coverage.sensitive selectors are not configured")
+)
+
+// UncoveredColumn - the column that is dumped without transformation
+type UncoveredColumn struct {
+	Schema    string `json:"schema"`
+	Table     string `json:"table"`
+	Column    string `json:"column"`
+	Type      string `json:"type"`
+	Status    string `json:"status"`
+	Sensitive bool   `json:"sensitive"`
+}
+
+// CoverageReport - the transformation coverage of the dumped tables columns
+type CoverageReport struct {
+	Tables           int                `json:"tables"`
+	Columns          int                `json:"columns"`
+	CoveredColumns   int                `json:"covered_columns"`
+	UncoveredColumns []*UncoveredColumn `json:"uncovered_columns"`
+}
+
+// GetSensitive - returns the uncovered columns that are marked as sensitive
+func (r *CoverageReport) GetSensitive() []*UncoveredColumn {
+	var res []*UncoveredColumn
+	for _, c := range r.UncoveredColumns {
+		if c.Sensitive {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// Print - prints the report in the provided format
+func (r *CoverageReport) Print(w io.Writer, format string) error {
+	switch format {
+	case JsonFormat:
+		if err := json.NewEncoder(w).Encode(r); err != nil {
+			return fmt.Errorf("cannot encode coverage report: %w", err)
+		}
+	case TextFormat:
+		var data [][]string
+		for _, c := range r.UncoveredColumns {
+			data = append(data, []string{
+				c.Schema, c.Table, c.Column, c.Type, c.Status, strconv.FormatBool(c.Sensitive),
+			})
+		}
+		table := tablewriter.NewWriter(w)
+		table.SetHeader([]string{"schema", "table", "column", "type", "status", "sensitive"})
+		table.SetFooter([]string{
+			"", "", "", "", "covered",
+			fmt.Sprintf("%d/%d", r.CoveredColumns, r.Columns),
+		})
+		table.AppendBulk(data)
+		table.Render()
+	default:
+		return fmt.Errorf("unknown format %s", format)
+	}
+	return nil
+}
+
+// coverageChecker - marks the untransformed columns as sensitive using the coverage config selectors
+type coverageChecker struct {
+	sensitive []*transformers.ColumnMatcher
+	allowed   []*transformers.ColumnMatcher
+}
+
+func newCoverageChecker(cfg *domains.Coverage) (*coverageChecker, error) {
+	sensitive, err := compileColumnSelectors(cfg.Sensitive)
+	if err != nil {
+		return nil, fmt.Errorf("cannot compile sensitive columns selector: %w", err)
+	}
+	allowed, err := compileColumnSelectors(cfg.Allowed)
+	if err != nil {
+		return nil, fmt.Errorf("cannot compile allowed columns selector: %w", err)
+	}
+	return &coverageChecker{
+		sensitive: sensitive,
+		allowed:   allowed,
+	}, nil
+}
+
+func (cc *coverageChecker) isSensitive(t *toolkit.Table, c *toolkit.Column) bool {
+	isMatched := func(m *transformers.ColumnMatcher) bool {
+		return m.Match(t, c)
+	}
+	return slices.ContainsFunc(cc.sensitive, isMatched) && !slices.ContainsFunc(cc.allowed, isMatched)
+}
+
+// buildCoverageReport - walks through the dumped tables and collects the columns that are not affected by any
+// transformer. Generated columns are not dumped and therefore are not reported
+func buildCoverageReport(
+	cfg *domains.Config, dataSectionObjects []entries.Entry,
+) (*CoverageReport, error) {
+	cc, err := newCoverageChecker(&cfg.Coverage)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &CoverageReport{}
+	for _, obj := range dataSectionObjects {
+		t, ok := obj.(*entries.Table)
+		if !ok || t.RelKind == 'p' {
+			continue
+		}
+		res.Tables++
+
+		affectedColumns := getTableAffectedColumns(t)
+		var skipColumns []string
+		if tableConfig := domains.FindTable(cfg.Dump.Transformation, t.Schema, t.Name); tableConfig != nil {
+			skipColumns = tableConfig.SkipAutoAnonymize
+		}
+
+		for _, c := range t.Columns {
+			if c.IsGenerated {
+				continue
+			}
+			res.Columns++
+			if _, ok := affectedColumns[c.Name]; ok {
+				res.CoveredColumns++
+				continue
+			}
+			status := UntransformedColumnStatus
+			if slices.Contains(skipColumns, c.Name) {
+				status = SkippedColumnStatus
+			}
+			res.UncoveredColumns = append(res.UncoveredColumns, &UncoveredColumn{
+				Schema:    t.Schema,
+				Table:     t.Name,
+				Column:    c.Name,
+				Type:      c.TypeName,
+				Status:    status,
+				Sensitive: cc.isSensitive(t.Table, c),
+			})
+		}
+	}
+	return res, nil
+}
+
+// ValidateRequireCoverage - checks the sensitive columns selectors are configured if the coverage is required.
+// Otherwise, no column is sensitive and the coverage check would always pass
+func ValidateRequireCoverage(cfg *domains.Config) error {
+	if cfg.Dump.RequireCoverage && len(cfg.Coverage.Sensitive) == 0 {
+		return fmt.Errorf("require_coverage is enabled: %w", ErrNoSensitiveColumnSelectors)
+	}
+	return nil
+}
+
+// checkCoverage - fails the dump if any sensitive column is not transformed
+func (d *Dump) checkCoverage() error {
+	report, err := buildCoverageReport(d.config, d.context.DataSectionObjects)
+	if err != nil {
+		return fmt.Errorf("cannot build coverage report: %w", err)
+	}
+	sensitive := report.GetSensitive()
+	for _, c := range sensitive {
+		log.Error().
+			Str("SchemaName", c.Schema).
+			Str("TableName", c.Table).
+			Str("ColumnName", c.Column).
+			Str("Status", c.Status).
+			Msg("sensitive column is not transformed")
+	}
+	if len(sensitive) > 0 {
+		return fmt.Errorf("%d %w", len(sensitive), ErrSensitiveColumnsNotCovered)
+	}
+	log.Info().
+		Int("CoveredColumns", report.CoveredColumns).
+		Int("Columns", report.Columns).
+		Msg("coverage check passed")
+	return nil
+}
+
+// Coverage - reports the dumped columns that are not transformed. Exits with non-zero code if any of them is
+// sensitive
+type Coverage struct {
+	*Dump
+}
+
+func NewCoverage(cfg *domains.Config, registry *utils.TransformerRegistry) *Coverage {
+	return &Coverage{
+		Dump: NewDump(cfg, nil, registry),
+	}
+}
+
+func (c *Coverage) Run(ctx context.Context) (int, error) {
+	if err := custom.BootstrapCustomTransformers(ctx, c.registry, c.config.CustomTransformers); err != nil {
+		return nonZeroExitCode, fmt.Errorf("error bootstraping custom transformers: %w", err)
+	}
+
+	dsn, err := c.pgDumpOptions.GetPgDSN()
+	if err != nil {
+		return nonZeroExitCode, fmt.Errorf("cannot build connection string: %w", err)
+	}
+
+	conn, err := c.connect(ctx, dsn)
+	if err != nil {
+		return nonZeroExitCode, err
+	}
+	defer func() {
+		if err := conn.Close(ctx); err != nil {
+			log.Warn().Err(err)
+		}
+	}()
+
+	tx, err := c.startMainTx(ctx, conn)
+	if err != nil {
+		return nonZeroExitCode, fmt.Errorf("cannot prepare coverage transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			log.Warn().Err(err)
+		}
+	}()
+
+	if err = c.gatherPgFacts(ctx, tx); err != nil {
+		return nonZeroExitCode, fmt.Errorf("error gathering facts: %w", err)
+	}
+
+	c.context, err = runtimeContext.NewRuntimeContext(
+		ctx, tx, &c.config.Dump, c.registry,
+		c.config.Dump.VirtualReferences, c.version,
+	)
+	if err != nil {
+		return nonZeroExitCode, fmt.Errorf("unable to build runtime context: %w", err)
+	}
+	if err = toolkit.PrintValidationWarnings(c.context.Warnings, nil, false); err != nil {
+		return nonZeroExitCode, err
+	}
+	if c.context.IsFatal() {
+		return nonZeroExitCode, fmt.Errorf("fatal validation error")
+	}
+
+	if len(c.config.Coverage.Sensitive) == 0 {
+		log.Warn().Msg("coverage.sensitive selectors are not configured: none of the columns is reported as sensitive")
+	}
+	report, err := buildCoverageReport(c.config, c.context.DataSectionObjects)
+	if err != nil {
+		return nonZeroExitCode, fmt.Errorf("cannot build coverage report: %w", err)
+	}
+	if err = report.Print(os.Stdout, c.config.Coverage.Format); err != nil {
+		return nonZeroExitCode, err
+	}
+	if len(report.GetSensitive()) > 0 {
+		return nonZeroExitCode, nil
+	}
+	return zeroExitCode, nil
+}
+
+// getTableAffectedColumns - returns the names of the columns affected by the table transformers
+func getTableAffectedColumns(t *entries.Table) map[string]struct{} {
+	res := make(map[string]struct{})
+	for _, tc := range t.TransformersContext {
+		for _, name := range tc.Transformer.GetAffectedColumns() {
+			res[name] = struct{}{}
+		}
+	}
+	return res
+}
+
+func compileColumnSelectors(selectors []*domains.ColumnSelector) ([]*transformers.ColumnMatcher, error) {
+	res := make([]*transformers.ColumnMatcher, 0, len(selectors))
+	for idx, s := range selectors {
+		m, err := transformers.NewColumnMatcher(s.Schema, s.Table, s.Column, s.Comment, s.Types)
+		if err != nil {
+			return nil, fmt.Errorf("selector %d: %w", idx, err)
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
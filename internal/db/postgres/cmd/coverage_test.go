@@ -0,0 +1,119 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/entries"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+type affectedColumnsTransformer struct {
+	affectedColumns map[int]string
+}
+
+func (t *affectedColumnsTransformer) Init(ctx context.Context) error {
+	return nil
+}
+
+func (t *affectedColumnsTransformer) Done(ctx context.Context) error {
+	return nil
+}
+
+func (t *affectedColumnsTransformer) Transform(ctx context.Context, r *toolkit.Record) (*toolkit.Record, error) {
+	return r, nil
+}
+
+func (t *affectedColumnsTransformer) GetAffectedColumns() map[int]string {
+	return t.affectedColumns
+}
+
+func Test_buildCoverageReport(t *testing.T) {
+	users := newTableEntry(1, "users")
+	users.RelKind = 'r'
+	users.Columns = []*toolkit.Column{
+		{Name: "id", TypeName: "integer"},
+		{Name: "email", TypeName: "text"},
+		{Name: "phone", TypeName: "text", Comment: "@pii"},
+		{Name: "login", TypeName: "text", Comment: "@pii"},
+		{Name: "note", TypeName: "text"},
+		{Name: "email_domain", TypeName: "text", IsGenerated: true},
+	}
+	users.TransformersContext = []*utils.TransformerContext{
+		{Transformer: &affectedColumnsTransformer{affectedColumns: map[int]string{1: "email"}}},
+	}
+	audit := newTableEntry(2, "audit")
+	audit.Schema = "internal"
+	audit.RelKind = 'r'
+	audit.Columns = []*toolkit.Column{{Name: "ip", TypeName: "inet", Comment: "@pii"}}
+	partitioned := newTableEntry(3, "events")
+	partitioned.RelKind = 'p'
+	partitioned.Columns = []*toolkit.Column{{Name: "payload", TypeName: "text", Comment: "@pii"}}
+
+	cfg := &domains.Config{
+		Dump: domains.Dump{
+			Transformation: []*domains.Table{
+				{Schema: "public", Name: "users", SkipAutoAnonymize: []string{"login"}},
+			},
+		},
+		Coverage: domains.Coverage{
+			Sensitive: []*domains.ColumnSelector{{Comment: "@pii"}, {Column: "^note$"}},
+			Allowed:   []*domains.ColumnSelector{{Schema: "^internal$"}},
+		},
+	}
+
+	report, err := buildCoverageReport(cfg, []entries.Entry{users, audit, partitioned})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Tables)
+	assert.Equal(t, 6, report.Columns)
+	assert.Equal(t, 1, report.CoveredColumns)
+	assert.Equal(t, []*UncoveredColumn{
+		{Schema: "public", Table: "users", Column: "id", Type: "integer", Status: UntransformedColumnStatus},
+		{
+			Schema: "public", Table: "users", Column: "phone", Type: "text",
+			Status: UntransformedColumnStatus, Sensitive: true,
+		},
+		{Schema: "public", Table: "users", Column: "login", Type: "text", Status: SkippedColumnStatus, Sensitive: true},
+		{Schema: "public", Table: "users", Column: "note", Type: "text", Status: UntransformedColumnStatus, Sensitive: true},
+		{Schema: "internal", Table: "audit", Column: "ip", Type: "inet", Status: UntransformedColumnStatus},
+	}, report.UncoveredColumns)
+	assert.Len(t, report.GetSensitive(), 3)
+}
+
+func Test_buildCoverageReport_InvalidSelector(t *testing.T) {
+	cfg := &domains.Config{
+		Coverage: domains.Coverage{Sensitive: []*domains.ColumnSelector{{}}},
+	}
+	_, err := buildCoverageReport(cfg, nil)
+	assert.Error(t, err)
+}
+
+func TestValidateRequireCoverage(t *testing.T) {
+	cfg := &domains.Config{}
+	require.NoError(t, ValidateRequireCoverage(cfg))
+
+	cfg.Dump.RequireCoverage = true
+	require.ErrorIs(t, ValidateRequireCoverage(cfg), ErrNoSensitiveColumnSelectors)
+
+	cfg.Coverage.Sensitive = []*domains.ColumnSelector{{Column: "email"}}
+	require.NoError(t, ValidateRequireCoverage(cfg))
+}
@@ -0,0 +1,121 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers"
+	"github.com/greenmaskio/greenmask/internal/domains"
+)
+
+var ErrFpeTransformerIsNotFound = errors.New("fpe transformer is not found for the column")
+
+// DecryptColumn - reverses the values of the column encrypted by the Fpe transformer. The key, the tweak and the
+// alphabet are taken from the dump transformation config, so the values are decrypted with the same settings they
+// were encrypted with
+type DecryptColumn struct {
+	// ciphers - ciphers of the Fpe transformers applied to the column in the order of the config
+	ciphers []*decryptColumnCipher
+}
+
+type decryptColumnCipher struct {
+	cipher *transformers.FpeCipher
+	// decrypt - the transformer is configured in the decrypt mode, so its reverse operation is the encryption
+	decrypt bool
+}
+
+// NewDecryptColumn - finds the Fpe transformers of the column in the transformation config and initializes their
+// ciphers. If the key is not set in the config it is taken from GREENMASK_FPE_KEY environment variable
+func NewDecryptColumn(cfgs []*domains.Table, schema, table, column string) (*DecryptColumn, error) {
+	cfg := domains.FindTable(cfgs, schema, table)
+	if cfg == nil {
+		return nil, fmt.Errorf("table %s.%s is not found in the transformation config", schema, table)
+	}
+
+	var ciphers []*decryptColumnCipher
+	for _, tc := range cfg.Transformers {
+		if tc.Name != transformers.FpeTransformerName || string(tc.Params["column"]) != column {
+			continue
+		}
+		key := string(tc.Params["key"])
+		if key == "" {
+			key = os.Getenv(transformers.FpeKeyEnvVariable)
+		}
+		tweak := string(tc.Params["tweak"])
+		if tweak == "" {
+			tweak = "00000000000000"
+		}
+		c, err := transformers.NewFpeCipher(key, tweak, string(tc.Params["alphabet"]))
+		if err != nil {
+			return nil, fmt.Errorf("cannot initialize cipher of column %s: %w", column, err)
+		}
+		// The values kept by the transformer due to their length are kept by the decryption as well
+		c.SetKeepInvalidLength(string(tc.Params["invalid_length"]) == "keep")
+		ciphers = append(ciphers, &decryptColumnCipher{
+			cipher:  c,
+			decrypt: string(tc.Params["mode"]) == "decrypt",
+		})
+	}
+	if len(ciphers) == 0 {
+		return nil, fmt.Errorf("%s.%s.%s: %w", schema, table, column, ErrFpeTransformerIsNotFound)
+	}
+	return &DecryptColumn{ciphers: ciphers}, nil
+}
+
+// Decrypt - returns the original value. The transformers are reversed in the backward order
+func (dc *DecryptColumn) Decrypt(v string) (string, error) {
+	res := v
+	var err error
+	for i := len(dc.ciphers) - 1; i >= 0; i-- {
+		c := dc.ciphers[i]
+		if c.decrypt {
+			res, err = c.cipher.Encrypt(res)
+		} else {
+			res, err = c.cipher.Decrypt(res)
+		}
+		if err != nil {
+			return "", fmt.Errorf("cannot decrypt value \"%s\": %w", v, err)
+		}
+	}
+	return res, nil
+}
+
+// Run - decrypts each line of r and writes the results into w line by line
+func (dc *DecryptColumn) Run(ctx context.Context, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	bw := bufio.NewWriter(w)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		res, err := dc.Decrypt(scanner.Text())
+		if err != nil {
+			return err
+		}
+		if _, err = bw.WriteString(res + "\n"); err != nil {
+			return fmt.Errorf("error writing result: %w", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading values: %w", err)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,138 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/utils/fpe"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+const testDecryptColumnKey = "ef4359d8d580aa4f7f036d6f04fc6a94"
+
+func newDecryptColumnTestConfig() []*domains.Table {
+	return []*domains.Table{
+		{
+			Schema: "public",
+			Name:   "accounts",
+			Transformers: []*domains.TransformerConfig{
+				{
+					Name: transformers.FpeTransformerName,
+					Params: toolkit.StaticParameters{
+						"column": toolkit.ParamsValue("card"),
+						"key":    toolkit.ParamsValue(testDecryptColumnKey),
+					},
+				},
+				{
+					Name: transformers.FpeTransformerName,
+					Params: toolkit.StaticParameters{
+						"column":   toolkit.ParamsValue("account"),
+						"alphabet": toolkit.ParamsValue("upper_alphanumeric"),
+						"tweak":    toolkit.ParamsValue("d8e7920afa330a"),
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestDecryptColumn_Decrypt(t *testing.T) {
+	t.Setenv(transformers.FpeKeyEnvVariable, testDecryptColumnKey)
+	cfgs := newDecryptColumnTestConfig()
+
+	tests := []struct {
+		column   string
+		tweak    string
+		alphabet string
+		original string
+	}{
+		{column: "card", tweak: "00000000000000", original: "4111-1111-1111-1111"},
+		{column: "account", tweak: "d8e7920afa330a", alphabet: "upper_alphanumeric", original: "ACC-00A7B19Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.column, func(t *testing.T) {
+			c, err := transformers.NewFpeCipher(testDecryptColumnKey, tt.tweak, tt.alphabet)
+			require.NoError(t, err)
+			encrypted, err := c.Encrypt(tt.original)
+			require.NoError(t, err)
+
+			dc, err := NewDecryptColumn(cfgs, "public", "accounts", tt.column)
+			require.NoError(t, err)
+			res, err := dc.Decrypt(encrypted)
+			require.NoError(t, err)
+			assert.Equal(t, tt.original, res)
+		})
+	}
+}
+
+func TestDecryptColumn_Run(t *testing.T) {
+	cfgs := newDecryptColumnTestConfig()
+	c, err := transformers.NewFpeCipher(testDecryptColumnKey, "00000000000000", "")
+	require.NoError(t, err)
+	values := []string{"1234567890", "000-00-0000"}
+	var input []string
+	for _, v := range values {
+		encrypted, err := c.Encrypt(v)
+		require.NoError(t, err)
+		input = append(input, encrypted)
+	}
+
+	dc, err := NewDecryptColumn(cfgs, "public", "accounts", "card")
+	require.NoError(t, err)
+	buf := new(bytes.Buffer)
+	require.NoError(t, dc.Run(context.Background(), strings.NewReader(strings.Join(input, "\n")), buf))
+	assert.Equal(t, strings.Join(values, "\n")+"\n", buf.String())
+}
+
+func TestDecryptColumn_Decrypt_invalid_length(t *testing.T) {
+	cfgs := newDecryptColumnTestConfig()
+	dc, err := NewDecryptColumn(cfgs, "public", "accounts", "card")
+	require.NoError(t, err)
+	_, err = dc.Decrypt("1234")
+	require.ErrorIs(t, err, fpe.ErrInvalidLength)
+
+	// The short values kept by the transformer are kept by the decryption
+	cfgs[0].Transformers[0].Params["invalid_length"] = toolkit.ParamsValue("keep")
+	dc, err = NewDecryptColumn(cfgs, "public", "accounts", "card")
+	require.NoError(t, err)
+	for _, v := range []string{"", "N/A", "1234"} {
+		res, err := dc.Decrypt(v)
+		require.NoError(t, err)
+		assert.Equal(t, v, res)
+	}
+}
+
+func TestNewDecryptColumn_errors(t *testing.T) {
+	cfgs := newDecryptColumnTestConfig()
+
+	_, err := NewDecryptColumn(cfgs, "public", "users", "card")
+	require.Error(t, err)
+
+	_, err = NewDecryptColumn(cfgs, "public", "accounts", "email")
+	require.ErrorIs(t, err, ErrFpeTransformerIsNotFound)
+
+	t.Setenv(transformers.FpeKeyEnvVariable, "")
+	_, err = NewDecryptColumn(cfgs, "public", "accounts", "account")
+	require.ErrorIs(t, err, transformers.ErrFpeKeyIsNotSet)
+}
@@ -22,6 +22,7 @@ import (
 	"os"
 	"path"
 	"slices"
+	"sync"
 	"time"
 
 	pgxdecimal "github.com/jackc/pgx-shopspring-decimal"
@@ -40,6 +41,7 @@ import (
 	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
 	"github.com/greenmaskio/greenmask/internal/domains"
 	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
 	"github.com/greenmaskio/greenmask/pkg/toolkit"
 )
 
@@ -55,6 +57,7 @@ const (
 const (
 	HeartBeatDoneContent       = "done"
 	HeartBeatInProgressContent = "in-progress"
+	HeartBeatResumedContent    = "resumed"
 )
 
 type Dump struct {
@@ -73,6 +76,7 @@ type Dump struct {
 	dumpedObjectSizes map[int32]storageDto.ObjectSizeStat
 	tableOidToDumpId  map[toolkit.Oid]int32
 	tocFileSize       int64
+	tocChecksum       string
 	version           int
 	blobs             *entries.Blobs
 	// dumpDependenciesGraph - map of table DumpId to its dependencies DumpIds. Stores in meta and uses for restoration
@@ -83,6 +87,45 @@ type Dump struct {
 	// validate shows that dump worker must be in validation mode
 	validate          bool
 	validateRowsLimit uint64
+	// resume - the dump continues the interrupted dump using the progress manifest
+	resume bool
+	// progress - per-table progress manifest. It is nil in validation mode
+	progress   *storageDto.Progress
+	progressMx *sync.Mutex
+	// progressVersion - the version of the progress manifest state. It is incremented on each write request and
+	// guarded by progressMx
+	progressVersion uint64
+	// progressWriteMx - serializes the progress manifest uploads. The manifest is uploaded outside progressMx, so the
+	// workers do not wait for the storage while they read or update the progress
+	progressWriteMx *sync.Mutex
+	// progressWrittenVersion - the version of the latest uploaded progress manifest. It is guarded by progressWriteMx
+	progressWrittenVersion uint64
+	// incrementalFrom - id of the dump that is used as the base for the incremental dump
+	incrementalFrom string
+	// dumpsSt - storage that contains all the dumps. It is used for reading the previous dumps
+	dumpsSt storages.Storager
+	// refStorages - the cached storages of the dumps that store the referenced objects
+	refStorages      *referencedDumpStorages
+	previousMetadata *storageDto.Metadata
+	// tablesState - the table change indicators of the current dump
+	tablesState            map[toolkit.Oid]*storageDto.TableState
+	transformationChecksum string
+	// references - map of the table DumpId to the object of the previous dump
+	references   map[int32]*storageDto.ObjectReference
+	referencesMx *sync.Mutex
+	// driftAnonymizedColumns - the new columns that are transformed by the default transformers according to the
+	// schema drift policy
+	driftAnonymizedColumns []*storageDto.ColumnReference
+	// compression - the compression settings of the table data and large objects files
+	compression *ioutils.CompressionSettings
+	// beforeDataDump - optional function that is called when the schema is dumped and the data dump is planned. The
+	// sync command uses it for restoring the schema into the target database before the data streaming
+	beforeDataDump func(ctx context.Context) error
+	// dumpIdsAssigned - the dump ids of the data section objects are assigned before the data dump
+	dumpIdsAssigned bool
+	// chunksLeft - map of the chunked table DumpId to the count of its chunks that are not dumped yet. It is guarded
+	// by progressMx
+	chunksLeft map[int32]int
 }
 
 func NewDump(cfg *domains.Config, st storages.Storager, registry *utils.TransformerRegistry) *Dump {
@@ -96,9 +139,27 @@ func NewDump(cfg *domains.Config, st storages.Storager, registry *utils.Transfor
 		dumpedObjectSizes: map[int32]storageDto.ObjectSizeStat{},
 		registry:          registry,
 		tableOidToDumpId:  make(map[toolkit.Oid]int32),
+		progressMx:        &sync.Mutex{},
+		progressWriteMx:   &sync.Mutex{},
+		tablesState:       make(map[toolkit.Oid]*storageDto.TableState),
+		references:        make(map[int32]*storageDto.ObjectReference),
+		referencesMx:      &sync.Mutex{},
+		chunksLeft:        make(map[int32]int),
+		compression: &ioutils.CompressionSettings{
+			Codec:    ioutils.GetCodec(cfg.Dump.PgDumpOptions.CompressionCodec),
+			Level:    cfg.Dump.PgDumpOptions.CompressionLevel,
+			UsePgzip: cfg.Dump.PgDumpOptions.Pgzip,
+		},
 	}
 }
 
+// SetDumpsStorage - sets the storage that contains all the dumps. It is required for the incremental mode and the
+// schema drift policy
+func (d *Dump) SetDumpsStorage(st storages.Storager) {
+	d.dumpsSt = st
+	d.refStorages = newReferencedDumpStorages(st)
+}
+
 func (d *Dump) prune() {
 	d.schemaToc = nil
 	d.context = nil
@@ -291,14 +352,48 @@ func (d *Dump) taskProducer(ctx context.Context, tasks chan<- dumpers.DumpTask)
 		}
 
 		for _, dumpObj := range dataObjects {
-			dumpObj.SetDumpId(d.dumpIdSequence)
+			if !d.dumpIdsAssigned {
+				d.setDumpId(dumpObj)
+			}
 			var task dumpers.DumpTask
 			switch v := dumpObj.(type) {
 			case *entries.Table:
 				if v.RelKind == 'p' {
 					continue
 				}
-				task = dumpers.NewTableDumper(v, d.validate, d.validateRowsLimit, d.pgDumpOptions.Pgzip)
+				v.Codec = d.compression.Codec
+				dumped, err := d.isTableDumped(ctx, v)
+				if err != nil {
+					return fmt.Errorf("cannot check table %s.%s progress: %w", v.Schema, v.Name, err)
+				}
+				if dumped {
+					log.Info().
+						Str("SchemaName", v.Schema).
+						Str("TableName", v.Name).
+						Int32("DumpId", v.DumpId).
+						Msg("table has been dumped in the interrupted run: skipping")
+					continue
+				}
+				ref, err := d.findTableReference(ctx, v)
+				if err != nil {
+					return fmt.Errorf("cannot check table %s.%s changes: %w", v.Schema, v.Name, err)
+				}
+				if ref != nil {
+					log.Info().
+						Str("SchemaName", v.Schema).
+						Str("TableName", v.Name).
+						Str("ReferencedDumpId", ref.DumpId).
+						Msg("table has not been changed since the previous dump: skipping")
+					d.setReference(v, ref)
+					continue
+				}
+				if v.IsChunked() {
+					if err = d.pushTableChunks(ctx, tasks, v); err != nil {
+						return err
+					}
+					continue
+				}
+				task = dumpers.NewTableDumper(v, d.validate, d.validateRowsLimit, d.compression)
 			case *entries.Sequence:
 				task = dumpers.NewSequenceDumper(v)
 			case *entries.Blobs:
@@ -313,20 +408,53 @@ func (d *Dump) taskProducer(ctx context.Context, tasks chan<- dumpers.DumpTask)
 					log.Debug().Msg("skipping blobs")
 					continue
 				}
-				task = dumpers.NewLargeObjectDumper(v, d.pgDumpOptions.Pgzip)
+				task = dumpers.NewLargeObjectDumper(v, d.compression)
 			default:
 				return fmt.Errorf("unknow dumper type")
 			}
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case tasks <- task:
+			if err := pushDumpTask(ctx, tasks, task); err != nil {
+				return err
 			}
 		}
 		return nil
 	}
 }
 
+// pushTableChunks - produces the task for each chunk of the table. The chunks are dumped by the different workers
+// under the same snapshot
+func (d *Dump) pushTableChunks(ctx context.Context, tasks chan<- dumpers.DumpTask, t *entries.Table) error {
+	for _, c := range t.Chunks {
+		c.FileName = t.ChunkFileName(c.Part)
+	}
+	d.progressMx.Lock()
+	d.chunksLeft[t.DumpId] = len(t.Chunks)
+	d.progressMx.Unlock()
+	for _, c := range t.Chunks {
+		if err := pushDumpTask(ctx, tasks, dumpers.NewTableChunkDumper(t, c, d.compression)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// completeTableChunk - decrements the count of the table chunks that are not dumped yet. It returns true if the last
+// chunk of the table is dumped
+func (d *Dump) completeTableChunk(t *entries.Table) bool {
+	d.progressMx.Lock()
+	defer d.progressMx.Unlock()
+	d.chunksLeft[t.DumpId]--
+	return d.chunksLeft[t.DumpId] == 0
+}
+
+func pushDumpTask(ctx context.Context, tasks chan<- dumpers.DumpTask, task dumpers.DumpTask) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case tasks <- task:
+	}
+	return nil
+}
+
 // createTocEntries - creates TOC entries based on d.context.DataSectionObjects
 // they will be stored in tod.dat file
 func (d *Dump) createTocEntries() error {
@@ -344,6 +472,8 @@ func (d *Dump) createTocEntries() error {
 			d.dumpedObjectSizes[entry.DumpId] = storageDto.ObjectSizeStat{
 				Original:   v.OriginalSize,
 				Compressed: v.CompressedSize,
+				Checksums:  d.getTableChecksums(v, entry),
+				Chunks:     v.ChunkFileNames(),
 			}
 			if v.RelKind != 'p' {
 				// Do not create TOC entry for partitioned tables because they are not dumped. Only their partitions are
@@ -363,6 +493,7 @@ func (d *Dump) createTocEntries() error {
 			d.dumpedObjectSizes[entry.DumpId] = storageDto.ObjectSizeStat{
 				Original:   v.OriginalSize,
 				Compressed: v.CompressedSize,
+				Checksums:  v.Checksums,
 			}
 			largeObjects = append(largeObjects, entry)
 		default:
@@ -378,6 +509,31 @@ func (d *Dump) createTocEntries() error {
 	return nil
 }
 
+// getTableChecksums - returns the checksum of the table data file. The referenced object file name is used if the
+// table data is stored in the previous dump
+func (d *Dump) getTableChecksums(t *entries.Table, entry *toc.Entry) map[string]string {
+	if t.IsChunked() {
+		ref, ok := d.references[entry.DumpId]
+		if !ok {
+			return t.DataChecksums()
+		}
+		// The checksums of the referenced chunks are stored by their names in the referenced dump
+		res := make(map[string]string, len(t.Chunks))
+		for idx, c := range t.Chunks {
+			res[ref.Chunks[idx]] = c.Checksum
+		}
+		return res
+	}
+	if t.Checksum == "" || entry.FileName == nil {
+		return nil
+	}
+	fileName := *entry.FileName
+	if ref, ok := d.references[entry.DumpId]; ok {
+		fileName = ref.FileName
+	}
+	return map[string]string{fileName: t.Checksum}
+}
+
 // setDumpDependenciesGraph - sets dumpDependenciesGraph of entries using their dumpId and build topological order of
 // dump ids
 func (d *Dump) setDumpDependenciesGraph(tables []*entries.Table) {
@@ -432,6 +588,11 @@ func (d *Dump) dataDump(ctx context.Context) error {
 	if err := d.createTocEntries(); err != nil {
 		return fmt.Errorf("error creating toc entries: %w", err)
 	}
+	if !d.validate {
+		if err := d.writeChunksPlaceholders(ctx); err != nil {
+			return err
+		}
+	}
 	log.Debug().Msg("all the data have been dumped")
 	return nil
 }
@@ -459,6 +620,7 @@ func (d *Dump) mergeAndWriteToc(ctx context.Context) error {
 		return fmt.Errorf("error writing built toc file to the storage: %w", err)
 	}
 	d.tocFileSize = int64(buf.Len())
+	d.tocChecksum = ioutils.ChecksumBytes(buf.Bytes())
 	// Writing dumped TOC into buffer to the storage
 	if err = d.st.PutObject(ctx, "toc.dat", buf); err != nil {
 		return err
@@ -480,6 +642,16 @@ func (d *Dump) writeMetaData(ctx context.Context, startedAt, completedAt time.Ti
 	if err != nil {
 		return fmt.Errorf("unable build metadata: %w", err)
 	}
+	metadata.TransformationChecksum = d.transformationChecksum
+	metadata.Header.TocChecksum = d.tocChecksum
+	metadata.Header.Codec = d.compression.Codec
+	metadata.Header.CompressionLevel = d.compression.Level
+	metadata.TablesState = d.getTablesState()
+	if len(d.references) > 0 {
+		metadata.IncrementalFrom = d.incrementalFrom
+		metadata.References = d.references
+	}
+	metadata.DriftAnonymizedColumns = d.driftAnonymizedColumns
 
 	buf := bytes.NewBuffer(make([]byte, 0, 1024))
 	if err = json.NewEncoder(buf).Encode(metadata); err != nil {
@@ -496,10 +668,45 @@ func (d *Dump) Run(ctx context.Context) (err error) {
 	defer d.prune()
 	startedAt := time.Now()
 
+	if err = ValidateSchemaDriftPolicy(d.config.Dump.SchemaDriftPolicy); err != nil {
+		return err
+	}
+
+	if err = ValidateRequireCoverage(d.config); err != nil {
+		return err
+	}
+
+	if err = ioutils.ValidateCompression(d.compression.Codec, d.compression.Level); err != nil {
+		return err
+	}
+
+	if d.resume {
+		if err = d.readProgress(ctx); err != nil {
+			return fmt.Errorf("cannot resume dump: %w", err)
+		}
+		// Keep the start time of the interrupted run
+		startedAt = d.progress.StartedAt
+		log.Info().
+			Int("DumpedTablesCount", len(d.progress.Tables)).
+			Msg("resuming interrupted dump")
+	} else {
+		d.progress = storageDto.NewProgress(startedAt)
+	}
+
 	if err := custom.BootstrapCustomTransformers(ctx, d.registry, d.config.CustomTransformers); err != nil {
 		return fmt.Errorf("error bootstraping custom transformers: %w", err)
 	}
 
+	if err = d.setTransformationChecksum(); err != nil {
+		return err
+	}
+
+	if d.incrementalFrom != "" {
+		if err = d.readPreviousMetadata(ctx); err != nil {
+			return fmt.Errorf("cannot read previous dump: %w", err)
+		}
+	}
+
 	dsn, err := d.pgDumpOptions.GetPgDSN()
 	if err != nil {
 		return fmt.Errorf("cannot build connection string: %w", err)
@@ -515,6 +722,16 @@ func (d *Dump) Run(ctx context.Context) (err error) {
 		}
 	}()
 
+	if d.pgDumpOptions.Snapshot == "" {
+		if err = d.gatherTablesStats(ctx, conn); err != nil {
+			return fmt.Errorf("error gathering tables statistics: %w", err)
+		}
+	} else if d.previousMetadata != nil {
+		// The statistics must be read before the snapshot is taken. It cannot be guaranteed for the provided snapshot
+		log.Warn().Msg("incremental dump cannot be used with the provided snapshot: all the tables will be dumped")
+		d.previousMetadata = nil
+	}
+
 	tx, err := d.startMainTx(ctx, conn)
 	if err != nil {
 		return fmt.Errorf("cannot prepare backup transaction: %w", err)
@@ -533,10 +750,38 @@ func (d *Dump) Run(ctx context.Context) (err error) {
 		return fmt.Errorf("context error: %w", err)
 	}
 
+	if err = d.checkSchemaDrift(ctx, tx); err != nil {
+		return fmt.Errorf("schema drift check error: %w", err)
+	}
+
+	if d.config.Dump.RequireCoverage {
+		if err = d.checkCoverage(); err != nil {
+			return fmt.Errorf("coverage check error: %w", err)
+		}
+	}
+
+	if err = d.gatherWatermarks(ctx, tx); err != nil {
+		return fmt.Errorf("error gathering watermarks: %w", err)
+	}
+
 	if err = d.schemaOnlyDump(ctx, tx); err != nil {
 		return fmt.Errorf("schema only stage dumping error: %w", err)
 	}
 
+	if err = d.setupProgress(ctx); err != nil {
+		return fmt.Errorf("progress manifest stage dumping error: %w", err)
+	}
+
+	if err = d.planTablesChunks(ctx, tx); err != nil {
+		return fmt.Errorf("tables chunks planning error: %w", err)
+	}
+
+	if d.beforeDataDump != nil {
+		if err = d.beforeDataDump(ctx); err != nil {
+			return fmt.Errorf("before data stage error: %w", err)
+		}
+	}
+
 	if err = d.dataDump(ctx); err != nil {
 		return fmt.Errorf("data stage dumping error: %w", err)
 	}
@@ -549,6 +794,8 @@ func (d *Dump) Run(ctx context.Context) (err error) {
 		return fmt.Errorf("writeMetaData stage dumping error: %w", err)
 	}
 
+	d.removeProgress(ctx)
+
 	return nil
 }
 
@@ -678,10 +925,31 @@ func (d *Dump) dumpWorker(
 			Str("ObjectName", task.DebugInfo()).
 			Msgf("dumping started")
 
+		td, isTable := task.(*dumpers.TableDumper)
+		if isTable {
+			if err = d.markTableProgress(ctx, td.Table(), storageDto.TableProgressInProgress); err != nil {
+				return err
+			}
+		}
+
 		if err = task.Execute(ctx, tx, d.st); err != nil {
 			return err
 		}
 
+		if isTable && td.Table().IsChunked() {
+			// The chunked table is done when all its chunks are dumped
+			isTable = d.completeTableChunk(td.Table())
+			if isTable {
+				td.Table().SumChunksSizes()
+			}
+		}
+
+		if isTable {
+			if err = d.markTableProgress(ctx, td.Table(), storageDto.TableProgressDone); err != nil {
+				return err
+			}
+		}
+
 		log.Debug().
 			Int("WorkerId", id).
 			Str("ObjectName", task.DebugInfo()).
@@ -754,7 +1022,7 @@ func (d *Dump) validateDumpExecuteTask(ctx context.Context, id int, task dumpers
 func (d *Dump) writeHeartBeatWorker(ctx context.Context, done chan struct{}) func() error {
 	return func() error {
 		// Initial write
-		if err := d.writeHeartBeat(ctx, HeartBeatInProgressContent); err != nil {
+		if err := d.writeHeartBeat(ctx, d.heartBeatInProgressContent()); err != nil {
 			return fmt.Errorf("error writing heartbeat: %w", err)
 		}
 		t := time.NewTicker(HeartBeatWriteInterval)
@@ -768,7 +1036,7 @@ func (d *Dump) writeHeartBeatWorker(ctx context.Context, done chan struct{}) fun
 				}
 				return nil
 			case <-t.C:
-				if err := d.writeHeartBeat(ctx, HeartBeatInProgressContent); err != nil {
+				if err := d.writeHeartBeat(ctx, d.heartBeatInProgressContent()); err != nil {
 					return fmt.Errorf("error writing heartbeat: %w", err)
 				}
 			}
@@ -784,3 +1052,25 @@ func (d *Dump) writeHeartBeat(ctx context.Context, data string) error {
 	}
 	return nil
 }
+
+// findTableByFileName - finds the table by the name of its data or chunk file
+func (d *Dump) findTableByFileName(fileName string) (*entries.Table, bool) {
+	for _, obj := range d.context.DataSectionObjects {
+		t, ok := obj.(*entries.Table)
+		if !ok || t.RelKind == 'p' {
+			continue
+		}
+		if t.IsChunked() {
+			if slices.ContainsFunc(t.Chunks, func(c *entries.TableChunk) bool {
+				return c.FileName == fileName
+			}) {
+				return t, true
+			}
+			continue
+		}
+		if t.DataFileName() == fileName {
+			return t, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,278 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/entries"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+const (
+	ChunkByPrimaryKey = "pk"
+	ChunkByCtid       = "ctid"
+)
+
+const chunkPrimaryKeyQuery = `
+SELECT a.attname, a.atttypid
+FROM pg_catalog.pg_index i
+         JOIN pg_catalog.pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY (i.indkey)
+WHERE i.indrelid = $1
+  AND i.indisprimary
+`
+
+const relationPagesQuery = `
+SELECT pg_catalog.pg_relation_size($1::OID::REGCLASS) / current_setting('block_size')::INT8
+`
+
+// integerTypeOids - the types of the primary key column that can be used for the chunking
+var integerTypeOids = map[uint32]struct{}{
+	pgtype.Int2OID: {},
+	pgtype.Int4OID: {},
+	pgtype.Int8OID: {},
+}
+
+// ValidateChunkBy - checks the chunk_by table option value
+func ValidateChunkBy(chunkBy string) error {
+	switch chunkBy {
+	case "", ChunkByPrimaryKey, ChunkByCtid:
+		return nil
+	}
+	return fmt.Errorf(
+		"unknown chunk_by value \"%s\": expected one of %s, %s", chunkBy, ChunkByPrimaryKey, ChunkByCtid,
+	)
+}
+
+// planTablesChunks - splits the tables data into chunks according to the chunks option of the table config. The
+// first and the last chunks are not bounded, so the rows that are out of the calculated bounds are not lost
+func (d *Dump) planTablesChunks(ctx context.Context, tx pgx.Tx) error {
+	if d.validate {
+		return nil
+	}
+	for _, obj := range d.context.DataSectionObjects {
+		t, ok := obj.(*entries.Table)
+		if !ok || t.RelKind == 'p' {
+			continue
+		}
+		cfg := domains.FindTable(d.config.Dump.Transformation, t.Schema, t.Name)
+		if cfg == nil || cfg.Chunks < 2 {
+			continue
+		}
+		if err := ValidateChunkBy(cfg.ChunkBy); err != nil {
+			return fmt.Errorf("table %s.%s: %w", t.Schema, t.Name, err)
+		}
+		chunks, err := planTableChunks(ctx, tx, t, cfg.Chunks, cfg.ChunkBy)
+		if err != nil {
+			return fmt.Errorf("cannot split table %s.%s into chunks: %w", t.Schema, t.Name, err)
+		}
+		t.Chunks = chunks
+		log.Debug().
+			Str("SchemaName", t.Schema).
+			Str("TableName", t.Name).
+			Int("ChunksCount", len(chunks)).
+			Msg("table is split into chunks")
+	}
+	return nil
+}
+
+func planTableChunks(
+	ctx context.Context, tx pgx.Tx, t *entries.Table, count int, chunkBy string,
+) ([]*entries.TableChunk, error) {
+	pkColumn, err := getChunkPrimaryKeyColumn(ctx, tx, t.Oid)
+	if err != nil {
+		return nil, err
+	}
+	switch chunkBy {
+	case "":
+		chunkBy = ChunkByCtid
+		if pkColumn != "" {
+			chunkBy = ChunkByPrimaryKey
+		}
+	case ChunkByPrimaryKey:
+		if pkColumn == "" {
+			return nil, fmt.Errorf("table does not have single-column integer primary key")
+		}
+	}
+
+	var column string
+	var bounds []string
+	switch chunkBy {
+	case ChunkByPrimaryKey:
+		column = pgx.Identifier{pkColumn}.Sanitize()
+		var minValue, maxValue *int64
+		query := fmt.Sprintf(
+			"SELECT min(%s)::INT8, max(%s)::INT8 FROM %s",
+			column, column, pgx.Identifier{t.Schema, t.Name}.Sanitize(),
+		)
+		if err = tx.QueryRow(ctx, query).Scan(&minValue, &maxValue); err != nil {
+			return nil, fmt.Errorf("cannot get primary key range: %w", err)
+		}
+		if minValue == nil || maxValue == nil {
+			// The table is empty
+			return nil, nil
+		}
+		for _, b := range splitRange(*minValue, *maxValue, count) {
+			bounds = append(bounds, fmt.Sprintf("%d", b))
+		}
+	case ChunkByCtid:
+		if t.Query != "" {
+			log.Warn().
+				Str("SchemaName", t.Schema).
+				Str("TableName", t.Name).
+				Msg("ctid chunking cannot be used for the table with query or subset conditions: table is dumped in one piece")
+			return nil, nil
+		}
+		column = "ctid"
+		var pages int64
+		if err = tx.QueryRow(ctx, relationPagesQuery, t.Oid).Scan(&pages); err != nil {
+			return nil, fmt.Errorf("cannot get relation size: %w", err)
+		}
+		if pages == 0 {
+			return nil, nil
+		}
+		for _, b := range splitRange(0, pages-1, count) {
+			bounds = append(bounds, fmt.Sprintf("'(%d,0)'::TID", b))
+		}
+	}
+	return buildTableChunks(column, bounds), nil
+}
+
+// getChunkPrimaryKeyColumn - returns the primary key column name if the primary key is a single integer column
+func getChunkPrimaryKeyColumn(ctx context.Context, tx pgx.Tx, tableOid toolkit.Oid) (string, error) {
+	rows, err := tx.Query(ctx, chunkPrimaryKeyQuery, tableOid)
+	if err != nil {
+		return "", fmt.Errorf("cannot get primary key columns: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	var typeOids []uint32
+	for rows.Next() {
+		var name string
+		var typeOid uint32
+		if err = rows.Scan(&name, &typeOid); err != nil {
+			return "", fmt.Errorf("cannot scan primary key column: %w", err)
+		}
+		names = append(names, name)
+		typeOids = append(typeOids, typeOid)
+	}
+	if err = rows.Err(); err != nil {
+		return "", fmt.Errorf("cannot get primary key columns: %w", err)
+	}
+	if len(names) != 1 {
+		return "", nil
+	}
+	if _, ok := integerTypeOids[typeOids[0]]; !ok {
+		return "", nil
+	}
+	return names[0], nil
+}
+
+// splitRange - returns the inner bounds that split the [minValue, maxValue] range into count nearly equal parts. It
+// returns less bounds if the range is too small
+func splitRange(minValue, maxValue int64, count int) []int64 {
+	if count < 2 || maxValue <= minValue {
+		return nil
+	}
+	span := uint64(maxValue-minValue) + 1
+	step := span / uint64(count)
+	if span%uint64(count) != 0 {
+		step++
+	}
+	var res []int64
+	for i := 1; i < count; i++ {
+		offset := uint64(i) * step
+		if offset >= span {
+			break
+		}
+		res = append(res, minValue+int64(offset))
+	}
+	return res
+}
+
+// buildTableChunks - builds the chunks conditions using the inner bounds. The first chunk is not bounded from below
+// and the last chunk is not bounded from above
+func buildTableChunks(column string, bounds []string) []*entries.TableChunk {
+	if len(bounds) == 0 {
+		return nil
+	}
+	res := make([]*entries.TableChunk, 0, len(bounds)+1)
+	res = append(res, &entries.TableChunk{
+		Part: 0,
+		Cond: fmt.Sprintf("%s < %s", column, bounds[0]),
+	})
+	for i := 1; i < len(bounds); i++ {
+		res = append(res, &entries.TableChunk{
+			Part: i,
+			Cond: fmt.Sprintf("%s >= %s AND %s < %s", column, bounds[i-1], column, bounds[i]),
+		})
+	}
+	res = append(res, &entries.TableChunk{
+		Part: len(bounds),
+		Cond: fmt.Sprintf("%s >= %s", column, bounds[len(bounds)-1]),
+	})
+	return res
+}
+
+// chunkPlaceholderData - the COPY data without rows. It is written into the data file of the chunked table TOC entry,
+// so the dump stays readable by pg_restore. pg_restore restores the chunked table empty because the rows are stored
+// in the chunk files
+var chunkPlaceholderData = []byte("\\.\n\n")
+
+// writeChunkPlaceholder - writes the placeholder into the data file of the chunked table. The codec is taken from the
+// file name extension
+func writeChunkPlaceholder(ctx context.Context, st storages.Storager, fileName string, level int) error {
+	buf := new(bytes.Buffer)
+	w, err := ioutils.NewCompressionWriter(
+		ioutils.NopWriteCloser(buf), ioutils.GetCodecByFileName(fileName), level, false,
+	)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(chunkPlaceholderData); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	if err = st.PutObject(ctx, fileName, buf); err != nil {
+		return fmt.Errorf("cannot write chunked table placeholder %s: %w", fileName, err)
+	}
+	return nil
+}
+
+// writeChunksPlaceholders - writes the placeholders of the chunked tables that are dumped or referenced by the dump
+func (d *Dump) writeChunksPlaceholders(ctx context.Context) error {
+	for _, obj := range d.context.DataSectionObjects {
+		t, ok := obj.(*entries.Table)
+		if !ok || t.RelKind == 'p' || !t.IsChunked() {
+			continue
+		}
+		if err := writeChunkPlaceholder(ctx, d.st, t.DataFileName(), d.compression.Level); err != nil {
+			return err
+		}
+	}
+	return nil
+}
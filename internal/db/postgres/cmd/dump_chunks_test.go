@@ -0,0 +1,89 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/utils/testutils"
+)
+
+func TestSplitRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		min      int64
+		max      int64
+		count    int
+		expected []int64
+	}{
+		{name: "even", min: 1, max: 100, count: 4, expected: []int64{26, 51, 76}},
+		{name: "uneven", min: 0, max: 9, count: 3, expected: []int64{4, 8}},
+		{name: "negative", min: -10, max: 9, count: 2, expected: []int64{0}},
+		{name: "range smaller than count", min: 1, max: 2, count: 4, expected: []int64{2}},
+		{name: "single value", min: 5, max: 5, count: 4, expected: nil},
+		{name: "one chunk", min: 1, max: 100, count: 1, expected: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, splitRange(tt.min, tt.max, tt.count))
+		})
+	}
+}
+
+func TestBuildTableChunks(t *testing.T) {
+	assert.Nil(t, buildTableChunks("\"id\"", nil))
+
+	chunks := buildTableChunks("\"id\"", []string{"26", "51"})
+	require.Len(t, chunks, 3)
+	assert.Equal(t, 0, chunks[0].Part)
+	assert.Equal(t, "\"id\" < 26", chunks[0].Cond)
+	assert.Equal(t, 1, chunks[1].Part)
+	assert.Equal(t, "\"id\" >= 26 AND \"id\" < 51", chunks[1].Cond)
+	assert.Equal(t, 2, chunks[2].Part)
+	assert.Equal(t, "\"id\" >= 51", chunks[2].Cond)
+}
+
+func TestValidateChunkBy(t *testing.T) {
+	require.NoError(t, ValidateChunkBy(""))
+	require.NoError(t, ValidateChunkBy(ChunkByPrimaryKey))
+	require.NoError(t, ValidateChunkBy(ChunkByCtid))
+	require.ErrorContains(t, ValidateChunkBy("hash"), "unknown chunk_by value")
+}
+
+func TestDump_isTableDumped_Chunks(t *testing.T) {
+	ctx := context.Background()
+	st := &testutils.StorageMock{}
+	st.On("Exists", ctx, "105.0.dat.gz").Return(true, nil)
+	st.On("Exists", ctx, "105.1.dat.gz").Return(true, nil)
+	d := newResumedDump(st, 100)
+	d.progress.Tables[1].Chunks = map[string]string{"105.1.dat.gz": "c1", "105.0.dat.gz": "c0"}
+	require.NoError(t, d.setupProgress(ctx))
+
+	done := newTableEntry(1, "done")
+	d.setDumpId(done)
+	dumped, err := d.isTableDumped(ctx, done)
+	require.NoError(t, err)
+	require.True(t, dumped)
+	require.Len(t, done.Chunks, 2)
+	assert.Equal(t, "105.0.dat.gz", done.Chunks[0].FileName)
+	assert.Equal(t, "c0", done.Chunks[0].Checksum)
+	assert.Equal(t, "105.1.dat.gz", done.Chunks[1].FileName)
+	assert.Equal(t, int64(100), done.OriginalSize)
+	st.AssertExpectations(t)
+}
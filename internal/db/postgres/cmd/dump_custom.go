@@ -0,0 +1,355 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/entries"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/toc"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/storages/directory"
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
+)
+
+// CustomDumpExtension - the extension of the custom format archive object
+const CustomDumpExtension = ".dump"
+
+// CustomDump - dumps the database into the pg_dump custom format (-Fc) archive <dumpId>.dump that is streamed into
+// the dumps storage while the dump runs. The header and the TOC are written when the data dump is planned and the
+// data block of each table is appended once the table is dumped. The archive is not seekable, so the data offsets are
+// not set in the same way as pg_dump does when it writes into the pipe. The other dump objects are written into the
+// temporary directory that is removed after the dump
+type CustomDump struct {
+	*Dump
+	fileName string
+	// spoolDir - the directory of the data blocks and the table chunks that are prepared before they are appended
+	spoolDir string
+	// tmpSt - the storage of the dump objects except the table data
+	tmpSt storages.Storager
+	// level - zlib compression level of the archive data
+	level int
+	// blobsEntry - the large objects entry of the archive. It is nil if the large objects are not dumped
+	blobsEntry *toc.Entry
+
+	// mx - guards the archive writes and tableChunksLeft
+	mx *sync.Mutex
+	cw *toc.CustomWriter
+	bw *bufio.Writer
+	pw *io.PipeWriter
+	// tableChunksLeft - map of the chunked table DumpId to the count of its chunks that are not spooled yet
+	tableChunksLeft map[int32]int
+	uploadDone      chan struct{}
+	uploadErr       error
+}
+
+// NewCustomDump - creates the dump that writes the custom format archive <dumpId>.dump into the dumps storage
+func NewCustomDump(
+	cfg *domains.Config, dumpsSt storages.Storager, dumpId string, registry *utils.TransformerRegistry,
+) *CustomDump {
+	d := NewDump(cfg, nil, registry)
+	d.SetDumpsStorage(dumpsSt)
+	return &CustomDump{
+		Dump:            d,
+		fileName:        dumpId + CustomDumpExtension,
+		level:           getCustomArchiveLevel(d.compression),
+		mx:              &sync.Mutex{},
+		tableChunksLeft: make(map[int32]int),
+	}
+}
+
+// getCustomArchiveLevel - returns the zlib level of the archive data. The data is not compressed if the codec is none.
+// The gzip level is used as is and the default zlib level is used for the other codecs
+func getCustomArchiveLevel(cs *ioutils.CompressionSettings) int {
+	switch cs.Codec {
+	case ioutils.CodecNone:
+		return 0
+	case ioutils.CodecGzip:
+		if cs.Level != ioutils.DefaultCompressionLevel {
+			return cs.Level
+		}
+	}
+	return -1
+}
+
+func (c *CustomDump) Run(ctx context.Context) (err error) {
+	if c.resume {
+		return errors.New("resume is not supported by the custom output format")
+	}
+	if c.incrementalFrom != "" {
+		return errors.New("incremental dump is not supported by the custom output format")
+	}
+	if err = ioutils.ValidateCompression(c.compression.Codec, c.compression.Level); err != nil {
+		return err
+	}
+
+	tmpDir := path.Join(c.config.Common.TempDirectory, fmt.Sprintf("custom_%d", time.Now().UnixNano()))
+	c.spoolDir = path.Join(tmpDir, "spool")
+	if err = os.MkdirAll(c.spoolDir, 0700); err != nil {
+		return fmt.Errorf("error creating temp dir: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			log.Warn().Err(err).Msg("error deleting temp dir")
+		}
+	}()
+	st, err := directory.NewStorage(&directory.Config{Path: path.Join(tmpDir, "dump")})
+	if err != nil {
+		return fmt.Errorf("error creating temp storage: %w", err)
+	}
+	c.tmpSt = st
+	// The table data is compressed into the archive, so the data files are not compressed
+	c.compression.Codec = ioutils.CodecNone
+	c.compression.Level = ioutils.DefaultCompressionLevel
+	c.st = &customDumpStorage{Storager: st, dump: c}
+
+	defer func() {
+		// Stop the upload, so the incomplete archive is not committed by the storages that upload the objects
+		// atomically
+		if err != nil && c.pw != nil {
+			_ = c.pw.CloseWithError(err)
+			<-c.uploadDone
+		}
+	}()
+	c.beforeDataDump = c.startArchive
+	if err = c.Dump.Run(ctx); err != nil {
+		return fmt.Errorf("dump error: %w", err)
+	}
+	if err = c.completeArchive(ctx); err != nil {
+		return fmt.Errorf("cannot complete custom archive: %w", err)
+	}
+	return nil
+}
+
+// startArchive - starts the upload of the archive and writes the header and the TOC. The dump ids of the data
+// section objects are assigned in advance, so the TOC contains the entries of the data that is not dumped yet
+func (c *CustomDump) startArchive(ctx context.Context) error {
+	c.assignDumpIds()
+	dataEntries, err := c.getArchiveDataEntries()
+	if err != nil {
+		return err
+	}
+	mergedEntries, err := c.MergeTocEntries(c.schemaToc.Entries, dataEntries)
+	if err != nil {
+		return fmt.Errorf("unable to merge TOC entries: %w", err)
+	}
+	header := *c.schemaToc.Header
+	header.TocCount = int32(len(mergedEntries))
+
+	pr, pw := io.Pipe()
+	c.pw = pw
+	c.uploadDone = make(chan struct{})
+	go func() {
+		defer close(c.uploadDone)
+		c.uploadErr = c.dumpsSt.PutObject(ctx, c.fileName, pr)
+		// Unblock the writer if the upload is stopped before the archive is read to the end
+		if c.uploadErr != nil {
+			_ = pr.CloseWithError(fmt.Errorf("upload is stopped: %w", c.uploadErr))
+		} else {
+			_ = pr.Close()
+		}
+	}()
+
+	c.bw = bufio.NewWriter(pw)
+	c.cw = toc.NewCustomWriter(c.bw, &toc.Toc{Header: &header, Entries: mergedEntries}, c.level)
+	if err = c.cw.WriteToc(); err != nil {
+		return fmt.Errorf("cannot write custom archive toc: %w", err)
+	}
+	return nil
+}
+
+// getArchiveDataEntries - returns the data section entries in the same order as createTocEntries does
+func (c *CustomDump) getArchiveDataEntries() ([]*toc.Entry, error) {
+	var tables, sequences, largeObjects []*toc.Entry
+	for _, obj := range c.context.DataSectionObjects {
+		entry, err := obj.Entry()
+		if err != nil {
+			return nil, fmt.Errorf("error producing toc entry: %w", err)
+		}
+		switch v := obj.(type) {
+		case *entries.Table:
+			if v.RelKind == 'p' {
+				continue
+			}
+			if v.IsChunked() {
+				c.tableChunksLeft[v.DumpId] = len(v.Chunks)
+			}
+			tables = append(tables, entry)
+		case *entries.Sequence:
+			sequences = append(sequences, entry)
+		case *entries.Blobs:
+			c.blobs = v
+			if c.pgDumpOptions.NoBlobs {
+				continue
+			}
+			c.blobsEntry = entry
+			largeObjects = append(largeObjects, entry)
+		default:
+			return nil, fmt.Errorf("unexpected entry type %T", obj)
+		}
+	}
+	res := append(tables, sequences...)
+	return append(res, largeObjects...), nil
+}
+
+// writeTableData - appends the table data file to the archive. The data block is prepared in the spool directory, so
+// the workers compress the data in parallel. The chunks of the table are spooled until the last one is written,
+// then they are concatenated into the single data block
+func (c *CustomDump) writeTableData(t *entries.Table, fileName string, body io.Reader) error {
+	if !t.IsChunked() {
+		return c.appendDataBlock(t.DumpId, func(w io.Writer) error {
+			return writeCustomCopyData(w, body)
+		})
+	}
+
+	if err := c.spoolChunk(fileName, body); err != nil {
+		return err
+	}
+	c.mx.Lock()
+	c.tableChunksLeft[t.DumpId]--
+	left := c.tableChunksLeft[t.DumpId]
+	c.mx.Unlock()
+	if left > 0 {
+		return nil
+	}
+	return c.appendDataBlock(t.DumpId, func(w io.Writer) error {
+		for _, chunk := range t.Chunks {
+			if err := c.copySpooledChunk(w, chunk.FileName); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *CustomDump) spoolChunk(fileName string, body io.Reader) error {
+	f, err := os.Create(filepath.Join(c.spoolDir, path.Base(fileName)))
+	if err != nil {
+		return fmt.Errorf("cannot create spool file: %w", err)
+	}
+	_, err = io.Copy(f, body)
+	if err = errors.Join(err, f.Close()); err != nil {
+		return fmt.Errorf("cannot spool table chunk %s: %w", fileName, err)
+	}
+	return nil
+}
+
+func (c *CustomDump) copySpooledChunk(w io.Writer, fileName string) error {
+	name := filepath.Join(c.spoolDir, path.Base(fileName))
+	f, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("cannot open spooled table chunk %s: %w", fileName, err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Warn().Err(err).Str("FileName", name).Msg("error closing spool file")
+		}
+		if err := os.Remove(name); err != nil {
+			log.Warn().Err(err).Str("FileName", name).Msg("error removing spool file")
+		}
+	}()
+	if err = writeCustomCopyData(w, bufio.NewReader(f)); err != nil {
+		return fmt.Errorf("cannot read spooled table chunk %s: %w", fileName, err)
+	}
+	return nil
+}
+
+// appendDataBlock - prepares the data block of the entry in the spool file and appends it to the archive. The COPY
+// data written by writeData is terminated in the same way as pg_dump does
+func (c *CustomDump) appendDataBlock(dumpId int32, writeData func(w io.Writer) error) error {
+	f, err := os.CreateTemp(c.spoolDir, "*.block")
+	if err != nil {
+		return fmt.Errorf("cannot create spool file: %w", err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Warn().Err(err).Str("FileName", f.Name()).Msg("error closing spool file")
+		}
+		if err := os.Remove(f.Name()); err != nil {
+			log.Warn().Err(err).Str("FileName", f.Name()).Msg("error removing spool file")
+		}
+	}()
+
+	bw := bufio.NewWriter(f)
+	w := c.cw.NewDataBlock(bw)
+	if err = writeData(w); err != nil {
+		return fmt.Errorf("cannot write data block: %w", err)
+	}
+	if _, err = w.Write(customCopyDataTerminator); err != nil {
+		return fmt.Errorf("cannot write data block: %w", err)
+	}
+	if err = w.Close(); err != nil {
+		return fmt.Errorf("cannot write data block: %w", err)
+	}
+	if err = bw.Flush(); err != nil {
+		return fmt.Errorf("cannot flush spool file: %w", err)
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("cannot read spool file: %w", err)
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.cw.WriteDataBlock(dumpId, f)
+}
+
+// completeArchive - writes the large objects block, completes the archive and waits for the upload
+func (c *CustomDump) completeArchive(ctx context.Context) error {
+	if c.blobsEntry != nil {
+		if err := writeCustomBlobs(ctx, c.cw, c.blobsEntry, c.tmpSt, ioutils.CodecNone); err != nil {
+			return fmt.Errorf("cannot write large objects: %w", err)
+		}
+	}
+	if err := c.cw.Close(); err != nil {
+		return err
+	}
+	if err := c.bw.Flush(); err != nil {
+		return fmt.Errorf("cannot flush archive: %w", err)
+	}
+	_ = c.pw.Close()
+	<-c.uploadDone
+	if c.uploadErr != nil {
+		return fmt.Errorf("cannot upload custom archive %s: %w", c.fileName, c.uploadErr)
+	}
+	return nil
+}
+
+// customDumpStorage - the storage decorator that appends the table data files to the custom format archive. The
+// other objects are written into the underlying storage
+type customDumpStorage struct {
+	storages.Storager
+	dump *CustomDump
+}
+
+func (cs *customDumpStorage) PutObject(ctx context.Context, filePath string, body io.Reader) error {
+	t, ok := cs.dump.findTableByFileName(filePath)
+	if !ok {
+		return cs.Storager.PutObject(ctx, filePath, body)
+	}
+	return cs.dump.writeTableData(t, filePath, body)
+}
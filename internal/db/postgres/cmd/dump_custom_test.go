@@ -0,0 +1,129 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/entries"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/toc"
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+func newCustomDumpTestArchive(t *testing.T) (*CustomDump, *bytes.Buffer) {
+	tableEntry := func(dumpId int32) *toc.Entry {
+		return &toc.Entry{
+			DumpId:    dumpId,
+			Section:   toc.SectionData,
+			HadDumper: 1,
+			Tag:       toc.NewObj("test"),
+			Desc:      toc.NewObj(toc.TableDataDesc),
+			CopyStmt:  toc.NewObj("COPY public.test (id) FROM stdin;\n"),
+		}
+	}
+	buf := new(bytes.Buffer)
+	c := &CustomDump{
+		spoolDir:        t.TempDir(),
+		mx:              &sync.Mutex{},
+		tableChunksLeft: map[int32]int{2: 2},
+	}
+	c.cw = toc.NewCustomWriter(buf, &toc.Toc{
+		Header: &toc.Header{
+			VersionMajor: 1,
+			VersionMinor: 16,
+			Version:      toc.BackupVersions["1.16"],
+			IntSize:      4,
+			OffSize:      8,
+			ArchDbName:   toc.NewObj("test"),
+		},
+		Entries: []*toc.Entry{tableEntry(1), tableEntry(2)},
+	}, 0)
+	require.NoError(t, c.cw.WriteToc())
+	return c, buf
+}
+
+// readCustomDumpTestBlock - reads the uncompressed data block written with 4 bytes int size
+func readCustomDumpTestBlock(t *testing.T, r io.Reader) (int32, string) {
+	readInt := func() int32 {
+		buf := make([]byte, 5)
+		_, err := io.ReadFull(r, buf)
+		require.NoError(t, err)
+		return int32(binary.LittleEndian.Uint32(buf[1:]))
+	}
+	blockType := make([]byte, 1)
+	_, err := io.ReadFull(r, blockType)
+	require.NoError(t, err)
+	require.Equal(t, toc.BlockData, blockType[0])
+	dumpId := readInt()
+	var data []byte
+	for l := readInt(); l != 0; l = readInt() {
+		chunk := make([]byte, l)
+		_, err = io.ReadFull(r, chunk)
+		require.NoError(t, err)
+		data = append(data, chunk...)
+	}
+	return dumpId, string(data)
+}
+
+func TestCustomDump_writeTableData(t *testing.T) {
+	c, buf := newCustomDumpTestArchive(t)
+	table := &entries.Table{Table: &toolkit.Table{}, DumpId: 1}
+	chunked := &entries.Table{
+		Table:  &toolkit.Table{},
+		DumpId: 2,
+		Chunks: []*entries.TableChunk{{Part: 0, FileName: "2.0.dat"}, {Part: 1, FileName: "2.1.dat"}},
+	}
+	r := bytes.NewReader(buf.Bytes())
+	_, err := toc.NewReader(r).Read()
+	require.NoError(t, err)
+	tocSize := buf.Len()
+
+	// The chunks are spooled until the last one is written
+	require.NoError(t, c.writeTableData(chunked, "2.1.dat", bytes.NewBufferString("3\n\\.\n\n")))
+	assert.Equal(t, tocSize, buf.Len())
+	require.NoError(t, c.writeTableData(table, "1.dat", bytes.NewBufferString("1\n\\.\n\n")))
+	require.NoError(t, c.writeTableData(chunked, "2.0.dat", bytes.NewBufferString("2\n\\.\n\n")))
+
+	// The data blocks are appended in the order the tables are completed and the chunks are concatenated in the
+	// part order
+	r = bytes.NewReader(buf.Bytes()[tocSize:])
+	dumpId, data := readCustomDumpTestBlock(t, r)
+	assert.Equal(t, int32(1), dumpId)
+	assert.Equal(t, "1\n\\.\n\n\n", data)
+	dumpId, data = readCustomDumpTestBlock(t, r)
+	assert.Equal(t, int32(2), dumpId)
+	assert.Equal(t, "2\n3\n\\.\n\n\n", data)
+	assert.Zero(t, r.Len())
+
+	spooled, err := os.ReadDir(c.spoolDir)
+	require.NoError(t, err)
+	assert.Empty(t, spooled)
+}
+
+func TestGetCustomArchiveLevel(t *testing.T) {
+	assert.Equal(t, 0, getCustomArchiveLevel(&ioutils.CompressionSettings{Codec: ioutils.CodecNone}))
+	assert.Equal(t, -1, getCustomArchiveLevel(&ioutils.CompressionSettings{Codec: ioutils.CodecGzip}))
+	assert.Equal(t, 9, getCustomArchiveLevel(&ioutils.CompressionSettings{Codec: ioutils.CodecGzip, Level: 9}))
+	assert.Equal(t, -1, getCustomArchiveLevel(&ioutils.CompressionSettings{Codec: ioutils.CodecZstd, Level: 19}))
+}
@@ -0,0 +1,303 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/entries"
+	storageDto "github.com/greenmaskio/greenmask/internal/db/postgres/storage"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
+)
+
+var ErrPreviousDumpNotFound = errors.New("previous dump is not found or is not completed")
+
+const tablesStatsQuery = `
+	SELECT c.oid,
+	       c.relfilenode,
+	       coalesce(s.n_tup_ins, 0),
+	       coalesce(s.n_tup_upd, 0),
+	       coalesce(s.n_tup_del, 0)
+	FROM pg_catalog.pg_class c
+	         JOIN pg_catalog.pg_stat_user_tables s ON s.relid = c.oid
+`
+
+// SetIncrementalFrom - enables the incremental mode. The tables that were not changed since the dump with the
+// provided id are not dumped. Instead, the metadata stores references to the objects of the previous dump. The dumps
+// storage must be set by SetDumpsStorage
+func (d *Dump) SetIncrementalFrom(dumpId string) {
+	d.incrementalFrom = dumpId
+}
+
+// readPreviousMetadata - reads the metadata of the dump that is used as the base for the incremental dump
+func (d *Dump) readPreviousMetadata(ctx context.Context) error {
+	st, err := GetDumpStorage(ctx, d.dumpsSt, d.incrementalFrom)
+	if err != nil {
+		if errors.Is(err, ErrDumpNotFound) {
+			return ErrPreviousDumpNotFound
+		}
+		return fmt.Errorf("cannot check previous dump metadata existence: %w", err)
+	}
+
+	f, err := st.GetObject(ctx, MetadataJsonFileName)
+	if err != nil {
+		return fmt.Errorf("cannot open previous dump metadata: %w", err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Warn().Err(err).Msg("error closing previous dump metadata")
+		}
+	}()
+
+	md := &storageDto.Metadata{}
+	if err = json.NewDecoder(f).Decode(md); err != nil {
+		return fmt.Errorf("cannot decode previous dump metadata: %w", err)
+	}
+
+	if md.TransformationChecksum != d.transformationChecksum {
+		log.Warn().
+			Str("IncrementalFrom", d.incrementalFrom).
+			Msg("transformation config has been changed since the previous dump: all the tables will be dumped")
+		return nil
+	}
+	d.previousMetadata = md
+	return nil
+}
+
+// setTransformationChecksum - calculates the checksum of the config parts that affect the transformed data
+func (d *Dump) setTransformationChecksum() error {
+	// The chunking options do not affect the transformed data
+	transformation := make([]*domains.Table, 0, len(d.config.Dump.Transformation))
+	for _, t := range d.config.Dump.Transformation {
+		tc := *t
+		tc.Chunks, tc.ChunkBy = 0, ""
+		transformation = append(transformation, &tc)
+	}
+	data, err := json.Marshal(struct {
+		AutoAnonymize      bool                         `json:"auto_anonymize"`
+		AutoAnonymizeRules []*domains.AutoAnonymizeRule `json:"auto_anonymize_rules"`
+		Transformation     []*domains.Table             `json:"transformation"`
+		CustomTransformers any                          `json:"custom_transformers"`
+	}{
+		AutoAnonymize:      d.config.Dump.AutoAnonymize,
+		AutoAnonymizeRules: d.config.Dump.AutoAnonymizeRules,
+		Transformation:     transformation,
+		CustomTransformers: d.config.CustomTransformers,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot encode transformation config: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	d.transformationChecksum = hex.EncodeToString(sum[:])
+	return nil
+}
+
+// gatherTablesStats - collects the tables statistics counters. It must be called before the snapshot is taken, so
+// the changes that were committed between the statistics reading and the snapshot are considered as changes by the
+// next incremental dump
+func (d *Dump) gatherTablesStats(ctx context.Context, conn *pgx.Conn) error {
+	rows, err := conn.Query(ctx, tablesStatsQuery)
+	if err != nil {
+		return fmt.Errorf("cannot query tables statistics: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		ts := &storageDto.TableState{}
+		if err = rows.Scan(&ts.Oid, &ts.RelFileNode, &ts.NTupIns, &ts.NTupUpd, &ts.NTupDel); err != nil {
+			return fmt.Errorf("cannot scan tables statistics: %w", err)
+		}
+		d.tablesState[ts.Oid] = ts
+	}
+	return rows.Err()
+}
+
+// gatherWatermarks - collects the max values of the watermark columns in the dump snapshot
+func (d *Dump) gatherWatermarks(ctx context.Context, tx pgx.Tx) error {
+	for _, obj := range d.context.DataSectionObjects {
+		t, ok := obj.(*entries.Table)
+		if !ok {
+			continue
+		}
+		ts, ok := d.tablesState[t.Oid]
+		if !ok {
+			continue
+		}
+		cfg := domains.FindTable(d.config.Dump.Transformation, t.Schema, t.Name)
+		if cfg == nil || cfg.WatermarkColumn == "" {
+			continue
+		}
+		query := fmt.Sprintf(
+			"SELECT max(%s)::TEXT FROM %s",
+			pgx.Identifier{cfg.WatermarkColumn}.Sanitize(), pgx.Identifier{t.Schema, t.Name}.Sanitize(),
+		)
+		if err := tx.QueryRow(ctx, query).Scan(&ts.Watermark); err != nil {
+			return fmt.Errorf("cannot get watermark of table %s.%s: %w", t.Schema, t.Name, err)
+		}
+		ts.WatermarkColumn = cfg.WatermarkColumn
+	}
+	return nil
+}
+
+// findTableReference - returns the reference to the table data of the previous dump if the table has not been changed
+// since it. Otherwise, it returns nil
+func (d *Dump) findTableReference(ctx context.Context, t *entries.Table) (*storageDto.ObjectReference, error) {
+	if d.previousMetadata == nil || t.Query != "" {
+		// Tables with query or subset conditions depend on the other tables data
+		return nil, nil
+	}
+	current, ok := d.tablesState[t.Oid]
+	if !ok {
+		return nil, nil
+	}
+	previous, ok := d.previousMetadata.GetTableState(t.Schema, t.Name)
+	if !ok || !isTableStateEqual(previous, current) {
+		return nil, nil
+	}
+	if !d.isTableColumnsEqual(t) {
+		return nil, nil
+	}
+
+	entry, ok := d.previousMetadata.GetEntry(previous.DumpId)
+	if !ok {
+		return nil, nil
+	}
+	ref, ok := d.previousMetadata.References[previous.DumpId]
+	if !ok {
+		ref = &storageDto.ObjectReference{
+			DumpId:   d.incrementalFrom,
+			FileName: entry.FileName,
+			Chunks:   entry.Chunks,
+		}
+	} else if len(entry.Chunks) > 0 {
+		ref = &storageDto.ObjectReference{
+			DumpId:   ref.DumpId,
+			FileName: ref.FileName,
+			Chunks:   ref.GetChunks(entry),
+		}
+	}
+
+	// The chunked table data is stored in the chunk files. The chunks are referenced by their names
+	fileNames := []string{ref.FileName}
+	if len(ref.Chunks) > 0 {
+		fileNames = ref.Chunks
+	}
+	refSt, err := d.refStorages.get(ctx, ref.DumpId)
+	if err != nil {
+		return nil, err
+	}
+	for _, fileName := range fileNames {
+		exists, err := refSt.Exists(ctx, fileName)
+		if err != nil {
+			return nil, fmt.Errorf("cannot check referenced object existence: %w", err)
+		}
+		if !exists {
+			log.Warn().
+				Str("SchemaName", t.Schema).
+				Str("TableName", t.Name).
+				Str("ReferencedDumpId", ref.DumpId).
+				Str("FileName", fileName).
+				Msg("referenced object is not found: table will be dumped")
+			return nil, nil
+		}
+	}
+
+	// The chunks are named by the dump id of the table in this dump. The codec of the referenced chunks is kept
+	t.Chunks = nil
+	for idx, fileName := range ref.Chunks {
+		t.Chunks = append(t.Chunks, &entries.TableChunk{
+			Part:     idx,
+			FileName: entries.ChunkFileName(t.DumpId, idx, ioutils.GetCodecByFileName(fileName)),
+			Checksum: entry.Checksums[fileName],
+		})
+	}
+	t.OriginalSize = entry.OriginalSize
+	t.CompressedSize = entry.CompressedSize
+	t.Checksum = entry.Checksums[ref.FileName]
+	return ref, nil
+}
+
+// isTableColumnsEqual - checks that the table columns are the same as in the previous dump
+func (d *Dump) isTableColumnsEqual(t *entries.Table) bool {
+	for _, prev := range d.previousMetadata.DatabaseSchema {
+		if prev.Oid != t.Oid {
+			continue
+		}
+		if len(prev.Columns) != len(t.Columns) {
+			return false
+		}
+		for idx, c := range t.Columns {
+			if prev.Columns[idx].Name != c.Name || prev.Columns[idx].TypeOid != c.TypeOid {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// setReference - stores the reference to the table data of the previous dump
+func (d *Dump) setReference(t *entries.Table, ref *storageDto.ObjectReference) {
+	d.referencesMx.Lock()
+	d.references[t.DumpId] = ref
+	d.referencesMx.Unlock()
+}
+
+// getTablesState - returns the change indicators of the data section tables for storing in metadata
+func (d *Dump) getTablesState() []*storageDto.TableState {
+	var res []*storageDto.TableState
+	for _, obj := range d.context.DataSectionObjects {
+		t, ok := obj.(*entries.Table)
+		if !ok || t.RelKind == 'p' {
+			continue
+		}
+		ts, ok := d.tablesState[t.Oid]
+		if !ok {
+			continue
+		}
+		ts.Schema = t.Schema
+		ts.Name = t.Name
+		ts.DumpId = t.DumpId
+		res = append(res, ts)
+	}
+	return res
+}
+
+// isTableStateEqual - compares the change indicators. If the watermark column is set the insert and update counters
+// are not compared because those changes are tracked by the watermark
+func isTableStateEqual(previous, current *storageDto.TableState) bool {
+	if previous.Oid != current.Oid ||
+		previous.RelFileNode != current.RelFileNode ||
+		previous.NTupDel != current.NTupDel ||
+		previous.WatermarkColumn != current.WatermarkColumn {
+		return false
+	}
+	if current.WatermarkColumn == "" {
+		return previous.NTupIns == current.NTupIns && previous.NTupUpd == current.NTupUpd
+	}
+	if previous.Watermark == nil || current.Watermark == nil {
+		return previous.Watermark == nil && current.Watermark == nil
+	}
+	return *previous.Watermark == *current.Watermark
+}
@@ -0,0 +1,245 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	storageDto "github.com/greenmaskio/greenmask/internal/db/postgres/storage"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/toc"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
+	"github.com/greenmaskio/greenmask/internal/utils/testutils"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+func Test_isTableStateEqual(t *testing.T) {
+	watermark := "2024-01-01 00:00:00"
+	newWatermark := "2024-01-02 00:00:00"
+	previous := &storageDto.TableState{Oid: 1, RelFileNode: 10, NTupIns: 5, NTupUpd: 3, NTupDel: 1}
+
+	tests := []struct {
+		name     string
+		previous *storageDto.TableState
+		current  *storageDto.TableState
+		expected bool
+	}{
+		{
+			name:     "unchanged",
+			previous: previous,
+			current:  &storageDto.TableState{Oid: 1, RelFileNode: 10, NTupIns: 5, NTupUpd: 3, NTupDel: 1},
+			expected: true,
+		},
+		{
+			name:     "inserted",
+			previous: previous,
+			current:  &storageDto.TableState{Oid: 1, RelFileNode: 10, NTupIns: 6, NTupUpd: 3, NTupDel: 1},
+			expected: false,
+		},
+		{
+			name:     "truncated",
+			previous: previous,
+			current:  &storageDto.TableState{Oid: 1, RelFileNode: 11, NTupIns: 5, NTupUpd: 3, NTupDel: 1},
+			expected: false,
+		},
+		{
+			name: "watermark is not changed",
+			previous: &storageDto.TableState{
+				Oid: 1, RelFileNode: 10, NTupIns: 5, WatermarkColumn: "updated_at", Watermark: &watermark,
+			},
+			current: &storageDto.TableState{
+				Oid: 1, RelFileNode: 10, NTupIns: 7, WatermarkColumn: "updated_at", Watermark: &watermark,
+			},
+			expected: true,
+		},
+		{
+			name: "watermark is changed",
+			previous: &storageDto.TableState{
+				Oid: 1, RelFileNode: 10, WatermarkColumn: "updated_at", Watermark: &watermark,
+			},
+			current: &storageDto.TableState{
+				Oid: 1, RelFileNode: 10, WatermarkColumn: "updated_at", Watermark: &newWatermark,
+			},
+			expected: false,
+		},
+		{
+			name: "watermark deleted rows",
+			previous: &storageDto.TableState{
+				Oid: 1, RelFileNode: 10, WatermarkColumn: "updated_at", Watermark: &watermark,
+			},
+			current: &storageDto.TableState{
+				Oid: 1, RelFileNode: 10, NTupDel: 1, WatermarkColumn: "updated_at", Watermark: &watermark,
+			},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isTableStateEqual(tt.previous, tt.current))
+		})
+	}
+}
+
+func TestDump_findTableReference(t *testing.T) {
+	ctx := context.Background()
+	columns := []*toolkit.Column{{Name: "id", TypeOid: 23}}
+
+	newIncrementalDump := func(dumpsSt *testutils.StorageMock) *Dump {
+		d := NewDump(&domains.Config{}, &testutils.StorageMock{}, nil)
+		d.SetDumpsStorage(dumpsSt)
+		d.SetIncrementalFrom("1000")
+		d.tablesState[1] = &storageDto.TableState{Oid: 1, RelFileNode: 10, NTupIns: 5}
+		d.previousMetadata = &storageDto.Metadata{
+			DatabaseSchema: toolkit.DatabaseSchema{{Oid: 1, Schema: "public", Name: "users", Columns: columns}},
+			Entries: []*storageDto.Entry{
+				{DumpId: 105, ObjectType: toc.TableDataDesc, FileName: "105.dat.gz", OriginalSize: 100, CompressedSize: 10},
+			},
+			TablesState: []*storageDto.TableState{
+				{Oid: 1, Schema: "public", Name: "users", DumpId: 105, RelFileNode: 10, NTupIns: 5},
+			},
+		}
+		return d
+	}
+
+	t.Run("unchanged table references previous dump", func(t *testing.T) {
+		prevSt := &testutils.StorageMock{}
+		prevSt.On("Exists", ctx, "105.dat.gz").Return(true, nil)
+		dumpsSt := &testutils.StorageMock{}
+		dumpsSt.On("Exists", ctx, "1000.tar").Return(false, nil)
+		dumpsSt.On("SubStorage", "1000", true).Return(prevSt)
+
+		d := newIncrementalDump(dumpsSt)
+		table := newTableEntry(1, "users")
+		table.Columns = columns
+		ref, err := d.findTableReference(ctx, table)
+		require.NoError(t, err)
+		require.NotNil(t, ref)
+		assert.Equal(t, "1000", ref.DumpId)
+		assert.Equal(t, "105.dat.gz", ref.FileName)
+		assert.Equal(t, int64(100), table.OriginalSize)
+		assert.Equal(t, int64(10), table.CompressedSize)
+	})
+
+	t.Run("reference of previous dump is reused", func(t *testing.T) {
+		originSt := &testutils.StorageMock{}
+		originSt.On("Exists", ctx, "101.dat.gz").Return(true, nil)
+		dumpsSt := &testutils.StorageMock{}
+		dumpsSt.On("Exists", ctx, "900.tar").Return(false, nil)
+		dumpsSt.On("SubStorage", "900", true).Return(originSt)
+
+		d := newIncrementalDump(dumpsSt)
+		d.previousMetadata.References = map[int32]*storageDto.ObjectReference{
+			105: {DumpId: "900", FileName: "101.dat.gz"},
+		}
+		table := newTableEntry(1, "users")
+		table.Columns = columns
+		ref, err := d.findTableReference(ctx, table)
+		require.NoError(t, err)
+		require.NotNil(t, ref)
+		assert.Equal(t, "900", ref.DumpId)
+		assert.Equal(t, "101.dat.gz", ref.FileName)
+	})
+
+	t.Run("chunks are renamed by table dump id", func(t *testing.T) {
+		prevSt := &testutils.StorageMock{}
+		prevSt.On("Exists", ctx, "105.0.dat.gz").Return(true, nil)
+		prevSt.On("Exists", ctx, "105.1.dat.gz").Return(true, nil)
+		dumpsSt := &testutils.StorageMock{}
+		dumpsSt.On("Exists", ctx, "1000.tar").Return(false, nil)
+		dumpsSt.On("SubStorage", "1000", true).Return(prevSt)
+
+		d := newIncrementalDump(dumpsSt)
+		e := d.previousMetadata.Entries[0]
+		e.Chunks = []string{"105.0.dat.gz", "105.1.dat.gz"}
+		e.Checksums = map[string]string{"105.0.dat.gz": "sum0", "105.1.dat.gz": "sum1"}
+		table := newTableEntry(1, "users")
+		table.Columns = columns
+		table.DumpId = 110
+		table.Codec = ioutils.CodecZstd
+		ref, err := d.findTableReference(ctx, table)
+		require.NoError(t, err)
+		require.NotNil(t, ref)
+		assert.Equal(t, []string{"105.0.dat.gz", "105.1.dat.gz"}, ref.Chunks)
+		// The referenced chunks keep their codec
+		assert.Equal(t, []string{"110.0.dat.gz", "110.1.dat.gz"}, table.ChunkFileNames())
+
+		d.setReference(table, ref)
+		entry, err := table.Entry()
+		require.NoError(t, err)
+		assert.Equal(
+			t, map[string]string{"105.0.dat.gz": "sum0", "105.1.dat.gz": "sum1"}, d.getTableChecksums(table, entry),
+		)
+	})
+
+	t.Run("chunks of previous dump reference are reused", func(t *testing.T) {
+		originSt := &testutils.StorageMock{}
+		originSt.On("Exists", ctx, "101.0.dat.gz").Return(true, nil)
+		dumpsSt := &testutils.StorageMock{}
+		dumpsSt.On("Exists", ctx, "900.tar").Return(false, nil)
+		dumpsSt.On("SubStorage", "900", true).Return(originSt)
+
+		d := newIncrementalDump(dumpsSt)
+		// The metadata of the previous versions stores the referenced chunk names in the entry
+		d.previousMetadata.Entries[0].Chunks = []string{"101.0.dat.gz"}
+		d.previousMetadata.References = map[int32]*storageDto.ObjectReference{
+			105: {DumpId: "900", FileName: "101.dat.gz"},
+		}
+		table := newTableEntry(1, "users")
+		table.Columns = columns
+		table.DumpId = 110
+		ref, err := d.findTableReference(ctx, table)
+		require.NoError(t, err)
+		require.NotNil(t, ref)
+		assert.Equal(t, "900", ref.DumpId)
+		assert.Equal(t, []string{"101.0.dat.gz"}, ref.Chunks)
+		assert.Equal(t, []string{"110.0.dat.gz"}, table.ChunkFileNames())
+		// The reference of the previous dump is not changed
+		assert.Empty(t, d.previousMetadata.References[105].Chunks)
+	})
+
+	t.Run("changed table", func(t *testing.T) {
+		d := newIncrementalDump(&testutils.StorageMock{})
+		d.tablesState[1].NTupIns = 6
+		table := newTableEntry(1, "users")
+		table.Columns = columns
+		ref, err := d.findTableReference(ctx, table)
+		require.NoError(t, err)
+		assert.Nil(t, ref)
+	})
+
+	t.Run("columns changed", func(t *testing.T) {
+		d := newIncrementalDump(&testutils.StorageMock{})
+		table := newTableEntry(1, "users")
+		table.Columns = []*toolkit.Column{{Name: "id", TypeOid: 20}}
+		ref, err := d.findTableReference(ctx, table)
+		require.NoError(t, err)
+		assert.Nil(t, ref)
+	})
+
+	t.Run("table with query", func(t *testing.T) {
+		d := newIncrementalDump(&testutils.StorageMock{})
+		table := newTableEntry(1, "users")
+		table.Columns = columns
+		table.Query = "SELECT * FROM public.users WHERE id > 10"
+		ref, err := d.findTableReference(ctx, table)
+		require.NoError(t, err)
+		assert.Nil(t, ref)
+	})
+}
@@ -0,0 +1,259 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/entries"
+	storageDto "github.com/greenmaskio/greenmask/internal/db/postgres/storage"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/toc"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+const ProgressJsonFileName = "progress.json"
+
+var (
+	ErrDumpIsAlreadyCompleted = errors.New("dump is already completed")
+	ErrProgressNotFound       = errors.New("progress manifest is not found: dump cannot be resumed")
+	ErrSchemaChanged          = errors.New("schema has been changed since the interrupted run: dump cannot be resumed")
+)
+
+// SetResume - enables the resume mode. In this mode the dump continues the interrupted dump in the same storage
+// directory and dumps only the tables that were not completely written before
+func (d *Dump) SetResume(resume bool) {
+	d.resume = resume
+}
+
+// readProgress - reads the progress manifest of the interrupted dump
+func (d *Dump) readProgress(ctx context.Context) error {
+	completed, err := d.st.Exists(ctx, MetadataJsonFileName)
+	if err != nil {
+		return fmt.Errorf("cannot check metadata existence: %w", err)
+	}
+	if completed {
+		return ErrDumpIsAlreadyCompleted
+	}
+
+	exists, err := d.st.Exists(ctx, ProgressJsonFileName)
+	if err != nil {
+		return fmt.Errorf("cannot check progress manifest existence: %w", err)
+	}
+	if !exists {
+		return ErrProgressNotFound
+	}
+
+	f, err := d.st.GetObject(ctx, ProgressJsonFileName)
+	if err != nil {
+		return fmt.Errorf("cannot open progress manifest: %w", err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Warn().Err(err).Msg("error closing progress manifest")
+		}
+	}()
+
+	progress := &storageDto.Progress{}
+	if err = json.NewDecoder(f).Decode(progress); err != nil {
+		return fmt.Errorf("cannot decode progress manifest: %w", err)
+	}
+	if progress.Tables == nil {
+		progress.Tables = make(map[toolkit.Oid]*storageDto.TableProgress)
+	}
+	d.progress = progress
+	return nil
+}
+
+// setupProgress - checks that the interrupted dump can be continued with the current schema TOC and shifts the DumpId
+// sequence after the DumpIds that were already assigned. In a regular run it stores the initial manifest
+func (d *Dump) setupProgress(ctx context.Context) error {
+	maxDumpId := d.schemaToc.Header.MaxDumpId
+	if !d.resume {
+		d.progress.SchemaMaxDumpId = maxDumpId
+		return d.writeProgress(ctx)
+	}
+
+	if d.progress.SchemaMaxDumpId != maxDumpId {
+		log.Debug().
+			Int32("PreviousMaxDumpId", d.progress.SchemaMaxDumpId).
+			Int32("CurrentMaxDumpId", maxDumpId).
+			Msg("schema toc max dump id mismatch")
+		return ErrSchemaChanged
+	}
+	if assignedMaxDumpId := d.progress.MaxDumpId(); assignedMaxDumpId > maxDumpId {
+		d.dumpIdSequence = toc.NewDumpSequence(assignedMaxDumpId)
+	}
+	return nil
+}
+
+// setDumpId - sets DumpId for the data section object. The table that was planned in the interrupted run gets the
+// same DumpId, so the already written data file can be reused
+func (d *Dump) setDumpId(obj entries.Entry) {
+	if t, ok := obj.(*entries.Table); ok && d.resume {
+		d.progressMx.Lock()
+		tp, found := d.progress.Tables[t.Oid]
+		d.progressMx.Unlock()
+		if found {
+			t.DumpId = tp.DumpId
+			return
+		}
+	}
+	obj.SetDumpId(d.dumpIdSequence)
+}
+
+// assignDumpIds - assigns the dump ids of the data section objects before the data dump in the same order as
+// taskProducer does. It is used when the TOC must be written before the data
+func (d *Dump) assignDumpIds() {
+	for _, obj := range d.context.DataSectionObjects {
+		d.setDumpId(obj)
+	}
+	d.dumpIdsAssigned = true
+}
+
+// isTableDumped - checks that the table data was completely written in the interrupted run. If so, the table sizes
+// are restored from the manifest
+func (d *Dump) isTableDumped(ctx context.Context, t *entries.Table) (bool, error) {
+	if !d.resume {
+		return false, nil
+	}
+	d.progressMx.Lock()
+	tp, found := d.progress.Tables[t.Oid]
+	d.progressMx.Unlock()
+	if !found || tp.Status != storageDto.TableProgressDone {
+		return false, nil
+	}
+
+	var chunks []*entries.TableChunk
+	fileNames := []string{t.DataFileName()}
+	if len(tp.Chunks) > 0 {
+		var err error
+		chunks, err = restoreTableChunks(tp.Chunks)
+		if err != nil {
+			return false, fmt.Errorf("cannot restore chunks of table %s.%s: %w", t.Schema, t.Name, err)
+		}
+		fileNames = fileNames[:0]
+		for _, c := range chunks {
+			fileNames = append(fileNames, c.FileName)
+		}
+	}
+	for _, fileName := range fileNames {
+		exists, err := d.st.Exists(ctx, fileName)
+		if err != nil {
+			return false, fmt.Errorf("cannot check table data file existence: %w", err)
+		}
+		if !exists {
+			return false, nil
+		}
+	}
+	t.Chunks = chunks
+	t.OriginalSize = tp.OriginalSize
+	t.CompressedSize = tp.CompressedSize
+	t.Checksum = tp.Checksum
+	return true, nil
+}
+
+// restoreTableChunks - builds the chunks of the table that has been dumped using the map of the chunk file name to
+// its checksum. Such chunks do not have the condition because they are not dumped again. The part numbers are parsed
+// from the file names because the string order of the names is not the order of the parts
+func restoreTableChunks(checksums map[string]string) ([]*entries.TableChunk, error) {
+	res := make([]*entries.TableChunk, 0, len(checksums))
+	for fileName, checksum := range checksums {
+		part, err := entries.ParseChunkPart(fileName)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, &entries.TableChunk{
+			Part:     part,
+			FileName: fileName,
+			Checksum: checksum,
+		})
+	}
+	slices.SortFunc(res, func(a, b *entries.TableChunk) int {
+		return a.Part - b.Part
+	})
+	return res, nil
+}
+
+// markTableProgress - sets the table status in the manifest and writes it into the storage
+func (d *Dump) markTableProgress(ctx context.Context, t *entries.Table, status string) error {
+	if d.progress == nil {
+		return nil
+	}
+	var chunks map[string]string
+	if status == storageDto.TableProgressDone && t.IsChunked() {
+		chunks = t.DataChecksums()
+	}
+	d.progressMx.Lock()
+	d.progress.Tables[t.Oid] = &storageDto.TableProgress{
+		DumpId:         t.DumpId,
+		Schema:         t.Schema,
+		Name:           t.Name,
+		Status:         status,
+		OriginalSize:   t.OriginalSize,
+		CompressedSize: t.CompressedSize,
+		Checksum:       t.Checksum,
+		Chunks:         chunks,
+	}
+	d.progressMx.Unlock()
+	return d.writeProgress(ctx)
+}
+
+// writeProgress - writes the progress manifest into the storage. The manifest is encoded under progressMx and uploaded
+// outside it. The uploads are serialized and the state that is older than the uploaded one is skipped, so the older
+// state never overwrites the newer one
+func (d *Dump) writeProgress(ctx context.Context) error {
+	buf := bytes.NewBuffer(make([]byte, 0, 1024))
+	d.progressMx.Lock()
+	d.progressVersion++
+	version := d.progressVersion
+	err := json.NewEncoder(buf).Encode(d.progress)
+	d.progressMx.Unlock()
+	if err != nil {
+		return fmt.Errorf("error encoding progress manifest: %w", err)
+	}
+
+	d.progressWriteMx.Lock()
+	defer d.progressWriteMx.Unlock()
+	if version <= d.progressWrittenVersion {
+		// The newer state that includes this one is already written
+		return nil
+	}
+	if err = d.st.PutObject(ctx, ProgressJsonFileName, buf); err != nil {
+		return fmt.Errorf("error writing progress manifest to the storage: %w", err)
+	}
+	d.progressWrittenVersion = version
+	return nil
+}
+
+// removeProgress - deletes the progress manifest after the dump is completed
+func (d *Dump) removeProgress(ctx context.Context) {
+	if err := d.st.Delete(ctx, ProgressJsonFileName); err != nil {
+		log.Warn().Err(err).Msg("unable to delete progress manifest")
+	}
+}
+
+func (d *Dump) heartBeatInProgressContent() string {
+	if d.resume {
+		return HeartBeatResumedContent
+	}
+	return HeartBeatInProgressContent
+}
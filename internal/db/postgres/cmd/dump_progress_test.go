@@ -0,0 +1,189 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/entries"
+	storageDto "github.com/greenmaskio/greenmask/internal/db/postgres/storage"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/toc"
+	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/storages/directory"
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
+	"github.com/greenmaskio/greenmask/internal/utils/testutils"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+func newResumedDump(st *testutils.StorageMock, schemaMaxDumpId int32) *Dump {
+	progress := storageDto.NewProgress(time.Now())
+	progress.SchemaMaxDumpId = schemaMaxDumpId
+	progress.Tables[1] = &storageDto.TableProgress{
+		DumpId: 105, Schema: "public", Name: "done", Status: storageDto.TableProgressDone,
+		OriginalSize: 100, CompressedSize: 10,
+	}
+	progress.Tables[2] = &storageDto.TableProgress{
+		DumpId: 106, Schema: "public", Name: "partial", Status: storageDto.TableProgressInProgress,
+	}
+	return &Dump{
+		st:              st,
+		resume:          true,
+		progress:        progress,
+		progressMx:      &sync.Mutex{},
+		progressWriteMx: &sync.Mutex{},
+		schemaToc: &toc.Toc{
+			Header: &toc.Header{MaxDumpId: schemaMaxDumpId},
+		},
+		dumpIdSequence: toc.NewDumpSequence(schemaMaxDumpId + 1),
+	}
+}
+
+func newTableEntry(oid toolkit.Oid, name string) *entries.Table {
+	return &entries.Table{
+		Table: &toolkit.Table{Oid: oid, Schema: "public", Name: name},
+	}
+}
+
+func TestDump_setupProgress(t *testing.T) {
+	t.Run("schema changed", func(t *testing.T) {
+		d := newResumedDump(&testutils.StorageMock{}, 100)
+		d.schemaToc.Header.MaxDumpId = 101
+		require.ErrorIs(t, d.setupProgress(context.Background()), ErrSchemaChanged)
+	})
+
+	t.Run("sequence is shifted after planned tables", func(t *testing.T) {
+		d := newResumedDump(&testutils.StorageMock{}, 100)
+		require.NoError(t, d.setupProgress(context.Background()))
+
+		newTable := newTableEntry(3, "new")
+		d.setDumpId(newTable)
+		assert.Equal(t, int32(107), newTable.DumpId)
+	})
+}
+
+func TestDump_isTableDumped(t *testing.T) {
+	ctx := context.Background()
+	st := &testutils.StorageMock{}
+	st.On("Exists", ctx, "105.dat.gz").Return(true, nil)
+	d := newResumedDump(st, 100)
+	require.NoError(t, d.setupProgress(ctx))
+
+	done := newTableEntry(1, "done")
+	d.setDumpId(done)
+	require.Equal(t, int32(105), done.DumpId)
+	dumped, err := d.isTableDumped(ctx, done)
+	require.NoError(t, err)
+	assert.True(t, dumped)
+	assert.Equal(t, int64(100), done.OriginalSize)
+	assert.Equal(t, int64(10), done.CompressedSize)
+
+	partial := newTableEntry(2, "partial")
+	d.setDumpId(partial)
+	require.Equal(t, int32(106), partial.DumpId)
+	dumped, err = d.isTableDumped(ctx, partial)
+	require.NoError(t, err)
+	assert.False(t, dumped)
+
+	st.AssertExpectations(t)
+}
+
+func Test_restoreTableChunks(t *testing.T) {
+	checksums := make(map[string]string)
+	for part := 0; part < 12; part++ {
+		checksums[entries.ChunkFileName(42, part, ioutils.CodecGzip)] = fmt.Sprintf("c%d", part)
+	}
+	chunks, err := restoreTableChunks(checksums)
+	require.NoError(t, err)
+	require.Len(t, chunks, 12)
+	for idx, c := range chunks {
+		assert.Equal(t, idx, c.Part)
+		assert.Equal(t, fmt.Sprintf("42.%d.dat.gz", idx), c.FileName)
+		assert.Equal(t, fmt.Sprintf("c%d", idx), c.Checksum)
+	}
+
+	_, err = restoreTableChunks(map[string]string{"42.dat.gz": "c"})
+	assert.Error(t, err)
+}
+
+// blockingStorage - the storage that blocks the first PutObject call until release is closed
+type blockingStorage struct {
+	storages.Storager
+	once    sync.Once
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStorage) PutObject(ctx context.Context, filePath string, body io.Reader) error {
+	s.once.Do(func() {
+		close(s.started)
+		<-s.release
+	})
+	return s.Storager.PutObject(ctx, filePath, body)
+}
+
+func TestDump_writeProgress_outside_lock(t *testing.T) {
+	ctx := context.Background()
+	dirSt, err := directory.NewStorage(&directory.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+	st := &blockingStorage{Storager: dirSt, started: make(chan struct{}), release: make(chan struct{})}
+	d := newResumedDump(nil, 100)
+	d.st = st
+
+	table := newTableEntry(3, "new")
+	table.DumpId = 107
+	inProgressErr := make(chan error, 1)
+	go func() {
+		inProgressErr <- d.markTableProgress(ctx, table, storageDto.TableProgressInProgress)
+	}()
+	<-st.started
+
+	// The progress state is available while the manifest is being uploaded
+	checked := make(chan error, 1)
+	go func() {
+		_, err := d.isTableDumped(ctx, newTableEntry(2, "partial"))
+		checked <- err
+	}()
+	select {
+	case err = <-checked:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("progress state is locked during the manifest upload")
+	}
+
+	doneErr := make(chan error, 1)
+	go func() {
+		doneErr <- d.markTableProgress(ctx, table, storageDto.TableProgressDone)
+	}()
+	close(st.release)
+	require.NoError(t, <-inProgressErr)
+	require.NoError(t, <-doneErr)
+
+	// The latest state is written last
+	f, err := dirSt.GetObject(ctx, ProgressJsonFileName)
+	require.NoError(t, err)
+	defer f.Close()
+	progress := &storageDto.Progress{}
+	require.NoError(t, json.NewDecoder(f).Decode(progress))
+	assert.Equal(t, storageDto.TableProgressDone, progress.Tables[3].Status)
+}
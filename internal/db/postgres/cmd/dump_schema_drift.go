@@ -0,0 +1,236 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/entries"
+	storageDto "github.com/greenmaskio/greenmask/internal/db/postgres/storage"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+const (
+	SchemaDriftPolicyIgnore        = "ignore"
+	SchemaDriftPolicyWarn          = "warn"
+	SchemaDriftPolicyFail          = "fail"
+	SchemaDriftPolicyAutoAnonymize = "auto_anonymize"
+)
+
+var ErrSchemaDrift = errors.New("database schema has been changed since the previous dump")
+
+// ValidateSchemaDriftPolicy - checks the schema_drift_policy value. Empty value means ignore
+func ValidateSchemaDriftPolicy(policy string) error {
+	switch policy {
+	case "", SchemaDriftPolicyIgnore, SchemaDriftPolicyWarn, SchemaDriftPolicyFail, SchemaDriftPolicyAutoAnonymize:
+		return nil
+	}
+	return fmt.Errorf(
+		"unknown schema_drift_policy \"%s\": must be one of %s, %s, %s, %s", policy,
+		SchemaDriftPolicyIgnore, SchemaDriftPolicyWarn, SchemaDriftPolicyFail, SchemaDriftPolicyAutoAnonymize,
+	)
+}
+
+// checkSchemaDrift - compares the current database schema with the schema of the latest dump and acts according to
+// the schema_drift_policy. In auto_anonymize mode the new columns that are not transformed by the config get the
+// default transformers and the runtime context is rebuilt
+func (d *Dump) checkSchemaDrift(ctx context.Context, tx pgx.Tx) error {
+	policy := d.config.Dump.SchemaDriftPolicy
+	if policy == "" || policy == SchemaDriftPolicyIgnore {
+		return nil
+	}
+	if d.dumpsSt == nil {
+		return fmt.Errorf("dumps storage is not set")
+	}
+
+	dumpId, err := getLatestDumpId(ctx, d.dumpsSt)
+	if err != nil {
+		return fmt.Errorf("cannot get previous dump id: %w", err)
+	}
+	if dumpId == "" {
+		log.Info().Msg("previous dump is not found: schema drift check is skipped")
+		return nil
+	}
+	md, err := getDumpMetadata(ctx, d.dumpsSt, dumpId)
+	if err != nil {
+		return fmt.Errorf("cannot get previous metadata: %w", err)
+	}
+
+	diff := md.DatabaseSchema.Diff(d.context.DatabaseSchema)
+	if len(diff) > 0 {
+		logSchemaDiff(diff, dumpId)
+	}
+
+	switch policy {
+	case SchemaDriftPolicyFail:
+		if len(diff) > 0 {
+			return fmt.Errorf("%w: previous dump %s", ErrSchemaDrift, dumpId)
+		}
+	case SchemaDriftPolicyAutoAnonymize:
+		newColumns := getNewColumns(md.DatabaseSchema, d.context.DatabaseSchema)
+		addDriftAnonymizedColumns(newColumns, md.DriftAnonymizedColumns, d.context.DatabaseSchema)
+		if err = d.addSchemaDriftTransformers(newColumns); err != nil {
+			return err
+		}
+		if len(d.driftAnonymizedColumns) == 0 {
+			return nil
+		}
+		if err = d.buildContextAndValidate(ctx, tx); err != nil {
+			return fmt.Errorf("cannot rebuild context with default transformers: %w", err)
+		}
+		if d.previousMetadata != nil && hasNewDriftAnonymizedColumns(d.driftAnonymizedColumns, md) {
+			// The objects of the base dump contain the data of the new columns that was not transformed
+			log.Warn().Msg("new columns are transformed by the default transformers: all the tables will be dumped")
+			d.previousMetadata = nil
+		}
+	}
+	return nil
+}
+
+// addSchemaDriftTransformers - adds the default transformers for the new columns that are not transformed by the
+// config. Primary key, generated columns and the columns listed in skip_auto_anonymize are not transformed
+func (d *Dump) addSchemaDriftTransformers(newColumns map[toolkit.Oid][]string) error {
+	rules, err := transformers.CompileAutoAnonymizeRules(d.config.Dump.AutoAnonymizeRules, d.registry)
+	if err != nil {
+		return fmt.Errorf("cannot compile auto anonymize rules: %w", err)
+	}
+
+	for _, obj := range d.context.DataSectionObjects {
+		t, ok := obj.(*entries.Table)
+		if !ok || len(newColumns[t.Oid]) == 0 {
+			continue
+		}
+		affectedColumns := getTableAffectedColumns(t)
+		tableConfig := domains.FindTable(d.config.Dump.Transformation, t.Schema, t.Name)
+
+		for _, name := range newColumns[t.Oid] {
+			idx := slices.IndexFunc(t.Columns, func(c *toolkit.Column) bool {
+				return c.Name == name
+			})
+			if idx == -1 {
+				continue
+			}
+			column := t.Columns[idx]
+			if _, ok := affectedColumns[name]; ok ||
+				column.IsGenerated ||
+				slices.Contains(t.PrimaryKey, name) ||
+				(tableConfig != nil && slices.Contains(tableConfig.SkipAutoAnonymize, name)) {
+				continue
+			}
+
+			tc, err := rules.GetTransformerForColumn(t.Table, column)
+			if err != nil {
+				return fmt.Errorf(
+					"cannot get default transformer for column %s.%s.%s: %w", t.Schema, t.Name, name, err,
+				)
+			}
+			if tc == nil {
+				log.Warn().
+					Str("SchemaName", t.Schema).
+					Str("TableName", t.Name).
+					Str("ColumnName", name).
+					Str("ColumnType", column.TypeName).
+					Msg("new column has no default transformer: it will be dumped as is")
+				continue
+			}
+
+			if tableConfig == nil {
+				tableConfig = &domains.Table{Schema: t.Schema, Name: t.Name}
+				d.config.Dump.Transformation = append(d.config.Dump.Transformation, tableConfig)
+			}
+			tableConfig.Transformers = append(tableConfig.Transformers, tc)
+			d.driftAnonymizedColumns = append(d.driftAnonymizedColumns, &storageDto.ColumnReference{
+				Schema: t.Schema,
+				Table:  t.Name,
+				Column: name,
+			})
+			log.Warn().
+				Str("SchemaName", t.Schema).
+				Str("TableName", t.Name).
+				Str("ColumnName", name).
+				Str("Transformer", tc.Name).
+				Msg("new column is transformed by the default transformer")
+		}
+	}
+	return nil
+}
+
+// getNewColumns - returns the names of the columns of the current schema that do not exist in the previous one by
+// table oid. Tables and columns are matched in the same way as DatabaseSchema.Diff does: by oid and attnum first and
+// then by name. All the columns of the new tables are considered as new
+func getNewColumns(previous, current toolkit.DatabaseSchema) map[toolkit.Oid][]string {
+	res := make(map[toolkit.Oid][]string)
+	for _, ct := range current {
+		idx := slices.IndexFunc(previous, func(t *toolkit.Table) bool {
+			return t.Oid == ct.Oid
+		})
+		if idx == -1 {
+			idx = slices.IndexFunc(previous, func(t *toolkit.Table) bool {
+				return t.Schema == ct.Schema && t.Name == ct.Name
+			})
+		}
+		for _, c := range ct.Columns {
+			if idx != -1 && slices.ContainsFunc(previous[idx].Columns, func(pc *toolkit.Column) bool {
+				return pc.Num == c.Num || pc.Name == c.Name
+			}) {
+				continue
+			}
+			res[ct.Oid] = append(res[ct.Oid], c.Name)
+		}
+	}
+	return res
+}
+
+// addDriftAnonymizedColumns - adds the columns that were transformed by the default transformers in the previous dump.
+// They are still considered as new until they are covered by the config
+func addDriftAnonymizedColumns(
+	newColumns map[toolkit.Oid][]string, columns []*storageDto.ColumnReference, current toolkit.DatabaseSchema,
+) {
+	for _, c := range columns {
+		idx := slices.IndexFunc(current, func(t *toolkit.Table) bool {
+			return t.Schema == c.Schema && t.Name == c.Table
+		})
+		if idx == -1 {
+			continue
+		}
+		t := current[idx]
+		if !slices.ContainsFunc(t.Columns, func(tc *toolkit.Column) bool { return tc.Name == c.Column }) ||
+			slices.Contains(newColumns[t.Oid], c.Column) {
+			continue
+		}
+		newColumns[t.Oid] = append(newColumns[t.Oid], c.Column)
+	}
+}
+
+// hasNewDriftAnonymizedColumns - checks that some of the columns were not transformed by the default transformers in
+// the provided dump
+func hasNewDriftAnonymizedColumns(columns []*storageDto.ColumnReference, md *storageDto.Metadata) bool {
+	for _, c := range columns {
+		if !slices.ContainsFunc(md.DriftAnonymizedColumns, func(pc *storageDto.ColumnReference) bool {
+			return *pc == *c
+		}) {
+			return true
+		}
+	}
+	return false
+}
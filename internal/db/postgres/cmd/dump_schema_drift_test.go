@@ -0,0 +1,102 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	storageDto "github.com/greenmaskio/greenmask/internal/db/postgres/storage"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+func TestValidateSchemaDriftPolicy(t *testing.T) {
+	for _, policy := range []string{
+		"", SchemaDriftPolicyIgnore, SchemaDriftPolicyWarn, SchemaDriftPolicyFail, SchemaDriftPolicyAutoAnonymize,
+	} {
+		assert.NoError(t, ValidateSchemaDriftPolicy(policy))
+	}
+	require.Error(t, ValidateSchemaDriftPolicy("anonymize"))
+}
+
+func Test_getNewColumns(t *testing.T) {
+	previous := toolkit.DatabaseSchema{
+		{
+			Schema: "public", Name: "users", Oid: 1, Kind: "r",
+			Columns: []*toolkit.Column{
+				{Name: "id", Num: 1},
+				{Name: "login", Num: 2},
+			},
+		},
+		{
+			Schema: "public", Name: "orders", Oid: 2, Kind: "r",
+			Columns: []*toolkit.Column{{Name: "id", Num: 1}},
+		},
+	}
+	current := toolkit.DatabaseSchema{
+		{
+			Schema: "public", Name: "users", Oid: 1, Kind: "r",
+			Columns: []*toolkit.Column{
+				{Name: "id", Num: 1},
+				// Renamed column is matched by attnum
+				{Name: "username", Num: 2},
+				{Name: "email", Num: 3},
+			},
+		},
+		// Recreated table is matched by name
+		{
+			Schema: "public", Name: "orders", Oid: 20, Kind: "r",
+			Columns: []*toolkit.Column{{Name: "id", Num: 1}},
+		},
+		{
+			Schema: "public", Name: "payments", Oid: 3, Kind: "r",
+			Columns: []*toolkit.Column{
+				{Name: "id", Num: 1},
+				{Name: "card", Num: 2},
+			},
+		},
+	}
+
+	res := getNewColumns(previous, current)
+	assert.Equal(t, map[toolkit.Oid][]string{
+		1: {"email"},
+		3: {"id", "card"},
+	}, res)
+
+	addDriftAnonymizedColumns(res, []*storageDto.ColumnReference{
+		{Schema: "public", Table: "users", Column: "username"},
+		{Schema: "public", Table: "users", Column: "email"},
+		{Schema: "public", Table: "users", Column: "deleted"},
+		{Schema: "public", Table: "accounts", Column: "name"},
+	}, current)
+	assert.Equal(t, []string{"email", "username"}, res[1])
+}
+
+func Test_hasNewDriftAnonymizedColumns(t *testing.T) {
+	md := &storageDto.Metadata{
+		DriftAnonymizedColumns: []*storageDto.ColumnReference{
+			{Schema: "public", Table: "users", Column: "email"},
+		},
+	}
+	assert.False(t, hasNewDriftAnonymizedColumns([]*storageDto.ColumnReference{
+		{Schema: "public", Table: "users", Column: "email"},
+	}, md))
+	assert.True(t, hasNewDriftAnonymizedColumns([]*storageDto.ColumnReference{
+		{Schema: "public", Table: "users", Column: "email"},
+		{Schema: "public", Table: "users", Column: "phone"},
+	}, md))
+}
@@ -0,0 +1,158 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/greenmaskio/greenmask/internal/storages"
+	tarStorage "github.com/greenmaskio/greenmask/internal/storages/tar"
+)
+
+const (
+	// DumpOutputFormatDirectory - the dump is stored as the directory of the objects (default)
+	DumpOutputFormatDirectory = "directory"
+	// DumpOutputFormatTar - the dump is bundled into the single tar object <dumpId>.tar
+	DumpOutputFormatTar = "tar"
+	// DumpOutputFormatCustom - the dump is written as the pg_dump custom format archive <dumpId>.dump
+	DumpOutputFormatCustom = "custom"
+)
+
+var ErrDumpNotFound = errors.New("dump is not found")
+
+// ValidateDumpOutputFormat - checks the dump output format is supported. The empty value is the directory format
+func ValidateDumpOutputFormat(format string) error {
+	switch format {
+	case "", DumpOutputFormatDirectory, DumpOutputFormatTar, DumpOutputFormatCustom:
+		return nil
+	}
+	return fmt.Errorf("unknown dump output format \"%s\": expected %s, %s or %s",
+		format, DumpOutputFormatDirectory, DumpOutputFormatTar, DumpOutputFormatCustom)
+}
+
+// NewTarDumpStorage - creates the storage that streams the dump into the tar object <dumpId>.tar of the dumps storage
+// while the dump runs. Each data file is spooled into the tmpDir and appended to the archive once it is complete,
+// so the local disk holds only the files that are being written. The toc, the metadata, the heartbeat and the
+// progress manifest are rewritten during the dump, so they are appended to the end of the archive on close
+func NewTarDumpStorage(ctx context.Context, dumpsSt storages.Storager, tmpDir, dumpId string) (*tarStorage.Writer, error) {
+	st, err := tarStorage.NewWriter(
+		ctx, dumpsSt, dumpId+tarStorage.Extension, filepath.Join(tmpDir, "dump_"+dumpId),
+		tocFileName, MetadataJsonFileName, HeartBeatFileName, ProgressJsonFileName,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create tar dump storage: %w", err)
+	}
+	return st, nil
+}
+
+// GetDumpStorage - returns the storage of the dump with the id. The dump is either the directory of the objects or
+// the tar object that bundles them
+func GetDumpStorage(ctx context.Context, dumpsSt storages.Storager, dumpId string) (storages.Storager, error) {
+	exists, err := dumpsSt.Exists(ctx, path.Join(dumpId, MetadataJsonFileName))
+	if err != nil {
+		return nil, fmt.Errorf("cannot check dump existence: %w", err)
+	}
+	if exists {
+		return dumpsSt.SubStorage(dumpId, true), nil
+	}
+	exists, err = dumpsSt.Exists(ctx, dumpId+tarStorage.Extension)
+	if err != nil {
+		return nil, fmt.Errorf("cannot check tar dump existence: %w", err)
+	}
+	if exists {
+		return tarStorage.NewStorage(dumpsSt, dumpId+tarStorage.Extension), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrDumpNotFound, dumpId)
+}
+
+// ListDumpStorages - returns the storages of all dumps in the dumps storage including the failed ones. The
+// directory dumps are followed by the tar dumps
+func ListDumpStorages(ctx context.Context, dumpsSt storages.Storager) ([]storages.Storager, error) {
+	files, dirs, err := dumpsSt.ListDir(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list dumps: %w", err)
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f, tarStorage.Extension) {
+			dirs = append(dirs, tarStorage.NewStorage(dumpsSt, f))
+		}
+	}
+	return dirs, nil
+}
+
+// IsTarDumpStorage - checks the dump storage is the tar object
+func IsTarDumpStorage(st storages.Storager) bool {
+	_, ok := st.(*tarStorage.Storage)
+	return ok
+}
+
+// referencedDumpStorages - resolves and caches the storages of the dumps that store the referenced objects. The
+// headers of the tar dump are cached by its storage, so the storage is created once per dump
+type referencedDumpStorages struct {
+	dumpsSt  storages.Storager
+	mx       sync.Mutex
+	storages map[string]storages.Storager
+}
+
+func newReferencedDumpStorages(dumpsSt storages.Storager) *referencedDumpStorages {
+	return &referencedDumpStorages{
+		dumpsSt:  dumpsSt,
+		storages: make(map[string]storages.Storager),
+	}
+}
+
+// get - returns the storage of the dump that stores the referenced objects. The directory of the dump is returned if
+// the tar object does not exist
+func (rs *referencedDumpStorages) get(ctx context.Context, dumpId string) (storages.Storager, error) {
+	rs.mx.Lock()
+	defer rs.mx.Unlock()
+	if st, ok := rs.storages[dumpId]; ok {
+		return st, nil
+	}
+	exists, err := rs.dumpsSt.Exists(ctx, dumpId+tarStorage.Extension)
+	if err != nil {
+		return nil, fmt.Errorf("cannot check tar dump existence: %w", err)
+	}
+	st := rs.dumpsSt.SubStorage(dumpId, true)
+	if exists {
+		st = tarStorage.NewStorage(rs.dumpsSt, dumpId+tarStorage.Extension)
+	}
+	rs.storages[dumpId] = st
+	return st, nil
+}
+
+// getTarDumpIds - returns the ids of the tar dumps that have the metadata
+func getTarDumpIds(ctx context.Context, files []string, st storages.Storager) ([]string, error) {
+	var res []string
+	for _, f := range files {
+		if !strings.HasSuffix(f, tarStorage.Extension) {
+			continue
+		}
+		exists, err := tarStorage.NewStorage(st, f).Exists(ctx, MetadataJsonFileName)
+		if err != nil {
+			return nil, fmt.Errorf("cannot check tar dump %s metadata existence: %w", f, err)
+		}
+		if exists {
+			res = append(res, strings.TrimSuffix(f, tarStorage.Extension))
+		}
+	}
+	return res, nil
+}
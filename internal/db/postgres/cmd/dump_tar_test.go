@@ -0,0 +1,97 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/storages"
+	tarStorage "github.com/greenmaskio/greenmask/internal/storages/tar"
+)
+
+func TestValidateDumpOutputFormat(t *testing.T) {
+	require.NoError(t, ValidateDumpOutputFormat(""))
+	require.NoError(t, ValidateDumpOutputFormat(DumpOutputFormatTar))
+	require.NoError(t, ValidateDumpOutputFormat(DumpOutputFormatCustom))
+	require.Error(t, ValidateDumpOutputFormat("zip"))
+}
+
+// streamTarTestDump - replaces the directory dump with the tar dump streamed by the tar dump storage. The files are
+// written in the order they are listed
+func streamTarTestDump(t *testing.T, st storages.Storager, dumpId string) {
+	ctx := context.Background()
+	dirSt := st.SubStorage(dumpId, true)
+	files, _, err := dirSt.ListDir(ctx)
+	require.NoError(t, err)
+	data := make(map[string][]byte, len(files))
+	for _, f := range files {
+		r, err := dirSt.GetObject(ctx, f)
+		require.NoError(t, err)
+		data[f], err = io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+	}
+	require.NoError(t, st.DeleteAll(ctx, dumpId))
+
+	tmpDir := t.TempDir()
+	tarSt, err := NewTarDumpStorage(ctx, st, tmpDir, dumpId)
+	require.NoError(t, err)
+	for _, f := range files {
+		require.NoError(t, tarSt.PutObject(ctx, f, bytes.NewReader(data[f])))
+	}
+	require.NoError(t, tarSt.Close())
+	// The spool directory is removed
+	spooled, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	require.Empty(t, spooled)
+}
+
+func TestNewTarDumpStorage(t *testing.T) {
+	ctx := context.Background()
+	st := newTransformTestDumps(t)
+	sqlBuf := new(bytes.Buffer)
+	require.NoError(t, NewConvert(st, "2", &ConvertOptions{Format: ConvertFormatSql}).Run(ctx, sqlBuf))
+
+	streamTarTestDump(t, st, "2")
+	exists, err := st.Exists(ctx, "2"+tarStorage.Extension)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	dumpId, err := GetLatestDumpId(ctx, st)
+	require.NoError(t, err)
+	assert.Equal(t, "2", dumpId)
+
+	dumpSt, err := GetDumpStorage(ctx, st, "2")
+	require.NoError(t, err)
+	assert.IsType(t, &tarStorage.Storage{}, dumpSt)
+	md, err := getDumpMetadata(ctx, st, "2")
+	require.NoError(t, err)
+	assert.Equal(t, "1", md.IncrementalFrom)
+
+	// The dump is read from the tar object in the same way as from the directory
+	tarBuf := new(bytes.Buffer)
+	require.NoError(t, NewConvert(st, latestDumpId, &ConvertOptions{Format: ConvertFormatSql}).Run(ctx, tarBuf))
+	assert.Equal(t, sqlBuf.String(), tarBuf.String())
+
+	_, err = GetDumpStorage(ctx, st, "3")
+	require.ErrorIs(t, err, ErrDumpNotFound)
+}
@@ -0,0 +1,251 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/entries"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/export"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/storages/directory"
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+// Export - dumps the database and writes the transformed table data into the storage in the export format instead of
+// the pg_dump archive. The manifest that describes the exported files and the tables schema is written at the end.
+// The rest of the dump objects are written into the temporary storage and deleted
+type Export struct {
+	*Dump
+	exportSt storages.Storager
+	format   *export.Format
+	// exportCompression - the compression settings of the exported files. The table data of the dump itself is not
+	// compressed
+	exportCompression ioutils.CompressionSettings
+	// tables - map of the table Oid to the exported table
+	tables map[toolkit.Oid]*export.Table
+	mx     *sync.Mutex
+}
+
+// NewExport - creates the export command that writes the exported files into st
+func NewExport(cfg *domains.Config, st storages.Storager, registry *utils.TransformerRegistry) *Export {
+	return &Export{
+		Dump:     NewDump(cfg, nil, registry),
+		exportSt: st,
+		tables:   make(map[toolkit.Oid]*export.Table),
+		mx:       &sync.Mutex{},
+	}
+}
+
+func (e *Export) Run(ctx context.Context) error {
+	startedAt := time.Now()
+	format, err := export.GetFormat(e.config.Export.Format)
+	if err != nil {
+		return err
+	}
+	e.format = format
+	if err = ioutils.ValidateCompression(e.compression.Codec, e.compression.Level); err != nil {
+		return err
+	}
+	if err = format.ValidateCodec(e.compression.Codec); err != nil {
+		return err
+	}
+	e.exportCompression = *e.compression
+	e.exportCompression.Codec = ioutils.GetCodec(e.compression.Codec)
+	// The table data is encoded into the export files, so there is no reason to compress it
+	e.compression.Codec = ioutils.CodecNone
+	e.compression.Level = ioutils.DefaultCompressionLevel
+
+	tmpDir := path.Join(e.config.Common.TempDirectory, fmt.Sprintf("export_%d", time.Now().UnixNano()))
+	if err = os.MkdirAll(tmpDir, 0700); err != nil {
+		return fmt.Errorf("error creating temp dir: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			log.Warn().Err(err).Msg("error deleting temp dir")
+		}
+	}()
+	tmpSt, err := directory.NewStorage(&directory.Config{Path: tmpDir})
+	if err != nil {
+		return fmt.Errorf("error creating temp storage: %w", err)
+	}
+	e.st = &exportStorage{Storager: tmpSt, export: e}
+
+	if err = e.Dump.Run(ctx); err != nil {
+		return fmt.Errorf("dump error: %w", err)
+	}
+
+	if err = e.writeManifest(ctx, startedAt); err != nil {
+		return fmt.Errorf("cannot write manifest: %w", err)
+	}
+	return nil
+}
+
+// exportTable - encodes the table data file that is being dumped and writes it into the export storage
+func (e *Export) exportTable(ctx context.Context, t *entries.Table, fileName string, body io.Reader) error {
+	columns := export.NewColumns(t.Table)
+	if len(columns) == 0 {
+		log.Debug().
+			Str("TableSchema", t.Schema).
+			Str("TableName", t.Name).
+			Msg("table does not have columns: skipping export")
+		_, err := io.Copy(io.Discard, body)
+		return err
+	}
+
+	cs := &e.exportCompression
+	if e.format.Compressed {
+		cs = &ioutils.CompressionSettings{Codec: ioutils.CodecNone}
+	}
+	w, pr, err := ioutils.NewCompressionPipe(cs)
+	if err != nil {
+		return fmt.Errorf("cannot create compression pipe: %w", err)
+	}
+	exportFileName := e.getExportFileName(t, fileName)
+
+	var rows int64
+	eg, gtx := errgroup.WithContext(ctx)
+	eg.Go(func() error {
+		defer func() {
+			if err := pr.Close(); err != nil {
+				log.Warn().Err(err).Msg("error closing export reader")
+			}
+		}()
+		if err := e.exportSt.PutObject(gtx, exportFileName, pr); err != nil {
+			return fmt.Errorf("cannot write object: %w", err)
+		}
+		return nil
+	})
+	eg.Go(func() error {
+		defer func() {
+			if err := w.Close(); err != nil {
+				log.Warn().Err(err).Msg("error closing export writer")
+			}
+		}()
+		enc, err := e.format.NewEncoder(w, columns, &e.exportCompression)
+		if err != nil {
+			return fmt.Errorf("cannot create %s encoder: %w", e.format.Name, err)
+		}
+		if rows, err = export.EncodeCopyData(body, enc, len(columns)); err != nil {
+			return fmt.Errorf("error exporting table %s.%s: %w", t.Schema, t.Name, err)
+		}
+		return enc.Close()
+	})
+	if err = eg.Wait(); err != nil {
+		return err
+	}
+
+	log.Debug().
+		Str("TableSchema", t.Schema).
+		Str("TableName", t.Name).
+		Str("FileName", exportFileName).
+		Int64("Rows", rows).
+		Msg("table exported")
+	e.addExportedFile(t, columns, &export.File{
+		FileName: exportFileName,
+		Rows:     rows,
+		Size:     pr.GetCount(),
+		Checksum: pr.GetChecksum(),
+	})
+	return nil
+}
+
+// getExportFileName - returns the name of the exported file. The chunks of the table are exported into the separate
+// files with the chunk number suffix
+func (e *Export) getExportFileName(t *entries.Table, fileName string) string {
+	name := fmt.Sprintf("%s.%s", t.Schema, t.Name)
+	if t.IsChunked() {
+		idx := slices.IndexFunc(t.Chunks, func(c *entries.TableChunk) bool {
+			return c.FileName == fileName
+		})
+		name = fmt.Sprintf("%s.%d", name, t.Chunks[idx].Part)
+	}
+	return name + e.format.FileExtension(e.exportCompression.Codec)
+}
+
+func (e *Export) addExportedFile(t *entries.Table, columns []*export.Column, f *export.File) {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+	et, ok := e.tables[t.Oid]
+	if !ok {
+		et = &export.Table{
+			Schema:  t.Schema,
+			Name:    t.Name,
+			Columns: columns,
+		}
+		e.tables[t.Oid] = et
+	}
+	et.Rows += f.Rows
+	et.Files = append(et.Files, f)
+}
+
+func (e *Export) writeManifest(ctx context.Context, startedAt time.Time) error {
+	m := &export.Manifest{
+		Format:      e.format.Name,
+		Codec:       e.exportCompression.Codec,
+		StartedAt:   startedAt,
+		CompletedAt: time.Now(),
+		Tables:      make([]*export.Table, 0, len(e.tables)),
+	}
+	for _, t := range e.tables {
+		slices.SortFunc(t.Files, func(a, b *export.File) int {
+			return strings.Compare(a.FileName, b.FileName)
+		})
+		m.Tables = append(m.Tables, t)
+	}
+	slices.SortFunc(m.Tables, func(a, b *export.Table) int {
+		if res := strings.Compare(a.Schema, b.Schema); res != 0 {
+			return res
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal manifest: %w", err)
+	}
+	return e.exportSt.PutObject(ctx, export.ManifestFileName, bytes.NewReader(data))
+}
+
+// exportStorage - the storage decorator that exports the table data files. The other objects are written into the
+// underlying temporary storage
+type exportStorage struct {
+	storages.Storager
+	export *Export
+}
+
+func (es *exportStorage) PutObject(ctx context.Context, filePath string, body io.Reader) error {
+	t, ok := es.export.findTableByFileName(filePath)
+	if !ok {
+		return es.Storager.PutObject(ctx, filePath, body)
+	}
+	return es.export.exportTable(ctx, t, filePath, body)
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/entries"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/export"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/storages/directory"
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+func TestExport_exportTable(t *testing.T) {
+	ctx := context.Background()
+	st, err := directory.NewStorage(&directory.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+	format, err := export.GetFormat(export.FormatCsv)
+	require.NoError(t, err)
+
+	e := NewExport(&domains.Config{}, st, nil)
+	e.format = format
+	e.exportCompression = ioutils.CompressionSettings{Codec: ioutils.CodecNone}
+
+	table := &entries.Table{
+		Table: &toolkit.Table{
+			Schema: "public",
+			Name:   "users",
+			Oid:    100,
+			Columns: []*toolkit.Column{
+				{Idx: 0, Name: "id", TypeName: "int4", TypeOid: toolkit.Oid(pgtype.Int4OID), Num: 1, NotNull: true},
+				{Idx: 1, Name: "email", TypeName: "text", TypeOid: toolkit.Oid(pgtype.TextOID), Num: 2},
+			},
+		},
+		Chunks: []*entries.TableChunk{
+			{Part: 0, FileName: "10.0.dat"},
+			{Part: 1, FileName: "10.1.dat"},
+		},
+	}
+	require.NoError(t, e.exportTable(ctx, table, "10.1.dat", strings.NewReader("2\t\\N\n\\.\n\n")))
+	require.NoError(t, e.exportTable(ctx, table, "10.0.dat", strings.NewReader("1\ta@example.com\n\\.\n\n")))
+	require.NoError(t, e.writeManifest(ctx, time.Now()))
+
+	r, err := st.GetObject(ctx, "public.users.1.csv")
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, "id,email\n2,\n", string(data))
+
+	r, err = st.GetObject(ctx, export.ManifestFileName)
+	require.NoError(t, err)
+	m := &export.Manifest{}
+	require.NoError(t, json.NewDecoder(r).Decode(m))
+	require.NoError(t, r.Close())
+	assert.Equal(t, export.FormatCsv, m.Format)
+	require.Len(t, m.Tables, 1)
+	assert.Equal(t, int64(2), m.Tables[0].Rows)
+	assert.Equal(t, []*export.Column{
+		{Name: "id", PgType: "int4", Type: export.TypeInt32},
+		{Name: "email", PgType: "text", Type: export.TypeString, Nullable: true},
+	}, m.Tables[0].Columns)
+	require.Len(t, m.Tables[0].Files, 2)
+	assert.Equal(t, "public.users.0.csv", m.Tables[0].Files[0].FileName)
+	assert.Equal(t, &export.File{
+		FileName: "public.users.1.csv",
+		Rows:     1,
+		Size:     int64(len(data)),
+		Checksum: ioutils.ChecksumBytes(data),
+	}, m.Tables[0].Files[1])
+}
@@ -40,6 +40,7 @@ import (
 	"github.com/greenmaskio/greenmask/internal/db/postgres/restorers"
 	"github.com/greenmaskio/greenmask/internal/db/postgres/storage"
 	"github.com/greenmaskio/greenmask/internal/db/postgres/toc"
+	transformersUtils "github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
 	"github.com/greenmaskio/greenmask/internal/db/postgres/utils"
 	"github.com/greenmaskio/greenmask/internal/domains"
 	"github.com/greenmaskio/greenmask/internal/storages"
@@ -74,6 +75,7 @@ const dependenciesCheckInterval = 15 * time.Millisecond
 var (
 	ErrTableDefinitionIsEmpty   = errors.New("table definition is empty: please re-dump the data using the latest version of greenmask if you want to use --inserts")
 	ErrDatabaseNameIsEmptyInTOC = errors.New("database name is empty in TOC: cannot use --create option because of missing database name in TOC")
+	ErrDumpsStorageIsNotSet     = errors.New("dumps storage is not set: cannot resolve references of incremental dump")
 )
 
 type restorationTask interface {
@@ -99,7 +101,18 @@ type Restore struct {
 	preDataClenUpToc  string
 	postDataClenUpToc string
 	restoredDumpIds   map[int32]bool
+	// chunksLeft - map of the chunked table DumpId to the count of its chunks that are not restored yet
+	chunksLeft        map[int32]int
 	maintenanceDbName string
+	// dumpsSt - storage that contains all the dumps. It is used for resolving references of incremental dump
+	dumpsSt storages.Storager
+	// refStorages - the cached storages of the dumps that store the referenced objects
+	refStorages *referencedDumpStorages
+	// tableDataRestored - the table data has already been streamed into the target database by the sync command. The
+	// data section restoration restores the rest of the data entries (sequences, large objects, etc.) only
+	tableDataRestored bool
+	// registry - the transformers registry that is used for the restore transformation
+	registry *transformersUtils.TransformerRegistry
 }
 
 func NewRestore(
@@ -115,17 +128,37 @@ func NewRestore(
 		cfg:             cfg,
 		metadata:        &storage.Metadata{},
 		restoredDumpIds: make(map[int32]bool),
+		chunksLeft:      make(map[int32]int),
 		mx:              &sync.RWMutex{},
 	}
 }
 
+// SetDumpsStorage - sets the storage that contains all the dumps. It is required for restoring incremental dumps
+// that reference the objects of the previous dumps
+func (r *Restore) SetDumpsStorage(st storages.Storager) {
+	r.dumpsSt = st
+	r.refStorages = newReferencedDumpStorages(st)
+}
+
 func (r *Restore) Run(ctx context.Context) error {
 	defer r.prune()
 
+	if err := r.validateMerge(); err != nil {
+		return fmt.Errorf("merge settings validation error: %w", err)
+	}
+
 	if err := r.readMetadata(ctx); err != nil {
 		return fmt.Errorf("cannot read metadata: %w", err)
 	}
 
+	if err := r.validateTransformation(ctx, r.metadata.DatabaseSchema); err != nil {
+		return fmt.Errorf("restore transformation validation error: %w", err)
+	}
+
+	if err := r.setupChecksumsVerification(ctx); err != nil {
+		return fmt.Errorf("checksums verification error: %w", err)
+	}
+
 	if err := r.prepare(ctx); err != nil {
 		return fmt.Errorf("preparation error: %w", err)
 	}
@@ -151,8 +184,17 @@ func (r *Restore) Run(ctx context.Context) error {
 
 func (r *Restore) putDumpId(task restorationTask) {
 	r.mx.Lock()
-	r.restoredDumpIds[task.GetEntry().DumpId] = true
-	r.mx.Unlock()
+	defer r.mx.Unlock()
+	dumpId := task.GetEntry().DumpId
+	if left, ok := r.chunksLeft[dumpId]; ok {
+		// The chunked table is restored when all its chunks are restored
+		left--
+		r.chunksLeft[dumpId] = left
+		if left > 0 {
+			return
+		}
+	}
+	r.restoredDumpIds[dumpId] = true
 }
 
 func (r *Restore) dependenciesAreRestored(deps []int32) bool {
@@ -224,8 +266,11 @@ func (r *Restore) prepare(ctx context.Context) error {
 		return fmt.Errorf("error creating temp dir: %w", err)
 	}
 
-	if err := r.readTocDatFile(ctx); err != nil {
-		return fmt.Errorf("read toc header: %w", err)
+	if r.tocObj == nil {
+		// The sync command provides the schema TOC before the dump is written into the storage
+		if err := r.readTocDatFile(ctx); err != nil {
+			return fmt.Errorf("read toc header: %w", err)
+		}
 	}
 
 	if r.restoreOpt.UseList != "" {
@@ -553,8 +598,10 @@ func (r *Restore) dataRestore(ctx context.Context) error {
 		}
 	}()
 
-	if err = r.RunScripts(ctx, conn, scriptDataSection, scriptExecuteBefore); err != nil {
-		return err
+	if !r.tableDataRestored {
+		if err = r.RunScripts(ctx, conn, scriptDataSection, scriptExecuteBefore); err != nil {
+			return err
+		}
 	}
 
 	tasks := make(chan restorationTask, r.restoreOpt.Jobs)
@@ -770,6 +817,10 @@ func (r *Restore) taskPusher(ctx context.Context, tasks chan restorationTask) fu
 				continue
 			}
 
+			if r.tableDataRestored && entry.Desc != nil && *entry.Desc == toc.TableDataDesc {
+				continue
+			}
+
 			if r.restoreOpt.RestoreInOrder && r.restoreOpt.Jobs > 1 {
 				deps := r.metadata.DependenciesGraph[entry.DumpId]
 				if err := r.waitDependenciesAreRestore(ctx, deps); err != nil {
@@ -783,17 +834,20 @@ func (r *Restore) taskPusher(ctx context.Context, tasks chan restorationTask) fu
 			}
 			switch *entry.Desc {
 			case toc.TableDataDesc:
-				if r.restoreOpt.Inserts || r.restoreOpt.OnConflictDoNothing {
-					t, err := r.getTableDefinitionFromMeta(entry.DumpId)
+				dataEntries, st, err := r.resolveTableData(ctx, entry)
+				if err != nil {
+					return fmt.Errorf("cannot resolve table data: %w", err)
+				}
+				for _, dataEntry := range dataEntries {
+					task, err = r.newTableRestorer(ctx, dataEntry, st)
 					if err != nil {
-						return fmt.Errorf("cannot get table definition from meta: %w", err)
+						return err
+					}
+					if err = pushRestorationTask(ctx, tasks, task); err != nil {
+						return err
 					}
-					task = restorers.NewTableRestorerInsertFormat(
-						entry, t, r.st, r.restoreOpt.ToDataSectionSettings(), r.cfg.ErrorExclusions,
-					)
-				} else {
-					task = restorers.NewTableRestorer(entry, r.st, r.restoreOpt.ToDataSectionSettings())
 				}
+				continue
 
 			case toc.SequenceSetDesc:
 				task = restorers.NewSequenceRestorer(entry)
@@ -805,7 +859,9 @@ func (r *Restore) taskPusher(ctx context.Context, tasks chan restorationTask) fu
 						Msg("blobs restoration is skipped")
 					continue
 				}
-				task = restorers.NewBlobsRestorer(entry, r.st, r.restoreOpt.Pgzip)
+				task = restorers.NewBlobsRestorer(
+					entry, r.st, r.metadata.Header.Codec, r.restoreOpt.Pgzip,
+				)
 			case toc.AclDesc:
 				// Skip ACL restoration if --no-privileges is set
 				if r.restoreOpt.NoPrivileges {
@@ -818,10 +874,8 @@ func (r *Restore) taskPusher(ctx context.Context, tasks chan restorationTask) fu
 			}
 
 			if task != nil {
-				select {
-				case <-ctx.Done():
-					return ctx.Err()
-				case tasks <- task:
+				if err := pushRestorationTask(ctx, tasks, task); err != nil {
+					return err
 				}
 			}
 
@@ -830,6 +884,150 @@ func (r *Restore) taskPusher(ctx context.Context, tasks chan restorationTask) fu
 	}
 }
 
+// newTableRestorer - creates the table data restorer according to the restoration options
+func (r *Restore) newTableRestorer(
+	ctx context.Context, entry *toc.Entry, st storages.Storager,
+) (restorationTask, error) {
+	var t *toolkit.Table
+	if r.restoreOpt.Inserts || r.restoreOpt.OnConflictDoNothing || r.findTableMerge(entry) != nil ||
+		r.findTableTransformation(*entry.Namespace, *entry.Tag) != nil {
+		var err error
+		t, err = r.getTableDefinitionFromMeta(entry.DumpId)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get table definition from meta: %w", err)
+		}
+	}
+	return r.newTableDataRestorer(ctx, entry, t, st)
+}
+
+// newTableDataRestorer - creates the table data restorer using the table definition. The definition is required for
+// the insert format, the merge restoration and the restore transformation
+func (r *Restore) newTableDataRestorer(
+	ctx context.Context, entry *toc.Entry, t *toolkit.Table, st storages.Storager,
+) (restorationTask, error) {
+	task, err := r.newTableDataRestorerTask(entry, t, st)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.setTableTransformation(ctx, task, t); err != nil {
+		return nil, fmt.Errorf("cannot set transformation of %s: %w", task.DebugInfo(), err)
+	}
+	return task, nil
+}
+
+func (r *Restore) newTableDataRestorerTask(
+	entry *toc.Entry, t *toolkit.Table, st storages.Storager,
+) (restorationTask, error) {
+	merge := r.findTableMerge(entry)
+	if r.restoreOpt.Inserts || r.restoreOpt.OnConflictDoNothing {
+		task := restorers.NewTableRestorerInsertFormat(
+			entry, t, st, r.restoreOpt.ToDataSectionSettings(), r.cfg.ErrorExclusions,
+		)
+		if merge != nil {
+			if err := task.SetMerge(merge); err != nil {
+				return nil, fmt.Errorf("cannot set merge restoration: %w", err)
+			}
+		}
+		return task, nil
+	}
+	if merge != nil {
+		task, err := restorers.NewTableRestorerMerge(entry, t, st, r.restoreOpt.ToDataSectionSettings(), merge)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create merge restorer: %w", err)
+		}
+		return task, nil
+	}
+	return restorers.NewTableRestorer(entry, st, r.restoreOpt.ToDataSectionSettings()), nil
+}
+
+// findTableMerge - returns the merge restoration settings of the table entry. The table without schema in the
+// settings matches the table in any schema
+func (r *Restore) findTableMerge(entry *toc.Entry) *domains.TableMerge {
+	if r.cfg == nil || entry.Namespace == nil || entry.Tag == nil {
+		return nil
+	}
+	for _, m := range r.cfg.Merge {
+		schema := m.Schema
+		if schema == "" {
+			schema = *entry.Namespace
+		}
+		if domains.IsTableMatch(schema, m.Name, *entry.Namespace, *entry.Tag) {
+			return m
+		}
+	}
+	return nil
+}
+
+// validateMerge - checks the merge restoration settings
+func (r *Restore) validateMerge() error {
+	if r.cfg == nil {
+		return nil
+	}
+	for _, m := range r.cfg.Merge {
+		if err := restorers.ValidateTableMerge(m); err != nil {
+			return fmt.Errorf("table %s.%s: %w", m.Schema, m.Name, err)
+		}
+	}
+	return nil
+}
+
+func pushRestorationTask(ctx context.Context, tasks chan restorationTask, task restorationTask) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case tasks <- task:
+	}
+	return nil
+}
+
+// resolveTableData - returns the entries and the storage that contain the table data. If the table data is stored in
+// another dump then the entry copy with the referenced file name and the storage of that dump are returned. If the
+// table data is split into chunks then the entry copy is returned for each chunk
+func (r *Restore) resolveTableData(ctx context.Context, entry *toc.Entry) ([]*toc.Entry, storages.Storager, error) {
+	dataEntry, st := entry, r.st
+	if ref, ok := r.metadata.References[entry.DumpId]; ok {
+		if r.dumpsSt == nil {
+			return nil, nil, ErrDumpsStorageIsNotSet
+		}
+		log.Debug().
+			Int32("DumpId", entry.DumpId).
+			Str("ReferencedDumpId", ref.DumpId).
+			Str("FileName", ref.FileName).
+			Msg("table data is stored in another dump")
+		refEntry := *entry
+		refEntry.FileName = &ref.FileName
+		dataEntry = &refEntry
+		var err error
+		if st, err = r.refStorages.get(ctx, ref.DumpId); err != nil {
+			return nil, nil, fmt.Errorf("cannot open referenced dump: %w", err)
+		}
+		if r.isVerifyChecksumsDuring() {
+			if e, ok := r.metadata.GetEntry(entry.DumpId); ok && len(e.Checksums) > 0 {
+				st = newChecksumStorage(st, e.Checksums)
+			}
+		}
+	}
+
+	e, ok := r.metadata.GetEntry(entry.DumpId)
+	if !ok || len(e.Chunks) == 0 {
+		return []*toc.Entry{dataEntry}, st, nil
+	}
+	chunks := e.Chunks
+	if ref, ok := r.metadata.References[entry.DumpId]; ok {
+		chunks = ref.GetChunks(e)
+	}
+	res := make([]*toc.Entry, 0, len(chunks))
+	for _, fileName := range chunks {
+		chunkEntry := *entry
+		chunkEntry.FileName = &fileName
+		res = append(res, &chunkEntry)
+	}
+	r.mx.Lock()
+	r.chunksLeft[entry.DumpId] = len(res)
+	r.mx.Unlock()
+	return res, st, nil
+}
+
 func (r *Restore) getTableDefinitionFromMeta(dumpId int32) (*toolkit.Table, error) {
 	tableOid, ok := r.metadata.DumpIdsToTableOid[dumpId]
 	if !ok {
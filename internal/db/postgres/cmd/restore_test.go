@@ -15,13 +15,19 @@
 package cmd
 
 import (
+	"context"
 	"testing"
 
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/greenmaskio/greenmask/internal/db/postgres/restorers"
 	"github.com/greenmaskio/greenmask/internal/db/postgres/storage"
 	"github.com/greenmaskio/greenmask/internal/db/postgres/toc"
+	transformersUtils "github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/utils/testutils"
 	"github.com/greenmaskio/greenmask/pkg/toolkit"
 )
 
@@ -269,3 +275,79 @@ func TestRestore_sortTocEntriesInTopoOrder_ACLs_WithoutTocObj(t *testing.T) {
 func strPtr(s string) *string {
 	return &s
 }
+
+func TestRestore_resolveTableData_Chunks(t *testing.T) {
+	st := &testutils.StorageMock{}
+	r := NewRestore("", st, &domains.Restore{}, nil, "")
+	r.metadata = &storage.Metadata{
+		Entries: []*storage.Entry{
+			{DumpId: 105, FileName: "105.dat.gz", Chunks: []string{"105.0.dat.gz", "105.1.dat.gz"}},
+		},
+	}
+	entry := &toc.Entry{DumpId: 105, Desc: strPtr(toc.TableDataDesc), FileName: strPtr("105.dat.gz")}
+
+	dataEntries, dataSt, err := r.resolveTableData(context.Background(), entry)
+	require.NoError(t, err)
+	assert.Equal(t, st, dataSt)
+	require.Len(t, dataEntries, 2)
+	assert.Equal(t, "105.0.dat.gz", *dataEntries[0].FileName)
+	assert.Equal(t, "105.1.dat.gz", *dataEntries[1].FileName)
+	assert.Equal(t, "105.dat.gz", *entry.FileName)
+
+	// The table is restored only after the last chunk
+	r.putDumpId(restorers.NewTableRestorer(dataEntries[0], st, nil))
+	assert.False(t, r.dependenciesAreRestored([]int32{105}))
+	r.putDumpId(restorers.NewTableRestorer(dataEntries[1], st, nil))
+	assert.True(t, r.dependenciesAreRestored([]int32{105}))
+}
+
+func TestRestore_findTableMerge(t *testing.T) {
+	users := &domains.TableMerge{Schema: "public", Name: "users"}
+	orders := &domains.TableMerge{Name: "orders", Method: restorers.MergeMethodMerge}
+	r := NewRestore("", nil, &domains.Restore{Merge: []*domains.TableMerge{users, orders}}, nil, "")
+
+	assert.Equal(t, users, r.findTableMerge(&toc.Entry{Namespace: strPtr(`"public"`), Tag: strPtr(`"users"`)}))
+	assert.Nil(t, r.findTableMerge(&toc.Entry{Namespace: strPtr(`"crm"`), Tag: strPtr(`"users"`)}))
+	assert.Equal(t, orders, r.findTableMerge(&toc.Entry{Namespace: strPtr(`"crm"`), Tag: strPtr(`"orders"`)}))
+	require.NoError(t, r.validateMerge())
+
+	r.cfg.Merge = append(r.cfg.Merge, &domains.TableMerge{Name: "items", Method: "upsert"})
+	require.ErrorContains(t, r.validateMerge(), "unknown merge method")
+}
+
+func TestRestore_validateTransformation(t *testing.T) {
+	schema := toolkit.DatabaseSchema{
+		{
+			Schema: "public",
+			Name:   "users",
+			Oid:    16384,
+			Columns: []*toolkit.Column{
+				{Idx: 0, Name: "id", TypeName: "int4", TypeOid: toolkit.Oid(pgtype.Int4OID), Num: 1},
+				{Idx: 1, Name: "email", TypeName: "text", TypeOid: toolkit.Oid(pgtype.TextOID), Num: 2},
+			},
+		},
+	}
+	cfg := &domains.Table{
+		Schema: "public",
+		Name:   `"users"`,
+		Transformers: []*domains.TransformerConfig{
+			{
+				Name: "Replace",
+				Params: toolkit.StaticParameters{
+					"column": toolkit.ParamsValue("email"),
+					"value":  toolkit.ParamsValue("masked@example.com"),
+				},
+			},
+		},
+	}
+	r := NewRestore("", nil, &domains.Restore{Transformation: []*domains.Table{cfg}}, nil, "")
+	require.ErrorIs(t, r.validateTransformation(context.Background(), schema), ErrTransformerRegistryIsNotSet)
+
+	r.SetTransformerRegistry(transformersUtils.DefaultTransformerRegistry)
+	require.NoError(t, r.validateTransformation(context.Background(), schema))
+	assert.Equal(t, cfg, r.findTableTransformation(`"public"`, `"users"`))
+	assert.Nil(t, r.findTableTransformation("public", "orders"))
+
+	r.cfg.Transformation = append(r.cfg.Transformation, &domains.Table{Schema: "public", Name: "orders"})
+	require.ErrorContains(t, r.validateTransformation(context.Background(), schema), "not found in the dump")
+}
@@ -0,0 +1,123 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+
+	runtimeContext "github.com/greenmaskio/greenmask/internal/db/postgres/context"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/entries"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+var ErrTransformerRegistryIsNotSet = errors.New("transformer registry is not set: cannot apply restore transformation")
+
+// transformableTask - the restoration task that can transform the table data while it is being restored
+type transformableTask interface {
+	restorationTask
+	SetTransformation(t *entries.Table)
+}
+
+// SetTransformerRegistry - sets the registry of the transformers that are used in the restore transformation
+func (r *Restore) SetTransformerRegistry(registry *utils.TransformerRegistry) {
+	r.registry = registry
+}
+
+// validateTransformation - checks the restore transformation config against the tables definition. The transformers
+// are initialized for each configured table, so the errors are found before the restoration starts
+func (r *Restore) validateTransformation(ctx context.Context, schema toolkit.DatabaseSchema) error {
+	if r.cfg == nil || len(r.cfg.Transformation) == 0 {
+		return nil
+	}
+	if r.registry == nil {
+		return ErrTransformerRegistryIsNotSet
+	}
+	return validateOfflineTransformation(ctx, r.cfg.Transformation, schema, r.registry)
+}
+
+// findTableTransformation - returns the restore transformation config of the table
+func (r *Restore) findTableTransformation(schema, name string) *domains.Table {
+	if r.cfg == nil {
+		return nil
+	}
+	return domains.FindTable(r.cfg.Transformation, schema, name)
+}
+
+// setTableTransformation - initializes the transformers of the table if it is set in the restore transformation
+// config. Each task gets its own transformers because the chunks of the table are restored in parallel
+func (r *Restore) setTableTransformation(ctx context.Context, task restorationTask, t *toolkit.Table) error {
+	if t == nil {
+		return nil
+	}
+	cfg := r.findTableTransformation(t.Schema, t.Name)
+	if cfg == nil {
+		return nil
+	}
+	if r.registry == nil {
+		return ErrTransformerRegistryIsNotSet
+	}
+	tt, ok := task.(transformableTask)
+	if !ok {
+		return fmt.Errorf("restoration task %s does not support transformation", task.DebugInfo())
+	}
+	table, warns, err := runtimeContext.NewOfflineTransformationTable(ctx, t, cfg, r.registry)
+	if err != nil {
+		return fmt.Errorf("cannot initialize transformers: %w", err)
+	}
+	if warns.IsFatal() {
+		return fmt.Errorf("fatal validation error in transformers of table %s.%s", t.Schema, t.Name)
+	}
+	tt.SetTransformation(table)
+	return nil
+}
+
+// validateOfflineTransformation - initializes the transformers of each configured table using the tables definition
+// stored in the dump metadata and logs the validation warnings. It returns an error if the table is not found or
+// the warnings are fatal
+func validateOfflineTransformation(
+	ctx context.Context, cfgs []*domains.Table, schema toolkit.DatabaseSchema, registry *utils.TransformerRegistry,
+) error {
+	var warnings toolkit.ValidationWarnings
+	for _, cfg := range cfgs {
+		idx := slices.IndexFunc(schema, func(t *toolkit.Table) bool {
+			return domains.IsTableMatch(cfg.Schema, cfg.Name, t.Schema, t.Name)
+		})
+		if idx == -1 {
+			return fmt.Errorf("table %s.%s is not found in the dump", cfg.Schema, cfg.Name)
+		}
+		_, warns, err := runtimeContext.NewOfflineTransformationTable(ctx, schema[idx], cfg, registry)
+		if err != nil {
+			return fmt.Errorf("cannot initialize transformers of table %s.%s: %w", cfg.Schema, cfg.Name, err)
+		}
+		warnings = append(warnings, warns...)
+	}
+
+	for _, w := range warnings {
+		if w.Severity == "error" {
+			log.Error().Any("ValidationWarning", w).Msg("")
+		}
+	}
+	if warnings.IsFatal() {
+		return fmt.Errorf("fatal validation error")
+	}
+	return nil
+}
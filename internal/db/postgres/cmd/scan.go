@@ -0,0 +1,281 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/cmd/scan_utils"
+	runtimeContext "github.com/greenmaskio/greenmask/internal/db/postgres/context"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/entries"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/custom"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+const stdoutOutput = "-"
+
+// sampleOrderByRandomFactor - the tables with the rows estimate up to this many times of the rows limit are sampled
+// by sorting all the rows by random()
+const sampleOrderByRandomFactor = 10
+
+// scannableTypes - the types that may contain the detected personal data. The other types are not sampled
+var scannableTypes = map[string]struct{}{
+	"text":    {},
+	"varchar": {},
+	"bpchar":  {},
+	"char":    {},
+	"citext":  {},
+	"name":    {},
+	"inet":    {},
+	"cidr":    {},
+	"int8":    {},
+	"numeric": {},
+}
+
+// Scan - samples the tables data and classifies the columns content using the detectors. It proposes the
+// transformation config for the detected columns and reports the columns that are not transformed by the current
+// config
+type Scan struct {
+	*Dump
+	detectors []*scan_utils.Detector
+	tables    []*scan_utils.TableReport
+}
+
+func NewScan(cfg *domains.Config, registry *utils.TransformerRegistry) *Scan {
+	return &Scan{
+		Dump:      NewDump(cfg, nil, registry),
+		detectors: scan_utils.DefaultDetectors,
+	}
+}
+
+func (s *Scan) Run(ctx context.Context) error {
+	if err := custom.BootstrapCustomTransformers(ctx, s.registry, s.config.CustomTransformers); err != nil {
+		return fmt.Errorf("error bootstraping custom transformers: %w", err)
+	}
+
+	dsn, err := s.pgDumpOptions.GetPgDSN()
+	if err != nil {
+		return fmt.Errorf("cannot build connection string: %w", err)
+	}
+
+	conn, err := s.connect(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := conn.Close(ctx); err != nil {
+			log.Warn().Err(err)
+		}
+	}()
+
+	tx, err := s.startMainTx(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("cannot prepare scan transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			log.Warn().Err(err)
+		}
+	}()
+
+	if err = s.gatherPgFacts(ctx, tx); err != nil {
+		return fmt.Errorf("error gathering facts: %w", err)
+	}
+
+	s.context, err = runtimeContext.NewRuntimeContext(
+		ctx, tx, &s.config.Dump, s.registry,
+		s.config.Dump.VirtualReferences, s.version,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to build runtime context: %w", err)
+	}
+	if err = toolkit.PrintValidationWarnings(s.context.Warnings, nil, false); err != nil {
+		return err
+	}
+	if s.context.IsFatal() {
+		return fmt.Errorf("fatal validation error")
+	}
+
+	if err = s.scanTables(ctx, tx); err != nil {
+		return err
+	}
+
+	if err = s.writeProposal(); err != nil {
+		return err
+	}
+	scan_utils.PrintUntransformedColumns(os.Stderr, s.tables, s.config.Scan.MinConfidence)
+	return nil
+}
+
+func (s *Scan) scanTables(ctx context.Context, tx pgx.Tx) error {
+	for _, obj := range s.context.DataSectionObjects {
+		t, ok := obj.(*entries.Table)
+		if !ok || t.RelKind == 'p' {
+			continue
+		}
+		scan, err := s.isTableScanned(t)
+		if err != nil {
+			return err
+		}
+		if !scan {
+			continue
+		}
+		log.Debug().
+			Str("SchemaName", t.Schema).
+			Str("TableName", t.Name).
+			Msg("scanning table")
+		tr, err := s.scanTable(ctx, tx, t)
+		if err != nil {
+			return fmt.Errorf("cannot scan table %s.%s: %w", t.Schema, t.Name, err)
+		}
+		s.tables = append(s.tables, tr)
+	}
+	return nil
+}
+
+// isTableScanned - checks that the table is requested in the scan.tables. All tables are scanned if it is empty
+func (s *Scan) isTableScanned(t *entries.Table) (bool, error) {
+	if len(s.config.Scan.Tables) == 0 {
+		return true, nil
+	}
+	for _, name := range s.config.Scan.Tables {
+		schemaName, tableName, err := parseTableName(name)
+		if err != nil {
+			return false, err
+		}
+		if tableName == t.Name && (schemaName == "" || schemaName == t.Schema) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// scanTable - samples the table rows and classifies the values of each scannable column
+func (s *Scan) scanTable(ctx context.Context, tx pgx.Tx, t *entries.Table) (*scan_utils.TableReport, error) {
+	affectedColumns := getTableAffectedColumns(t)
+
+	res := &scan_utils.TableReport{
+		Schema: t.Schema,
+		Name:   t.Name,
+	}
+	var sampledColumns []*scan_utils.ColumnReport
+	var selectList []string
+	for _, c := range t.Columns {
+		cr := &scan_utils.ColumnReport{
+			Name:     c.Name,
+			TypeName: c.TypeName,
+		}
+		_, cr.Transformed = affectedColumns[c.Name]
+		res.Columns = append(res.Columns, cr)
+		if !isColumnScannable(c) {
+			continue
+		}
+		sampledColumns = append(sampledColumns, cr)
+		selectList = append(selectList, fmt.Sprintf("%s::TEXT", pgx.Identifier{c.Name}.Sanitize()))
+	}
+	if len(sampledColumns) == 0 {
+		return res, nil
+	}
+
+	query := buildSampleQuery(t, selectList, s.config.Scan.RowsLimit)
+	rows, err := tx.Query(ctx, query, s.config.Scan.RowsLimit)
+	if err != nil {
+		return nil, fmt.Errorf("cannot sample rows: %w", err)
+	}
+	defer rows.Close()
+
+	values := make([][]string, len(sampledColumns))
+	row := make([]*string, len(sampledColumns))
+	dest := make([]any, len(sampledColumns))
+	for idx := range row {
+		dest[idx] = &row[idx]
+	}
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("cannot scan sampled row: %w", err)
+		}
+		for idx, v := range row {
+			if v == nil || *v == "" {
+				continue
+			}
+			values[idx] = append(values[idx], *v)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read sampled rows: %w", err)
+	}
+
+	for idx, cr := range sampledColumns {
+		cr.Sampled = len(values[idx])
+		cr.Detection = scan_utils.Classify(cr.Name, values[idx], s.detectors)
+	}
+	return res, nil
+}
+
+// buildSampleQuery - returns the query that selects up to rowsLimit random rows of the table. The small tables
+// and the tables without the rows estimate are sorted by random(). The other ordinary tables are read using
+// TABLESAMPLE BERNOULLI with the percentage that gives about twice as many rows as requested, so the sample
+// stays random even if the estimate is outdated
+func buildSampleQuery(t *entries.Table, selectList []string, rowsLimit uint64) string {
+	from := pgx.Identifier{t.Schema, t.Name}.Sanitize()
+	columns := strings.Join(selectList, ", ")
+	limit := int64(rowsLimit)
+	if t.RelKind != 'r' || t.RowsEstimate <= 0 || t.RowsEstimate <= limit*sampleOrderByRandomFactor {
+		return fmt.Sprintf("SELECT %s FROM %s ORDER BY random() LIMIT $1", columns, from)
+	}
+	percent := float64(limit*2) * 100 / float64(t.RowsEstimate)
+	return fmt.Sprintf(
+		"SELECT %s FROM %s TABLESAMPLE BERNOULLI (%s) LIMIT $1",
+		columns, from, strconv.FormatFloat(percent, 'f', -1, 64),
+	)
+}
+
+func (s *Scan) writeProposal() error {
+	var w io.Writer = os.Stdout
+	if s.config.Scan.Output != "" && s.config.Scan.Output != stdoutOutput {
+		f, err := os.Create(s.config.Scan.Output)
+		if err != nil {
+			return fmt.Errorf("cannot create output file: %w", err)
+		}
+		defer func() {
+			if err := f.Close(); err != nil {
+				log.Warn().Err(err).Msg("error closing output file")
+			}
+		}()
+		w = f
+	}
+	if err := scan_utils.WriteTransformationYaml(w, s.tables, s.config.Scan.MinConfidence); err != nil {
+		return fmt.Errorf("cannot write transformation proposal: %w", err)
+	}
+	return nil
+}
+
+func isColumnScannable(c *toolkit.Column) bool {
+	if _, ok := scannableTypes[c.TypeName]; ok {
+		return true
+	}
+	_, ok := scannableTypes[c.CanonicalTypeName]
+	return ok
+}
@@ -0,0 +1,71 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/entries"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+func Test_buildSampleQuery(t *testing.T) {
+	tests := []struct {
+		name         string
+		relKind      rune
+		rowsEstimate int64
+		expected     string
+	}{
+		{
+			name:         "never analyzed",
+			relKind:      'r',
+			rowsEstimate: -1,
+			expected:     `SELECT "email"::TEXT FROM "public"."users" ORDER BY random() LIMIT $1`,
+		},
+		{
+			name:         "small table",
+			relKind:      'r',
+			rowsEstimate: 1000,
+			expected:     `SELECT "email"::TEXT FROM "public"."users" ORDER BY random() LIMIT $1`,
+		},
+		{
+			name:         "large table",
+			relKind:      'r',
+			rowsEstimate: 1000000,
+			expected:     `SELECT "email"::TEXT FROM "public"."users" TABLESAMPLE BERNOULLI (0.02) LIMIT $1`,
+		},
+		{
+			name:         "large foreign table",
+			relKind:      'f',
+			rowsEstimate: 1000000,
+			expected:     `SELECT "email"::TEXT FROM "public"."users" ORDER BY random() LIMIT $1`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := &entries.Table{
+				Table: &toolkit.Table{
+					Schema:       "public",
+					Name:         "users",
+					RowsEstimate: tt.rowsEstimate,
+				},
+				RelKind: tt.relKind,
+			}
+			assert.Equal(t, tt.expected, buildSampleQuery(table, []string{`"email"::TEXT`}, 100))
+		})
+	}
+}
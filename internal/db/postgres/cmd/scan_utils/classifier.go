@@ -0,0 +1,92 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scan_utils
+
+const (
+	// nameHintWeight - the confidence part that is given by the matched column name
+	nameHintWeight = 0.2
+)
+
+// Detection - the result of the column classification
+type Detection struct {
+	Detector    string
+	Confidence  float64
+	Transformer *TransformerProposal
+}
+
+// TransformerProposal - the transformer that is proposed for the detected column
+type TransformerProposal struct {
+	Name   string
+	Params map[string]any
+}
+
+func newTransformerProposal(name, column string) *TransformerProposal {
+	return &TransformerProposal{
+		Name:   name,
+		Params: map[string]any{"column": column},
+	}
+}
+
+// ColumnReport - the scan result of the column
+type ColumnReport struct {
+	Name     string
+	TypeName string
+	// Transformed - the column is already transformed by the existing config
+	Transformed bool
+	// Sampled - the number of not NULL sampled values
+	Sampled   int
+	Detection *Detection
+}
+
+// TableReport - the scan result of the table
+type TableReport struct {
+	Schema  string
+	Name    string
+	Columns []*ColumnReport
+}
+
+// Classify - runs the detectors against the sampled not NULL values of the column and returns the detection with the
+// highest confidence. Confidence is the ratio of the matched values, the column name that matches the detector hint
+// increases it. Nil is returned if none of the values was matched
+func Classify(column string, values []string, detectors []*Detector) *Detection {
+	if len(values) == 0 {
+		return nil
+	}
+	var res *Detection
+	for _, d := range detectors {
+		var matched []string
+		for _, v := range values {
+			if d.Match(v) {
+				matched = append(matched, v)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		confidence := float64(len(matched)) / float64(len(values))
+		if d.NameHint != nil && d.NameHint.MatchString(column) {
+			confidence = confidence*(1-nameHintWeight) + nameHintWeight
+		}
+		if res != nil && res.Confidence >= confidence {
+			continue
+		}
+		res = &Detection{
+			Detector:    d.Name,
+			Confidence:  confidence,
+			Transformer: d.Transformer(column, matched),
+		}
+	}
+	return res
+}
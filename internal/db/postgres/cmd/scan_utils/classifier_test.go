@@ -0,0 +1,158 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scan_utils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name               string
+		column             string
+		values             []string
+		expectedDetector   string
+		expectedConfidence float64
+		expectedParams     map[string]any
+	}{
+		{
+			name:               "email",
+			column:             "contact",
+			values:             []string{"john@example.com", "jane.doe@mail.org"},
+			expectedDetector:   EmailDetectorName,
+			expectedConfidence: 1,
+		},
+		{
+			name:               "credit card with luhn",
+			column:             "payment",
+			values:             []string{"4111 1111 1111 1111", "5500-0000-0000-0004", "4111111111111112", "test"},
+			expectedDetector:   CreditCardDetectorName,
+			expectedConfidence: 0.5,
+		},
+		{
+			name:               "iban",
+			column:             "data",
+			values:             []string{"GB82 WEST 1234 5698 7654 32", "DE89370400440532013000"},
+			expectedDetector:   IbanDetectorName,
+			expectedConfidence: 1,
+		},
+		{
+			name:               "ssn",
+			column:             "data",
+			values:             []string{"123-45-6789", "666-45-6789"},
+			expectedDetector:   SsnDetectorName,
+			expectedConfidence: 0.5,
+		},
+		{
+			name:               "phone with name hint",
+			column:             "mobile_phone",
+			values:             []string{"+1 415-555-0100", "(415) 555-0101", "2024-01-01", "n/a"},
+			expectedDetector:   PhoneDetectorName,
+			expectedConfidence: 0.6,
+		},
+		{
+			name:               "ipv6",
+			column:             "last_ip",
+			values:             []string{"2001:db8::1", "10.0.0.1/32"},
+			expectedDetector:   IpDetectorName,
+			expectedConfidence: 1,
+			expectedParams:     map[string]any{"column": "last_ip", "subnet": "fd00::/8", "engine": "hash"},
+		},
+		{
+			name:               "first names",
+			column:             "person",
+			values:             []string{"Aaron", "Adam", "Abbott"},
+			expectedDetector:   NameDetectorName,
+			expectedConfidence: 1,
+			expectedParams: map[string]any{
+				"columns": []map[string]string{{"name": "person", "template": "{{ .FirstName }}"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Classify(tt.column, tt.values, DefaultDetectors)
+			require.NotNil(t, res)
+			assert.Equal(t, tt.expectedDetector, res.Detector)
+			assert.InDelta(t, tt.expectedConfidence, res.Confidence, 0.001)
+			if tt.expectedParams != nil {
+				assert.Equal(t, tt.expectedParams, res.Transformer.Params)
+			}
+		})
+	}
+
+	t.Run("nothing detected", func(t *testing.T) {
+		assert.Nil(t, Classify("status", []string{"active", "12345", "2024-01-01"}, DefaultDetectors))
+		assert.Nil(t, Classify("status", nil, DefaultDetectors))
+	})
+}
+
+func TestWriteTransformationYaml(t *testing.T) {
+	tables := []*TableReport{
+		{
+			Schema: "public",
+			Name:   "users",
+			Columns: []*ColumnReport{
+				{Name: "id", TypeName: "integer"},
+				{Name: "email", TypeName: "text", Detection: Classify("email", []string{"a@b.com"}, DefaultDetectors)},
+				{
+					Name: "first_name", TypeName: "text",
+					Detection: Classify("first_name", []string{"Adam"}, DefaultDetectors),
+				},
+				{
+					Name: "last_name", TypeName: "text",
+					Detection: Classify("last_name", []string{"Abbott"}, DefaultDetectors),
+				},
+				{
+					Name: "phone", TypeName: "text", Transformed: true,
+					Detection: Classify("phone", []string{"+14155550100"}, DefaultDetectors),
+				},
+			},
+		},
+		{
+			Schema:  "public",
+			Name:    "orders",
+			Columns: []*ColumnReport{{Name: "id", TypeName: "integer"}},
+		},
+	}
+
+	expected := `dump:
+  transformation:
+    - schema: public
+      name: users
+      transformers:
+        - name: RandomEmail # email: email (confidence 1.00)
+          params:
+            column: email
+        - name: RandomPerson # first_name: name (confidence 1.00); last_name: name (confidence 1.00)
+          params:
+            columns:
+              - name: first_name
+                template: '{{ .FirstName }}'
+              - name: last_name
+                template: '{{ .LastName }}'
+`
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteTransformationYaml(buf, tables, 0.5))
+	assert.Equal(t, expected, buf.String())
+
+	// The report data must not be modified by the proposal building
+	assert.Len(t, tables[0].Columns[2].Detection.Transformer.Params["columns"], 1)
+}
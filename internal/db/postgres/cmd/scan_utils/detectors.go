@@ -0,0 +1,295 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scan_utils
+
+import (
+	"math/big"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers"
+	generators "github.com/greenmaskio/greenmask/internal/generators/transformers"
+)
+
+const (
+	EmailDetectorName      = "email"
+	PhoneDetectorName      = "phone"
+	CreditCardDetectorName = "credit_card"
+	IpDetectorName         = "ip"
+	IbanDetectorName       = "iban"
+	SsnDetectorName        = "ssn"
+	NameDetectorName       = "name"
+)
+
+var (
+	emailRegexp      = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
+	phoneRegexp      = regexp.MustCompile(`^\+?[0-9()\-. ]{7,20}$`)
+	creditCardRegexp = regexp.MustCompile(`^[0-9][0-9 \-]{11,22}[0-9]$`)
+	ibanRegexp       = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{11,30}$`)
+	ssnRegexp        = regexp.MustCompile(`^([0-9]{3})-([0-9]{2})-([0-9]{4})$`)
+	dateRegexp       = regexp.MustCompile(`^[0-9]{4}[\-./][0-9]{2}[\-./][0-9]{2}$`)
+)
+
+// Detector - checks whether the value contains a particular kind of personal data and proposes the transformer
+// for the column that contains it
+type Detector struct {
+	// Name - the detector name that is shown in the report
+	Name string
+	// NameHint - the column name pattern that increases the confidence of the detection
+	NameHint *regexp.Regexp
+	// Match - returns true if the value contains the detected data
+	Match func(value string) bool
+	// Transformer - returns the proposed transformer for the column. The matched values are passed for the fine-tuning
+	Transformer func(column string, matched []string) *TransformerProposal
+}
+
+// DefaultDetectors - the detectors used by scan command. The order matters: if a few detectors have the same
+// confidence, the first one is chosen
+var DefaultDetectors = []*Detector{
+	{
+		Name:     EmailDetectorName,
+		NameHint: regexp.MustCompile(`(?i)e_?mail`),
+		Match:    isEmail,
+		Transformer: func(column string, _ []string) *TransformerProposal {
+			return newTransformerProposal(transformers.RandomEmailTransformerName, column)
+		},
+	},
+	{
+		Name:     CreditCardDetectorName,
+		NameHint: regexp.MustCompile(`(?i)(card|^cc_|_cc$|^pan$)`),
+		Match:    isCreditCard,
+		Transformer: func(column string, _ []string) *TransformerProposal {
+			return newTransformerProposal(transformers.RandomCCNumberTransformerName, column)
+		},
+	},
+	{
+		Name:     IbanDetectorName,
+		NameHint: regexp.MustCompile(`(?i)(iban|account)`),
+		Match:    isIban,
+		Transformer: func(column string, _ []string) *TransformerProposal {
+			tp := newTransformerProposal(transformers.MaskingTransformerName, column)
+			tp.Params["type"] = transformers.MDefault
+			return tp
+		},
+	},
+	{
+		Name:     SsnDetectorName,
+		NameHint: regexp.MustCompile(`(?i)(ssn|social_security)`),
+		Match:    isSsn,
+		Transformer: func(column string, _ []string) *TransformerProposal {
+			tp := newTransformerProposal(transformers.MaskingTransformerName, column)
+			tp.Params["type"] = transformers.MID
+			return tp
+		},
+	},
+	{
+		Name:     IpDetectorName,
+		NameHint: regexp.MustCompile(`(?i)(^|_)ip(_|$|v4|v6|addr)`),
+		Match:    isIp,
+		Transformer: func(column string, matched []string) *TransformerProposal {
+			tp := newTransformerProposal(transformers.RandomIpTransformerName, column)
+			tp.Params["subnet"] = "192.168.0.0/16"
+			for _, v := range matched {
+				if strings.Contains(v, ":") {
+					tp.Params["subnet"] = "fd00::/8"
+					break
+				}
+			}
+			tp.Params["engine"] = "hash"
+			return tp
+		},
+	},
+	{
+		Name:     PhoneDetectorName,
+		NameHint: regexp.MustCompile(`(?i)(phone|mobile|msisdn|tel$|^tel|fax)`),
+		Match:    isPhone,
+		Transformer: func(column string, _ []string) *TransformerProposal {
+			return newTransformerProposal(transformers.RandomE164PhoneNumberTransformerName, column)
+		},
+	},
+	{
+		Name:        NameDetectorName,
+		NameHint:    regexp.MustCompile(`(?i)(name|surname)`),
+		Match:       isPersonName,
+		Transformer: personNameTransformer,
+	},
+}
+
+var firstNames, lastNames = func() (map[string]struct{}, map[string]struct{}) {
+	first := make(map[string]struct{})
+	for _, n := range generators.DefaultFirstNamesMale {
+		first[strings.ToLower(n)] = struct{}{}
+	}
+	for _, n := range generators.DefaultFirstNamesFemale {
+		first[strings.ToLower(n)] = struct{}{}
+	}
+	last := make(map[string]struct{})
+	for _, n := range generators.DefaultLastNames {
+		last[strings.ToLower(n)] = struct{}{}
+	}
+	return first, last
+}()
+
+func isEmail(value string) bool {
+	return emailRegexp.MatchString(value)
+}
+
+func isPhone(value string) bool {
+	// Dates and SSN-like values have the same symbols but never have the phone layout
+	if !phoneRegexp.MatchString(value) || dateRegexp.MatchString(value) || ssnRegexp.MatchString(value) {
+		return false
+	}
+	digits := onlyDigits(value)
+	// Phone must contain the separators or the country code prefix, otherwise it is indistinguishable from an integer
+	if len(digits) < 7 || len(digits) > 15 || (digits == value && !strings.HasPrefix(value, "+")) {
+		return false
+	}
+	return true
+}
+
+func isCreditCard(value string) bool {
+	if !creditCardRegexp.MatchString(value) {
+		return false
+	}
+	digits := onlyDigits(value)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	return luhnValid(digits)
+}
+
+func isIp(value string) bool {
+	// inet type may contain the network mask
+	if idx := strings.IndexByte(value, '/'); idx != -1 {
+		value = value[:idx]
+	}
+	return net.ParseIP(value) != nil
+}
+
+func isIban(value string) bool {
+	value = strings.ToUpper(strings.ReplaceAll(value, " ", ""))
+	if !ibanRegexp.MatchString(value) {
+		return false
+	}
+	// Move the country code and check digits to the end and replace the letters with numbers (A=10 ... Z=35)
+	rearranged := value[4:] + value[:4]
+	var sb strings.Builder
+	for _, r := range rearranged {
+		if unicode.IsLetter(r) {
+			sb.WriteString(strconv.Itoa(int(r-'A') + 10))
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+	n, ok := new(big.Int).SetString(sb.String(), 10)
+	if !ok {
+		return false
+	}
+	return new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// isSsn - checks the value is the US Social Security Number. The national ID numbers of the other countries are not
+// detected
+func isSsn(value string) bool {
+	m := ssnRegexp.FindStringSubmatch(value)
+	if m == nil {
+		return false
+	}
+	// Area number 000, 666 and 900-999, group 00 and serial 0000 are never assigned
+	if m[1] == "000" || m[1] == "666" || m[1][0] == '9' || m[2] == "00" || m[3] == "0000" {
+		return false
+	}
+	return true
+}
+
+func isPersonName(value string) bool {
+	parts := strings.Fields(value)
+	if len(parts) == 0 || len(parts) > 3 {
+		return false
+	}
+	for _, p := range parts {
+		p = strings.ToLower(strings.Trim(p, ".,"))
+		_, isFirst := firstNames[p]
+		_, isLast := lastNames[p]
+		if isFirst || isLast {
+			return true
+		}
+	}
+	return false
+}
+
+// personNameTransformer - proposes RandomPerson template according to the matched values. Full name is used if the
+// most of the values consist of a few words
+func personNameTransformer(column string, matched []string) *TransformerProposal {
+	var fullNames, firstOnly, lastOnly int
+	for _, v := range matched {
+		parts := strings.Fields(v)
+		if len(parts) > 1 {
+			fullNames++
+			continue
+		}
+		if _, ok := firstNames[strings.ToLower(v)]; ok {
+			firstOnly++
+		} else {
+			lastOnly++
+		}
+	}
+	template := "{{ .FirstName }} {{ .LastName }}"
+	if fullNames*2 < len(matched) {
+		if firstOnly >= lastOnly {
+			template = "{{ .FirstName }}"
+		} else {
+			template = "{{ .LastName }}"
+		}
+	}
+	return &TransformerProposal{
+		Name: transformers.RandomPersonTransformerName,
+		Params: map[string]any{
+			"columns": []map[string]string{
+				{"name": column, "template": template},
+			},
+		},
+	}
+}
+
+func luhnValid(digits string) bool {
+	var sum int
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func onlyDigits(value string) string {
+	var sb strings.Builder
+	for _, r := range value {
+		if r >= '0' && r <= '9' {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,158 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scan_utils
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/yaml.v3"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers"
+)
+
+const noDetectionValue = "-"
+
+type tableProposal struct {
+	schema       string
+	name         string
+	transformers []*transformerProposalItem
+}
+
+type transformerProposalItem struct {
+	proposal *TransformerProposal
+	comments []string
+}
+
+// WriteTransformationYaml - writes the dump.transformation config block with the proposed transformers for the
+// detected columns that are not transformed by the existing config. Each transformer is commented with the detector
+// name and the confidence
+func WriteTransformationYaml(w io.Writer, tables []*TableReport, minConfidence float64) error {
+	proposals := buildProposals(tables, minConfidence)
+
+	transformation := &yaml.Node{Kind: yaml.SequenceNode}
+	for _, tp := range proposals {
+		transformersNode := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, item := range tp.transformers {
+			paramsNode := &yaml.Node{}
+			if err := paramsNode.Encode(item.proposal.Params); err != nil {
+				return fmt.Errorf("cannot encode transformer params: %w", err)
+			}
+			nameNode := scalarNode(item.proposal.Name)
+			nameNode.LineComment = strings.Join(item.comments, "; ")
+			transformersNode.Content = append(transformersNode.Content, mappingNode(
+				scalarNode("name"), nameNode,
+				scalarNode("params"), paramsNode,
+			))
+		}
+		transformation.Content = append(transformation.Content, mappingNode(
+			scalarNode("schema"), scalarNode(tp.schema),
+			scalarNode("name"), scalarNode(tp.name),
+			scalarNode("transformers"), transformersNode,
+		))
+	}
+
+	doc := mappingNode(
+		scalarNode("dump"), mappingNode(
+			scalarNode("transformation"), transformation,
+		),
+	)
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("cannot encode transformation config: %w", err)
+	}
+	return enc.Close()
+}
+
+// PrintUntransformedColumns - prints the table of the columns that are not transformed by the existing config
+// including the detection result if the confidence is not less than minConfidence
+func PrintUntransformedColumns(w io.Writer, tables []*TableReport, minConfidence float64) {
+	var data [][]string
+	for _, t := range tables {
+		for _, c := range t.Columns {
+			if c.Transformed {
+				continue
+			}
+			detector, confidence := noDetectionValue, noDetectionValue
+			if c.Detection != nil && c.Detection.Confidence >= minConfidence {
+				detector = c.Detection.Detector
+				confidence = formatConfidence(c.Detection.Confidence)
+			}
+			data = append(data, []string{
+				t.Schema, t.Name, c.Name, c.TypeName, fmt.Sprintf("%d", c.Sampled), detector, confidence,
+			})
+		}
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"schema", "table", "column", "type", "sampled", "detector", "confidence"})
+	table.AppendBulk(data)
+	table.Render()
+}
+
+func buildProposals(tables []*TableReport, minConfidence float64) []*tableProposal {
+	var res []*tableProposal
+	for _, t := range tables {
+		tp := &tableProposal{schema: t.Schema, name: t.Name}
+		// The name columns of the table are transformed by a single RandomPerson transformer to keep the
+		// generated first and last names consistent
+		var person *transformerProposalItem
+		for _, c := range t.Columns {
+			if c.Transformed || c.Detection == nil || c.Detection.Confidence < minConfidence {
+				continue
+			}
+			comment := fmt.Sprintf(
+				"%s: %s (confidence %s)", c.Name, c.Detection.Detector, formatConfidence(c.Detection.Confidence),
+			)
+			proposal := c.Detection.Transformer
+			if proposal.Name == transformers.RandomPersonTransformerName && person != nil {
+				columns := person.proposal.Params["columns"].([]map[string]string)
+				person.proposal.Params["columns"] = append(
+					slices.Clone(columns), proposal.Params["columns"].([]map[string]string)...,
+				)
+				person.comments = append(person.comments, comment)
+				continue
+			}
+			item := &transformerProposalItem{proposal: proposal, comments: []string{comment}}
+			if proposal.Name == transformers.RandomPersonTransformerName {
+				// Copy the proposal because the columns of the other name columns are appended to it
+				item.proposal = &TransformerProposal{Name: proposal.Name, Params: maps.Clone(proposal.Params)}
+				person = item
+			}
+			tp.transformers = append(tp.transformers, item)
+		}
+		if len(tp.transformers) > 0 {
+			res = append(res, tp)
+		}
+	}
+	return res
+}
+
+func formatConfidence(c float64) string {
+	return fmt.Sprintf("%.2f", c)
+}
+
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+func mappingNode(content ...*yaml.Node) *yaml.Node {
+	return &yaml.Node{Kind: yaml.MappingNode, Content: content}
+}
@@ -20,7 +20,6 @@ import (
 	"fmt"
 	"html/template"
 	"os"
-	"path"
 	"regexp"
 
 	"gopkg.in/yaml.v3"
@@ -42,6 +41,7 @@ var templateString = `;
 ;     dbname: {{ .Header.DbName }}
 ;     TOC Entries: {{ .Header.TocEntriesCount }}
 ;     Compression: {{ .Header.Compression }}
+;     Data Codec: {{ if ne .Header.Codec "" }}{{ .Header.Codec }}{{ else }}gzip{{ end }}
 ;     Dump Version: {{ .Header.DumpVersion }}
 ;     TableFormat: DIRECTORY
 ;     Integer: {{ .Header.Integer }} bytes
@@ -59,7 +59,11 @@ var templateString = `;
 
 func ShowDump(ctx context.Context, st storages.Storager, dumpId string, format string) error {
 	meta := &storageDto.Metadata{}
-	r, err := st.GetObject(ctx, path.Join(dumpId, MetadataJsonFileName))
+	dumpSt, err := GetDumpStorage(ctx, st, dumpId)
+	if err != nil {
+		return err
+	}
+	r, err := dumpSt.GetObject(ctx, MetadataJsonFileName)
 	if err != nil {
 		return fmt.Errorf("cannot get metadata: %w", err)
 	}
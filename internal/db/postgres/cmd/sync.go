@@ -0,0 +1,337 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/entries"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	pgUtils "github.com/greenmaskio/greenmask/internal/db/postgres/utils"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/storages/directory"
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+// Sync - dumps the source database and streams the transformed table data straight into the target database. The
+// schema is restored before the data streaming and the post-data section is restored after it. The dump objects
+// except the table data are written into the temporary storage. If the storage is provided, the whole dump is
+// written into it as well
+type Sync struct {
+	*Dump
+	restore *Restore
+	// dataSt - the storage of the dump objects. It is the temporary directory storage if the dump is not saved
+	dataSt storages.Storager
+	save   bool
+	// dependencies - map of the table Oid to the Oids of the tables it depends on. It is set only if the tables must
+	// be restored in the topological order
+	dependencies map[toolkit.Oid][]toolkit.Oid
+	// tablesLeft - map of the table Oid to the count of its data files (partitions or chunks) that are not restored
+	// yet
+	tablesLeft map[toolkit.Oid]int
+	mx         *sync.Mutex
+}
+
+// NewSync - creates the sync command. If st is nil the dump is not saved and the table data is not compressed
+func NewSync(cfg *domains.Config, st storages.Storager, registry *utils.TransformerRegistry) *Sync {
+	return &Sync{
+		Dump:       NewDump(cfg, st, registry),
+		dataSt:     st,
+		save:       st != nil,
+		tablesLeft: make(map[toolkit.Oid]int),
+		mx:         &sync.Mutex{},
+	}
+}
+
+func (s *Sync) Run(ctx context.Context) error {
+	if !s.save {
+		tmpDir := path.Join(s.config.Common.TempDirectory, fmt.Sprintf("sync_%d", time.Now().UnixNano()))
+		if err := os.MkdirAll(tmpDir, 0700); err != nil {
+			return fmt.Errorf("error creating temp dir: %w", err)
+		}
+		defer func() {
+			if err := os.RemoveAll(tmpDir); err != nil {
+				log.Warn().Err(err).Msg("error deleting temp dir")
+			}
+		}()
+		st, err := directory.NewStorage(&directory.Config{Path: tmpDir})
+		if err != nil {
+			return fmt.Errorf("error creating temp storage: %w", err)
+		}
+		s.dataSt = st
+		// The table data is not stored, so there is no reason to compress it
+		s.compression.Codec = ioutils.CodecNone
+		s.compression.Level = ioutils.DefaultCompressionLevel
+	}
+	s.st = &syncStorage{Storager: s.dataSt, sync: s}
+
+	s.restore = NewRestore(
+		s.config.Common.PgBinPath, s.dataSt, &s.config.Restore, s.config.Restore.Scripts,
+		s.config.Common.TempDirectory,
+	)
+	defer s.restore.prune()
+	if s.restore.restoreOpt.Jobs < 1 {
+		s.restore.restoreOpt.Jobs = 1
+	}
+	s.restore.SetTransformerRegistry(s.registry)
+	if err := s.restore.validateMerge(); err != nil {
+		return fmt.Errorf("merge settings validation error: %w", err)
+	}
+
+	s.beforeDataDump = s.restoreSchema
+	if err := s.Dump.Run(ctx); err != nil {
+		return fmt.Errorf("dump error: %w", err)
+	}
+
+	// The dump is written, so the rest of the data section (sequences, large objects, etc.) and the post-data
+	// section are restored as usual
+	s.restore.tableDataRestored = true
+	if err := s.restore.readMetadata(ctx); err != nil {
+		return fmt.Errorf("cannot read metadata: %w", err)
+	}
+	if err := s.restore.readTocDatFile(ctx); err != nil {
+		return fmt.Errorf("cannot read toc: %w", err)
+	}
+	if err := s.restore.dataRestore(ctx); err != nil {
+		return fmt.Errorf("data stage restoration error: %w", err)
+	}
+	if err := s.restore.postDataRestore(ctx); err != nil {
+		return fmt.Errorf("post-data stage restoration error: %w", err)
+	}
+	return nil
+}
+
+// restoreSchema - restores the pre-data section of the schema TOC into the target database and runs the data section
+// "before" scripts. It is called by Dump.Run when the schema is dumped
+func (s *Sync) restoreSchema(ctx context.Context) error {
+	r := s.restore
+	if err := r.validateTransformation(ctx, s.context.DatabaseSchema); err != nil {
+		return fmt.Errorf("restore transformation validation error: %w", err)
+	}
+	r.tocObj = s.schemaToc.Copy()
+	if err := r.prepare(ctx); err != nil {
+		return fmt.Errorf("preparation error: %w", err)
+	}
+	if err := r.preFlightRestore(); err != nil {
+		return fmt.Errorf("pre-flight stage restoration error: %w", err)
+	}
+	if err := r.preDataRestore(ctx); err != nil {
+		return fmt.Errorf("pre-data stage restoration error: %w", err)
+	}
+
+	conn, err := pgx.Connect(ctx, r.dsn)
+	if err != nil {
+		return fmt.Errorf("cannot establish connection to db: %w", err)
+	}
+	defer func() {
+		if err := conn.Close(ctx); err != nil {
+			log.Warn().Err(err).Msg("error closing connection")
+		}
+	}()
+	if err = r.RunScripts(ctx, conn, scriptDataSection, scriptExecuteBefore); err != nil {
+		return err
+	}
+
+	if r.restoreOpt.RestoreInOrder {
+		sortedOids, graph := s.context.Graph.GetSortedTablesAndDependenciesGraph()
+		s.planRestoreOrder(sortedOids, graph)
+	}
+	return nil
+}
+
+// planRestoreOrder - sorts the data section objects in the topological order, so the tables are dumped and streamed
+// after the tables they depend on
+func (s *Sync) planRestoreOrder(sortedOids []toolkit.Oid, graph map[toolkit.Oid][]toolkit.Oid) {
+	rank := func(obj entries.Entry) int {
+		t, ok := obj.(*entries.Table)
+		if !ok {
+			return len(sortedOids)
+		}
+		// The tables that are not in the graph do not have dependencies, so they go first
+		return slices.Index(sortedOids, getSyncTableOid(t))
+	}
+	slices.SortStableFunc(s.context.DataSectionObjects, func(a, b entries.Entry) int {
+		return cmp.Compare(rank(a), rank(b))
+	})
+
+	for _, obj := range s.context.DataSectionObjects {
+		t, ok := obj.(*entries.Table)
+		if !ok || t.RelKind == 'p' {
+			continue
+		}
+		s.tablesLeft[getSyncTableOid(t)] += max(len(t.Chunks), 1)
+	}
+	s.dependencies = graph
+}
+
+// restoreTableData - restores the table data file that is being dumped into the target database. If the dump is
+// saved, the data is written into the storage at the same time
+func (s *Sync) restoreTableData(ctx context.Context, t *entries.Table, fileName string, body io.Reader) (err error) {
+	data := body
+	if s.save {
+		pr, pw := io.Pipe()
+		putErr := make(chan error, 1)
+		go func() {
+			err := s.dataSt.PutObject(ctx, fileName, pr)
+			_ = pr.CloseWithError(err)
+			putErr <- err
+		}()
+		data = io.TeeReader(body, pw)
+		defer func() {
+			_ = pw.CloseWithError(err)
+			if storageErr := <-putErr; storageErr != nil && err == nil {
+				err = fmt.Errorf("cannot write data file to the storage: %w", storageErr)
+			}
+		}()
+	}
+
+	if err = s.waitTableDependencies(ctx, t); err != nil {
+		return err
+	}
+	if err = s.streamTableData(ctx, t, fileName, data); err != nil {
+		return err
+	}
+	// The rest of the data must be consumed even if the restoration failed and the error was skipped
+	if _, err = io.Copy(io.Discard, data); err != nil {
+		return fmt.Errorf("cannot read table data: %w", err)
+	}
+	s.markTableRestored(t)
+	return nil
+}
+
+func (s *Sync) streamTableData(ctx context.Context, t *entries.Table, fileName string, data io.Reader) error {
+	entry, err := t.Entry()
+	if err != nil {
+		return fmt.Errorf("cannot create table toc entry: %w", err)
+	}
+	entry.FileName = &fileName
+	st := &streamStorage{fileName: fileName, r: data}
+
+	r := s.restore
+	task, err := r.newTableDataRestorer(ctx, entry, t.Table, st)
+	if err != nil {
+		return fmt.Errorf("cannot create table restorer: %w", err)
+	}
+
+	conn, err := pgx.Connect(ctx, r.dsn)
+	if err != nil {
+		return fmt.Errorf("cannot connect to target db: %w", err)
+	}
+	defer func() {
+		if err := conn.Close(ctx); err != nil {
+			log.Warn().Err(err).Msg("error closing connection")
+		}
+	}()
+
+	log.Debug().
+		Str("ObjectName", task.DebugInfo()).
+		Str("FileName", fileName).
+		Msg("streaming table data into target database")
+	if err = task.Execute(ctx, pgUtils.NewPGConn(conn)); err != nil {
+		return fmt.Errorf("unable to perform restoration task (restoring %s): %w", task.DebugInfo(), err)
+	}
+	return nil
+}
+
+// waitTableDependencies - waits until the tables the table depends on are restored. It does nothing if the tables are
+// not restored in the topological order
+func (s *Sync) waitTableDependencies(ctx context.Context, t *entries.Table) error {
+	if s.dependencies == nil {
+		return nil
+	}
+	oid := getSyncTableOid(t)
+	for {
+		if s.dependenciesAreRestored(oid) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(dependenciesCheckInterval):
+		}
+	}
+}
+
+func (s *Sync) dependenciesAreRestored(oid toolkit.Oid) bool {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for _, depOid := range s.dependencies[oid] {
+		if depOid != oid && s.tablesLeft[depOid] > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Sync) markTableRestored(t *entries.Table) {
+	if s.dependencies == nil {
+		return
+	}
+	s.mx.Lock()
+	s.tablesLeft[getSyncTableOid(t)]--
+	s.mx.Unlock()
+}
+
+// getSyncTableOid - returns the Oid of the table in the dependencies graph. The partitions are represented by their
+// root table
+func getSyncTableOid(t *entries.Table) toolkit.Oid {
+	if t.RootPtOid != 0 {
+		return t.RootPtOid
+	}
+	return t.Oid
+}
+
+// syncStorage - the storage decorator that streams the table data files into the target database. The other objects
+// are written into the underlying storage
+type syncStorage struct {
+	storages.Storager
+	sync *Sync
+}
+
+func (ss *syncStorage) PutObject(ctx context.Context, filePath string, body io.Reader) error {
+	t, ok := ss.sync.findTableByFileName(filePath)
+	if !ok {
+		return ss.Storager.PutObject(ctx, filePath, body)
+	}
+	return ss.sync.restoreTableData(ctx, t, filePath, body)
+}
+
+// streamStorage - the storage that provides the single object that is being streamed. The restorers read the table
+// data from it
+type streamStorage struct {
+	storages.Storager
+	fileName string
+	r        io.Reader
+}
+
+func (ss *streamStorage) GetObject(_ context.Context, filePath string) (io.ReadCloser, error) {
+	if filePath != ss.fileName {
+		return nil, storages.ErrFileNotFound
+	}
+	return io.NopCloser(ss.r), nil
+}
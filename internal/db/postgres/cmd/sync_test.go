@@ -0,0 +1,118 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	runtimeContext "github.com/greenmaskio/greenmask/internal/db/postgres/context"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/entries"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/storages/validate"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+func newSyncTestTable(oid toolkit.Oid, name string, dumpId int32) *entries.Table {
+	t := newTableEntry(oid, name)
+	t.DumpId = dumpId
+	return t
+}
+
+func newTestSync(objects ...entries.Entry) *Sync {
+	s := NewSync(domains.NewConfig(), nil, utils.DefaultTransformerRegistry)
+	s.context = &runtimeContext.RuntimeContext{DataSectionObjects: objects}
+	return s
+}
+
+func TestSync_findTableByFileName(t *testing.T) {
+	users := newSyncTestTable(1, "users", 10)
+	orders := newSyncTestTable(2, "orders", 11)
+	orders.Chunks = []*entries.TableChunk{
+		{Part: 0, FileName: "11.0.dat.gz"},
+		{Part: 1, FileName: "11.1.dat.gz"},
+	}
+	s := newTestSync(users, orders, &entries.Sequence{Name: "users_id_seq"})
+
+	res, ok := s.findTableByFileName("10.dat.gz")
+	require.True(t, ok)
+	assert.Equal(t, users, res)
+
+	res, ok = s.findTableByFileName("11.1.dat.gz")
+	require.True(t, ok)
+	assert.Equal(t, orders, res)
+
+	_, ok = s.findTableByFileName("11.dat.gz")
+	assert.False(t, ok)
+	_, ok = s.findTableByFileName("toc.dat")
+	assert.False(t, ok)
+}
+
+func TestSyncStorage_PutObject(t *testing.T) {
+	ctx := context.Background()
+	st := validate.New("")
+	s := newTestSync(newSyncTestTable(1, "users", 10))
+	ss := &syncStorage{Storager: st, sync: s}
+
+	// The objects that are not table data are written into the underlying storage
+	require.NoError(t, ss.PutObject(ctx, "toc.dat", bytes.NewBufferString("toc")))
+	exists, err := st.Exists(ctx, "toc.dat")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestSync_planRestoreOrder(t *testing.T) {
+	users := newSyncTestTable(1, "users", 10)
+	orders := newSyncTestTable(2, "orders", 11)
+	orders.Chunks = []*entries.TableChunk{{Part: 0}, {Part: 1}}
+	seq := &entries.Sequence{Name: "orders_id_seq"}
+	s := newTestSync(seq, orders, users)
+
+	s.planRestoreOrder([]toolkit.Oid{1, 2}, map[toolkit.Oid][]toolkit.Oid{1: {}, 2: {1}})
+	assert.Equal(t, []entries.Entry{users, orders, seq}, s.context.DataSectionObjects)
+	assert.Equal(t, map[toolkit.Oid]int{1: 1, 2: 2}, s.tablesLeft)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, s.waitTableDependencies(ctx, users))
+	assert.False(t, s.dependenciesAreRestored(orders.Oid))
+
+	done := make(chan error)
+	go func() {
+		done <- s.waitTableDependencies(ctx, orders)
+	}()
+	s.markTableRestored(users)
+	require.NoError(t, <-done)
+}
+
+func TestStreamStorage_GetObject(t *testing.T) {
+	ss := &streamStorage{fileName: "10.dat", r: bytes.NewBufferString("1\tadmin\n")}
+	_, err := ss.GetObject(context.Background(), "11.dat")
+	require.ErrorIs(t, err, storages.ErrFileNotFound)
+
+	r, err := ss.GetObject(context.Background(), "10.dat")
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "1\tadmin\n", string(data))
+}
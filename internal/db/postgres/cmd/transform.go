@@ -0,0 +1,456 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+
+	runtimeContext "github.com/greenmaskio/greenmask/internal/db/postgres/context"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/dumpers"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/entries"
+	storageDto "github.com/greenmaskio/greenmask/internal/db/postgres/storage"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/toc"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/custom"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+var ErrTransformationIsEmpty = errors.New("dump.transformation is empty: nothing to transform")
+
+// transformFileTask - writes a single table data file of the new dump. The file is copied as is if transformer is nil
+type transformFileTask struct {
+	entry       *storageDto.Entry
+	transformer *dumpers.TableDataTransformer
+	srcSt       storages.Storager
+	srcFileName string
+	fileName    string
+}
+
+// Transform - applies the dump transformation config to the data of the existing dump and writes the result as a
+// new dump. The transformers are initialized using the tables definition stored in the dump metadata, so the
+// database connection is not required
+type Transform struct {
+	config   *domains.Config
+	dumpsSt  storages.Storager
+	srcSt    storages.Storager
+	st       storages.Storager
+	fromId   string
+	dumpId   string
+	registry *utils.TransformerRegistry
+	// checksums - map of the written file name to its SHA-256 checksum
+	checksums map[string]string
+	mx        *sync.Mutex
+}
+
+// NewTransform - creates the transformation of the dump fromId into the new dump dumpId. dumpsSt is the storage
+// that contains all the dumps, it is used for resolving the references of incremental dumps
+func NewTransform(
+	cfg *domains.Config, dumpsSt storages.Storager, fromId, dumpId string, registry *utils.TransformerRegistry,
+) *Transform {
+	return &Transform{
+		config:    cfg,
+		dumpsSt:   dumpsSt,
+		st:        dumpsSt.SubStorage(dumpId, true),
+		fromId:    fromId,
+		dumpId:    dumpId,
+		registry:  registry,
+		checksums: make(map[string]string),
+		mx:        &sync.Mutex{},
+	}
+}
+
+// Run - transforms the dump. The dump id "latest" is resolved to the latest completed dump
+func (t *Transform) Run(ctx context.Context) error {
+	startedAt := time.Now()
+	if len(t.config.Dump.Transformation) == 0 {
+		return ErrTransformationIsEmpty
+	}
+	if t.fromId == latestDumpId {
+		dumpId, err := getLatestDumpId(ctx, t.dumpsSt)
+		if err != nil {
+			return err
+		}
+		if dumpId == "" {
+			return errors.New("no dumps found in storage")
+		}
+		t.fromId = dumpId
+	}
+	log.Info().
+		Str("FromDumpId", t.fromId).
+		Str("DumpId", t.dumpId).
+		Msg("transforming dump")
+
+	srcSt, err := GetDumpStorage(ctx, t.dumpsSt, t.fromId)
+	if err != nil {
+		return err
+	}
+	t.srcSt = srcSt
+
+	if err := custom.BootstrapCustomTransformers(ctx, t.registry, t.config.CustomTransformers); err != nil {
+		return fmt.Errorf("error bootstraping custom transformers: %w", err)
+	}
+
+	md, err := getDumpMetadata(ctx, t.dumpsSt, t.fromId)
+	if err != nil {
+		return err
+	}
+	err = validateOfflineTransformation(ctx, t.config.Dump.Transformation, md.DatabaseSchema, t.registry)
+	if err != nil {
+		return err
+	}
+	tocObj, err := t.readToc(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err = t.writeHeartBeat(ctx, HeartBeatInProgressContent); err != nil {
+		return fmt.Errorf("cannot write heartbeat: %w", err)
+	}
+
+	tasks, err := t.getTasks(ctx, md)
+	if err != nil {
+		return err
+	}
+	if err = t.transformFiles(ctx, tasks); err != nil {
+		return err
+	}
+	if err = t.copyOtherFiles(ctx, tasks); err != nil {
+		return err
+	}
+	if err = t.writeChunksPlaceholders(ctx, md); err != nil {
+		return err
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, 1024))
+	if err = toc.NewWriter(buf).Write(tocObj); err != nil {
+		return fmt.Errorf("error writing toc file: %w", err)
+	}
+	tocFileSize := int64(buf.Len())
+	tocChecksum := ioutils.ChecksumBytes(buf.Bytes())
+	if err = t.st.PutObject(ctx, tocFileName, buf); err != nil {
+		return fmt.Errorf("error writing toc file to the storage: %w", err)
+	}
+
+	if err = t.writeMetadata(ctx, md, startedAt, tocFileSize, tocChecksum); err != nil {
+		return err
+	}
+	if err = t.writeHeartBeat(ctx, HeartBeatDoneContent); err != nil {
+		return fmt.Errorf("cannot write heartbeat: %w", err)
+	}
+	return nil
+}
+
+func (t *Transform) readToc(ctx context.Context) (*toc.Toc, error) {
+	f, err := t.srcSt.GetObject(ctx, tocFileName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open toc file: %w", err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Warn().Err(err).Msg("error closing toc file")
+		}
+	}()
+	tocObj, err := toc.NewReader(f).Read()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read toc file: %w", err)
+	}
+	return tocObj, nil
+}
+
+// getTasks - creates the tasks for each table data file. The data of the incremental dump is taken from the
+// referenced dump and written into the new dump, so the new dump does not depend on other dumps
+func (t *Transform) getTasks(ctx context.Context, md *storageDto.Metadata) ([]*transformFileTask, error) {
+	var tasks []*transformFileTask
+	refStorages := newReferencedDumpStorages(t.dumpsSt)
+	for _, e := range md.Entries {
+		if e.ObjectType != toc.TableDataDesc || e.FileName == "" {
+			continue
+		}
+
+		var table *toolkit.Table
+		if oid, ok := md.DumpIdsToTableOid[e.DumpId]; ok {
+			idx := slices.IndexFunc(md.DatabaseSchema, func(t *toolkit.Table) bool {
+				return t.Oid == oid
+			})
+			if idx != -1 {
+				table = md.DatabaseSchema[idx]
+			}
+		}
+		var cfg *domains.Table
+		if table != nil {
+			cfg = domains.FindTable(t.config.Dump.Transformation, table.Schema, table.Name)
+		}
+
+		srcSt := t.srcSt
+		fileNames := []string{e.FileName}
+		srcFileNames := []string{e.FileName}
+		if len(e.Chunks) > 0 {
+			fileNames = e.Chunks
+			srcFileNames = e.Chunks
+		}
+		if ref, ok := md.References[e.DumpId]; ok {
+			refSt, err := refStorages.get(ctx, ref.DumpId)
+			if err != nil {
+				return nil, err
+			}
+			srcSt = refSt
+			if len(e.Chunks) == 0 {
+				srcFileNames = []string{ref.FileName}
+			} else {
+				srcFileNames = ref.GetChunks(e)
+				if len(ref.Chunks) == 0 {
+					// The dumps created before the referenced chunks were renamed store the chunks under the dump
+					// id of the referenced dump, so they are renamed to avoid the collision with the other chunks
+					fileNames = make([]string, 0, len(e.Chunks))
+					for idx, fileName := range e.Chunks {
+						fileNames = append(
+							fileNames, entries.ChunkFileName(e.DumpId, idx, ioutils.GetCodecByFileName(fileName)),
+						)
+					}
+					e.Chunks = fileNames
+				}
+			}
+		}
+
+		for i, fileName := range fileNames {
+			task := &transformFileTask{
+				entry:       e,
+				srcSt:       srcSt,
+				srcFileName: srcFileNames[i],
+				fileName:    fileName,
+			}
+			codecChanged := ioutils.GetCodecByFileName(task.srcFileName) != ioutils.GetCodecByFileName(fileName)
+			if cfg != nil || codecChanged {
+				var transformationTable *entries.Table
+				if cfg != nil {
+					// Each task gets its own transformers because the files are transformed in parallel
+					var warns toolkit.ValidationWarnings
+					var err error
+					transformationTable, warns, err = runtimeContext.NewOfflineTransformationTable(
+						ctx, table, cfg, t.registry,
+					)
+					if err != nil {
+						return nil, fmt.Errorf("cannot initialize transformers: %w", err)
+					}
+					if warns.IsFatal() {
+						return nil, fmt.Errorf(
+							"fatal validation error in transformers of table %s.%s", table.Schema, table.Name,
+						)
+					}
+				}
+				task.transformer = dumpers.NewTableDataTransformer(
+					transformationTable, srcSt, task.srcFileName, fileName, md.Header.CompressionLevel,
+					t.config.Dump.PgDumpOptions.Pgzip,
+				)
+			}
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, nil
+}
+
+// transformFiles - executes the tasks in parallel and updates the entries sizes of the transformed files
+func (t *Transform) transformFiles(ctx context.Context, tasks []*transformFileTask) error {
+	jobs := t.config.Dump.PgDumpOptions.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	eg, gtx := errgroup.WithContext(ctx)
+	eg.SetLimit(jobs)
+	for _, task := range tasks {
+		eg.Go(func() error {
+			if task.transformer == nil {
+				return t.copyObject(gtx, task.srcSt, task.srcFileName, task.fileName)
+			}
+			log.Debug().
+				Str("ObjectName", task.transformer.DebugInfo()).
+				Msg("transforming")
+			if err := task.transformer.Execute(gtx, t.st); err != nil {
+				return fmt.Errorf("cannot transform %s: %w", task.transformer.DebugInfo(), err)
+			}
+			t.setChecksum(task.fileName, task.transformer.Checksum)
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+
+	// The sizes are recalculated for the entries that have been rewritten. All the files of the entry are either
+	// copied or rewritten
+	sizes := make(map[*storageDto.Entry]*storageDto.ObjectSizeStat)
+	for _, task := range tasks {
+		if task.transformer == nil {
+			continue
+		}
+		s, ok := sizes[task.entry]
+		if !ok {
+			s = &storageDto.ObjectSizeStat{}
+			sizes[task.entry] = s
+		}
+		s.Original += task.transformer.OriginalSize
+		s.Compressed += task.transformer.CompressedSize
+	}
+	for e, s := range sizes {
+		e.OriginalSize = s.Original
+		e.CompressedSize = s.Compressed
+	}
+	return nil
+}
+
+// copyOtherFiles - copies the files of the source dump that are not table data, for instance large objects
+func (t *Transform) copyOtherFiles(ctx context.Context, tasks []*transformFileTask) error {
+	files, _, err := t.srcSt.ListDir(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot list dump files: %w", err)
+	}
+	skip := []string{MetadataJsonFileName, tocFileName, HeartBeatFileName, ProgressJsonFileName}
+	for _, task := range tasks {
+		skip = append(skip, task.fileName)
+		if len(task.entry.Chunks) > 0 {
+			// The placeholder of the chunked table is written by writeChunksPlaceholders
+			skip = append(skip, task.entry.FileName)
+		}
+	}
+
+	jobs := t.config.Dump.PgDumpOptions.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	eg, gtx := errgroup.WithContext(ctx)
+	eg.SetLimit(jobs)
+	for _, fileName := range files {
+		if slices.Contains(skip, fileName) {
+			continue
+		}
+		eg.Go(func() error {
+			return t.copyObject(gtx, t.srcSt, fileName, fileName)
+		})
+	}
+	return eg.Wait()
+}
+
+// writeChunksPlaceholders - writes the placeholders of the chunked tables. The dumps created by the previous versions
+// and the incremental dumps might not contain them
+func (t *Transform) writeChunksPlaceholders(ctx context.Context, md *storageDto.Metadata) error {
+	for _, e := range md.Entries {
+		if e.ObjectType != toc.TableDataDesc || len(e.Chunks) == 0 {
+			continue
+		}
+		if err := writeChunkPlaceholder(ctx, t.st, e.FileName, md.Header.CompressionLevel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyObject - copies the object into the new dump and stores its checksum
+func (t *Transform) copyObject(ctx context.Context, srcSt storages.Storager, srcFileName, fileName string) error {
+	log.Debug().
+		Str("FileName", fileName).
+		Msg("copying")
+	src, err := srcSt.GetObject(ctx, srcFileName)
+	if err != nil {
+		return fmt.Errorf("cannot open %s: %w", srcFileName, err)
+	}
+	r := ioutils.NewChecksumReader(src)
+	defer func() {
+		if err := r.Close(); err != nil {
+			log.Warn().Err(err).Msg("error closing source object")
+		}
+	}()
+	if err = t.st.PutObject(ctx, fileName, r); err != nil {
+		return fmt.Errorf("cannot write %s: %w", fileName, err)
+	}
+	t.setChecksum(fileName, r.GetChecksum())
+	return nil
+}
+
+func (t *Transform) setChecksum(fileName, checksum string) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.checksums[fileName] = checksum
+}
+
+// writeMetadata - writes metadata of the new dump. It is based on the source dump metadata with the updated sizes
+// and checksums. The new dump does not reference other dumps
+func (t *Transform) writeMetadata(
+	ctx context.Context, src *storageDto.Metadata, startedAt time.Time, tocFileSize int64, tocChecksum string,
+) error {
+	md := *src
+	md.StartedAt = startedAt
+	md.CompletedAt = time.Now()
+	md.Description = t.config.Dump.PgDumpOptions.Description
+	md.Transformers = append(slices.Clone(src.Transformers), t.config.Dump.Transformation...)
+	// The data is transformed by another config, so the incremental dump must not reuse it
+	md.TransformationChecksum = ""
+	md.IncrementalFrom = ""
+	md.References = nil
+	md.Header.TocFileSize = tocFileSize
+	md.Header.TocChecksum = tocChecksum
+
+	md.OriginalSize = tocFileSize
+	md.CompressedSize = tocFileSize
+	for _, e := range md.Entries {
+		if e.ObjectType == toc.TableDataDesc && e.FileName != "" {
+			fileNames := []string{e.FileName}
+			if len(e.Chunks) > 0 {
+				fileNames = e.Chunks
+			}
+			e.Checksums = make(map[string]string, len(fileNames))
+			for _, fileName := range fileNames {
+				e.Checksums[fileName] = t.checksums[fileName]
+			}
+			md.OriginalSize += e.OriginalSize
+			md.CompressedSize += e.CompressedSize
+		} else if len(e.Checksums) > 0 {
+			checksums := maps.Clone(e.Checksums)
+			for fileName := range checksums {
+				if checksum, ok := t.checksums[fileName]; ok {
+					checksums[fileName] = checksum
+				}
+			}
+			e.Checksums = checksums
+		}
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, 1024))
+	if err := json.NewEncoder(buf).Encode(&md); err != nil {
+		return fmt.Errorf("error encoding metadata.json: %w", err)
+	}
+	if err := t.st.PutObject(ctx, MetadataJsonFileName, buf); err != nil {
+		return fmt.Errorf("error writing metadata to the storage: %w", err)
+	}
+	return nil
+}
+
+// writeHeartBeat - write data in heart beat file
+func (t *Transform) writeHeartBeat(ctx context.Context, data string) error {
+	return t.st.PutObject(ctx, HeartBeatFileName, bytes.NewBufferString(data))
+}
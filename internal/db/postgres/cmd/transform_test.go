@@ -0,0 +1,355 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	storageDto "github.com/greenmaskio/greenmask/internal/db/postgres/storage"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/toc"
+	transformersUtils "github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/storages/directory"
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+func gzipBytes(t *testing.T, data string) []byte {
+	buf := new(bytes.Buffer)
+	w := gzip.NewWriter(buf)
+	_, err := w.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func newTransformTestToc(t *testing.T) []byte {
+	tableDataEntry := func(dumpId int32, name, fileName string) *toc.Entry {
+		return &toc.Entry{
+			DumpId:    dumpId,
+			Section:   toc.SectionData,
+			HadDumper: 1,
+			Tag:       toc.NewObj(name),
+			Namespace: toc.NewObj("public"),
+			Owner:     toc.NewObj("postgres"),
+			Desc:      toc.NewObj(toc.TableDataDesc),
+			Defn:      toc.NewObj(""),
+			DropStmt:  toc.NewObj(""),
+			CopyStmt:  toc.NewObj("COPY public." + name + " (id, email) FROM stdin;\n"),
+			FileName:  toc.NewObj(fileName),
+		}
+	}
+	tocObj := &toc.Toc{
+		Header: &toc.Header{
+			VersionMajor:         1,
+			VersionMinor:         14,
+			Version:              toc.BackupVersions["1.14"],
+			IntSize:              4,
+			OffSize:              8,
+			Format:               toc.ArchTar,
+			ArchDbName:           toc.NewObj("test"),
+			ArchiveRemoteVersion: toc.NewObj("16.0"),
+			ArchiveDumpVersion:   toc.NewObj("16.0"),
+			TocCount:             2,
+			MaxDumpId:            11,
+		},
+		Entries: []*toc.Entry{
+			tableDataEntry(10, "users", "10.dat.gz"),
+			tableDataEntry(11, "orders", "11.dat.gz"),
+		},
+	}
+	buf := new(bytes.Buffer)
+	require.NoError(t, toc.NewWriter(buf).Write(tocObj))
+	return buf.Bytes()
+}
+
+// newTransformTestDumps - creates two dumps. The second one is incremental and references the data of table users
+// in the first one
+func newTransformTestDumps(t *testing.T) storages.Storager {
+	ctx := context.Background()
+	st, err := directory.NewStorage(&directory.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	put := func(dumpId, name string, data []byte) {
+		require.NoError(t, st.SubStorage(dumpId, true).PutObject(ctx, name, bytes.NewReader(data)))
+	}
+	columns := []*toolkit.Column{
+		{Idx: 0, Name: "id", TypeName: "int4", TypeOid: toolkit.Oid(pgtype.Int4OID), Num: 1},
+		{Idx: 1, Name: "email", TypeName: "text", TypeOid: toolkit.Oid(pgtype.TextOID), Num: 2},
+	}
+	tocData := newTransformTestToc(t)
+	users := gzipBytes(t, "1\talice@example.com\n2\t\\N\n\\.\n\n")
+	orders := gzipBytes(t, "1\torder@example.com\n\\.\n\n")
+	put("1", "10.dat.gz", users)
+	put("2", tocFileName, tocData)
+	put("2", "11.dat.gz", orders)
+	put("2", "blobs.toc", []byte("1 blob_1.dat\n"))
+	put("2", HeartBeatFileName, []byte(HeartBeatDoneContent))
+
+	md := &storageDto.Metadata{
+		Header: storageDto.Header{TocChecksum: ioutils.ChecksumBytes(tocData)},
+		DatabaseSchema: toolkit.DatabaseSchema{
+			{Schema: "public", Name: "users", Oid: 100, Columns: columns},
+			{Schema: "public", Name: "orders", Oid: 101, Columns: columns},
+		},
+		Entries: []*storageDto.Entry{
+			{
+				DumpId: 10, ObjectType: toc.TableDataDesc, FileName: "10.dat.gz", OriginalSize: 1, CompressedSize: 1,
+				Checksums: map[string]string{"10.dat.gz": ioutils.ChecksumBytes(users)},
+			},
+			{
+				DumpId: 11, ObjectType: toc.TableDataDesc, FileName: "11.dat.gz", OriginalSize: 2, CompressedSize: 2,
+				Checksums: map[string]string{"11.dat.gz": ioutils.ChecksumBytes(orders)},
+			},
+			{
+				DumpId: 12, ObjectType: "BLOBS",
+				Checksums: map[string]string{"blobs.toc": ioutils.ChecksumBytes([]byte("1 blob_1.dat\n"))},
+			},
+		},
+		DumpIdsToTableOid:      map[int32]toolkit.Oid{10: 100, 11: 101},
+		TransformationChecksum: "checksum",
+		IncrementalFrom:        "1",
+		References: map[int32]*storageDto.ObjectReference{
+			10: {DumpId: "1", FileName: "10.dat.gz"},
+		},
+	}
+	data, err := json.Marshal(md)
+	require.NoError(t, err)
+	put("2", MetadataJsonFileName, data)
+	return st
+}
+
+func readGzipObject(t *testing.T, st storages.Storager, name string) string {
+	f, err := st.GetObject(context.Background(), name)
+	require.NoError(t, err)
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestTransform_Run(t *testing.T) {
+	ctx := context.Background()
+	st := newTransformTestDumps(t)
+	cfg := &domains.Config{
+		Dump: domains.Dump{
+			Transformation: []*domains.Table{
+				{
+					Schema: "public",
+					Name:   "users",
+					Transformers: []*domains.TransformerConfig{
+						{
+							Name: "Replace",
+							Params: toolkit.StaticParameters{
+								"column": toolkit.ParamsValue("email"),
+								"value":  toolkit.ParamsValue("masked@example.com"),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	cfg.Dump.PgDumpOptions.Jobs = 2
+	cfg.Dump.PgDumpOptions.Description = "masked"
+
+	require.NoError(t, NewTransform(cfg, st, "latest", "3", transformersUtils.DefaultTransformerRegistry).Run(ctx))
+
+	dst := st.SubStorage("3", true)
+	assert.Equal(t, "1\tmasked@example.com\n2\t\\N\n\\.\n\n", readGzipObject(t, dst, "10.dat.gz"))
+	assert.Equal(t, "1\torder@example.com\n\\.\n\n", readGzipObject(t, dst, "11.dat.gz"))
+
+	f, err := dst.GetObject(ctx, HeartBeatFileName)
+	require.NoError(t, err)
+	heartBeat, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, HeartBeatDoneContent, string(heartBeat))
+
+	md, err := getDumpMetadata(ctx, st, "3")
+	require.NoError(t, err)
+	assert.Equal(t, "masked", md.Description)
+	assert.Empty(t, md.References)
+	assert.Empty(t, md.IncrementalFrom)
+	assert.Empty(t, md.TransformationChecksum)
+	require.Len(t, md.Transformers, 1)
+	assert.Equal(t, "users", md.Transformers[0].Name)
+	users, ok := md.GetEntry(10)
+	require.True(t, ok)
+	assert.Equal(t, int64(len("1\tmasked@example.com\n2\t\\N\n\\.\n\n")), users.OriginalSize)
+	orders, ok := md.GetEntry(11)
+	require.True(t, ok)
+	assert.Equal(t, int64(2), orders.OriginalSize)
+
+	// The new dump is self-contained and its checksums are valid
+	results, err := NewVerify(st, "3", 1).Run(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, GetFailedObjects(results))
+	assert.Equal(t, map[string]string{
+		"3/toc.dat":   VerifyStatusOk,
+		"3/10.dat.gz": VerifyStatusOk,
+		"3/11.dat.gz": VerifyStatusOk,
+		"3/blobs.toc": VerifyStatusOk,
+	}, getVerificationStatuses(results))
+}
+
+func TestTransform_Run_tar(t *testing.T) {
+	ctx := context.Background()
+	st := newTransformTestDumps(t)
+	// Both the transformed dump and the dump referenced by it are tar dumps
+	streamTarTestDump(t, st, "1")
+	streamTarTestDump(t, st, "2")
+	cfg := &domains.Config{
+		Dump: domains.Dump{
+			Transformation: []*domains.Table{
+				{
+					Schema: "public",
+					Name:   "users",
+					Transformers: []*domains.TransformerConfig{
+						{
+							Name: "Replace",
+							Params: toolkit.StaticParameters{
+								"column": toolkit.ParamsValue("email"),
+								"value":  toolkit.ParamsValue("masked@example.com"),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	require.NoError(t, NewTransform(cfg, st, "2", "3", transformersUtils.DefaultTransformerRegistry).Run(ctx))
+
+	dst := st.SubStorage("3", true)
+	assert.Equal(t, "1\tmasked@example.com\n2\t\\N\n\\.\n\n", readGzipObject(t, dst, "10.dat.gz"))
+	assert.Equal(t, "1\torder@example.com\n\\.\n\n", readGzipObject(t, dst, "11.dat.gz"))
+	results, err := NewVerify(st, "3", 1).Run(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, GetFailedObjects(results))
+}
+
+func TestTransform_Run_referenced_chunks(t *testing.T) {
+	// The chunk 11.0 of table users is stored in the referenced dump 1. Dump 2 stores the chunk 11.0 of table orders.
+	// The dumps of the previous versions referenced the chunks by their names in the entry
+	for _, legacy := range []bool{false, true} {
+		t.Run(fmt.Sprintf("legacy %t", legacy), func(t *testing.T) {
+			ctx := context.Background()
+			st, err := directory.NewStorage(&directory.Config{Path: t.TempDir()})
+			require.NoError(t, err)
+			put := func(dumpId, name string, data []byte) {
+				require.NoError(t, st.SubStorage(dumpId, true).PutObject(ctx, name, bytes.NewReader(data)))
+			}
+			columns := []*toolkit.Column{
+				{Idx: 0, Name: "id", TypeName: "int4", TypeOid: toolkit.Oid(pgtype.Int4OID), Num: 1},
+				{Idx: 1, Name: "email", TypeName: "text", TypeOid: toolkit.Oid(pgtype.TextOID), Num: 2},
+			}
+			tocData := newTransformTestToc(t)
+			users := gzipBytes(t, "1\talice@example.com\n\\.\n\n")
+			orders := gzipBytes(t, "1\torder@example.com\n\\.\n\n")
+			put("1", "11.0.dat.gz", users)
+			put("2", tocFileName, tocData)
+			put("2", "11.0.dat.gz", orders)
+			put("2", HeartBeatFileName, []byte(HeartBeatDoneContent))
+
+			usersEntry := &storageDto.Entry{
+				DumpId: 10, ObjectType: toc.TableDataDesc, FileName: "10.dat.gz", Chunks: []string{"10.0.dat.gz"},
+				Checksums: map[string]string{"11.0.dat.gz": ioutils.ChecksumBytes(users)},
+			}
+			ref := &storageDto.ObjectReference{DumpId: "1", FileName: "10.dat.gz", Chunks: []string{"11.0.dat.gz"}}
+			if legacy {
+				usersEntry.Chunks, ref.Chunks = []string{"11.0.dat.gz"}, nil
+			}
+			md := &storageDto.Metadata{
+				Header: storageDto.Header{TocChecksum: ioutils.ChecksumBytes(tocData)},
+				DatabaseSchema: toolkit.DatabaseSchema{
+					{Schema: "public", Name: "users", Oid: 100, Columns: columns},
+					{Schema: "public", Name: "orders", Oid: 101, Columns: columns},
+				},
+				Entries: []*storageDto.Entry{
+					usersEntry,
+					{
+						DumpId: 11, ObjectType: toc.TableDataDesc, FileName: "11.dat.gz",
+						Chunks:    []string{"11.0.dat.gz"},
+						Checksums: map[string]string{"11.0.dat.gz": ioutils.ChecksumBytes(orders)},
+					},
+				},
+				DumpIdsToTableOid: map[int32]toolkit.Oid{10: 100, 11: 101},
+				IncrementalFrom:   "1",
+				References:        map[int32]*storageDto.ObjectReference{10: ref},
+			}
+			data, err := json.Marshal(md)
+			require.NoError(t, err)
+			put("2", MetadataJsonFileName, data)
+
+			cfg := &domains.Config{
+				Dump: domains.Dump{
+					Transformation: []*domains.Table{
+						{
+							Schema: "public",
+							Name:   "users",
+							Transformers: []*domains.TransformerConfig{
+								{
+									Name: "Replace",
+									Params: toolkit.StaticParameters{
+										"column": toolkit.ParamsValue("email"),
+										"value":  toolkit.ParamsValue("masked@example.com"),
+									},
+								},
+							},
+						},
+					},
+				},
+			}
+			require.NoError(t, NewTransform(cfg, st, "2", "3", transformersUtils.DefaultTransformerRegistry).Run(ctx))
+
+			dst := st.SubStorage("3", true)
+			assert.Equal(t, "1\tmasked@example.com\n\\.\n\n", readGzipObject(t, dst, "10.0.dat.gz"))
+			assert.Equal(t, "1\torder@example.com\n\\.\n\n", readGzipObject(t, dst, "11.0.dat.gz"))
+			// The placeholders of the chunked tables are readable by pg_restore
+			assert.Equal(t, "\\.\n\n", readGzipObject(t, dst, "10.dat.gz"))
+			assert.Equal(t, "\\.\n\n", readGzipObject(t, dst, "11.dat.gz"))
+
+			res, err := getDumpMetadata(ctx, st, "3")
+			require.NoError(t, err)
+			usersRes, ok := res.GetEntry(10)
+			require.True(t, ok)
+			assert.Equal(t, []string{"10.0.dat.gz"}, usersRes.Chunks)
+			results, err := NewVerify(st, "3", 1).Run(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, GetFailedObjects(results))
+		})
+	}
+}
+
+func TestTransform_Run_EmptyTransformation(t *testing.T) {
+	st := newTransformTestDumps(t)
+	err := NewTransform(&domains.Config{}, st, "2", "3", transformersUtils.DefaultTransformerRegistry).
+		Run(context.Background())
+	require.ErrorIs(t, err, ErrTransformationIsEmpty)
+}
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"bufio"
-	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
@@ -26,6 +25,7 @@ import (
 	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
 	"github.com/greenmaskio/greenmask/internal/domains"
 	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
 	"github.com/greenmaskio/greenmask/internal/utils/reader"
 	"github.com/greenmaskio/greenmask/pkg/toolkit"
 )
@@ -204,17 +204,17 @@ func (v *Validate) getDocument(table *entries.Table) validate_utils.Documenter {
 }
 
 func (v *Validate) getReader(ctx context.Context, table *entries.Table) (closeFunc, *bufio.Reader, error) {
-	tableData, err := v.st.GetObject(ctx, fmt.Sprintf("%d.dat.gz", table.DumpId))
+	tableData, err := v.st.GetObject(ctx, table.DataFileName())
 	if err != nil {
 		return nil, nil, fmt.Errorf("unable to get object from storage: %w", err)
 	}
 
-	gz, err := gzip.NewReader(tableData)
+	gz, err := ioutils.GetCompressionReadCloser(tableData, table.Codec, false)
 	if err != nil {
 		if err := tableData.Close(); err != nil {
 			log.Warn().Err(err).Msg("error closing table data")
 		}
-		return nil, nil, fmt.Errorf("cannot create gzip reader: %w", err)
+		return nil, nil, fmt.Errorf("cannot create %s reader: %w", ioutils.GetCodec(table.Codec), err)
 	}
 
 	f := func() {
@@ -222,7 +222,7 @@ func (v *Validate) getReader(ctx context.Context, table *entries.Table) (closeFu
 			log.Warn().Err(err).Msg("caused error when closing reader object")
 		}
 		if err := gz.Close(); err != nil {
-			log.Warn().Err(err).Msg("caused error when closing gzip reader")
+			log.Warn().Err(err).Msg("caused error when closing decompression reader")
 		}
 	}
 
@@ -322,7 +322,7 @@ func (v *Validate) diffWithPreviousSchema(ctx context.Context) error {
 		return nil
 	}
 
-	dumpId, err := v.getPreviousDumpId(ctx)
+	dumpId, err := getLatestDumpId(ctx, v.mainSt)
 	if err != nil {
 		return fmt.Errorf("cannot get previous dump id: %w", err)
 	}
@@ -330,7 +330,7 @@ func (v *Validate) diffWithPreviousSchema(ctx context.Context) error {
 		return nil
 	}
 
-	md, err := v.getPreviousMetadata(ctx, dumpId)
+	md, err := getDumpMetadata(ctx, v.mainSt, dumpId)
 	if err != nil {
 		return fmt.Errorf("cannot get previous metadata: %w", err)
 	}
@@ -362,6 +362,11 @@ func (v *Validate) printSchemaDiff(diff []*toolkit.DiffNode, previousDumpId stri
 			Msg("Database schema has been changed")
 		return nil
 	}
+	logSchemaDiff(diff, previousDumpId)
+	return nil
+}
+
+func logSchemaDiff(diff []*toolkit.DiffNode, previousDumpId string) {
 	log.Warn().
 		Str("PreviousDumpId", previousDumpId).
 		Str("Hint", "Check schema changes before making new dump").
@@ -373,17 +378,25 @@ func (v *Validate) printSchemaDiff(diff []*toolkit.DiffNode, previousDumpId stri
 			Any("Signature", node.Signature).
 			Msg(toolkit.DiffEventMsgs[node.Event])
 	}
-
-	return nil
 }
 
-func (v *Validate) getPreviousDumpId(ctx context.Context) (string, error) {
-	var backupNames []string
+// GetLatestDumpId - returns the id of the latest dump that has the metadata. The directory and tar dumps are
+// considered. Empty string is returned if there are no such dumps
+func GetLatestDumpId(ctx context.Context, st storages.Storager) (string, error) {
+	return getLatestDumpId(ctx, st)
+}
 
-	_, dirs, err := v.mainSt.ListDir(ctx)
+// getLatestDumpId - returns the id of the latest dump that has the metadata. Empty string is returned if there are no
+// such dumps
+func getLatestDumpId(ctx context.Context, st storages.Storager) (string, error) {
+	files, dirs, err := st.ListDir(ctx)
 	if err != nil {
 		return "", fmt.Errorf("cannot walk through directory: %w", err)
 	}
+	backupNames, err := getTarDumpIds(ctx, files, st)
+	if err != nil {
+		return "", err
+	}
 	for _, dir := range dirs {
 		exists, err := dir.Exists(ctx, MetadataJsonFileName)
 		if err != nil {
@@ -408,11 +421,13 @@ func (v *Validate) getPreviousDumpId(ctx context.Context) (string, error) {
 	return "", nil
 }
 
-func (v *Validate) getPreviousMetadata(ctx context.Context, dumpId string) (*storageDto.Metadata, error) {
-
-	st := v.mainSt.SubStorage(dumpId, true)
-
-	f, err := st.GetObject(ctx, MetadataJsonFileName)
+// getDumpMetadata - reads the metadata of the dump with the provided id
+func getDumpMetadata(ctx context.Context, st storages.Storager, dumpId string) (*storageDto.Metadata, error) {
+	dumpSt, err := GetDumpStorage(ctx, st, dumpId)
+	if err != nil {
+		return nil, err
+	}
+	f, err := dumpSt.GetObject(ctx, MetadataJsonFileName)
 	if err != nil {
 		return nil, fmt.Errorf("cannot open metadata file: %w", err)
 	}
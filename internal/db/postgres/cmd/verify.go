@@ -0,0 +1,369 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+
+	storageDto "github.com/greenmaskio/greenmask/internal/db/postgres/storage"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/toc"
+	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
+)
+
+const (
+	VerifyStatusOk         = "ok"
+	VerifyStatusCorrupted  = "corrupted"
+	VerifyStatusMissing    = "missing"
+	VerifyStatusNoChecksum = "no checksum"
+	VerifyStatusError      = "error"
+)
+
+const (
+	tocFileName  = "toc.dat"
+	latestDumpId = "latest"
+)
+
+const (
+	VerifyChecksumsBefore = "before"
+	VerifyChecksumsDuring = "during"
+	VerifyChecksumsBoth   = "both"
+)
+
+var ErrDumpIsCorrupted = errors.New("dump is corrupted")
+
+// ObjectVerification - the result of the dump object verification
+type ObjectVerification struct {
+	// DumpId - the TOC entry DumpId. It is 0 for toc.dat
+	DumpId int32 `json:"dumpId"`
+	// StoredIn - id of the dump that contains the object. It differs from the verified dump for the objects of the
+	// incremental dump that are stored in the previous dumps
+	StoredIn string `json:"storedIn"`
+	FileName string `json:"fileName"`
+	Status   string `json:"status"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// IsFailed - returns true if the object is corrupted, missing or cannot be read
+func (ov *ObjectVerification) IsFailed() bool {
+	return ov.Status != VerifyStatusOk && ov.Status != VerifyStatusNoChecksum
+}
+
+type verificationTask struct {
+	st     storages.Storager
+	result *ObjectVerification
+}
+
+// Verify - re-reads the dump objects from the storage and compares their SHA-256 checksums with the ones stored in
+// metadata.json
+type Verify struct {
+	st      storages.Storager
+	dumpsSt storages.Storager
+	dumpId  string
+	jobs    int
+}
+
+// NewVerify - creates verification of the dump. dumpsSt is the storage that contains all the dumps, it is used for
+// resolving the references of incremental dumps
+func NewVerify(dumpsSt storages.Storager, dumpId string, jobs int) *Verify {
+	return &Verify{
+		dumpsSt: dumpsSt,
+		dumpId:  dumpId,
+		jobs:    jobs,
+	}
+}
+
+// Run - verifies the dump objects. The dump id "latest" is resolved to the latest completed dump
+func (v *Verify) Run(ctx context.Context) ([]*ObjectVerification, error) {
+	if v.dumpId == latestDumpId {
+		dumpId, err := getLatestDumpId(ctx, v.dumpsSt)
+		if err != nil {
+			return nil, err
+		}
+		if dumpId == "" {
+			return nil, errors.New("no dumps found in storage")
+		}
+		v.dumpId = dumpId
+	}
+	log.Info().Str("DumpId", v.dumpId).Msg("verifying dump")
+
+	st, err := GetDumpStorage(ctx, v.dumpsSt, v.dumpId)
+	if err != nil {
+		return nil, err
+	}
+	v.st = st
+
+	md, err := getDumpMetadata(ctx, v.dumpsSt, v.dumpId)
+	if err != nil {
+		return nil, err
+	}
+	return v.verifyMetadata(ctx, md)
+}
+
+func (v *Verify) verifyMetadata(ctx context.Context, md *storageDto.Metadata) ([]*ObjectVerification, error) {
+	tasks, err := v.getTasks(ctx, md)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := v.jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	eg, gtx := errgroup.WithContext(ctx)
+	eg.SetLimit(jobs)
+	for _, t := range tasks {
+		eg.Go(func() error {
+			verifyObject(gtx, t.st, t.result)
+			if gtx.Err() != nil {
+				return gtx.Err()
+			}
+			log.Debug().
+				Int32("DumpId", t.result.DumpId).
+				Str("FileName", t.result.FileName).
+				Str("Status", t.result.Status).
+				Msg("object verified")
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	res := make([]*ObjectVerification, 0, len(tasks))
+	for _, t := range tasks {
+		res = append(res, t.result)
+	}
+	return res, nil
+}
+
+// getTasks - collects toc.dat and the data files of the entries. The referenced objects are read from the directory or
+// the tar object of the dump that stores them
+func (v *Verify) getTasks(ctx context.Context, md *storageDto.Metadata) ([]*verificationTask, error) {
+	tasks := []*verificationTask{
+		{
+			st: v.st,
+			result: &ObjectVerification{
+				StoredIn: v.dumpId,
+				FileName: tocFileName,
+				Expected: md.Header.TocChecksum,
+			},
+		},
+	}
+	refStorages := newReferencedDumpStorages(v.dumpsSt)
+	for _, e := range md.Entries {
+		st, storedIn := v.st, v.dumpId
+		if ref, ok := md.References[e.DumpId]; ok {
+			refSt, err := refStorages.get(ctx, ref.DumpId)
+			if err != nil {
+				return nil, err
+			}
+			st, storedIn = refSt, ref.DumpId
+		}
+
+		fileNames := make([]string, 0, len(e.Checksums))
+		for fileName := range e.Checksums {
+			fileNames = append(fileNames, fileName)
+		}
+		// The data files of the dumps created before the checksums were introduced are checked for existence only
+		if len(fileNames) == 0 && e.ObjectType == toc.TableDataDesc && e.FileName != "" {
+			fileName := e.FileName
+			if ref, ok := md.References[e.DumpId]; ok {
+				fileName = ref.FileName
+			}
+			fileNames = append(fileNames, fileName)
+		}
+		slices.Sort(fileNames)
+
+		for _, fileName := range fileNames {
+			tasks = append(tasks, &verificationTask{
+				st: st,
+				result: &ObjectVerification{
+					DumpId:   e.DumpId,
+					StoredIn: storedIn,
+					FileName: fileName,
+					Expected: e.Checksums[fileName],
+				},
+			})
+		}
+	}
+	return tasks, nil
+}
+
+func verifyObject(ctx context.Context, st storages.Storager, res *ObjectVerification) {
+	r, err := st.GetObject(ctx, res.FileName)
+	if err != nil {
+		if errors.Is(err, storages.ErrFileNotFound) || isNotFoundErr(st, res.FileName) {
+			res.Status = VerifyStatusMissing
+			return
+		}
+		res.Status = VerifyStatusError
+		res.Error = err.Error()
+		return
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			log.Warn().Err(err).Str("FileName", res.FileName).Msg("error closing object")
+		}
+	}()
+
+	actual, _, err := ioutils.Checksum(r)
+	if err != nil {
+		res.Status = VerifyStatusError
+		res.Error = err.Error()
+		return
+	}
+	res.Actual = actual
+	switch {
+	case res.Expected == "":
+		res.Status = VerifyStatusNoChecksum
+	case res.Expected != actual:
+		res.Status = VerifyStatusCorrupted
+	default:
+		res.Status = VerifyStatusOk
+	}
+}
+
+// isNotFoundErr - not every storage returns storages.ErrFileNotFound, so the existence is checked explicitly
+func isNotFoundErr(st storages.Storager, fileName string) bool {
+	stat, err := st.Stat(fileName)
+	if err != nil {
+		return false
+	}
+	return !stat.Exist
+}
+
+// GetFailedObjects - returns the objects that failed the verification
+func GetFailedObjects(results []*ObjectVerification) []*ObjectVerification {
+	var res []*ObjectVerification
+	for _, r := range results {
+		if r.IsFailed() {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// ValidateVerifyChecksumsMode - checks the restore.verify_checksums value
+func ValidateVerifyChecksumsMode(mode string) error {
+	switch mode {
+	case "", VerifyChecksumsBefore, VerifyChecksumsDuring, VerifyChecksumsBoth:
+		return nil
+	}
+	return fmt.Errorf(
+		"unknown verify checksums mode \"%s\": expected one of %s",
+		mode, strings.Join([]string{VerifyChecksumsBefore, VerifyChecksumsDuring, VerifyChecksumsBoth}, ", "),
+	)
+}
+
+// checksumStorage - the storage decorator that verifies the checksums of the objects while they are being read. The
+// mismatch is reported instead of io.EOF, so the restoration of the object fails
+type checksumStorage struct {
+	storages.Storager
+	checksums map[string]string
+}
+
+func newChecksumStorage(st storages.Storager, checksums map[string]string) *checksumStorage {
+	return &checksumStorage{
+		Storager:  st,
+		checksums: checksums,
+	}
+}
+
+func (s *checksumStorage) GetObject(ctx context.Context, filePath string) (io.ReadCloser, error) {
+	r, err := s.Storager.GetObject(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	expected, ok := s.checksums[filePath]
+	if !ok {
+		return r, nil
+	}
+	return ioutils.NewVerifyingReader(r, filePath, expected), nil
+}
+
+// setupChecksumsVerification - verifies all the dump objects before the restoration and wraps the storage for the
+// verification of the objects while they are being restored according to restore.verify_checksums
+func (r *Restore) setupChecksumsVerification(ctx context.Context) error {
+	mode := r.cfg.VerifyChecksums
+	if err := ValidateVerifyChecksumsMode(mode); err != nil {
+		return err
+	}
+	if mode == "" {
+		return nil
+	}
+	if len(r.metadata.References) > 0 && r.dumpsSt == nil {
+		return ErrDumpsStorageIsNotSet
+	}
+
+	if mode == VerifyChecksumsBefore || mode == VerifyChecksumsBoth {
+		log.Info().Msg("verifying dump checksums")
+		v := &Verify{
+			st:      r.st,
+			dumpsSt: r.dumpsSt,
+			dumpId:  r.st.Dirname(),
+			jobs:    r.restoreOpt.Jobs,
+		}
+		results, err := v.verifyMetadata(ctx, r.metadata)
+		if err != nil {
+			return err
+		}
+		if failed := GetFailedObjects(results); len(failed) > 0 {
+			for _, f := range failed {
+				log.Error().
+					Int32("DumpId", f.DumpId).
+					Str("StoredIn", f.StoredIn).
+					Str("FileName", f.FileName).
+					Str("Status", f.Status).
+					Str("Error", f.Error).
+					Msg("object verification failed")
+			}
+			return fmt.Errorf("%w: %d objects failed verification", ErrDumpIsCorrupted, len(failed))
+		}
+		log.Info().Int("ObjectsCount", len(results)).Msg("dump checksums are valid")
+	}
+
+	if r.isVerifyChecksumsDuring() {
+		checksums := make(map[string]string)
+		if r.metadata.Header.TocChecksum != "" {
+			checksums[tocFileName] = r.metadata.Header.TocChecksum
+		}
+		for _, e := range r.metadata.Entries {
+			if _, ok := r.metadata.References[e.DumpId]; ok {
+				// The referenced objects are verified in resolveTableData
+				continue
+			}
+			for fileName, checksum := range e.Checksums {
+				checksums[fileName] = checksum
+			}
+		}
+		r.st = newChecksumStorage(r.st, checksums)
+	}
+	return nil
+}
+
+func (r *Restore) isVerifyChecksumsDuring() bool {
+	return r.cfg.VerifyChecksums == VerifyChecksumsDuring || r.cfg.VerifyChecksums == VerifyChecksumsBoth
+}
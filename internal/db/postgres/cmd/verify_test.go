@@ -0,0 +1,192 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	storageDto "github.com/greenmaskio/greenmask/internal/db/postgres/storage"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/storages/directory"
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
+)
+
+// newVerifyTestDumps - creates two dumps. The second one is incremental and references the table data of the first
+func newVerifyTestDumps(t *testing.T) storages.Storager {
+	ctx := context.Background()
+	st, err := directory.NewStorage(&directory.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	put := func(dumpId, name string, data []byte) {
+		require.NoError(t, st.SubStorage(dumpId, true).PutObject(ctx, name, bytes.NewReader(data)))
+	}
+	putMetadata := func(dumpId string, md *storageDto.Metadata) {
+		data, err := json.Marshal(md)
+		require.NoError(t, err)
+		put(dumpId, MetadataJsonFileName, data)
+	}
+
+	put("1", "toc.dat", []byte("toc1"))
+	put("1", "10.dat.gz", []byte("table 10"))
+	put("1", "11.dat.gz", []byte("table 11"))
+	putMetadata("1", &storageDto.Metadata{
+		Header: storageDto.Header{TocChecksum: ioutils.ChecksumBytes([]byte("toc1"))},
+		Entries: []*storageDto.Entry{
+			{
+				DumpId: 10, ObjectType: "TABLE DATA", FileName: "10.dat.gz",
+				Checksums: map[string]string{"10.dat.gz": ioutils.ChecksumBytes([]byte("table 10"))},
+			},
+			{
+				DumpId: 11, ObjectType: "TABLE DATA", FileName: "11.dat.gz",
+				Checksums: map[string]string{"11.dat.gz": ioutils.ChecksumBytes([]byte("table 11"))},
+			},
+			{DumpId: 12, ObjectType: "SEQUENCE SET"},
+		},
+	})
+
+	put("2", "toc.dat", []byte("toc2"))
+	put("2", "11.dat.gz", []byte("table 11 v2"))
+	put("2", "blob_1.dat.gz", []byte("blob 1"))
+	put("2", "blobs.toc", []byte("1 blob_1.dat\n"))
+	putMetadata("2", &storageDto.Metadata{
+		Header: storageDto.Header{TocChecksum: ioutils.ChecksumBytes([]byte("toc2"))},
+		Entries: []*storageDto.Entry{
+			{
+				DumpId: 10, ObjectType: "TABLE DATA", FileName: "10.dat.gz",
+				Checksums: map[string]string{"10.dat.gz": ioutils.ChecksumBytes([]byte("table 10"))},
+			},
+			{
+				DumpId: 11, ObjectType: "TABLE DATA", FileName: "11.dat.gz",
+				Checksums: map[string]string{"11.dat.gz": ioutils.ChecksumBytes([]byte("table 11 v2"))},
+			},
+			{
+				DumpId: 13, ObjectType: "BLOBS",
+				Checksums: map[string]string{
+					"blob_1.dat.gz": ioutils.ChecksumBytes([]byte("blob 1")),
+					"blobs.toc":     ioutils.ChecksumBytes([]byte("1 blob_1.dat\n")),
+				},
+			},
+		},
+		References: map[int32]*storageDto.ObjectReference{
+			10: {DumpId: "1", FileName: "10.dat.gz"},
+		},
+	})
+	return st
+}
+
+func getVerificationStatuses(results []*ObjectVerification) map[string]string {
+	res := make(map[string]string)
+	for _, r := range results {
+		res[r.StoredIn+"/"+r.FileName] = r.Status
+	}
+	return res
+}
+
+func TestVerify_Run(t *testing.T) {
+	ctx := context.Background()
+	st := newVerifyTestDumps(t)
+
+	t.Run("valid dump", func(t *testing.T) {
+		results, err := NewVerify(st, "latest", 2).Run(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, GetFailedObjects(results))
+		assert.Equal(t, map[string]string{
+			"2/toc.dat":       VerifyStatusOk,
+			"1/10.dat.gz":     VerifyStatusOk,
+			"2/11.dat.gz":     VerifyStatusOk,
+			"2/blob_1.dat.gz": VerifyStatusOk,
+			"2/blobs.toc":     VerifyStatusOk,
+		}, getVerificationStatuses(results))
+	})
+
+	t.Run("corrupted and missing objects", func(t *testing.T) {
+		require.NoError(t, st.SubStorage("1", true).PutObject(ctx, "10.dat.gz", bytes.NewBufferString("table 1O")))
+		require.NoError(t, st.SubStorage("2", true).Delete(ctx, "blobs.toc"))
+
+		results, err := NewVerify(st, "2", 1).Run(ctx)
+		require.NoError(t, err)
+		failed := GetFailedObjects(results)
+		require.Len(t, failed, 2)
+		assert.Equal(t, map[string]string{
+			"1/10.dat.gz": VerifyStatusCorrupted,
+			"2/blobs.toc": VerifyStatusMissing,
+		}, getVerificationStatuses(failed))
+	})
+}
+
+func TestRestore_setupChecksumsVerification(t *testing.T) {
+	ctx := context.Background()
+	dumpsSt := newVerifyTestDumps(t)
+
+	newRestore := func(mode string) *Restore {
+		r := NewRestore("", dumpsSt.SubStorage("2", true), &domains.Restore{VerifyChecksums: mode}, nil, "")
+		r.SetDumpsStorage(dumpsSt)
+		require.NoError(t, r.readMetadata(ctx))
+		return r
+	}
+
+	t.Run("unknown mode", func(t *testing.T) {
+		require.ErrorContains(t, newRestore("always").setupChecksumsVerification(ctx), "unknown verify checksums mode")
+	})
+
+	require.NoError(t, dumpsSt.SubStorage("2", true).PutObject(ctx, "11.dat.gz", bytes.NewBufferString("table 11 v3")))
+
+	t.Run("before", func(t *testing.T) {
+		err := newRestore(VerifyChecksumsBefore).setupChecksumsVerification(ctx)
+		require.ErrorIs(t, err, ErrDumpIsCorrupted)
+	})
+
+	t.Run("during", func(t *testing.T) {
+		r := newRestore(VerifyChecksumsDuring)
+		require.NoError(t, r.setupChecksumsVerification(ctx))
+
+		f, err := r.st.GetObject(ctx, "11.dat.gz")
+		require.NoError(t, err)
+		_, err = io.ReadAll(f)
+		require.ErrorIs(t, err, ioutils.ErrChecksumMismatch)
+
+		f, err = r.st.GetObject(ctx, "toc.dat")
+		require.NoError(t, err)
+		_, err = io.ReadAll(f)
+		require.NoError(t, err)
+	})
+}
+
+func TestVerify_Run_tar(t *testing.T) {
+	ctx := context.Background()
+	st := newVerifyTestDumps(t)
+	// The incremental tar dump references the table data of another tar dump
+	streamTarTestDump(t, st, "1")
+	streamTarTestDump(t, st, "2")
+
+	results, err := NewVerify(st, "2", 1).Run(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, GetFailedObjects(results))
+	assert.Equal(t, map[string]string{
+		"2/toc.dat":       VerifyStatusOk,
+		"1/10.dat.gz":     VerifyStatusOk,
+		"2/11.dat.gz":     VerifyStatusOk,
+		"2/blob_1.dat.gz": VerifyStatusOk,
+		"2/blobs.toc":     VerifyStatusOk,
+	}, getVerificationStatuses(results))
+}
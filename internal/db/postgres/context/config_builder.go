@@ -70,6 +70,14 @@ func validateAndBuildEntriesConfig(
 		return tableConfigExistsWarns, nil
 	}
 
+	var autoAnonymizeRules transformers.AutoAnonymizeRules
+	if cfg.AutoAnonymize {
+		autoAnonymizeRules, err = transformers.CompileAutoAnonymizeRules(cfg.AutoAnonymizeRules, r)
+		if err != nil {
+			return nil, fmt.Errorf("cannot compile auto anonymize rules: %w", err)
+		}
+	}
+
 	// Assign settings to the Tables using config received
 	entriesWithTransformers, setConfigWarns, err := setConfigToEntries(ctx, tx, cfg.Transformation, entries, graph, r)
 	if err != nil {
@@ -126,7 +134,9 @@ func validateAndBuildEntriesConfig(
 		setColumnTypeOverrides(cfgMapping.entry, cfgMapping.config, typeMap)
 
 		// Set transformers for the table
-		transformersInitWarns, err := initAndSetupTransformers(ctx, cfgMapping.entry, cfgMapping.config, cfg, r)
+		transformersInitWarns, err := initAndSetupTransformers(
+			ctx, cfgMapping.entry, cfgMapping.config, cfg, autoAnonymizeRules, r,
+		)
 		enrichWarningsWithTableName(transformersInitWarns, cfgMapping.entry)
 		warnings = append(warnings, transformersInitWarns...)
 		if err != nil {
@@ -188,8 +198,7 @@ func findTablesWithTransformers(
 	var entriesWithTransformers []*tableConfigMapping
 	for _, entry := range tables {
 		idx := slices.IndexFunc(cfg, func(table *domains.Table) bool {
-			return (table.Name == entry.Name || fmt.Sprintf(`"%s"`, table.Name) == entry.Name) &&
-				(table.Schema == entry.Schema || fmt.Sprintf(`"%s"`, table.Schema) == entry.Schema)
+			return domains.IsTableMatch(table.Schema, table.Name, entry.Schema, entry.Name)
 		})
 		if idx != -1 {
 			entriesWithTransformers = append(entriesWithTransformers, &tableConfigMapping{
@@ -360,8 +369,7 @@ func collectRootTransformers(rootTable *entries.Table, rootTableCfg *domains.Tab
 // findTableIndex locates the index of a table in the graph by name and schema
 func findTableIndex(graph *subset.Graph, table *entries.Table) int {
 	return slices.IndexFunc(graph.GetTables(), func(t *entries.Table) bool {
-		return (table.Name == t.Name || fmt.Sprintf(`"%s"`, table.Name) == t.Name) &&
-			(table.Schema == t.Schema || fmt.Sprintf(`"%s"`, table.Schema) == t.Schema)
+		return domains.IsTableMatch(table.Schema, table.Name, t.Schema, t.Name)
 	})
 }
 
@@ -566,7 +574,7 @@ func compileAndSetWhenCondForTable(
 func setTableConstraints(
 	ctx context.Context, tx pgx.Tx, t *entries.Table, version int,
 ) (err error) {
-	t.Constraints, err = getTableConstraints(ctx, tx, t.Oid, version)
+	t.Constraints, t.ReferencedUniqueConstraints, err = getTableConstraints(ctx, tx, t.Oid, version)
 	if err != nil {
 		return fmt.Errorf("cannot get table constraints: %w", err)
 	}
@@ -613,7 +621,9 @@ func enrichWarningsWithTransformerName(warns toolkit.ValidationWarnings, n strin
 	}
 }
 
-func generateDefaultTransformersForUndefinedColumns(t *entries.Table, tableConfig *domains.Table, dumpConfig *domains.Dump) ([]*domains.TransformerConfig, error) {
+func generateDefaultTransformersForUndefinedColumns(
+	t *entries.Table, tableConfig *domains.Table, rules transformers.AutoAnonymizeRules,
+) ([]*domains.TransformerConfig, error) {
 	var defaultTransformers []*domains.TransformerConfig
 
 	// Create a set of columns that already have transformers configured
@@ -657,8 +667,8 @@ func generateDefaultTransformersForUndefinedColumns(t *entries.Table, tableConfi
 			continue
 		}
 
-		// Get default transformer for this column type
-		defaultTransformer, err := transformers.GetDefaultTransformerForColumn(column)
+		// Get transformer by the auto anonymize rules or the default transformer for this column type
+		defaultTransformer, err := rules.GetTransformerForColumn(t.Table, column)
 		if err != nil {
 			return nil, fmt.Errorf("error getting default transformer for column %s: %w", column.Name, err)
 		}
@@ -731,13 +741,14 @@ func extractColumnNamesFromParam(param toolkit.ParamsValue) ([]string, error) {
 	return []string{}, nil
 }
 
-func initAndSetupTransformers(ctx context.Context, t *entries.Table, tableConfig *domains.Table, dumpConfig *domains.Dump, r *transformersUtils.TransformerRegistry,
+func initAndSetupTransformers(ctx context.Context, t *entries.Table, tableConfig *domains.Table, dumpConfig *domains.Dump,
+	autoAnonymizeRules transformers.AutoAnonymizeRules, r *transformersUtils.TransformerRegistry,
 ) (toolkit.ValidationWarnings, error) {
 	var warnings toolkit.ValidationWarnings
 
 	// If AutoAnonymize is enabled globally, add default transformers for columns without explicit transformers
 	if dumpConfig.AutoAnonymize {
-		defaultTransformers, err := generateDefaultTransformersForUndefinedColumns(t, tableConfig, dumpConfig)
+		defaultTransformers, err := generateDefaultTransformersForUndefinedColumns(t, tableConfig, autoAnonymizeRules)
 		if err != nil {
 			return nil, fmt.Errorf("cannot generate default transformers for undefined columns: %w", err)
 		}
@@ -749,7 +760,9 @@ func initAndSetupTransformers(ctx context.Context, t *entries.Table, tableConfig
 	}
 
 	for _, tc := range tableConfig.Transformers {
-		transformationCtx, initWarnings, err := initTransformer(ctx, t.Driver, tc, r)
+		transformationCtx, initWarnings, err := initTransformer(
+			transformersUtils.WithReferencedUniqueConstraints(ctx, t.ReferencedUniqueConstraints), t.Driver, tc, r,
+		)
 		enrichWarningsWithTransformerName(initWarnings, tc.Name)
 		if err != nil {
 			return initWarnings, err
@@ -878,8 +891,7 @@ func setupConfigForPartitionedTableChildren(
 		e.Columns = parentTcm.entry.Columns
 		// Check table already has transformers. If so print message that they will be merged
 		cfgIdx := slices.IndexFunc(cfg, func(table *domains.Table) bool {
-			return (table.Name == e.Name || fmt.Sprintf(`"%s"`, table.Name) == e.Name) &&
-				(table.Schema == e.Schema || fmt.Sprintf(`"%s"`, table.Schema) == e.Schema)
+			return domains.IsTableMatch(table.Schema, table.Name, e.Schema, e.Name)
 		})
 		if cfgIdx != -1 {
 			log.Info().
@@ -0,0 +1,93 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/entries"
+	transformersUtils "github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+// NewOfflineTransformationTable - builds the table with the initialized transformers using the table definition
+// stored in the dump metadata, so the source database is not required. It is used for the restore-time
+// transformation and for the transformation of the existing dump. The custom types are not stored in the metadata,
+// therefore the columns of such types can be transformed only as raw values
+func NewOfflineTransformationTable(
+	ctx context.Context, t *toolkit.Table, cfg *domains.Table, r *transformersUtils.TransformerRegistry,
+) (*entries.Table, toolkit.ValidationWarnings, error) {
+	ctx, err := withSalt(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot set salt: %w", err)
+	}
+
+	table := &entries.Table{
+		Table: &toolkit.Table{
+			Schema:     t.Schema,
+			Name:       t.Name,
+			Oid:        t.Oid,
+			Kind:       t.Kind,
+			Parent:     t.Parent,
+			PrimaryKey: t.PrimaryKey,
+		},
+		DefinedInConfig: true,
+	}
+	// The generated columns are not dumped. The columns are copied because the type override changes them
+	for _, c := range t.Columns {
+		if c.IsGenerated {
+			continue
+		}
+		column := *c
+		column.Idx = len(table.Columns)
+		table.Columns = append(table.Columns, &column)
+	}
+
+	var warnings toolkit.ValidationWarnings
+	setColumnTypeOverrides(table, cfg, pgtype.NewMap())
+	driverWarns, err := setGlobalDriverForTable(table, nil)
+	warnings = append(warnings, driverWarns...)
+	if err != nil {
+		return nil, warnings, err
+	}
+	if warnings.IsFatal() {
+		enrichWarningsWithTableName(warnings, table)
+		return nil, warnings, nil
+	}
+
+	whenWarns := compileAndSetWhenCondForTable(table, cfg)
+	warnings = append(warnings, whenWarns...)
+	if warnings.IsFatal() {
+		enrichWarningsWithTableName(warnings, table)
+		return nil, warnings, nil
+	}
+
+	// The transformers list is copied because it is appended by the auto anonymization
+	tableCfg := *cfg
+	initWarns, err := initAndSetupTransformers(ctx, table, &tableCfg, &domains.Dump{}, nil, r)
+	warnings = append(warnings, initWarns...)
+	enrichWarningsWithTableName(warnings, table)
+	if err != nil {
+		return nil, warnings, err
+	}
+	if warnings.IsFatal() {
+		return nil, warnings, nil
+	}
+	return table, warnings, nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+func newRestoreTransformationTestTable() *toolkit.Table {
+	return &toolkit.Table{
+		Schema: "public",
+		Name:   "users",
+		Oid:    16384,
+		Columns: []*toolkit.Column{
+			{Idx: 0, Name: "id", TypeName: "int4", TypeOid: pgtype.Int4OID, Num: 1},
+			{Idx: 1, Name: "search", TypeName: "text", TypeOid: pgtype.TextOID, Num: 2, IsGenerated: true},
+			{Idx: 2, Name: "email", TypeName: "text", TypeOid: pgtype.TextOID, Num: 3},
+		},
+		PrimaryKey: []string{"id"},
+	}
+}
+
+func TestNewOfflineTransformationTable(t *testing.T) {
+	src := newRestoreTransformationTestTable()
+	cfg := &domains.Table{
+		Schema: "public",
+		Name:   "users",
+		Transformers: []*domains.TransformerConfig{
+			{
+				Name:   "Replace",
+				Params: toolkit.StaticParameters{"column": toolkit.ParamsValue("email"), "value": toolkit.ParamsValue("x")},
+			},
+		},
+	}
+
+	table, warns, err := NewOfflineTransformationTable(context.Background(), src, cfg, utils.DefaultTransformerRegistry)
+	require.NoError(t, err)
+	require.False(t, warns.IsFatal(), warns)
+	require.NotNil(t, table)
+	require.Len(t, table.Columns, 2)
+	assert.Equal(t, "email", table.Columns[1].Name)
+	assert.Equal(t, 1, table.Columns[1].Idx)
+	require.Len(t, table.TransformersContext, 1)
+	// The metadata table definition is not changed
+	assert.Len(t, src.Columns, 3)
+	assert.Equal(t, 2, src.Columns[2].Idx)
+}
+
+func TestNewOfflineTransformationTable_UnknownTransformer(t *testing.T) {
+	cfg := &domains.Table{
+		Schema:       "public",
+		Name:         "users",
+		Transformers: []*domains.TransformerConfig{{Name: "Unknown"}},
+	}
+	table, warns, err := NewOfflineTransformationTable(
+		context.Background(), newRestoreTransformationTestTable(), cfg, utils.DefaultTransformerRegistry,
+	)
+	require.NoError(t, err)
+	assert.Nil(t, table)
+	assert.True(t, warns.IsFatal())
+}
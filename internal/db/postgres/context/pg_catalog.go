@@ -85,12 +85,12 @@ func findTablesAndSequences(
 	defer tableSearchRows.Close()
 	for tableSearchRows.Next() {
 		var oid toc.Oid
-		var lastVal, relSize int64
+		var lastVal, relSize, rowsEstimate int64
 		var schemaName, name, owner, rootPtName, rootPtSchema string
 		var relKind rune
 		var excludeData, isCalled bool
 
-		err = tableSearchRows.Scan(&oid, &schemaName, &name, &owner, &relSize, &relKind,
+		err = tableSearchRows.Scan(&oid, &schemaName, &name, &owner, &relSize, &rowsEstimate, &relKind,
 			&rootPtSchema, &rootPtName, &excludeData, &isCalled, &lastVal,
 		)
 		if err != nil {
@@ -126,10 +126,11 @@ func findTablesAndSequences(
 			// Building table objects
 			table = &entries.Table{
 				Table: &toolkit.Table{
-					Name:   name,
-					Schema: schemaName,
-					Oid:    toolkit.Oid(oid),
-					Size:   relSize,
+					Name:         name,
+					Schema:       schemaName,
+					Oid:          toolkit.Oid(oid),
+					Size:         relSize,
+					RowsEstimate: rowsEstimate,
 				},
 				Owner:   owner,
 				RelKind: relKind,
@@ -412,6 +413,7 @@ func buildTableSearchQuery(
 						   ), 
 						   0
 					   ) 							      as "Size",
+			   c.reltuples::BIGINT 					  as "RowsEstimate",
 			   c.relkind 							  as "RelKind",
 			   (coalesce(pn.nspname, '')) 			  as "rootPtSchema",
 			   (coalesce(pc.relname, '')) 			  as "rootPtName",
@@ -84,7 +84,8 @@ var (
 		  	a.attnotnull 									as notnull,
 		  	a.atttypmod 									as att_len,
 		  	a.attnum 										as num,
-		  	t.typlen 										as type_len
+		  	t.typlen 										as type_len,
+		  	coalesce(pg_catalog.col_description(a.attrelid, a.attnum), '') as comment
 			{{ if ge .Version 120000 }}
 		  	,a.attgenerated != ''	    				    as attgenerated
 			{{ end }}
@@ -166,7 +167,7 @@ var (
 		WHERE conrelid = $1;
 	`
 
-	// TablePrimaryKeyReferencesConstraintsQuery - SQL query for collecting all the PK references
+	// TablePrimaryKeyReferencesConstraintsQuery - SQL query for collecting all the PK and unique constraint references
 	TablePrimaryKeyReferencesConstraintsQuery = template.Must(
 		template.New("TablePrimaryKeyReferencesConstraintsQuery").Parse(`
 		SELECT pc.oid::TEXT::BIGINT,
@@ -176,7 +177,8 @@ var (
 			   cn.nspname                                    AS on_table_schema,
 			   c.relname                                     AS on_table_name,
 			   pc.conkey                                     AS on_table_constrained_columns,
-			   pg_catalog.pg_get_constraintdef(pc.oid, true) AS condef
+			   pg_catalog.pg_get_constraintdef(pc.oid, true) AS condef,
+			   pc.confkey                                    AS referenced_columns
 		FROM pg_catalog.pg_constraint pc
 				 JOIN pg_catalog.pg_namespace pn on pc.connamespace = pn.oid
 				 JOIN pg_catalog.pg_class c ON pc.confrelid = c.oid
@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"slices"
 
 	"github.com/greenmaskio/greenmask/pkg/toolkit"
 	"github.com/jackc/pgx/v5"
@@ -71,10 +72,10 @@ func getColumnsConfig(ctx context.Context, tx pgx.Tx, oid toolkit.Oid, version i
 		column := toolkit.Column{Idx: idx}
 		if version >= 120000 {
 			err = rows.Scan(&column.Name, &column.TypeOid, &column.TypeName,
-				&column.NotNull, &column.Length, &column.Num, &column.TypeLength, &column.IsGenerated)
+				&column.NotNull, &column.Length, &column.Num, &column.TypeLength, &column.Comment, &column.IsGenerated)
 		} else {
 			err = rows.Scan(&column.Name, &column.TypeOid, &column.TypeName,
-				&column.NotNull, &column.Length, &column.Num, &column.TypeLength)
+				&column.NotNull, &column.Length, &column.Num, &column.TypeLength, &column.Comment)
 		}
 		if err != nil {
 			return nil, fmt.Errorf("cannot scan tableColumnQuery: %w", err)
@@ -97,19 +98,21 @@ func getColumnsConfig(ctx context.Context, tx pgx.Tx, oid toolkit.Oid, version i
 	return res, nil
 }
 
+// getTableConstraints - returns the table constraints and the oids of the unique constraints that are referenced by
+// the foreign keys. The FK references of the unique constraints are not stored in the toolkit.Unique, so they are
+// returned separately
 func getTableConstraints(ctx context.Context, tx pgx.Tx, tableOid toolkit.Oid, version int) (
-	[]toolkit.Constraint, error,
+	constraints []toolkit.Constraint, referencedUniques []toolkit.Oid, err error,
 ) {
-	var constraints []toolkit.Constraint
-
 	rows, err := tx.Query(ctx, TableConstraintsCommonQuery, tableOid)
 	if err != nil {
-		return nil, fmt.Errorf("cannot execute TableConstraintsCommonQuery: %w", err)
+		return nil, nil, fmt.Errorf("cannot execute TableConstraintsCommonQuery: %w", err)
 	}
 	defer rows.Close()
 
 	// Common constraints discovering
 	var pk *toolkit.PrimaryKey
+	var uniques []*toolkit.Unique
 	for rows.Next() {
 		var c toolkit.Constraint
 		var constraintOid toolkit.Oid
@@ -123,7 +126,7 @@ func getTableConstraints(ctx context.Context, tx pgx.Tx, tableOid toolkit.Oid, v
 			&rtOid, &rtName, &rtSchema, &rtColumns, &constraintDefinition,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("unable to build constraints list: %w", err)
+			return nil, nil, fmt.Errorf("unable to build constraints list: %w", err)
 		}
 
 		switch constraintType {
@@ -150,13 +153,9 @@ func getTableConstraints(ctx context.Context, tx pgx.Tx, tableOid toolkit.Oid, v
 			pk = toolkit.NewPrimaryKey(constraintSchema, constraintName, constraintDefinition, constraintOid, constraintColumns)
 			c = pk
 		case 'u':
-			c = &toolkit.Unique{
-				Schema:     constraintSchema,
-				Name:       constraintName,
-				Oid:        constraintOid,
-				Columns:    constraintColumns,
-				Definition: constraintDefinition,
-			}
+			u := toolkit.NewUnique(constraintSchema, constraintName, constraintDefinition, constraintOid, constraintColumns)
+			uniques = append(uniques, u)
+			c = u
 		case 't':
 			c = &toolkit.TriggerConstraint{
 				Schema:     constraintSchema,
@@ -186,41 +185,41 @@ func getTableConstraints(ctx context.Context, tx pgx.Tx, tableOid toolkit.Oid, v
 				Msg("ignoring table constraint")
 			continue
 		default:
-			return nil, fmt.Errorf("unknown constraint type %c", constraintType)
+			return nil, nil, fmt.Errorf("unknown constraint type %c", constraintType)
 		}
 		constraints = append(constraints, c)
 	}
 
-	if pk != nil {
-		// Add FK references to PK
+	if pk != nil || len(uniques) > 0 {
+		// Add FK references to PK and unique constraints
 		buf := bytes.NewBuffer(nil)
 		err = TablePrimaryKeyReferencesConstraintsQuery.Execute(
 			buf,
 			map[string]int{"Version": version},
 		)
 		if err != nil {
-			return nil, fmt.Errorf("error templating TablePrimaryKeyReferencesConstraintsQuery: %w", err)
+			return nil, nil, fmt.Errorf("error templating TablePrimaryKeyReferencesConstraintsQuery: %w", err)
 		}
 		fkRows, err := tx.Query(ctx, buf.String(), tableOid)
 		if err != nil {
-			return nil, fmt.Errorf("cannot execute tableConstraintsQuery: %w", err)
+			return nil, nil, fmt.Errorf("cannot execute tableConstraintsQuery: %w", err)
 		}
 		defer fkRows.Close()
 
 		for fkRows.Next() {
 			var constraintOid, onTableOid toolkit.Oid
 			var constraintName, constraintSchema, constraintDefinition, onTableSchema, onTableName string
-			var constraintColumns []toolkit.AttNum
+			var constraintColumns, referencedColumns []toolkit.AttNum
 
 			err = fkRows.Scan(
 				&constraintOid, &constraintSchema, &constraintName, &onTableOid,
-				&onTableSchema, &onTableName, &constraintColumns, &constraintDefinition,
+				&onTableSchema, &onTableName, &constraintColumns, &constraintDefinition, &referencedColumns,
 			)
 			if err != nil {
-				return nil, fmt.Errorf("unable to build constraints list: %w", err)
+				return nil, nil, fmt.Errorf("unable to build constraints list: %w", err)
 			}
 
-			pk.References = append(pk.References, &toolkit.LinkedTable{
+			ref := &toolkit.LinkedTable{
 				Oid:    onTableOid,
 				Schema: onTableSchema,
 				Name:   onTableName,
@@ -238,11 +237,38 @@ func getTableConstraints(ctx context.Context, tx pgx.Tx, tableOid toolkit.Oid, v
 						Oid:    onTableOid,
 					},
 				},
+			}
+
+			// The unique constraint with the same columns is marked as referenced. The FKs that reference a unique
+			// index without constraint are assigned to the PK as before
+			idx := slices.IndexFunc(uniques, func(u *toolkit.Unique) bool {
+				return isSameColumns(u.Columns, referencedColumns)
 			})
+			switch {
+			case idx != -1 && (pk == nil || !isSameColumns(pk.Columns, referencedColumns)):
+				if !slices.Contains(referencedUniques, uniques[idx].Oid) {
+					referencedUniques = append(referencedUniques, uniques[idx].Oid)
+				}
+			case pk != nil:
+				pk.References = append(pk.References, ref)
+			}
 		}
 	}
 
-	return constraints, nil
+	return constraints, referencedUniques, nil
+}
+
+// isSameColumns - checks the constraints have the same columns regardless of their order
+func isSameColumns(a, b []toolkit.AttNum) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, c := range a {
+		if !slices.Contains(b, c) {
+			return false
+		}
+	}
+	return true
 }
 
 func escapeSubsetConds(conds []string) []string {
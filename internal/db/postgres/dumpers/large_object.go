@@ -32,23 +32,27 @@ import (
 	"github.com/greenmaskio/greenmask/internal/storages"
 )
 
-const loBufSize = 1024 * 1024
+const (
+	loBufSize        = 1024 * 1024
+	blobsTocFileName = "blobs.toc"
+)
 
 type BlobsDumper struct {
 	Blobs          *entries.Blobs
 	OriginalSize   int64
 	CompressedSize int64
-	usePgzip       bool
+	compression    *ioutils.CompressionSettings
 }
 
-func NewLargeObjectDumper(blobs *entries.Blobs, usePgzip bool) *BlobsDumper {
+func NewLargeObjectDumper(blobs *entries.Blobs, compression *ioutils.CompressionSettings) *BlobsDumper {
 	return &BlobsDumper{
-		Blobs:    blobs,
-		usePgzip: usePgzip,
+		Blobs:       blobs,
+		compression: compression,
 	}
 }
 
 func (lod *BlobsDumper) Execute(ctx context.Context, tx pgx.Tx, st storages.Storager) error {
+	lod.Blobs.Checksums = make(map[string]string, len(lod.Blobs.LargeObjects)+1)
 
 	for _, lo := range lod.Blobs.LargeObjects {
 		eg, gtx := errgroup.WithContext(ctx)
@@ -56,10 +60,13 @@ func (lod *BlobsDumper) Execute(ctx context.Context, tx pgx.Tx, st storages.Stor
 			Uint32("oid", uint32(lo.Oid)).
 			Msg("dumping large object")
 
-		w, r := ioutils.NewGzipPipe(lod.usePgzip)
+		w, r, err := ioutils.NewCompressionPipe(lod.compression)
+		if err != nil {
+			return fmt.Errorf("cannot create compression pipe: %w", err)
+		}
 
 		// Writing goroutine
-		eg.Go(largeObjectWriter(gtx, st, lo, r))
+		eg.Go(largeObjectWriter(gtx, st, lo, r, lod.compression.Codec))
 
 		// Dumping goroutine
 		eg.Go(largeObjectDumper(gtx, lo, w, tx))
@@ -70,6 +77,7 @@ func (lod *BlobsDumper) Execute(ctx context.Context, tx pgx.Tx, st storages.Stor
 
 		lod.OriginalSize += w.GetCount()
 		lod.CompressedSize += r.GetCount()
+		lod.Blobs.Checksums[entries.LargeObjectFileName(lo.Oid, lod.compression.Codec)] = r.GetChecksum()
 
 		log.Debug().
 			Uint32("oid", uint32(lo.Oid)).
@@ -93,14 +101,17 @@ func (lod *BlobsDumper) generateBlobsToc(ctx context.Context, st storages.Storag
 		fmt.Fprintf(blobsTocBuf, "%d blob_%d.dat\n", lo.Oid, lo.Oid)
 	}
 
-	err := st.PutObject(ctx, "blobs.toc", blobsTocBuf)
+	lod.Blobs.Checksums[blobsTocFileName] = ioutils.ChecksumBytes(blobsTocBuf.Bytes())
+	err := st.PutObject(ctx, blobsTocFileName, blobsTocBuf)
 	if err != nil {
 		return fmt.Errorf("cannot write large object blobs.toc: %w", err)
 	}
 	return nil
 }
 
-func largeObjectWriter(ctx context.Context, st storages.Storager, lo *entries.LargeObject, r ioutils.CountReadCloser) func() error {
+func largeObjectWriter(
+	ctx context.Context, st storages.Storager, lo *entries.LargeObject, r ioutils.CountReadCloser, codec string,
+) func() error {
 	return func() error {
 		defer func() {
 			log.Debug().
@@ -113,7 +124,7 @@ func largeObjectWriter(ctx context.Context, st storages.Storager, lo *entries.La
 					Msg("error closing LargeObject reader")
 			}
 		}()
-		err := st.PutObject(ctx, fmt.Sprintf("blob_%d.dat.gz", lo.Oid), r)
+		err := st.PutObject(ctx, entries.LargeObjectFileName(lo.Oid, codec), r)
 		if err != nil {
 			return fmt.Errorf("cannot write large object %d object: %w", lo.Oid, err)
 		}
@@ -30,22 +30,42 @@ import (
 )
 
 type TableDumper struct {
-	table             *entries.Table
+	table *entries.Table
+	// chunk - the part of the table that is dumped by this task. It is nil if the table is not split into chunks
+	chunk             *entries.TableChunk
 	recordNum         uint64
 	validate          bool
 	validateRowsLimit uint64
-	usePgzip          bool
+	compression       *ioutils.CompressionSettings
 }
 
-func NewTableDumper(table *entries.Table, validate bool, rowsLimit uint64, usePgzip bool) *TableDumper {
+func NewTableDumper(
+	table *entries.Table, validate bool, rowsLimit uint64, compression *ioutils.CompressionSettings,
+) *TableDumper {
 	return &TableDumper{
 		table:             table,
 		validate:          validate,
-		usePgzip:          usePgzip,
+		compression:       compression,
 		validateRowsLimit: rowsLimit,
 	}
 }
 
+// NewTableChunkDumper - creates the dumper of the table chunk. The chunks are not used in validation mode
+func NewTableChunkDumper(
+	table *entries.Table, chunk *entries.TableChunk, compression *ioutils.CompressionSettings,
+) *TableDumper {
+	return &TableDumper{
+		table:       table,
+		chunk:       chunk,
+		compression: compression,
+	}
+}
+
+// Table - returns the table that is dumped by this task
+func (td *TableDumper) Table() *entries.Table {
+	return td.table
+}
+
 // writer - writes the data to the storage
 func (td *TableDumper) writer(ctx context.Context, st storages.Storager, r io.ReadCloser) func() error {
 	return func() error {
@@ -54,7 +74,7 @@ func (td *TableDumper) writer(ctx context.Context, st storages.Storager, r io.Re
 				log.Warn().Err(err).Msg("error closing TableDumper reader")
 			}
 		}()
-		err := st.PutObject(ctx, fmt.Sprintf("%d.dat.gz", td.table.DumpId), r)
+		err := st.PutObject(ctx, td.fileName(), r)
 		if err != nil {
 			return fmt.Errorf("cannot write object: %w", err)
 		}
@@ -100,7 +120,10 @@ func (td *TableDumper) dumper(ctx context.Context, eg *errgroup.Group, w io.Writ
 
 func (td *TableDumper) Execute(ctx context.Context, tx pgx.Tx, st storages.Storager) error {
 
-	w, r := ioutils.NewGzipPipe(td.usePgzip)
+	w, r, err := ioutils.NewCompressionPipe(td.compression)
+	if err != nil {
+		return fmt.Errorf("cannot create compression pipe: %w", err)
+	}
 
 	eg, gtx := errgroup.WithContext(ctx)
 
@@ -113,11 +136,34 @@ func (td *TableDumper) Execute(ctx context.Context, tx pgx.Tx, st storages.Stora
 		return err
 	}
 
+	if td.chunk != nil {
+		td.chunk.OriginalSize = w.GetCount()
+		td.chunk.CompressedSize = r.GetCount()
+		td.chunk.Checksum = r.GetChecksum()
+		return nil
+	}
 	td.table.OriginalSize = w.GetCount()
 	td.table.CompressedSize = r.GetCount()
+	td.table.Checksum = r.GetChecksum()
 	return nil
 }
 
+// fileName - returns the name of the data file of the table or the chunk
+func (td *TableDumper) fileName() string {
+	if td.chunk != nil {
+		return td.chunk.FileName
+	}
+	return td.table.DataFileName()
+}
+
+// getCopyFromStatement - returns COPY statement of the table or the chunk
+func (td *TableDumper) getCopyFromStatement() (string, error) {
+	if td.chunk != nil {
+		return td.table.GetChunkCopyFromStatement(td.chunk)
+	}
+	return td.table.GetCopyFromStatement()
+}
+
 func (td *TableDumper) process(ctx context.Context, tx pgx.Tx, w io.WriteCloser, pipeline Pipeliner) (err error) {
 	defer func() {
 		if err := w.Close(); err != nil {
@@ -126,7 +172,7 @@ func (td *TableDumper) process(ctx context.Context, tx pgx.Tx, w io.WriteCloser,
 	}()
 
 	frontend := tx.Conn().PgConn().Frontend()
-	query, err := td.table.GetCopyFromStatement()
+	query, err := td.getCopyFromStatement()
 	log.Debug().
 		Str("query", query).
 		Msgf("dumping table %s.%s using pgcopy query", td.table.Schema, td.table.Name)
@@ -182,5 +228,8 @@ func (td *TableDumper) process(ctx context.Context, tx pgx.Tx, w io.WriteCloser,
 }
 
 func (td *TableDumper) DebugInfo() string {
+	if td.chunk != nil {
+		return fmt.Sprintf("table %s.%s chunk %d", td.table.Schema, td.table.Name, td.chunk.Part)
+	}
 	return fmt.Sprintf("table %s.%s", td.table.Schema, td.table.Name)
 }
@@ -0,0 +1,199 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dumpers
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/entries"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/pgcopy"
+	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
+	"github.com/greenmaskio/greenmask/internal/utils/reader"
+)
+
+const defaultReadBufferSize = 1024 * 1024
+
+// TableDataTransformer - transforms the table data file of the existing dump and writes the result into another
+// dump. It does not require the database connection. If the table is nil the data is copied as is and only
+// recompressed using the codec of the destination file
+type TableDataTransformer struct {
+	table       *entries.Table
+	srcSt       storages.Storager
+	srcFileName string
+	fileName    string
+	compression *ioutils.CompressionSettings
+	// OriginalSize - size of the written data before compression
+	OriginalSize int64
+	// CompressedSize - size of the written file
+	CompressedSize int64
+	// Checksum - SHA-256 checksum of the written file
+	Checksum string
+}
+
+// NewTableDataTransformer - creates the transformer that reads srcFileName from srcSt and writes the result into
+// fileName. The destination codec is detected using the fileName extension, so the file names in the toc remain
+// valid
+func NewTableDataTransformer(
+	table *entries.Table, srcSt storages.Storager, srcFileName, fileName string, level int, usePgzip bool,
+) *TableDataTransformer {
+	codec := ioutils.GetCodecByFileName(fileName)
+	if codec == ioutils.CodecNone {
+		level = ioutils.DefaultCompressionLevel
+	}
+	return &TableDataTransformer{
+		table:       table,
+		srcSt:       srcSt,
+		srcFileName: srcFileName,
+		fileName:    fileName,
+		compression: &ioutils.CompressionSettings{
+			Codec:    codec,
+			Level:    level,
+			UsePgzip: usePgzip,
+		},
+	}
+}
+
+func (tt *TableDataTransformer) Execute(ctx context.Context, st storages.Storager) error {
+	src, err := tt.srcSt.GetObject(ctx, tt.srcFileName)
+	if err != nil {
+		return fmt.Errorf("cannot open %s: %w", tt.srcFileName, err)
+	}
+	r, err := ioutils.NewCompressionReader(
+		src, ioutils.GetCodecByFileName(tt.srcFileName), tt.compression.UsePgzip,
+	)
+	if err != nil {
+		if err := src.Close(); err != nil {
+			log.Warn().Err(err).Msg("error closing source object")
+		}
+		return fmt.Errorf("cannot create decompression reader: %w", err)
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			log.Warn().Err(err).Msg("error closing source object")
+		}
+	}()
+
+	w, pr, err := ioutils.NewCompressionPipe(tt.compression)
+	if err != nil {
+		return fmt.Errorf("cannot create compression pipe: %w", err)
+	}
+
+	eg, gtx := errgroup.WithContext(ctx)
+	// Storage writing goroutine
+	eg.Go(func() error {
+		defer func() {
+			if err := pr.Close(); err != nil {
+				log.Warn().Err(err).Msg("error closing TableDataTransformer reader")
+			}
+		}()
+		if err := st.PutObject(gtx, tt.fileName, pr); err != nil {
+			return fmt.Errorf("cannot write object: %w", err)
+		}
+		return nil
+	})
+	// Transformation goroutine
+	eg.Go(tt.transformer(gtx, eg, w, r))
+
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+
+	tt.OriginalSize = w.GetCount()
+	tt.CompressedSize = pr.GetCount()
+	tt.Checksum = pr.GetChecksum()
+	return nil
+}
+
+// transformer - reads the source data, transforms it if needed and writes it into the compression pipe
+func (tt *TableDataTransformer) transformer(
+	ctx context.Context, eg *errgroup.Group, w io.WriteCloser, r io.Reader,
+) func() error {
+	return func() error {
+		defer func() {
+			if err := w.Close(); err != nil {
+				log.Warn().Err(err).Msg("error closing TableDataTransformer writer")
+			}
+		}()
+
+		if tt.table == nil {
+			if _, err := io.Copy(w, r); err != nil {
+				return fmt.Errorf("error copying %s: %w", tt.srcFileName, err)
+			}
+			return nil
+		}
+
+		var pipeline Pipeliner
+		if len(tt.table.TransformersContext) > 0 {
+			var err error
+			pipeline, err = NewTransformationPipeline(ctx, eg, tt.table, w)
+			if err != nil {
+				return fmt.Errorf("cannot initialize transformation pipeline: %w", err)
+			}
+		} else {
+			pipeline = NewPlainDumpPipeline(tt.table, w)
+		}
+		if err := pipeline.Init(ctx); err != nil {
+			return fmt.Errorf("error initializing transformation pipeline: %w", err)
+		}
+		if err := TransformCopyData(ctx, pipeline, r); err != nil {
+			if doneErr := pipeline.Done(ctx); doneErr != nil {
+				log.Warn().Err(doneErr).Msg("error terminating transformation pipeline")
+			}
+			return fmt.Errorf("error transforming table data %s.%s: %w", tt.table.Schema, tt.table.Name, err)
+		}
+		return pipeline.Done(ctx)
+	}
+}
+
+func (tt *TableDataTransformer) DebugInfo() string {
+	if tt.table == nil {
+		return fmt.Sprintf("file %s", tt.fileName)
+	}
+	return fmt.Sprintf("table %s.%s file %s", tt.table.Schema, tt.table.Name, tt.fileName)
+}
+
+// TransformCopyData - reads the COPY data lines and passes them through the pipeline until the termination sequence
+func TransformCopyData(ctx context.Context, pipeline Pipeliner, r io.Reader) error {
+	buf := bufio.NewReader(r)
+	line := make([]byte, 0, defaultReadBufferSize)
+	var err error
+	for {
+		line, err = reader.ReadLine(buf, line)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("error reading from table dump: %w", err)
+		}
+		if slices.Equal(line, pgcopy.DefaultCopyTerminationSeq) {
+			break
+		}
+		// The pipeline expects the line with the end of line symbol
+		line = append(line, '\n')
+		if err = pipeline.Dump(ctx, line); err != nil {
+			return fmt.Errorf("transformation error: %w", err)
+		}
+	}
+	return pipeline.CompleteDump()
+}
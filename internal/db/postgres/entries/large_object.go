@@ -20,6 +20,7 @@ import (
 	"strings"
 
 	"github.com/greenmaskio/greenmask/internal/db/postgres/toc"
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
 )
 
 type LargeObject struct {
@@ -34,6 +35,12 @@ type LargeObject struct {
 	Owner         string
 }
 
+// LargeObjectFileName - returns the name of the large object data file. The extension depends on the compression
+// codec
+func LargeObjectFileName(oid toc.Oid, codec string) string {
+	return fmt.Sprintf("blob_%d.dat%s", oid, ioutils.GetCodecFileExtension(codec))
+}
+
 func (lo *LargeObject) SetDumpId(sequence *toc.DumpIdSequence) {
 	if sequence == nil {
 		panic("sequence cannot be nil")
@@ -166,6 +173,8 @@ type Blobs struct {
 	Dependencies   []int32
 	OriginalSize   int64
 	CompressedSize int64
+	// Checksums - SHA-256 checksums of the large object files and blobs.toc
+	Checksums map[string]string
 }
 
 func (b *Blobs) GetAllDDLs() []*toc.Entry {
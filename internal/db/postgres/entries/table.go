@@ -23,6 +23,7 @@ import (
 	"github.com/greenmaskio/greenmask/internal/db/postgres/toc"
 	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/custom"
 	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
 	"github.com/greenmaskio/greenmask/pkg/toolkit"
 )
 
@@ -51,11 +52,20 @@ type Table struct {
 	DumpId              int32
 	OriginalSize        int64
 	CompressedSize      int64
+	// Checksum - SHA-256 checksum of the compressed table data file
+	Checksum string
+	// Codec - compression codec of the table data file
+	Codec string
+	// Chunks - the parts of the table data that are dumped into the separate files. It is empty if the table is not
+	// split
+	Chunks []*TableChunk
 	//ExcludeData          bool
 	Driver      *toolkit.Driver
 	Scores      int64
 	SubsetConds []string
 	When        *toolkit.WhenCond
+	// ReferencedUniqueConstraints - oids of the table unique constraints that are referenced by the foreign keys
+	ReferencedUniqueConstraints []toolkit.Oid
 	// DefinedInConfig indicates the table was selected/defined by the configuration (explicitly or via
 	// configuration-driven inheritance like apply_for_inherited/apply_for_references)
 	DefinedInConfig bool
@@ -69,6 +79,11 @@ func (t *Table) HasCustomTransformer() bool {
 	})
 }
 
+// DataFileName - returns the name of the table data file. The extension depends on the compression codec
+func (t *Table) DataFileName() string {
+	return fmt.Sprintf("%d.dat%s", t.DumpId, ioutils.GetCodecFileExtension(t.Codec))
+}
+
 // SetDumpId - set dump id for table - it uses in TOC entry identification
 func (t *Table) SetDumpId(sequence *toc.DumpIdSequence) {
 	if sequence == nil {
@@ -111,7 +126,7 @@ func (t *Table) Entry() (*toc.Entry, error) {
 	}
 	copyStmt := fmt.Sprintf(query, escapeIdent(schemaName), escapeIdent(tableName), strings.Join(columns, ", "))
 
-	fileName := fmt.Sprintf("%d.dat.gz", t.DumpId)
+	fileName := t.DataFileName()
 
 	dependencies := make([]int32, 0)
 	if len(t.Dependencies) != 0 {
@@ -0,0 +1,116 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entries
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
+)
+
+// TableChunk - the part of the table data that is dumped into the separate file. The chunks of the table are dumped
+// and restored in parallel
+type TableChunk struct {
+	Part int
+	// Cond - the condition that selects the chunk rows. It is empty for the chunks that are not dumped in the current
+	// run (resumed or referenced from the previous dump)
+	Cond           string
+	FileName       string
+	OriginalSize   int64
+	CompressedSize int64
+	// Checksum - SHA-256 checksum of the compressed chunk data file
+	Checksum string
+}
+
+// IsChunked - returns true if the table data is split into chunks
+func (t *Table) IsChunked() bool {
+	return len(t.Chunks) > 0
+}
+
+// ChunkFileName - returns the name of the table chunk data file. The extension depends on the compression codec
+func (t *Table) ChunkFileName(part int) string {
+	return ChunkFileName(t.DumpId, part, t.Codec)
+}
+
+// ChunkFileName - returns the name of the chunk data file of the table with the dump id
+func ChunkFileName(dumpId int32, part int, codec string) string {
+	return fmt.Sprintf("%d.%d.dat%s", dumpId, part, ioutils.GetCodecFileExtension(codec))
+}
+
+// ParseChunkPart - returns the part number of the chunk data file name created by ChunkFileName
+func ParseChunkPart(fileName string) (int, error) {
+	parts := strings.SplitN(fileName, ".", 3)
+	if len(parts) != 3 || !strings.HasPrefix(parts[2], "dat") {
+		return 0, fmt.Errorf("invalid chunk file name %s", fileName)
+	}
+	part, err := strconv.Atoi(parts[1])
+	if err != nil || part < 0 {
+		return 0, fmt.Errorf("invalid part number of chunk file name %s", fileName)
+	}
+	return part, nil
+}
+
+// ChunkFileNames - returns the data file names of the table chunks
+func (t *Table) ChunkFileNames() []string {
+	if !t.IsChunked() {
+		return nil
+	}
+	res := make([]string, 0, len(t.Chunks))
+	for _, c := range t.Chunks {
+		res = append(res, c.FileName)
+	}
+	return res
+}
+
+// DataChecksums - returns the map of the table data file name to its checksum
+func (t *Table) DataChecksums() map[string]string {
+	if t.IsChunked() {
+		res := make(map[string]string, len(t.Chunks))
+		for _, c := range t.Chunks {
+			res[c.FileName] = c.Checksum
+		}
+		return res
+	}
+	if t.Checksum == "" {
+		return nil
+	}
+	return map[string]string{t.DataFileName(): t.Checksum}
+}
+
+// SumChunksSizes - sets the table sizes as the sum of the chunks sizes
+func (t *Table) SumChunksSizes() {
+	t.OriginalSize, t.CompressedSize = 0, 0
+	for _, c := range t.Chunks {
+		t.OriginalSize += c.OriginalSize
+		t.CompressedSize += c.CompressedSize
+	}
+}
+
+// GetChunkCopyFromStatement - get COPY FROM statement for the table chunk. The chunk condition refers to the table
+// columns, so the table query is wrapped into subquery
+func (t *Table) GetChunkCopyFromStatement(c *TableChunk) (string, error) {
+	if c.Cond == "" {
+		return "", fmt.Errorf("chunk %d of table %s.%s has empty condition", c.Part, t.Schema, t.Name)
+	}
+	if t.Query != "" {
+		return fmt.Sprintf("COPY (SELECT * FROM (%s) AS chunk WHERE %s) TO STDOUT", t.Query, c.Cond), nil
+	}
+	return fmt.Sprintf(
+		"COPY (SELECT %s FROM \"%s\".\"%s\" WHERE %s) TO STDOUT",
+		t.SelectColumns(), escapeIdent(t.Schema), escapeIdent(t.Name), c.Cond,
+	), nil
+}
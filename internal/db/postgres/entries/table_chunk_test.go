@@ -0,0 +1,96 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package entries
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+func TestTable_ChunkFileName(t *testing.T) {
+	table := &Table{Table: &toolkit.Table{Schema: "public", Name: "orders"}, DumpId: 42}
+	assert.Equal(t, "42.3.dat.gz", table.ChunkFileName(3))
+	table.Codec = ioutils.CodecZstd
+	assert.Equal(t, "42.0.dat.zst", table.ChunkFileName(0))
+	assert.False(t, table.IsChunked())
+	assert.Nil(t, table.ChunkFileNames())
+}
+
+func TestParseChunkPart(t *testing.T) {
+	part, err := ParseChunkPart("42.10.dat.gz")
+	require.NoError(t, err)
+	assert.Equal(t, 10, part)
+	part, err = ParseChunkPart("42.3.dat")
+	require.NoError(t, err)
+	assert.Equal(t, 3, part)
+
+	for _, name := range []string{"42.dat.gz", "42.x.dat.gz", "42.-1.dat.gz", "42.1.toc"} {
+		_, err = ParseChunkPart(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestTable_DataChecksums(t *testing.T) {
+	table := &Table{Table: &toolkit.Table{Schema: "public", Name: "orders"}, DumpId: 42, Checksum: "abc"}
+	assert.Equal(t, map[string]string{"42.dat.gz": "abc"}, table.DataChecksums())
+
+	table.Chunks = []*TableChunk{
+		{Part: 0, FileName: "42.0.dat.gz", Checksum: "c0", OriginalSize: 10, CompressedSize: 2},
+		{Part: 1, FileName: "42.1.dat.gz", Checksum: "c1", OriginalSize: 20, CompressedSize: 3},
+	}
+	assert.Equal(t, map[string]string{"42.0.dat.gz": "c0", "42.1.dat.gz": "c1"}, table.DataChecksums())
+	assert.Equal(t, []string{"42.0.dat.gz", "42.1.dat.gz"}, table.ChunkFileNames())
+	table.SumChunksSizes()
+	assert.Equal(t, int64(30), table.OriginalSize)
+	assert.Equal(t, int64(5), table.CompressedSize)
+}
+
+func TestTable_GetChunkCopyFromStatement(t *testing.T) {
+	table := &Table{
+		Table: &toolkit.Table{
+			Schema: "public",
+			Name:   "orders",
+			Columns: []*toolkit.Column{
+				{Name: "id"},
+				{Name: "total"},
+			},
+		},
+	}
+	chunk := &TableChunk{Part: 1, Cond: "\"id\" >= 10 AND \"id\" < 20"}
+	query, err := table.GetChunkCopyFromStatement(chunk)
+	require.NoError(t, err)
+	assert.Equal(t,
+		"COPY (SELECT \"public\".\"orders\".\"id\", \"public\".\"orders\".\"total\" FROM \"public\".\"orders\" "+
+			"WHERE \"id\" >= 10 AND \"id\" < 20) TO STDOUT",
+		query,
+	)
+
+	table.Query = "SELECT * FROM public.orders WHERE total > 0"
+	query, err = table.GetChunkCopyFromStatement(chunk)
+	require.NoError(t, err)
+	assert.Equal(t,
+		"COPY (SELECT * FROM (SELECT * FROM public.orders WHERE total > 0) AS chunk "+
+			"WHERE \"id\" >= 10 AND \"id\" < 20) TO STDOUT",
+		query,
+	)
+
+	_, err = table.GetChunkCopyFromStatement(&TableChunk{Part: 0})
+	require.ErrorContains(t, err, "empty condition")
+}
@@ -0,0 +1,62 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"encoding/csv"
+	"io"
+
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+const FormatCsv = "csv"
+
+// CsvEncoder - writes the rows in CSV format with the header. The values are written in PostgreSQL text format,
+// NULL is written as the empty value
+type CsvEncoder struct {
+	w      *csv.Writer
+	record []string
+}
+
+func NewCsvEncoder(w io.Writer, columns []*Column, _ *ioutils.CompressionSettings) (Encoder, error) {
+	e := &CsvEncoder{
+		w:      csv.NewWriter(w),
+		record: make([]string, len(columns)),
+	}
+	for i, c := range columns {
+		e.record[i] = c.Name
+	}
+	if err := e.w.Write(e.record); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *CsvEncoder) Encode(row []*toolkit.RawValue) error {
+	for i, v := range row {
+		if v.IsNull {
+			e.record[i] = ""
+			continue
+		}
+		e.record[i] = string(v.Data)
+	}
+	return e.w.Write(e.record)
+}
+
+func (e *CsvEncoder) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
@@ -0,0 +1,213 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/pgcopy"
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
+	"github.com/greenmaskio/greenmask/internal/utils/reader"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+// The types of the exported values. The PostgreSQL types that are not listed are exported as strings
+const (
+	TypeBoolean     = "boolean"
+	TypeInt16       = "int16"
+	TypeInt32       = "int32"
+	TypeInt64       = "int64"
+	TypeFloat32     = "float32"
+	TypeFloat64     = "float64"
+	TypeDecimal     = "decimal"
+	TypeDate        = "date"
+	TypeTimestamp   = "timestamp"
+	TypeTimestampTz = "timestamptz"
+	TypeTime        = "time"
+	TypeUuid        = "uuid"
+	TypeBinary      = "binary"
+	TypeJson        = "json"
+	TypeString      = "string"
+)
+
+var pgTypes = map[toolkit.Oid]string{
+	pgtype.BoolOID:        TypeBoolean,
+	pgtype.Int2OID:        TypeInt16,
+	pgtype.Int4OID:        TypeInt32,
+	pgtype.Int8OID:        TypeInt64,
+	pgtype.OIDOID:         TypeInt64,
+	pgtype.Float4OID:      TypeFloat32,
+	pgtype.Float8OID:      TypeFloat64,
+	pgtype.NumericOID:     TypeDecimal,
+	pgtype.DateOID:        TypeDate,
+	pgtype.TimestampOID:   TypeTimestamp,
+	pgtype.TimestamptzOID: TypeTimestampTz,
+	pgtype.TimeOID:        TypeTime,
+	pgtype.UUIDOID:        TypeUuid,
+	pgtype.ByteaOID:       TypeBinary,
+	pgtype.JSONOID:        TypeJson,
+	pgtype.JSONBOID:       TypeJson,
+}
+
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// Column - the exported column
+type Column struct {
+	Name string `json:"name"`
+	// PgType - the PostgreSQL type name of the column
+	PgType string `json:"pg_type"`
+	// Type - the type of the exported values
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+}
+
+// NewColumns - returns the exported columns of the table in the order they are dumped. The generated columns are
+// not dumped
+func NewColumns(t *toolkit.Table) []*Column {
+	res := make([]*Column, 0, len(t.Columns))
+	for _, c := range t.Columns {
+		if c.IsGenerated {
+			continue
+		}
+		typ, ok := pgTypes[c.TypeOid]
+		if !ok {
+			typ = TypeString
+		}
+		res = append(res, &Column{
+			Name:     c.Name,
+			PgType:   c.TypeName,
+			Type:     typ,
+			Nullable: !c.NotNull,
+		})
+	}
+	return res
+}
+
+// Encoder - writes the table rows in the export format
+type Encoder interface {
+	// Encode - writes the row. The values are in PostgreSQL text format, the value data must not be retained after
+	// the call
+	Encode(row []*toolkit.RawValue) error
+	// Close - flushes the buffered rows and writes the file footer. It does not close the underlying writer
+	Close() error
+}
+
+// NewEncoderFunc - creates the encoder of the table with the columns that writes into w
+type NewEncoderFunc func(w io.Writer, columns []*Column, cs *ioutils.CompressionSettings) (Encoder, error)
+
+// Format - the export file format
+type Format struct {
+	Name string
+	// Extension - the file extension including the dot. The codec extension is appended if the file is compressed as
+	// a whole
+	Extension string
+	// Compressed - the format compresses the data by itself, so the file is not compressed as a whole
+	Compressed bool
+	// Codecs - the supported compression codecs. Empty list means all the codecs are supported
+	Codecs     []string
+	NewEncoder NewEncoderFunc
+}
+
+// FileExtension - returns the extension of the exported file using the compression codec
+func (f *Format) FileExtension(codec string) string {
+	if f.Compressed {
+		return f.Extension
+	}
+	return f.Extension + ioutils.GetCodecFileExtension(codec)
+}
+
+// ValidateCodec - checks the format supports the compression codec
+func (f *Format) ValidateCodec(codec string) error {
+	codec = ioutils.GetCodec(codec)
+	if len(f.Codecs) > 0 && !slices.Contains(f.Codecs, codec) {
+		return fmt.Errorf("\"%s\" codec is not supported by %s format: expected one of %v", codec, f.Name, f.Codecs)
+	}
+	return nil
+}
+
+var formats = map[string]*Format{}
+
+// RegisterFormat - registers the export format. The existing format with the same name is replaced
+func RegisterFormat(f *Format) {
+	formats[f.Name] = f
+}
+
+// GetFormat - returns the registered export format by name
+func GetFormat(name string) (*Format, error) {
+	f, ok := formats[name]
+	if !ok {
+		return nil, fmt.Errorf("%w \"%s\": expected one of %v", ErrUnknownFormat, name, GetFormatNames())
+	}
+	return f, nil
+}
+
+// GetFormatNames - returns the sorted names of the registered formats
+func GetFormatNames() []string {
+	res := make([]string, 0, len(formats))
+	for name := range formats {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// EncodeCopyData - reads the table data in COPY text format until the termination sequence and writes the rows
+// using the encoder. It returns the count of the encoded rows
+func EncodeCopyData(r io.Reader, enc Encoder, columnsCount int) (int64, error) {
+	if columnsCount == 0 {
+		return 0, errors.New("table does not have columns")
+	}
+	buf := bufio.NewReader(r)
+	row := pgcopy.NewRow(columnsCount)
+	values := make([]*toolkit.RawValue, columnsCount)
+	var line []byte
+	var err error
+	var rows int64
+	for {
+		line, err = reader.ReadLine(buf, line)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return rows, nil
+			}
+			return rows, fmt.Errorf("error reading table data: %w", err)
+		}
+		if slices.Equal(line, pgcopy.DefaultCopyTerminationSeq) {
+			return rows, nil
+		}
+		if n := bytes.Count(line, []byte{pgcopy.DefaultCopyDelimiter}) + 1; n != columnsCount {
+			return rows, fmt.Errorf("row %d has %d columns but the table has %d columns", rows+1, n, columnsCount)
+		}
+		if err = row.Decode(line); err != nil {
+			return rows, fmt.Errorf("cannot decode row %d: %w", rows+1, err)
+		}
+		for i := range values {
+			if values[i], err = row.GetColumn(i); err != nil {
+				return rows, fmt.Errorf("cannot get column %d of row %d: %w", i, rows+1, err)
+			}
+		}
+		if err = enc.Encode(values); err != nil {
+			return rows, fmt.Errorf("cannot encode row %d: %w", rows+1, err)
+		}
+		rows++
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
+)
+
+var testColumns = []*Column{
+	{Name: "id", Type: TypeInt32},
+	{Name: "active", Type: TypeBoolean, Nullable: true},
+	{Name: "score", Type: TypeFloat64, Nullable: true},
+	{Name: "data", Type: TypeJson, Nullable: true},
+	{Name: "photo", Type: TypeBinary, Nullable: true},
+	{Name: "title", Type: TypeString, Nullable: true},
+}
+
+const testCopyData = "1\tt\t1.5\t{\"a\": 1}\t\\\\x0102\tfirst\n" +
+	"2\t\\N\tNaN\t\\N\t\\N\tsecond\\tline\n" +
+	"\\.\n"
+
+func encodeTestData(t *testing.T, format string) string {
+	f, err := GetFormat(format)
+	require.NoError(t, err)
+	buf := new(bytes.Buffer)
+	enc, err := f.NewEncoder(buf, testColumns, &ioutils.CompressionSettings{Codec: ioutils.CodecNone})
+	require.NoError(t, err)
+	rows, err := EncodeCopyData(strings.NewReader(testCopyData), enc, len(testColumns))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), rows)
+	require.NoError(t, enc.Close())
+	return buf.String()
+}
+
+func TestEncodeCopyData_Csv(t *testing.T) {
+	expected := "id,active,score,data,photo,title\n" +
+		"1,t,1.5,\"{\"\"a\"\": 1}\",\\x0102,first\n" +
+		"2,,NaN,,,second\tline\n"
+	assert.Equal(t, expected, encodeTestData(t, FormatCsv))
+}
+
+func TestEncodeCopyData_Jsonl(t *testing.T) {
+	expected := `{"id":1,"active":true,"score":1.5,"data":{"a": 1},"photo":"AQI=","title":"first"}` + "\n" +
+		`{"id":2,"active":null,"score":"NaN","data":null,"photo":null,"title":"second\tline"}` + "\n"
+	assert.Equal(t, expected, encodeTestData(t, FormatJsonl))
+}
+
+func TestEncodeCopyData_ColumnsMismatch(t *testing.T) {
+	f, err := GetFormat(FormatCsv)
+	require.NoError(t, err)
+	enc, err := f.NewEncoder(new(bytes.Buffer), testColumns[:2], &ioutils.CompressionSettings{})
+	require.NoError(t, err)
+	_, err = EncodeCopyData(strings.NewReader("1\tt\textra\n"), enc, 2)
+	require.ErrorContains(t, err, "row 1 has 3 columns")
+}
+
+func TestGetFormat(t *testing.T) {
+	assert.Equal(t, []string{FormatCsv, FormatJsonl, FormatParquet}, GetFormatNames())
+
+	_, err := GetFormat("xml")
+	require.ErrorIs(t, err, ErrUnknownFormat)
+
+	f, err := GetFormat(FormatParquet)
+	require.NoError(t, err)
+	assert.Equal(t, ".parquet", f.FileExtension(ioutils.CodecZstd))
+	require.Error(t, f.ValidateCodec(ioutils.CodecLz4))
+	require.NoError(t, f.ValidateCodec(""))
+
+	f, err = GetFormat(FormatCsv)
+	require.NoError(t, err)
+	assert.Equal(t, ".csv.gz", f.FileExtension(ioutils.CodecGzip))
+	require.NoError(t, f.ValidateCodec(ioutils.CodecLz4))
+}
@@ -0,0 +1,125 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+const FormatJsonl = "jsonl"
+
+// JsonlEncoder - writes each row as JSON object on a separate line. The booleans and numbers are written as JSON
+// values, json and jsonb as nested documents, bytea as base64 string and the rest of the types as strings
+type JsonlEncoder struct {
+	w       *bufio.Writer
+	columns []*Column
+	// keys - the encoded column names
+	keys [][]byte
+	buf  []byte
+}
+
+func NewJsonlEncoder(w io.Writer, columns []*Column, _ *ioutils.CompressionSettings) (Encoder, error) {
+	e := &JsonlEncoder{
+		w:       bufio.NewWriter(w),
+		columns: columns,
+		keys:    make([][]byte, len(columns)),
+	}
+	for i, c := range columns {
+		key, err := json.Marshal(c.Name)
+		if err != nil {
+			return nil, err
+		}
+		e.keys[i] = key
+	}
+	return e, nil
+}
+
+func (e *JsonlEncoder) Encode(row []*toolkit.RawValue) error {
+	res := append(e.buf[:0], '{')
+	for i, v := range row {
+		if i > 0 {
+			res = append(res, ',')
+		}
+		res = append(res, e.keys[i]...)
+		res = append(res, ':')
+		var err error
+		if res, err = e.appendValue(res, e.columns[i], v); err != nil {
+			return fmt.Errorf("column \"%s\": %w", e.columns[i].Name, err)
+		}
+	}
+	res = append(res, '}', '\n')
+	e.buf = res
+	_, err := e.w.Write(res)
+	return err
+}
+
+func (e *JsonlEncoder) appendValue(res []byte, c *Column, v *toolkit.RawValue) ([]byte, error) {
+	if v.IsNull {
+		return append(res, "null"...), nil
+	}
+	switch c.Type {
+	case TypeBoolean:
+		b, err := parseBool(v.Data)
+		if err != nil {
+			return nil, err
+		}
+		if b {
+			return append(res, "true"...), nil
+		}
+		return append(res, "false"...), nil
+	case TypeInt16, TypeInt32, TypeInt64:
+		// The integer in text format is a valid JSON number
+		return append(res, v.Data...), nil
+	case TypeFloat32, TypeFloat64:
+		f, err := parseFloat(v.Data, 64)
+		if err != nil {
+			return nil, err
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			// NaN and Infinity are not valid JSON numbers
+			return appendJsonString(res, v.Data)
+		}
+		return append(res, v.Data...), nil
+	case TypeJson:
+		return append(res, v.Data...), nil
+	case TypeBinary:
+		data, err := decodeBytea(v.Data)
+		if err != nil {
+			return nil, err
+		}
+		return appendJsonString(res, []byte(base64.StdEncoding.EncodeToString(data)))
+	}
+	return appendJsonString(res, v.Data)
+}
+
+func appendJsonString(res []byte, data []byte) ([]byte, error) {
+	s, err := json.Marshal(string(data))
+	if err != nil {
+		return nil, err
+	}
+	return append(res, s...), nil
+}
+
+func (e *JsonlEncoder) Close() error {
+	return e.w.Flush()
+}
@@ -0,0 +1,48 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import "time"
+
+// ManifestFileName - the name of the file that describes the exported files and the tables schema
+const ManifestFileName = "manifest.json"
+
+// Manifest - the description of the export
+type Manifest struct {
+	Format      string    `json:"format"`
+	Codec       string    `json:"codec"`
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+	Tables      []*Table  `json:"tables"`
+}
+
+// Table - the exported table. The big tables might be exported into several files
+type Table struct {
+	Schema  string    `json:"schema"`
+	Name    string    `json:"name"`
+	Columns []*Column `json:"columns"`
+	Rows    int64     `json:"rows"`
+	Files   []*File   `json:"files"`
+}
+
+// File - the exported file
+type File struct {
+	FileName string `json:"file_name"`
+	Rows     int64  `json:"rows"`
+	// Size - the size of the file in bytes
+	Size int64 `json:"size"`
+	// Checksum - SHA-256 checksum of the file
+	Checksum string `json:"checksum"`
+}
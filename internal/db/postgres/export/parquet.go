@@ -0,0 +1,242 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/google/uuid"
+	kzstd "github.com/klauspost/compress/zstd"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+	"github.com/parquet-go/parquet-go/compress/gzip"
+	"github.com/parquet-go/parquet-go/compress/uncompressed"
+	"github.com/parquet-go/parquet-go/compress/zstd"
+
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+const FormatParquet = "parquet"
+
+// parquetRowGroupSize - the approximate size of the row group values before compression. The row group is kept in
+// memory until it is flushed
+const parquetRowGroupSize = 32 * 1024 * 1024
+
+// parquetNodes - the parquet types of the exported values. The values of the other types are stored as strings
+var parquetNodes = map[string]func() parquet.Node{
+	TypeBoolean:     func() parquet.Node { return parquet.Leaf(parquet.BooleanType) },
+	TypeInt16:       func() parquet.Node { return parquet.Int(16) },
+	TypeInt32:       func() parquet.Node { return parquet.Int(32) },
+	TypeInt64:       func() parquet.Node { return parquet.Int(64) },
+	TypeFloat32:     func() parquet.Node { return parquet.Leaf(parquet.FloatType) },
+	TypeFloat64:     func() parquet.Node { return parquet.Leaf(parquet.DoubleType) },
+	TypeDate:        parquet.Date,
+	TypeTimestamp:   func() parquet.Node { return parquet.TimestampAdjusted(parquet.Microsecond, false) },
+	TypeTimestampTz: func() parquet.Node { return parquet.TimestampAdjusted(parquet.Microsecond, true) },
+	TypeTime:        func() parquet.Node { return parquet.TimeAdjusted(parquet.Microsecond, false) },
+	TypeUuid:        parquet.UUID,
+	TypeBinary:      func() parquet.Node { return parquet.Leaf(parquet.ByteArrayType) },
+	TypeJson:        parquet.JSON,
+}
+
+// ParquetEncoder - writes the rows into the parquet file. The pages are compressed using the configured codec
+type ParquetEncoder struct {
+	w       *parquet.Writer
+	columns []*Column
+	row     parquet.Row
+	// rowGroupSize - the size of the values buffered since the last row group flush
+	rowGroupSize int
+}
+
+func NewParquetEncoder(w io.Writer, columns []*Column, cs *ioutils.CompressionSettings) (Encoder, error) {
+	codec, err := newParquetCodec(cs)
+	if err != nil {
+		return nil, err
+	}
+	return &ParquetEncoder{
+		w:       parquet.NewWriter(w, newParquetSchema(columns), parquet.Compression(codec)),
+		columns: columns,
+		row:     make(parquet.Row, len(columns)),
+	}, nil
+}
+
+func newParquetCodec(cs *ioutils.CompressionSettings) (compress.Codec, error) {
+	switch ioutils.GetCodec(cs.Codec) {
+	case ioutils.CodecGzip:
+		level := cs.Level
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return &gzip.Codec{Level: level}, nil
+	case ioutils.CodecZstd:
+		level := zstd.DefaultLevel
+		if cs.Level != 0 {
+			level = kzstd.EncoderLevelFromZstd(cs.Level)
+		}
+		return &zstd.Codec{Level: level}, nil
+	case ioutils.CodecNone:
+		return &uncompressed.Codec{}, nil
+	}
+	return nil, fmt.Errorf("unsupported parquet codec \"%s\"", cs.Codec)
+}
+
+// newParquetSchema - returns the flat parquet schema of the exported columns. The NOT NULL columns are required and
+// the rest of them are optional
+func newParquetSchema(columns []*Column) *parquet.Schema {
+	root := &parquetGroup{
+		Group:  make(parquet.Group, len(columns)),
+		fields: make([]parquet.Field, 0, len(columns)),
+	}
+	for _, c := range columns {
+		newNode, ok := parquetNodes[c.Type]
+		if !ok {
+			// The decimal values are stored as strings to keep the precision
+			newNode = parquet.String
+		}
+		node := newNode()
+		if c.Nullable {
+			node = parquet.Optional(node)
+		}
+		root.Group[c.Name] = node
+		root.fields = append(root.fields, &parquetField{Node: node, name: c.Name})
+	}
+	return parquet.NewSchema("schema", root)
+}
+
+// parquetGroup - the root node of the schema. parquet.Group sorts the fields by name, so the fields are kept in the
+// order of the table columns separately
+type parquetGroup struct {
+	parquet.Group
+	fields []parquet.Field
+}
+
+func (g *parquetGroup) Fields() []parquet.Field {
+	return g.fields
+}
+
+type parquetField struct {
+	parquet.Node
+	name string
+}
+
+func (f *parquetField) Name() string {
+	return f.name
+}
+
+func (f *parquetField) Value(base reflect.Value) reflect.Value {
+	return base.MapIndex(reflect.ValueOf(f.name))
+}
+
+func (e *ParquetEncoder) Encode(row []*toolkit.RawValue) error {
+	for i, v := range row {
+		val, err := parseParquetValue(e.columns[i], v)
+		if err != nil {
+			return fmt.Errorf("column \"%s\": %w", e.columns[i].Name, err)
+		}
+		var definitionLevel int
+		if e.columns[i].Nullable && !v.IsNull {
+			definitionLevel = 1
+		}
+		e.row[i] = val.Level(0, definitionLevel, i)
+		e.rowGroupSize += len(v.Data)
+	}
+	// The writer copies the values, so the data buffers can be reused after the call
+	if _, err := e.w.WriteRows([]parquet.Row{e.row}); err != nil {
+		return err
+	}
+	if e.rowGroupSize >= parquetRowGroupSize {
+		e.rowGroupSize = 0
+		return e.w.Flush()
+	}
+	return nil
+}
+
+// parseParquetValue - converts the value from PostgreSQL text format into the value of the parquet column type
+func parseParquetValue(c *Column, v *toolkit.RawValue) (parquet.Value, error) {
+	if v.IsNull {
+		if !c.Nullable {
+			return parquet.Value{}, errors.New("unexpected null value in NOT NULL column")
+		}
+		return parquet.NullValue(), nil
+	}
+	switch c.Type {
+	case TypeBoolean:
+		res, err := parseBool(v.Data)
+		return parquet.BooleanValue(res), err
+	case TypeInt16, TypeInt32:
+		bitSize := 32
+		if c.Type == TypeInt16 {
+			bitSize = 16
+		}
+		res, err := parseInt(v.Data, bitSize)
+		return parquet.Int32Value(int32(res)), err
+	case TypeInt64:
+		res, err := parseInt(v.Data, 64)
+		return parquet.Int64Value(res), err
+	case TypeFloat32:
+		res, err := parseFloat(v.Data, 32)
+		return parquet.FloatValue(float32(res)), err
+	case TypeFloat64:
+		res, err := parseFloat(v.Data, 64)
+		return parquet.DoubleValue(res), err
+	case TypeDate:
+		res, err := parseDate(v.Data)
+		return parquet.Int32Value(res), err
+	case TypeTimestamp, TypeTimestampTz:
+		res, err := parseTimestamp(v.Data, c.Type == TypeTimestampTz)
+		return parquet.Int64Value(res), err
+	case TypeTime:
+		res, err := parseTime(v.Data)
+		return parquet.Int64Value(res), err
+	case TypeUuid:
+		res, err := uuid.ParseBytes(v.Data)
+		if err != nil {
+			return parquet.Value{}, fmt.Errorf("invalid uuid value: %w", err)
+		}
+		return parquet.FixedLenByteArrayValue(res[:]), nil
+	case TypeBinary:
+		res, err := decodeBytea(v.Data)
+		return parquet.ByteArrayValue(res), err
+	}
+	return parquet.ByteArrayValue(v.Data), nil
+}
+
+func (e *ParquetEncoder) Close() error {
+	return e.w.Close()
+}
+
+func init() {
+	RegisterFormat(&Format{
+		Name:       FormatParquet,
+		Extension:  ".parquet",
+		Compressed: true,
+		Codecs:     []string{ioutils.CodecGzip, ioutils.CodecZstd, ioutils.CodecNone},
+		NewEncoder: NewParquetEncoder,
+	})
+	RegisterFormat(&Format{
+		Name:       FormatCsv,
+		Extension:  ".csv",
+		NewEncoder: NewCsvEncoder,
+	})
+	RegisterFormat(&Format{
+		Name:       FormatJsonl,
+		Extension:  ".jsonl",
+		NewEncoder: NewJsonlEncoder,
+	})
+}
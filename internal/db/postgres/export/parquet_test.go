@@ -0,0 +1,229 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/utils/ioutils"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+var parquetTestColumns = []*Column{
+	{Name: "id", Type: TypeInt32},
+	{Name: "small", Type: TypeInt16, Nullable: true},
+	{Name: "big", Type: TypeInt64, Nullable: true},
+	{Name: "flag", Type: TypeBoolean, Nullable: true},
+	{Name: "ratio", Type: TypeFloat32, Nullable: true},
+	{Name: "score", Type: TypeFloat64, Nullable: true},
+	{Name: "price", Type: TypeDecimal, Nullable: true},
+	{Name: "doc", Type: TypeJson, Nullable: true},
+	{Name: "raw", Type: TypeBinary, Nullable: true},
+	{Name: "day", Type: TypeDate, Nullable: true},
+	{Name: "ts", Type: TypeTimestamp, Nullable: true},
+	{Name: "tsz", Type: TypeTimestampTz, Nullable: true},
+	{Name: "at", Type: TypeTime, Nullable: true},
+	{Name: "uid", Type: TypeUuid, Nullable: true},
+	{Name: "title", Type: TypeString, Nullable: true},
+}
+
+var parquetTestRows = [][]string{
+	{
+		"1", "-32768", "9223372036854775807", "t", "1.5", "-2.25", "12.345", `{"a": [1, 2]}`, `\x0001ff`,
+		"2024-02-29", "2024-02-29 03:04:05.000006", "2024-02-29 03:04:05.000006+00", "01:02:03",
+		"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", "Grüße",
+	},
+	{"2", `\N`, `\N`, `\N`, `\N`, `\N`, `\N`, `\N`, `\N`, `\N`, `\N`, `\N`, `\N`, `\N`, `\N`},
+	{
+		"3", "32767", "-9223372036854775808", "f", "0", "Infinity", "0", "null", `\x`, "infinity", "-infinity",
+		"1970-01-01 00:00:00+00", "24:00:00", "00000000-0000-0000-0000-000000000000", "",
+	},
+}
+
+// encodeParquetTestRows - encodes the test rows, \N is null. The same data buffer is used for all the values to
+// check the encoder does not retain it
+func encodeParquetTestRows(t *testing.T, cs *ioutils.CompressionSettings) []byte {
+	buf := new(bytes.Buffer)
+	enc, err := NewParquetEncoder(buf, parquetTestColumns, cs)
+	require.NoError(t, err)
+	data := make([]byte, 0, 1024)
+	for _, row := range parquetTestRows {
+		values := make([]*toolkit.RawValue, len(row))
+		data = data[:0]
+		for i, v := range row {
+			if v == `\N` {
+				values[i] = toolkit.NewRawValue(nil, true)
+				continue
+			}
+			start := len(data)
+			data = append(data, v...)
+			values[i] = toolkit.NewRawValue(data[start:len(data):len(data)], false)
+		}
+		require.NoError(t, enc.Encode(values))
+		for i := range data {
+			data[i] = 0
+		}
+	}
+	require.NoError(t, enc.Close())
+	return buf.Bytes()
+}
+
+func readParquetRows(t *testing.T, data []byte) (*parquet.File, []parquet.Row) {
+	f, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	r := parquet.NewReader(f)
+	defer r.Close()
+	var res []parquet.Row
+	rows := make([]parquet.Row, 1)
+	for {
+		n, err := r.ReadRows(rows)
+		if n > 0 {
+			res = append(res, rows[0].Clone())
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+	}
+	return f, res
+}
+
+func TestNewParquetSchema(t *testing.T) {
+	schema := newParquetSchema(parquetTestColumns)
+	fields := schema.Fields()
+	require.Len(t, fields, len(parquetTestColumns))
+	for i, c := range parquetTestColumns {
+		assert.Equal(t, c.Name, fields[i].Name())
+		assert.Equal(t, c.Nullable, fields[i].Optional(), c.Name)
+	}
+
+	logicalTypes := map[string]func(lt *format.LogicalType) bool{
+		"small": func(lt *format.LogicalType) bool { return lt.Integer != nil && lt.Integer.BitWidth == 16 },
+		"price": func(lt *format.LogicalType) bool { return lt.UTF8 != nil },
+		"doc":   func(lt *format.LogicalType) bool { return lt.Json != nil },
+		"day":   func(lt *format.LogicalType) bool { return lt.Date != nil },
+		"ts": func(lt *format.LogicalType) bool {
+			return lt.Timestamp != nil && !lt.Timestamp.IsAdjustedToUTC && lt.Timestamp.Unit.Micros != nil
+		},
+		"tsz": func(lt *format.LogicalType) bool {
+			return lt.Timestamp != nil && lt.Timestamp.IsAdjustedToUTC && lt.Timestamp.Unit.Micros != nil
+		},
+		"at":  func(lt *format.LogicalType) bool { return lt.Time != nil && lt.Time.Unit.Micros != nil },
+		"uid": func(lt *format.LogicalType) bool { return lt.UUID != nil },
+	}
+	for name, check := range logicalTypes {
+		leaf, ok := schema.Lookup(name)
+		require.True(t, ok, name)
+		assert.True(t, check(leaf.Node.Type().LogicalType()), name)
+	}
+	raw, _ := schema.Lookup("raw")
+	assert.Equal(t, parquet.ByteArray, raw.Node.Type().Kind())
+	assert.Nil(t, raw.Node.Type().LogicalType())
+	uid, _ := schema.Lookup("uid")
+	assert.Equal(t, parquet.FixedLenByteArray, uid.Node.Type().Kind())
+}
+
+func TestParquetEncoder(t *testing.T) {
+	for _, codec := range []string{ioutils.CodecGzip, ioutils.CodecZstd, ioutils.CodecNone} {
+		t.Run(codec, func(t *testing.T) {
+			f, rows := readParquetRows(t, encodeParquetTestRows(t, &ioutils.CompressionSettings{Codec: codec}))
+			require.Len(t, f.Metadata().RowGroups, 1)
+			for _, c := range f.Metadata().RowGroups[0].Columns {
+				assert.Equal(t, codec, map[format.CompressionCodec]string{
+					format.Gzip: ioutils.CodecGzip, format.Zstd: ioutils.CodecZstd, format.Uncompressed: ioutils.CodecNone,
+				}[c.MetaData.Codec])
+			}
+			require.Len(t, rows, 3)
+
+			row := rows[0]
+			assert.Equal(t, int32(1), row[0].Int32())
+			assert.Equal(t, int32(-32768), row[1].Int32())
+			assert.Equal(t, int64(math.MaxInt64), row[2].Int64())
+			assert.True(t, row[3].Boolean())
+			assert.Equal(t, float32(1.5), row[4].Float())
+			assert.Equal(t, -2.25, row[5].Double())
+			assert.Equal(t, "12.345", string(row[6].ByteArray()))
+			assert.Equal(t, `{"a": [1, 2]}`, string(row[7].ByteArray()))
+			assert.Equal(t, []byte{0, 1, 0xff}, row[8].ByteArray())
+			assert.Equal(t, int32(19782), row[9].Int32())
+			assert.Equal(t, int64(1709175845000006), row[10].Int64())
+			assert.Equal(t, int64(1709175845000006), row[11].Int64())
+			assert.Equal(t, int64(3723000000), row[12].Int64())
+			assert.Equal(t, []byte{
+				0xa0, 0xee, 0xbc, 0x99, 0x9c, 0x0b, 0x4e, 0xf8, 0xbb, 0x6d, 0x6b, 0xb9, 0xbd, 0x38, 0x0a, 0x11,
+			}, row[13].ByteArray())
+			assert.Equal(t, "Grüße", string(row[14].ByteArray()))
+
+			assert.Equal(t, int32(2), rows[1][0].Int32())
+			for _, v := range rows[1][1:] {
+				assert.True(t, v.IsNull())
+			}
+
+			row = rows[2]
+			assert.False(t, row[3].Boolean())
+			assert.True(t, math.IsInf(row[5].Double(), 1))
+			assert.Empty(t, row[8].ByteArray())
+			assert.False(t, row[8].IsNull())
+			assert.Equal(t, int32(math.MaxInt32), row[9].Int32())
+			assert.Equal(t, int64(math.MinInt64), row[10].Int64())
+			assert.Equal(t, int64(0), row[11].Int64())
+			assert.Equal(t, int64(86400000000), row[12].Int64())
+			assert.Equal(t, make([]byte, 16), row[13].ByteArray())
+			assert.False(t, row[14].IsNull())
+			assert.Empty(t, row[14].ByteArray())
+		})
+	}
+}
+
+func TestParquetEncoder_Empty(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc, err := NewParquetEncoder(buf, parquetTestColumns, &ioutils.CompressionSettings{Codec: ioutils.CodecZstd})
+	require.NoError(t, err)
+	require.NoError(t, enc.Close())
+	f, rows := readParquetRows(t, buf.Bytes())
+	assert.Equal(t, int64(0), f.NumRows())
+	assert.Len(t, f.Schema().Fields(), len(parquetTestColumns))
+	assert.Empty(t, rows)
+}
+
+func TestParquetEncoder_InvalidValues(t *testing.T) {
+	columns := []*Column{
+		{Name: "id", Type: TypeInt16},
+		{Name: "uid", Type: TypeUuid, Nullable: true},
+	}
+	enc, err := NewParquetEncoder(new(bytes.Buffer), columns, &ioutils.CompressionSettings{Codec: ioutils.CodecZstd})
+	require.NoError(t, err)
+
+	err = enc.Encode([]*toolkit.RawValue{toolkit.NewRawValue([]byte("100000"), false), toolkit.NewRawValue(nil, true)})
+	require.ErrorContains(t, err, "column \"id\"")
+	err = enc.Encode([]*toolkit.RawValue{toolkit.NewRawValue(nil, true), toolkit.NewRawValue(nil, true)})
+	require.ErrorContains(t, err, "column \"id\": unexpected null value")
+	err = enc.Encode([]*toolkit.RawValue{toolkit.NewRawValue([]byte("1"), false), toolkit.NewRawValue([]byte("x"), false)})
+	require.ErrorContains(t, err, "column \"uid\": invalid uuid value")
+}
+
+func TestNewParquetEncoder_UnsupportedCodec(t *testing.T) {
+	_, err := NewParquetEncoder(new(bytes.Buffer), nil, &ioutils.CompressionSettings{Codec: ioutils.CodecLz4})
+	require.Error(t, err)
+}
@@ -0,0 +1,161 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dateLayout      = "2006-01-02"
+	timestampLayout = "2006-01-02 15:04:05"
+	timeLayout      = "15:04:05.999999"
+	endOfDayValue   = "24:00:00"
+
+	infinityValue         = "infinity"
+	negativeInfinityValue = "-infinity"
+	bcSuffix              = " BC"
+)
+
+// timestampTzLayouts - the layouts of timestamptz in ISO DateStyle. The offset is printed with minutes and seconds
+// only if they are not zero. The fractional seconds are parsed even if they are not in the layout
+var timestampTzLayouts = []string{
+	"2006-01-02 15:04:05-07",
+	"2006-01-02 15:04:05-07:00",
+	"2006-01-02 15:04:05-07:00:00",
+}
+
+func parseBool(data []byte) (bool, error) {
+	switch string(data) {
+	case "t":
+		return true, nil
+	case "f":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean value \"%s\"", data)
+}
+
+func parseInt(data []byte, bitSize int) (int64, error) {
+	return strconv.ParseInt(string(data), 10, bitSize)
+}
+
+// parseFloat - parses the float value. NaN, Infinity and -Infinity are supported
+func parseFloat(data []byte, bitSize int) (float64, error) {
+	return strconv.ParseFloat(string(data), bitSize)
+}
+
+// parseDate - returns the count of days since the Unix epoch. infinity and -infinity are mapped to the max and min
+// int32 values
+func parseDate(data []byte) (int32, error) {
+	switch string(data) {
+	case infinityValue:
+		return math.MaxInt32, nil
+	case negativeInfinityValue:
+		return math.MinInt32, nil
+	}
+	t, err := parseIsoTime(string(data), dateLayout)
+	if err != nil {
+		return 0, fmt.Errorf("unsupported date value \"%s\": %w", data, err)
+	}
+	return int32(t.Unix() / (24 * 60 * 60)), nil
+}
+
+// parseTimestamp - returns the count of microseconds since the Unix epoch. The timestamp without time zone is
+// treated as UTC. infinity and -infinity are mapped to the max and min int64 values
+func parseTimestamp(data []byte, withTz bool) (int64, error) {
+	switch string(data) {
+	case infinityValue:
+		return math.MaxInt64, nil
+	case negativeInfinityValue:
+		return math.MinInt64, nil
+	}
+	if !withTz {
+		t, err := parseIsoTime(string(data), timestampLayout)
+		if err != nil {
+			return 0, fmt.Errorf("unsupported timestamp value \"%s\": %w", data, err)
+		}
+		return t.UnixMicro(), nil
+	}
+	t, err := parseIsoTime(string(data), timestampTzLayouts...)
+	if err != nil {
+		return 0, fmt.Errorf("unsupported timestamptz value \"%s\": %w", data, err)
+	}
+	return t.UnixMicro(), nil
+}
+
+// parseTime - returns the count of microseconds since midnight. PostgreSQL allows 24:00:00 as the end of the day
+func parseTime(data []byte) (int64, error) {
+	if string(data) == endOfDayValue {
+		return int64(24 * time.Hour / time.Microsecond), nil
+	}
+	t, err := time.Parse(timeLayout, string(data))
+	if err != nil {
+		return 0, fmt.Errorf("unsupported time value \"%s\": %w", data, err)
+	}
+	return t.Sub(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)).Microseconds(), nil
+}
+
+// parseIsoTime - parses the date or timestamp in ISO DateStyle. The year might have more than 4 digits or the BC
+// suffix, so it is parsed separately. The rest of the value is parsed with the year of the same leap status
+func parseIsoTime(value string, layouts ...string) (time.Time, error) {
+	value, bc := strings.CutSuffix(value, bcSuffix)
+	idx := strings.IndexByte(value, '-')
+	if idx <= 0 {
+		return time.Time{}, errors.New("year is not found")
+	}
+	year, err := strconv.Atoi(value[:idx])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid year: %w", err)
+	}
+	if bc {
+		// There is no year 0 in PostgreSQL: 1 BC is the year 0 of the proleptic Gregorian calendar
+		year = 1 - year
+	}
+	surrogateYear := "2001"
+	if isLeapYear(year) {
+		surrogateYear = "2000"
+	}
+	for _, layout := range layouts {
+		var t time.Time
+		if t, err = time.Parse(layout, surrogateYear+value[idx:]); err == nil {
+			return time.Date(
+				year, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location(),
+			), nil
+		}
+	}
+	return time.Time{}, err
+}
+
+func isLeapYear(year int) bool {
+	return year%4 == 0 && (year%100 != 0 || year%400 == 0)
+}
+
+// decodeBytea - decodes bytea in hex format. The values in escape format are returned as is
+func decodeBytea(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != '\\' || data[1] != 'x' {
+		return data, nil
+	}
+	res := make([]byte, hex.DecodedLen(len(data)-2))
+	if _, err := hex.Decode(res, data[2:]); err != nil {
+		return nil, fmt.Errorf("invalid bytea value: %w", err)
+	}
+	return res, nil
+}
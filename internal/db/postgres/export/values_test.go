@@ -0,0 +1,125 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDate(t *testing.T) {
+	days := func(year int, month time.Month, day int) int32 {
+		return int32(time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix() / (24 * 60 * 60))
+	}
+	tests := []struct {
+		name     string
+		value    string
+		expected int32
+	}{
+		{name: "after epoch", value: "1970-01-02", expected: 1},
+		{name: "before epoch", value: "1969-12-31", expected: -1},
+		{name: "infinity", value: "infinity", expected: math.MaxInt32},
+		{name: "negative infinity", value: "-infinity", expected: math.MinInt32},
+		{name: "BC", value: "0044-03-15 BC", expected: days(-43, time.March, 15)},
+		{name: "BC leap day", value: "0001-02-29 BC", expected: days(0, time.February, 29)},
+		{name: "five digits year", value: "10000-01-01", expected: days(10000, time.January, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := parseDate([]byte(tt.value))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, res)
+		})
+	}
+
+	// 2 BC is not a leap year
+	_, err := parseDate([]byte("0002-02-29 BC"))
+	require.Error(t, err)
+	_, err = parseDate([]byte("unknown"))
+	require.Error(t, err)
+}
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		withTz   bool
+		expected int64
+	}{
+		{name: "timestamp", value: "1970-01-01 00:00:01", expected: 1_000_000},
+		{name: "timestamp with fraction", value: "1970-01-01 00:00:01.5", expected: 1_500_000},
+		{name: "timestamptz hours offset", value: "1970-01-01 03:00:01+03", withTz: true, expected: 1_000_000},
+		{name: "timestamptz minutes offset", value: "1970-01-01 05:30:00.25+05:30", withTz: true, expected: 250_000},
+		{name: "timestamptz seconds offset", value: "1970-01-01 00:00:00-00:00:10", withTz: true, expected: 10_000_000},
+		{name: "timestamp infinity", value: "infinity", expected: math.MaxInt64},
+		{name: "timestamp negative infinity", value: "-infinity", expected: math.MinInt64},
+		{name: "timestamptz infinity", value: "infinity", withTz: true, expected: math.MaxInt64},
+		{name: "timestamptz negative infinity", value: "-infinity", withTz: true, expected: math.MinInt64},
+		{
+			name:     "timestamp BC",
+			value:    "0044-03-15 12:00:00.5 BC",
+			expected: time.Date(-43, time.March, 15, 12, 0, 0, 500_000_000, time.UTC).UnixMicro(),
+		},
+		{
+			name:     "timestamptz BC",
+			value:    "0001-01-01 01:00:00+01 BC",
+			withTz:   true,
+			expected: time.Date(0, time.January, 1, 0, 0, 0, 0, time.UTC).UnixMicro(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := parseTimestamp([]byte(tt.value), tt.withTz)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, res)
+		})
+	}
+}
+
+func TestDecodeBytea(t *testing.T) {
+	res, err := decodeBytea([]byte(`\x00ff`))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0xff}, res)
+
+	_, err = decodeBytea([]byte(`\xzz`))
+	require.Error(t, err)
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int64
+	}{
+		{value: "00:00:00", expected: 0},
+		{value: "01:02:03", expected: 3723000000},
+		{value: "01:02:03.000004", expected: 3723000004},
+		{value: "23:59:59.5", expected: 86399500000},
+		{value: "24:00:00", expected: 86400000000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			res, err := parseTime([]byte(tt.value))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, res)
+		})
+	}
+
+	_, err := parseTime([]byte("25:00:00"))
+	require.Error(t, err)
+}
@@ -96,6 +96,10 @@ type Options struct {
 	// Custom options (not from pg_dump)
 	// Use pgzip compression instead of gzip
 	Pgzip bool `mapstructure:"pgzip"`
+	// Compression codec of the data files: gzip, zstd, lz4 or none
+	CompressionCodec string `mapstructure:"compression-codec"`
+	// Compression level of the codec. 0 - the codec default level
+	CompressionLevel int `mapstructure:"compression-level"`
 
 	// Connection options:
 	DbName     string `mapstructure:"dbname"`
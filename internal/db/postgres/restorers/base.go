@@ -8,6 +8,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/rs/zerolog/log"
 
+	"github.com/greenmaskio/greenmask/internal/db/postgres/entries"
 	"github.com/greenmaskio/greenmask/internal/db/postgres/pgrestore"
 	"github.com/greenmaskio/greenmask/internal/db/postgres/toc"
 	"github.com/greenmaskio/greenmask/internal/storages"
@@ -18,6 +19,8 @@ type restoreBase struct {
 	opt   *pgrestore.DataSectionSettings
 	entry *toc.Entry
 	st    storages.Storager
+	// transformation - the table with the initialized transformers that are applied to the restored data
+	transformation *entries.Table
 }
 
 func newRestoreBase(entry *toc.Entry, st storages.Storager, opt *pgrestore.DataSectionSettings) *restoreBase {
@@ -29,6 +32,12 @@ func newRestoreBase(entry *toc.Entry, st storages.Storager, opt *pgrestore.DataS
 
 }
 
+// SetTransformation - sets the table with the initialized transformers. The table data is transformed while it is
+// being restored
+func (rb *restoreBase) SetTransformation(t *entries.Table) {
+	rb.transformation = t
+}
+
 func (rb *restoreBase) DebugInfo() string {
 	return fmt.Sprintf("table %s.%s", *rb.entry.Namespace, *rb.entry.Tag)
 }
@@ -154,8 +163,24 @@ func (rb *restoreBase) resetTx(ctx context.Context, tx pgx.Tx) error {
 	return nil
 }
 
-// getObject returns a reader for the dump file. It warps the file in a gzip reader.
-func (rb *restoreBase) getObject(ctx context.Context) (io.ReadCloser, error) {
+// dumpObject - the dump file reader that is closed only once. The restorers close it before the transaction commit,
+// because the storage reader might verify the object checksum on close, and close it again in defer in case of error
+type dumpObject struct {
+	io.ReadCloser
+	closed bool
+}
+
+func (o *dumpObject) Close() error {
+	if o.closed {
+		return nil
+	}
+	o.closed = true
+	return o.ReadCloser.Close()
+}
+
+// getObject returns a reader for the dump file. It warps the file in a decompression reader of the codec that is
+// detected by the file extension.
+func (rb *restoreBase) getObject(ctx context.Context) (*dumpObject, error) {
 	if rb.entry.FileName == nil {
 		return nil, fmt.Errorf("file name in toc.Entry is empty")
 	}
@@ -165,15 +190,29 @@ func (rb *restoreBase) getObject(ctx context.Context) (io.ReadCloser, error) {
 		return nil, fmt.Errorf("cannot open dump file: %w", err)
 	}
 
-	gz, err := ioutils.NewGzipReader(r, rb.opt.UsePgzip)
+	codec := ioutils.GetCodecByFileName(*rb.entry.FileName)
+	gz, err := ioutils.NewCompressionReader(r, codec, rb.opt.UsePgzip)
 	if err != nil {
 		if err := r.Close(); err != nil {
 			log.Warn().
 				Err(err).
 				Msg("error closing dump file")
 		}
-		return nil, fmt.Errorf("cannot create gzip reader: %w", err)
+		return nil, fmt.Errorf("cannot create %s reader: %w", codec, err)
+	}
+
+	if rb.transformation != nil {
+		tr, err := newTransformationReader(ctx, gz, rb.transformation)
+		if err != nil {
+			if err := gz.Close(); err != nil {
+				log.Warn().
+					Err(err).
+					Msg("error closing dump file")
+			}
+			return nil, fmt.Errorf("cannot create transformation reader: %w", err)
+		}
+		return &dumpObject{ReadCloser: tr}, nil
 	}
 
-	return gz, nil
+	return &dumpObject{ReadCloser: gz}, nil
 }
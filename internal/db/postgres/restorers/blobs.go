@@ -17,6 +17,7 @@ package restorers
 import (
 	"bufio"
 	"context"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"strconv"
@@ -26,6 +27,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 
+	"github.com/greenmaskio/greenmask/internal/db/postgres/entries"
 	"github.com/greenmaskio/greenmask/internal/db/postgres/toc"
 	"github.com/greenmaskio/greenmask/internal/db/postgres/utils"
 	"github.com/greenmaskio/greenmask/internal/storages"
@@ -36,14 +38,17 @@ type BlobsRestorer struct {
 	Entry    *toc.Entry
 	St       storages.Storager
 	usePgzip bool
-	buf      []byte
+	// codec - compression codec of the large objects files
+	codec string
+	buf   []byte
 }
 
-func NewBlobsRestorer(entry *toc.Entry, st storages.Storager, usePgzip bool) *BlobsRestorer {
+func NewBlobsRestorer(entry *toc.Entry, st storages.Storager, codec string, usePgzip bool) *BlobsRestorer {
 	return &BlobsRestorer{
 		Entry:    entry,
 		St:       st,
 		usePgzip: usePgzip,
+		codec:    codec,
 		buf:      make([]byte, defaultBufferSize),
 	}
 }
@@ -139,16 +144,17 @@ func (br *BlobsRestorer) restoreLargeObject(ctx context.Context, conn utils.PGCo
 
 // getLargeObjectDataReader - get reader for large object by oid
 func (br *BlobsRestorer) getLargeObjectDataReader(ctx context.Context, oid uint32) (io.ReadCloser, error) {
-	loReader, err := br.St.GetObject(ctx, fmt.Sprintf("blob_%d.dat.gz", oid))
+	fileName := entries.LargeObjectFileName(toc.Oid(oid), br.codec)
+	loReader, err := br.St.GetObject(ctx, fileName)
 	if err != nil {
-		return nil, fmt.Errorf("error getting object %s: %w", fmt.Sprintf("blob_%d.dat.gz", oid), err)
+		return nil, fmt.Errorf("error getting object %s: %w", fileName, err)
 	}
-	gz, err := ioutils.GetGzipReadCloser(loReader, br.usePgzip)
+	gz, err := ioutils.NewCompressionReader(loReader, br.codec, br.usePgzip)
 	if err != nil {
 		if err := loReader.Close(); err != nil {
 			log.Warn().Err(err).Msg("error closing large object reader")
 		}
-		return nil, fmt.Errorf("cannot create gzip reader: %w", err)
+		return nil, fmt.Errorf("cannot create %s reader: %w", ioutils.GetCodec(br.codec), err)
 	}
 	return gz, nil
 }
@@ -191,6 +197,87 @@ func (br *BlobsRestorer) restoreLargeObjectData(ctx context.Context, tx pgx.Tx,
 	return nil
 }
 
+// WriteScript - writes the large objects data into the plain SQL script. The large objects are created by the
+// pre-data section of the script
+func (br *BlobsRestorer) WriteScript(ctx context.Context, w io.Writer) error {
+	loOids, err := br.getBlobsOids(ctx)
+	if err != nil {
+		return fmt.Errorf("get blobs oids: %w", err)
+	}
+	if _, err = io.WriteString(w, "BEGIN;\n\n"); err != nil {
+		return err
+	}
+	for _, loOid := range loOids {
+		if err = br.writeLargeObjectScript(ctx, w, loOid); err != nil {
+			return fmt.Errorf("write large object %d: %w", loOid, err)
+		}
+	}
+	_, err = io.WriteString(w, "COMMIT;\n\n")
+	return err
+}
+
+// writeLargeObjectScript - writes the large object data as lowrite calls with the hex encoded chunks
+func (br *BlobsRestorer) writeLargeObjectScript(ctx context.Context, w io.Writer, oid uint32) error {
+	loObj, err := br.getLargeObjectDataReader(ctx, oid)
+	if err != nil {
+		return fmt.Errorf("get large object reader: %w", err)
+	}
+	defer func() {
+		if err := loObj.Close(); err != nil {
+			log.Warn().
+				Uint32("oid", oid).
+				Err(err).
+				Msg("error closing large object reader")
+		}
+	}()
+
+	if _, err = fmt.Fprintf(w, "SELECT pg_catalog.lo_open('%d', %d);\n", oid, pgx.LargeObjectModeWrite); err != nil {
+		return err
+	}
+	for {
+		n, err := loObj.Read(br.buf)
+		if n > 0 {
+			_, err := fmt.Fprintf(w, "SELECT pg_catalog.lowrite(0, '\\x%s');\n", hex.EncodeToString(br.buf[:n]))
+			if err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("error reading large object data: %w", err)
+		}
+	}
+	_, err = io.WriteString(w, "SELECT pg_catalog.lo_close(0);\n\n")
+	return err
+}
+
+// ReadLargeObjects - calls fn for each large object of the entry with the reader of its data
+func (br *BlobsRestorer) ReadLargeObjects(ctx context.Context, fn func(oid uint32, r io.Reader) error) error {
+	loOids, err := br.getBlobsOids(ctx)
+	if err != nil {
+		return fmt.Errorf("get blobs oids: %w", err)
+	}
+	for _, loOid := range loOids {
+		loObj, err := br.getLargeObjectDataReader(ctx, loOid)
+		if err != nil {
+			return fmt.Errorf("get large object %d reader: %w", loOid, err)
+		}
+		err = fn(loOid, loObj)
+		if closeErr := loObj.Close(); closeErr != nil {
+			log.Warn().
+				Uint32("oid", loOid).
+				Err(closeErr).
+				Msg("error closing large object reader")
+		}
+		if err != nil {
+			return fmt.Errorf("large object %d: %w", loOid, err)
+		}
+	}
+	return nil
+}
+
 func (br *BlobsRestorer) GetEntry() *toc.Entry {
 	return br.Entry
 }
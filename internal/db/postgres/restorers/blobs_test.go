@@ -7,9 +7,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"testing"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	"github.com/greenmaskio/greenmask/internal/db/postgres/toc"
 	"github.com/greenmaskio/greenmask/internal/db/postgres/utils"
@@ -29,7 +32,7 @@ func (s *restoresSuite) Test_BlobsRestorer_getBlobsOIds() {
 		st := new(testutils.StorageMock)
 		st.On("GetObject", ctx, mock.Anything).Return(objSrc, nil)
 
-		br := NewBlobsRestorer(&toc.Entry{}, st, false)
+		br := NewBlobsRestorer(&toc.Entry{}, st, "", false)
 		loOids, err := br.getBlobsOids(ctx)
 		s.Require().NoError(err)
 		s.Require().Len(loOids, 2)
@@ -43,7 +46,7 @@ func (s *restoresSuite) Test_BlobsRestorer_getBlobsOIds() {
 		st.On("GetObject", ctx, mock.Anything).
 			Return(nil, errors.New("test err"))
 
-		br := NewBlobsRestorer(&toc.Entry{}, st, false)
+		br := NewBlobsRestorer(&toc.Entry{}, st, "", false)
 		_, err := br.getBlobsOids(ctx)
 		s.Require().Error(err)
 	})
@@ -59,7 +62,7 @@ func (s *restoresSuite) Test_BlobsRestorer_getBlobsOIds() {
 		st := new(testutils.StorageMock)
 		st.On("GetObject", ctx, mock.Anything).Return(objSrc, nil)
 
-		br := NewBlobsRestorer(&toc.Entry{}, st, false)
+		br := NewBlobsRestorer(&toc.Entry{}, st, "", false)
 		_, err := br.getBlobsOids(ctx)
 		s.Require().ErrorContains(err, "parse oid")
 	})
@@ -84,7 +87,7 @@ func (s *restoresSuite) Test_BlobsRestorer_getLargeObjectDataReader() {
 		st.On("GetObject", ctx, "blob_123.dat.gz").
 			Return(objSrc, nil)
 
-		br := NewBlobsRestorer(&toc.Entry{}, st, false)
+		br := NewBlobsRestorer(&toc.Entry{}, st, "", false)
 		r, err := br.getLargeObjectDataReader(ctx, 123)
 		s.Require().NoError(err)
 		s.Require().NotNil(r)
@@ -102,7 +105,7 @@ func (s *restoresSuite) Test_BlobsRestorer_getLargeObjectDataReader() {
 		st.On("GetObject", ctx, "blob_123.dat.gz").
 			Return(nil, errors.New("test err"))
 
-		br := NewBlobsRestorer(&toc.Entry{}, st, false)
+		br := NewBlobsRestorer(&toc.Entry{}, st, "", false)
 		_, err := br.getLargeObjectDataReader(ctx, 123)
 		s.Require().Error(err)
 		st.AssertNumberOfCalls(s.T(), "GetObject", 1)
@@ -134,7 +137,7 @@ func (s *restoresSuite) Test_BlobsRestorer_restoreLargeObjectData() {
 		s.Require().NoError(err)
 		st := new(testutils.StorageMock)
 
-		br := NewBlobsRestorer(&toc.Entry{}, st, false)
+		br := NewBlobsRestorer(&toc.Entry{}, st, "", false)
 		tx, err := conn.Begin(ctx)
 		s.Require().NoError(err)
 		defer tx.Rollback(ctx) // nolint: errcheck
@@ -197,7 +200,7 @@ func (s *restoresSuite) Test_BlobsRestorer_Execute() {
 		st.On("GetObject", mock.Anything, fmt.Sprintf("blob_%d.dat.gz", loOid1)).Return(obj1, nil)
 		st.On("GetObject", mock.Anything, fmt.Sprintf("blob_%d.dat.gz", loOid2)).Return(obj2, nil)
 
-		br := NewBlobsRestorer(&toc.Entry{}, st, false)
+		br := NewBlobsRestorer(&toc.Entry{}, st, "", false)
 		err = br.Execute(ctx, utils.NewPGConn(conn))
 		s.Require().NoError(err)
 
@@ -220,3 +223,49 @@ func (s *restoresSuite) Test_BlobsRestorer_Execute() {
 
 	})
 }
+
+func TestBlobsRestorer_WriteScript(t *testing.T) {
+	ctx := context.Background()
+	st := new(testutils.StorageMock)
+	st.On("GetObject", ctx, "blobs.toc").
+		Return(io.NopCloser(bytes.NewBufferString("26176 blob_26176.dat\n")), nil)
+	st.On("GetObject", ctx, "blob_26176.dat").
+		Return(io.NopCloser(bytes.NewBufferString("abc")), nil)
+
+	br := NewBlobsRestorer(&toc.Entry{}, st, ioutils.CodecNone, false)
+	buf := new(bytes.Buffer)
+	require.NoError(t, br.WriteScript(ctx, buf))
+	assert.Equal(
+		t,
+		"BEGIN;\n\n"+
+			"SELECT pg_catalog.lo_open('26176', 131072);\n"+
+			"SELECT pg_catalog.lowrite(0, '\\x616263');\n"+
+			"SELECT pg_catalog.lo_close(0);\n\n"+
+			"COMMIT;\n\n",
+		buf.String(),
+	)
+}
+
+func TestBlobsRestorer_ReadLargeObjects(t *testing.T) {
+	ctx := context.Background()
+	st := new(testutils.StorageMock)
+	st.On("GetObject", ctx, "blobs.toc").
+		Return(io.NopCloser(bytes.NewBufferString("26176 blob_26176.dat\n26177 blob_26177.dat\n")), nil)
+	st.On("GetObject", ctx, "blob_26176.dat").
+		Return(io.NopCloser(bytes.NewBufferString("abc")), nil)
+	st.On("GetObject", ctx, "blob_26177.dat").
+		Return(io.NopCloser(bytes.NewBufferString("def")), nil)
+
+	br := NewBlobsRestorer(&toc.Entry{}, st, ioutils.CodecNone, false)
+	res := make(map[uint32]string)
+	err := br.ReadLargeObjects(ctx, func(oid uint32, r io.Reader) error {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		res[oid] = string(data)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[uint32]string{26176: "abc", 26177: "def"}, res)
+}
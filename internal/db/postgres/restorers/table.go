@@ -104,6 +104,11 @@ func (td *TableRestorer) Execute(ctx context.Context, conn utils.PGConnector) er
 		}
 	}
 
+	if err = r.Close(); err != nil {
+		rollbackTransaction(ctx, tx, td.entry)
+		return fmt.Errorf("cannot close storage object (restoring %s): %w", td.DebugInfo(), err)
+	}
+
 	if err = tx.Commit(ctx); err != nil {
 		return fmt.Errorf("cannot commit transaction (restoring %s): %w", td.DebugInfo(), err)
 	}
@@ -43,6 +43,8 @@ type TableRestorerInsertFormat struct {
 	query            string
 	globalExclusions *domains.GlobalDataRestorationErrorExclusions
 	tableExclusion   *domains.TablesDataRestorationErrorExclusions
+	// mergeColumns - the columns used for ON CONFLICT DO UPDATE clause if the merge restoration is set
+	mergeColumns *mergeColumns
 }
 
 func NewTableRestorerInsertFormat(
@@ -81,6 +83,16 @@ func NewTableRestorerInsertFormat(
 	}
 }
 
+// SetMerge - sets the merge restoration settings. The rows that conflict with the existing rows are updated
+func (td *TableRestorerInsertFormat) SetMerge(merge *domains.TableMerge) error {
+	columns, err := newMergeColumns(td.Table, merge)
+	if err != nil {
+		return err
+	}
+	td.mergeColumns = columns
+	return nil
+}
+
 func (td *TableRestorerInsertFormat) GetEntry() *toc.Entry {
 	return td.entry
 }
@@ -106,6 +118,10 @@ func (td *TableRestorerInsertFormat) Execute(ctx context.Context, conn utils.PGC
 		log.Warn().Err(err).Msg("error streaming pgcopy data")
 		return nil
 	}
+	// The rows are inserted one by one, so the checksum mismatch that is detected on close is only reported
+	if err = r.Close(); err != nil {
+		return fmt.Errorf("cannot close storage object (restoring %s): %w", td.DebugInfo(), err)
+	}
 	return nil
 }
 
@@ -150,41 +166,72 @@ func (td *TableRestorerInsertFormat) streamInsertData(ctx context.Context, conn
 
 func (td *TableRestorerInsertFormat) generateInsertStmt(onConflictDoNothing bool) string {
 	var placeholders []string
-	var columnNames []string
 	columns := getRealColumns(td.Table.Columns)
 	for i := 0; i < len(columns); i++ {
-		column := fmt.Sprintf(`"%s"`, columns[i].Name)
-		columnNames = append(columnNames, column)
 		placeholders = append(placeholders, fmt.Sprintf("$%d", i+1))
 	}
 	var onConflict string
-	if onConflictDoNothing {
+	if td.mergeColumns != nil {
+		onConflict = td.mergeColumns.onConflictDoUpdateClause()
+	} else if onConflictDoNothing {
 		onConflict = " ON CONFLICT DO NOTHING"
 	}
+	return buildInsertStmt(td.entry, td.Table, placeholders, td.opt.OverridingSystemValue, onConflict)
+}
 
-	overridingSystemValue := ""
-	if td.opt.OverridingSystemValue {
-		overridingSystemValue = "OVERRIDING SYSTEM VALUE "
+// GenerateInsertStmt - returns the INSERT statement of the row with the values as literals. It is used for writing
+// the table data into the plain SQL script. The literals require standard_conforming_strings to be on
+func GenerateInsertStmt(
+	entry *toc.Entry, t *toolkit.Table, row *pgcopy.Row, overridingSystemValue bool,
+) (string, error) {
+	columnsCount := len(getRealColumns(t.Columns))
+	if row.Length() != columnsCount {
+		return "", fmt.Errorf("row has %d columns but the table has %d columns", row.Length(), columnsCount)
 	}
+	values := make([]string, 0, row.Length())
+	for i := 0; i < row.Length(); i++ {
+		v, err := row.GetColumn(i)
+		if err != nil {
+			return "", fmt.Errorf("error getting column %d: %w", i, err)
+		}
+		if v.IsNull {
+			values = append(values, "NULL")
+			continue
+		}
+		values = append(values, quoteLiteral(v.Data))
+	}
+	return buildInsertStmt(entry, t, values, overridingSystemValue, "") + ";", nil
+}
 
-	tableName := *td.entry.Tag
-	tableSchema := *td.entry.Namespace
+// buildInsertStmt - returns the INSERT statement with the provided values. The values are either placeholders or
+// literals
+func buildInsertStmt(
+	entry *toc.Entry, t *toolkit.Table, values []string, overridingSystemValue bool, onConflict string,
+) string {
+	columns := getRealColumns(t.Columns)
+	columnNames := make([]string, 0, len(columns))
+	for _, c := range columns {
+		columnNames = append(columnNames, fmt.Sprintf(`"%s"`, c.Name))
+	}
 
-	if td.Table.RootPtOid != 0 {
-		tableName = td.Table.RootPtName
-		tableSchema = td.Table.RootPtSchema
+	overriding := ""
+	if overridingSystemValue {
+		overriding = "OVERRIDING SYSTEM VALUE "
 	}
 
-	res := fmt.Sprintf(
-		`INSERT INTO %s.%s (%s) %sVALUES(%s)%s`,
-		tableSchema,
-		tableName,
+	return fmt.Sprintf(
+		`INSERT INTO %s (%s) %sVALUES(%s)%s`,
+		getTargetTableName(entry, t),
 		strings.Join(columnNames, ", "),
-		overridingSystemValue,
-		strings.Join(placeholders, ", "),
+		overriding,
+		strings.Join(values, ", "),
 		onConflict,
 	)
-	return res
+}
+
+// quoteLiteral - returns the value as the SQL string literal
+func quoteLiteral(v []byte) string {
+	return "'" + strings.ReplaceAll(string(v), "'", "''") + "'"
 }
 
 func (td *TableRestorerInsertFormat) insertData(
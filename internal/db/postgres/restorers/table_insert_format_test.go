@@ -4,9 +4,13 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
+	"github.com/greenmaskio/greenmask/internal/db/postgres/pgcopy"
 	"github.com/greenmaskio/greenmask/internal/db/postgres/pgrestore"
 	"github.com/greenmaskio/greenmask/internal/db/postgres/toc"
 	"github.com/greenmaskio/greenmask/internal/db/postgres/utils"
@@ -196,3 +200,21 @@ func (s *restoresSuite) Test_TableRestorerInsertFormat_check_triggers_errors() {
 		s.Require().NoError(err)
 	})
 }
+
+func TestGenerateInsertStmt(t *testing.T) {
+	row := pgcopy.NewRow(pgcopy.UseDynamicSize)
+	require.NoError(t, row.Decode([]byte("1\t\\N\tO'Brien\\\\path")))
+	res, err := GenerateInsertStmt(newMergeTestEntry(), newMergeTestTable(), row, true)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		`INSERT INTO "public"."users" ("id", "name", "email") OVERRIDING SYSTEM VALUE `+
+			`VALUES('1', NULL, 'O''Brien\path');`,
+		res,
+	)
+
+	row = pgcopy.NewRow(pgcopy.UseDynamicSize)
+	require.NoError(t, row.Decode([]byte("1\tname")))
+	_, err = GenerateInsertStmt(newMergeTestEntry(), newMergeTestTable(), row, false)
+	require.ErrorContains(t, err, "row has 2 columns but the table has 3 columns")
+}
@@ -0,0 +1,314 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restorers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/pgrestore"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/toc"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/utils"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+const (
+	// MergeMethodInsert - merge the data using INSERT ... ON CONFLICT DO UPDATE statement
+	MergeMethodInsert = "insert"
+	// MergeMethodMerge - merge the data using MERGE statement. It requires PostgreSQL 15 or higher
+	MergeMethodMerge = "merge"
+)
+
+// ValidateTableMerge - checks the merge restoration settings of the table
+func ValidateTableMerge(m *domains.TableMerge) error {
+	if m.Name == "" {
+		return fmt.Errorf("table name is required")
+	}
+	switch m.Method {
+	case "", MergeMethodInsert, MergeMethodMerge:
+	default:
+		return fmt.Errorf(
+			"unknown merge method \"%s\": expected one of %s, %s", m.Method, MergeMethodInsert, MergeMethodMerge,
+		)
+	}
+	return nil
+}
+
+// mergeColumns - the columns used for the merge statements generation
+type mergeColumns struct {
+	// all - the real columns of the table in the order they are stored in the dump
+	all []string
+	// conflict - the columns that identify the row
+	conflict []string
+	// update - the columns that are updated if the row exists
+	update []string
+}
+
+// newMergeColumns - resolves the merge columns using the table definition. The conflict columns are the primary key
+// by default, the update columns are all the real columns except the conflict columns by default
+func newMergeColumns(t *toolkit.Table, m *domains.TableMerge) (*mergeColumns, error) {
+	res := &mergeColumns{}
+	for _, c := range getRealColumns(t.Columns) {
+		res.all = append(res.all, c.Name)
+	}
+
+	res.conflict = m.ConflictColumns
+	if len(res.conflict) == 0 {
+		res.conflict = t.PrimaryKey
+	}
+	if len(res.conflict) == 0 {
+		return nil, fmt.Errorf(
+			"table %s.%s does not have primary key: conflict_columns must be set", t.Schema, t.Name,
+		)
+	}
+	for _, name := range res.conflict {
+		if !slices.Contains(res.all, name) {
+			return nil, fmt.Errorf("conflict column \"%s\" is not found in table %s.%s", name, t.Schema, t.Name)
+		}
+	}
+
+	res.update = m.UpdateColumns
+	if len(res.update) == 0 {
+		for _, name := range res.all {
+			if !slices.Contains(res.conflict, name) {
+				res.update = append(res.update, name)
+			}
+		}
+	}
+	for _, name := range res.update {
+		if !slices.Contains(res.all, name) {
+			return nil, fmt.Errorf("update column \"%s\" is not found in table %s.%s", name, t.Schema, t.Name)
+		}
+	}
+	return res, nil
+}
+
+// onConflictDoUpdateClause - generates ON CONFLICT clause that updates the existing row. If there are no columns to
+// update the conflicting row is skipped
+func (mc *mergeColumns) onConflictDoUpdateClause() string {
+	if len(mc.update) == 0 {
+		return fmt.Sprintf(" ON CONFLICT (%s) DO NOTHING", quoteColumns(mc.conflict, ""))
+	}
+	setList := make([]string, 0, len(mc.update))
+	for _, name := range mc.update {
+		setList = append(setList, fmt.Sprintf(`"%s" = EXCLUDED."%s"`, name, name))
+	}
+	return fmt.Sprintf(
+		" ON CONFLICT (%s) DO UPDATE SET %s", quoteColumns(mc.conflict, ""), strings.Join(setList, ", "),
+	)
+}
+
+// TableRestorerMerge - restores the table data into the existing rows of the table. The data is copied into the
+// temporary table and then merged into the target table in the same transaction
+type TableRestorerMerge struct {
+	*TableRestorer
+	Table   *toolkit.Table
+	entry   *toc.Entry
+	method  string
+	columns *mergeColumns
+}
+
+func NewTableRestorerMerge(
+	entry *toc.Entry, t *toolkit.Table, st storages.Storager, opt *pgrestore.DataSectionSettings,
+	merge *domains.TableMerge,
+) (*TableRestorerMerge, error) {
+	columns, err := newMergeColumns(t, merge)
+	if err != nil {
+		return nil, err
+	}
+
+	// The data is copied into the temporary table instead of the target table
+	copyEntry := entry.Copy()
+	copyStmt := fmt.Sprintf(
+		"COPY %s (%s) FROM stdin;", mergeTempTableName(entry), quoteColumns(columns.all, ""),
+	)
+	copyEntry.CopyStmt = &copyStmt
+
+	method := merge.Method
+	if method == "" {
+		method = MergeMethodInsert
+	}
+
+	return &TableRestorerMerge{
+		TableRestorer: NewTableRestorer(copyEntry, st, opt),
+		Table:         t,
+		entry:         entry,
+		method:        method,
+		columns:       columns,
+	}, nil
+}
+
+func (td *TableRestorerMerge) GetEntry() *toc.Entry {
+	return td.entry
+}
+
+func (td *TableRestorerMerge) Execute(ctx context.Context, conn utils.PGConnector) error {
+	if err := td.execute(ctx, conn.GetConn()); err != nil {
+		if td.opt.ExitOnError {
+			return fmt.Errorf("unable to merge table data: %w", err)
+		}
+		log.Warn().
+			Err(err).
+			Str("objectName", td.DebugInfo()).
+			Msg("unable to merge table data")
+	}
+	return nil
+}
+
+func (td *TableRestorerMerge) execute(ctx context.Context, conn *pgx.Conn) error {
+	r, err := td.getObject(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot get storage object: %w", err)
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			log.Warn().
+				Err(err).
+				Str("objectName", td.DebugInfo()).
+				Msg("cannot close storage object")
+		}
+	}()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot start transaction (restoring %s): %w", td.DebugInfo(), err)
+	}
+	if err = td.setupTx(ctx, tx); err != nil {
+		rollbackTransaction(ctx, tx, td.entry)
+		return fmt.Errorf("cannot setup transaction: %w", err)
+	}
+
+	createStmt := td.generateCreateTempTableStmt()
+	log.Debug().
+		Str("createStmt", createStmt).
+		Msg("creating temporary table for merge")
+	if _, err = tx.Exec(ctx, createStmt); err != nil {
+		rollbackTransaction(ctx, tx, td.entry)
+		return fmt.Errorf("cannot create temporary table: %w", err)
+	}
+
+	if err = td.restoreCopy(ctx, tx.Conn().PgConn().Frontend(), r); err != nil {
+		rollbackTransaction(ctx, tx, td.entry)
+		return fmt.Errorf("cannot copy data into temporary table: %w", err)
+	}
+
+	mergeStmt := td.generateMergeStmt()
+	log.Debug().
+		Str("mergeStmt", mergeStmt).
+		Msg("merging table data")
+	if _, err = tx.Exec(ctx, mergeStmt); err != nil {
+		rollbackTransaction(ctx, tx, td.entry)
+		return fmt.Errorf("cannot merge data from temporary table: %w", err)
+	}
+
+	if err = td.resetTx(ctx, tx); err != nil {
+		rollbackTransaction(ctx, tx, td.entry)
+		return fmt.Errorf("cannot reset transaction: %w", err)
+	}
+
+	if err = r.Close(); err != nil {
+		rollbackTransaction(ctx, tx, td.entry)
+		return fmt.Errorf("cannot close storage object (restoring %s): %w", td.DebugInfo(), err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("cannot commit transaction (restoring %s): %w", td.DebugInfo(), err)
+	}
+	return nil
+}
+
+// generateCreateTempTableStmt - generates the statement that creates the temporary table with the target table
+// structure. The table is dropped on the transaction commit
+func (td *TableRestorerMerge) generateCreateTempTableStmt() string {
+	return fmt.Sprintf(
+		"CREATE TEMP TABLE %s (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP",
+		mergeTempTableName(td.entry), getTargetTableName(td.entry, td.Table),
+	)
+}
+
+// generateMergeStmt - generates the statement that merges the temporary table data into the target table
+func (td *TableRestorerMerge) generateMergeStmt() string {
+	overridingSystemValue := ""
+	if td.opt.OverridingSystemValue {
+		overridingSystemValue = "OVERRIDING SYSTEM VALUE "
+	}
+	target := getTargetTableName(td.entry, td.Table)
+	source := mergeTempTableName(td.entry)
+
+	if td.method == MergeMethodMerge {
+		onList := make([]string, 0, len(td.columns.conflict))
+		for _, name := range td.columns.conflict {
+			onList = append(onList, fmt.Sprintf(`t."%s" = s."%s"`, name, name))
+		}
+		var matched string
+		if len(td.columns.update) > 0 {
+			setList := make([]string, 0, len(td.columns.update))
+			for _, name := range td.columns.update {
+				setList = append(setList, fmt.Sprintf(`"%s" = s."%s"`, name, name))
+			}
+			matched = fmt.Sprintf(" WHEN MATCHED THEN UPDATE SET %s", strings.Join(setList, ", "))
+		}
+		return fmt.Sprintf(
+			"MERGE INTO %s AS t USING %s AS s ON %s%s WHEN NOT MATCHED THEN INSERT (%s) %sVALUES (%s)",
+			target,
+			source,
+			strings.Join(onList, " AND "),
+			matched,
+			quoteColumns(td.columns.all, ""),
+			overridingSystemValue,
+			quoteColumns(td.columns.all, "s."),
+		)
+	}
+
+	columns := quoteColumns(td.columns.all, "")
+	return fmt.Sprintf(
+		"INSERT INTO %s (%s) %sSELECT %s FROM %s%s",
+		target,
+		columns,
+		overridingSystemValue,
+		columns,
+		source,
+		td.columns.onConflictDoUpdateClause(),
+	)
+}
+
+// mergeTempTableName - returns the name of the temporary table that is used for the table data merge
+func mergeTempTableName(entry *toc.Entry) string {
+	return fmt.Sprintf(`"greenmask_merge_%d"`, entry.DumpId)
+}
+
+// getTargetTableName - returns the name of the table the data is restored into. The data of the partition is
+// restored via the root partitioned table if the table is a partition
+func getTargetTableName(entry *toc.Entry, t *toolkit.Table) string {
+	if t.RootPtOid != 0 {
+		return fmt.Sprintf("%s.%s", t.RootPtSchema, t.RootPtName)
+	}
+	return fmt.Sprintf("%s.%s", *entry.Namespace, *entry.Tag)
+}
+
+func quoteColumns(columns []string, prefix string) string {
+	res := make([]string, 0, len(columns))
+	for _, name := range columns {
+		res = append(res, fmt.Sprintf(`%s"%s"`, prefix, name))
+	}
+	return strings.Join(res, ", ")
+}
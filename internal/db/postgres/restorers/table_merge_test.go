@@ -0,0 +1,209 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restorers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/pgrestore"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/toc"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/utils"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/utils/testutils"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+func newMergeTestTable() *toolkit.Table {
+	return &toolkit.Table{
+		Schema:     "public",
+		Name:       "users",
+		PrimaryKey: []string{"id"},
+		Columns: []*toolkit.Column{
+			{Name: "id", TypeName: "int4"},
+			{Name: "name", TypeName: "text"},
+			{Name: "email", TypeName: "text"},
+			{Name: "search", TypeName: "tsvector", IsGenerated: true},
+		},
+	}
+}
+
+func newMergeTestEntry() *toc.Entry {
+	schemaName := `"public"`
+	tableName := `"users"`
+	fileName := "3456.dat.gz"
+	copyStmt := `COPY "public"."users" ("id", "name", "email") FROM stdin;`
+	return &toc.Entry{
+		DumpId:    3456,
+		Namespace: &schemaName,
+		Tag:       &tableName,
+		FileName:  &fileName,
+		CopyStmt:  &copyStmt,
+	}
+}
+
+func TestValidateTableMerge(t *testing.T) {
+	require.NoError(t, ValidateTableMerge(&domains.TableMerge{Name: "users"}))
+	require.NoError(t, ValidateTableMerge(&domains.TableMerge{Name: "users", Method: MergeMethodMerge}))
+	require.ErrorContains(t, ValidateTableMerge(&domains.TableMerge{}), "table name is required")
+	require.ErrorContains(
+		t, ValidateTableMerge(&domains.TableMerge{Name: "users", Method: "replace"}), "unknown merge method",
+	)
+}
+
+func TestNewMergeColumns(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		mc, err := newMergeColumns(newMergeTestTable(), &domains.TableMerge{Name: "users"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"id", "name", "email"}, mc.all)
+		assert.Equal(t, []string{"id"}, mc.conflict)
+		assert.Equal(t, []string{"name", "email"}, mc.update)
+	})
+
+	t.Run("custom columns", func(t *testing.T) {
+		mc, err := newMergeColumns(newMergeTestTable(), &domains.TableMerge{
+			Name:            "users",
+			ConflictColumns: []string{"email"},
+			UpdateColumns:   []string{"name"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"email"}, mc.conflict)
+		assert.Equal(t, []string{"name"}, mc.update)
+	})
+
+	t.Run("no primary key", func(t *testing.T) {
+		table := newMergeTestTable()
+		table.PrimaryKey = nil
+		_, err := newMergeColumns(table, &domains.TableMerge{Name: "users"})
+		require.ErrorContains(t, err, "conflict_columns must be set")
+	})
+
+	t.Run("unknown column", func(t *testing.T) {
+		_, err := newMergeColumns(newMergeTestTable(), &domains.TableMerge{
+			Name:          "users",
+			UpdateColumns: []string{"search"},
+		})
+		require.ErrorContains(t, err, `update column "search" is not found`)
+	})
+}
+
+func TestTableRestorerMerge_statements(t *testing.T) {
+	entry := newMergeTestEntry()
+	opt := &pgrestore.DataSectionSettings{}
+
+	t.Run("insert", func(t *testing.T) {
+		tr, err := NewTableRestorerMerge(entry, newMergeTestTable(), nil, opt, &domains.TableMerge{Name: "users"})
+		require.NoError(t, err)
+		assert.Equal(
+			t, `COPY "greenmask_merge_3456" ("id", "name", "email") FROM stdin;`, *tr.TableRestorer.entry.CopyStmt,
+		)
+		assert.Equal(t, `COPY "public"."users" ("id", "name", "email") FROM stdin;`, *tr.GetEntry().CopyStmt)
+		assert.Equal(
+			t,
+			`CREATE TEMP TABLE "greenmask_merge_3456" (LIKE "public"."users" INCLUDING DEFAULTS) ON COMMIT DROP`,
+			tr.generateCreateTempTableStmt(),
+		)
+		assert.Equal(
+			t,
+			`INSERT INTO "public"."users" ("id", "name", "email") SELECT "id", "name", "email" `+
+				`FROM "greenmask_merge_3456" ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name", `+
+				`"email" = EXCLUDED."email"`,
+			tr.generateMergeStmt(),
+		)
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		tr, err := NewTableRestorerMerge(
+			entry, newMergeTestTable(), nil, &pgrestore.DataSectionSettings{OverridingSystemValue: true},
+			&domains.TableMerge{Name: "users", Method: MergeMethodMerge, UpdateColumns: []string{"name"}},
+		)
+		require.NoError(t, err)
+		assert.Equal(
+			t,
+			`MERGE INTO "public"."users" AS t USING "greenmask_merge_3456" AS s ON t."id" = s."id" `+
+				`WHEN MATCHED THEN UPDATE SET "name" = s."name" `+
+				`WHEN NOT MATCHED THEN INSERT ("id", "name", "email") OVERRIDING SYSTEM VALUE `+
+				`VALUES (s."id", s."name", s."email")`,
+			tr.generateMergeStmt(),
+		)
+	})
+}
+
+func TestTableRestorerInsertFormat_generateInsertStmt_merge(t *testing.T) {
+	tr := NewTableRestorerInsertFormat(
+		newMergeTestEntry(), newMergeTestTable(), nil, &pgrestore.DataSectionSettings{}, nil,
+	)
+	require.NoError(t, tr.SetMerge(&domains.TableMerge{Name: "users", UpdateColumns: []string{"email"}}))
+	assert.Equal(
+		t,
+		`INSERT INTO "public"."users" ("id", "name", "email") VALUES($1, $2, $3) `+
+			`ON CONFLICT ("id") DO UPDATE SET "email" = EXCLUDED."email"`,
+		tr.generateInsertStmt(true),
+	)
+}
+
+func (s *restoresSuite) Test_TableRestorerMerge_Execute() {
+	ctx := context.Background()
+	schemaName := "public"
+	tableName := "users"
+	fileName := "test_table"
+	entry := &toc.Entry{
+		DumpId:    1,
+		Namespace: &schemaName,
+		Tag:       &tableName,
+		FileName:  &fileName,
+	}
+	data := "1\tAlice Updated\talice@example.com\t2024-01-01 00:00:00\n" +
+		"100\tCharlie\tcharlie@example.com\t2024-01-01 00:00:00\n"
+	buf := new(bytes.Buffer)
+	gzData := gzip.NewWriter(buf)
+	_, err := gzData.Write([]byte(data))
+	s.Require().NoError(err)
+	s.Require().NoError(gzData.Close())
+
+	st := new(testutils.StorageMock)
+	st.On("GetObject", ctx, mock.Anything).Return(&readCloserMock{Buffer: buf}, nil)
+	t := &toolkit.Table{
+		Schema:     schemaName,
+		Name:       tableName,
+		PrimaryKey: []string{"id"},
+		Columns: []*toolkit.Column{
+			{Name: "id", TypeName: "int4"},
+			{Name: "name", TypeName: "text"},
+			{Name: "email", TypeName: "text"},
+			{Name: "created_at", TypeName: "timestamp"},
+		},
+	}
+	tr, err := NewTableRestorerMerge(
+		entry, t, st, &pgrestore.DataSectionSettings{ExitOnError: true}, &domains.TableMerge{Name: tableName},
+	)
+	s.Require().NoError(err)
+
+	conn, err := s.GetConnection(ctx)
+	s.Require().NoError(err)
+	s.Require().NoError(tr.Execute(ctx, utils.NewPGConn(conn)))
+
+	var name string
+	s.Require().NoError(conn.QueryRow(ctx, "SELECT name FROM users WHERE id = 1").Scan(&name))
+	s.Assert().Equal("Alice Updated", name)
+	s.Require().NoError(conn.QueryRow(ctx, "SELECT name FROM users WHERE id = 100").Scan(&name))
+	s.Assert().Equal("Charlie", name)
+}
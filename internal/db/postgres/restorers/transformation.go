@@ -0,0 +1,84 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restorers
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/dumpers"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/entries"
+)
+
+// transformationReader - transforms the table COPY data while it is being restored. The data is transformed by the
+// same pipeline as in the dump and streamed through the pipe
+type transformationReader struct {
+	*io.PipeReader
+	src    io.ReadCloser
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newTransformationReader(
+	ctx context.Context, src io.ReadCloser, table *entries.Table,
+) (*transformationReader, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	eg, gtx := errgroup.WithContext(ctx)
+	pr, pw := io.Pipe()
+
+	pipeline, err := dumpers.NewTransformationPipeline(gtx, eg, table, pw)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("cannot initialize transformation pipeline: %w", err)
+	}
+	if err = pipeline.Init(gtx); err != nil {
+		cancel()
+		return nil, fmt.Errorf("error initializing transformation pipeline: %w", err)
+	}
+
+	tr := &transformationReader{
+		PipeReader: pr,
+		src:        src,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+	go func() {
+		defer close(tr.done)
+		err := dumpers.TransformCopyData(gtx, pipeline, src)
+		if doneErr := pipeline.Done(gtx); doneErr != nil && err == nil {
+			err = doneErr
+		}
+		if egErr := eg.Wait(); egErr != nil && err == nil {
+			err = egErr
+		}
+		// The nil error closes the pipe with io.EOF
+		pw.CloseWithError(err)
+	}()
+	return tr, nil
+}
+
+// Close - stops the transformation and closes the source reader
+func (tr *transformationReader) Close() error {
+	if err := tr.PipeReader.Close(); err != nil {
+		log.Warn().Err(err).Msg("error closing transformation pipe")
+	}
+	tr.cancel()
+	<-tr.done
+	return tr.src.Close()
+}
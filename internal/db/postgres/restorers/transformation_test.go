@@ -0,0 +1,118 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restorers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	runtimeContext "github.com/greenmaskio/greenmask/internal/db/postgres/context"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/entries"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/pgrestore"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/toc"
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/internal/utils/testutils"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+func newTransformationTestTable(t *testing.T) *entries.Table {
+	table := &toolkit.Table{
+		Schema: "public",
+		Name:   "users",
+		Oid:    16384,
+		Columns: []*toolkit.Column{
+			{Idx: 0, Name: "id", TypeName: "int4", TypeOid: pgtype.Int4OID, Num: 1},
+			{Idx: 1, Name: "email", TypeName: "text", TypeOid: pgtype.TextOID, Num: 2},
+		},
+	}
+	cfg := &domains.Table{
+		Schema: "public",
+		Name:   "users",
+		Transformers: []*domains.TransformerConfig{
+			{
+				Name: "Replace",
+				Params: toolkit.StaticParameters{
+					"column": toolkit.ParamsValue("email"),
+					"value":  toolkit.ParamsValue("masked@example.com"),
+				},
+			},
+		},
+	}
+	res, warns, err := runtimeContext.NewOfflineTransformationTable(
+		context.Background(), table, cfg, utils.DefaultTransformerRegistry,
+	)
+	require.NoError(t, err)
+	require.False(t, warns.IsFatal(), warns)
+	return res
+}
+
+func TestTransformationReader(t *testing.T) {
+	src := newReadCloserMock()
+	src.WriteString("1\talice@example.com\n2\tbob@example.com\n3\t\\N\n\\.\n\n")
+
+	r, err := newTransformationReader(context.Background(), src, newTransformationTestTable(t))
+	require.NoError(t, err)
+	res, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	// The NULL values are kept by the Replace transformer by default
+	assert.Equal(t, "1\tmasked@example.com\n2\tmasked@example.com\n3\t\\N\n\\.\n\n", string(res))
+}
+
+func TestTransformationReader_Close(t *testing.T) {
+	src := newReadCloserMock()
+	for i := 0; i < 10000; i++ {
+		src.WriteString("1\talice@example.com\n")
+	}
+
+	r, err := newTransformationReader(context.Background(), src, newTransformationTestTable(t))
+	require.NoError(t, err)
+	buf := make([]byte, 10)
+	_, err = r.Read(buf)
+	require.NoError(t, err)
+	// The transformation is stopped if the data is not read till the end
+	require.NoError(t, r.Close())
+}
+
+func TestRestoreBase_getObject_Transformation(t *testing.T) {
+	ctx := context.Background()
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	_, err := gz.Write([]byte("1\talice@example.com\n\\.\n\n"))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	st := new(testutils.StorageMock)
+	st.On("GetObject", ctx, mock.Anything).Return(&readCloserMock{Buffer: buf}, nil)
+	fileName := "16384.dat.gz"
+	tr := NewTableRestorer(&toc.Entry{FileName: &fileName}, st, &pgrestore.DataSectionSettings{})
+	tr.SetTransformation(newTransformationTestTable(t))
+
+	r, err := tr.getObject(ctx)
+	require.NoError(t, err)
+	res, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, "1\tmasked@example.com\n\\.\n\n", string(res))
+}
@@ -0,0 +1,60 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+// TableState - the table change indicators that were collected during the dump. The next incremental dump compares
+// them with the current values for deciding whether the table data has been changed
+type TableState struct {
+	Oid    toolkit.Oid `json:"oid" yaml:"oid"`
+	Schema string      `json:"schema" yaml:"schema"`
+	Name   string      `json:"name" yaml:"name"`
+	DumpId int32       `json:"dumpId" yaml:"dumpId"`
+	// RelFileNode - the table file node. It is changed by TRUNCATE, VACUUM FULL and CLUSTER
+	RelFileNode toolkit.Oid `json:"relFileNode" yaml:"relFileNode"`
+	// NTupIns, NTupUpd, NTupDel - cumulative statistics counters from pg_stat_user_tables
+	NTupIns int64 `json:"nTupIns" yaml:"nTupIns"`
+	NTupUpd int64 `json:"nTupUpd" yaml:"nTupUpd"`
+	NTupDel int64 `json:"nTupDel" yaml:"nTupDel"`
+	// WatermarkColumn - the column name from the table config that is used as the watermark
+	WatermarkColumn string `json:"watermarkColumn,omitempty" yaml:"watermarkColumn,omitempty"`
+	// Watermark - max value of the watermark column in text representation. It is nil if the table is empty or
+	// watermark column is not set
+	Watermark *string `json:"watermark,omitempty" yaml:"watermark,omitempty"`
+}
+
+// ObjectReference - reference to the object that is stored in another dump. It is used by incremental dumps
+// instead of the table data file
+type ObjectReference struct {
+	// DumpId - id of the dump that contains the object
+	DumpId string `json:"dumpId" yaml:"dumpId"`
+	// FileName - object file name in the referenced dump
+	FileName string `json:"fileName" yaml:"fileName"`
+	// Chunks - the chunk file names in the referenced dump. The chunks of the entry are named by its dump id, so they
+	// do not collide with the chunks of the other tables when the referenced data is copied into the dump
+	Chunks []string `json:"chunks,omitempty" yaml:"chunks,omitempty"`
+}
+
+// GetChunks - returns the chunk file names of the entry in the referenced dump. The dumps created before the chunks
+// were referenced by their own names store the referenced chunk names in the entry
+func (r *ObjectReference) GetChunks(e *Entry) []string {
+	if len(r.Chunks) > 0 {
+		return r.Chunks
+	}
+	return e.Chunks
+}
@@ -16,6 +16,7 @@ package storage
 
 import (
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -28,6 +29,10 @@ import (
 type ObjectSizeStat struct {
 	Original   int64
 	Compressed int64
+	// Checksums - map of the object file name to its SHA-256 checksum
+	Checksums map[string]string
+	// Chunks - data file names of the table chunks
+	Chunks []string
 }
 
 type Header struct {
@@ -42,6 +47,13 @@ type Header struct {
 	DumpedBy        string    `json:"dumpedBy" yaml:"dumpedBy"`
 	TocFileSize     int64     `json:"tocFileSize" yaml:"tocFileSize"`
 	Compression     int32     `json:"compression" yaml:"compression"`
+	// TocChecksum - SHA-256 checksum of toc.dat
+	TocChecksum string `json:"tocChecksum,omitempty" yaml:"tocChecksum,omitempty"`
+	// Codec - compression codec of the data files. Empty codec is gzip, it is used for the dumps created before
+	// the codecs were introduced
+	Codec string `json:"codec,omitempty" yaml:"codec,omitempty"`
+	// CompressionLevel - compression level of the codec. 0 - the codec default level
+	CompressionLevel int `json:"compressionLevel,omitempty" yaml:"compressionLevel,omitempty"`
 }
 
 type Entry struct {
@@ -57,6 +69,12 @@ type Entry struct {
 	CompressedSize int64   `json:"compressedSize" yaml:"compressedSize"`
 	FileName       string  `json:"fileName" yaml:"fileName"`
 	Dependencies   []int32 `json:"dependencies" yaml:"dependencies"`
+	// Checksums - map of the object file name to its SHA-256 checksum. If the object is stored in another dump (see
+	// Metadata.References) the file name of the referenced object is used
+	Checksums map[string]string `json:"checksums,omitempty" yaml:"checksums,omitempty"`
+	// Chunks - data file names of the table chunks if the table data is split into chunks. FileName is the logical
+	// name of the table data in this case and the object with such name does not exist
+	Chunks []string `json:"chunks,omitempty" yaml:"chunks,omitempty"`
 }
 
 type Metadata struct {
@@ -74,6 +92,57 @@ type Metadata struct {
 	Cycles            [][]string             `yaml:"cycles" json:"cycles"`
 	TableOidToDumpId  map[toolkit.Oid]int32  `yaml:"table_dump_id" json:"table_dump_id"`
 	DumpIdsToTableOid map[int32]toolkit.Oid  `yaml:"dump_id_table" json:"dump_id_table"`
+	// TransformationChecksum - checksum of the transformation config. Incremental dump reuses the data of the
+	// previous dump only if the checksums are equal
+	TransformationChecksum string `yaml:"transformation_checksum" json:"transformation_checksum,omitempty"`
+	// TablesState - the table change indicators collected during the dump
+	TablesState []*TableState `yaml:"tables_state" json:"tables_state,omitempty"`
+	// IncrementalFrom - id of the dump that was used as the base for the incremental dump
+	IncrementalFrom string `yaml:"incremental_from" json:"incremental_from,omitempty"`
+	// References - map of the table DumpId to the object that is stored in another dump
+	References map[int32]*ObjectReference `yaml:"references" json:"references,omitempty"`
+	// DriftAnonymizedColumns - the columns that were transformed by the default transformers because they appeared
+	// after the previous dump and schema_drift_policy is auto_anonymize
+	DriftAnonymizedColumns []*ColumnReference `yaml:"drift_anonymized_columns" json:"drift_anonymized_columns,omitempty"`
+}
+
+// ColumnReference - identifies the table column
+type ColumnReference struct {
+	Schema string `yaml:"schema" json:"schema"`
+	Table  string `yaml:"table" json:"table"`
+	Column string `yaml:"column" json:"column"`
+}
+
+// GetTableState - finds table state by schema and table name
+func (m *Metadata) GetTableState(schema, name string) (*TableState, bool) {
+	for _, ts := range m.TablesState {
+		if ts.Schema == schema && ts.Name == name {
+			return ts, true
+		}
+	}
+	return nil, false
+}
+
+// GetReferencedDumpIds - returns ids of the dumps that contain the objects referenced by the incremental dump
+func (m *Metadata) GetReferencedDumpIds() []string {
+	var res []string
+	for _, ref := range m.References {
+		if !slices.Contains(res, ref.DumpId) {
+			res = append(res, ref.DumpId)
+		}
+	}
+	slices.Sort(res)
+	return res
+}
+
+// GetEntry - finds entry by DumpId
+func (m *Metadata) GetEntry(dumpId int32) (*Entry, bool) {
+	for _, e := range m.Entries {
+		if e.DumpId == dumpId {
+			return e, true
+		}
+	}
+	return nil, false
 }
 
 func NewMetadata(
@@ -128,8 +197,8 @@ func NewMetadata(
 		}
 
 		var objCompressedSize, objOriginalSize int64
+		s := stats[entry.DumpId]
 		if entry.Section == toc.SectionData && *entry.Desc == toc.TableDataDesc {
-			s := stats[entry.DumpId]
 			objCompressedSize = s.Compressed
 			objOriginalSize = s.Original
 			totalCompressedSize += s.Compressed
@@ -156,6 +225,8 @@ func NewMetadata(
 				OriginalSize:   objOriginalSize,
 				CompressedSize: objCompressedSize,
 				Section:        section,
+				Checksums:      s.Checksums,
+				Chunks:         s.Chunks,
 			},
 		)
 	}
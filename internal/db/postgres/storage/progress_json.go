@@ -0,0 +1,67 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"time"
+
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+const (
+	TableProgressInProgress = "in-progress"
+	TableProgressDone       = "done"
+)
+
+// TableProgress - the state of the table data dumping
+type TableProgress struct {
+	DumpId         int32  `json:"dumpId" yaml:"dumpId"`
+	Schema         string `json:"schema" yaml:"schema"`
+	Name           string `json:"name" yaml:"name"`
+	Status         string `json:"status" yaml:"status"`
+	OriginalSize   int64  `json:"originalSize" yaml:"originalSize"`
+	CompressedSize int64  `json:"compressedSize" yaml:"compressedSize"`
+	Checksum       string `json:"checksum,omitempty" yaml:"checksum,omitempty"`
+	// Chunks - map of the chunk data file name to its checksum. It is set when the chunked table is done
+	Chunks map[string]string `json:"chunks,omitempty" yaml:"chunks,omitempty"`
+}
+
+// Progress - per-table progress manifest of the dump. It is written into the dump directory while the dump is
+// running and is used for resuming the interrupted dump
+type Progress struct {
+	StartedAt time.Time `json:"startedAt" yaml:"startedAt"`
+	// SchemaMaxDumpId - the max DumpId of the schema section TOC. The data section entries get DumpIds after it, so
+	// the dump can be resumed only if the schema TOC has the same value
+	SchemaMaxDumpId int32                          `json:"schemaMaxDumpId" yaml:"schemaMaxDumpId"`
+	Tables          map[toolkit.Oid]*TableProgress `json:"tables" yaml:"tables"`
+}
+
+func NewProgress(startedAt time.Time) *Progress {
+	return &Progress{
+		StartedAt: startedAt,
+		Tables:    make(map[toolkit.Oid]*TableProgress),
+	}
+}
+
+// MaxDumpId - returns the max DumpId assigned to the tables in the manifest
+func (p *Progress) MaxDumpId() int32 {
+	var res int32
+	for _, t := range p.Tables {
+		if t.DumpId > res {
+			res = t.DumpId
+		}
+	}
+	return res
+}
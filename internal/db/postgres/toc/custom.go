@@ -0,0 +1,253 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package toc
+
+import (
+	"bufio"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const customDataChunkSize = 64 * 1024
+
+var ErrCustomWriterIsNotStarted = errors.New("custom archive toc is not written")
+
+// CustomWriter - writes the pg_dump custom format (-Fc) archive. The header and the TOC are written first with unset
+// data offsets, then the data blocks are written. If the output is seekable, Close rewrites the TOC with the block
+// offsets. The size of the TOC does not depend on the offset values, so it is rewritten in place. Otherwise the
+// offsets are left unset in the same way as pg_dump does when it writes into the pipe, and pg_restore looks up the
+// data blocks by reading the archive
+type CustomWriter struct {
+	w        io.Writer
+	ws       io.WriteSeeker
+	tw       *Writer
+	toc      *Toc
+	entries  map[int32]*Entry
+	level    int
+	startPos int64
+	started  bool
+}
+
+// NewCustomWriter - creates the custom format writer for the copy of the toc. The data blocks are compressed with
+// zlib if the level is not 0, where -1 is the default zlib compression level
+func NewCustomWriter(w io.Writer, t *Toc, level int) *CustomWriter {
+	t = t.Copy()
+	t.Header.Format = ArchCustom
+	if t.Header.OffSize == 0 {
+		t.Header.OffSize = 8
+	}
+	t.Header.CompressionSpec = CompressionSpecification{
+		Algorithm: PgCompressionNone,
+	}
+	if level != 0 {
+		t.Header.CompressionSpec.Algorithm = PgCompressionGzip
+		t.Header.CompressionSpec.Level = int32(level)
+	}
+	entries := make(map[int32]*Entry, len(t.Entries))
+	for _, e := range t.Entries {
+		e.FileName = nil
+		e.DataState = 0
+		e.DataOffset = 0
+		entries[e.DumpId] = e
+	}
+	return &CustomWriter{
+		w:       w,
+		toc:     t,
+		entries: entries,
+		level:   level,
+		tw:      newCustomDataWriter(w, t.Header),
+	}
+}
+
+func newCustomDataWriter(w io.Writer, h *Header) *Writer {
+	return &Writer{
+		w:       w,
+		intSize: h.IntSize,
+		version: h.Version,
+	}
+}
+
+// WriteToc - writes the header and the TOC with unset data offsets. It must be called before the data is written. The
+// output is considered seekable if it implements io.Seeker and the current position can be got
+func (cw *CustomWriter) WriteToc() error {
+	if ws, ok := cw.w.(io.WriteSeeker); ok {
+		if pos, err := ws.Seek(0, io.SeekCurrent); err == nil {
+			cw.ws = ws
+			cw.startPos = pos
+		}
+	}
+	if err := NewWriter(cw.w).Write(cw.toc); err != nil {
+		return fmt.Errorf("cannot write toc: %w", err)
+	}
+	cw.started = true
+	return nil
+}
+
+// StartData - starts the data block of the entry. The returned writer must be closed before the next block is
+// started
+func (cw *CustomWriter) StartData(dumpId int32) (io.WriteCloser, error) {
+	if err := cw.startBlock(BlockData, dumpId); err != nil {
+		return nil, err
+	}
+	return cw.newDataWriter(cw.tw), nil
+}
+
+// NewDataBlock - returns the writer that encodes the data block content into w in the same way as the writer returned
+// by StartData does. It is safe for concurrent use, so the data blocks can be prepared in parallel and then written by
+// WriteDataBlock
+func (cw *CustomWriter) NewDataBlock(w io.Writer) io.WriteCloser {
+	return cw.newDataWriter(newCustomDataWriter(w, cw.toc.Header))
+}
+
+// WriteDataBlock - writes the data block of the entry with the content prepared by NewDataBlock
+func (cw *CustomWriter) WriteDataBlock(dumpId int32, r io.Reader) error {
+	if err := cw.startBlock(BlockData, dumpId); err != nil {
+		return err
+	}
+	if _, err := io.Copy(cw.w, r); err != nil {
+		return fmt.Errorf("cannot write data block: %w", err)
+	}
+	return nil
+}
+
+// StartBlobs - starts the large objects block of the entry. Each large object is written using StartBlob and the
+// block is completed by EndBlobs
+func (cw *CustomWriter) StartBlobs(dumpId int32) error {
+	return cw.startBlock(BlockBlobs, dumpId)
+}
+
+// StartBlob - starts the large object data in the large objects block
+func (cw *CustomWriter) StartBlob(oid Oid) (io.WriteCloser, error) {
+	// The oid is written as signed int the same way as pg_dump does
+	if err := cw.tw.writeInt(int32(oid)); err != nil {
+		return nil, fmt.Errorf("cannot write large object oid: %w", err)
+	}
+	return cw.newDataWriter(cw.tw), nil
+}
+
+// EndBlobs - completes the large objects block
+func (cw *CustomWriter) EndBlobs() error {
+	if err := cw.tw.writeInt(0); err != nil {
+		return fmt.Errorf("cannot write large objects block terminator: %w", err)
+	}
+	return nil
+}
+
+// Close - rewrites the TOC with the data offsets and moves the position to the end of the archive. The TOC is not
+// rewritten if the output is not seekable
+func (cw *CustomWriter) Close() error {
+	if !cw.started {
+		return ErrCustomWriterIsNotStarted
+	}
+	if cw.ws == nil {
+		return nil
+	}
+	if _, err := cw.ws.Seek(cw.startPos, io.SeekStart); err != nil {
+		return fmt.Errorf("cannot seek to toc: %w", err)
+	}
+	if err := NewWriter(cw.ws).Write(cw.toc); err != nil {
+		return fmt.Errorf("cannot rewrite toc: %w", err)
+	}
+	if _, err := cw.ws.Seek(0, io.SeekEnd); err != nil {
+		return fmt.Errorf("cannot seek to the archive end: %w", err)
+	}
+	return nil
+}
+
+func (cw *CustomWriter) startBlock(blockType byte, dumpId int32) error {
+	if !cw.started {
+		return ErrCustomWriterIsNotStarted
+	}
+	entry, ok := cw.entries[dumpId]
+	if !ok {
+		return fmt.Errorf("entry with dumpId %d is not found", dumpId)
+	}
+	if cw.ws != nil {
+		pos, err := cw.ws.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return fmt.Errorf("cannot get archive position: %w", err)
+		}
+		entry.DataState = OffsetPosSet
+		entry.DataOffset = pos
+	}
+	if err := cw.tw.writeByte(blockType); err != nil {
+		return fmt.Errorf("cannot write block type: %w", err)
+	}
+	if err := cw.tw.writeInt(dumpId); err != nil {
+		return fmt.Errorf("cannot write block dumpId: %w", err)
+	}
+	return nil
+}
+
+func (cw *CustomWriter) newDataWriter(tw *Writer) *customDataWriter {
+	dw := &customDataWriter{
+		tw: tw,
+	}
+	dw.buf = bufio.NewWriterSize(&customChunkWriter{tw: tw}, customDataChunkSize)
+	if cw.level != 0 {
+		// The level is validated by the caller, so the error is not expected here
+		dw.zw, _ = zlib.NewWriterLevel(dw.buf, cw.level)
+	}
+	return dw
+}
+
+// customDataWriter - writes the data as the sequence of the length-prefixed chunks terminated by the zero length
+type customDataWriter struct {
+	tw  *Writer
+	buf *bufio.Writer
+	zw  *zlib.Writer
+}
+
+func (dw *customDataWriter) Write(p []byte) (int, error) {
+	if dw.zw != nil {
+		return dw.zw.Write(p)
+	}
+	return dw.buf.Write(p)
+}
+
+func (dw *customDataWriter) Close() error {
+	if dw.zw != nil {
+		if err := dw.zw.Close(); err != nil {
+			return fmt.Errorf("cannot close compressor: %w", err)
+		}
+	}
+	if err := dw.buf.Flush(); err != nil {
+		return fmt.Errorf("cannot flush data: %w", err)
+	}
+	if err := dw.tw.writeInt(0); err != nil {
+		return fmt.Errorf("cannot write data terminator: %w", err)
+	}
+	return nil
+}
+
+// customChunkWriter - writes each non-empty buffer as the chunk prefixed with its length
+type customChunkWriter struct {
+	tw *Writer
+}
+
+func (cw *customChunkWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := cw.tw.writeInt(int32(len(p))); err != nil {
+		return 0, fmt.Errorf("cannot write chunk length: %w", err)
+	}
+	if err := cw.tw.writeBuf(p); err != nil {
+		return 0, fmt.Errorf("cannot write chunk: %w", err)
+	}
+	return len(p), nil
+}
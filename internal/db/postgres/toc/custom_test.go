@@ -0,0 +1,237 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package toc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCustomTestToc() *Toc {
+	return &Toc{
+		Header: &Header{
+			VersionMajor: 1,
+			VersionMinor: 16,
+			Version:      BackupVersions["1.16"],
+			IntSize:      4,
+			OffSize:      8,
+			Format:       ArchTar,
+			ArchDbName:   NewObj("test"),
+		},
+		Entries: []*Entry{
+			{
+				DumpId:   1,
+				Section:  SectionPreData,
+				Tag:      NewObj("test"),
+				Desc:     NewObj("TABLE"),
+				Defn:     NewObj("CREATE TABLE public.test (id integer);"),
+				FileName: NewObj(""),
+			},
+			{
+				DumpId:    2,
+				Section:   SectionData,
+				HadDumper: 1,
+				Tag:       NewObj("test"),
+				Desc:      NewObj("TABLE DATA"),
+				CopyStmt:  NewObj("COPY public.test (id) FROM stdin;\n"),
+				FileName:  NewObj("2.dat.gz"),
+			},
+			{
+				DumpId:    3,
+				Section:   SectionData,
+				HadDumper: 1,
+				Tag:       NewObj("BLOBS"),
+				Desc:      NewObj("BLOBS"),
+				FileName:  NewObj("blobs.toc"),
+			},
+		},
+	}
+}
+
+// readCustomTestInt - reads the int written by pg_dump archiver with 4 bytes int size
+func readCustomTestInt(t *testing.T, r io.Reader) int32 {
+	buf := make([]byte, 5)
+	_, err := io.ReadFull(r, buf)
+	require.NoError(t, err)
+	res := int32(binary.LittleEndian.Uint32(buf[1:]))
+	if buf[0] != 0 {
+		return -res
+	}
+	return res
+}
+
+// readCustomTestData - reads the length-prefixed chunks until the zero length
+func readCustomTestData(t *testing.T, r io.Reader) []byte {
+	var res []byte
+	for {
+		l := readCustomTestInt(t, r)
+		if l == 0 {
+			return res
+		}
+		buf := make([]byte, l)
+		_, err := io.ReadFull(r, buf)
+		require.NoError(t, err)
+		res = append(res, buf...)
+	}
+}
+
+func TestCustomWriter(t *testing.T) {
+	tests := []struct {
+		name  string
+		level int
+	}{
+		{name: "uncompressed", level: 0},
+		{name: "gzip", level: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := os.Create(filepath.Join(t.TempDir(), "archive.dump"))
+			require.NoError(t, err)
+			defer f.Close()
+
+			tableData := []byte("1\n2\n\\.\n\n")
+			blobData := []byte("large object data")
+
+			cw := NewCustomWriter(f, newCustomTestToc(), tt.level)
+			require.NoError(t, cw.WriteToc())
+			w, err := cw.StartData(2)
+			require.NoError(t, err)
+			_, err = w.Write(tableData)
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+			require.NoError(t, cw.StartBlobs(3))
+			w, err = cw.StartBlob(16384)
+			require.NoError(t, err)
+			_, err = w.Write(blobData)
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+			require.NoError(t, cw.EndBlobs())
+			require.NoError(t, cw.Close())
+
+			_, err = f.Seek(0, io.SeekStart)
+			require.NoError(t, err)
+			res, err := NewReader(f).Read()
+			require.NoError(t, err)
+			assert.Equal(t, ArchCustom, res.Header.Format)
+			require.Len(t, res.Entries, 3)
+			assert.Equal(t, OffsetNoData, res.Entries[0].DataState)
+			assert.Nil(t, res.Entries[0].FileName)
+			assert.Equal(t, OffsetPosSet, res.Entries[1].DataState)
+			assert.Equal(t, OffsetPosSet, res.Entries[2].DataState)
+			if tt.level == 0 {
+				assert.Equal(t, PgCompressionNone, res.Header.CompressionSpec.Algorithm)
+			} else {
+				assert.Equal(t, PgCompressionGzip, res.Header.CompressionSpec.Algorithm)
+			}
+
+			decode := func(data []byte) []byte {
+				if tt.level == 0 {
+					return data
+				}
+				zr, err := zlib.NewReader(bytes.NewReader(data))
+				require.NoError(t, err)
+				res, err := io.ReadAll(zr)
+				require.NoError(t, err)
+				return res
+			}
+
+			_, err = f.Seek(res.Entries[1].DataOffset, io.SeekStart)
+			require.NoError(t, err)
+			blockType := make([]byte, 1)
+			_, err = io.ReadFull(f, blockType)
+			require.NoError(t, err)
+			assert.Equal(t, BlockData, blockType[0])
+			assert.Equal(t, int32(2), readCustomTestInt(t, f))
+			assert.Equal(t, tableData, decode(readCustomTestData(t, f)))
+
+			_, err = f.Seek(res.Entries[2].DataOffset, io.SeekStart)
+			require.NoError(t, err)
+			_, err = io.ReadFull(f, blockType)
+			require.NoError(t, err)
+			assert.Equal(t, BlockBlobs, blockType[0])
+			assert.Equal(t, int32(3), readCustomTestInt(t, f))
+			assert.Equal(t, int32(16384), readCustomTestInt(t, f))
+			assert.Equal(t, blobData, decode(readCustomTestData(t, f)))
+			assert.Equal(t, int32(0), readCustomTestInt(t, f))
+
+			rest, err := io.ReadAll(f)
+			require.NoError(t, err)
+			assert.Empty(t, rest)
+		})
+	}
+}
+
+func TestCustomWriter_NotStarted(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "archive.dump"))
+	require.NoError(t, err)
+	defer f.Close()
+	cw := NewCustomWriter(f, newCustomTestToc(), 0)
+	_, err = cw.StartData(2)
+	require.ErrorIs(t, err, ErrCustomWriterIsNotStarted)
+}
+
+func TestCustomWriter_NotSeekable(t *testing.T) {
+	buf := new(bytes.Buffer)
+	tableData := []byte("1\n2\n\\.\n\n")
+
+	cw := NewCustomWriter(buf, newCustomTestToc(), -1)
+	require.NoError(t, cw.WriteToc())
+	// The data block is prepared separately and then written into the archive
+	block := new(bytes.Buffer)
+	w := cw.NewDataBlock(block)
+	_, err := w.Write(tableData)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, cw.WriteDataBlock(2, block))
+	require.NoError(t, cw.StartBlobs(3))
+	require.NoError(t, cw.EndBlobs())
+	require.NoError(t, cw.Close())
+
+	// The offsets are not set, so the data blocks follow the TOC in the written order
+	r := bytes.NewReader(buf.Bytes())
+	res, err := NewReader(r).Read()
+	require.NoError(t, err)
+	require.Len(t, res.Entries, 3)
+	assert.Equal(t, OffsetNoData, res.Entries[0].DataState)
+	assert.Equal(t, OffsetPosNotSet, res.Entries[1].DataState)
+	assert.Equal(t, int64(0), res.Entries[1].DataOffset)
+	assert.Equal(t, OffsetPosNotSet, res.Entries[2].DataState)
+
+	blockType := make([]byte, 1)
+	_, err = io.ReadFull(r, blockType)
+	require.NoError(t, err)
+	assert.Equal(t, BlockData, blockType[0])
+	assert.Equal(t, int32(2), readCustomTestInt(t, r))
+	zr, err := zlib.NewReader(bytes.NewReader(readCustomTestData(t, r)))
+	require.NoError(t, err)
+	data, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, tableData, data)
+
+	_, err = io.ReadFull(r, blockType)
+	require.NoError(t, err)
+	assert.Equal(t, BlockBlobs, blockType[0])
+	assert.Equal(t, int32(3), readCustomTestInt(t, r))
+	assert.Equal(t, int32(0), readCustomTestInt(t, r))
+	assert.Zero(t, r.Len())
+}
@@ -58,6 +58,8 @@ type Entry struct {
 
 	/* working state while dumping/restoring */
 	DataLength uint32 /* item's data size; 0 if none or unknown */
+	DataState  byte   /* data offset state; custom format only */
+	DataOffset int64  /* data block position in the archive; custom format only */
 
 	//OriginalSize   int64
 	//CompressedSize int64
@@ -30,6 +30,8 @@ type Reader struct {
 	version   int
 	position  int
 	maxDumpId int32
+	format    byte
+	offSize   uint32
 }
 
 func NewReader(r io.Reader) *Reader {
@@ -43,6 +45,8 @@ func (r *Reader) prune() {
 	r.version = 0
 	r.position = 0
 	r.maxDumpId = 0
+	r.format = 0
+	r.offSize = 0
 }
 
 func (r *Reader) Read() (*Toc, error) {
@@ -220,9 +224,13 @@ func (r *Reader) readHeader() (*Header, error) {
 	 * is compatible with 'tar', so there's no point having a different
 	 * format code for it.
 	 */
-	if ArchTar != header.Format {
-		return nil, fmt.Errorf("unsupported format \"%s\": suports only directory", BackupFormats[header.Format])
+	if ArchTar != header.Format && ArchCustom != header.Format {
+		return nil, fmt.Errorf(
+			"unsupported format \"%s\": suports only directory and custom", BackupFormats[header.Format],
+		)
 	}
+	r.format = header.Format
+	r.offSize = header.OffSize
 
 	if header.Version >= BackupVersions["1.15"] {
 		algorithm, err := r.readByte()
@@ -485,14 +493,40 @@ func (r *Reader) readEntries() ([]*Entry, error) {
 		}
 		entry.DataLength = 0
 
-		fileName, err := r.readStr()
-		if err != nil {
-			return nil, fmt.Errorf("cannot read an additional FileName data: %w", err)
+		if r.format == ArchCustom {
+			if err = r.readOffset(&entry); err != nil {
+				return nil, fmt.Errorf("cannot read data offset: %w", err)
+			}
+		} else {
+			fileName, err := r.readStr()
+			if err != nil {
+				return nil, fmt.Errorf("cannot read an additional FileName data: %w", err)
+			}
+			entry.FileName = fileName
 		}
-		entry.FileName = fileName
 		entries = append(entries, &entry)
 
 	}
 
 	return entries, nil
 }
+
+// readOffset - reads the data offset of the custom format archive entry. The offset is stored as the state flag
+// followed by offSize bytes with the least significant byte first
+func (r *Reader) readOffset(entry *Entry) error {
+	state, err := r.readByte()
+	if err != nil {
+		return fmt.Errorf("cannot read offset state: %w", err)
+	}
+	offsetBytes, err := r.readBytes(int(r.offSize))
+	if err != nil {
+		return fmt.Errorf("cannot read offset value: %w", err)
+	}
+	var offset int64
+	for idx := len(offsetBytes) - 1; idx >= 0; idx-- {
+		offset = offset<<8 | int64(offsetBytes[idx])
+	}
+	entry.DataState = state
+	entry.DataOffset = offset
+	return nil
+}
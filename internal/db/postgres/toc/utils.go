@@ -24,6 +24,19 @@ const (
 
 const InvalidOid = 0
 
+// The data offset states of the custom format archive entry
+const (
+	OffsetPosNotSet byte = 1
+	OffsetPosSet    byte = 2
+	OffsetNoData    byte = 3
+)
+
+// The data block types of the custom format archive
+const (
+	BlockData  byte = 1
+	BlockBlobs byte = 3
+)
+
 const MaxVersion = "1.16"
 
 const (
@@ -26,6 +26,8 @@ type Writer struct {
 	intSize  uint32
 	version  int
 	position int
+	format   byte
+	offSize  uint32
 }
 
 func NewWriter(w io.Writer) *Writer {
@@ -38,6 +40,8 @@ func (w *Writer) prune() {
 	w.intSize = 0
 	w.version = 0
 	w.position = 0
+	w.format = 0
+	w.offSize = 0
 }
 
 func (w *Writer) Write(toc *Toc) error {
@@ -50,6 +54,8 @@ func (w *Writer) Write(toc *Toc) error {
 	defer w.prune()
 	w.intSize = toc.Header.IntSize
 	w.version = toc.Header.Version
+	w.format = toc.Header.Format
+	w.offSize = toc.Header.OffSize
 	if err := w.writeHeader(toc.Header); err != nil {
 		return fmt.Errorf("error writing header: %w", err)
 	}
@@ -259,10 +265,16 @@ func (w *Writer) writeEntries(entries []*Entry) error {
 			}
 		}
 
-		// TODO: Ensure entry.FileName is required for all versions
-		// WriteExtraTocPtr - write filename here
-		if err := w.writeStr(entry.FileName); err != nil {
-			return fmt.Errorf("unable to write FileName: %w", err)
+		// WriteExtraTocPtr - the custom format stores the data offset and the directory format stores the filename
+		if w.format == ArchCustom {
+			if err := w.writeOffset(entry); err != nil {
+				return fmt.Errorf("unable to write data offset: %w", err)
+			}
+		} else {
+			// TODO: Ensure entry.FileName is required for all versions
+			if err := w.writeStr(entry.FileName); err != nil {
+				return fmt.Errorf("unable to write FileName: %w", err)
+			}
 		}
 
 	}
@@ -270,6 +282,29 @@ func (w *Writer) writeEntries(entries []*Entry) error {
 	return nil
 }
 
+// writeOffset - writes the data offset of the custom format archive entry. If the state is not set it is
+// derived from HadDumper, so the entries with data get OffsetPosNotSet and the rest get OffsetNoData
+func (w *Writer) writeOffset(entry *Entry) error {
+	state := entry.DataState
+	if state == 0 {
+		state = OffsetNoData
+		if entry.HadDumper != 0 {
+			state = OffsetPosNotSet
+		}
+	}
+	if err := w.writeByte(state); err != nil {
+		return fmt.Errorf("unable to write offset state: %w", err)
+	}
+	offset := entry.DataOffset
+	for b := uint32(0); b < w.offSize; b++ {
+		if err := w.writeByte(byte(offset & 0xFF)); err != nil {
+			return fmt.Errorf("unable to write offset byte: %w", err)
+		}
+		offset >>= 8
+	}
+	return nil
+}
+
 func (w *Writer) writeBuf(buf []byte) error {
 	n, err := w.w.Write(buf)
 	if err != nil {
@@ -0,0 +1,162 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transformers
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+// ColumnMatcher - matches the column by the schema, table, column name and comment regular expressions and the list
+// of the type names. Empty conditions are not checked
+type ColumnMatcher struct {
+	schema  *regexp.Regexp
+	table   *regexp.Regexp
+	column  *regexp.Regexp
+	comment *regexp.Regexp
+	types   []string
+}
+
+// AutoAnonymizeRule - compiled domains.AutoAnonymizeRule
+type AutoAnonymizeRule struct {
+	*ColumnMatcher
+	transformer *domains.TransformerConfig
+}
+
+// AutoAnonymizeRules - ordered list of the compiled rules. The first matched rule is applied
+type AutoAnonymizeRules []*AutoAnonymizeRule
+
+// CompileAutoAnonymizeRules - validates and compiles the rules from config. The rule transformer must be registered
+// and must have the column parameter, because the matched column is passed to it
+func CompileAutoAnonymizeRules(
+	rules []*domains.AutoAnonymizeRule, registry *utils.TransformerRegistry,
+) (AutoAnonymizeRules, error) {
+	res := make(AutoAnonymizeRules, 0, len(rules))
+	for idx, r := range rules {
+		if r.Transformer == nil || r.Transformer.Name == "" {
+			return nil, fmt.Errorf("auto anonymize rule %d: transformer name is required", idx)
+		}
+		def, ok := registry.Get(r.Transformer.Name)
+		if !ok {
+			return nil, fmt.Errorf("auto anonymize rule %d: unknown transformer \"%s\"", idx, r.Transformer.Name)
+		}
+		if !slices.ContainsFunc(def.Parameters, func(p *toolkit.ParameterDefinition) bool {
+			return p.Name == "column" && p.IsColumn
+		}) {
+			return nil, fmt.Errorf(
+				"auto anonymize rule %d: transformer \"%s\" does not have the \"column\" parameter: "+
+					"the transformers of several columns cannot be used in the rules",
+				idx, r.Transformer.Name,
+			)
+		}
+		m, err := NewColumnMatcher(r.Schema, r.Table, r.Column, r.Comment, r.Types)
+		if err != nil {
+			return nil, fmt.Errorf("auto anonymize rule %d: %w", idx, err)
+		}
+		res = append(res, &AutoAnonymizeRule{
+			ColumnMatcher: m,
+			transformer:   r.Transformer,
+		})
+	}
+	return res, nil
+}
+
+// NewColumnMatcher - compiles the column matcher. At least one condition is required
+func NewColumnMatcher(schema, table, column, comment string, types []string) (*ColumnMatcher, error) {
+	if schema == "" && table == "" && column == "" && comment == "" && len(types) == 0 {
+		return nil, fmt.Errorf("at least one condition is required")
+	}
+	m := &ColumnMatcher{}
+	for _, t := range types {
+		m.types = append(m.types, strings.ToLower(t))
+	}
+	var err error
+	if m.schema, err = compileRulePattern(schema); err != nil {
+		return nil, fmt.Errorf("cannot compile schema pattern: %w", err)
+	}
+	if m.table, err = compileRulePattern(table); err != nil {
+		return nil, fmt.Errorf("cannot compile table pattern: %w", err)
+	}
+	if m.column, err = compileRulePattern(column); err != nil {
+		return nil, fmt.Errorf("cannot compile column pattern: %w", err)
+	}
+	if m.comment, err = compileRulePattern(comment); err != nil {
+		return nil, fmt.Errorf("cannot compile comment pattern: %w", err)
+	}
+	return m, nil
+}
+
+// GetTransformerForColumn - returns the transformer config of the first matched rule. If none of the rules is matched
+// the default transformer for the column type is returned
+func (rules AutoAnonymizeRules) GetTransformerForColumn(
+	table *toolkit.Table, column *toolkit.Column,
+) (*domains.TransformerConfig, error) {
+	for _, r := range rules {
+		if r.Match(table, column) {
+			return r.getTransformerConfig(column), nil
+		}
+	}
+	return GetDefaultTransformerForColumn(column)
+}
+
+// Match - returns true if the column satisfies all the conditions
+func (m *ColumnMatcher) Match(table *toolkit.Table, column *toolkit.Column) bool {
+	if m.schema != nil && !m.schema.MatchString(table.Schema) {
+		return false
+	}
+	if m.table != nil && !m.table.MatchString(table.Name) {
+		return false
+	}
+	if m.column != nil && !m.column.MatchString(column.Name) {
+		return false
+	}
+	if m.comment != nil && !m.comment.MatchString(column.Comment) {
+		return false
+	}
+	if len(m.types) > 0 {
+		typeName, _ := column.GetType()
+		if !slices.Contains(m.types, strings.ToLower(typeName)) &&
+			!slices.Contains(m.types, strings.ToLower(column.CanonicalTypeName)) {
+			return false
+		}
+	}
+	return true
+}
+
+// getTransformerConfig - returns the copy of the rule transformer config for the column. The column parameter is set
+// if it was not provided in the rule. The rule transformers are checked to have the column parameter on compilation
+func (r *AutoAnonymizeRule) getTransformerConfig(column *toolkit.Column) *domains.TransformerConfig {
+	tc := r.transformer.Clone()
+	if tc.Params == nil {
+		tc.Params = make(toolkit.StaticParameters)
+	}
+	if _, ok := tc.Params["column"]; !ok {
+		tc.Params["column"] = toolkit.ParamsValue(column.Name)
+	}
+	return tc
+}
+
+func compileRulePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
@@ -0,0 +1,158 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transformers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/domains"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+func TestAutoAnonymizeRules_GetTransformerForColumn(t *testing.T) {
+	rules, err := CompileAutoAnonymizeRules([]*domains.AutoAnonymizeRule{
+		{
+			Column:      "(?i)email",
+			Transformer: &domains.TransformerConfig{Name: RandomEmailTransformerName},
+		},
+		{
+			Schema: "^billing$",
+			Column: "^phone$",
+			Transformer: &domains.TransformerConfig{
+				Name:   RandomE164PhoneNumberTransformerName,
+				Params: toolkit.StaticParameters{"engine": toolkit.ParamsValue("hash")},
+			},
+		},
+		{
+			Comment:     "@pii:login",
+			Types:       []string{"text"},
+			Transformer: &domains.TransformerConfig{Name: RandomUsernameTransformerName},
+		},
+	}, utils.DefaultTransformerRegistry)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		table        *toolkit.Table
+		column       *toolkit.Column
+		expectedName string
+		expectedCol  string
+	}{
+		{
+			name:         "column name regexp",
+			table:        &toolkit.Table{Schema: "public", Name: "users"},
+			column:       &toolkit.Column{Name: "work_Email", TypeName: "text"},
+			expectedName: RandomEmailTransformerName,
+			expectedCol:  "work_Email",
+		},
+		{
+			name:         "schema and column regexp",
+			table:        &toolkit.Table{Schema: "billing", Name: "accounts"},
+			column:       &toolkit.Column{Name: "phone", TypeName: "text"},
+			expectedName: RandomE164PhoneNumberTransformerName,
+			expectedCol:  "phone",
+		},
+		{
+			name:         "schema is not matched fallback to type",
+			table:        &toolkit.Table{Schema: "public", Name: "accounts"},
+			column:       &toolkit.Column{Name: "phone", TypeName: "text"},
+			expectedName: "RandomString",
+			expectedCol:  "phone",
+		},
+		{
+			name:         "comment tag and type",
+			table:        &toolkit.Table{Schema: "public", Name: "users"},
+			column:       &toolkit.Column{Name: "login", TypeName: "text", Comment: "owner @pii:login"},
+			expectedName: RandomUsernameTransformerName,
+			expectedCol:  "login",
+		},
+		{
+			name:         "comment tag with another type",
+			table:        &toolkit.Table{Schema: "public", Name: "users"},
+			column:       &toolkit.Column{Name: "login_id", TypeName: "int4", Comment: "@pii:login"},
+			expectedName: "RandomInt",
+			expectedCol:  "login_id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := rules.GetTransformerForColumn(tt.table, tt.column)
+			require.NoError(t, err)
+			require.NotNil(t, res)
+			assert.Equal(t, tt.expectedName, res.Name)
+			assert.Equal(t, tt.expectedCol, string(res.Params["column"]))
+		})
+	}
+
+	t.Run("rule config is not modified", func(t *testing.T) {
+		_, err := rules.GetTransformerForColumn(
+			&toolkit.Table{Schema: "billing"}, &toolkit.Column{Name: "phone", TypeName: "text"},
+		)
+		require.NoError(t, err)
+		_, ok := rules[1].transformer.Params["column"]
+		assert.False(t, ok)
+	})
+}
+
+func TestCompileAutoAnonymizeRules_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule *domains.AutoAnonymizeRule
+		err  string
+	}{
+		{
+			name: "no transformer",
+			rule: &domains.AutoAnonymizeRule{Column: "email"},
+			err:  "transformer name is required",
+		},
+		{
+			name: "no conditions",
+			rule: &domains.AutoAnonymizeRule{Transformer: &domains.TransformerConfig{Name: "RandomEmail"}},
+			err:  "at least one condition is required",
+		},
+		{
+			name: "invalid regexp",
+			rule: &domains.AutoAnonymizeRule{
+				Column: "(email", Transformer: &domains.TransformerConfig{Name: "RandomEmail"},
+			},
+			err: "cannot compile column pattern",
+		},
+		{
+			name: "unknown transformer",
+			rule: &domains.AutoAnonymizeRule{
+				Column: "email", Transformer: &domains.TransformerConfig{Name: "Unknown"},
+			},
+			err: `unknown transformer "Unknown"`,
+		},
+		{
+			name: "transformer of several columns",
+			rule: &domains.AutoAnonymizeRule{
+				Column: "name", Transformer: &domains.TransformerConfig{Name: RandomPersonTransformerName},
+			},
+			err: `transformer "RandomPerson" does not have the "column" parameter`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CompileAutoAnonymizeRules([]*domains.AutoAnonymizeRule{tt.rule}, utils.DefaultTransformerRegistry)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
@@ -2,8 +2,10 @@ package transformers
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
+	"github.com/greenmaskio/greenmask/internal/generators/transformers"
 	"github.com/greenmaskio/greenmask/pkg/toolkit"
 )
 
@@ -34,6 +36,19 @@ var (
 		"max random percentage for noise",
 	).SetDefaultValue(toolkit.ParamsValue("0.2"))
 
+	localeParameterDefinition = toolkit.MustNewParameterDefinition(
+		"locale",
+		fmt.Sprintf(
+			"locale of the generated values (%s). %s is used by default",
+			strings.Join(transformers.GetLocales(), ", "), transformers.DefaultLocale,
+		),
+	).SetRawValueValidator(localeValidator)
+
+	dictionaryFileParameterDefinition = toolkit.MustNewParameterDefinition(
+		"dictionary_file",
+		"path to the JSON file with the user dataset that overrides the locale dataset sections",
+	)
+
 	truncateDateParameterDefinition = toolkit.MustNewParameterDefinition(
 		"truncate",
 		fmt.Sprintf("truncate date till the part (%s)", strings.Join(truncateParts, ", ")),
@@ -52,3 +67,17 @@ func engineValidator(p *toolkit.ParameterDefinition, v toolkit.ParamsValue) (too
 	}
 	return nil, nil
 }
+
+func localeValidator(p *toolkit.ParameterDefinition, v toolkit.ParamsValue) (toolkit.ValidationWarnings, error) {
+	value := string(v)
+	if value != "" && !slices.Contains(transformers.GetLocales(), value) {
+		return toolkit.ValidationWarnings{
+			toolkit.NewValidationWarning().
+				SetMsg("unknown locale").
+				AddMeta("ParameterValue", value).
+				AddMeta("AllowedValues", transformers.GetLocales()).
+				SetSeverity(toolkit.ErrorValidationSeverity),
+		}, nil
+	}
+	return nil, nil
+}
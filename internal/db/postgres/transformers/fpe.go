@@ -0,0 +1,301 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transformers
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/utils/fpe"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+const (
+	FpeTransformerName = "Fpe"
+	// FpeKeyEnvVariable - environment variable that contains the hex encoded key if it is not set in the config
+	FpeKeyEnvVariable = "GREENMASK_FPE_KEY"
+)
+
+const (
+	fpeModeEncrypt = "encrypt"
+	fpeModeDecrypt = "decrypt"
+)
+
+const (
+	fpeInvalidLengthError = "error"
+	fpeInvalidLengthKeep  = "keep"
+)
+
+const (
+	fpeAlphabetDigits            = "digits"
+	fpeAlphabetAlphanumeric      = "alphanumeric"
+	fpeAlphabetUpperAlphanumeric = "upper_alphanumeric"
+	fpeAlphabetLowerAlphanumeric = "lower_alphanumeric"
+)
+
+var ErrFpeKeyIsNotSet = errors.New("fpe key is not set")
+
+var FpeTransformerDefinition = utils.NewTransformerDefinition(
+	utils.NewTransformerProperties(
+		FpeTransformerName,
+		"Encrypt the value using format-preserving encryption FF3-1. The length and the charset of the value are kept",
+	).AddMeta(AllowApplyForReferenced, true).
+		AddMeta(RequireHashEngineParameter, false),
+
+	NewFpeTransformer,
+
+	toolkit.MustNewParameterDefinition(
+		"column",
+		"column name",
+	).SetIsColumn(toolkit.NewColumnProperties().
+		SetAffected(true).
+		SetAllowedColumnTypes("text", "varchar", "char", "bpchar", "citext"),
+	).SetRequired(true),
+
+	toolkit.MustNewParameterDefinition(
+		"key",
+		"hex encoded AES key of 16, 24 or 32 bytes. This value may be provided via environment variable "+
+			FpeKeyEnvVariable,
+	).SetGetFromGlobalEnvVariable(FpeKeyEnvVariable),
+
+	toolkit.MustNewParameterDefinition(
+		"tweak",
+		"hex encoded tweak of 7 bytes",
+	).SetDefaultValue([]byte("00000000000000")),
+
+	toolkit.MustNewParameterDefinition(
+		"alphabet",
+		"symbols that are encrypted. The other symbols are kept as is. Possible values: digits, alphanumeric, "+
+			"upper_alphanumeric, lower_alphanumeric",
+	).SetAllowedValues(
+		toolkit.ParamsValue(fpeAlphabetDigits),
+		toolkit.ParamsValue(fpeAlphabetAlphanumeric),
+		toolkit.ParamsValue(fpeAlphabetUpperAlphanumeric),
+		toolkit.ParamsValue(fpeAlphabetLowerAlphanumeric),
+	).SetDefaultValue(toolkit.ParamsValue(fpeAlphabetDigits)),
+
+	toolkit.MustNewParameterDefinition(
+		"mode",
+		"encrypt or decrypt the value. The decrypt mode may be used in the restore transformation",
+	).SetAllowedValues(
+		toolkit.ParamsValue(fpeModeEncrypt),
+		toolkit.ParamsValue(fpeModeDecrypt),
+	).SetDefaultValue(toolkit.ParamsValue(fpeModeEncrypt)),
+
+	toolkit.MustNewParameterDefinition(
+		"invalid_length",
+		"behaviour for the values that contain too few or too many alphabet symbols to be encrypted, for instance "+
+			"empty strings or short codes. Possible values: error - fail the transformation, keep - keep the value "+
+			"as is",
+	).SetAllowedValues(
+		toolkit.ParamsValue(fpeInvalidLengthError),
+		toolkit.ParamsValue(fpeInvalidLengthKeep),
+	).SetDefaultValue(toolkit.ParamsValue(fpeInvalidLengthError)),
+)
+
+type FpeTransformer struct {
+	columnName      string
+	affectedColumns map[int]string
+	columnIdx       int
+	cipher          *FpeCipher
+	decrypt         bool
+}
+
+func NewFpeTransformer(
+	ctx context.Context, driver *toolkit.Driver, parameters map[string]toolkit.Parameterizer,
+) (utils.Transformer, toolkit.ValidationWarnings, error) {
+	p := parameters["column"]
+	var columnName string
+	if err := p.Scan(&columnName); err != nil {
+		return nil, nil, fmt.Errorf("unable to parse column param: %w", err)
+	}
+
+	idx, column, ok := driver.GetColumnByName(columnName)
+	if !ok {
+		return nil, nil, fmt.Errorf("column with name %s is not found", columnName)
+	}
+	affectedColumns := make(map[int]string)
+	affectedColumns[idx] = columnName
+
+	var key, tweak, alphabet, mode, invalidLength string
+	if err := parameters["key"].Scan(&key); err != nil {
+		return nil, nil, fmt.Errorf("unable to scan \"key\" parameter: %w", err)
+	}
+	if err := parameters["tweak"].Scan(&tweak); err != nil {
+		return nil, nil, fmt.Errorf("unable to scan \"tweak\" parameter: %w", err)
+	}
+	if err := parameters["alphabet"].Scan(&alphabet); err != nil {
+		return nil, nil, fmt.Errorf("unable to scan \"alphabet\" parameter: %w", err)
+	}
+	if err := parameters["mode"].Scan(&mode); err != nil {
+		return nil, nil, fmt.Errorf("unable to scan \"mode\" parameter: %w", err)
+	}
+	if err := parameters["invalid_length"].Scan(&invalidLength); err != nil {
+		return nil, nil, fmt.Errorf("unable to scan \"invalid_length\" parameter: %w", err)
+	}
+
+	c, err := NewFpeCipher(key, tweak, alphabet)
+	if err != nil {
+		return nil, toolkit.ValidationWarnings{
+			toolkit.NewValidationWarning().
+				SetSeverity(toolkit.ErrorValidationSeverity).
+				AddMeta("Error", err.Error()).
+				SetMsg("cannot initialize fpe cipher"),
+		}, nil
+	}
+	c.SetKeepInvalidLength(invalidLength == fpeInvalidLengthKeep)
+
+	var warnings toolkit.ValidationWarnings
+	if invalidLength == fpeInvalidLengthError && column.Length > 0 && column.Length < c.MinLen() {
+		warnings = append(warnings, toolkit.NewValidationWarning().
+			SetSeverity(toolkit.WarningValidationSeverity).
+			AddMeta("ColumnName", columnName).
+			AddMeta("ColumnMaxLength", column.Length).
+			AddMeta("MinLength", c.MinLen()).
+			AddMeta("Hint", "set invalid_length parameter to keep for keeping the short values").
+			SetMsg("column values are too short to be encrypted: the transformation fails on each non-empty value"))
+	}
+
+	return &FpeTransformer{
+		columnName:      columnName,
+		affectedColumns: affectedColumns,
+		columnIdx:       idx,
+		cipher:          c,
+		decrypt:         mode == fpeModeDecrypt,
+	}, warnings, nil
+}
+
+func (ft *FpeTransformer) GetAffectedColumns() map[int]string {
+	return ft.affectedColumns
+}
+
+func (ft *FpeTransformer) Init(ctx context.Context) error {
+	return nil
+}
+
+func (ft *FpeTransformer) Done(ctx context.Context) error {
+	return nil
+}
+
+func (ft *FpeTransformer) Transform(ctx context.Context, r *toolkit.Record) (*toolkit.Record, error) {
+	val, err := r.GetRawColumnValueByIdx(ft.columnIdx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to scan attribute value: %w", err)
+	}
+	if val.IsNull {
+		return r, nil
+	}
+
+	var res string
+	if ft.decrypt {
+		res, err = ft.cipher.Decrypt(string(val.Data))
+	} else {
+		res, err = ft.cipher.Encrypt(string(val.Data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot transform value of column %s: %w", ft.columnName, err)
+	}
+
+	if err = r.SetRawColumnValueByIdx(ft.columnIdx, toolkit.NewRawValue([]byte(res), false)); err != nil {
+		return nil, fmt.Errorf("unable to set new value: %w", err)
+	}
+	return r, nil
+}
+
+// FpeCipher - FF3-1 cipher with the fixed tweak that is used by the Fpe transformer
+type FpeCipher struct {
+	cipher *fpe.Cipher
+	tweak  []byte
+	// keepInvalidLength - the values that cannot be encrypted due to their length are returned as is
+	keepInvalidLength bool
+}
+
+// NewFpeCipher - creates the cipher using the Fpe transformer parameters values. The key and the tweak are hex
+// encoded
+func NewFpeCipher(key, tweak, alphabet string) (*FpeCipher, error) {
+	if key == "" {
+		return nil, ErrFpeKeyIsNotSet
+	}
+	rawKey, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode key: %w", err)
+	}
+	rawTweak, err := hex.DecodeString(tweak)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode tweak: %w", err)
+	}
+	if len(rawTweak) != fpe.TweakSize {
+		return nil, fmt.Errorf("tweak must be %d bytes long", fpe.TweakSize)
+	}
+
+	var symbols string
+	switch alphabet {
+	case fpeAlphabetDigits, "":
+		symbols = fpe.AlphabetDigits
+	case fpeAlphabetAlphanumeric:
+		symbols = fpe.AlphabetAlphanumeric
+	case fpeAlphabetUpperAlphanumeric:
+		symbols = fpe.AlphabetUpperAlphanumeric
+	case fpeAlphabetLowerAlphanumeric:
+		symbols = fpe.AlphabetLowerAlphanumeric
+	default:
+		return nil, fmt.Errorf("unknown alphabet \"%s\"", alphabet)
+	}
+
+	c, err := fpe.NewCipher(rawKey, symbols)
+	if err != nil {
+		return nil, err
+	}
+	return &FpeCipher{cipher: c, tweak: rawTweak}, nil
+}
+
+// SetKeepInvalidLength - sets the behaviour for the values that contain too few or too many alphabet symbols. If
+// keep is true such values are returned as is, otherwise the error is returned
+func (fc *FpeCipher) SetKeepInvalidLength(keep bool) *FpeCipher {
+	fc.keepInvalidLength = keep
+	return fc
+}
+
+// MinLen - returns the minimal number of the alphabet symbols in the value
+func (fc *FpeCipher) MinLen() int {
+	return fc.cipher.MinLen()
+}
+
+// Encrypt - encrypts the alphabet symbols of the value keeping the other symbols at their positions
+func (fc *FpeCipher) Encrypt(v string) (string, error) {
+	res, err := fc.cipher.EncryptText(v, fc.tweak)
+	return fc.result(v, res, err)
+}
+
+// Decrypt - decrypts the value encrypted by Encrypt
+func (fc *FpeCipher) Decrypt(v string) (string, error) {
+	res, err := fc.cipher.DecryptText(v, fc.tweak)
+	return fc.result(v, res, err)
+}
+
+// result - replaces the invalid length error with the original value if such values are kept
+func (fc *FpeCipher) result(v, res string, err error) (string, error) {
+	if err != nil && fc.keepInvalidLength && errors.Is(err, fpe.ErrInvalidLength) {
+		return v, nil
+	}
+	return res, err
+}
+
+func init() {
+	utils.DefaultTransformerRegistry.MustRegister(FpeTransformerDefinition)
+}
@@ -0,0 +1,220 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transformers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/utils/fpe"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+const testFpeKey = "ef4359d8d580aa4f7f036d6f04fc6a94"
+
+func TestFpeTransformer_Transform(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   map[string]toolkit.ParamsValue
+		original string
+		pattern  string
+	}{
+		{
+			name: "digits",
+			params: map[string]toolkit.ParamsValue{
+				"column": toolkit.ParamsValue("data"),
+				"key":    toolkit.ParamsValue(testFpeKey),
+			},
+			original: "4111-1111-1111-1111",
+			pattern:  `^\d{4}-\d{4}-\d{4}-\d{4}$`,
+		},
+		{
+			name: "upper alphanumeric",
+			params: map[string]toolkit.ParamsValue{
+				"column":   toolkit.ParamsValue("data"),
+				"key":      toolkit.ParamsValue(testFpeKey),
+				"alphabet": toolkit.ParamsValue("upper_alphanumeric"),
+				"tweak":    toolkit.ParamsValue("d8e7920afa330a"),
+			},
+			original: "ACC-00A7B19Z",
+			pattern:  `^[0-9A-Z]{3}-[0-9A-Z]{8}$`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driver, record := getDriverAndRecord(string(tt.params["column"]), tt.original)
+			transformer, warnings, err := FpeTransformerDefinition.Instance(
+				context.Background(), driver, tt.params, nil, "",
+			)
+			require.NoError(t, err)
+			require.Empty(t, warnings)
+
+			r, err := transformer.Transformer.Transform(context.Background(), record)
+			require.NoError(t, err)
+			res, err := r.GetRawColumnValueByName("data")
+			require.NoError(t, err)
+			require.False(t, res.IsNull)
+			encrypted := string(res.Data)
+			assert.Regexp(t, tt.pattern, encrypted)
+			assert.NotEqual(t, tt.original, encrypted)
+
+			// The decrypt mode restores the original value
+			tt.params["mode"] = toolkit.ParamsValue("decrypt")
+			driver, record = getDriverAndRecord("data", encrypted)
+			transformer, warnings, err = FpeTransformerDefinition.Instance(
+				context.Background(), driver, tt.params, nil, "",
+			)
+			require.NoError(t, err)
+			require.Empty(t, warnings)
+			r, err = transformer.Transformer.Transform(context.Background(), record)
+			require.NoError(t, err)
+			res, err = r.GetRawColumnValueByName("data")
+			require.NoError(t, err)
+			assert.Equal(t, tt.original, string(res.Data))
+		})
+	}
+}
+
+func TestFpeTransformer_Transform_null(t *testing.T) {
+	driver, record := getDriverAndRecord("data", "\\N")
+	transformer, warnings, err := FpeTransformerDefinition.Instance(
+		context.Background(), driver,
+		map[string]toolkit.ParamsValue{
+			"column": toolkit.ParamsValue("data"),
+			"key":    toolkit.ParamsValue(testFpeKey),
+		}, nil, "",
+	)
+	require.NoError(t, err)
+	require.Empty(t, warnings)
+	r, err := transformer.Transformer.Transform(context.Background(), record)
+	require.NoError(t, err)
+	res, err := r.GetRawColumnValueByName("data")
+	require.NoError(t, err)
+	assert.True(t, res.IsNull)
+}
+
+func TestFpeTransformer_Transform_invalid_length(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+	}{
+		{name: "empty", original: ""},
+		{name: "without digits", original: "N/A"},
+		{name: "pin", original: "1234"},
+		{name: "too long", original: strings.Repeat("1", 57)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, mode := range []string{fpeModeEncrypt, fpeModeDecrypt} {
+				params := map[string]toolkit.ParamsValue{
+					"column": toolkit.ParamsValue("data"),
+					"key":    toolkit.ParamsValue(testFpeKey),
+					"mode":   toolkit.ParamsValue(mode),
+				}
+				driver, record := getDriverAndRecord("data", tt.original)
+				transformer, warnings, err := FpeTransformerDefinition.Instance(
+					context.Background(), driver, params, nil, "",
+				)
+				require.NoError(t, err)
+				require.Empty(t, warnings)
+				_, err = transformer.Transformer.Transform(context.Background(), record)
+				require.ErrorIs(t, err, fpe.ErrInvalidLength)
+
+				params["invalid_length"] = toolkit.ParamsValue(fpeInvalidLengthKeep)
+				driver, record = getDriverAndRecord("data", tt.original)
+				transformer, warnings, err = FpeTransformerDefinition.Instance(
+					context.Background(), driver, params, nil, "",
+				)
+				require.NoError(t, err)
+				require.Empty(t, warnings)
+				r, err := transformer.Transformer.Transform(context.Background(), record)
+				require.NoError(t, err)
+				res, err := r.GetRawColumnValueByName("data")
+				require.NoError(t, err)
+				assert.False(t, res.IsNull)
+				assert.Equal(t, tt.original, string(res.Data))
+			}
+		})
+	}
+}
+
+func TestFpeTransformer_short_column_warning(t *testing.T) {
+	table := &toolkit.Table{
+		Schema: "public",
+		Name:   "test",
+		Oid:    1224,
+		Columns: []*toolkit.Column{
+			{Name: "pin", TypeName: "varchar", TypeOid: pgtype.VarcharOID, Num: 1, Length: 4, TypeLength: -1},
+		},
+	}
+	driver, _, err := toolkit.NewDriver(table, nil)
+	require.NoError(t, err)
+
+	params := map[string]toolkit.ParamsValue{
+		"column": toolkit.ParamsValue("pin"),
+		"key":    toolkit.ParamsValue(testFpeKey),
+	}
+	_, warnings, err := FpeTransformerDefinition.Instance(context.Background(), driver, params, nil, "")
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+	assert.Equal(t, toolkit.WarningValidationSeverity, warnings[0].Severity)
+	assert.Equal(t, 6, warnings[0].Meta["MinLength"])
+
+	params["invalid_length"] = toolkit.ParamsValue(fpeInvalidLengthKeep)
+	_, warnings, err = FpeTransformerDefinition.Instance(context.Background(), driver, params, nil, "")
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+}
+
+func TestFpeTransformer_key_from_env(t *testing.T) {
+	t.Setenv(FpeKeyEnvVariable, testFpeKey)
+	driver, _ := getDriverAndRecord("data", "1234567890")
+	_, warnings, err := FpeTransformerDefinition.Instance(
+		context.Background(), driver,
+		map[string]toolkit.ParamsValue{"column": toolkit.ParamsValue("data")}, nil, "",
+	)
+	require.NoError(t, err)
+	require.Empty(t, warnings)
+}
+
+func TestFpeTransformer_invalid_key(t *testing.T) {
+	t.Setenv(FpeKeyEnvVariable, "")
+	tests := []struct {
+		name string
+		key  toolkit.ParamsValue
+	}{
+		{name: "not set", key: nil},
+		{name: "not hex", key: toolkit.ParamsValue("qwerty")},
+		{name: "wrong length", key: toolkit.ParamsValue("0011")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := map[string]toolkit.ParamsValue{"column": toolkit.ParamsValue("data")}
+			if tt.key != nil {
+				params["key"] = tt.key
+			}
+			driver, _ := getDriverAndRecord("data", "1234567890")
+			_, warnings, err := FpeTransformerDefinition.Instance(context.Background(), driver, params, nil, "")
+			require.NoError(t, err)
+			assert.True(t, warnings.IsFatal())
+		})
+	}
+}
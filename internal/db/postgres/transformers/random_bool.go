@@ -99,6 +99,11 @@ func NewBooleanTransformer(ctx context.Context, driver *toolkit.Driver, paramete
 	}, nil, nil
 }
 
+// GetValueSpaceSize - returns the number of the boolean values
+func (rbt *BooleanTransformer) GetValueSpaceSize() float64 {
+	return 2
+}
+
 func (rbt *BooleanTransformer) GetAffectedColumns() map[int]string {
 	return rbt.affectedColumns
 }
@@ -67,6 +67,7 @@ type ChoiceTransformer struct {
 	validate        bool
 	affectedColumns map[int]string
 	keepNull        bool
+	valueSpaceSize  float64
 }
 
 func NewRandomChoiceTransformer(
@@ -162,9 +163,15 @@ func NewRandomChoiceTransformer(
 		validate:        validate,
 		affectedColumns: affectedColumns,
 		keepNull:        keepNull,
+		valueSpaceSize:  float64(len(rawValues)),
 	}, warnings, nil
 }
 
+// GetValueSpaceSize - returns the number of the values to choose from
+func (rct *ChoiceTransformer) GetValueSpaceSize() float64 {
+	return rct.valueSpaceSize
+}
+
 func (rct *ChoiceTransformer) GetAffectedColumns() map[int]string {
 	return rct.affectedColumns
 }
@@ -28,6 +28,10 @@ var randomCompanyTransformerDefinition = utils.NewTransformerDefinition(
 	).SetRequired(true).
 		SetIsColumnContainer(true),
 
+	localeParameterDefinition,
+
+	dictionaryFileParameterDefinition,
+
 	engineParameterDefinition,
 )
 
@@ -72,7 +76,12 @@ func NewRandomCompanyTransformer(ctx context.Context, driver *toolkit.Driver, pa
 		engineMode = hashEngineMode
 	}
 
-	t := transformers.NewRandomCompanyTransformer(nil)
+	ds, warns, err := getLocaleDataset(parameters)
+	if err != nil || warns.IsFatal() {
+		return nil, warns, err
+	}
+
+	t := transformers.NewRandomCompanyTransformer(ds.Company)
 
 	g, err := getGenerateEngine(ctx, engine, t.GetRequiredGeneratorByteLength())
 	if err != nil {
@@ -3,6 +3,7 @@ package transformers
 import (
 	"context"
 	"slices"
+	"strings"
 	"testing"
 
 	"github.com/rs/zerolog/log"
@@ -154,3 +155,32 @@ func TestRandomCompanyTransformer_Transform_static_nullable(t *testing.T) {
 	require.NoError(t, err)
 	require.True(t, rawVal.IsNull)
 }
+
+func TestRandomCompanyTransformer_Transform_locale(t *testing.T) {
+	ds, err := transformers.GetLocaleDataset("fr_FR")
+	require.NoError(t, err)
+
+	driver, record := getDriverAndRecord("data", "ACME Corp.")
+	transformer, warnings, err := randomCompanyTransformerDefinition.Instance(
+		context.Background(),
+		driver,
+		map[string]toolkit.ParamsValue{
+			"columns": toolkit.ParamsValue(`[{"name": "data", "template": "{{ .CompanyName }}|{{ .CompanySuffix }}"}]`),
+			"engine":  toolkit.ParamsValue("hash"),
+			"locale":  toolkit.ParamsValue("fr_FR"),
+		},
+		nil,
+		"",
+	)
+	require.NoError(t, err)
+	require.Empty(t, warnings)
+
+	r, err := transformer.Transformer.Transform(context.Background(), record)
+	require.NoError(t, err)
+	rawVal, err := r.GetRawColumnValueByName("data")
+	require.NoError(t, err)
+	parts := strings.Split(string(rawVal.Data), "|")
+	require.Len(t, parts, 2)
+	require.True(t, slices.Contains(ds.Company["CompanyName"], parts[0]))
+	require.True(t, slices.Contains(ds.Company["CompanySuffix"], parts[1]))
+}
@@ -45,7 +45,6 @@ const (
 	RandomCCNumberTransformerName            = "RandomCCNumber"
 	RandomCurrencyTransformerName            = "RandomCurrency"
 	RandomAmountWithCurrencyTransformerName  = "RandomAmountWithCurrency"
-	RandomPhoneNumberTransformerName         = "RandomPhoneNumber"
 	RandomTollFreePhoneNumberTransformerName = "RandomTollFreePhoneNumber"
 	RandomE164PhoneNumberTransformerName     = "RandomE164PhoneNumber"
 )
@@ -168,11 +167,6 @@ var FakerTransformersDes = map[string]*FakerTransformerDef{
 	},
 
 	// Faker Phone
-	RandomPhoneNumberTransformerName: {
-		Generator:      faker.Phonenumber,
-		SupportedTypes: []string{"text", "varchar", "char", "bpchar", "citext"},
-		Description:    "Generates a random phone number.",
-	},
 	RandomTollFreePhoneNumberTransformerName: {
 		Generator:      faker.TollFreePhoneNumber,
 		SupportedTypes: []string{"text", "varchar", "char", "bpchar", "citext"},
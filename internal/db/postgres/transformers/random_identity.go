@@ -0,0 +1,396 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transformers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"text/template"
+	"time"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/generators/transformers"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+const RandomIdentityTransformerName = "RandomIdentity"
+
+const randomIdentityDateFormat = "2006-01-02"
+
+// randomIdentityAttributes - maps the column attribute to the identity value
+var randomIdentityAttributes = map[string]func(v *transformers.IdentityValue) string{
+	"first_name": func(v *transformers.IdentityValue) string { return v.FirstName },
+	"last_name":  func(v *transformers.IdentityValue) string { return v.LastName },
+	"full_name":  func(v *transformers.IdentityValue) string { return v.FullName },
+	"title":      func(v *transformers.IdentityValue) string { return v.Title },
+	"gender":     func(v *transformers.IdentityValue) string { return v.Gender },
+	"email":      func(v *transformers.IdentityValue) string { return v.Email },
+	"username":   func(v *transformers.IdentityValue) string { return v.Username },
+	"phone":      func(v *transformers.IdentityValue) string { return v.Phone },
+	"birth_date": func(v *transformers.IdentityValue) string { return v.BirthDate.Format(randomIdentityDateFormat) },
+}
+
+var randomIdentityTransformerDefinition = utils.NewTransformerDefinition(
+	utils.NewTransformerProperties(
+		RandomIdentityTransformerName,
+		"Generate consistent person identity (name, email, username, phone, birth date, gender) for several columns",
+	),
+
+	NewRandomIdentityTransformer,
+
+	toolkit.MustNewParameterDefinition(
+		"columns",
+		"affected column names."+
+			"The structure:"+
+			`{`+
+			`"name": "type:string, required:true, description: column Name",`+
+			`"attribute": "type:string, required:false, description: identity attribute (first_name, last_name, `+
+			`full_name, title, gender, email, username, phone, birth_date)",`+
+			`"template": "type:string, required:false, description: gotemplate with identity attributes injections",`+
+			`"keep_null": "type:bool, required:false, description: keep null values",`+
+			`}`,
+	).SetRequired(true).
+		SetIsColumnContainer(true),
+
+	toolkit.MustNewParameterDefinition(
+		"key_columns",
+		"the columns which original values are used as the hash engine input. "+
+			"The original values of the affected columns are used by default",
+	),
+
+	toolkit.MustNewParameterDefinition(
+		"gender",
+		"set specific gender (possible values: Male, Female, Any)",
+	).SetDefaultValue(toolkit.ParamsValue("Any")),
+
+	toolkit.MustNewParameterDefinition(
+		"domains",
+		"the domains of the generated emails",
+	).SetDefaultValue(toolkit.ParamsValue(`["example.com", "example.net", "example.org"]`)),
+
+	toolkit.MustNewParameterDefinition(
+		"min_birth_date",
+		"the min generated birth date",
+	).SetDefaultValue(toolkit.ParamsValue("1945-01-01")),
+
+	toolkit.MustNewParameterDefinition(
+		"max_birth_date",
+		"the max generated birth date",
+	).SetDefaultValue(toolkit.ParamsValue("2005-12-31")),
+
+	localeParameterDefinition,
+
+	dictionaryFileParameterDefinition,
+
+	engineParameterDefinition,
+)
+
+type randomIdentityColumn struct {
+	Name      string `json:"name"`
+	Attribute string `json:"attribute"`
+	Template  string `json:"template"`
+	KeepNull  *bool  `json:"keep_null"`
+	tmpl      *template.Template
+	columnIdx int
+}
+
+type RandomIdentityTransformer struct {
+	t               *transformers.RandomIdentityTransformer
+	columns         []*randomIdentityColumn
+	keyColumnsIdx   []int
+	affectedColumns map[int]string
+	engine          int
+	// originalData - the original values of the key columns that are used as the hash engine input
+	originalData []byte
+	buf          *bytes.Buffer
+}
+
+func NewRandomIdentityTransformer(ctx context.Context, driver *toolkit.Driver, parameters map[string]toolkit.Parameterizer) (utils.Transformer, toolkit.ValidationWarnings, error) {
+	var engine, gender, minBirthDate, maxBirthDate string
+	var columns []*randomIdentityColumn
+	var keyColumns, domains []string
+
+	if err := parameters["engine"].Scan(&engine); err != nil {
+		return nil, nil, fmt.Errorf(`unable to scan "engine" param: %w`, err)
+	}
+	engineMode := randomEngineMode
+	if engine == HashEngineParameterName {
+		engineMode = hashEngineMode
+	}
+
+	if err := parameters["columns"].Scan(&columns); err != nil {
+		return nil, nil, fmt.Errorf(`unable to scan "columns" param: %w`, err)
+	}
+	affectedColumns, warns := validateIdentityColumns(driver, columns)
+
+	if err := parameters["key_columns"].Scan(&keyColumns); err != nil {
+		return nil, nil, fmt.Errorf(`unable to scan "key_columns" param: %w`, err)
+	}
+	keyColumnsIdx := make([]int, 0, len(keyColumns))
+	for idx, name := range keyColumns {
+		columnIdx, _, ok := driver.GetColumnByName(name)
+		if !ok {
+			warns = append(warns, toolkit.NewValidationWarning().
+				SetSeverity(toolkit.ErrorValidationSeverity).
+				AddMeta("ParameterName", "key_columns").
+				AddMeta("ParameterValue", name).
+				AddMeta("ListIdx", idx).
+				SetMsg("column is not found"))
+			continue
+		}
+		keyColumnsIdx = append(keyColumnsIdx, columnIdx)
+	}
+	if len(keyColumns) == 0 {
+		for _, c := range columns {
+			keyColumnsIdx = append(keyColumnsIdx, c.columnIdx)
+		}
+	}
+
+	if err := parameters["gender"].Scan(&gender); err != nil {
+		return nil, nil, fmt.Errorf(`unable to scan "gender" param: %w`, err)
+	}
+	if err := parameters["domains"].Scan(&domains); err != nil {
+		return nil, nil, fmt.Errorf(`unable to scan "domains" param: %w`, err)
+	}
+	if len(domains) == 0 {
+		warns = append(warns, toolkit.NewValidationWarning().
+			SetSeverity(toolkit.ErrorValidationSeverity).
+			AddMeta("ParameterName", "domains").
+			SetMsg("at least one domain is required"))
+	}
+
+	if err := parameters["min_birth_date"].Scan(&minBirthDate); err != nil {
+		return nil, nil, fmt.Errorf(`unable to scan "min_birth_date" param: %w`, err)
+	}
+	if err := parameters["max_birth_date"].Scan(&maxBirthDate); err != nil {
+		return nil, nil, fmt.Errorf(`unable to scan "max_birth_date" param: %w`, err)
+	}
+	minDate, w := parseIdentityDate("min_birth_date", minBirthDate)
+	warns = append(warns, w...)
+	maxDate, w := parseIdentityDate("max_birth_date", maxBirthDate)
+	warns = append(warns, w...)
+	if len(w) == 0 && maxDate.Before(minDate) {
+		warns = append(warns, toolkit.NewValidationWarning().
+			SetSeverity(toolkit.ErrorValidationSeverity).
+			AddMeta("ParameterName", "max_birth_date").
+			AddMeta("ParameterValue", maxBirthDate).
+			SetMsg("max_birth_date must not be before min_birth_date"))
+	}
+
+	ds, w, err := getLocaleDataset(parameters)
+	if err != nil {
+		return nil, nil, err
+	}
+	warns = append(warns, w...)
+	if warns.IsFatal() {
+		return nil, warns, nil
+	}
+
+	if gender != transformers.AnyGenderName {
+		warns = append(warns, randomNameTransformerValidateGender(gender, transformers.NewPersonalDatabase(ds.Person).Genders)...)
+		if warns.IsFatal() {
+			return nil, warns, nil
+		}
+	}
+
+	t, err := transformers.NewRandomIdentityTransformer(gender, ds, domains, minDate, maxDate)
+	if err != nil {
+		return nil, toolkit.ValidationWarnings{
+			toolkit.NewValidationWarning().
+				SetSeverity(toolkit.ErrorValidationSeverity).
+				AddMeta("Error", err.Error()).
+				SetMsg("cannot create identity generator"),
+		}, nil
+	}
+
+	g, err := getGenerateEngine(ctx, engine, t.GetRequiredGeneratorByteLength())
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to get generator: %w", err)
+	}
+	if err = t.SetGenerator(g); err != nil {
+		return nil, nil, fmt.Errorf("unable to set generator: %w", err)
+	}
+
+	return &RandomIdentityTransformer{
+		t:               t,
+		columns:         columns,
+		keyColumnsIdx:   keyColumnsIdx,
+		affectedColumns: affectedColumns,
+		engine:          engineMode,
+		buf:             bytes.NewBuffer(nil),
+	}, warns, nil
+}
+
+func (rit *RandomIdentityTransformer) GetAffectedColumns() map[int]string {
+	return rit.affectedColumns
+}
+
+func (rit *RandomIdentityTransformer) Init(ctx context.Context) error {
+	return nil
+}
+
+func (rit *RandomIdentityTransformer) Done(ctx context.Context) error {
+	return nil
+}
+
+func (rit *RandomIdentityTransformer) Transform(ctx context.Context, r *toolkit.Record) (*toolkit.Record, error) {
+	rit.originalData = rit.originalData[:0]
+	if rit.engine == hashEngineMode {
+		for _, idx := range rit.keyColumnsIdx {
+			rawVal, err := r.GetRawColumnValueByIdx(idx)
+			if err != nil {
+				return nil, fmt.Errorf("unable to get raw value by idx %d: %w", idx, err)
+			}
+			if !rawVal.IsNull {
+				rit.originalData = append(rit.originalData, rawVal.Data...)
+			}
+		}
+	}
+
+	identity, err := rit.t.GetIdentity(rit.originalData)
+	if err != nil {
+		return nil, fmt.Errorf("error generating identity: %w", err)
+	}
+
+	for _, c := range rit.columns {
+		rawVal, err := r.GetRawColumnValueByIdx(c.columnIdx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get raw value by idx %d: %w", c.columnIdx, err)
+		}
+		if rawVal.IsNull && *c.KeepNull {
+			continue
+		}
+
+		rit.buf.Reset()
+		if c.tmpl != nil {
+			if err = c.tmpl.Execute(rit.buf, identity); err != nil {
+				return nil, fmt.Errorf("error executing template for column %s: %w", c.Name, err)
+			}
+		} else {
+			rit.buf.WriteString(randomIdentityAttributes[c.Attribute](identity))
+		}
+		newRawVal := toolkit.NewRawValue(slices.Clone(rit.buf.Bytes()), false)
+		if err = r.SetRawColumnValueByIdx(c.columnIdx, newRawVal); err != nil {
+			return nil, fmt.Errorf("unable to set new value for column \"%s\": %w", c.Name, err)
+		}
+	}
+	return r, nil
+}
+
+func parseIdentityDate(paramName, value string) (time.Time, toolkit.ValidationWarnings) {
+	res, err := time.Parse(randomIdentityDateFormat, value)
+	if err != nil {
+		return time.Time{}, toolkit.ValidationWarnings{
+			toolkit.NewValidationWarning().
+				SetSeverity(toolkit.ErrorValidationSeverity).
+				AddMeta("ParameterName", paramName).
+				AddMeta("ParameterValue", value).
+				AddMeta("Error", err.Error()).
+				SetMsg("invalid date: expected YYYY-MM-DD format"),
+		}
+	}
+	return res, nil
+}
+
+func validateIdentityColumns(driver *toolkit.Driver, columns []*randomIdentityColumn) (map[int]string, toolkit.ValidationWarnings) {
+	affectedColumns := make(map[int]string)
+	var warns toolkit.ValidationWarnings
+
+	for idx, c := range columns {
+		if c.Name == "" {
+			warns = append(warns,
+				toolkit.NewValidationWarning().
+					SetSeverity(toolkit.ErrorValidationSeverity).
+					AddMeta("ParameterName", "columns").
+					AddMeta("ListIdx", idx).
+					SetMsg("name is required"),
+			)
+			continue
+		}
+
+		columnIdx, _, ok := driver.GetColumnByName(c.Name)
+		if !ok {
+			warns = append(warns, toolkit.NewValidationWarning().
+				SetSeverity(toolkit.ErrorValidationSeverity).
+				AddMeta("ParameterName", "columns").
+				AddMeta("ParameterValue", c.Name).
+				AddMeta("ListIdx", idx).
+				SetMsg("column is not found"))
+			continue
+		}
+		affectedColumns[columnIdx] = c.Name
+		c.columnIdx = columnIdx
+
+		if c.KeepNull == nil {
+			defaultKeepNullValue := true
+			c.KeepNull = &defaultKeepNullValue
+		}
+
+		switch {
+		case c.Attribute != "" && c.Template != "":
+			warns = append(warns,
+				toolkit.NewValidationWarning().
+					SetSeverity(toolkit.ErrorValidationSeverity).
+					AddMeta("ParameterName", "columns").
+					AddMeta("ListIdx", idx).
+					SetMsg("\"attribute\" and \"template\" cannot be used together"),
+			)
+		case c.Template != "":
+			tmpl, err := template.New(c.Name).
+				Funcs(toolkit.FuncMap()).
+				Parse(c.Template)
+			if err != nil {
+				warns = append(warns, toolkit.NewValidationWarning().
+					SetSeverity(toolkit.ErrorValidationSeverity).
+					AddMeta("Error", err.Error()).
+					AddMeta("ParameterName", "columns").
+					AddMeta("ListIdx", idx).
+					SetMsg("error parsing template"),
+				)
+				continue
+			}
+			c.tmpl = tmpl
+		case c.Attribute == "":
+			warns = append(warns,
+				toolkit.NewValidationWarning().
+					SetSeverity(toolkit.ErrorValidationSeverity).
+					AddMeta("ParameterName", "columns").
+					AddMeta("ListIdx", idx).
+					SetMsg("\"attribute\" or \"template\" is required"),
+			)
+		default:
+			if _, ok := randomIdentityAttributes[c.Attribute]; !ok {
+				warns = append(warns,
+					toolkit.NewValidationWarning().
+						SetSeverity(toolkit.ErrorValidationSeverity).
+						AddMeta("ParameterName", "columns").
+						AddMeta("ParameterValue", c.Attribute).
+						AddMeta("ListIdx", idx).
+						AddMeta("AllowedValues", slices.Sorted(maps.Keys(randomIdentityAttributes))).
+						SetMsg("unknown identity attribute"),
+				)
+			}
+		}
+	}
+
+	return affectedColumns, warns
+}
+
+func init() {
+	utils.DefaultTransformerRegistry.MustRegister(randomIdentityTransformerDefinition)
+}
@@ -0,0 +1,207 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transformers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/generators/transformers"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+func TestRandomIdentityTransformer_Transform(t *testing.T) {
+	ds, err := transformers.GetLocaleDataset("fr_FR")
+	require.NoError(t, err)
+
+	params := map[string]toolkit.ParamsValue{
+		"columns": toolkit.ParamsValue(`[
+			{"name": "first_name", "attribute": "first_name"},
+			{"name": "last_name", "attribute": "last_name"},
+			{"name": "data", "attribute": "email"},
+			{"name": "date_date", "attribute": "birth_date"}
+		]`),
+		"key_columns":    toolkit.ParamsValue(`["id"]`),
+		"locale":         toolkit.ParamsValue("fr_FR"),
+		"engine":         toolkit.ParamsValue("hash"),
+		"domains":        toolkit.ParamsValue(`["example.fr"]`),
+		"min_birth_date": toolkit.ParamsValue("1980-01-01"),
+		"max_birth_date": toolkit.ParamsValue("1989-12-31"),
+	}
+	columns := []string{"id", "first_name", "last_name", "data", "date_date"}
+
+	transform := func(original string) []string {
+		driver, record := getDriverAndRecordByColumns(columns, original)
+		transformer, warnings, err := randomIdentityTransformerDefinition.Instance(
+			context.Background(), driver, params, nil, "",
+		)
+		require.NoError(t, err)
+		require.Empty(t, warnings)
+		r, err := transformer.Transformer.Transform(context.Background(), record)
+		require.NoError(t, err)
+		res := make([]string, 0, len(columns))
+		for _, c := range columns {
+			rawVal, err := r.GetRawColumnValueByName(c)
+			require.NoError(t, err)
+			res = append(res, string(rawVal.Data))
+		}
+		return res
+	}
+
+	res := transform("1\tJohn\tSmith\tjohn@corp.com\t2000-01-01")
+	assert.Equal(t, "1", res[0])
+	firstName, lastName, email, birthDate := res[1], res[2], res[3], res[4]
+	assert.True(t, testStringContainsOneOfItemFromList(firstName, append(
+		ds.Person[transformers.MaleGenderName]["FirstName"], ds.Person[transformers.FemaleGenderName]["FirstName"]...,
+	)))
+	assert.Contains(t, ds.Person[transformers.MaleGenderName]["LastName"], lastName)
+	assert.True(t, strings.HasSuffix(email, "@example.fr"))
+	assert.Regexp(t, `^198\d-\d{2}-\d{2}$`, birthDate)
+
+	// The identity depends only on the key column
+	assert.Equal(t, res, transform("1\tJane\tDoe\tjane@corp.com\t1990-05-05"))
+	assert.NotEqual(t, res, transform("2\tJohn\tSmith\tjohn@corp.com\t2000-01-01"))
+}
+
+func TestRandomIdentityTransformer_Transform_template_and_keep_null(t *testing.T) {
+	driver, record := getDriverAndRecordByColumns([]string{"first_name", "data"}, "\\N\tjohn@corp.com")
+	transformer, warnings, err := randomIdentityTransformerDefinition.Instance(
+		context.Background(),
+		driver,
+		map[string]toolkit.ParamsValue{
+			"columns": toolkit.ParamsValue(`[
+				{"name": "first_name", "attribute": "first_name"},
+				{"name": "data", "template": "{{ .FullName }} <{{ .Email }}>"}
+			]`),
+			"gender": toolkit.ParamsValue("Female"),
+		},
+		nil,
+		"",
+	)
+	require.NoError(t, err)
+	require.Empty(t, warnings)
+
+	r, err := transformer.Transformer.Transform(context.Background(), record)
+	require.NoError(t, err)
+	firstName, err := r.GetRawColumnValueByName("first_name")
+	require.NoError(t, err)
+	assert.True(t, firstName.IsNull)
+
+	data, err := r.GetRawColumnValueByName("data")
+	require.NoError(t, err)
+	assert.Regexp(t, `^\S+ \S+ <[a-z0-9._]+@example\.(com|net|org)>$`, string(data.Data))
+	assert.True(t, testStringContainsOneOfItemFromList(string(data.Data), transformers.DefaultFirstNamesFemale))
+}
+
+func TestRandomIdentityTransformer_validation(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]toolkit.ParamsValue
+		msg    string
+	}{
+		{
+			name: "unknown attribute",
+			params: map[string]toolkit.ParamsValue{
+				"columns": toolkit.ParamsValue(`[{"name": "data", "attribute": "ssn"}]`),
+			},
+			msg: "unknown identity attribute",
+		},
+		{
+			name: "attribute and template",
+			params: map[string]toolkit.ParamsValue{
+				"columns": toolkit.ParamsValue(`[{"name": "data", "attribute": "email", "template": "{{ .Email }}"}]`),
+			},
+			msg: `"attribute" and "template" cannot be used together`,
+		},
+		{
+			name: "unknown key column",
+			params: map[string]toolkit.ParamsValue{
+				"columns":     toolkit.ParamsValue(`[{"name": "data", "attribute": "email"}]`),
+				"key_columns": toolkit.ParamsValue(`["unknown"]`),
+			},
+			msg: "column is not found",
+		},
+		{
+			name: "birth date range",
+			params: map[string]toolkit.ParamsValue{
+				"columns":        toolkit.ParamsValue(`[{"name": "data", "attribute": "email"}]`),
+				"min_birth_date": toolkit.ParamsValue("2000-01-01"),
+				"max_birth_date": toolkit.ParamsValue("1990-01-01"),
+			},
+			msg: "max_birth_date must not be before min_birth_date",
+		},
+		{
+			name: "unknown gender",
+			params: map[string]toolkit.ParamsValue{
+				"columns": toolkit.ParamsValue(`[{"name": "data", "attribute": "email"}]`),
+				"gender":  toolkit.ParamsValue("Unknown"),
+			},
+			msg: "wrong gender name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driver, _ := getDriverAndRecord("data", "john@corp.com")
+			_, warnings, err := randomIdentityTransformerDefinition.Instance(
+				context.Background(), driver, tt.params, nil, "",
+			)
+			require.NoError(t, err)
+			require.True(t, warnings.IsFatal())
+			require.Len(t, warnings, 1)
+			assert.Equal(t, tt.msg, warnings[0].Msg)
+		})
+	}
+}
+
+func TestRandomIdentityTransformer_Transform_unique(t *testing.T) {
+	columns := []string{"id", "data"}
+	driver, _ := getDriverAndRecordByColumns(columns, "1\tjohn@corp.com")
+	driver.Table.Constraints = []toolkit.Constraint{
+		toolkit.NewUnique("public", "test_data_key", "", 1, []toolkit.AttNum{driver.Table.Columns[1].Num}),
+	}
+	transformer, warnings, err := randomIdentityTransformerDefinition.Instance(
+		context.Background(), driver,
+		map[string]toolkit.ParamsValue{
+			"columns":     toolkit.ParamsValue(`[{"name": "data", "attribute": "email"}]`),
+			"key_columns": toolkit.ParamsValue(`["id"]`),
+			"locale":      toolkit.ParamsValue("ja_JP"),
+		}, nil, "",
+	)
+	require.NoError(t, err)
+	require.Empty(t, warnings)
+	// The email column of the column container is transformed in the unique mode
+	require.IsType(t, &utils.UniqueTransformer{}, transformer.Transformer)
+
+	seen := make(map[string]struct{})
+	for i := 0; i < 1000; i++ {
+		_, record := getDriverAndRecordByColumns(columns, fmt.Sprintf("%d\tjohn@corp.com", i))
+		record.Driver = driver
+		r, err := transformer.Transformer.Transform(context.Background(), record)
+		require.NoError(t, err)
+		res, err := r.GetRawColumnValueByName("data")
+		require.NoError(t, err)
+		// The names of the ja_JP locale cannot be transliterated, so the fallback login is used
+		require.Regexp(t, `^user\d+@example\.(com|net|org)$`, string(res.Data))
+		require.NotContains(t, seen, string(res.Data))
+		seen[string(res.Data)] = struct{}{}
+	}
+}
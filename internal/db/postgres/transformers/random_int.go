@@ -83,6 +83,7 @@ type IntegerTransformer struct {
 	columnIdx       int
 	dynamicMode     bool
 	intSize         int
+	valueSpaceSize  float64
 
 	columnParam   toolkit.Parameterizer
 	maxParam      toolkit.Parameterizer
@@ -184,8 +185,9 @@ func NewIntegerTransformer(ctx context.Context, driver *toolkit.Driver, paramete
 		keepNullParam: keepNullParam,
 		engineParam:   engineParam,
 
-		dynamicMode: dynamicMode,
-		intSize:     intSize,
+		dynamicMode:    dynamicMode,
+		intSize:        intSize,
+		valueSpaceSize: getInt64ValueSpaceSize(limiter, dynamicMode),
 
 		transform: func(bytes []byte) (int64, error) {
 			return t.Transform(nil, bytes)
@@ -193,6 +195,12 @@ func NewIntegerTransformer(ctx context.Context, driver *toolkit.Driver, paramete
 	}, nil, nil
 }
 
+// GetValueSpaceSize - returns the number of the integers the limiter generates between min and max. In the dynamic
+// mode the limits are changed for each record, so the size is unknown
+func (rit *IntegerTransformer) GetValueSpaceSize() float64 {
+	return rit.valueSpaceSize
+}
+
 func (rit *IntegerTransformer) GetAffectedColumns() map[int]string {
 	return rit.affectedColumns
 }
@@ -265,6 +273,14 @@ func getIntThresholds(size int) (int64, int64, error) {
 	return 0, 0, fmt.Errorf("unsupported int size %d", size)
 }
 
+func getInt64ValueSpaceSize(limiter *transformers.Int64Limiter, dynamicMode bool) float64 {
+	if dynamicMode {
+		return math.Inf(1)
+	}
+	// The limiter returns the values in the range [min, max)
+	return float64(limiter.MaxValue) - float64(limiter.MinValue)
+}
+
 func getRandomInt64LimiterForDynamicParameter(size int, requestedMinValue, requestedMaxValue int64) (*transformers.Int64Limiter, error) {
 	minValue, maxValue, err := getIntThresholds(size)
 	if err != nil {
@@ -313,3 +313,39 @@ func TestRandomIntTransformer_Transform_deterministic_dynamic(t *testing.T) {
 		})
 	}
 }
+
+func TestRandomIntTransformer_Transform_unique(t *testing.T) {
+	driver, _ := getDriverAndRecord("id4", "1")
+	driver.Table.Constraints = []toolkit.Constraint{
+		toolkit.NewUnique("public", "test_id4_key", "", 1, []toolkit.AttNum{driver.Table.Columns[0].Num}),
+	}
+	driver.Table.RowsEstimate = 20
+	ctx := utils2.WithSalt(context.Background(), []byte("salt"))
+
+	transformer, warnings, err := integerTransformerDefinition.Instance(
+		ctx, driver,
+		map[string]toolkit.ParamsValue{
+			"column": toolkit.ParamsValue("id4"),
+			"min":    toolkit.ParamsValue("1"),
+			"max":    toolkit.ParamsValue("10"),
+			"engine": toolkit.ParamsValue("hash"),
+		}, nil, "",
+	)
+	require.NoError(t, err)
+	// The value space of 9 integers is too small for 20 rows and the hash engine is retried in the unique mode
+	require.Len(t, warnings, 2)
+	require.Equal(t, float64(9), warnings[0].Meta["ValueSpaceSize"])
+	require.Equal(t, "hash", warnings[1].Meta["Engine"])
+
+	seen := make(map[string]struct{})
+	for i := 0; i < 9; i++ {
+		_, record := getDriverAndRecord("id4", fmt.Sprintf("%d", i))
+		record.Driver = driver
+		r, err := transformer.Transformer.Transform(ctx, record)
+		require.NoError(t, err)
+		res, err := r.GetRawColumnValueByName("id4")
+		require.NoError(t, err)
+		require.NotContains(t, seen, string(res.Data))
+		seen[string(res.Data)] = struct{}{}
+	}
+}
@@ -54,7 +54,9 @@ var randomPersonTransformerDefinition = utils.NewTransformerDefinition(
 	).SetSupportTemplate(true).
 		SetDefaultValue(toolkit.ParamsValue("Any")),
 
-	// TODO: Allow user to override the default names, surnames and genders with kind of dictionary
+	localeParameterDefinition,
+
+	dictionaryFileParameterDefinition,
 
 	engineParameterDefinition,
 )
@@ -112,7 +114,12 @@ func NewRandomNameTransformer(ctx context.Context, driver *toolkit.Driver, param
 		engineMode = hashEngineMode
 	}
 
-	t := transformers.NewRandomPersonTransformer(gender, nil)
+	ds, warns, err := getLocaleDataset(parameters)
+	if err != nil || warns.IsFatal() {
+		return nil, warns, err
+	}
+
+	t := transformers.NewRandomPersonTransformer(gender, ds.Person)
 
 	g, err := getGenerateEngine(ctx, engine, t.GetRequiredGeneratorByteLength())
 	if err != nil {
@@ -153,9 +160,12 @@ func NewRandomNameTransformer(ctx context.Context, driver *toolkit.Driver, param
 	if err := genderMappingParam.Scan(&genderMapping); err != nil {
 		return nil, nil, fmt.Errorf(`unable to scan "gender_mapping" param: %w`, err)
 	}
-	// generate reverse mapping for faster access
+	// generate reverse mapping for faster access. The mapping is used only in dynamic mode, so the default mapping
+	// is not validated against the genders of the user dataset otherwise
 	for k, v := range genderMapping {
-		warns = append(warns, randomNameTransformerValidateGender(k, t.GetDb().Genders)...)
+		if dynamicMode {
+			warns = append(warns, randomNameTransformerValidateGender(k, t.GetDb().Genders)...)
+		}
 		for _, val := range v {
 			reverseGenderMapping[val] = k
 		}
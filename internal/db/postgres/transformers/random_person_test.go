@@ -2,6 +2,8 @@ package transformers
 
 import (
 	"context"
+	"os"
+	"path"
 	"slices"
 	"strings"
 	"testing"
@@ -344,3 +346,66 @@ func TestRandomPersonTransformer_Transform_keep_null(t *testing.T) {
 		assert.Equal(t, lastName, string(lastNameRawValue.Data))
 	})
 }
+
+func TestRandomPersonTransformer_Transform_locale(t *testing.T) {
+	ds, err := transformers.GetLocaleDataset("de_DE")
+	require.NoError(t, err)
+
+	params := map[string]toolkit.ParamsValue{
+		"columns": toolkit.ParamsValue(`[{"name": "data", "template": "{{ .FirstName }} {{ .LastName }}"}]`),
+		"engine":  toolkit.ParamsValue("hash"),
+		"gender":  toolkit.ParamsValue("Female"),
+		"locale":  toolkit.ParamsValue("de_DE"),
+	}
+
+	var results []string
+	for i := 0; i < 2; i++ {
+		driver, record := getDriverAndRecord("data", "Erika Mustermann")
+		transformer, warnings, err := randomPersonTransformerDefinition.Instance(
+			context.Background(), driver, params, nil, "",
+		)
+		require.NoError(t, err)
+		require.Empty(t, warnings)
+		r, err := transformer.Transformer.Transform(context.Background(), record)
+		require.NoError(t, err)
+		rawVal, err := r.GetRawColumnValueByName("data")
+		require.NoError(t, err)
+		results = append(results, string(rawVal.Data))
+	}
+	assert.Equal(t, results[0], results[1])
+
+	names := strings.SplitN(results[0], " ", 2)
+	require.Len(t, names, 2)
+	assert.Contains(t, ds.Person[transformers.FemaleGenderName]["FirstName"], names[0])
+	assert.Contains(t, ds.Person[transformers.FemaleGenderName]["LastName"], names[1])
+}
+
+func TestRandomPersonTransformer_Transform_dictionary_file(t *testing.T) {
+	dictionaryFile := path.Join(t.TempDir(), "dictionary.json")
+	err := os.WriteFile(
+		dictionaryFile,
+		[]byte(`{"person": {"Diverse": {"FirstName": ["Kim"], "LastName": ["Meier"]}}}`),
+		0600,
+	)
+	require.NoError(t, err)
+
+	driver, record := getDriverAndRecord("data", "Erika Mustermann")
+	transformer, warnings, err := randomPersonTransformerDefinition.Instance(
+		context.Background(),
+		driver,
+		map[string]toolkit.ParamsValue{
+			"columns":         toolkit.ParamsValue(`[{"name": "data", "template": "{{ .FirstName }} {{ .LastName }}"}]`),
+			"gender":          toolkit.ParamsValue("Diverse"),
+			"dictionary_file": toolkit.ParamsValue(dictionaryFile),
+		},
+		nil,
+		"",
+	)
+	require.NoError(t, err)
+	require.Empty(t, warnings)
+	r, err := transformer.Transformer.Transform(context.Background(), record)
+	require.NoError(t, err)
+	rawVal, err := r.GetRawColumnValueByName("data")
+	require.NoError(t, err)
+	assert.Equal(t, "Kim Meier", string(rawVal.Data))
+}
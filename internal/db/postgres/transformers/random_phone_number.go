@@ -0,0 +1,153 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transformers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/generators/transformers"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+const RandomPhoneNumberTransformerName = "RandomPhoneNumber"
+
+var randomPhoneNumberTransformerDefinition = utils.NewTransformerDefinition(
+	utils.NewTransformerProperties(
+		RandomPhoneNumberTransformerName,
+		"Generates a random phone number.",
+	).AddMeta(AllowApplyForReferenced, true).
+		AddMeta(RequireHashEngineParameter, true),
+
+	NewRandomPhoneNumberTransformer,
+
+	toolkit.MustNewParameterDefinition(
+		"column",
+		"column name",
+	).SetIsColumn(toolkit.NewColumnProperties().
+		SetAffected(true).
+		SetAllowedColumnTypes("text", "varchar", "char", "bpchar", "citext"),
+	).SetRequired(true),
+
+	keepNullParameterDefinition,
+
+	localeParameterDefinition,
+
+	dictionaryFileParameterDefinition,
+
+	engineParameterDefinition,
+)
+
+type RandomPhoneNumberTransformer struct {
+	t               *transformers.RandomPhoneTransformer
+	columnName      string
+	columnIdx       int
+	keepNull        bool
+	affectedColumns map[int]string
+}
+
+func NewRandomPhoneNumberTransformer(ctx context.Context, driver *toolkit.Driver, parameters map[string]toolkit.Parameterizer) (utils.Transformer, toolkit.ValidationWarnings, error) {
+	var columnName, engine string
+	var keepNull bool
+
+	p := parameters["column"]
+	if err := p.Scan(&columnName); err != nil {
+		return nil, nil, fmt.Errorf("unable to scan column param: %w", err)
+	}
+
+	idx, _, ok := driver.GetColumnByName(columnName)
+	if !ok {
+		return nil, nil, fmt.Errorf("column with name %s is not found", columnName)
+	}
+	affectedColumns := make(map[int]string)
+	affectedColumns[idx] = columnName
+
+	p = parameters["keep_null"]
+	if err := p.Scan(&keepNull); err != nil {
+		return nil, nil, fmt.Errorf(`unable to scan "keep_null" param: %w`, err)
+	}
+
+	p = parameters["engine"]
+	if err := p.Scan(&engine); err != nil {
+		return nil, nil, fmt.Errorf(`unable to scan "engine" param: %w`, err)
+	}
+
+	ds, warns, err := getLocaleDataset(parameters)
+	if err != nil || warns.IsFatal() {
+		return nil, warns, err
+	}
+
+	t, err := transformers.NewRandomPhoneTransformer(ds.Phone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to create phone generator: %w", err)
+	}
+
+	g, err := getGenerateEngine(ctx, engine, t.GetRequiredGeneratorByteLength())
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to get generator: %w", err)
+	}
+	if err = t.SetGenerator(g); err != nil {
+		return nil, nil, fmt.Errorf("unable to set generator: %w", err)
+	}
+
+	return &RandomPhoneNumberTransformer{
+		t:               t,
+		columnName:      columnName,
+		keepNull:        keepNull,
+		affectedColumns: affectedColumns,
+		columnIdx:       idx,
+	}, nil, nil
+}
+
+func (rpt *RandomPhoneNumberTransformer) GetAffectedColumns() map[int]string {
+	return rpt.affectedColumns
+}
+
+func (rpt *RandomPhoneNumberTransformer) Init(ctx context.Context) error {
+	return nil
+}
+
+func (rpt *RandomPhoneNumberTransformer) Done(ctx context.Context) error {
+	return nil
+}
+
+func (rpt *RandomPhoneNumberTransformer) GetValueSpaceSize() float64 {
+	return rpt.t.GetValueSpaceSize()
+}
+
+func (rpt *RandomPhoneNumberTransformer) Transform(ctx context.Context, r *toolkit.Record) (*toolkit.Record, error) {
+	val, err := r.GetRawColumnValueByIdx(rpt.columnIdx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to scan value: %w", err)
+	}
+	if val.IsNull && rpt.keepNull {
+		return r, nil
+	}
+
+	phone, err := rpt.t.GetPhone(val.Data)
+	if err != nil {
+		return nil, fmt.Errorf("error generating phone number: %w", err)
+	}
+	if err = r.SetRawColumnValueByIdx(rpt.columnIdx, toolkit.NewRawValue(slices.Clone(phone), false)); err != nil {
+		return nil, fmt.Errorf("unable to set new value: %w", err)
+	}
+	return r, nil
+}
+
+func init() {
+	utils.DefaultTransformerRegistry.MustRegister(randomPhoneNumberTransformerDefinition)
+}
@@ -0,0 +1,125 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transformers
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+func TestRandomPhoneNumberTransformer_Transform(t *testing.T) {
+	dictionaryFile := path.Join(t.TempDir(), "dictionary.json")
+	err := os.WriteFile(dictionaryFile, []byte(`{"phone": ["+41 ## ### ## ##"]}`), 0600)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		original string
+		params   map[string]toolkit.ParamsValue
+		pattern  string
+	}{
+		{
+			name:     "default",
+			original: "123-456-7890",
+			params:   map[string]toolkit.ParamsValue{},
+			pattern:  `^\d{3}-\d{3}-\d{4}$`,
+		},
+		{
+			name:     "locale",
+			original: "123-456-7890",
+			params: map[string]toolkit.ParamsValue{
+				"locale": toolkit.ParamsValue("ja_JP"),
+			},
+			pattern: `^(\+81 3|0\d{1,2})-\d{3,4}-\d{4}$`,
+		},
+		{
+			name:     "dictionary file",
+			original: "123-456-7890",
+			params: map[string]toolkit.ParamsValue{
+				"locale":          toolkit.ParamsValue("de_DE"),
+				"dictionary_file": toolkit.ParamsValue(dictionaryFile),
+			},
+			pattern: `^\+41 \d{2} \d{3} \d{2} \d{2}$`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.params["column"] = toolkit.ParamsValue("data")
+			driver, record := getDriverAndRecord("data", tt.original)
+			def, ok := utils.DefaultTransformerRegistry.Get(RandomPhoneNumberTransformerName)
+			require.True(t, ok)
+			transformer, warnings, err := def.Instance(context.Background(), driver, tt.params, nil, "")
+			require.NoError(t, err)
+			require.Empty(t, warnings)
+
+			r, err := transformer.Transformer.Transform(context.Background(), record)
+			require.NoError(t, err)
+			rawVal, err := r.GetRawColumnValueByName("data")
+			require.NoError(t, err)
+			require.False(t, rawVal.IsNull)
+			assert.Regexp(t, tt.pattern, string(rawVal.Data))
+		})
+	}
+}
+
+func TestRandomPhoneNumberTransformer_Transform_hash(t *testing.T) {
+	params := map[string]toolkit.ParamsValue{
+		"column": toolkit.ParamsValue("data"),
+		"locale": toolkit.ParamsValue("fr_FR"),
+		"engine": toolkit.ParamsValue("hash"),
+	}
+	var results []string
+	for i := 0; i < 2; i++ {
+		driver, record := getDriverAndRecord("data", "+33 1 23 45 67 89")
+		transformer, warnings, err := randomPhoneNumberTransformerDefinition.Instance(
+			context.Background(), driver, params, nil, "",
+		)
+		require.NoError(t, err)
+		require.Empty(t, warnings)
+		r, err := transformer.Transformer.Transform(context.Background(), record)
+		require.NoError(t, err)
+		rawVal, err := r.GetRawColumnValueByName("data")
+		require.NoError(t, err)
+		results = append(results, string(rawVal.Data))
+	}
+	assert.Equal(t, results[0], results[1])
+	assert.Regexp(t, `^(\+33 \d|0\d) \d{2} \d{2} \d{2} \d{2}$`, results[0])
+}
+
+func TestRandomPhoneNumberTransformer_unknown_locale(t *testing.T) {
+	driver, _ := getDriverAndRecord("data", "123-456-7890")
+	_, warnings, err := randomPhoneNumberTransformerDefinition.Instance(
+		context.Background(),
+		driver,
+		map[string]toolkit.ParamsValue{
+			"column": toolkit.ParamsValue("data"),
+			"locale": toolkit.ParamsValue("xx_XX"),
+		},
+		nil,
+		"",
+	)
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+	assert.Equal(t, "unknown locale", warnings[0].Msg)
+}
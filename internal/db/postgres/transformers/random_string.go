@@ -17,6 +17,7 @@ package transformers
 import (
 	"context"
 	"fmt"
+	"math"
 
 	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
 	"github.com/greenmaskio/greenmask/internal/generators/transformers"
@@ -75,6 +76,7 @@ type RandomStringTransformer struct {
 	keepNull        bool
 	affectedColumns map[int]string
 	columnIdx       int
+	valueSpaceSize  float64
 }
 
 func NewRandomStringTransformer(ctx context.Context, driver *toolkit.Driver, parameters map[string]toolkit.Parameterizer) (utils.Transformer, toolkit.ValidationWarnings, error) {
@@ -139,9 +141,23 @@ func NewRandomStringTransformer(ctx context.Context, driver *toolkit.Driver, par
 		keepNull:        keepNull,
 		affectedColumns: affectedColumns,
 		columnIdx:       idx,
+		valueSpaceSize:  getStringValueSpaceSize(len([]rune(symbols)), minLength, maxLength),
 	}, nil, nil
 }
 
+// GetValueSpaceSize - returns the number of the strings of the symbols with the length between min and max
+func (rst *RandomStringTransformer) GetValueSpaceSize() float64 {
+	return rst.valueSpaceSize
+}
+
+func getStringValueSpaceSize(symbolsCount, minLength, maxLength int) float64 {
+	var res float64
+	for l := minLength; l <= maxLength; l++ {
+		res += math.Pow(float64(symbolsCount), float64(l))
+	}
+	return res
+}
+
 func (rst *RandomStringTransformer) GetAffectedColumns() map[int]string {
 	return rst.affectedColumns
 }
@@ -24,6 +24,7 @@ import (
 	"github.com/go-faker/faker/v4"
 
 	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/generators/transformers"
 	"github.com/greenmaskio/greenmask/pkg/toolkit"
 )
 
@@ -48,12 +49,23 @@ var RealAddressTransformerDefinition = utils.NewTransformerDefinition(
 			`}`,
 	).SetRequired(true).
 		SetIsColumnContainer(true),
+
+	localeParameterDefinition,
+
+	dictionaryFileParameterDefinition,
+
+	engineParameterDefinition,
 )
 
 type RealAddressTransformer struct {
 	columns         []*RealAddressColumn
 	affectedColumns map[int]string
 	buf             *bytes.Buffer
+	// t - generates the address from the locale dataset. It is nil if the faker real addresses are used
+	t      *transformers.RandomAddressTransformer
+	engine int
+	// originalData - the original values of the columns that are used as the hash engine input
+	originalData []byte
 }
 
 type RealAddressColumn struct {
@@ -137,13 +149,67 @@ func NewRealAddressTransformer(ctx context.Context, driver *toolkit.Driver, para
 		return nil, warnings, nil
 	}
 
+	t, engineMode, warns, err := getRealAddressGenerator(ctx, parameters)
+	if err != nil {
+		return nil, nil, err
+	}
+	warnings = append(warnings, warns...)
+	if warnings.IsFatal() {
+		return nil, warnings, nil
+	}
+
 	return &RealAddressTransformer{
 		columns:         columns,
 		affectedColumns: affectedColumns,
 		buf:             bytes.NewBuffer(nil),
+		t:               t,
+		engine:          engineMode,
 	}, warnings, nil
 }
 
+// getRealAddressGenerator - returns the generator of the addresses from the locale dataset. The faker real
+// addresses are used for compatibility if the locale, dictionary file and engine are not set, in this case the
+// generator is nil
+func getRealAddressGenerator(
+	ctx context.Context, parameters map[string]toolkit.Parameterizer,
+) (*transformers.RandomAddressTransformer, int, toolkit.ValidationWarnings, error) {
+	var engine, locale, dictionaryFile string
+	if err := parameters["engine"].Scan(&engine); err != nil {
+		return nil, 0, nil, fmt.Errorf(`unable to scan "engine" param: %w`, err)
+	}
+	if err := parameters["locale"].Scan(&locale); err != nil {
+		return nil, 0, nil, fmt.Errorf(`unable to scan "locale" param: %w`, err)
+	}
+	if err := parameters["dictionary_file"].Scan(&dictionaryFile); err != nil {
+		return nil, 0, nil, fmt.Errorf(`unable to scan "dictionary_file" param: %w`, err)
+	}
+	if engine == RandomEngineParameterName && locale == "" && dictionaryFile == "" {
+		return nil, randomEngineMode, nil, nil
+	}
+
+	engineMode := randomEngineMode
+	if engine == HashEngineParameterName {
+		engineMode = hashEngineMode
+	}
+
+	ds, warns, err := getLocaleDataset(parameters)
+	if err != nil || warns.IsFatal() {
+		return nil, 0, warns, err
+	}
+	t, err := transformers.NewRandomAddressTransformer(ds.Address)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("unable to create address generator: %w", err)
+	}
+	g, err := getGenerateEngine(ctx, engine, t.GetRequiredGeneratorByteLength())
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("unable to get generator: %w", err)
+	}
+	if err = t.SetGenerator(g); err != nil {
+		return nil, 0, nil, fmt.Errorf("unable to set generator: %w", err)
+	}
+	return t, engineMode, nil, nil
+}
+
 func (rat *RealAddressTransformer) GetAffectedColumns() map[int]string {
 	return rat.affectedColumns
 }
@@ -157,7 +223,10 @@ func (rat *RealAddressTransformer) Done(ctx context.Context) error {
 }
 
 func (rat *RealAddressTransformer) Transform(ctx context.Context, r *toolkit.Record) (*toolkit.Record, error) {
-	address := getRealAddress()
+	address, err := rat.getAddress(r)
+	if err != nil {
+		return nil, err
+	}
 
 	// Iterate over the columns and update the record with generated address data
 	for _, col := range rat.columns {
@@ -185,6 +254,40 @@ func (rat *RealAddressTransformer) Transform(ctx context.Context, r *toolkit.Rec
 	return r, nil
 }
 
+// getAddress - generates the address using the locale dataset or the faker real addresses. The hash engine uses the
+// original values of all the affected columns
+func (rat *RealAddressTransformer) getAddress(r *toolkit.Record) (*RealAddressValue, error) {
+	if rat.t == nil {
+		return getRealAddress(), nil
+	}
+
+	rat.originalData = rat.originalData[:0]
+	if rat.engine == hashEngineMode {
+		for _, col := range rat.columns {
+			rawValue, err := r.GetRawColumnValueByIdx(col.columnIdx)
+			if err != nil {
+				return nil, fmt.Errorf("unable to get raw value by idx %d: %w", col.columnIdx, err)
+			}
+			if !rawValue.IsNull {
+				rat.originalData = append(rat.originalData, rawValue.Data...)
+			}
+		}
+	}
+
+	addr, err := rat.t.GetAddress(rat.originalData)
+	if err != nil {
+		return nil, fmt.Errorf("error generating address: %w", err)
+	}
+	return &RealAddressValue{
+		Address:    addr.Address,
+		City:       addr.City,
+		State:      addr.State,
+		PostalCode: addr.PostalCode,
+		Latitude:   addr.Latitude,
+		Longitude:  addr.Longitude,
+	}, nil
+}
+
 func getRealAddress() *RealAddressValue {
 	addr := faker.GetRealAddress()
 
@@ -17,6 +17,8 @@ package transformers
 import (
 	"context"
 	"encoding/json"
+	"os"
+	"path"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -111,3 +113,80 @@ func TestMakeNewFakeTransformerFunction_validation_error(t *testing.T) {
 	require.Len(t, warnings, 1)
 	require.Equal(t, "error validating template", warnings[0].Msg)
 }
+
+func TestRealAddressTransformer_Transform_locale(t *testing.T) {
+	columns := []*RealAddressColumn{
+		{
+			Name:     "data",
+			Template: "{{ .Address }}, {{ .PostalCode }} {{ .City }}",
+		},
+	}
+	rawData, err := json.Marshal(columns)
+	require.NoError(t, err)
+
+	var results []string
+	for i := 0; i < 2; i++ {
+		driver, record := getDriverAndRecord("data", "Musterstraße 1, 12345 Musterstadt")
+		transformer, warnings, err := RealAddressTransformerDefinition.Instance(
+			context.Background(),
+			driver,
+			map[string]toolkit.ParamsValue{
+				"columns": rawData,
+				"locale":  toolkit.ParamsValue("de_DE"),
+				"engine":  toolkit.ParamsValue("hash"),
+			},
+			nil,
+			"",
+		)
+		require.NoError(t, err)
+		require.Empty(t, warnings)
+
+		_, err = transformer.Transformer.Transform(context.Background(), record)
+		require.NoError(t, err)
+		rawValue, err := record.GetRawColumnValueByName("data")
+		require.NoError(t, err)
+		results = append(results, string(rawValue.Data))
+	}
+	require.Equal(t, results[0], results[1])
+	require.Regexp(t, `^\D+ \d{1,3}, \d{5} \D+$`, results[0])
+}
+
+func TestRealAddressTransformer_Transform_dictionary_file(t *testing.T) {
+	dictionaryFile := path.Join(t.TempDir(), "dictionary.json")
+	err := os.WriteFile(dictionaryFile, []byte(`{
+		"address": {
+			"formats": ["{{ .Street }} {{ .Number }}"],
+			"streets": ["Bahnhofstrasse"],
+			"cities": [{"city": "Zürich", "state": "ZH", "postal_code": "80##", "lat": 47.3769, "lng": 8.5417}]
+		}
+	}`), 0600)
+	require.NoError(t, err)
+
+	rawData, err := json.Marshal([]*RealAddressColumn{
+		{
+			Name:     "data",
+			Template: "{{ .Address }}, {{ .PostalCode }} {{ .City }} {{ .State }}",
+		},
+	})
+	require.NoError(t, err)
+
+	driver, record := getDriverAndRecord("data", "somaval")
+	transformer, warnings, err := RealAddressTransformerDefinition.Instance(
+		context.Background(),
+		driver,
+		map[string]toolkit.ParamsValue{
+			"columns":         rawData,
+			"dictionary_file": toolkit.ParamsValue(dictionaryFile),
+		},
+		nil,
+		"",
+	)
+	require.NoError(t, err)
+	require.Empty(t, warnings)
+
+	_, err = transformer.Transformer.Transform(context.Background(), record)
+	require.NoError(t, err)
+	rawValue, err := record.GetRawColumnValueByName("data")
+	require.NoError(t, err)
+	require.Regexp(t, `^Bahnhofstrasse \d{1,3}, 80\d{2} Zürich ZH$`, string(rawValue.Data))
+}
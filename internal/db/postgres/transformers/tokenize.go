@@ -0,0 +1,349 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transformers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
+	"github.com/greenmaskio/greenmask/internal/generators"
+	"github.com/greenmaskio/greenmask/internal/generators/transformers"
+	"github.com/greenmaskio/greenmask/internal/utils/vault"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+const (
+	TokenizeTransformerName = "Tokenize"
+	// TokenizeVaultEnvVariable - environment variable that contains the vault URI if it is not set in the config
+	TokenizeVaultEnvVariable = "GREENMASK_TOKEN_VAULT"
+	// TokenizeKeyEnvVariable - environment variable that contains the HMAC key if it is not set in the config
+	TokenizeKeyEnvVariable = "GREENMASK_TOKEN_KEY"
+)
+
+const (
+	tokenizeFormatString = "string"
+	tokenizeFormatDigits = "digits"
+	tokenizeFormatEmail  = "email"
+	tokenizeFormatUuid   = "uuid"
+)
+
+// tokenizeMaxAttempts - the number of the tokens generated for the value until the unique one is found
+const tokenizeMaxAttempts = 16
+
+const (
+	tokenizeStringChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	tokenizeEmailChars  = "abcdefghijklmnopqrstuvwxyz0123456789"
+	tokenizeDigitChars  = "0123456789"
+)
+
+var ErrUniqueTokenIsNotGenerated = errors.New("unique token is not generated")
+
+var TokenizeTransformerDefinition = utils.NewTransformerDefinition(
+	utils.NewTransformerProperties(
+		TokenizeTransformerName,
+		"Replace the value with the unique token stored in the persistent vault. The same value is replaced with "+
+			"the same token in all the dumps and databases that use the vault",
+	).AddMeta(AllowApplyForReferenced, true).
+		AddMeta(RequireHashEngineParameter, false),
+
+	NewTokenizeTransformer,
+
+	toolkit.MustNewParameterDefinition(
+		"column",
+		"column name",
+	).SetIsColumn(toolkit.NewColumnProperties().
+		SetAffected(true).
+		SetUnique(true).
+		SetAllowedColumnTypes("text", "varchar", "char", "bpchar", "citext"),
+	).SetRequired(true),
+
+	toolkit.MustNewParameterDefinition(
+		"vault",
+		"vault URI: postgresql connection string or path of the vault file. This value may be provided via "+
+			"environment variable "+TokenizeVaultEnvVariable,
+	).SetGetFromGlobalEnvVariable(TokenizeVaultEnvVariable),
+
+	toolkit.MustNewParameterDefinition(
+		"key",
+		"secret key of HMAC that identifies the original value in the vault. This value may be provided via "+
+			"environment variable "+TokenizeKeyEnvVariable,
+	).SetGetFromGlobalEnvVariable(TokenizeKeyEnvVariable),
+
+	toolkit.MustNewParameterDefinition(
+		"namespace",
+		"namespace of the tokens in the vault. The columns that use the same namespace share the tokens",
+	).SetDefaultValue(toolkit.ParamsValue("default")),
+
+	toolkit.MustNewParameterDefinition(
+		"format",
+		"format of the generated token. Possible values: string, digits, email, uuid",
+	).SetAllowedValues(
+		toolkit.ParamsValue(tokenizeFormatString),
+		toolkit.ParamsValue(tokenizeFormatDigits),
+		toolkit.ParamsValue(tokenizeFormatEmail),
+		toolkit.ParamsValue(tokenizeFormatUuid),
+	).SetDefaultValue(toolkit.ParamsValue(tokenizeFormatString)),
+
+	toolkit.MustNewParameterDefinition(
+		"length",
+		"length of the generated string or digits token or of the local part of the email",
+	).SetDefaultValue(toolkit.ParamsValue("16")).
+		SetRawValueValidator(validateTokenizeLengthParameter),
+
+	toolkit.MustNewParameterDefinition(
+		"domain",
+		"domain of the generated email",
+	).SetDefaultValue(toolkit.ParamsValue("example.com")),
+)
+
+type TokenizeTransformer struct {
+	columnName      string
+	affectedColumns map[int]string
+	columnIdx       int
+	vaultUri        string
+	vault           vault.Vault
+	namespace       string
+	mac             hash.Hash
+	generate        func(data []byte) (string, error)
+	input           []byte
+}
+
+func NewTokenizeTransformer(
+	ctx context.Context, driver *toolkit.Driver, parameters map[string]toolkit.Parameterizer,
+) (utils.Transformer, toolkit.ValidationWarnings, error) {
+	p := parameters["column"]
+	var columnName string
+	if err := p.Scan(&columnName); err != nil {
+		return nil, nil, fmt.Errorf("unable to parse column param: %w", err)
+	}
+
+	idx, _, ok := driver.GetColumnByName(columnName)
+	if !ok {
+		return nil, nil, fmt.Errorf("column with name %s is not found", columnName)
+	}
+	affectedColumns := make(map[int]string)
+	affectedColumns[idx] = columnName
+
+	var vaultUri, key, namespace, format, domain string
+	var length int
+	if err := parameters["vault"].Scan(&vaultUri); err != nil {
+		return nil, nil, fmt.Errorf("unable to scan \"vault\" parameter: %w", err)
+	}
+	if err := parameters["key"].Scan(&key); err != nil {
+		return nil, nil, fmt.Errorf("unable to scan \"key\" parameter: %w", err)
+	}
+	if err := parameters["namespace"].Scan(&namespace); err != nil {
+		return nil, nil, fmt.Errorf("unable to scan \"namespace\" parameter: %w", err)
+	}
+	if err := parameters["format"].Scan(&format); err != nil {
+		return nil, nil, fmt.Errorf("unable to scan \"format\" parameter: %w", err)
+	}
+	if err := parameters["length"].Scan(&length); err != nil {
+		return nil, nil, fmt.Errorf("unable to scan \"length\" parameter: %w", err)
+	}
+	if err := parameters["domain"].Scan(&domain); err != nil {
+		return nil, nil, fmt.Errorf("unable to scan \"domain\" parameter: %w", err)
+	}
+
+	var warnings toolkit.ValidationWarnings
+	if vaultUri == "" {
+		warnings = append(warnings, toolkit.NewValidationWarning().
+			SetSeverity(toolkit.ErrorValidationSeverity).
+			AddMeta("ParameterName", "vault").
+			SetMsg("vault is not set: set the parameter or environment variable "+TokenizeVaultEnvVariable),
+		)
+	}
+	if key == "" {
+		warnings = append(warnings, toolkit.NewValidationWarning().
+			SetSeverity(toolkit.ErrorValidationSeverity).
+			AddMeta("ParameterName", "key").
+			SetMsg("key is not set: set the parameter or environment variable "+TokenizeKeyEnvVariable),
+		)
+	}
+	if warnings.IsFatal() {
+		return nil, warnings, nil
+	}
+
+	generate, err := newTokenGenerator([]byte(key), format, length, domain)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &TokenizeTransformer{
+		columnName:      columnName,
+		affectedColumns: affectedColumns,
+		columnIdx:       idx,
+		vaultUri:        vaultUri,
+		namespace:       namespace,
+		mac:             hmac.New(sha256.New, []byte(key)),
+		generate:        generate,
+		input:           make([]byte, 0, sha256.Size+1),
+	}, nil, nil
+}
+
+// newTokenGenerator - returns the function that generates the token from the bytes sequence. The tokens are
+// generated by the hash engine salted with the key, so the token of the value does not depend on the order in which
+// the values are added to the vault
+func newTokenGenerator(key []byte, format string, length int, domain string) (func(data []byte) (string, error), error) {
+	if format == tokenizeFormatUuid {
+		t := transformers.NewRandomUuidTransformer()
+		g, err := generators.GetHashBytesGen(key, t.GetRequiredGeneratorByteLength())
+		if err != nil {
+			return nil, fmt.Errorf("cannot create generator: %w", err)
+		}
+		if err = t.SetGenerator(g); err != nil {
+			return nil, fmt.Errorf("cannot set generator: %w", err)
+		}
+		return func(data []byte) (string, error) {
+			res, err := t.Transform(data)
+			if err != nil {
+				return "", err
+			}
+			return res.String(), nil
+		}, nil
+	}
+
+	chars := tokenizeStringChars
+	suffix := ""
+	switch format {
+	case tokenizeFormatDigits:
+		chars = tokenizeDigitChars
+	case tokenizeFormatEmail:
+		chars = tokenizeEmailChars
+		suffix = "@" + domain
+	}
+	t, err := transformers.NewRandomStringTransformer([]rune(chars), length, length)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create string generator: %w", err)
+	}
+	g, err := generators.GetHashBytesGen(key, t.GetRequiredGeneratorByteLength())
+	if err != nil {
+		return nil, fmt.Errorf("cannot create generator: %w", err)
+	}
+	if err = t.SetGenerator(g); err != nil {
+		return nil, fmt.Errorf("cannot set generator: %w", err)
+	}
+	return func(data []byte) (string, error) {
+		return string(t.Transform(data)) + suffix, nil
+	}, nil
+}
+
+func (tt *TokenizeTransformer) GetAffectedColumns() map[int]string {
+	return tt.affectedColumns
+}
+
+func (tt *TokenizeTransformer) Init(ctx context.Context) error {
+	v, err := vault.Open(ctx, tt.vaultUri)
+	if err != nil {
+		return err
+	}
+	tt.vault = v
+	return nil
+}
+
+func (tt *TokenizeTransformer) Done(ctx context.Context) error {
+	if tt.vault == nil {
+		return nil
+	}
+	err := tt.vault.Flush(ctx)
+	if closeErr := tt.vault.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	tt.vault = nil
+	if err != nil {
+		return fmt.Errorf("cannot release vault: %w", err)
+	}
+	return nil
+}
+
+func (tt *TokenizeTransformer) Transform(ctx context.Context, r *toolkit.Record) (*toolkit.Record, error) {
+	val, err := r.GetRawColumnValueByIdx(tt.columnIdx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to scan attribute value: %w", err)
+	}
+	if val.IsNull {
+		return r, nil
+	}
+
+	token, err := tt.getToken(ctx, val.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = r.SetRawColumnValueByIdx(tt.columnIdx, toolkit.NewRawValue([]byte(token), false)); err != nil {
+		return nil, fmt.Errorf("unable to set new value: %w", err)
+	}
+	return r, nil
+}
+
+// getToken - returns the token of the value from the vault or generates the new one. If the generated token is
+// already used by another value the next attempt is made with the different generator input
+func (tt *TokenizeTransformer) getToken(ctx context.Context, value []byte) (string, error) {
+	tt.mac.Reset()
+	tt.mac.Write(value)
+	key := tt.mac.Sum(nil)
+
+	token, ok, err := tt.vault.Get(ctx, tt.namespace, key)
+	if err != nil {
+		return "", fmt.Errorf("cannot get token from vault: %w", err)
+	}
+	if ok {
+		return token, nil
+	}
+
+	for attempt := 0; attempt < tokenizeMaxAttempts; attempt++ {
+		tt.input = append(append(tt.input[:0], key...), byte(attempt))
+		token, err = tt.generate(tt.input)
+		if err != nil {
+			return "", fmt.Errorf("cannot generate token: %w", err)
+		}
+		token, err = tt.vault.Put(ctx, tt.namespace, key, token)
+		if errors.Is(err, vault.ErrTokenIsNotUnique) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("cannot put token into vault: %w", err)
+		}
+		return token, nil
+	}
+	return "", fmt.Errorf(
+		"%w after %d attempts: increase the token length", ErrUniqueTokenIsNotGenerated, tokenizeMaxAttempts,
+	)
+}
+
+func validateTokenizeLengthParameter(p *toolkit.ParameterDefinition, v toolkit.ParamsValue) (toolkit.ValidationWarnings, error) {
+	length, err := strconv.ParseInt(string(v), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing \"length\" as integer: %w", err)
+	}
+	if length > 0 {
+		return nil, nil
+	}
+	return toolkit.ValidationWarnings{
+		toolkit.NewValidationWarning().
+			SetSeverity(toolkit.ErrorValidationSeverity).
+			AddMeta("ParameterValue", string(v)).
+			SetMsg("length parameter must be greater than zero")}, nil
+}
+
+func init() {
+	utils.DefaultTransformerRegistry.MustRegister(TokenizeTransformerDefinition)
+}
@@ -0,0 +1,166 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transformers
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+func tokenizeValue(t *testing.T, params map[string]toolkit.ParamsValue, value string) (string, error) {
+	t.Helper()
+	ctx := context.Background()
+	driver, record := getDriverAndRecord("data", value)
+	transformer, warnings, err := TokenizeTransformerDefinition.Instance(ctx, driver, params, nil, "")
+	require.NoError(t, err)
+	require.Empty(t, warnings)
+	require.NoError(t, transformer.Transformer.Init(ctx))
+	defer func() {
+		require.NoError(t, transformer.Transformer.Done(ctx))
+	}()
+
+	r, err := transformer.Transformer.Transform(ctx, record)
+	if err != nil {
+		return "", err
+	}
+	res, err := r.GetRawColumnValueByName("data")
+	require.NoError(t, err)
+	if res.IsNull {
+		return "\\N", nil
+	}
+	return string(res.Data), nil
+}
+
+func TestTokenizeTransformer_Transform(t *testing.T) {
+	vaultPath := filepath.Join(t.TempDir(), "vault.jsonl")
+	tests := []struct {
+		name    string
+		params  map[string]toolkit.ParamsValue
+		pattern string
+	}{
+		{
+			name:    "string",
+			params:  map[string]toolkit.ParamsValue{},
+			pattern: `^[a-zA-Z0-9]{16}$`,
+		},
+		{
+			name: "digits",
+			params: map[string]toolkit.ParamsValue{
+				"format": toolkit.ParamsValue("digits"),
+				"length": toolkit.ParamsValue("10"),
+			},
+			pattern: `^\d{10}$`,
+		},
+		{
+			name: "email",
+			params: map[string]toolkit.ParamsValue{
+				"format":    toolkit.ParamsValue("email"),
+				"domain":    toolkit.ParamsValue("test.com"),
+				"namespace": toolkit.ParamsValue("email"),
+			},
+			pattern: `^[a-z0-9]{16}@test\.com$`,
+		},
+		{
+			name: "uuid",
+			params: map[string]toolkit.ParamsValue{
+				"format": toolkit.ParamsValue("uuid"),
+			},
+			pattern: `^[a-f0-9]{8}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{12}$`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.params["column"] = toolkit.ParamsValue("data")
+			tt.params["vault"] = toolkit.ParamsValue(vaultPath)
+			tt.params["key"] = toolkit.ParamsValue("secret")
+			tt.params["namespace"] = toolkit.ParamsValue(tt.name)
+
+			res, err := tokenizeValue(t, tt.params, "john@example.com")
+			require.NoError(t, err)
+			assert.Regexp(t, tt.pattern, res)
+
+			// The same value gets the same token from the vault
+			again, err := tokenizeValue(t, tt.params, "john@example.com")
+			require.NoError(t, err)
+			assert.Equal(t, res, again)
+
+			other, err := tokenizeValue(t, tt.params, "jane@example.com")
+			require.NoError(t, err)
+			assert.NotEqual(t, res, other)
+		})
+	}
+}
+
+func TestTokenizeTransformer_Transform_unique(t *testing.T) {
+	params := map[string]toolkit.ParamsValue{
+		"column": toolkit.ParamsValue("data"),
+		"vault":  toolkit.ParamsValue(filepath.Join(t.TempDir(), "vault.jsonl")),
+		"key":    toolkit.ParamsValue("secret"),
+		"format": toolkit.ParamsValue("digits"),
+		"length": toolkit.ParamsValue("1"),
+	}
+
+	// Only 10 tokens of 1 digit exist, so the tokens of 11 values cannot be unique
+	tokens := make(map[string]struct{})
+	var err error
+	for i := 0; i < 11; i++ {
+		var res string
+		res, err = tokenizeValue(t, params, fmt.Sprintf("value%d", i))
+		if err != nil {
+			break
+		}
+		assert.NotContains(t, tokens, res)
+		tokens[res] = struct{}{}
+	}
+	require.ErrorIs(t, err, ErrUniqueTokenIsNotGenerated)
+}
+
+func TestTokenizeTransformer_Transform_null(t *testing.T) {
+	res, err := tokenizeValue(t, map[string]toolkit.ParamsValue{
+		"column": toolkit.ParamsValue("data"),
+		"vault":  toolkit.ParamsValue(filepath.Join(t.TempDir(), "vault.jsonl")),
+		"key":    toolkit.ParamsValue("secret"),
+	}, "\\N")
+	require.NoError(t, err)
+	assert.Equal(t, "\\N", res)
+}
+
+func TestTokenizeTransformer_params_from_env(t *testing.T) {
+	t.Setenv(TokenizeVaultEnvVariable, filepath.Join(t.TempDir(), "vault.jsonl"))
+	t.Setenv(TokenizeKeyEnvVariable, "secret")
+	res, err := tokenizeValue(t, map[string]toolkit.ParamsValue{"column": toolkit.ParamsValue("data")}, "value")
+	require.NoError(t, err)
+	assert.Len(t, res, 16)
+}
+
+func TestTokenizeTransformer_params_not_set(t *testing.T) {
+	t.Setenv(TokenizeVaultEnvVariable, "")
+	t.Setenv(TokenizeKeyEnvVariable, "")
+	driver, _ := getDriverAndRecord("data", "value")
+	_, warnings, err := TokenizeTransformerDefinition.Instance(
+		context.Background(), driver, map[string]toolkit.ParamsValue{"column": toolkit.ParamsValue("data")}, nil, "",
+	)
+	require.NoError(t, err)
+	assert.True(t, warnings.IsFatal())
+	assert.Len(t, warnings, 2)
+}
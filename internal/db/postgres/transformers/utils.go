@@ -8,7 +8,9 @@ import (
 
 	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/utils"
 	"github.com/greenmaskio/greenmask/internal/generators"
+	"github.com/greenmaskio/greenmask/internal/generators/transformers"
 	commonutils "github.com/greenmaskio/greenmask/internal/utils"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
 )
 
 const (
@@ -16,15 +18,50 @@ const (
 	RequireHashEngineParameter utils.MetaKey = "RequireHashEngineParameter"
 )
 
+// getGenerateEngine - returns the generator of the engine. The generator is wrapped for the retries if the
+// transformer is created for the unique column
 func getGenerateEngine(ctx context.Context, engineName string, size int) (generators.Generator, error) {
+	var g generators.Generator
+	var err error
 	switch engineName {
 	case RandomEngineParameterName:
-		return getRandomBytesGen(size)
+		g, err = getRandomBytesGen(size)
 	case HashEngineParameterName:
 		salt := commonutils.SaltFromCtx(ctx)
-		return generators.GetHashBytesGen(salt, size)
+		g, err = generators.GetHashBytesGen(salt, size)
+	default:
+		return nil, fmt.Errorf("unknown engine %s", engineName)
 	}
-	return nil, fmt.Errorf("unknown engine %s", engineName)
+	if err != nil {
+		return nil, err
+	}
+	return utils.UniqueGenerator(ctx, g), nil
+}
+
+// getLocaleDataset - returns the dataset of the "locale" parameter overridden by the user dataset from the
+// "dictionary_file" parameter
+func getLocaleDataset(
+	parameters map[string]toolkit.Parameterizer,
+) (*transformers.LocaleDataset, toolkit.ValidationWarnings, error) {
+	var locale, dictionaryFile string
+	if err := parameters["locale"].Scan(&locale); err != nil {
+		return nil, nil, fmt.Errorf(`unable to scan "locale" param: %w`, err)
+	}
+	if err := parameters["dictionary_file"].Scan(&dictionaryFile); err != nil {
+		return nil, nil, fmt.Errorf(`unable to scan "dictionary_file" param: %w`, err)
+	}
+	ds, err := transformers.LoadLocaleDataset(locale, dictionaryFile)
+	if err != nil {
+		return nil, toolkit.ValidationWarnings{
+			toolkit.NewValidationWarning().
+				SetSeverity(toolkit.ErrorValidationSeverity).
+				SetMsg("cannot load locale dataset").
+				AddMeta("ParameterName", "dictionary_file").
+				AddMeta("ParameterValue", dictionaryFile).
+				AddMeta("Error", err.Error()),
+		}, nil
+	}
+	return ds, nil, nil
 }
 
 func getRandomBytesGen(size int) (generators.Generator, error) {
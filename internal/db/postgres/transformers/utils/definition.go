@@ -87,16 +87,42 @@ func (d *TransformerDefinition) Instance(
 		return nil, nil, fmt.Errorf("schema validation error: %w", err)
 	}
 
+	// The transformers of the unique columns are created in the unique mode, so their generators can be retried
+	// on the collision
+	uniqueColumns, referencedColumns := findUniqueColumns(ctx, driver, staticParams)
+	var um *uniqueMode
+	if len(uniqueColumns) > 0 || len(referencedColumns) > 0 {
+		um = &uniqueMode{}
+		ctx = context.WithValue(ctx, uniqueModeKey{}, um)
+	}
+
 	// Create a new transformer and receive warnings
 	t, transformerWarnings, err := d.New(ctx, driver, params)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	res := make(toolkit.ValidationWarnings, 0, len(parametersWarnings)+len(schemaWarnings)+len(transformerWarnings))
+	var uniqueWarnings toolkit.ValidationWarnings
+	if um != nil && t != nil {
+		uniqueWarnings = validateValueSpace(t, uniqueColumns, driver.Table.RowsEstimate)
+		if len(um.generators) > 0 {
+			uniqueWarnings = append(uniqueWarnings, newReferencedKeyWarnings(referencedColumns)...)
+		}
+		var wrapped bool
+		if t, wrapped = newUniqueTransformer(driver, t, um, uniqueColumns); wrapped {
+			schemaWarnings = filterUniqueConstraintWarnings(schemaWarnings, uniqueColumns)
+			uniqueWarnings = append(uniqueWarnings, newHashEngineWarnings(staticParams, uniqueColumns)...)
+		}
+	}
+
+	res := make(
+		toolkit.ValidationWarnings, 0,
+		len(parametersWarnings)+len(schemaWarnings)+len(transformerWarnings)+len(uniqueWarnings),
+	)
 	res = append(res, parametersWarnings...)
 	res = append(res, schemaWarnings...)
 	res = append(res, transformerWarnings...)
+	res = append(res, uniqueWarnings...)
 
 	meta := map[string]interface{}{
 		"TableSchema": driver.Table.Schema,
@@ -0,0 +1,382 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/greenmaskio/greenmask/internal/generators"
+	"github.com/greenmaskio/greenmask/internal/utils/bloom"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+const (
+	// uniqueMaxAttempts - the number of the transformation attempts until the unique value is generated
+	uniqueMaxAttempts = 32
+	// uniqueFilterMinCapacity - the initial capacity of the generated values filter if the table rows estimate is
+	// unknown or small
+	uniqueFilterMinCapacity = 1024
+	// uniqueFilterFpRate - the false positive rate of the generated values filter. The false positive causes only
+	// the extra transformation attempt
+	uniqueFilterFpRate = 0.001
+
+	engineParameterName = "engine"
+	hashEngineName      = "hash"
+)
+
+var ErrUniqueValueIsNotGenerated = errors.New("unique value is not generated")
+
+// ValueSpace - transformer that knows the number of the distinct values it is able to generate. It is used to check
+// the transformer is able to generate the unique value for each row of the table
+type ValueSpace interface {
+	// GetValueSpaceSize - returns the number of the distinct values. +Inf means the number is too large to be
+	// exhausted
+	GetValueSpaceSize() float64
+}
+
+type uniqueModeKey struct{}
+
+// uniqueMode - collects the generators of the transformer that is created for the unique column
+type uniqueMode struct {
+	generators []*generators.Retry
+}
+
+// UniqueGenerator - wraps the generator into generators.Retry if the transformer is created for the unique column.
+// The unique transformer changes the attempt of the generator when the generated value collides with the previous
+// ones. Otherwise, the generator is returned as is
+func UniqueGenerator(ctx context.Context, g generators.Generator) generators.Generator {
+	um, ok := ctx.Value(uniqueModeKey{}).(*uniqueMode)
+	if !ok {
+		return g
+	}
+	r := generators.NewRetry(g)
+	um.generators = append(um.generators, r)
+	return r
+}
+
+type referencedUniquesKey struct{}
+
+// WithReferencedUniqueConstraints - returns the context that carries the oids of the table unique constraints that
+// are referenced by the foreign keys. The transformers of such columns are not created in the unique mode
+func WithReferencedUniqueConstraints(ctx context.Context, oids []toolkit.Oid) context.Context {
+	if len(oids) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, referencedUniquesKey{}, oids)
+}
+
+// uniqueColumn - the affected column that has single column primary key or unique constraint
+type uniqueColumn struct {
+	idx    int
+	name   string
+	filter *bloom.Filter
+}
+
+// UniqueTransformer - transformer that guarantees the generated values of the unique columns do not collide. The
+// generated values are tracked by the scalable Bloom filter, so the memory is bounded by ~2 bytes per row. If the
+// value might be generated before, the transformation is repeated with the next generator attempt. The chunks of the
+// table are transformed in parallel by the same transformer, so the transformation is serialized
+type UniqueTransformer struct {
+	Transformer
+	columns    []*uniqueColumn
+	generators []*generators.Retry
+	originals  map[int]*toolkit.RawValue
+	mx         sync.Mutex
+}
+
+func (ut *UniqueTransformer) setAttempt(attempt uint32) {
+	for _, g := range ut.generators {
+		g.SetAttempt(attempt)
+	}
+}
+
+func (ut *UniqueTransformer) Transform(ctx context.Context, r *toolkit.Record) (*toolkit.Record, error) {
+	ut.mx.Lock()
+	defer ut.mx.Unlock()
+
+	// The original values of the affected columns are restored before each next attempt because the transformers
+	// might use them as the generator input
+	for idx := range ut.originals {
+		v, err := r.GetRawColumnValueByIdx(idx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get original value: %w", err)
+		}
+		ut.originals[idx] = toolkit.NewRawValue(slices.Clone(v.Data), v.IsNull)
+	}
+	defer ut.setAttempt(0)
+
+	for attempt := uint32(0); attempt < uniqueMaxAttempts; attempt++ {
+		if attempt > 0 {
+			for idx, v := range ut.originals {
+				if err := r.SetRawColumnValueByIdx(idx, toolkit.NewRawValue(slices.Clone(v.Data), v.IsNull)); err != nil {
+					return nil, fmt.Errorf("unable to restore original value: %w", err)
+				}
+			}
+		}
+		ut.setAttempt(attempt)
+
+		res, err := ut.Transformer.Transform(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		unique, err := ut.isUnique(res)
+		if err != nil {
+			return nil, err
+		}
+		if unique {
+			return res, ut.register(res)
+		}
+	}
+	names := make([]string, 0, len(ut.columns))
+	for _, c := range ut.columns {
+		names = append(names, c.name)
+	}
+	return nil, fmt.Errorf(
+		"%w for columns %v after %d attempts: the transformer value space might be exhausted",
+		ErrUniqueValueIsNotGenerated, names, uniqueMaxAttempts,
+	)
+}
+
+// isUnique - checks the values of the unique columns have not been generated before. NULL values do not violate
+// the unique constraint
+func (ut *UniqueTransformer) isUnique(r *toolkit.Record) (bool, error) {
+	for _, c := range ut.columns {
+		v, err := r.GetRawColumnValueByIdx(c.idx)
+		if err != nil {
+			return false, fmt.Errorf("unable to get transformed value: %w", err)
+		}
+		if !v.IsNull && c.filter.Contains(v.Data) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (ut *UniqueTransformer) register(r *toolkit.Record) error {
+	for _, c := range ut.columns {
+		v, err := r.GetRawColumnValueByIdx(c.idx)
+		if err != nil {
+			return fmt.Errorf("unable to get transformed value: %w", err)
+		}
+		if !v.IsNull {
+			c.filter.Add(v.Data)
+		}
+	}
+	return nil
+}
+
+// referencedColumn - the affected unique column that is referenced by the foreign keys
+type referencedColumn struct {
+	column         *toolkit.Column
+	constraintType string
+}
+
+// containerColumn - the column of the column container parameter. The containers are the list of the objects with
+// the column name
+type containerColumn struct {
+	Name        string `json:"name"`
+	NotAffected bool   `json:"not_affected"`
+}
+
+// findAffectedColumns - returns the affected columns of the column parameter or column container parameter
+func findAffectedColumns(driver *toolkit.Driver, p *toolkit.StaticParameter) []*toolkit.Column {
+	def := p.GetDefinition()
+	switch {
+	case def.IsColumn:
+		if def.ColumnProperties == nil || !def.ColumnProperties.Affected || def.ColumnProperties.Unique ||
+			p.Column == nil {
+			return nil
+		}
+		return []*toolkit.Column{p.Column}
+	case def.IsColumnContainer:
+		rawValue, err := p.RawValue()
+		if err != nil || len(rawValue) == 0 {
+			return nil
+		}
+		var containerColumns []*containerColumn
+		// The containers with another structure do not provide the column names
+		if err = json.Unmarshal(rawValue, &containerColumns); err != nil {
+			return nil
+		}
+		var res []*toolkit.Column
+		for _, cc := range containerColumns {
+			if cc == nil || cc.NotAffected {
+				continue
+			}
+			if _, c, ok := driver.GetColumnByName(cc.Name); ok {
+				res = append(res, c)
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+// findUniqueColumns - returns the affected columns that have single column primary key or unique constraint. The
+// columns of the column containers are checked as well. The keys that are referenced by the foreign keys are
+// returned separately because the retried values would not match the values of the foreign keys transformed with
+// the same transformer
+func findUniqueColumns(
+	ctx context.Context, driver *toolkit.Driver, parameters map[string]*toolkit.StaticParameter,
+) (unique []*toolkit.Column, referenced []*referencedColumn) {
+	referencedUniques, _ := ctx.Value(referencedUniquesKey{}).([]toolkit.Oid)
+	for _, p := range parameters {
+		for _, column := range findAffectedColumns(driver, p) {
+			for _, c := range driver.Table.Constraints {
+				var columns []toolkit.AttNum
+				var isReferenced bool
+				switch v := c.(type) {
+				case *toolkit.PrimaryKey:
+					columns = v.Columns
+					isReferenced = len(v.References) > 0
+				case *toolkit.Unique:
+					columns = v.Columns
+					isReferenced = slices.Contains(referencedUniques, v.Oid)
+				default:
+					continue
+				}
+				if !slices.Equal(columns, []toolkit.AttNum{column.Num}) {
+					continue
+				}
+				if isReferenced {
+					referenced = append(referenced, &referencedColumn{column: column, constraintType: c.Type()})
+				} else {
+					unique = append(unique, column)
+				}
+				break
+			}
+		}
+	}
+	return unique, referenced
+}
+
+// newReferencedKeyWarnings - returns the warnings about the referenced keys that are not transformed in the unique
+// mode
+func newReferencedKeyWarnings(columns []*referencedColumn) toolkit.ValidationWarnings {
+	var warnings toolkit.ValidationWarnings
+	for _, c := range columns {
+		warnings = append(warnings, toolkit.NewValidationWarning().
+			SetSeverity(toolkit.InfoValidationSeverity).
+			AddMeta("ColumnName", c.column.Name).
+			AddMeta("ConstraintType", c.constraintType).
+			SetMsg("unique mode is disabled: key is referenced by foreign keys"),
+		)
+	}
+	return warnings
+}
+
+// newHashEngineWarnings - returns the warnings about the hash engine transformer created in the unique mode. The
+// retried value depends on the values transformed before it, so it is neither stable between the runs with the
+// parallel chunks nor equal to the value of the same transformer in another table
+func newHashEngineWarnings(
+	parameters map[string]*toolkit.StaticParameter, columns []*toolkit.Column,
+) toolkit.ValidationWarnings {
+	p, ok := parameters[engineParameterName]
+	if !ok {
+		return nil
+	}
+	engine, err := p.RawValue()
+	if err != nil || string(engine) != hashEngineName {
+		return nil
+	}
+	var warnings toolkit.ValidationWarnings
+	for _, c := range columns {
+		warnings = append(warnings, toolkit.NewValidationWarning().
+			SetSeverity(toolkit.WarningValidationSeverity).
+			AddMeta("ColumnName", c.Name).
+			AddMeta("Engine", hashEngineName).
+			SetMsg("hash engine is used in unique mode: collided values are retried and depend on the rows order"),
+		)
+	}
+	return warnings
+}
+
+// newUniqueTransformer - wraps the transformer created in the unique mode. The transformer is not wrapped if it
+// does not use the generators, because the next attempt would produce the same value
+func newUniqueTransformer(
+	driver *toolkit.Driver, t Transformer, um *uniqueMode, columns []*toolkit.Column,
+) (Transformer, bool) {
+	if len(um.generators) == 0 || len(columns) == 0 {
+		return t, false
+	}
+	capacity := uint64(uniqueFilterMinCapacity)
+	if driver.Table.RowsEstimate > int64(capacity) {
+		capacity = uint64(driver.Table.RowsEstimate)
+	}
+	ut := &UniqueTransformer{
+		Transformer: t,
+		generators:  um.generators,
+		originals:   make(map[int]*toolkit.RawValue),
+	}
+	for idx := range t.GetAffectedColumns() {
+		ut.originals[idx] = nil
+	}
+	for _, c := range columns {
+		idx, _, ok := driver.GetColumnByName(c.Name)
+		if !ok {
+			continue
+		}
+		ut.columns = append(ut.columns, &uniqueColumn{
+			idx:    idx,
+			name:   c.Name,
+			filter: bloom.NewFilter(capacity, uniqueFilterFpRate),
+		})
+	}
+	return ut, true
+}
+
+// validateValueSpace - checks the transformer is able to generate the unique value for each row of the table
+func validateValueSpace(t Transformer, columns []*toolkit.Column, rowsEstimate int64) toolkit.ValidationWarnings {
+	vs, ok := t.(ValueSpace)
+	if !ok || rowsEstimate <= 0 {
+		return nil
+	}
+	size := vs.GetValueSpaceSize()
+	if size >= float64(rowsEstimate) {
+		return nil
+	}
+	var warnings toolkit.ValidationWarnings
+	for _, c := range columns {
+		warnings = append(warnings, toolkit.NewValidationWarning().
+			SetSeverity(toolkit.WarningValidationSeverity).
+			AddMeta("ColumnName", c.Name).
+			AddMeta("ValueSpaceSize", size).
+			AddMeta("RowsEstimate", rowsEstimate).
+			SetMsg("transformer value space is too small for the unique column: unique values cannot be generated for all rows"),
+		)
+	}
+	return warnings
+}
+
+// filterUniqueConstraintWarnings - removes the possible unique constraint violation warnings of the columns that
+// are transformed in the unique mode
+func filterUniqueConstraintWarnings(
+	warnings toolkit.ValidationWarnings, columns []*toolkit.Column,
+) toolkit.ValidationWarnings {
+	return slices.DeleteFunc(warnings, func(w *toolkit.ValidationWarning) bool {
+		ct := w.Meta["ConstraintType"]
+		if ct != toolkit.PkConstraintType && ct != toolkit.UniqueConstraintType {
+			return false
+		}
+		return slices.ContainsFunc(columns, func(c *toolkit.Column) bool {
+			return w.Meta["ColumnName"] == c.Name
+		})
+	})
+}
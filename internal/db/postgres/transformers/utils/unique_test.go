@@ -0,0 +1,243 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/db/postgres/pgcopy"
+	"github.com/greenmaskio/greenmask/internal/generators"
+	"github.com/greenmaskio/greenmask/pkg/toolkit"
+)
+
+// testUniqueTransformer - generates the number in the range [0, size) using the hash engine
+type testUniqueTransformer struct {
+	idx  int
+	size uint64
+	g    generators.Generator
+}
+
+func newTestUniqueTransformer(ctx context.Context, driver *toolkit.Driver, parameters map[string]toolkit.Parameterizer) (
+	Transformer, toolkit.ValidationWarnings, error,
+) {
+	var size uint64
+	if err := parameters["size"].Scan(&size); err != nil {
+		return nil, nil, err
+	}
+	g, err := generators.GetHashBytesGen([]byte("salt"), 8)
+	if err != nil {
+		return nil, nil, err
+	}
+	idx, _, _ := driver.GetColumnByName("data")
+	return &testUniqueTransformer{idx: idx, size: size, g: UniqueGenerator(ctx, g)}, nil, nil
+}
+
+func (tt *testUniqueTransformer) Init(ctx context.Context) error {
+	return nil
+}
+
+func (tt *testUniqueTransformer) Done(ctx context.Context) error {
+	return nil
+}
+
+func (tt *testUniqueTransformer) Transform(ctx context.Context, r *toolkit.Record) (*toolkit.Record, error) {
+	v, err := r.GetRawColumnValueByIdx(tt.idx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := tt.g.Generate(v.Data)
+	if err != nil {
+		return nil, err
+	}
+	n := binary.LittleEndian.Uint64(res) % tt.size
+	if err = r.SetRawColumnValueByIdx(tt.idx, toolkit.NewRawValue([]byte(strconv.FormatUint(n, 10)), false)); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (tt *testUniqueTransformer) GetAffectedColumns() map[int]string {
+	return map[int]string{tt.idx: "data"}
+}
+
+func (tt *testUniqueTransformer) GetValueSpaceSize() float64 {
+	return float64(tt.size)
+}
+
+var testUniqueTransformerDefinition = NewTransformerDefinition(
+	NewTransformerProperties("TestUnique", "test unique transformer"),
+	newTestUniqueTransformer,
+	toolkit.MustNewParameterDefinition("column", "column name").
+		SetIsColumn(toolkit.NewColumnProperties().SetAffected(true)).
+		SetRequired(true),
+	toolkit.MustNewParameterDefinition("size", "value space size").
+		SetRequired(true),
+	toolkit.MustNewParameterDefinition("engine", "engine name").
+		SetDefaultValue([]byte("random")),
+)
+
+func newTestUniqueDriver(t *testing.T, rowsEstimate int64, constraints ...toolkit.Constraint) *toolkit.Driver {
+	table := &toolkit.Table{
+		Schema: "public",
+		Name:   "test",
+		Oid:    1224,
+		Columns: []*toolkit.Column{
+			{
+				Name:     "data",
+				TypeName: "text",
+				TypeOid:  pgtype.TextOID,
+				Num:      1,
+				Length:   -1,
+			},
+		},
+		RowsEstimate: rowsEstimate,
+		Constraints:  constraints,
+	}
+	driver, _, err := toolkit.NewDriver(table, nil)
+	require.NoError(t, err)
+	return driver
+}
+
+func transformTestUniqueValues(t *testing.T, tc *TransformerContext, driver *toolkit.Driver, count int) ([]string, error) {
+	var res []string
+	for i := 0; i < count; i++ {
+		row := pgcopy.NewRow(1)
+		require.NoError(t, row.Decode([]byte(fmt.Sprintf("value%d", i))))
+		r := toolkit.NewRecord(driver)
+		r.SetRow(row)
+		r, err := tc.Transformer.Transform(context.Background(), r)
+		if err != nil {
+			return res, err
+		}
+		v, err := r.GetRawColumnValueByName("data")
+		require.NoError(t, err)
+		res = append(res, string(v.Data))
+	}
+	return res, nil
+}
+
+func TestUniqueTransformer(t *testing.T) {
+	driver := newTestUniqueDriver(t, 0, toolkit.NewUnique("public", "test_data_key", "", 1, []toolkit.AttNum{1}))
+	tc, warnings, err := testUniqueTransformerDefinition.Instance(
+		context.Background(), driver,
+		map[string]toolkit.ParamsValue{"column": []byte("data"), "size": []byte("20")}, nil, "",
+	)
+	require.NoError(t, err)
+	// The possible unique constraint violation warning is removed
+	assert.Empty(t, warnings)
+	require.IsType(t, &UniqueTransformer{}, tc.Transformer)
+
+	values, err := transformTestUniqueValues(t, tc, driver, 20)
+	require.NoError(t, err)
+	seen := make(map[string]struct{})
+	for _, v := range values {
+		require.NotContains(t, seen, v)
+		seen[v] = struct{}{}
+	}
+
+	// The value space is exhausted
+	_, err = transformTestUniqueValues(t, tc, driver, 1)
+	require.ErrorIs(t, err, ErrUniqueValueIsNotGenerated)
+}
+
+func TestUniqueTransformer_deterministic(t *testing.T) {
+	driver := newTestUniqueDriver(t, 0, toolkit.NewPrimaryKey("public", "test_pkey", "", 1, []toolkit.AttNum{1}))
+	params := map[string]toolkit.ParamsValue{"column": []byte("data"), "size": []byte("1000")}
+
+	tc1, _, err := testUniqueTransformerDefinition.Instance(context.Background(), driver, params, nil, "")
+	require.NoError(t, err)
+	first, err := transformTestUniqueValues(t, tc1, driver, 100)
+	require.NoError(t, err)
+
+	tc2, _, err := testUniqueTransformerDefinition.Instance(context.Background(), driver, params, nil, "")
+	require.NoError(t, err)
+	second, err := transformTestUniqueValues(t, tc2, driver, 100)
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+}
+
+func TestUniqueTransformer_not_unique_column(t *testing.T) {
+	driver := newTestUniqueDriver(t, 0)
+	tc, _, err := testUniqueTransformerDefinition.Instance(
+		context.Background(), driver,
+		map[string]toolkit.ParamsValue{"column": []byte("data"), "size": []byte("20")}, nil, "",
+	)
+	require.NoError(t, err)
+	assert.IsType(t, &testUniqueTransformer{}, tc.Transformer)
+}
+
+func TestUniqueTransformer_referenced_pk(t *testing.T) {
+	pk := toolkit.NewPrimaryKey("public", "test_pkey", "", 1, []toolkit.AttNum{1})
+	pk.References = []*toolkit.LinkedTable{{Schema: "public", Name: "orders", Oid: 1225}}
+	driver := newTestUniqueDriver(t, 0, pk)
+	tc, warnings, err := testUniqueTransformerDefinition.Instance(
+		context.Background(), driver,
+		map[string]toolkit.ParamsValue{"column": []byte("data"), "size": []byte("20")}, nil, "",
+	)
+	require.NoError(t, err)
+	assert.IsType(t, &testUniqueTransformer{}, tc.Transformer)
+	require.NotEmpty(t, warnings)
+	assert.Equal(t, "unique mode is disabled: key is referenced by foreign keys", warnings[len(warnings)-1].Msg)
+	assert.Equal(t, toolkit.PkConstraintType, warnings[len(warnings)-1].Meta["ConstraintType"])
+}
+
+func TestUniqueTransformer_referenced_unique(t *testing.T) {
+	u := toolkit.NewUnique("public", "test_data_key", "", 1, []toolkit.AttNum{1})
+	driver := newTestUniqueDriver(t, 0, u)
+	tc, warnings, err := testUniqueTransformerDefinition.Instance(
+		WithReferencedUniqueConstraints(context.Background(), []toolkit.Oid{u.Oid}), driver,
+		map[string]toolkit.ParamsValue{"column": []byte("data"), "size": []byte("20")}, nil, "",
+	)
+	require.NoError(t, err)
+	assert.IsType(t, &testUniqueTransformer{}, tc.Transformer)
+	require.NotEmpty(t, warnings)
+	assert.Equal(t, "unique mode is disabled: key is referenced by foreign keys", warnings[len(warnings)-1].Msg)
+	assert.Equal(t, toolkit.UniqueConstraintType, warnings[len(warnings)-1].Meta["ConstraintType"])
+}
+
+func TestUniqueTransformer_hash_engine_warning(t *testing.T) {
+	driver := newTestUniqueDriver(t, 0, toolkit.NewUnique("public", "test_data_key", "", 1, []toolkit.AttNum{1}))
+	tc, warnings, err := testUniqueTransformerDefinition.Instance(
+		context.Background(), driver,
+		map[string]toolkit.ParamsValue{"column": []byte("data"), "size": []byte("20"), "engine": []byte("hash")},
+		nil, "",
+	)
+	require.NoError(t, err)
+	require.IsType(t, &UniqueTransformer{}, tc.Transformer)
+	require.Len(t, warnings, 1)
+	assert.Equal(t, toolkit.WarningValidationSeverity, warnings[0].Severity)
+	assert.Equal(t, "data", warnings[0].Meta["ColumnName"])
+}
+
+func TestUniqueTransformer_value_space_warning(t *testing.T) {
+	driver := newTestUniqueDriver(t, 100, toolkit.NewUnique("public", "test_data_key", "", 1, []toolkit.AttNum{1}))
+	_, warnings, err := testUniqueTransformerDefinition.Instance(
+		context.Background(), driver,
+		map[string]toolkit.ParamsValue{"column": []byte("data"), "size": []byte("20")}, nil, "",
+	)
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+	assert.Equal(t, toolkit.WarningValidationSeverity, warnings[0].Severity)
+	assert.Equal(t, float64(20), warnings[0].Meta["ValueSpaceSize"])
+	assert.Equal(t, int64(100), warnings[0].Meta["RowsEstimate"])
+}
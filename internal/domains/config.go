@@ -21,8 +21,12 @@ import (
 	"github.com/greenmaskio/greenmask/internal/db/postgres/pgdump"
 	"github.com/greenmaskio/greenmask/internal/db/postgres/pgrestore"
 	"github.com/greenmaskio/greenmask/internal/db/postgres/transformers/custom"
+	"github.com/greenmaskio/greenmask/internal/storages/azure"
 	"github.com/greenmaskio/greenmask/internal/storages/directory"
+	"github.com/greenmaskio/greenmask/internal/storages/encrypted"
+	"github.com/greenmaskio/greenmask/internal/storages/gcs"
 	"github.com/greenmaskio/greenmask/internal/storages/s3"
+	"github.com/greenmaskio/greenmask/internal/storages/sftp"
 	"github.com/greenmaskio/greenmask/pkg/toolkit"
 )
 
@@ -44,9 +48,13 @@ func NewConfig() *Config {
 					TempDirectory: defaultDirectoryStoragePath,
 				},
 				Storage: StorageConfig{
-					Type:      defaultStorageType,
-					S3:        s3.NewConfig(),
-					Directory: directory.NewConfig(),
+					Type:       defaultStorageType,
+					S3:         s3.NewConfig(),
+					Gcs:        gcs.NewConfig(),
+					Azure:      azure.NewConfig(),
+					Sftp:       sftp.NewConfig(),
+					Encryption: encrypted.NewConfig(),
+					Directory:  directory.NewConfig(),
 				},
 			}
 		},
@@ -60,7 +68,10 @@ type Config struct {
 	Storage            StorageConfig                   `mapstructure:"storage" yaml:"storage" json:"storage"`
 	Dump               Dump                            `mapstructure:"dump" yaml:"dump" json:"dump"`
 	Validate           Validate                        `mapstructure:"validate" yaml:"validate" json:"validate"`
+	Scan               Scan                            `mapstructure:"scan" yaml:"scan" json:"scan"`
+	Coverage           Coverage                        `mapstructure:"coverage" yaml:"coverage" json:"coverage"`
 	Restore            Restore                         `mapstructure:"restore" yaml:"restore" json:"restore"`
+	Export             Export                          `mapstructure:"export" yaml:"export" json:"export"`
 	CustomTransformers []*custom.TransformerDefinition `mapstructure:"custom_transformers" yaml:"custom_transformers" json:"custom_transformers,omitempty"`
 }
 
@@ -77,6 +88,43 @@ type Validate struct {
 	Warnings         bool     `mapstructure:"warnings" yaml:"warnings" json:"warnings,omitempty"`
 }
 
+type Scan struct {
+	Tables        []string `mapstructure:"tables" yaml:"tables" json:"tables,omitempty"`
+	RowsLimit     uint64   `mapstructure:"rows_limit" yaml:"rows_limit" json:"rows_limit,omitempty"`
+	MinConfidence float64  `mapstructure:"min_confidence" yaml:"min_confidence" json:"min_confidence,omitempty"`
+	Output        string   `mapstructure:"output" yaml:"output" json:"output,omitempty"`
+}
+
+type Coverage struct {
+	Format string `mapstructure:"format" yaml:"format" json:"format,omitempty"`
+	// Sensitive - the columns that must be transformed
+	Sensitive []*ColumnSelector `mapstructure:"sensitive" yaml:"sensitive" json:"sensitive,omitempty"`
+	// Allowed - the columns that may be left untransformed even if they are matched by the sensitive selectors
+	Allowed []*ColumnSelector `mapstructure:"allowed" yaml:"allowed" json:"allowed,omitempty"`
+}
+
+// ColumnSelector - selects the columns by conditions. All the provided conditions must be matched
+type ColumnSelector struct {
+	// Schema - regular expression for the schema name
+	Schema string `mapstructure:"schema" yaml:"schema" json:"schema,omitempty"`
+	// Table - regular expression for the table name
+	Table string `mapstructure:"table" yaml:"table" json:"table,omitempty"`
+	// Column - regular expression for the column name
+	Column string `mapstructure:"column" yaml:"column" json:"column,omitempty"`
+	// Types - list of the column type names
+	Types []string `mapstructure:"types" yaml:"types" json:"types,omitempty"`
+	// Comment - regular expression for the column comment (COMMENT ON COLUMN)
+	Comment string `mapstructure:"comment" yaml:"comment" json:"comment,omitempty"`
+}
+
+// Export - the settings of the export command that writes the transformed tables in the data lake formats
+type Export struct {
+	// Format - the format of the exported table files: parquet, csv or jsonl
+	Format string `mapstructure:"format" yaml:"format" json:"format,omitempty"`
+	// Storage - the storage of the exported files. The main storage is used if it is not set
+	Storage *StorageConfig `mapstructure:"storage" yaml:"storage" json:"storage,omitempty"`
+}
+
 type Common struct {
 	PgBinPath     string `mapstructure:"pg_bin_path" yaml:"pg_bin_path,omitempty" json:"pg_bin_path,omitempty"`
 	TempDirectory string `mapstructure:"tmp_dir" yaml:"tmp_dir,omitempty" json:"tmp_dir,omitempty"`
@@ -85,7 +133,12 @@ type Common struct {
 type StorageConfig struct {
 	Type      string            `mapstructure:"type" yaml:"type" json:"type,omitempty"`
 	S3        *s3.Config        `mapstructure:"s3"  json:"s3,omitempty" yaml:"s3"`
+	Gcs       *gcs.Config       `mapstructure:"gcs" json:"gcs,omitempty" yaml:"gcs"`
+	Azure     *azure.Config     `mapstructure:"azure" json:"azure,omitempty" yaml:"azure"`
+	Sftp      *sftp.Config      `mapstructure:"sftp" json:"sftp,omitempty" yaml:"sftp"`
 	Directory *directory.Config `mapstructure:"directory" json:"directory,omitempty" yaml:"directory"`
+	// Encryption - client side encryption of the objects. It is applied on top of any storage type
+	Encryption *encrypted.Config `mapstructure:"encryption" json:"encryption,omitempty" yaml:"encryption"`
 }
 
 type LogConfig struct {
@@ -98,12 +151,64 @@ type Dump struct {
 	AutoAnonymize     bool                `mapstructure:"auto_anonymize" yaml:"auto_anonymize" json:"auto_anonymize,omitempty"`
 	Transformation    []*Table            `mapstructure:"transformation" yaml:"transformation" json:"transformation,omitempty"`
 	VirtualReferences []*VirtualReference `mapstructure:"virtual_references" yaml:"virtual_references" json:"virtual_references,omitempty"`
+	// AutoAnonymizeRules - rules that select the transformer for the columns in auto anonymize mode. The first
+	// matched rule is applied. If none of the rules is matched, the transformer is selected by the column type
+	AutoAnonymizeRules []*AutoAnonymizeRule `mapstructure:"auto_anonymize_rules" yaml:"auto_anonymize_rules" json:"auto_anonymize_rules,omitempty"`
+	// RequireCoverage - fail the dump if any sensitive column is not transformed. The sensitive columns are defined
+	// in the coverage section
+	RequireCoverage bool `mapstructure:"require_coverage" yaml:"require_coverage" json:"require_coverage,omitempty"`
+	// SchemaDriftPolicy - the action on the schema changes since the latest dump. One of ignore, warn, fail and
+	// auto_anonymize
+	SchemaDriftPolicy string `mapstructure:"schema_drift_policy" yaml:"schema_drift_policy" json:"schema_drift_policy,omitempty"`
+	// OutputFormat - the layout of the dump in the storage: directory (default) - the directory of the objects, tar -
+	// the single tar object <dumpId>.tar
+	OutputFormat string `mapstructure:"output_format" yaml:"output_format" json:"output_format,omitempty"`
+}
+
+// AutoAnonymizeRule - the rule of the transformer selection in auto anonymize mode. All the provided conditions must
+// be matched
+type AutoAnonymizeRule struct {
+	// Schema - regular expression for the schema name
+	Schema string `mapstructure:"schema" yaml:"schema" json:"schema,omitempty"`
+	// Table - regular expression for the table name
+	Table string `mapstructure:"table" yaml:"table" json:"table,omitempty"`
+	// Column - regular expression for the column name
+	Column string `mapstructure:"column" yaml:"column" json:"column,omitempty"`
+	// Types - list of the column type names
+	Types []string `mapstructure:"types" yaml:"types" json:"types,omitempty"`
+	// Comment - regular expression for the column comment (COMMENT ON COLUMN)
+	Comment     string             `mapstructure:"comment" yaml:"comment" json:"comment,omitempty"`
+	Transformer *TransformerConfig `mapstructure:"transformer" yaml:"transformer" json:"transformer,omitempty"`
 }
 
 type Restore struct {
 	PgRestoreOptions pgrestore.Options               `mapstructure:"pg_restore_options" yaml:"pg_restore_options" json:"pg_restore_options"`
 	Scripts          map[string][]pgrestore.Script   `mapstructure:"scripts" yaml:"scripts" json:"scripts,omitempty"`
 	ErrorExclusions  *DataRestorationErrorExclusions `mapstructure:"insert_error_exclusions" yaml:"insert_error_exclusions" json:"insert_error_exclusions,omitempty"`
+	// VerifyChecksums - verification of the dump objects checksums: before - all the objects are verified before the
+	// restoration, during - each object is verified while it is being restored, both - before and during. The
+	// verification is disabled if it is empty
+	VerifyChecksums string `mapstructure:"verify_checksums" yaml:"verify_checksums" json:"verify_checksums,omitempty"`
+	// Merge - the tables that are restored with the upsert semantic. The existing rows are updated instead of the
+	// unique constraint violation, so the data can be refreshed in the running database without truncating it
+	Merge []*TableMerge `mapstructure:"merge" yaml:"merge" json:"merge,omitempty"`
+	// Transformation - the transformers that are applied to the dumped data while it is being restored. It uses the
+	// table definitions stored in the dump, so the source database is not required
+	Transformation []*Table `mapstructure:"transformation" yaml:"transformation" json:"transformation,omitempty"`
+}
+
+// TableMerge - the merge restoration settings of the table
+type TableMerge struct {
+	Schema string `mapstructure:"schema" yaml:"schema" json:"schema,omitempty"`
+	Name   string `mapstructure:"name" yaml:"name" json:"name"`
+	// ConflictColumns - the columns of the unique constraint that identifies the row. The primary key is used if empty
+	ConflictColumns []string `mapstructure:"conflict_columns" yaml:"conflict_columns" json:"conflict_columns,omitempty"`
+	// UpdateColumns - the columns that are updated if the row exists. All the columns except the conflict columns are
+	// updated if empty
+	UpdateColumns []string `mapstructure:"update_columns" yaml:"update_columns" json:"update_columns,omitempty"`
+	// Method - the statement that merges the COPY format data from the temporary table: insert - INSERT ... ON
+	// CONFLICT DO UPDATE (default), merge - MERGE (PostgreSQL 15+). It is ignored for the insert format
+	Method string `mapstructure:"method" yaml:"method" json:"method,omitempty"`
 }
 
 type TablesDataRestorationErrorExclusions struct {
@@ -165,6 +270,14 @@ type Table struct {
 	ColumnsTypeOverride map[string]string    `mapstructure:"columns_type_override" yaml:"columns_type_override" json:"columns_type_override,omitempty"`
 	SubsetConds         []string             `mapstructure:"subset_conds" yaml:"subset_conds" json:"subset_conds,omitempty"`
 	When                string               `mapstructure:"when" yaml:"when" json:"when,omitempty"`
+	// WatermarkColumn - column that is used as the change indicator of the table in incremental dumps. For instance
+	// updated_at column
+	WatermarkColumn string `mapstructure:"watermark_column" yaml:"watermark_column" json:"watermark_column,omitempty"`
+	// Chunks - number of the chunks the table data is split into. The chunks are dumped and restored in parallel
+	Chunks int `mapstructure:"chunks" yaml:"chunks" json:"chunks,omitempty"`
+	// ChunkBy - the table splitting method: pk or ctid. By default, the single-column integer primary key is used if
+	// the table has it, otherwise ctid
+	ChunkBy string `mapstructure:"chunk_by" yaml:"chunk_by" json:"chunk_by,omitempty"`
 }
 
 // DummyConfig - This is a dummy config to the viper workaround
@@ -177,5 +290,10 @@ type DummyConfig struct {
 				Params map[string]interface{} `yaml:"params" json:"params"`
 			} `yaml:"transformers" json:"transformers"`
 		} `yaml:"transformation" json:"transformation"`
+		AutoAnonymizeRules []struct {
+			Transformer struct {
+				Params map[string]interface{} `yaml:"params" json:"params"`
+			} `yaml:"transformer" json:"transformer"`
+		} `yaml:"auto_anonymize_rules" json:"auto_anonymize_rules"`
 	} `yaml:"dump" json:"dump"`
 }
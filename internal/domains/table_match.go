@@ -0,0 +1,43 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domains
+
+import "strings"
+
+// IsTableMatch - checks the configured schema and table name refer to the table. Both the configured names and the
+// table names might be quoted identifiers, so they are compared without the quotes. It is used by dump, restore,
+// transform and decrypt-column, so the table config is resolved in the same way by each of them
+func IsTableMatch(cfgSchema, cfgName, schema, name string) bool {
+	return unquoteIdent(cfgName) == unquoteIdent(name) && unquoteIdent(cfgSchema) == unquoteIdent(schema)
+}
+
+// FindTable - returns the table config of the table from the list or nil if the table is not configured
+func FindTable(cfgs []*Table, schema, name string) *Table {
+	for _, cfg := range cfgs {
+		if IsTableMatch(cfg.Schema, cfg.Name, schema, name) {
+			return cfg
+		}
+	}
+	return nil
+}
+
+// unquoteIdent - returns the identifier without the surrounding double quotes. The escaped quotes inside the quoted
+// identifier are unescaped
+func unquoteIdent(ident string) string {
+	if len(ident) < 2 || ident[0] != '"' || ident[len(ident)-1] != '"' {
+		return ident
+	}
+	return strings.ReplaceAll(ident[1:len(ident)-1], `""`, `"`)
+}
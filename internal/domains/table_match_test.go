@@ -0,0 +1,57 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package domains
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTableMatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfgSchema string
+		cfgName   string
+		schema    string
+		table     string
+		expected  bool
+	}{
+		{name: "unquoted", cfgSchema: "public", cfgName: "users", schema: "public", table: "users", expected: true},
+		{name: "quoted table", cfgSchema: "public", cfgName: "Users", schema: `"public"`, table: `"Users"`, expected: true},
+		{name: "quoted config", cfgSchema: `"public"`, cfgName: `"Users"`, schema: "public", table: "Users", expected: true},
+		{name: "both quoted", cfgSchema: `"public"`, cfgName: `"Users"`, schema: `"public"`, table: `"Users"`, expected: true},
+		{name: "escaped quote", cfgSchema: "public", cfgName: `a"b`, schema: "public", table: `"a""b"`, expected: true},
+		{name: "case sensitive", cfgSchema: "public", cfgName: "users", schema: "public", table: `"Users"`},
+		{name: "other table", cfgSchema: "public", cfgName: "users", schema: "public", table: "orders"},
+		{name: "other schema", cfgSchema: "public", cfgName: "users", schema: "bookings", table: "users"},
+		{name: "empty schema", cfgSchema: "", cfgName: "users", schema: "public", table: "users"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsTableMatch(tt.cfgSchema, tt.cfgName, tt.schema, tt.table))
+		})
+	}
+}
+
+func TestFindTable(t *testing.T) {
+	cfgs := []*Table{
+		{Schema: "public", Name: "users"},
+		{Schema: "public", Name: `"Orders"`},
+	}
+	assert.Same(t, cfgs[0], FindTable(cfgs, `"public"`, `"users"`))
+	assert.Same(t, cfgs[1], FindTable(cfgs, "public", "Orders"))
+	assert.Nil(t, FindTable(cfgs, "public", "orders"))
+}
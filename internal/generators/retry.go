@@ -0,0 +1,34 @@
+package generators
+
+import (
+	"encoding/binary"
+)
+
+// Retry - generator that mixes the attempt number into the input data, so the deterministic generator returns
+// another value for the same input on the next attempt. The zero attempt returns the wrapped generator result as is.
+// It is used to resolve the collisions of the generated values in the unique columns
+type Retry struct {
+	Generator
+	attempt uint32
+	buf     []byte
+}
+
+func NewRetry(g Generator) *Retry {
+	return &Retry{
+		Generator: g,
+	}
+}
+
+// SetAttempt - sets the attempt number that is used in the next Generate calls
+func (r *Retry) SetAttempt(attempt uint32) {
+	r.attempt = attempt
+}
+
+func (r *Retry) Generate(data []byte) ([]byte, error) {
+	if r.attempt == 0 {
+		return r.Generator.Generate(data)
+	}
+	r.buf = append(r.buf[:0], data...)
+	r.buf = binary.LittleEndian.AppendUint32(r.buf, r.attempt)
+	return r.Generator.Generate(r.buf)
+}
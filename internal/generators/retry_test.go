@@ -0,0 +1,37 @@
+package generators
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetry_Generate(t *testing.T) {
+	g, err := GetHashBytesGen([]byte("salt"), 16)
+	require.NoError(t, err)
+	expected, err := g.Generate([]byte("data"))
+	require.NoError(t, err)
+	expected = append([]byte(nil), expected...)
+
+	r := NewRetry(g)
+	res, err := r.Generate([]byte("data"))
+	require.NoError(t, err)
+	assert.Equal(t, expected, res)
+
+	r.SetAttempt(1)
+	first, err := r.Generate([]byte("data"))
+	require.NoError(t, err)
+	assert.NotEqual(t, expected, first)
+	first = append([]byte(nil), first...)
+
+	// The attempt result is deterministic
+	res, err = r.Generate([]byte("data"))
+	require.NoError(t, err)
+	assert.Equal(t, first, res)
+
+	r.SetAttempt(2)
+	res, err = r.Generate([]byte("data"))
+	require.NoError(t, err)
+	assert.NotEqual(t, first, res)
+}
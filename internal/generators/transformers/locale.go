@@ -0,0 +1,286 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transformers
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"math/bits"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultLocale - the locale that is used if the locale is not set. The person and company data of this locale
+	// are the default lists of the transformers
+	DefaultLocale = "en_US"
+	// patternDigit - the placeholder of the random digit in the phone and postal code patterns
+	patternDigit = '#'
+	// maxPatternDigits - the max number of the digit placeholders in the pattern. Each digit takes one byte of the
+	// generator output
+	maxPatternDigits = 20
+)
+
+var ErrUnknownLocale = errors.New("unknown locale")
+
+//go:embed locales/*.json
+var localesFs embed.FS
+
+// LocaleDataset - the locale specific values that are used by the person, company, address and phone transformers.
+// The bundled datasets are stored in the locales directory, the user dataset has the same structure and is loaded
+// from the file
+type LocaleDataset struct {
+	// Person - mapping gender to the person attributes values
+	Person Database `json:"person,omitempty"`
+	// Company - mapping company attribute to its values
+	Company map[string][]string `json:"company,omitempty"`
+	Address *AddressDatabase    `json:"address,omitempty"`
+	// Phone - the phone number patterns. Each # symbol is replaced with the random digit
+	Phone []string `json:"phone,omitempty"`
+}
+
+// AddressDatabase - the values of the address parts
+type AddressDatabase struct {
+	// Formats - Go templates of the address line. The Street and Number attributes are available
+	Formats []string       `json:"formats"`
+	Streets []string       `json:"streets"`
+	Cities  []*AddressCity `json:"cities"`
+}
+
+// AddressCity - the city with its region and location. Each # symbol of the postal code is replaced with the random
+// digit
+type AddressCity struct {
+	City       string  `json:"city"`
+	State      string  `json:"state"`
+	PostalCode string  `json:"postal_code"`
+	Latitude   float64 `json:"lat"`
+	Longitude  float64 `json:"lng"`
+}
+
+// GetLocales - returns the sorted names of the bundled locales
+func GetLocales() []string {
+	entries, err := localesFs.ReadDir("locales")
+	if err != nil {
+		panic(fmt.Sprintf("cannot read bundled locales: %s", err))
+	}
+	res := make([]string, 0, len(entries))
+	for _, e := range entries {
+		res = append(res, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	slices.Sort(res)
+	return res
+}
+
+// GetLocaleDataset - returns the bundled dataset of the locale. The default locale is used if the locale is empty
+func GetLocaleDataset(locale string) (*LocaleDataset, error) {
+	if locale == "" {
+		locale = DefaultLocale
+	}
+	if !slices.Contains(GetLocales(), locale) {
+		return nil, fmt.Errorf("%w \"%s\"", ErrUnknownLocale, locale)
+	}
+	data, err := localesFs.ReadFile(path.Join("locales", locale+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read locale \"%s\": %w", locale, err)
+	}
+	ds := &LocaleDataset{}
+	if err = json.Unmarshal(data, ds); err != nil {
+		return nil, fmt.Errorf("cannot parse locale \"%s\": %w", locale, err)
+	}
+	if locale == DefaultLocale {
+		ds.Person = DefaultPersonMap
+		ds.Company = DefaultCompanyMap
+	}
+	return ds, nil
+}
+
+// LoadLocaleDataset - returns the bundled dataset of the locale overridden by the sections of the user dataset
+// stored in the JSON file. The file is not loaded if fileName is empty
+func LoadLocaleDataset(locale, fileName string) (*LocaleDataset, error) {
+	ds, err := GetLocaleDataset(locale)
+	if err != nil {
+		return nil, err
+	}
+	if fileName == "" {
+		return ds, nil
+	}
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read dictionary file: %w", err)
+	}
+	userDs := &LocaleDataset{}
+	if err = json.Unmarshal(data, userDs); err != nil {
+		return nil, fmt.Errorf("cannot parse dictionary file: %w", err)
+	}
+	if err = userDs.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid dictionary file: %w", err)
+	}
+
+	if userDs.Person != nil {
+		ds.Person = userDs.Person
+	}
+	if userDs.Company != nil {
+		ds.Company = userDs.Company
+	}
+	if userDs.Address != nil {
+		ds.Address = userDs.Address
+	}
+	if userDs.Phone != nil {
+		ds.Phone = userDs.Phone
+	}
+	return ds, nil
+}
+
+// Validate - checks the sections of the dataset that are set. Each attribute must have at least one value because
+// the values are chosen by the generated index
+func (ld *LocaleDataset) Validate() error {
+	if ld.Person != nil {
+		if len(ld.Person) == 0 {
+			return errors.New("person: at least one gender is required")
+		}
+		var attrNames []string
+		for gender, attrs := range ld.Person {
+			if gender == AnyGenderName {
+				return fmt.Errorf("person: gender name \"%s\" is reserved", AnyGenderName)
+			}
+			if err := validateAttributes(attrs); err != nil {
+				return fmt.Errorf("person: gender \"%s\": %w", gender, err)
+			}
+			names := slices.Sorted(maps.Keys(attrs))
+			if attrNames == nil {
+				attrNames = names
+			} else if !slices.Equal(attrNames, names) {
+				return errors.New("person: all genders must have the same attributes")
+			}
+		}
+	}
+	if ld.Company != nil {
+		if err := validateAttributes(ld.Company); err != nil {
+			return fmt.Errorf("company: %w", err)
+		}
+	}
+	if ld.Address != nil {
+		if err := ld.Address.Validate(); err != nil {
+			return fmt.Errorf("address: %w", err)
+		}
+	}
+	if ld.Phone != nil {
+		if err := validatePatterns(ld.Phone); err != nil {
+			return fmt.Errorf("phone: %w", err)
+		}
+	}
+	return nil
+}
+
+// Validate - checks all the address parts are set, the formats are valid templates and the postal codes patterns
+// are not too long
+func (ad *AddressDatabase) Validate() error {
+	if len(ad.Formats) == 0 {
+		return errors.New("at least one format is required")
+	}
+	for _, f := range ad.Formats {
+		if _, err := template.New("").Parse(f); err != nil {
+			return fmt.Errorf("cannot parse format \"%s\": %w", f, err)
+		}
+	}
+	if len(ad.Streets) == 0 {
+		return errors.New("at least one street is required")
+	}
+	if len(ad.Cities) == 0 {
+		return errors.New("at least one city is required")
+	}
+	for _, c := range ad.Cities {
+		if c == nil || c.City == "" {
+			return errors.New("city name is required")
+		}
+		if n := countPatternDigits(c.PostalCode); n > maxPatternDigits {
+			return fmt.Errorf(
+				"postal code pattern \"%s\" of city \"%s\" has %d digits: max %d digits are allowed",
+				c.PostalCode, c.City, n, maxPatternDigits,
+			)
+		}
+	}
+	return nil
+}
+
+func validateAttributes(attrs map[string][]string) error {
+	if len(attrs) == 0 {
+		return errors.New("at least one attribute is required")
+	}
+	for name, values := range attrs {
+		if len(values) == 0 {
+			return fmt.Errorf("attribute \"%s\" has no values", name)
+		}
+	}
+	return nil
+}
+
+func validatePatterns(patterns []string) error {
+	if len(patterns) == 0 {
+		return errors.New("at least one pattern is required")
+	}
+	for _, p := range patterns {
+		if n := countPatternDigits(p); n == 0 || n > maxPatternDigits {
+			return fmt.Errorf(
+				"pattern \"%s\" has %d digits: from 1 to %d digits are allowed", p, n, maxPatternDigits,
+			)
+		}
+	}
+	return nil
+}
+
+// getValueSpaceSize - returns the product of the attributes values counts saturated at math.MaxUint64
+func getValueSpaceSize(attrs map[string][]string) uint64 {
+	if len(attrs) == 0 {
+		return 0
+	}
+	res := uint64(1)
+	for _, values := range attrs {
+		hi, lo := bits.Mul64(res, uint64(len(values)))
+		if hi != 0 {
+			return math.MaxUint64
+		}
+		res = lo
+	}
+	return res
+}
+
+func countPatternDigits(pattern string) int {
+	return strings.Count(pattern, string(patternDigit))
+}
+
+// fillPattern - appends the pattern to buf replacing each # symbol with the digit chosen by the next byte of
+// randomBytes
+func fillPattern(buf []byte, pattern string, randomBytes []byte) []byte {
+	var idx int
+	for _, r := range pattern {
+		if r == patternDigit {
+			buf = append(buf, '0'+randomBytes[idx]%10)
+			idx++
+			continue
+		}
+		buf = utf8.AppendRune(buf, r)
+	}
+	return buf
+}
@@ -0,0 +1,169 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transformers
+
+import (
+	"math"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetLocaleDataset(t *testing.T) {
+	locales := GetLocales()
+	require.Equal(t, []string{"de_DE", "en_US", "es_ES", "fr_FR", "ja_JP", "pt_BR"}, locales)
+
+	for _, locale := range locales {
+		t.Run(locale, func(t *testing.T) {
+			ds, err := GetLocaleDataset(locale)
+			require.NoError(t, err)
+			require.NoError(t, ds.Validate())
+			assert.NotEmpty(t, ds.Person)
+			assert.NotEmpty(t, ds.Company)
+			assert.NotNil(t, ds.Address)
+			assert.NotEmpty(t, ds.Phone)
+
+			// The datasets must be large enough to mask the real tables without many collisions
+			for gender, attrs := range ds.Person {
+				assert.GreaterOrEqual(t, len(attrs["FirstName"]), 90, gender)
+				assert.GreaterOrEqual(t, len(attrs["LastName"]), 100, gender)
+				assert.GreaterOrEqual(t, NewPersonalDatabase(ds.Person).GetValueSpaceSize(gender), uint64(10000))
+			}
+			assert.GreaterOrEqual(t, len(ds.Company["CompanyName"]), 70)
+		})
+	}
+
+	t.Run("default", func(t *testing.T) {
+		ds, err := GetLocaleDataset("")
+		require.NoError(t, err)
+		assert.Equal(t, DefaultCompanyNames, ds.Company["CompanyName"])
+		assert.Equal(t, DefaultLastNames, ds.Person[MaleGenderName]["LastName"])
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := GetLocaleDataset("xx_XX")
+		require.ErrorIs(t, err, ErrUnknownLocale)
+	})
+}
+
+func TestLoadLocaleDataset(t *testing.T) {
+	fileName := path.Join(t.TempDir(), "dictionary.json")
+	err := os.WriteFile(fileName, []byte(`{"phone": ["+41 ## ### ## ##"]}`), 0600)
+	require.NoError(t, err)
+
+	ds, err := LoadLocaleDataset("de_DE", fileName)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"+41 ## ### ## ##"}, ds.Phone)
+	assert.Contains(t, ds.Person[MaleGenderName]["LastName"], "Müller")
+
+	t.Run("invalid", func(t *testing.T) {
+		err := os.WriteFile(fileName, []byte(`{"person": {"Male": {"FirstName": []}}}`), 0600)
+		require.NoError(t, err)
+		_, err = LoadLocaleDataset("", fileName)
+		require.ErrorContains(t, err, `attribute "FirstName" has no values`)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := LoadLocaleDataset("", path.Join(t.TempDir(), "unknown.json"))
+		require.ErrorContains(t, err, "cannot read dictionary file")
+	})
+}
+
+func TestLocaleDataset_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		ds   *LocaleDataset
+		err  string
+	}{
+		{
+			name: "reserved gender",
+			ds:   &LocaleDataset{Person: Database{AnyGenderName: {"FirstName": {"Alex"}}}},
+			err:  `gender name "Any" is reserved`,
+		},
+		{
+			name: "different attributes",
+			ds: &LocaleDataset{Person: Database{
+				MaleGenderName:   {"FirstName": {"Alex"}, "Title": {"Mr."}},
+				FemaleGenderName: {"FirstName": {"Alex"}},
+			}},
+			err: "all genders must have the same attributes",
+		},
+		{
+			name: "phone without digits",
+			ds:   &LocaleDataset{Phone: []string{"+1"}},
+			err:  "from 1 to 20 digits are allowed",
+		},
+		{
+			name: "address format",
+			ds: &LocaleDataset{Address: &AddressDatabase{
+				Formats: []string{"{{ .Street }"},
+				Streets: []string{"Main Street"},
+				Cities:  []*AddressCity{{City: "Springfield"}},
+			}},
+			err: "cannot parse format",
+		},
+		{
+			name: "postal code",
+			ds: &LocaleDataset{Address: &AddressDatabase{
+				Formats: []string{"{{ .Street }}"},
+				Streets: []string{"Main Street"},
+				Cities:  []*AddressCity{{City: "Springfield", PostalCode: "#####################"}},
+			}},
+			err: "max 20 digits are allowed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.ds.Validate(), tt.err)
+		})
+	}
+}
+
+func TestGetValueSpaceSize(t *testing.T) {
+	person := NewPersonalDatabase(Database{
+		MaleGenderName: {
+			"FirstName": {"John", "Jack"},
+			"LastName":  {"Smith", "Brown", "Jones"},
+		},
+		FemaleGenderName: {
+			"FirstName": {"Jane"},
+			"LastName":  {"Smith", "Brown", "Jones"},
+		},
+	})
+	assert.Equal(t, uint64(6), person.GetValueSpaceSize(MaleGenderName))
+	assert.Equal(t, uint64(3), person.GetValueSpaceSize(FemaleGenderName))
+	assert.Equal(t, uint64(9), person.GetValueSpaceSize(AnyGenderName))
+	assert.Equal(t, uint64(9), NewRandomPersonTransformer(AnyGenderName, person.Db).GetValueSpaceSize())
+
+	company := NewRandomCompanyTransformer(map[string][]string{
+		"CompanyName":   {"Apex", "Blue"},
+		"CompanySuffix": {"Ltd.", "Inc.", "LLC."},
+	})
+	assert.Equal(t, uint64(6), company.GetValueSpaceSize())
+
+	// The size is saturated
+	values := make([]string, 1<<20)
+	assert.Equal(t, uint64(math.MaxUint64), getValueSpaceSize(map[string][]string{
+		"a": values, "b": values, "c": values, "d": values,
+	}))
+}
+
+func TestFillPattern(t *testing.T) {
+	res := fillPattern(nil, "+81 3-##-#", []byte{1, 12, 255})
+	require.Equal(t, "+81 3-12-5", string(res))
+}
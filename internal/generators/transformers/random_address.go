@@ -0,0 +1,122 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transformers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"text/template"
+
+	"github.com/greenmaskio/greenmask/internal/generators"
+)
+
+const (
+	// addressMaxNumber - the max building number of the generated address
+	addressMaxNumber = 200
+	// addressIndexesLength - 4 bytes for format, street and city indexes and 2 bytes for the building number
+	addressIndexesLength = 3*4 + 2
+)
+
+// AddressValue - the generated address attributes
+type AddressValue struct {
+	Address    string
+	City       string
+	State      string
+	PostalCode string
+	Latitude   float64
+	Longitude  float64
+}
+
+// addressLine - the attributes of the address line format
+type addressLine struct {
+	Street string
+	Number int
+}
+
+// RandomAddressTransformer - generates the address from the locale address database. The same generator output
+// produces the same address, so it can be used with the hash engine
+type RandomAddressTransformer struct {
+	db         *AddressDatabase
+	formats    []*template.Template
+	byteLength int
+	generator  generators.Generator
+	buf        *bytes.Buffer
+	postalCode []byte
+}
+
+func NewRandomAddressTransformer(db *AddressDatabase) (*RandomAddressTransformer, error) {
+	if err := db.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid address database: %w", err)
+	}
+	formats := make([]*template.Template, 0, len(db.Formats))
+	for _, f := range db.Formats {
+		tmpl, err := template.New("").Parse(f)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse format \"%s\": %w", f, err)
+		}
+		formats = append(formats, tmpl)
+	}
+	var postalCodeDigits int
+	for _, c := range db.Cities {
+		postalCodeDigits = max(postalCodeDigits, countPatternDigits(c.PostalCode))
+	}
+
+	return &RandomAddressTransformer{
+		db:         db,
+		formats:    formats,
+		byteLength: addressIndexesLength + postalCodeDigits,
+		buf:        bytes.NewBuffer(nil),
+	}, nil
+}
+
+func (rat *RandomAddressTransformer) GetAddress(original []byte) (*AddressValue, error) {
+	resBytes, err := rat.generator.Generate(original)
+	if err != nil {
+		return nil, err
+	}
+
+	format := rat.formats[binary.LittleEndian.Uint32(resBytes[0:4])%uint32(len(rat.formats))]
+	street := rat.db.Streets[binary.LittleEndian.Uint32(resBytes[4:8])%uint32(len(rat.db.Streets))]
+	city := rat.db.Cities[binary.LittleEndian.Uint32(resBytes[8:12])%uint32(len(rat.db.Cities))]
+	number := int(binary.LittleEndian.Uint16(resBytes[12:14])%addressMaxNumber) + 1
+
+	rat.buf.Reset()
+	if err = format.Execute(rat.buf, &addressLine{Street: street, Number: number}); err != nil {
+		return nil, fmt.Errorf("error executing address format: %w", err)
+	}
+	rat.postalCode = fillPattern(rat.postalCode[:0], city.PostalCode, resBytes[addressIndexesLength:])
+
+	return &AddressValue{
+		Address:    rat.buf.String(),
+		City:       city.City,
+		State:      city.State,
+		PostalCode: string(rat.postalCode),
+		Latitude:   city.Latitude,
+		Longitude:  city.Longitude,
+	}, nil
+}
+
+func (rat *RandomAddressTransformer) GetRequiredGeneratorByteLength() int {
+	return rat.byteLength
+}
+
+func (rat *RandomAddressTransformer) SetGenerator(g generators.Generator) error {
+	if g.Size() < rat.byteLength {
+		return fmt.Errorf("requested byte length (%d) higher than generator can produce (%d)", rat.byteLength, g.Size())
+	}
+	rat.generator = g
+	return nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transformers
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/generators"
+)
+
+func TestRandomAddressTransformer_GetAddress(t *testing.T) {
+	ds, err := GetLocaleDataset("de_DE")
+	require.NoError(t, err)
+	rat, err := NewRandomAddressTransformer(ds.Address)
+	require.NoError(t, err)
+	g, err := generators.GetHashBytesGen([]byte("salt"), rat.GetRequiredGeneratorByteLength())
+	require.NoError(t, err)
+	require.NoError(t, rat.SetGenerator(g))
+
+	res, err := rat.GetAddress([]byte("Hauptstraße 1"))
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^\D+ \d{1,3}$`), res.Address)
+	assert.Regexp(t, regexp.MustCompile(`^\d{5}$`), res.PostalCode)
+	assert.NotEmpty(t, res.City)
+	assert.NotEmpty(t, res.State)
+
+	again, err := rat.GetAddress([]byte("Hauptstraße 1"))
+	require.NoError(t, err)
+	assert.Equal(t, res, again)
+}
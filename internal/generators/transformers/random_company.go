@@ -44,6 +44,12 @@ func (pd *CompanyDatabase) GetRandomAttribute(attr string, randomIdx uint32) str
 	return attrs[randomIdx%uint32(len(attrs))]
 }
 
+// GetValueSpaceSize - returns the number of the distinct attributes combinations. The result is saturated at
+// math.MaxUint64
+func (pd *CompanyDatabase) GetValueSpaceSize() uint64 {
+	return getValueSpaceSize(pd.Db)
+}
+
 func NewCompanyDatabase(data map[string][]string) *CompanyDatabase {
 	if data == nil {
 		panic("data is nil")
@@ -117,6 +123,11 @@ func (rpt *RandomCompanyTransformer) GetCompanyName(original []byte) (map[string
 	return rpt.result, nil
 }
 
+// GetValueSpaceSize - returns the number of the distinct attributes combinations the transformer can generate
+func (rpt *RandomCompanyTransformer) GetValueSpaceSize() uint64 {
+	return rpt.db.GetValueSpaceSize()
+}
+
 func (rpt *RandomCompanyTransformer) GetRequiredGeneratorByteLength() int {
 	return rpt.byteLength
 }
@@ -0,0 +1,240 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transformers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/greenmaskio/greenmask/internal/generators"
+)
+
+const (
+	identityFirstNameAttr = "FirstName"
+	identityLastNameAttr  = "LastName"
+	identityTitleAttr     = "Title"
+	// identityFallbackLogin - the login prefix that is used if the name cannot be transliterated into ASCII
+	identityFallbackLogin = "user"
+	// identityMaxNumber - the upper bound of the number that is appended to the login
+	identityMaxNumber = 100
+)
+
+// identityTransliteration - the ASCII replacements of the letters that are used in the bundled locales
+var identityTransliteration = map[rune]string{
+	'ä': "ae", 'ö': "oe", 'ü': "ue", 'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o",
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'å': "a",
+	'ç': "c",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i",
+	'ñ': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o",
+	'ù': "u", 'ú': "u", 'û': "u",
+	'ý': "y", 'ÿ': "y",
+}
+
+// loginStyle - builds the login from the transliterated first name, last name and number
+type loginStyle func(first, last string, number int) string
+
+var (
+	identityEmailStyles = []loginStyle{
+		func(first, last string, n int) string { return joinLogin(".", first, last) },
+		func(first, last string, n int) string { return joinLogin("", first, last) },
+		func(first, last string, n int) string { return joinLogin(".", initial(first), last) },
+		func(first, last string, n int) string { return joinLogin("_", first, last) },
+		func(first, last string, n int) string { return joinLogin(".", first, last) + strconv.Itoa(n) },
+		func(first, last string, n int) string { return joinLogin(".", last, first) },
+	}
+	identityUsernameStyles = []loginStyle{
+		func(first, last string, n int) string { return joinLogin("", first, last) },
+		func(first, last string, n int) string { return joinLogin(".", first, last) },
+		func(first, last string, n int) string { return joinLogin("", initial(first), last) },
+		func(first, last string, n int) string { return joinLogin("_", first, last) + strconv.Itoa(n) },
+		func(first, last string, n int) string { return joinLogin("", first, last) + strconv.Itoa(n) },
+		func(first, last string, n int) string { return joinLogin("", initial(first), last) + strconv.Itoa(n) },
+	}
+)
+
+// IdentityValue - the attributes of the generated person. The email and username are derived from the name
+type IdentityValue struct {
+	FirstName string
+	LastName  string
+	FullName  string
+	Title     string
+	Gender    string
+	Email     string
+	Username  string
+	Phone     string
+	BirthDate time.Time
+}
+
+// RandomIdentityTransformer - generates the consistent person attributes using a single generator output, so the
+// same input produces the same person with the hash engine
+type RandomIdentityTransformer struct {
+	gender     string
+	db         *PersonDatabase
+	phones     []string
+	domains    []string
+	minDate    time.Time
+	daysRange  uint32
+	byteLength int
+	generator  generators.Generator
+	phoneBuf   []byte
+}
+
+// NewRandomIdentityTransformer - creates the identity transformer. The person names and phone patterns are taken
+// from the dataset, the birth date is generated in the range [minBirthDate, maxBirthDate]
+func NewRandomIdentityTransformer(
+	gender string, ds *LocaleDataset, domains []string, minBirthDate, maxBirthDate time.Time,
+) (*RandomIdentityTransformer, error) {
+	if len(domains) == 0 {
+		return nil, errors.New("at least one email domain is required")
+	}
+	if maxBirthDate.Before(minBirthDate) {
+		return nil, errors.New("max birth date must not be before min birth date")
+	}
+	db := NewPersonalDatabase(ds.Person)
+	if !slices.Contains(db.Attributes, identityFirstNameAttr) || !slices.Contains(db.Attributes, identityLastNameAttr) {
+		return nil, fmt.Errorf(
+			"person dataset must contain %s and %s attributes", identityFirstNameAttr, identityLastNameAttr,
+		)
+	}
+	if gender != AnyGenderName && !slices.Contains(db.Genders, gender) {
+		return nil, fmt.Errorf("unable to match gender \"%s\"", gender)
+	}
+	if err := validatePatterns(ds.Phone); err != nil {
+		return nil, fmt.Errorf("invalid phone patterns: %w", err)
+	}
+	var phoneDigits int
+	for _, p := range ds.Phone {
+		phoneDigits = max(phoneDigits, countPatternDigits(p))
+	}
+
+	return &RandomIdentityTransformer{
+		gender:    gender,
+		db:        db,
+		phones:    ds.Phone,
+		domains:   domains,
+		minDate:   minBirthDate,
+		daysRange: uint32(maxBirthDate.Sub(minBirthDate).Hours()/24) + 1,
+		// 1 byte for gender, 4 bytes per person attribute, 4 bytes for domain, 1 byte per email and username
+		// style, 4 bytes for the login number, 4 bytes for birth date, 4 bytes for phone pattern and 1 byte per
+		// phone digit
+		byteLength: 1 + db.AttributesCount*4 + 4 + 1 + 1 + 4 + 4 + 4 + phoneDigits,
+	}, nil
+}
+
+func (rit *RandomIdentityTransformer) GetIdentity(original []byte) (*IdentityValue, error) {
+	resBytes, err := rit.generator.Generate(original)
+	if err != nil {
+		return nil, err
+	}
+
+	gender := rit.gender
+	if gender == AnyGenderName {
+		gender = rit.db.GetRandomGender(uint32(resBytes[0]))
+	}
+	res := &IdentityValue{Gender: gender}
+	offset := 1
+	for _, attr := range rit.db.Attributes {
+		value := rit.db.GetRandomAttribute(gender, attr, binary.LittleEndian.Uint32(resBytes[offset:offset+4]))
+		switch attr {
+		case identityFirstNameAttr:
+			res.FirstName = value
+		case identityLastNameAttr:
+			res.LastName = value
+		case identityTitleAttr:
+			res.Title = value
+		}
+		offset += 4
+	}
+	res.FullName = res.FirstName + " " + res.LastName
+
+	domain := rit.domains[binary.LittleEndian.Uint32(resBytes[offset:offset+4])%uint32(len(rit.domains))]
+	emailStyle := identityEmailStyles[int(resBytes[offset+4])%len(identityEmailStyles)]
+	usernameStyle := identityUsernameStyles[int(resBytes[offset+5])%len(identityUsernameStyles)]
+	loginNumber := binary.LittleEndian.Uint32(resBytes[offset+6 : offset+10])
+	number := int(loginNumber % identityMaxNumber)
+	offset += 10
+
+	first, last := transliterate(res.FirstName), transliterate(res.LastName)
+	if first == "" && last == "" {
+		// The name cannot be represented in ASCII, the login is based on the number only. The whole 32-bit number
+		// is used, so the unique email and username columns do not exhaust the value space
+		login := identityFallbackLogin + strconv.FormatUint(uint64(loginNumber), 10)
+		res.Email = login + "@" + domain
+		res.Username = login
+	} else {
+		res.Email = emailStyle(first, last, number) + "@" + domain
+		res.Username = usernameStyle(first, last, number)
+	}
+
+	days := binary.LittleEndian.Uint32(resBytes[offset:offset+4]) % rit.daysRange
+	res.BirthDate = rit.minDate.AddDate(0, 0, int(days))
+	offset += 4
+
+	phone := rit.phones[binary.LittleEndian.Uint32(resBytes[offset:offset+4])%uint32(len(rit.phones))]
+	rit.phoneBuf = fillPattern(rit.phoneBuf[:0], phone, resBytes[offset+4:])
+	res.Phone = string(rit.phoneBuf)
+
+	return res, nil
+}
+
+func (rit *RandomIdentityTransformer) GetRequiredGeneratorByteLength() int {
+	return rit.byteLength
+}
+
+func (rit *RandomIdentityTransformer) SetGenerator(g generators.Generator) error {
+	if g.Size() < rit.byteLength {
+		return fmt.Errorf("requested byte length (%d) higher than generator can produce (%d)", rit.byteLength, g.Size())
+	}
+	rit.generator = g
+	return nil
+}
+
+// transliterate - returns the lower case ASCII representation of the name. The letters that cannot be represented
+// are dropped
+func transliterate(name string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			sb.WriteRune(r)
+		default:
+			sb.WriteString(identityTransliteration[r])
+		}
+	}
+	return sb.String()
+}
+
+func initial(name string) string {
+	if name == "" {
+		return ""
+	}
+	return name[:1]
+}
+
+// joinLogin - joins the non-empty login parts with the separator
+func joinLogin(sep string, parts ...string) string {
+	parts = slices.DeleteFunc(parts, func(p string) bool {
+		return p == ""
+	})
+	return strings.Join(parts, sep)
+}
@@ -0,0 +1,106 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transformers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/generators"
+)
+
+func newTestIdentityTransformer(t *testing.T, locale string) *RandomIdentityTransformer {
+	ds, err := GetLocaleDataset(locale)
+	require.NoError(t, err)
+	rit, err := NewRandomIdentityTransformer(
+		AnyGenderName,
+		ds,
+		[]string{"example.com"},
+		time.Date(1950, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2000, 12, 31, 0, 0, 0, 0, time.UTC),
+	)
+	require.NoError(t, err)
+	g, err := generators.GetHashBytesGen([]byte("salt"), rit.GetRequiredGeneratorByteLength())
+	require.NoError(t, err)
+	require.NoError(t, rit.SetGenerator(g))
+	return rit
+}
+
+func TestRandomIdentityTransformer_GetIdentity(t *testing.T) {
+	rit := newTestIdentityTransformer(t, "de_DE")
+
+	for _, key := range []string{"1", "2", "3", "4", "5", "6", "7", "8"} {
+		res, err := rit.GetIdentity([]byte(key))
+		require.NoError(t, err)
+
+		assert.Contains(t, rit.db.Genders, res.Gender)
+		assert.Contains(t, rit.db.Db[res.Gender][identityFirstNameAttr], res.FirstName)
+		assert.Contains(t, rit.db.Db[res.Gender][identityLastNameAttr], res.LastName)
+		assert.Equal(t, res.FirstName+" "+res.LastName, res.FullName)
+
+		last := transliterate(res.LastName)
+		assert.True(t, strings.HasSuffix(res.Email, "@example.com"))
+		assert.Contains(t, res.Email, last)
+		assert.Contains(t, res.Username, last)
+		assert.Regexp(t, `^[a-z0-9._]+$`, res.Username)
+		assert.Regexp(t, `^\+?[0-9 ]+$`, res.Phone)
+		assert.False(t, res.BirthDate.Before(time.Date(1950, 1, 1, 0, 0, 0, 0, time.UTC)))
+		assert.False(t, res.BirthDate.After(time.Date(2000, 12, 31, 0, 0, 0, 0, time.UTC)))
+
+		again, err := rit.GetIdentity([]byte(key))
+		require.NoError(t, err)
+		assert.Equal(t, res, again)
+	}
+}
+
+func TestRandomIdentityTransformer_GetIdentity_non_latin(t *testing.T) {
+	rit := newTestIdentityTransformer(t, "ja_JP")
+	res, err := rit.GetIdentity([]byte("1"))
+	require.NoError(t, err)
+	assert.Regexp(t, `^user\d+@example\.com$`, res.Email)
+	assert.Regexp(t, `^user\d+$`, res.Username)
+	assert.Equal(t, strings.TrimSuffix(res.Email, "@example.com"), res.Username)
+}
+
+func TestNewRandomIdentityTransformer_validation(t *testing.T) {
+	ds, err := GetLocaleDataset("")
+	require.NoError(t, err)
+	minDate := time.Date(1950, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxDate := time.Date(2000, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	_, err = NewRandomIdentityTransformer(AnyGenderName, ds, nil, minDate, maxDate)
+	require.ErrorContains(t, err, "at least one email domain is required")
+
+	_, err = NewRandomIdentityTransformer(AnyGenderName, ds, []string{"example.com"}, maxDate, minDate)
+	require.ErrorContains(t, err, "max birth date must not be before min birth date")
+
+	_, err = NewRandomIdentityTransformer("Unknown", ds, []string{"example.com"}, minDate, maxDate)
+	require.ErrorContains(t, err, `unable to match gender "Unknown"`)
+
+	ds.Person = Database{MaleGenderName: {identityFirstNameAttr: {"Alex"}}}
+	_, err = NewRandomIdentityTransformer(AnyGenderName, ds, []string{"example.com"}, minDate, maxDate)
+	require.ErrorContains(t, err, "person dataset must contain FirstName and LastName attributes")
+}
+
+func TestTransliterate(t *testing.T) {
+	assert.Equal(t, "mueller", transliterate("Müller"))
+	assert.Equal(t, "gonzalez", transliterate("González"))
+	assert.Equal(t, "francois", transliterate("François"))
+	assert.Equal(t, "", transliterate("佐藤"))
+}
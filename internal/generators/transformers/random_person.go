@@ -3,6 +3,8 @@ package transformers
 import (
 	"encoding/binary"
 	"fmt"
+	"math"
+	"math/bits"
 	"slices"
 
 	"github.com/greenmaskio/greenmask/internal/generators"
@@ -127,6 +129,23 @@ func (pd *PersonDatabase) GetRandomGender(randomIdx uint32) string {
 	return pd.Genders[randomIdx%uint32(len(pd.Genders))]
 }
 
+// GetValueSpaceSize - returns the number of the distinct attributes combinations of the gender. If the gender is
+// AnyGenderName the combinations of all genders are summed. The result is saturated at math.MaxUint64
+func (pd *PersonDatabase) GetValueSpaceSize(gender string) uint64 {
+	if gender != AnyGenderName {
+		return getValueSpaceSize(pd.Db[gender])
+	}
+	var res uint64
+	for _, g := range pd.Genders {
+		sum, carry := bits.Add64(res, getValueSpaceSize(pd.Db[g]), 0)
+		if carry != 0 {
+			return math.MaxUint64
+		}
+		res = sum
+	}
+	return res
+}
+
 func NewPersonalDatabase(data Database) *PersonDatabase {
 	uniqueAttributes := make(map[string]struct{})
 	genders := make([]string, 0, len(data))
@@ -149,6 +168,9 @@ func NewPersonalDatabase(data Database) *PersonDatabase {
 	}
 
 	slices.Sort(attributes)
+	// The genders are sorted because the gender is chosen by the generated index and the hash engine must be
+	// deterministic
+	slices.Sort(genders)
 
 	return &PersonDatabase{
 		Db:              data,
@@ -204,7 +226,7 @@ func NewRandomPersonTransformer(gender string, personDb Database) *RandomPersonT
 		// we assume 4 bytes peer attribute + 1 byte for gender
 		db:         db,
 		result:     make(map[string]string, db.AttributesCount),
-		byteLength: db.AttributesCount * 4,
+		byteLength: db.AttributesCount*4 + 1,
 	}
 }
 
@@ -240,16 +262,21 @@ func (rpt *RandomPersonTransformer) getGender(gender string, randomGenderIdx byt
 		gender = rpt.gender
 	}
 
-	switch gender {
-	case MaleGenderName, FemaleGenderName:
-	case AnyGenderName:
-		gender = rpt.db.GetRandomGender(uint32(randomGenderIdx))
-	default:
+	if gender == AnyGenderName {
+		return rpt.db.GetRandomGender(uint32(randomGenderIdx)), nil
+	}
+	if !slices.Contains(rpt.db.Genders, gender) {
 		return "", fmt.Errorf("unable to match gender \"%s\"", gender)
 	}
 	return gender, nil
 }
 
+// GetValueSpaceSize - returns the number of the distinct attributes combinations the transformer can generate. If
+// the transformed column takes only some attributes the number of its distinct values is lower
+func (rpt *RandomPersonTransformer) GetValueSpaceSize() uint64 {
+	return rpt.db.GetValueSpaceSize(rpt.gender)
+}
+
 func (rpt *RandomPersonTransformer) GetRequiredGeneratorByteLength() int {
 	return rpt.byteLength
 }
@@ -0,0 +1,79 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transformers
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/greenmaskio/greenmask/internal/generators"
+)
+
+// RandomPhoneTransformer - generates the phone number using one of the patterns. Each # symbol of the pattern is
+// replaced with the random digit
+type RandomPhoneTransformer struct {
+	patterns   []string
+	byteLength int
+	generator  generators.Generator
+	buf        []byte
+}
+
+func NewRandomPhoneTransformer(patterns []string) (*RandomPhoneTransformer, error) {
+	if err := validatePatterns(patterns); err != nil {
+		return nil, err
+	}
+	var digits int
+	for _, p := range patterns {
+		digits = max(digits, countPatternDigits(p))
+	}
+	return &RandomPhoneTransformer{
+		patterns: patterns,
+		// 4 bytes for the pattern index and 1 byte per digit
+		byteLength: 4 + digits,
+	}, nil
+}
+
+// GetPhone - returns the generated phone number. The result is valid until the next call
+func (rpt *RandomPhoneTransformer) GetPhone(original []byte) ([]byte, error) {
+	resBytes, err := rpt.generator.Generate(original)
+	if err != nil {
+		return nil, err
+	}
+	pattern := rpt.patterns[binary.LittleEndian.Uint32(resBytes[0:4])%uint32(len(rpt.patterns))]
+	rpt.buf = fillPattern(rpt.buf[:0], pattern, resBytes[4:])
+	return rpt.buf, nil
+}
+
+// GetValueSpaceSize - returns the number of the distinct phone numbers. The patterns are assumed not to overlap
+func (rpt *RandomPhoneTransformer) GetValueSpaceSize() float64 {
+	var res float64
+	for _, p := range rpt.patterns {
+		res += math.Pow(10, float64(countPatternDigits(p)))
+	}
+	return res
+}
+
+func (rpt *RandomPhoneTransformer) GetRequiredGeneratorByteLength() int {
+	return rpt.byteLength
+}
+
+func (rpt *RandomPhoneTransformer) SetGenerator(g generators.Generator) error {
+	if g.Size() < rpt.byteLength {
+		return fmt.Errorf("requested byte length (%d) higher than generator can produce (%d)", rpt.byteLength, g.Size())
+	}
+	rpt.generator = g
+	return nil
+}
@@ -0,0 +1,45 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transformers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/generators"
+)
+
+func TestRandomPhoneTransformer_GetPhone(t *testing.T) {
+	rpt, err := NewRandomPhoneTransformer([]string{"090-####-####", "03-####-####"})
+	require.NoError(t, err)
+	assert.Equal(t, 12, rpt.GetRequiredGeneratorByteLength())
+	assert.Equal(t, float64(2e8), rpt.GetValueSpaceSize())
+
+	g := generators.NewRandomBytes(time.Now().UnixNano(), rpt.GetRequiredGeneratorByteLength())
+	require.NoError(t, rpt.SetGenerator(g))
+	for i := 0; i < 10; i++ {
+		res, err := rpt.GetPhone(nil)
+		require.NoError(t, err)
+		assert.Regexp(t, `^(090|03)-\d{4}-\d{4}$`, string(res))
+	}
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		_, err := NewRandomPhoneTransformer([]string{"+1"})
+		require.Error(t, err)
+	})
+}
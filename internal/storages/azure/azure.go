@@ -0,0 +1,265 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	azlog "github.com/Azure/azure-sdk-for-go/sdk/azcore/log"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/storages/domains"
+)
+
+const DefaultAzureObjectsDelimiter = "/"
+
+type Storage struct {
+	config    *Config
+	client    *azblob.Client
+	container *container.Client
+	prefix    string
+}
+
+func NewStorage(ctx context.Context, cfg *Config, logLevel string) (*Storage, error) {
+	if logLevel == zerolog.LevelDebugValue {
+		azlog.SetListener(func(event azlog.Event, msg string) {
+			log.Debug().Str("Event", string(event)).Msg(msg)
+		})
+	}
+
+	client, err := newClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create azure blob client: %w", err)
+	}
+
+	log.Debug().
+		Str("url", client.URL()).
+		Str("container", cfg.Container).
+		Msg("azure storage container")
+
+	return &Storage{
+		config:    cfg,
+		client:    client,
+		container: client.ServiceClient().NewContainerClient(cfg.Container),
+		prefix:    fixPrefix(strings.TrimPrefix(cfg.Prefix, "/")),
+	}, nil
+}
+
+func newClient(cfg *Config) (*azblob.Client, error) {
+	if cfg.ConnectionString != "" {
+		return azblob.NewClientFromConnectionString(cfg.ConnectionString, nil)
+	}
+
+	serviceUrl := cfg.getServiceUrl()
+	if cfg.AccountKey != "" {
+		cred, err := azblob.NewSharedKeyCredential(cfg.AccountName, cfg.AccountKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid shared key credential: %w", err)
+		}
+		return azblob.NewClientWithSharedKeyCredential(serviceUrl, cred, nil)
+	}
+
+	if cfg.SasToken != "" {
+		return azblob.NewClientWithNoCredential(
+			fmt.Sprintf("%s?%s", serviceUrl, strings.TrimPrefix(cfg.SasToken, "?")), nil,
+		)
+	}
+
+	var cred azcore.TokenCredential
+	var err error
+	if cfg.UseManagedIdentity {
+		opts := &azidentity.ManagedIdentityCredentialOptions{}
+		if cfg.ManagedIdentityClientId != "" {
+			opts.ID = azidentity.ClientID(cfg.ManagedIdentityClientId)
+		}
+		cred, err = azidentity.NewManagedIdentityCredential(opts)
+	} else {
+		cred, err = azidentity.NewDefaultAzureCredential(nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot get azure credentials: %w", err)
+	}
+	return azblob.NewClient(serviceUrl, cred, nil)
+}
+
+func (s *Storage) GetCwd() string {
+	return s.prefix
+}
+
+func (s *Storage) Dirname() string {
+	return filepath.Base(s.prefix)
+}
+
+func (s *Storage) ListDir(ctx context.Context) (files []string, dirs []storages.Storager, err error) {
+	pager := s.container.NewListBlobsHierarchyPager(DefaultAzureObjectsDelimiter, &container.ListBlobsHierarchyOptions{
+		Prefix: &s.prefix,
+	})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error listing azure blobs: %w", err)
+		}
+		for _, prefix := range page.Segment.BlobPrefixes {
+			dirs = append(dirs, s.SubStorage(*prefix.Name, false))
+		}
+		for _, item := range page.Segment.BlobItems {
+			files = append(files, strings.TrimPrefix(*item.Name, s.prefix))
+		}
+	}
+	return
+}
+
+func (s *Storage) GetObject(ctx context.Context, filePath string) (reader io.ReadCloser, err error) {
+	return s.getObject(ctx, filePath, nil)
+}
+
+// GetObjectRange - downloads the part of the blob
+func (s *Storage) GetObjectRange(ctx context.Context, filePath string, offset, length int64) (io.ReadCloser, error) {
+	// The zero count means the blob is read up to the end
+	return s.getObject(ctx, filePath, &azblob.DownloadStreamOptions{
+		Range: azblob.HTTPRange{Offset: offset, Count: max(length, 0)},
+	})
+}
+
+func (s *Storage) getObject(
+	ctx context.Context, filePath string, opts *azblob.DownloadStreamOptions,
+) (io.ReadCloser, error) {
+	resp, err := s.client.DownloadStream(ctx, s.config.Container, s.objectName(filePath), opts)
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return nil, storages.ErrFileNotFound
+		}
+		return nil, fmt.Errorf("error getting object: %w", err)
+	}
+	return resp.Body, nil
+}
+
+func (s *Storage) PutObject(ctx context.Context, filePath string, body io.Reader) error {
+	_, err := s.client.UploadStream(ctx, s.config.Container, s.objectName(filePath), body,
+		&azblob.UploadStreamOptions{
+			BlockSize:   s.config.BlockSize,
+			Concurrency: s.config.Concurrency,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("azure blob uploading error: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) Delete(ctx context.Context, filePaths ...string) error {
+	eg, gtx := errgroup.WithContext(ctx)
+	eg.SetLimit(max(s.config.Concurrency, 1))
+	for _, fp := range filePaths {
+		name := s.objectName(fp)
+		eg.Go(func() error {
+			_, err := s.client.DeleteBlob(gtx, s.config.Container, name, nil)
+			if err != nil && !bloberror.HasCode(err, bloberror.BlobNotFound) {
+				return fmt.Errorf("error deleting object %s: %w", name, err)
+			}
+			return nil
+		})
+	}
+	return eg.Wait()
+}
+
+func (s *Storage) DeleteAll(ctx context.Context, pathPrefix string) error {
+	pathPrefix = fixPrefix(pathPrefix)
+	ss := s.SubStorage(pathPrefix, true)
+	filesList, err := storages.Walk(ctx, ss, "")
+	if err != nil {
+		return fmt.Errorf("error walking through storage: %w", err)
+	}
+
+	if err = ss.Delete(ctx, filesList...); err != nil {
+		return fmt.Errorf("error deleting files: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) SubStorage(subPath string, relative bool) storages.Storager {
+	prefix := subPath
+	if relative {
+		prefix = path.Join(s.prefix, subPath)
+	}
+	return &Storage{
+		config:    s.config,
+		client:    s.client,
+		container: s.container,
+		prefix:    fixPrefix(strings.TrimPrefix(prefix, "/")),
+	}
+}
+
+func (s *Storage) Exists(ctx context.Context, fileName string) (bool, error) {
+	_, err := s.container.NewBlobClient(s.objectName(fileName)).GetProperties(ctx, nil)
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error getting object info: %w", err)
+	}
+	return true, nil
+}
+
+func (s *Storage) Stat(fileName string) (*domains.ObjectStat, error) {
+	fullPath := s.objectName(fileName)
+	props, err := s.container.NewBlobClient(fullPath).GetProperties(context.Background(), nil)
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return &domains.ObjectStat{
+				Name:         fullPath,
+				LastModified: time.Time{},
+				Exist:        false,
+			}, nil
+		}
+		return nil, fmt.Errorf("error getting object info: %w", err)
+	}
+
+	var lastModified time.Time
+	if props.LastModified != nil {
+		lastModified = *props.LastModified
+	}
+	return &domains.ObjectStat{
+		Name:         fullPath,
+		LastModified: lastModified,
+		Exist:        true,
+	}, nil
+}
+
+// objectName - returns the blob name of the file in the current directory. Blob names do not start with the delimiter
+func (s *Storage) objectName(fileName string) string {
+	return strings.TrimPrefix(path.Join(s.prefix, fileName), "/")
+}
+
+func fixPrefix(prefix string) string {
+	if prefix != "" && prefix[len(prefix)-1] != '/' {
+		prefix = prefix + "/"
+	}
+	return prefix
+}
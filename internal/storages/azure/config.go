@@ -0,0 +1,91 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	defaultBlockSize   = 8 * 1024 * 1024
+	defaultConcurrency = 4
+)
+
+var (
+	ErrContainerIsRequired  = errors.New("container is required")
+	ErrAccountIsRequired    = errors.New("account_name or endpoint is required")
+	ErrAmbiguousCredentials = errors.New("only one of connection_string, account_key and sas_token can be set")
+)
+
+// Config - Azure Blob Storage config. The credentials are chosen in the following order: connection_string,
+// account_key (shared key), sas_token, managed identity if use_managed_identity is set and the default Azure
+// credential chain otherwise (environment, workload identity, managed identity and Azure CLI)
+type Config struct {
+	Container string `mapstructure:"container"`
+	Prefix    string `mapstructure:"prefix"`
+	// AccountName - storage account name. It is used for building the endpoint and for the shared key auth
+	AccountName string `mapstructure:"account_name"`
+	// Endpoint - custom service URL. For instance, http://localhost:10000/devstoreaccount1 for Azurite. By default,
+	// https://<account_name>.blob.core.windows.net/ is used
+	Endpoint         string `mapstructure:"endpoint"`
+	AccountKey       string `mapstructure:"account_key"`
+	SasToken         string `mapstructure:"sas_token"`
+	ConnectionString string `mapstructure:"connection_string"`
+	// UseManagedIdentity - authenticate with the managed identity of the host
+	UseManagedIdentity bool `mapstructure:"use_managed_identity"`
+	// ManagedIdentityClientId - client id of the user-assigned managed identity
+	ManagedIdentityClientId string `mapstructure:"managed_identity_client_id"`
+	// BlockSize - size of the block for the uploads
+	BlockSize int64 `mapstructure:"block_size"`
+	// Concurrency - number of the blocks uploaded in parallel
+	Concurrency int `mapstructure:"concurrency"`
+}
+
+func NewConfig() *Config {
+	return &Config{
+		BlockSize:   defaultBlockSize,
+		Concurrency: defaultConcurrency,
+	}
+}
+
+func (c *Config) Validate() error {
+	if c.Container == "" {
+		return ErrContainerIsRequired
+	}
+	var credentials int
+	for _, v := range []string{c.ConnectionString, c.AccountKey, c.SasToken} {
+		if v != "" {
+			credentials++
+		}
+	}
+	if credentials > 1 {
+		return ErrAmbiguousCredentials
+	}
+	if c.ConnectionString == "" && c.AccountName == "" && c.Endpoint == "" {
+		return ErrAccountIsRequired
+	}
+	if c.AccountKey != "" && c.AccountName == "" {
+		return fmt.Errorf("account_name is required for account_key auth")
+	}
+	return nil
+}
+
+func (c *Config) getServiceUrl() string {
+	if c.Endpoint != "" {
+		return c.Endpoint
+	}
+	return fmt.Sprintf("https://%s.blob.core.windows.net/", c.AccountName)
+}
@@ -20,19 +20,41 @@ import (
 
 	"github.com/greenmaskio/greenmask/internal/domains"
 	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/storages/azure"
 	"github.com/greenmaskio/greenmask/internal/storages/directory"
+	"github.com/greenmaskio/greenmask/internal/storages/encrypted"
+	"github.com/greenmaskio/greenmask/internal/storages/gcs"
 	"github.com/greenmaskio/greenmask/internal/storages/s3"
+	"github.com/greenmaskio/greenmask/internal/storages/sftp"
 )
 
 const (
 	DirectoryStorageType = "directory"
 	S3StorageType        = "s3"
+	GcsStorageType       = "gcs"
+	AzureStorageType     = "azure"
+	SftpStorageType      = "sftp"
 )
 
 func GetStorage(ctx context.Context, stCfg *domains.StorageConfig, logCgf *domains.LogConfig) (
 	storages.Storager, error,
 ) {
+	if err := stCfg.Encryption.Validate(); err != nil {
+		return nil, fmt.Errorf("encryption config validation failed: %w", err)
+	}
+	st, err := getStorage(ctx, stCfg, logCgf)
+	if err != nil {
+		return nil, err
+	}
+	if !stCfg.Encryption.Enabled() {
+		return st, nil
+	}
+	return encrypted.NewStorage(ctx, st, stCfg.Encryption)
+}
 
+func getStorage(ctx context.Context, stCfg *domains.StorageConfig, logCgf *domains.LogConfig) (
+	storages.Storager, error,
+) {
 	switch stCfg.Type {
 	case DirectoryStorageType:
 		if err := stCfg.Directory.Validate(); err != nil {
@@ -41,6 +63,21 @@ func GetStorage(ctx context.Context, stCfg *domains.StorageConfig, logCgf *domai
 		return directory.NewStorage(stCfg.Directory)
 	case S3StorageType:
 		return s3.NewStorage(ctx, stCfg.S3, logCgf.Level)
+	case GcsStorageType:
+		if err := stCfg.Gcs.Validate(); err != nil {
+			return nil, fmt.Errorf("gcs storage config validation failed: %w", err)
+		}
+		return gcs.NewStorage(ctx, stCfg.Gcs)
+	case AzureStorageType:
+		if err := stCfg.Azure.Validate(); err != nil {
+			return nil, fmt.Errorf("azure storage config validation failed: %w", err)
+		}
+		return azure.NewStorage(ctx, stCfg.Azure, logCgf.Level)
+	case SftpStorageType:
+		if err := stCfg.Sftp.Validate(); err != nil {
+			return nil, fmt.Errorf("sftp storage config validation failed: %w", err)
+		}
+		return sftp.NewStorage(ctx, stCfg.Sftp)
 	}
 	return nil, fmt.Errorf("unknown storage type: %s", stCfg.Type)
 }
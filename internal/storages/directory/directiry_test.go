@@ -3,6 +3,7 @@ package directory
 import (
 	"bytes"
 	"context"
+	"io"
 	"os"
 	"testing"
 
@@ -38,6 +39,25 @@ func (suite *DirectorySuite) TestPutObject() {
 	suite.Require().NoError(err)
 }
 
+func (suite *DirectorySuite) TestGetObjectRange() {
+	err := suite.st.PutObject(context.Background(), "range.txt", bytes.NewBufferString("1234567890"))
+	suite.Require().NoError(err)
+
+	r, err := suite.st.GetObjectRange(context.Background(), "range.txt", 2, 3)
+	suite.Require().NoError(err)
+	data, err := io.ReadAll(r)
+	suite.Require().NoError(err)
+	suite.Require().NoError(r.Close())
+	suite.Equal("345", string(data))
+
+	r, err = suite.st.GetObjectRange(context.Background(), "range.txt", 7, -1)
+	suite.Require().NoError(err)
+	data, err = io.ReadAll(r)
+	suite.Require().NoError(err)
+	suite.Require().NoError(r.Close())
+	suite.Equal("890", string(data))
+}
+
 func (suite *DirectorySuite) TearDownSuite() {
 	if err := os.RemoveAll(suite.tmpDir); err != nil {
 		log.Warn().Err(err).Msg("error deleting tmp dir")
@@ -92,6 +92,19 @@ func (s *Storage) GetObject(ctx context.Context, filePath string) (reader io.Rea
 	return
 }
 
+// GetObjectRange - opens the file and seeks to the offset
+func (s *Storage) GetObjectRange(ctx context.Context, filePath string, offset, length int64) (io.ReadCloser, error) {
+	f, err := os.Open(path.Join(s.cwd, filePath))
+	if err != nil {
+		return nil, err
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("cannot seek file: %w", err)
+	}
+	return storages.LimitReadCloser(f, length), nil
+}
+
 func (s *Storage) PutObject(ctx context.Context, filePath string, body io.Reader) error {
 	_, err := os.Stat(path.Join(s.cwd, path.Dir(filePath)))
 	var errNo syscall.Errno
@@ -0,0 +1,281 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encrypted
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+)
+
+const (
+	aesGcmVersion   byte = 1
+	chunkSize            = 64 * 1024
+	noncePrefixSize      = 7
+)
+
+var aesGcmMagic = []byte("GMAESGCM")
+
+var ErrTruncated = errors.New("encrypted object is truncated")
+
+// AesGcmCipher - envelope encryption with AES-256-GCM. The data key is generated once per cipher instance and stored
+// wrapped by KeyWrapper in the header of each object. The object is encrypted in 64 KiB chunks (STREAM construction):
+// the nonce of the chunk consists of the random per-object prefix, the chunk counter and the last chunk flag, so
+// reordering, truncating or appending the chunks is detected.
+//
+// Object layout: magic | version | wrapped key length (uint16) | wrapped key | nonce prefix | chunks
+type AesGcmCipher struct {
+	keyWrapper KeyWrapper
+
+	mx        sync.Mutex
+	dataKey   *dataKey
+	unwrapped map[string]cipher.AEAD
+}
+
+type dataKey struct {
+	aead    cipher.AEAD
+	wrapped []byte
+}
+
+func NewAesGcmCipher(kw KeyWrapper) *AesGcmCipher {
+	return &AesGcmCipher{
+		keyWrapper: kw,
+		unwrapped:  make(map[string]cipher.AEAD),
+	}
+}
+
+func (c *AesGcmCipher) getDataKey(ctx context.Context) (*dataKey, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.dataKey != nil {
+		return c.dataKey, nil
+	}
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("cannot generate data key: %w", err)
+	}
+	wrapped, err := c.keyWrapper.WrapKey(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("cannot wrap data key: %w", err)
+	}
+	if len(wrapped) > math.MaxUint16 {
+		return nil, errors.New("wrapped data key is too long")
+	}
+	aead, err := newAead(key)
+	if err != nil {
+		return nil, err
+	}
+	c.dataKey = &dataKey{aead: aead, wrapped: wrapped}
+	return c.dataKey, nil
+}
+
+func (c *AesGcmCipher) unwrapDataKey(ctx context.Context, wrapped []byte) (cipher.AEAD, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if aead, ok := c.unwrapped[string(wrapped)]; ok {
+		return aead, nil
+	}
+	key, err := c.keyWrapper.UnwrapKey(ctx, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAead(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key: %w", err)
+	}
+	c.unwrapped[string(wrapped)] = aead
+	return aead, nil
+}
+
+func (c *AesGcmCipher) Encrypt(ctx context.Context, dst io.Writer) (io.WriteCloser, error) {
+	dk, err := c.getDataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	w := &aesGcmWriter{
+		w:    dst,
+		aead: dk.aead,
+		buf:  make([]byte, 0, chunkSize),
+		out:  make([]byte, 0, chunkSize+dk.aead.Overhead()),
+	}
+	if _, err = rand.Read(w.noncePrefix[:]); err != nil {
+		return nil, err
+	}
+
+	header := bytes.NewBuffer(nil)
+	header.Write(aesGcmMagic)
+	header.WriteByte(aesGcmVersion)
+	_ = binary.Write(header, binary.BigEndian, uint16(len(dk.wrapped)))
+	header.Write(dk.wrapped)
+	header.Write(w.noncePrefix[:])
+	if _, err = dst.Write(header.Bytes()); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (c *AesGcmCipher) Decrypt(ctx context.Context, src io.Reader) (io.Reader, error) {
+	magic := make([]byte, len(aesGcmMagic)+1)
+	n, err := io.ReadFull(src, magic)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if n < len(aesGcmMagic) || !bytes.Equal(magic[:len(aesGcmMagic)], aesGcmMagic) {
+		return nil, ErrNotEncrypted
+	}
+	if n != len(magic) || magic[len(aesGcmMagic)] != aesGcmVersion {
+		return nil, fmt.Errorf("unsupported aes-gcm object version")
+	}
+
+	var wrappedLen uint16
+	if err = binary.Read(src, binary.BigEndian, &wrappedLen); err != nil {
+		return nil, fmt.Errorf("cannot read header: %w", err)
+	}
+	wrapped := make([]byte, wrappedLen)
+	if _, err = io.ReadFull(src, wrapped); err != nil {
+		return nil, fmt.Errorf("cannot read header: %w", err)
+	}
+	r := &aesGcmReader{
+		r: bufio.NewReaderSize(src, chunkSize),
+	}
+	if _, err = io.ReadFull(src, r.noncePrefix[:]); err != nil {
+		return nil, fmt.Errorf("cannot read header: %w", err)
+	}
+
+	r.aead, err = c.unwrapDataKey(ctx, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	r.in = make([]byte, chunkSize+r.aead.Overhead())
+	r.plainBuf = make([]byte, 0, chunkSize)
+	return r, nil
+}
+
+func chunkNonce(prefix [noncePrefixSize]byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, noncePrefixSize+5)
+	copy(nonce, prefix[:])
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], counter)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+type aesGcmWriter struct {
+	w           io.Writer
+	aead        cipher.AEAD
+	noncePrefix [noncePrefixSize]byte
+	counter     uint32
+	buf         []byte
+	out         []byte
+	closed      bool
+}
+
+func (w *aesGcmWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed writer")
+	}
+	var n int
+	for len(p) > 0 {
+		// The full chunk is flushed only when there is more data, so the last chunk is marked on Close
+		if len(w.buf) == chunkSize {
+			if err := w.flush(false); err != nil {
+				return n, err
+			}
+		}
+		k := copy(w.buf[len(w.buf):chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+k]
+		p = p[k:]
+		n += k
+	}
+	return n, nil
+}
+
+func (w *aesGcmWriter) flush(last bool) error {
+	if w.counter == math.MaxUint32 {
+		return errors.New("object is too large")
+	}
+	w.out = w.aead.Seal(w.out[:0], chunkNonce(w.noncePrefix, w.counter, last), w.buf, nil)
+	w.counter++
+	w.buf = w.buf[:0]
+	_, err := w.w.Write(w.out)
+	return err
+}
+
+func (w *aesGcmWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.flush(true)
+}
+
+type aesGcmReader struct {
+	r           *bufio.Reader
+	aead        cipher.AEAD
+	noncePrefix [noncePrefixSize]byte
+	counter     uint32
+	in          []byte
+	plainBuf    []byte
+	plain       []byte
+	last        bool
+}
+
+func (r *aesGcmReader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.last {
+			return 0, io.EOF
+		}
+		if err := r.readChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+func (r *aesGcmReader) readChunk() error {
+	n, err := io.ReadFull(r.r, r.in)
+	switch {
+	case errors.Is(err, io.EOF):
+		return ErrTruncated
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		r.last = true
+	case err != nil:
+		return err
+	default:
+		if _, err = r.r.Peek(1); errors.Is(err, io.EOF) {
+			r.last = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	r.plain, err = r.aead.Open(r.plainBuf[:0], chunkNonce(r.noncePrefix, r.counter, r.last), r.in[:n], nil)
+	if err != nil {
+		return fmt.Errorf("cannot decrypt chunk %d: %w", r.counter, err)
+	}
+	r.counter++
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encrypted
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+var ageHeader = []byte("age-encryption.org/")
+
+// AgeCipher - encrypts the objects using age (https://age-encryption.org)
+type AgeCipher struct {
+	recipients []age.Recipient
+	identities []age.Identity
+}
+
+func NewAgeCipher(recipients []age.Recipient, identities []age.Identity) *AgeCipher {
+	return &AgeCipher{
+		recipients: recipients,
+		identities: identities,
+	}
+}
+
+func newAgeCipher(cfg *AgeConfig) (*AgeCipher, error) {
+	var recipients []age.Recipient
+	if len(cfg.Recipients) > 0 {
+		r, err := age.ParseRecipients(strings.NewReader(strings.Join(cfg.Recipients, "\n")))
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse recipients: %w", err)
+		}
+		recipients = append(recipients, r...)
+	}
+	if cfg.RecipientsFile != "" {
+		data, err := os.ReadFile(cfg.RecipientsFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read recipients file: %w", err)
+		}
+		r, err := age.ParseRecipients(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse recipients file: %w", err)
+		}
+		recipients = append(recipients, r...)
+	}
+
+	var identities []age.Identity
+	if cfg.IdentityFile != "" {
+		data, err := os.ReadFile(cfg.IdentityFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read identity file: %w", err)
+		}
+		ids, err := age.ParseIdentities(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse identity file: %w", err)
+		}
+		identities = append(identities, ids...)
+	}
+	if cfg.IdentityEnv != "" {
+		value, ok := os.LookupEnv(cfg.IdentityEnv)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s is not set", cfg.IdentityEnv)
+		}
+		ids, err := age.ParseIdentities(strings.NewReader(value))
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse identity from environment variable %s: %w", cfg.IdentityEnv, err)
+		}
+		identities = append(identities, ids...)
+	}
+
+	if len(recipients) == 0 {
+		for _, id := range identities {
+			if x25519, ok := id.(*age.X25519Identity); ok {
+				recipients = append(recipients, x25519.Recipient())
+			}
+		}
+	}
+
+	return NewAgeCipher(recipients, identities), nil
+}
+
+func (c *AgeCipher) Encrypt(ctx context.Context, dst io.Writer) (io.WriteCloser, error) {
+	if len(c.recipients) == 0 {
+		return nil, errors.New("age recipients are not set")
+	}
+	return age.Encrypt(dst, c.recipients...)
+}
+
+func (c *AgeCipher) Decrypt(ctx context.Context, src io.Reader) (io.Reader, error) {
+	if len(c.identities) == 0 {
+		return nil, errors.New("age identities are not set")
+	}
+	// age reads the header itself, so the prefix is peeked only to distinguish the plain objects
+	header := make([]byte, len(ageHeader))
+	n, err := io.ReadFull(src, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if !bytes.Equal(header[:n], ageHeader) {
+		return nil, ErrNotEncrypted
+	}
+	return age.Decrypt(io.MultiReader(bytes.NewReader(header), src), c.identities...)
+}
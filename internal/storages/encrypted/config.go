@@ -0,0 +1,106 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encrypted
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	AgeAlgorithm    = "age"
+	AesGcmAlgorithm = "aes-gcm"
+)
+
+var (
+	ErrAgeKeysAreRequired = errors.New("at least one of recipients, recipients_file, identity_file and identity_env is required")
+	ErrAmbiguousKeySource = errors.New("exactly one of key_file, key_env and kms must be set")
+)
+
+// Config - client side encryption config. The encryption is disabled when the algorithm is empty
+type Config struct {
+	Algorithm string        `mapstructure:"algorithm" json:"algorithm,omitempty" yaml:"algorithm"`
+	Age       *AgeConfig    `mapstructure:"age" json:"age,omitempty" yaml:"age"`
+	AesGcm    *AesGcmConfig `mapstructure:"aes_gcm" json:"aes_gcm,omitempty" yaml:"aes_gcm"`
+}
+
+// AgeConfig - the recipients are used for encryption and the identities for decryption. If only the identities are
+// set, the recipients are derived from them
+type AgeConfig struct {
+	Recipients     []string `mapstructure:"recipients" json:"recipients,omitempty" yaml:"recipients"`
+	RecipientsFile string   `mapstructure:"recipients_file" json:"recipients_file,omitempty" yaml:"recipients_file"`
+	IdentityFile   string   `mapstructure:"identity_file" json:"identity_file,omitempty" yaml:"identity_file"`
+	// IdentityEnv - name of the environment variable that contains the identity
+	IdentityEnv string `mapstructure:"identity_env" json:"identity_env,omitempty" yaml:"identity_env"`
+}
+
+// AesGcmConfig - the objects are encrypted with the data key that is wrapped by the key encryption key (KEK). KEK is
+// the base64 encoded 256-bit key from the file or the environment variable, or it is managed by the KMS
+type AesGcmConfig struct {
+	KeyFile string `mapstructure:"key_file" json:"key_file,omitempty" yaml:"key_file"`
+	// KeyEnv - name of the environment variable that contains the key
+	KeyEnv string     `mapstructure:"key_env" json:"key_env,omitempty" yaml:"key_env"`
+	Kms    *KmsConfig `mapstructure:"kms" json:"kms,omitempty" yaml:"kms"`
+}
+
+// KmsConfig - the commands wrap and unwrap the data key using the KMS. The command receives the key in stdin and
+// writes the result into stdout
+type KmsConfig struct {
+	WrapCommand   []string `mapstructure:"wrap_command" json:"wrap_command,omitempty" yaml:"wrap_command"`
+	UnwrapCommand []string `mapstructure:"unwrap_command" json:"unwrap_command,omitempty" yaml:"unwrap_command"`
+}
+
+func NewConfig() *Config {
+	return &Config{
+		Age:    &AgeConfig{},
+		AesGcm: &AesGcmConfig{},
+	}
+}
+
+func (c *Config) Enabled() bool {
+	return c != nil && c.Algorithm != ""
+}
+
+func (c *Config) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	switch c.Algorithm {
+	case AgeAlgorithm:
+		if c.Age == nil ||
+			len(c.Age.Recipients) == 0 && c.Age.RecipientsFile == "" && c.Age.IdentityFile == "" && c.Age.IdentityEnv == "" {
+			return ErrAgeKeysAreRequired
+		}
+	case AesGcmAlgorithm:
+		if c.AesGcm == nil {
+			return ErrAmbiguousKeySource
+		}
+		sources := 0
+		for _, set := range []bool{c.AesGcm.KeyFile != "", c.AesGcm.KeyEnv != "", c.AesGcm.Kms != nil} {
+			if set {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return ErrAmbiguousKeySource
+		}
+		if c.AesGcm.Kms != nil && (len(c.AesGcm.Kms.WrapCommand) == 0 || len(c.AesGcm.Kms.UnwrapCommand) == 0) {
+			return errors.New("kms wrap_command and unwrap_command are required")
+		}
+	default:
+		return fmt.Errorf("unknown encryption algorithm \"%s\"", c.Algorithm)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encrypted
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const keySize = 32
+
+// KeyWrapper - wraps and unwraps the data keys. It is the extension point for the KMS
+type KeyWrapper interface {
+	WrapKey(ctx context.Context, key []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, wrappedKey []byte) ([]byte, error)
+}
+
+func newKeyWrapper(cfg *AesGcmConfig) (KeyWrapper, error) {
+	switch {
+	case cfg.KeyFile != "":
+		data, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read key file: %w", err)
+		}
+		key, err := decodeKey(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid key in file %s: %w", cfg.KeyFile, err)
+		}
+		return NewLocalKeyWrapper(key)
+	case cfg.KeyEnv != "":
+		value, ok := os.LookupEnv(cfg.KeyEnv)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s is not set", cfg.KeyEnv)
+		}
+		key, err := decodeKey(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key in environment variable %s: %w", cfg.KeyEnv, err)
+		}
+		return NewLocalKeyWrapper(key)
+	case cfg.Kms != nil:
+		return NewCommandKeyWrapper(cfg.Kms.WrapCommand, cfg.Kms.UnwrapCommand), nil
+	}
+	return nil, ErrAmbiguousKeySource
+}
+
+func decodeKey(value string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("key must be base64 encoded: %w", err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("key must be %d bytes long but got %d", keySize, len(key))
+	}
+	return key, nil
+}
+
+// LocalKeyWrapper - wraps the data keys with AES-256-GCM using the local key encryption key
+type LocalKeyWrapper struct {
+	aead cipher.AEAD
+}
+
+func NewLocalKeyWrapper(kek []byte) (*LocalKeyWrapper, error) {
+	aead, err := newAead(kek)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalKeyWrapper{aead: aead}, nil
+}
+
+func (w *LocalKeyWrapper) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	nonce := make([]byte, w.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return w.aead.Seal(nonce, nonce, key, nil), nil
+}
+
+func (w *LocalKeyWrapper) UnwrapKey(ctx context.Context, wrappedKey []byte) ([]byte, error) {
+	nonceSize := w.aead.NonceSize()
+	if len(wrappedKey) < nonceSize {
+		return nil, errors.New("wrapped key is too short")
+	}
+	key, err := w.aead.Open(nil, wrappedKey[:nonceSize], wrappedKey[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unwrap data key (wrong key?): %w", err)
+	}
+	return key, nil
+}
+
+// CommandKeyWrapper - wraps the data keys calling the external commands, for instance, the KMS CLI. The command
+// receives the key in stdin and writes the result into stdout
+type CommandKeyWrapper struct {
+	wrapCommand   []string
+	unwrapCommand []string
+}
+
+func NewCommandKeyWrapper(wrapCommand, unwrapCommand []string) *CommandKeyWrapper {
+	return &CommandKeyWrapper{
+		wrapCommand:   wrapCommand,
+		unwrapCommand: unwrapCommand,
+	}
+}
+
+func (w *CommandKeyWrapper) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	return runKeyCommand(ctx, w.wrapCommand, key)
+}
+
+func (w *CommandKeyWrapper) UnwrapKey(ctx context.Context, wrappedKey []byte) ([]byte, error) {
+	return runKeyCommand(ctx, w.unwrapCommand, wrappedKey)
+}
+
+func runKeyCommand(ctx context.Context, command []string, input []byte) ([]byte, error) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("key command %s failed: %w: %s", command[0], err, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("key command %s returned empty output", command[0])
+	}
+	return stdout.Bytes(), nil
+}
+
+func newAead(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,165 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encrypted
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/storages/domains"
+)
+
+var ErrNotEncrypted = errors.New("object is not encrypted")
+
+// Cipher - encrypts and decrypts the object streams
+type Cipher interface {
+	// Encrypt - returns the writer that encrypts the data into dst. The data is flushed on Close
+	Encrypt(ctx context.Context, dst io.Writer) (io.WriteCloser, error)
+	// Decrypt - returns the reader of the decrypted src
+	Decrypt(ctx context.Context, src io.Reader) (io.Reader, error)
+}
+
+// Storage - the decorator that encrypts every object written into the wrapped storage and decrypts the objects on
+// read. The object names and the directory structure are not changed
+type Storage struct {
+	st     storages.Storager
+	cipher Cipher
+}
+
+func NewStorage(ctx context.Context, st storages.Storager, cfg *Config) (*Storage, error) {
+	var (
+		c   Cipher
+		err error
+	)
+	switch cfg.Algorithm {
+	case AgeAlgorithm:
+		c, err = newAgeCipher(cfg.Age)
+	case AesGcmAlgorithm:
+		var kw KeyWrapper
+		kw, err = newKeyWrapper(cfg.AesGcm)
+		if err == nil {
+			c = NewAesGcmCipher(kw)
+		}
+	default:
+		return nil, fmt.Errorf("unknown encryption algorithm \"%s\"", cfg.Algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot initialize %s cipher: %w", cfg.Algorithm, err)
+	}
+	return Wrap(st, c), nil
+}
+
+// Wrap - wraps the storage with the provided cipher
+func Wrap(st storages.Storager, c Cipher) *Storage {
+	return &Storage{
+		st:     st,
+		cipher: c,
+	}
+}
+
+func (s *Storage) GetCwd() string {
+	return s.st.GetCwd()
+}
+
+func (s *Storage) Dirname() string {
+	return s.st.Dirname()
+}
+
+func (s *Storage) ListDir(ctx context.Context) (files []string, dirs []storages.Storager, err error) {
+	files, dirs, err = s.st.ListDir(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range dirs {
+		dirs[i] = Wrap(dirs[i], s.cipher)
+	}
+	return files, dirs, nil
+}
+
+func (s *Storage) GetObject(ctx context.Context, filePath string) (reader io.ReadCloser, err error) {
+	rc, err := s.st.GetObject(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.cipher.Decrypt(ctx, rc)
+	if err != nil {
+		if closeErr := rc.Close(); closeErr != nil {
+			err = errors.Join(err, closeErr)
+		}
+		return nil, fmt.Errorf("cannot decrypt object %s: %w", filePath, err)
+	}
+	return &readCloser{Reader: r, Closer: rc}, nil
+}
+
+// PutObject - encrypts the body on the fly and streams it into the wrapped storage
+func (s *Storage) PutObject(ctx context.Context, filePath string, body io.Reader) error {
+	pr, pw := io.Pipe()
+	eg, gtx := errgroup.WithContext(ctx)
+	eg.Go(func() error {
+		err := s.encrypt(gtx, pw, body)
+		_ = pw.CloseWithError(err)
+		return err
+	})
+	eg.Go(func() error {
+		err := s.st.PutObject(gtx, filePath, pr)
+		_ = pr.CloseWithError(err)
+		return err
+	})
+	if err := eg.Wait(); err != nil {
+		return fmt.Errorf("cannot put encrypted object %s: %w", filePath, err)
+	}
+	return nil
+}
+
+func (s *Storage) encrypt(ctx context.Context, dst io.Writer, body io.Reader) error {
+	w, err := s.cipher.Encrypt(ctx, dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(w, body); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func (s *Storage) Delete(ctx context.Context, filePaths ...string) error {
+	return s.st.Delete(ctx, filePaths...)
+}
+
+func (s *Storage) DeleteAll(ctx context.Context, pathPrefix string) error {
+	return s.st.DeleteAll(ctx, pathPrefix)
+}
+
+func (s *Storage) Exists(ctx context.Context, fileName string) (bool, error) {
+	return s.st.Exists(ctx, fileName)
+}
+
+func (s *Storage) SubStorage(subPath string, relative bool) storages.Storager {
+	return Wrap(s.st.SubStorage(subPath, relative), s.cipher)
+}
+
+func (s *Storage) Stat(fileName string) (*domains.ObjectStat, error) {
+	return s.st.Stat(fileName)
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,226 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encrypted
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/storages/directory"
+)
+
+func newDirectoryStorage(t *testing.T) (storages.Storager, string) {
+	dir := t.TempDir()
+	st, err := directory.NewStorage(&directory.Config{Path: dir})
+	require.NoError(t, err)
+	return st, dir
+}
+
+func newKey(t *testing.T) string {
+	key := make([]byte, keySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func readObject(t *testing.T, st storages.Storager, name string) []byte {
+	r, err := st.GetObject(context.Background(), name)
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	return data
+}
+
+func TestStorage_AesGcm(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("GREENMASK_TEST_KEY", newKey(t))
+	cfg := &Config{Algorithm: AesGcmAlgorithm, AesGcm: &AesGcmConfig{KeyEnv: "GREENMASK_TEST_KEY"}}
+	require.NoError(t, cfg.Validate())
+
+	plain, dir := newDirectoryStorage(t)
+	st, err := NewStorage(ctx, plain, cfg)
+	require.NoError(t, err)
+
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, chunkSize*3 + 17} {
+		data := make([]byte, size)
+		_, err = rand.Read(data)
+		require.NoError(t, err)
+
+		require.NoError(t, st.PutObject(ctx, "1/tables/1.dat.gz", bytes.NewReader(data)))
+		raw, err := os.ReadFile(path.Join(dir, "1", "tables", "1.dat.gz"))
+		require.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(raw, aesGcmMagic))
+		if size > 16 {
+			assert.False(t, bytes.Contains(raw, data))
+		}
+
+		// Reading through the sub storage from the listing
+		_, dirs, err := st.ListDir(ctx)
+		require.NoError(t, err)
+		require.Len(t, dirs, 1)
+		assert.Equal(t, data, readObject(t, dirs[0].SubStorage("tables", true), "1.dat.gz"), "size %d", size)
+	}
+
+	t.Run("another instance with the same key", func(t *testing.T) {
+		st2, err := NewStorage(ctx, plain, cfg)
+		require.NoError(t, err)
+		require.NoError(t, st.PutObject(ctx, "metadata.json", bytes.NewBufferString("{}")))
+		assert.Equal(t, "{}", string(readObject(t, st2, "metadata.json")))
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		t.Setenv("GREENMASK_TEST_KEY", newKey(t))
+		st2, err := NewStorage(ctx, plain, cfg)
+		require.NoError(t, err)
+		_, err = st2.GetObject(ctx, "metadata.json")
+		require.ErrorContains(t, err, "cannot unwrap data key")
+	})
+
+	t.Run("tampered and truncated object", func(t *testing.T) {
+		data := bytes.Repeat([]byte{'a'}, chunkSize*2)
+		require.NoError(t, st.PutObject(ctx, "data.bin", bytes.NewReader(data)))
+		raw, err := os.ReadFile(path.Join(dir, "data.bin"))
+		require.NoError(t, err)
+
+		tampered := bytes.Clone(raw)
+		tampered[len(tampered)-20] ^= 1
+		require.NoError(t, plain.PutObject(ctx, "data.bin", bytes.NewReader(tampered)))
+		r, err := st.GetObject(ctx, "data.bin")
+		require.NoError(t, err)
+		_, err = io.ReadAll(r)
+		require.ErrorContains(t, err, "cannot decrypt chunk 1")
+
+		// Dropping the last chunk
+		require.NoError(t, plain.PutObject(ctx, "data.bin", bytes.NewReader(raw[:len(raw)-chunkSize-16])))
+		r, err = st.GetObject(ctx, "data.bin")
+		require.NoError(t, err)
+		_, err = io.ReadAll(r)
+		require.ErrorContains(t, err, "cannot decrypt chunk 0")
+	})
+
+	t.Run("plain object", func(t *testing.T) {
+		require.NoError(t, plain.PutObject(ctx, "plain.json", bytes.NewBufferString("{}")))
+		_, err = st.GetObject(ctx, "plain.json")
+		require.ErrorIs(t, err, ErrNotEncrypted)
+	})
+}
+
+func TestStorage_Age(t *testing.T) {
+	ctx := context.Background()
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	identityFile := path.Join(t.TempDir(), "key.txt")
+	require.NoError(t, os.WriteFile(identityFile, []byte("# test key\n"+identity.String()+"\n"), 0600))
+
+	plain, dir := newDirectoryStorage(t)
+
+	// The dump requires only the recipient
+	dumpSt, err := NewStorage(ctx, plain, &Config{
+		Algorithm: AgeAlgorithm,
+		Age:       &AgeConfig{Recipients: []string{identity.Recipient().String()}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, dumpSt.PutObject(ctx, "toc.dat", bytes.NewBufferString("toc data")))
+	_, err = dumpSt.GetObject(ctx, "toc.dat")
+	require.ErrorContains(t, err, "age identities are not set")
+
+	raw, err := os.ReadFile(path.Join(dir, "toc.dat"))
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(raw, ageHeader))
+
+	restoreSt, err := NewStorage(ctx, plain, &Config{
+		Algorithm: AgeAlgorithm,
+		Age:       &AgeConfig{IdentityFile: identityFile},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "toc data", string(readObject(t, restoreSt, "toc.dat")))
+
+	// The recipient is derived from the identity
+	require.NoError(t, restoreSt.PutObject(ctx, "metadata.json", bytes.NewBufferString("{}")))
+	assert.Equal(t, "{}", string(readObject(t, restoreSt, "metadata.json")))
+
+	require.NoError(t, plain.PutObject(ctx, "plain.json", bytes.NewBufferString("{}")))
+	_, err = restoreSt.GetObject(ctx, "plain.json")
+	require.ErrorIs(t, err, ErrNotEncrypted)
+}
+
+func TestCommandKeyWrapper(t *testing.T) {
+	if _, err := exec.LookPath("base64"); err != nil {
+		t.Skip("base64 command is not found")
+	}
+	ctx := context.Background()
+	cfg := &Config{
+		Algorithm: AesGcmAlgorithm,
+		AesGcm: &AesGcmConfig{Kms: &KmsConfig{
+			WrapCommand:   []string{"base64"},
+			UnwrapCommand: []string{"base64", "-d"},
+		}},
+	}
+	require.NoError(t, cfg.Validate())
+
+	plain, _ := newDirectoryStorage(t)
+	st, err := NewStorage(ctx, plain, cfg)
+	require.NoError(t, err)
+	require.NoError(t, st.PutObject(ctx, "metadata.json", bytes.NewBufferString("{}")))
+
+	st2, err := NewStorage(ctx, plain, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "{}", string(readObject(t, st2, "metadata.json")))
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *Config
+		err  string
+	}{
+		{name: "disabled", cfg: NewConfig()},
+		{name: "unknown algorithm", cfg: &Config{Algorithm: "des"}, err: "unknown encryption algorithm"},
+		{name: "age without keys", cfg: &Config{Algorithm: AgeAlgorithm, Age: &AgeConfig{}}, err: ErrAgeKeysAreRequired.Error()},
+		{
+			name: "aes-gcm with two key sources",
+			cfg:  &Config{Algorithm: AesGcmAlgorithm, AesGcm: &AesGcmConfig{KeyFile: "key", KeyEnv: "KEY"}},
+			err:  ErrAmbiguousKeySource.Error(),
+		},
+		{
+			name: "aes-gcm without key source",
+			cfg:  &Config{Algorithm: AesGcmAlgorithm, AesGcm: &AesGcmConfig{}},
+			err:  ErrAmbiguousKeySource.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.err)
+		})
+	}
+}
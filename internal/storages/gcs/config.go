@@ -0,0 +1,77 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcs
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	defaultChunkSize  = 16 * 1024 * 1024
+	defaultMaxRetries = 5
+	// chunkSizeGranularity - the size of the resumable upload chunk must be a multiple of 256 KiB
+	chunkSizeGranularity = 256 * 1024
+)
+
+var (
+	ErrBucketIsRequired     = errors.New("bucket is required")
+	ErrAmbiguousCredentials = errors.New("only one of credentials_file and credentials_json can be set")
+)
+
+// Config - Google Cloud Storage config. If neither credentials_file nor credentials_json is set, the Application
+// Default Credentials are used (GOOGLE_APPLICATION_CREDENTIALS, gcloud user credentials, workload identity or the
+// metadata server of the compute instance)
+type Config struct {
+	Bucket string `mapstructure:"bucket"`
+	Prefix string `mapstructure:"prefix"`
+	// Endpoint - custom API endpoint without the API path. For instance, http://localhost:4443 for fake-gcs-server
+	Endpoint string `mapstructure:"endpoint"`
+	// CredentialsFile - path to the service account JSON key file
+	CredentialsFile string `mapstructure:"credentials_file"`
+	// CredentialsJson - content of the service account JSON key
+	CredentialsJson string `mapstructure:"credentials_json"`
+	// WithoutAuthentication - do not authenticate requests. It is used for the emulators and the public buckets
+	WithoutAuthentication bool   `mapstructure:"without_authentication"`
+	StorageClass          string `mapstructure:"storage_class"`
+	// ChunkSize - size of the chunk for the resumable uploads. Objects that are smaller than the chunk are uploaded
+	// in a single request
+	ChunkSize int `mapstructure:"chunk_size"`
+	// MaxRetries - the number of retries of the requests failed with 429 Too Many Requests or 5xx status
+	MaxRetries int `mapstructure:"max_retries"`
+}
+
+func NewConfig() *Config {
+	return &Config{
+		ChunkSize:  defaultChunkSize,
+		MaxRetries: defaultMaxRetries,
+	}
+}
+
+func (c *Config) Validate() error {
+	if c.Bucket == "" {
+		return ErrBucketIsRequired
+	}
+	if c.CredentialsFile != "" && c.CredentialsJson != "" {
+		return ErrAmbiguousCredentials
+	}
+	if c.ChunkSize <= 0 || c.ChunkSize%chunkSizeGranularity != 0 {
+		return fmt.Errorf("chunk_size must be a positive multiple of %d", chunkSizeGranularity)
+	}
+	if c.MaxRetries < 0 {
+		return errors.New("max_retries must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,626 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+
+	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/storages/domains"
+)
+
+const (
+	DefaultGcsObjectsDelimiter = "/"
+	DefaultEndpoint            = "https://storage.googleapis.com"
+	readWriteScope             = "https://www.googleapis.com/auth/devstorage.read_write"
+)
+
+const (
+	// defaultRetryDelay - the delay before the first retry. It is doubled on each next retry up to maxRetryDelay
+	defaultRetryDelay = time.Second
+	maxRetryDelay     = 32 * time.Second
+)
+
+var errObjectNotFound = errors.New("not found")
+
+// apiError - error response of the Cloud Storage JSON API
+type apiError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("gcs api error: status %d: %s", e.StatusCode, e.Message)
+}
+
+type object struct {
+	Name    string    `json:"name"`
+	Updated time.Time `json:"updated"`
+}
+
+type listObjectsResponse struct {
+	Items         []*object `json:"items"`
+	Prefixes      []string  `json:"prefixes"`
+	NextPageToken string    `json:"nextPageToken"`
+}
+
+// Storage - Google Cloud Storage implementation that uses the Cloud Storage JSON API. The objects are uploaded
+// using the resumable uploads, so the size of the object does not need to be known in advance
+type Storage struct {
+	config     *Config
+	client     *http.Client
+	apiUrl     string
+	uploadUrl  string
+	prefix     string
+	retryDelay time.Duration
+}
+
+func NewStorage(ctx context.Context, cfg *Config) (*Storage, error) {
+	client, err := newHttpClient(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create gcs client: %w", err)
+	}
+
+	endpoint := DefaultEndpoint
+	if cfg.Endpoint != "" {
+		endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	}
+
+	log.Debug().
+		Str("endpoint", endpoint).
+		Str("bucket", cfg.Bucket).
+		Msg("gcs storage bucket")
+
+	return &Storage{
+		config:     cfg,
+		client:     client,
+		apiUrl:     fmt.Sprintf("%s/storage/v1/b/%s/o", endpoint, url.PathEscape(cfg.Bucket)),
+		uploadUrl:  fmt.Sprintf("%s/upload/storage/v1/b/%s/o", endpoint, url.PathEscape(cfg.Bucket)),
+		prefix:     fixPrefix(strings.TrimPrefix(cfg.Prefix, "/")),
+		retryDelay: defaultRetryDelay,
+	}, nil
+}
+
+func newHttpClient(ctx context.Context, cfg *Config) (*http.Client, error) {
+	if cfg.WithoutAuthentication {
+		return &http.Client{}, nil
+	}
+
+	var creds *google.Credentials
+	var err error
+	switch {
+	case cfg.CredentialsFile != "":
+		data, err := os.ReadFile(cfg.CredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read credentials file: %w", err)
+		}
+		creds, err = google.CredentialsFromJSON(ctx, data, readWriteScope)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse credentials file: %w", err)
+		}
+	case cfg.CredentialsJson != "":
+		creds, err = google.CredentialsFromJSON(ctx, []byte(cfg.CredentialsJson), readWriteScope)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse credentials json: %w", err)
+		}
+	default:
+		creds, err = google.FindDefaultCredentials(ctx, readWriteScope)
+		if err != nil {
+			return nil, fmt.Errorf("cannot find default credentials: %w", err)
+		}
+	}
+	return oauth2.NewClient(ctx, creds.TokenSource), nil
+}
+
+func (s *Storage) GetCwd() string {
+	return s.prefix
+}
+
+func (s *Storage) Dirname() string {
+	return filepath.Base(s.prefix)
+}
+
+func (s *Storage) ListDir(ctx context.Context) (files []string, dirs []storages.Storager, err error) {
+	var pageToken string
+	for {
+		query := url.Values{}
+		query.Set("prefix", s.prefix)
+		query.Set("delimiter", DefaultGcsObjectsDelimiter)
+		query.Set("fields", "items(name,updated),prefixes,nextPageToken")
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+
+		page := &listObjectsResponse{}
+		if err = s.doJson(ctx, http.MethodGet, s.apiUrl+"?"+query.Encode(), page); err != nil {
+			return nil, nil, fmt.Errorf("error listing gcs objects: %w", err)
+		}
+		for _, prefix := range page.Prefixes {
+			dirs = append(dirs, s.SubStorage(prefix, false))
+		}
+		for _, obj := range page.Items {
+			files = append(files, strings.TrimPrefix(obj.Name, s.prefix))
+		}
+
+		if page.NextPageToken == "" {
+			break
+		}
+		pageToken = page.NextPageToken
+	}
+	return
+}
+
+func (s *Storage) GetObject(ctx context.Context, filePath string) (reader io.ReadCloser, err error) {
+	return s.getObject(ctx, filePath, "")
+}
+
+// GetObjectRange - downloads the part of the object using the Range header
+func (s *Storage) GetObjectRange(ctx context.Context, filePath string, offset, length int64) (io.ReadCloser, error) {
+	return s.getObject(ctx, filePath, storages.FormatHttpRange(offset, length))
+}
+
+func (s *Storage) getObject(ctx context.Context, filePath, objectRange string) (io.ReadCloser, error) {
+	expectedStatus := http.StatusOK
+	if objectRange != "" {
+		expectedStatus = http.StatusPartialContent
+	}
+	resp, err := s.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectUrl(filePath)+"?alt=media", nil)
+		if err != nil {
+			return nil, err
+		}
+		if objectRange != "" {
+			req.Header.Set("Range", objectRange)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting object: %w", err)
+	}
+	if err = checkResponse(resp, expectedStatus); err != nil {
+		if errors.Is(err, errObjectNotFound) {
+			return nil, storages.ErrFileNotFound
+		}
+		return nil, fmt.Errorf("error getting object: %w", err)
+	}
+	return resp.Body, nil
+}
+
+// PutObject - uploads the object using the resumable upload. The body is sent in chunks of the configured size
+func (s *Storage) PutObject(ctx context.Context, filePath string, body io.Reader) error {
+	sessionUrl, err := s.startResumableUpload(ctx, s.objectName(filePath))
+	if err != nil {
+		return fmt.Errorf("gcs object uploading error: %w", err)
+	}
+
+	buf := make([]byte, s.config.ChunkSize)
+	var offset int64
+	for {
+		n, err := io.ReadFull(body, buf)
+		last := errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+		if err != nil && !last {
+			return fmt.Errorf("error reading object data: %w", err)
+		}
+
+		done, err := s.uploadChunk(ctx, sessionUrl, buf[:n], offset, last)
+		if err != nil {
+			return fmt.Errorf("gcs object uploading error: %w", err)
+		}
+		offset += int64(n)
+		if done {
+			return nil
+		}
+		if last {
+			return fmt.Errorf("gcs object uploading error: upload is not finalized")
+		}
+	}
+}
+
+func (s *Storage) startResumableUpload(ctx context.Context, name string) (string, error) {
+	query := url.Values{}
+	query.Set("uploadType", "resumable")
+	query.Set("name", name)
+
+	metadata := map[string]string{"name": name}
+	if s.config.StorageClass != "" {
+		metadata["storageClass"] = s.config.StorageClass
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := s.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(
+			ctx, http.MethodPost, s.uploadUrl+"?"+query.Encode(), bytes.NewReader(data),
+		)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+		return req, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot start resumable upload: %w", err)
+	}
+	defer closeBody(resp)
+	if err = checkResponse(resp, http.StatusOK); err != nil {
+		return "", fmt.Errorf("cannot start resumable upload: %w", err)
+	}
+	sessionUrl := resp.Header.Get("Location")
+	if sessionUrl == "" {
+		return "", fmt.Errorf("cannot start resumable upload: empty session url")
+	}
+	return sessionUrl, nil
+}
+
+// uploadChunk - sends the chunk of the resumable upload that starts at the offset of the object. The server may
+// persist only a part of the chunk, so the rest of it is sent again. If the request fails with the retryable status,
+// or the network error, the persisted size is queried and the upload is resumed from it. It returns true when the
+// upload is finalized
+func (s *Storage) uploadChunk(ctx context.Context, sessionUrl string, chunk []byte, offset int64, last bool) (bool, error) {
+	end := offset + int64(len(chunk))
+	persisted := offset
+	for attempt := 0; ; {
+		data := chunk[persisted-offset:]
+		// The total size is unknown until the body is read to the end
+		var contentRange string
+		switch {
+		case len(data) == 0:
+			contentRange = fmt.Sprintf("bytes */%d", end)
+		case last:
+			contentRange = fmt.Sprintf("bytes %d-%d/%d", persisted, end-1, end)
+		default:
+			contentRange = fmt.Sprintf("bytes %d-%d/*", persisted, end-1)
+		}
+		done, size, err := s.putChunk(ctx, sessionUrl, data, contentRange, false)
+		if err != nil {
+			var apiErr *apiError
+			retryable := isRetryableError(err) || errors.As(err, &apiErr) && isRetryableStatus(apiErr.StatusCode)
+			if !retryable || attempt >= s.config.MaxRetries {
+				return false, fmt.Errorf("cannot upload chunk: %w", err)
+			}
+			log.Debug().
+				Err(err).
+				Int64("Offset", persisted).
+				Int("Attempt", attempt+1).
+				Msg("gcs chunk upload failed: retrying from the persisted size")
+			if err = s.wait(ctx, attempt, nil); err != nil {
+				return false, err
+			}
+			// The chunk may be persisted partially before the failure, so the upload is resumed from the size
+			// committed by the session
+			done, size, err = s.putChunk(ctx, sessionUrl, nil, "bytes */*", true)
+			if err != nil {
+				return false, fmt.Errorf("cannot get upload status: %w", err)
+			}
+		}
+		if done {
+			return true, nil
+		}
+		if size < persisted || size > end {
+			return false, fmt.Errorf("unexpected persisted size %d: expected from %d to %d", size, persisted, end)
+		}
+		if size == end && (!last || len(data) == 0) {
+			return false, nil
+		}
+		if size == persisted {
+			// Nothing is persisted since the previous attempt
+			attempt++
+		}
+		persisted = size
+	}
+}
+
+// putChunk - sends the data of the resumable upload. It returns true when the upload is finalized, otherwise the
+// number of the persisted bytes of the object is returned. If retry is true the request is retried on the retryable
+// statuses and network errors, it must be used only for the status requests without data
+func (s *Storage) putChunk(
+	ctx context.Context, sessionUrl string, data []byte, contentRange string, retry bool,
+) (bool, int64, error) {
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, sessionUrl, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.ContentLength = int64(len(data))
+		req.Header.Set("Content-Range", contentRange)
+		return req, nil
+	}
+	var resp *http.Response
+	var err error
+	if retry {
+		resp, err = s.do(ctx, newRequest)
+	} else {
+		var req *http.Request
+		if req, err = newRequest(); err != nil {
+			return false, 0, err
+		}
+		resp, err = s.client.Do(req)
+	}
+	if err != nil {
+		return false, 0, err
+	}
+	defer closeBody(resp)
+	// 308 Resume Incomplete - the data is accepted and the upload waits for the next chunk
+	if resp.StatusCode == http.StatusPermanentRedirect {
+		size, err := parsePersistedSize(resp.Header.Get("Range"))
+		return false, size, err
+	}
+	if err = checkResponse(resp, http.StatusOK, http.StatusCreated); err != nil {
+		return false, 0, err
+	}
+	return true, 0, nil
+}
+
+func (s *Storage) Delete(ctx context.Context, filePaths ...string) error {
+	for _, fp := range filePaths {
+		resp, err := s.do(ctx, func() (*http.Request, error) {
+			return http.NewRequestWithContext(ctx, http.MethodDelete, s.objectUrl(fp), nil)
+		})
+		if err != nil {
+			return fmt.Errorf("error deleting object %s: %w", fp, err)
+		}
+		err = checkResponse(resp, http.StatusNoContent, http.StatusOK)
+		closeBody(resp)
+		if err != nil && !errors.Is(err, errObjectNotFound) {
+			return fmt.Errorf("error deleting object %s: %w", fp, err)
+		}
+	}
+	return nil
+}
+
+func (s *Storage) DeleteAll(ctx context.Context, pathPrefix string) error {
+	pathPrefix = fixPrefix(pathPrefix)
+	ss := s.SubStorage(pathPrefix, true)
+	filesList, err := storages.Walk(ctx, ss, "")
+	if err != nil {
+		return fmt.Errorf("error walking through storage: %w", err)
+	}
+
+	if err = ss.Delete(ctx, filesList...); err != nil {
+		return fmt.Errorf("error deleting files: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) SubStorage(subPath string, relative bool) storages.Storager {
+	prefix := subPath
+	if relative {
+		prefix = path.Join(s.prefix, subPath)
+	}
+	return &Storage{
+		config:     s.config,
+		client:     s.client,
+		apiUrl:     s.apiUrl,
+		uploadUrl:  s.uploadUrl,
+		prefix:     fixPrefix(strings.TrimPrefix(prefix, "/")),
+		retryDelay: s.retryDelay,
+	}
+}
+
+func (s *Storage) Exists(ctx context.Context, fileName string) (bool, error) {
+	_, err := s.getObjectMetadata(ctx, fileName)
+	if err != nil {
+		if errors.Is(err, errObjectNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error getting object info: %w", err)
+	}
+	return true, nil
+}
+
+func (s *Storage) Stat(fileName string) (*domains.ObjectStat, error) {
+	fullPath := s.objectName(fileName)
+	obj, err := s.getObjectMetadata(context.Background(), fileName)
+	if err != nil {
+		if errors.Is(err, errObjectNotFound) {
+			return &domains.ObjectStat{
+				Name:         fullPath,
+				LastModified: time.Time{},
+				Exist:        false,
+			}, nil
+		}
+		return nil, fmt.Errorf("error getting object info: %w", err)
+	}
+	return &domains.ObjectStat{
+		Name:         fullPath,
+		LastModified: obj.Updated,
+		Exist:        true,
+	}, nil
+}
+
+func (s *Storage) getObjectMetadata(ctx context.Context, fileName string) (*object, error) {
+	obj := &object{}
+	if err := s.doJson(ctx, http.MethodGet, s.objectUrl(fileName)+"?fields=name,updated", obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// doJson - performs the request without body and decodes the JSON response into dest
+func (s *Storage) doJson(ctx context.Context, method, reqUrl string, dest any) error {
+	resp, err := s.do(ctx, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, method, reqUrl, nil)
+	})
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp)
+	if err = checkResponse(resp, http.StatusOK); err != nil {
+		return err
+	}
+	if err = json.NewDecoder(resp.Body).Decode(dest); err != nil {
+		return fmt.Errorf("cannot decode response: %w", err)
+	}
+	return nil
+}
+
+// do - performs the request created by newRequest. The request is retried with the exponential backoff if the
+// response status is 429 Too Many Requests or 5xx or the request fails with the network error
+func (s *Storage) do(ctx context.Context, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.client.Do(req)
+		if err != nil {
+			if !isRetryableError(err) || attempt >= s.config.MaxRetries {
+				return nil, err
+			}
+			log.Debug().
+				Err(err).
+				Str("Method", req.Method).
+				Str("Url", req.URL.Redacted()).
+				Int("Attempt", attempt+1).
+				Msg("gcs request failed: retrying")
+			if err = s.wait(ctx, attempt, nil); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if !isRetryableStatus(resp.StatusCode) || attempt >= s.config.MaxRetries {
+			return resp, nil
+		}
+		closeBody(resp)
+		log.Debug().
+			Str("Method", req.Method).
+			Str("Url", req.URL.Redacted()).
+			Int("StatusCode", resp.StatusCode).
+			Int("Attempt", attempt+1).
+			Msg("gcs request failed: retrying")
+		if err = s.wait(ctx, attempt, resp); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// wait - sleeps before the retry. The delay is taken from the Retry-After header of the response if it is set,
+// otherwise the exponential delay with the jitter is used
+func (s *Storage) wait(ctx context.Context, attempt int, resp *http.Response) error {
+	delay := min(s.retryDelay<<attempt, maxRetryDelay)
+	delay = delay/2 + rand.N(delay/2+1)
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			delay = min(time.Duration(seconds)*time.Second, maxRetryDelay)
+		}
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// isRetryableError - returns true if the request failed with the network error, for instance, the connection is
+// reset or the response is interrupted. The cancelled requests and the other client errors are not retried
+func isRetryableError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	// The client wraps all the errors into url.Error, that implements net.Error itself
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// parsePersistedSize - returns the number of the persisted bytes from the Range header of the resumable upload
+// status. The header is not set if no bytes are persisted
+func parsePersistedSize(header string) (int64, error) {
+	if header == "" {
+		return 0, nil
+	}
+	_, last, ok := strings.Cut(strings.TrimPrefix(header, "bytes="), "-")
+	if !ok {
+		return 0, fmt.Errorf("invalid upload range header \"%s\"", header)
+	}
+	n, err := strconv.ParseInt(last, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid upload range header \"%s\": %w", header, err)
+	}
+	return n + 1, nil
+}
+
+func (s *Storage) objectUrl(fileName string) string {
+	return fmt.Sprintf("%s/%s", s.apiUrl, url.PathEscape(s.objectName(fileName)))
+}
+
+// objectName - returns the object name of the file in the current directory. Object names do not start with the
+// delimiter
+func (s *Storage) objectName(fileName string) string {
+	return strings.TrimPrefix(path.Join(s.prefix, fileName), "/")
+}
+
+// checkResponse - returns error if the response status is not one of the expected. The body of the unexpected
+// response is read and closed
+func checkResponse(resp *http.Response, expected ...int) error {
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			return nil
+		}
+	}
+	defer closeBody(resp)
+	if resp.StatusCode == http.StatusNotFound {
+		return errObjectNotFound
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return &apiError{
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(msg)),
+	}
+}
+
+func closeBody(resp *http.Response) {
+	if err := resp.Body.Close(); err != nil {
+		log.Warn().Err(err).Msg("error closing gcs response body")
+	}
+}
+
+func fixPrefix(prefix string) string {
+	if prefix != "" && prefix[len(prefix)-1] != '/' {
+		prefix = prefix + "/"
+	}
+	return prefix
+}
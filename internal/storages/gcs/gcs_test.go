@@ -0,0 +1,385 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/storages"
+)
+
+// fakeGcsServer - minimal in-memory implementation of the Cloud Storage JSON API used by the storage
+type fakeGcsServer struct {
+	mx       sync.Mutex
+	objects  map[string][]byte
+	sessions map[string]*bytes.Buffer
+	chunks   int
+	// failures - the number of the next requests that fail with failureStatus
+	failures      int
+	failureStatus int
+	// dropConnection - the failed requests are interrupted by closing the connection instead of the failureStatus
+	dropConnection bool
+	// persistFailed - the data of the failed upload requests is persisted
+	persistFailed bool
+	// persistLimit - the max number of the bytes persisted by one upload request. It is not limited if zero
+	persistLimit int
+}
+
+func newFakeGcsServer() *fakeGcsServer {
+	return &fakeGcsServer{
+		objects:  make(map[string][]byte),
+		sessions: make(map[string]*bytes.Buffer),
+	}
+}
+
+func (f *fakeGcsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	const objectsPath = "/storage/v1/b/testbucket/o"
+	if f.failures > 0 {
+		f.failures--
+		if buf, ok := f.sessions[r.URL.Query().Get("name")]; ok && f.persistFailed && r.URL.Path == "/session" {
+			_, _ = io.Copy(buf, r.Body)
+		}
+		if f.dropConnection {
+			conn, _, err := http.NewResponseController(w).Hijack()
+			if err == nil {
+				_ = conn.Close()
+			}
+			return
+		}
+		w.WriteHeader(f.failureStatus)
+		return
+	}
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/upload/storage/v1/b/testbucket/o":
+		name := r.URL.Query().Get("name")
+		f.sessions[name] = &bytes.Buffer{}
+		w.Header().Set("Location", fmt.Sprintf("http://%s/session?name=%s", r.Host, url.QueryEscape(name)))
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPut && r.URL.Path == "/session":
+		f.upload(w, r)
+	case r.Method == http.MethodGet && r.URL.Path == objectsPath:
+		f.list(w, r.URL.Query().Get("prefix"), r.URL.Query().Get("delimiter"))
+	case strings.HasPrefix(r.URL.Path, objectsPath+"/"):
+		name := strings.TrimPrefix(r.URL.Path, objectsPath+"/")
+		data, ok := f.objects[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch {
+		case r.Method == http.MethodDelete:
+			delete(f.objects, name)
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Query().Get("alt") == "media":
+			http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+		default:
+			_ = json.NewEncoder(w).Encode(&object{Name: name, Updated: time.Unix(1700000000, 0).UTC()})
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// upload - handles the resumable upload request. The data must start at the end of the persisted data
+func (f *fakeGcsServer) upload(w http.ResponseWriter, r *http.Request) {
+	f.chunks++
+	name := r.URL.Query().Get("name")
+	buf, ok := f.sessions[name]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	data, _ := io.ReadAll(r.Body)
+	dataRange, total, _ := strings.Cut(strings.TrimPrefix(r.Header.Get("Content-Range"), "bytes "), "/")
+	if dataRange != "*" {
+		if !strings.HasPrefix(dataRange, fmt.Sprintf("%d-", buf.Len())) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if f.persistLimit > 0 && len(data) > f.persistLimit {
+			data = data[:f.persistLimit]
+		}
+		buf.Write(data)
+	}
+	if total == fmt.Sprint(buf.Len()) {
+		f.objects[name] = buf.Bytes()
+		delete(f.sessions, name)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if buf.Len() > 0 {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", buf.Len()-1))
+	}
+	w.WriteHeader(http.StatusPermanentRedirect)
+}
+
+func (f *fakeGcsServer) list(w http.ResponseWriter, prefix, delimiter string) {
+	res := &listObjectsResponse{}
+	prefixes := make(map[string]struct{})
+	for name := range f.objects {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(name, prefix)
+		if idx := strings.Index(rest, delimiter); idx != -1 {
+			prefixes[prefix+rest[:idx+1]] = struct{}{}
+			continue
+		}
+		res.Items = append(res.Items, &object{Name: name})
+	}
+	for p := range prefixes {
+		res.Prefixes = append(res.Prefixes, p)
+	}
+	sort.Strings(res.Prefixes)
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func newTestStorage(t *testing.T, prefix string) (*Storage, *fakeGcsServer) {
+	srv := newFakeGcsServer()
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+
+	cfg := NewConfig()
+	cfg.Bucket = "testbucket"
+	cfg.Prefix = prefix
+	cfg.Endpoint = ts.URL
+	cfg.WithoutAuthentication = true
+	cfg.ChunkSize = chunkSizeGranularity
+	require.NoError(t, cfg.Validate())
+
+	st, err := NewStorage(context.Background(), cfg)
+	require.NoError(t, err)
+	st.retryDelay = time.Millisecond
+	return st, srv
+}
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	st, srv := newTestStorage(t, "/dumps")
+
+	t.Run("put and get object", func(t *testing.T) {
+		require.NoError(t, st.PutObject(ctx, "/test.txt", bytes.NewBufferString("1234567890")))
+		assert.Contains(t, srv.objects, "dumps/test.txt")
+
+		r, err := st.GetObject(ctx, "test.txt")
+		require.NoError(t, err)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		assert.Equal(t, "1234567890", string(data))
+
+		_, err = st.GetObject(ctx, "unknown.txt")
+		assert.ErrorIs(t, err, storages.ErrFileNotFound)
+	})
+
+	t.Run("get object range", func(t *testing.T) {
+		for _, tt := range []struct {
+			offset, length int64
+			expected       string
+		}{
+			{offset: 2, length: 3, expected: "345"},
+			{offset: 7, length: -1, expected: "890"},
+		} {
+			r, err := st.GetObjectRange(ctx, "test.txt", tt.offset, tt.length)
+			require.NoError(t, err)
+			data, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.NoError(t, r.Close())
+			assert.Equal(t, tt.expected, string(data))
+		}
+	})
+
+	t.Run("multi chunk upload", func(t *testing.T) {
+		for _, size := range []int{chunkSizeGranularity * 2, chunkSizeGranularity*2 + 10, 0} {
+			srv.chunks = 0
+			data := bytes.Repeat([]byte{'a'}, size)
+			require.NoError(t, st.PutObject(ctx, "big.bin", bytes.NewReader(data)))
+			assert.Equal(t, data, append([]byte{}, srv.objects["dumps/big.bin"]...))
+			assert.Equal(t, size/chunkSizeGranularity+1, srv.chunks)
+		}
+	})
+
+	t.Run("list dir and sub storage", func(t *testing.T) {
+		require.NoError(t, st.PutObject(ctx, "1/metadata.json", bytes.NewBufferString("{}")))
+		require.NoError(t, st.PutObject(ctx, "1/tables/1.dat.gz", bytes.NewBufferString("data")))
+
+		files, dirs, err := st.ListDir(ctx)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"test.txt", "big.bin"}, files)
+		require.Len(t, dirs, 1)
+		assert.Equal(t, "1", dirs[0].Dirname())
+		assert.Equal(t, "dumps/1/", dirs[0].GetCwd())
+
+		files, dirs, err = dirs[0].ListDir(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"metadata.json"}, files)
+		require.Len(t, dirs, 1)
+		assert.Equal(t, "dumps/1/tables/", dirs[0].GetCwd())
+
+		sub := st.SubStorage("1", true)
+		exists, err := sub.Exists(ctx, "metadata.json")
+		require.NoError(t, err)
+		assert.True(t, exists)
+	})
+
+	t.Run("stat", func(t *testing.T) {
+		stat, err := st.Stat("test.txt")
+		require.NoError(t, err)
+		assert.True(t, stat.Exist)
+		assert.Equal(t, "dumps/test.txt", stat.Name)
+		assert.Equal(t, time.Unix(1700000000, 0).UTC(), stat.LastModified)
+
+		stat, err = st.Stat("unknown.txt")
+		require.NoError(t, err)
+		assert.False(t, stat.Exist)
+	})
+
+	t.Run("delete all", func(t *testing.T) {
+		require.NoError(t, st.DeleteAll(ctx, "1"))
+		assert.NotContains(t, srv.objects, "dumps/1/metadata.json")
+		assert.NotContains(t, srv.objects, "dumps/1/tables/1.dat.gz")
+		assert.Contains(t, srv.objects, "dumps/test.txt")
+
+		require.NoError(t, st.Delete(ctx, "test.txt", "unknown.txt"))
+		exists, err := st.Exists(ctx, "test.txt")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
+
+func TestStorage_retries(t *testing.T) {
+	ctx := context.Background()
+	st, srv := newTestStorage(t, "dumps")
+	data := bytes.Repeat([]byte("0123456789"), chunkSizeGranularity/4)
+
+	t.Run("request", func(t *testing.T) {
+		srv.failures, srv.failureStatus = 2, http.StatusTooManyRequests
+		require.NoError(t, st.PutObject(ctx, "test.txt", bytes.NewBufferString("1234567890")))
+		srv.failures, srv.failureStatus = 2, http.StatusServiceUnavailable
+		r, err := st.GetObject(ctx, "test.txt")
+		require.NoError(t, err)
+		res, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		assert.Equal(t, "1234567890", string(res))
+	})
+
+	t.Run("retries are exhausted", func(t *testing.T) {
+		srv.failures, srv.failureStatus = st.config.MaxRetries+1, http.StatusInternalServerError
+		_, err := st.Exists(ctx, "test.txt")
+		var apiErr *apiError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+		srv.failures = 0
+
+		// The client errors are not retried
+		srv.failures, srv.failureStatus = 2, http.StatusForbidden
+		_, err = st.Exists(ctx, "test.txt")
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, 1, srv.failures)
+		srv.failures = 0
+	})
+
+	for _, persistFailed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("upload chunk persisted %t", persistFailed), func(t *testing.T) {
+			srv.failureStatus, srv.persistFailed = http.StatusServiceUnavailable, persistFailed
+			defer func() { srv.persistFailed = false }()
+			// The session is started before the body is read, so the first chunk upload fails
+			r := &onReadReader{r: bytes.NewReader(data), onRead: func() {
+				srv.mx.Lock()
+				srv.failures = 1
+				srv.mx.Unlock()
+			}}
+			require.NoError(t, st.PutObject(ctx, "big.bin", r))
+			assert.Equal(t, data, srv.objects["dumps/big.bin"])
+		})
+	}
+
+	t.Run("network errors", func(t *testing.T) {
+		srv.dropConnection = true
+		defer func() { srv.dropConnection = false }()
+		srv.failures = 2
+		exists, err := st.Exists(ctx, "test.txt")
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		// The transport may resend the idempotent request on the reused connection itself, so the failures are
+		// not limited by the number of retries
+		srv.failures = 1000
+		_, err = st.Exists(ctx, "test.txt")
+		require.Error(t, err)
+		assert.True(t, isRetryableError(err))
+		srv.failures = 0
+
+		for _, persistFailed := range []bool{false, true} {
+			srv.persistFailed = persistFailed
+			r := &onReadReader{r: bytes.NewReader(data), onRead: func() {
+				srv.mx.Lock()
+				srv.failures = 1
+				srv.mx.Unlock()
+			}}
+			require.NoError(t, st.PutObject(ctx, "big.bin", r))
+			assert.Equal(t, data, srv.objects["dumps/big.bin"])
+		}
+		srv.persistFailed = false
+	})
+
+	t.Run("cancelled request", func(t *testing.T) {
+		cancelledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := st.Exists(cancelledCtx, "test.txt")
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.False(t, isRetryableError(err))
+	})
+
+	t.Run("partially persisted chunk", func(t *testing.T) {
+		srv.persistLimit = 100000
+		defer func() { srv.persistLimit = 0 }()
+		require.NoError(t, st.PutObject(ctx, "big.bin", bytes.NewReader(data)))
+		assert.Equal(t, data, srv.objects["dumps/big.bin"])
+	})
+}
+
+// onReadReader - calls onRead before the first read
+type onReadReader struct {
+	r      io.Reader
+	onRead func()
+}
+
+func (r *onReadReader) Read(p []byte) (int, error) {
+	if r.onRead != nil {
+		r.onRead()
+		r.onRead = nil
+	}
+	return r.r.Read(p)
+}
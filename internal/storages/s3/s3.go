@@ -246,10 +246,20 @@ func (s *Storage) ListDir(ctx context.Context) (files []string, dirs []storages.
 }
 
 func (s *Storage) GetObject(ctx context.Context, filePath string) (writer io.ReadCloser, err error) {
+	return s.getObject(ctx, filePath, nil)
+}
+
+// GetObjectRange - gets the part of the object using the Range header
+func (s *Storage) GetObjectRange(ctx context.Context, filePath string, offset, length int64) (io.ReadCloser, error) {
+	return s.getObject(ctx, filePath, aws.String(storages.FormatHttpRange(offset, length)))
+}
+
+func (s *Storage) getObject(ctx context.Context, filePath string, objectRange *string) (io.ReadCloser, error) {
 	obj, err := s.service.GetObjectWithContext(
 		ctx, &s3.GetObjectInput{
 			Bucket: aws.String(s.config.Bucket),
 			Key:    aws.String(path.Join(s.prefix, filePath)),
+			Range:  objectRange,
 		},
 	)
 	if err != nil {
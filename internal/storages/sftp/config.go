@@ -0,0 +1,75 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sftp
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	defaultPort    = 22
+	defaultTimeout = 30 * time.Second
+)
+
+var (
+	ErrHostIsRequired = errors.New("host is required")
+	ErrUserIsRequired = errors.New("user is required")
+	ErrPathIsRequired = errors.New("path is required")
+	ErrAuthIsRequired = errors.New("password, private_key or private_key_file is required")
+)
+
+// Config - SFTP storage config. The private key and the password auth methods can be used together. The host key is
+// verified using the known_hosts file, by default ~/.ssh/known_hosts
+type Config struct {
+	Host string `mapstructure:"host"`
+	Port int    `mapstructure:"port"`
+	User string `mapstructure:"user"`
+	// Path - the directory on the server where the dumps are stored
+	Path     string `mapstructure:"path"`
+	Password string `mapstructure:"password"`
+	// PrivateKeyFile - path to the PEM encoded private key file
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+	// PrivateKey - content of the PEM encoded private key
+	PrivateKey           string `mapstructure:"private_key"`
+	PrivateKeyPassphrase string `mapstructure:"private_key_passphrase"`
+	KnownHostsFile       string `mapstructure:"known_hosts_file"`
+	// InsecureIgnoreHostKey - do not verify the server host key. Must not be used in production
+	InsecureIgnoreHostKey bool          `mapstructure:"insecure_ignore_host_key"`
+	Timeout               time.Duration `mapstructure:"timeout"`
+}
+
+func NewConfig() *Config {
+	return &Config{
+		Port:    defaultPort,
+		Timeout: defaultTimeout,
+	}
+}
+
+func (c *Config) Validate() error {
+	if c.Host == "" {
+		return ErrHostIsRequired
+	}
+	if c.User == "" {
+		return ErrUserIsRequired
+	}
+	if c.Path == "" {
+		return ErrPathIsRequired
+	}
+	if c.Password == "" && c.PrivateKey == "" && c.PrivateKeyFile == "" {
+		return ErrAuthIsRequired
+	}
+	return nil
+}
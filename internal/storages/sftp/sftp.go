@@ -0,0 +1,348 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/storages/domains"
+)
+
+const (
+	posixRenameExtension = "posix-rename@openssh.com"
+	tmpFileSuffix        = ".tmp"
+)
+
+// Storage - SFTP storage. The objects are uploaded into the temporary files that are renamed after the upload is
+// completed, so the readers never see the partially written objects
+type Storage struct {
+	client *sftp.Client
+	cwd    string
+}
+
+func NewStorage(ctx context.Context, cfg *Config) (*Storage, error) {
+	sshCfg, err := newSshClientConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to %s: %w", addr, err)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, sshCfg)
+	if err != nil {
+		// The connection might be already closed by the failed handshake
+		if err := conn.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Warn().Err(err).Msg("error closing sftp connection")
+		}
+		return nil, fmt.Errorf("ssh handshake error: %w", err)
+	}
+	sshClient := ssh.NewClient(sshConn, chans, reqs)
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		if err := sshClient.Close(); err != nil {
+			log.Warn().Err(err).Msg("error closing ssh client")
+		}
+		return nil, fmt.Errorf("cannot start sftp session: %w", err)
+	}
+
+	log.Debug().
+		Str("addr", addr).
+		Str("path", cfg.Path).
+		Msg("sftp storage directory")
+
+	return newStorage(client, cfg.Path), nil
+}
+
+func newStorage(client *sftp.Client, cwd string) *Storage {
+	return &Storage{
+		client: client,
+		cwd:    cwd,
+	}
+}
+
+func newSshClientConfig(cfg *Config) (*ssh.ClientConfig, error) {
+	var auth []ssh.AuthMethod
+	if cfg.PrivateKey != "" || cfg.PrivateKeyFile != "" {
+		key := []byte(cfg.PrivateKey)
+		if cfg.PrivateKeyFile != "" {
+			var err error
+			key, err = os.ReadFile(cfg.PrivateKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read private key file: %w", err)
+			}
+		}
+		var signer ssh.Signer
+		var err error
+		if cfg.PrivateKeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(cfg.PrivateKeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse private key: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if cfg.Password != "" {
+		auth = append(auth, ssh.Password(cfg.Password))
+	}
+
+	hostKeyCallback, err := newHostKeyCallback(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ssh.ClientConfig{
+		User:            cfg.User,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         cfg.Timeout,
+	}, nil
+}
+
+func newHostKeyCallback(cfg *Config) (ssh.HostKeyCallback, error) {
+	if cfg.InsecureIgnoreHostKey {
+		log.Warn().Msg("sftp storage: host key verification is disabled")
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	knownHostsFile := cfg.KnownHostsFile
+	if knownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("cannot get home directory for known_hosts file: %w", err)
+		}
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	cb, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read known_hosts file: %w", err)
+	}
+	return cb, nil
+}
+
+func (s *Storage) GetCwd() string {
+	return s.cwd
+}
+
+func (s *Storage) Dirname() string {
+	return filepath.Base(s.cwd)
+}
+
+func (s *Storage) ListDir(ctx context.Context) (files []string, dirs []storages.Storager, err error) {
+	entries, err := s.client.ReadDir(s.cwd)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error listing directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, s.SubStorage(entry.Name(), true))
+		} else if !isTmpFile(entry.Name()) {
+			files = append(files, entry.Name())
+		}
+	}
+	return
+}
+
+func (s *Storage) GetObject(ctx context.Context, filePath string) (reader io.ReadCloser, err error) {
+	f, err := s.client.Open(path.Join(s.cwd, filePath))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, storages.ErrFileNotFound
+		}
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	return f, nil
+}
+
+// GetObjectRange - opens the file and seeks to the offset
+func (s *Storage) GetObjectRange(ctx context.Context, filePath string, offset, length int64) (io.ReadCloser, error) {
+	f, err := s.client.Open(path.Join(s.cwd, filePath))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, storages.ErrFileNotFound
+		}
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("cannot seek file: %w", err)
+	}
+	return storages.LimitReadCloser(f, length), nil
+}
+
+// PutObject - writes the data into the temporary file in the same directory and renames it to the target name
+func (s *Storage) PutObject(ctx context.Context, filePath string, body io.Reader) error {
+	fullPath := path.Join(s.cwd, filePath)
+	if err := s.client.MkdirAll(path.Dir(fullPath)); err != nil {
+		return fmt.Errorf("error creating directory: %w", err)
+	}
+
+	tmpPath := path.Join(path.Dir(fullPath), fmt.Sprintf(".%s.%d%s", path.Base(fullPath), time.Now().UnixNano(), tmpFileSuffix))
+	if err := s.writeFile(ctx, tmpPath, body); err != nil {
+		s.removeTmpFile(tmpPath)
+		return err
+	}
+	if err := s.rename(tmpPath, fullPath); err != nil {
+		s.removeTmpFile(tmpPath)
+		return fmt.Errorf("error renaming temporary file: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) writeFile(ctx context.Context, filePath string, body io.Reader) error {
+	f, err := s.client.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("unable to create file: %w", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		_, err = f.ReadFrom(body)
+		close(done)
+	}()
+
+	select {
+	case <-ctx.Done():
+		if err := f.Close(); err != nil {
+			log.Warn().Err(err).Msg("error closing file")
+		}
+		<-done
+		return ctx.Err()
+	case <-done:
+	}
+
+	if err != nil {
+		if err := f.Close(); err != nil {
+			log.Warn().Err(err).Msg("error closing file")
+		}
+		return fmt.Errorf("error writing data: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("error closing file: %w", err)
+	}
+	return nil
+}
+
+// rename - renames the file replacing the existing one. The posix-rename extension does it atomically. Otherwise,
+// the existing file is removed before renaming because SSH_FXP_RENAME fails if the target exists
+func (s *Storage) rename(oldPath, newPath string) error {
+	if _, ok := s.client.HasExtension(posixRenameExtension); ok {
+		return s.client.PosixRename(oldPath, newPath)
+	}
+	if err := s.client.Remove(newPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("cannot remove existing file: %w", err)
+	}
+	return s.client.Rename(oldPath, newPath)
+}
+
+func (s *Storage) removeTmpFile(tmpPath string) {
+	if err := s.client.Remove(tmpPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warn().Err(err).Str("path", tmpPath).Msg("cannot remove temporary file")
+	}
+}
+
+func (s *Storage) Delete(ctx context.Context, filePaths ...string) error {
+	for _, fp := range filePaths {
+		fullPath := path.Join(s.cwd, fp)
+		fileInfo, err := s.client.Stat(fullPath)
+		if err != nil {
+			return fmt.Errorf("error getting file stat %s: %w", fp, err)
+		}
+		if fileInfo.IsDir() {
+			err = s.client.RemoveAll(fullPath)
+		} else {
+			err = s.client.Remove(fullPath)
+		}
+		if err != nil {
+			return fmt.Errorf("error deleting %s: %w", fp, err)
+		}
+	}
+	return nil
+}
+
+func (s *Storage) DeleteAll(ctx context.Context, pathPrefix string) error {
+	fullPath := path.Join(s.cwd, pathPrefix)
+	if _, err := s.client.Stat(fullPath); err != nil {
+		return fmt.Errorf("error getting file stat %s: %w", pathPrefix, err)
+	}
+	if err := s.client.RemoveAll(fullPath); err != nil {
+		return fmt.Errorf("error deleting %s: %w", pathPrefix, err)
+	}
+	return nil
+}
+
+func (s *Storage) Exists(ctx context.Context, fileName string) (bool, error) {
+	_, err := s.client.Stat(path.Join(s.cwd, fileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Storage) SubStorage(subPath string, relative bool) storages.Storager {
+	dirPath := subPath
+	if relative {
+		dirPath = path.Join(s.cwd, subPath)
+	}
+	return newStorage(s.client, dirPath)
+}
+
+func (s *Storage) Stat(fileName string) (*domains.ObjectStat, error) {
+	fullPath := path.Join(s.cwd, fileName)
+	fileInfo, err := s.client.Stat(fullPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &domains.ObjectStat{
+				Name:         fullPath,
+				LastModified: time.Time{},
+				Exist:        false,
+			}, nil
+		}
+		return nil, fmt.Errorf("error getting file stat: %w", err)
+	}
+	return &domains.ObjectStat{
+		Name:         fullPath,
+		LastModified: fileInfo.ModTime(),
+		Exist:        true,
+	}, nil
+}
+
+// isTmpFile - checks that the file is the temporary file of the upload in progress
+func isTmpFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, tmpFileSuffix)
+}
@@ -0,0 +1,240 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sftp
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/greenmaskio/greenmask/internal/storages"
+)
+
+// newTestStorage - runs the sftp server over the pipes. The server works with the local file system, so the storage
+// points to the temporary directory
+func newTestStorage(t *testing.T) (*Storage, string) {
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+
+	server, err := sftp.NewServer(struct {
+		io.Reader
+		io.WriteCloser
+	}{serverReader, serverWriter})
+	require.NoError(t, err)
+	go func() {
+		_ = server.Serve()
+	}()
+
+	client, err := sftp.NewClientPipe(clientReader, clientWriter)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+
+	dir := t.TempDir()
+	return newStorage(client, dir), dir
+}
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	st, dir := newTestStorage(t)
+
+	t.Run("put and get object", func(t *testing.T) {
+		require.NoError(t, st.PutObject(ctx, "1/metadata.json", bytes.NewBufferString("{}")))
+		// Overwriting the existing file
+		require.NoError(t, st.PutObject(ctx, "1/metadata.json", bytes.NewBufferString(`{"a": 1}`)))
+
+		data, err := os.ReadFile(path.Join(dir, "1", "metadata.json"))
+		require.NoError(t, err)
+		assert.Equal(t, `{"a": 1}`, string(data))
+
+		r, err := st.GetObject(ctx, "1/metadata.json")
+		require.NoError(t, err)
+		data, err = io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		assert.Equal(t, `{"a": 1}`, string(data))
+
+		_, err = st.GetObject(ctx, "1/unknown.json")
+		assert.ErrorIs(t, err, storages.ErrFileNotFound)
+	})
+
+	t.Run("failed upload does not leave files", func(t *testing.T) {
+		err := st.PutObject(ctx, "1/broken.dat", iotest.ErrReader(errors.New("test error")))
+		require.Error(t, err)
+
+		entries, err := os.ReadDir(path.Join(dir, "1"))
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "metadata.json", entries[0].Name())
+	})
+
+	t.Run("list dir", func(t *testing.T) {
+		require.NoError(t, st.PutObject(ctx, "1/tables/1.dat.gz", bytes.NewBufferString("data")))
+		require.NoError(t, os.WriteFile(path.Join(dir, "1", ".2.dat.gz.1.tmp"), nil, 0600))
+
+		files, dirs, err := st.ListDir(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, files)
+		require.Len(t, dirs, 1)
+		assert.Equal(t, "1", dirs[0].Dirname())
+
+		files, dirs, err = dirs[0].ListDir(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"metadata.json"}, files)
+		require.Len(t, dirs, 1)
+		assert.Equal(t, path.Join(dir, "1", "tables"), dirs[0].GetCwd())
+	})
+
+	t.Run("exists and stat", func(t *testing.T) {
+		sub := st.SubStorage("1", true)
+		exists, err := sub.Exists(ctx, "metadata.json")
+		require.NoError(t, err)
+		assert.True(t, exists)
+		exists, err = sub.Exists(ctx, "unknown.json")
+		require.NoError(t, err)
+		assert.False(t, exists)
+
+		stat, err := sub.Stat("metadata.json")
+		require.NoError(t, err)
+		assert.True(t, stat.Exist)
+		assert.False(t, stat.LastModified.IsZero())
+		stat, err = sub.Stat("unknown.json")
+		require.NoError(t, err)
+		assert.False(t, stat.Exist)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, st.SubStorage("1", true).Delete(ctx, "metadata.json"))
+		_, err := os.Stat(path.Join(dir, "1", "metadata.json"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+
+		require.NoError(t, st.DeleteAll(ctx, "1"))
+		_, err = os.Stat(path.Join(dir, "1"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestNewStorage_handshake_error_closes_connection(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	closed := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			closed <- err
+			return
+		}
+		defer conn.Close()
+		// The valid version followed by the too large packet fails the handshake
+		_, _ = conn.Write([]byte("SSH-2.0-test\r\n\xff\xff\xff\xff\x00\x00\x00\x00"))
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, err = io.Copy(io.Discard, conn)
+		closed <- err
+	}()
+
+	_, err = NewStorage(context.Background(), newListenerConfig(ln))
+	require.ErrorContains(t, err, "ssh handshake error")
+	// The client closes the connection, so the server reads io.EOF instead of the deadline error
+	require.NoError(t, <-closed)
+}
+
+func TestNewStorage_sftp_session_error_closes_ssh_client(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+	serverCfg := &ssh.ServerConfig{
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	serverCfg.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	closed := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			closed <- err
+			return
+		}
+		defer conn.Close()
+		sshConn, chans, reqs, err := ssh.NewServerConn(conn, serverCfg)
+		if err != nil {
+			closed <- err
+			return
+		}
+		go ssh.DiscardRequests(reqs)
+		go func() {
+			// The sftp subsystem is not supported by the server
+			for ch := range chans {
+				channel, requests, err := ch.Accept()
+				if err != nil {
+					continue
+				}
+				go func() {
+					for req := range requests {
+						_ = req.Reply(false, nil)
+					}
+					_ = channel.Close()
+				}()
+			}
+		}()
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		// Wait returns io.EOF when the client closes the connection
+		err = sshConn.Wait()
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+		closed <- err
+	}()
+
+	_, err = NewStorage(context.Background(), newListenerConfig(ln))
+	require.ErrorContains(t, err, "cannot start sftp session")
+	require.NoError(t, <-closed)
+}
+
+func newListenerConfig(ln net.Listener) *Config {
+	addr := ln.Addr().(*net.TCPAddr)
+	cfg := NewConfig()
+	cfg.Host = addr.IP.String()
+	cfg.Port = addr.Port
+	cfg.User = "test"
+	cfg.Path = "/"
+	cfg.Password = "test"
+	cfg.InsecureIgnoreHostKey = true
+	return cfg
+}
@@ -44,3 +44,11 @@ type Storager interface {
 	// Stat - get the metadata info about object from the storage
 	Stat(fileName string) (*domains.ObjectStat, error)
 }
+
+// RangeReader - the storage that is able to read the part of the object. The objects bundled into the tar dump are
+// read with the ranged reads if the storage supports them, so the archive is not streamed from the beginning
+type RangeReader interface {
+	// GetObjectRange - returns ReadCloser of length bytes of the object starting from offset. The negative length
+	// means the object is read up to the end
+	GetObjectRange(ctx context.Context, filePath string, offset, length int64) (io.ReadCloser, error)
+}
@@ -0,0 +1,236 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tar
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/greenmaskio/greenmask/internal/storages"
+)
+
+// blockSize - the size of the tar block. The file data is padded to the block size
+const blockSize = 512
+
+// entry - the regular file of the archive
+type entry struct {
+	name string
+	hdr  *tar.Header
+	// offset - the offset of the file data in the archive
+	offset int64
+}
+
+// index - the headers of the archive files and the offsets of their data. The headers are read once and shared by the
+// sub storages of the archive. They are read lazily up to the requested file, so the files placed at the beginning of
+// the archive are found without reading the rest of it
+type index struct {
+	st       storages.Storager
+	fileName string
+
+	mx      sync.Mutex
+	entries []*entry
+	names   map[string]*entry
+	// next - the offset of the next header that has not been read yet
+	next     int64
+	complete bool
+}
+
+func newIndex(st storages.Storager, fileName string) *index {
+	return &index{
+		st:       st,
+		fileName: fileName,
+		names:    make(map[string]*entry),
+	}
+}
+
+// lookup - returns the entry of the file or nil if the archive does not contain it
+func (idx *index) lookup(ctx context.Context, name string) (*entry, error) {
+	idx.mx.Lock()
+	defer idx.mx.Unlock()
+	if e, ok := idx.names[name]; ok || idx.complete {
+		return e, nil
+	}
+	if err := idx.read(ctx, name); err != nil {
+		return nil, err
+	}
+	return idx.names[name], nil
+}
+
+// all - returns the entries of all regular files of the archive in the archive order
+func (idx *index) all(ctx context.Context) ([]*entry, error) {
+	idx.mx.Lock()
+	defer idx.mx.Unlock()
+	if !idx.complete {
+		if err := idx.read(ctx, ""); err != nil {
+			return nil, err
+		}
+	}
+	return idx.entries, nil
+}
+
+// read - reads the headers starting from the next unread one until the file stop is found or the archive ends. The
+// data of the files is skipped
+func (idx *index) read(ctx context.Context, stop string) error {
+	r := newArchiveReader(ctx, idx.st, idx.fileName, idx.next)
+	defer func() {
+		if err := r.Close(); err != nil {
+			log.Warn().Err(err).Str("FileName", idx.fileName).Msg("error closing tar object")
+		}
+	}()
+	tr := tar.NewReader(r)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		hdr, err := tr.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				idx.complete = true
+				return nil
+			}
+			return fmt.Errorf("cannot read tar object %s: %w", idx.fileName, err)
+		}
+		// After the header is read the reader is positioned at the beginning of the file data
+		offset := r.offset
+		idx.next = offset + (hdr.Size+blockSize-1)/blockSize*blockSize
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		e := &entry{name: path.Clean(hdr.Name), hdr: hdr, offset: offset}
+		idx.entries = append(idx.entries, e)
+		idx.names[e.name] = e
+		if e.name == stop {
+			return nil
+		}
+	}
+}
+
+// open - returns the reader of the file data
+func (idx *index) open(ctx context.Context, e *entry) (io.ReadCloser, error) {
+	if e.hdr.Size == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	if rr, ok := idx.st.(storages.RangeReader); ok {
+		r, err := rr.GetObjectRange(ctx, idx.fileName, e.offset, e.hdr.Size)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read tar object %s: %w", idx.fileName, err)
+		}
+		return r, nil
+	}
+	r := newArchiveReader(ctx, idx.st, idx.fileName, e.offset)
+	return storages.LimitReadCloser(r, e.hdr.Size), nil
+}
+
+// archiveReader - reads the archive object starting from the offset. If the storage supports the ranged reads,
+// seeking forward reopens the object at the new offset, so the data of the skipped files is not transferred.
+// Otherwise, the object is streamed from the beginning and the skipped data is discarded
+type archiveReader struct {
+	ctx      context.Context
+	st       storages.Storager
+	fileName string
+	offset   int64
+	r        io.ReadCloser
+}
+
+func newArchiveReader(ctx context.Context, st storages.Storager, fileName string, offset int64) *archiveReader {
+	return &archiveReader{
+		ctx:      ctx,
+		st:       st,
+		fileName: fileName,
+		offset:   offset,
+	}
+}
+
+func (ar *archiveReader) open() error {
+	if rr, ok := ar.st.(storages.RangeReader); ok {
+		r, err := rr.GetObjectRange(ar.ctx, ar.fileName, ar.offset, -1)
+		if err != nil {
+			return fmt.Errorf("cannot open tar object %s: %w", ar.fileName, err)
+		}
+		ar.r = r
+		return nil
+	}
+	r, err := ar.st.GetObject(ar.ctx, ar.fileName)
+	if err != nil {
+		return fmt.Errorf("cannot open tar object %s: %w", ar.fileName, err)
+	}
+	if _, err = io.CopyN(io.Discard, r, ar.offset); err != nil {
+		_ = r.Close()
+		return fmt.Errorf("cannot read tar object %s: %w", ar.fileName, err)
+	}
+	ar.r = r
+	return nil
+}
+
+func (ar *archiveReader) Read(p []byte) (int, error) {
+	if ar.r == nil {
+		if err := ar.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := ar.r.Read(p)
+	ar.offset += int64(n)
+	return n, err
+}
+
+// Seek - moves the reader forward. The archive/tar reader uses it for skipping the file data
+func (ar *archiveReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += ar.offset
+	default:
+		return 0, fmt.Errorf("unsupported seek whence %d", whence)
+	}
+	if offset < ar.offset {
+		return 0, errors.New("tar object cannot be read backwards")
+	}
+	if offset == ar.offset {
+		return offset, nil
+	}
+	_, ranged := ar.st.(storages.RangeReader)
+	switch {
+	case ar.r == nil:
+		// The object is opened at the new offset on the next read
+	case ranged:
+		if err := ar.r.Close(); err != nil {
+			return 0, fmt.Errorf("cannot close tar object %s: %w", ar.fileName, err)
+		}
+		ar.r = nil
+	default:
+		if _, err := io.CopyN(io.Discard, ar, offset-ar.offset); err != nil {
+			return 0, err
+		}
+	}
+	ar.offset = offset
+	return offset, nil
+}
+
+func (ar *archiveReader) Close() error {
+	if ar.r == nil {
+		return nil
+	}
+	return ar.r.Close()
+}
@@ -0,0 +1,168 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tar
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/storages/domains"
+)
+
+// Extension - the extension of the tar object
+const Extension = ".tar"
+
+var ErrReadOnly = errors.New("tar storage is read-only")
+
+// Storage - the read-only storage of the files bundled into the tar object of the wrapped storage. The archive is
+// never extracted locally. The headers of the archive are read once and cached with the offsets of the file data, so
+// the file is read with the ranged read if the wrapped storage is a storages.RangeReader. Otherwise, the object is
+// streamed up to the requested file. The files that are read first should be placed at the beginning of the archive
+type Storage struct {
+	st       storages.Storager
+	fileName string
+	prefix   string
+	index    *index
+}
+
+// NewStorage - creates the storage of the tar object fileName in st
+func NewStorage(st storages.Storager, fileName string) *Storage {
+	return &Storage{
+		st:       st,
+		fileName: fileName,
+		index:    newIndex(st, fileName),
+	}
+}
+
+func (s *Storage) GetCwd() string {
+	return path.Join(s.st.GetCwd(), s.fileName, s.prefix)
+}
+
+// Dirname - returns the name of the tar object without extension or the name of the directory inside the archive
+func (s *Storage) Dirname() string {
+	if s.prefix != "" {
+		return path.Base(s.prefix)
+	}
+	return strings.TrimSuffix(path.Base(s.fileName), Extension)
+}
+
+func (s *Storage) ListDir(ctx context.Context) (files []string, dirs []storages.Storager, err error) {
+	entries, err := s.index.all(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	seenDirs := make(map[string]struct{})
+	for _, e := range entries {
+		rel, ok := s.relativeName(e.name)
+		if !ok {
+			continue
+		}
+		dir, _, found := strings.Cut(rel, "/")
+		if !found {
+			files = append(files, rel)
+			continue
+		}
+		if _, ok := seenDirs[dir]; !ok {
+			seenDirs[dir] = struct{}{}
+			dirs = append(dirs, s.SubStorage(dir, true))
+		}
+	}
+	return files, dirs, nil
+}
+
+// GetObject - returns the reader of the file data. The reader must be closed to release the archive object
+func (s *Storage) GetObject(ctx context.Context, filePath string) (reader io.ReadCloser, err error) {
+	e, err := s.index.lookup(ctx, path.Join(s.prefix, filePath))
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, fmt.Errorf("%w: %s", storages.ErrFileNotFound, filePath)
+	}
+	return s.index.open(ctx, e)
+}
+
+func (s *Storage) PutObject(ctx context.Context, filePath string, body io.Reader) error {
+	return ErrReadOnly
+}
+
+func (s *Storage) Delete(ctx context.Context, filePaths ...string) error {
+	return ErrReadOnly
+}
+
+func (s *Storage) DeleteAll(ctx context.Context, pathPrefix string) error {
+	return ErrReadOnly
+}
+
+func (s *Storage) Exists(ctx context.Context, fileName string) (bool, error) {
+	hdr, err := s.find(ctx, fileName)
+	if err != nil {
+		return false, err
+	}
+	return hdr != nil, nil
+}
+
+// SubStorage - returns the storage of the directory inside the archive. The absolute path is counted from the
+// archive root
+func (s *Storage) SubStorage(subPath string, relative bool) storages.Storager {
+	prefix := strings.TrimPrefix(path.Clean("/"+subPath), "/")
+	if relative {
+		prefix = path.Join(s.prefix, prefix)
+	}
+	return &Storage{
+		st:       s.st,
+		fileName: s.fileName,
+		prefix:   prefix,
+		index:    s.index,
+	}
+}
+
+func (s *Storage) Stat(fileName string) (*domains.ObjectStat, error) {
+	hdr, err := s.find(context.Background(), fileName)
+	if err != nil {
+		return nil, err
+	}
+	if hdr == nil {
+		return &domains.ObjectStat{Name: fileName}, nil
+	}
+	return &domains.ObjectStat{
+		Name:         fileName,
+		LastModified: hdr.ModTime,
+		Exist:        true,
+	}, nil
+}
+
+// find - returns the header of the file or nil if the file is not found
+func (s *Storage) find(ctx context.Context, fileName string) (*tar.Header, error) {
+	e, err := s.index.lookup(ctx, path.Join(s.prefix, fileName))
+	if err != nil || e == nil {
+		return nil, err
+	}
+	return e.hdr, nil
+}
+
+// relativeName - returns the name of the file relatively to the storage prefix
+func (s *Storage) relativeName(name string) (string, bool) {
+	if s.prefix == "" {
+		return name, true
+	}
+	return strings.CutPrefix(name, s.prefix+"/")
+}
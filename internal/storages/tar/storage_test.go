@@ -0,0 +1,364 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tar
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/storages/directory"
+)
+
+// writeTestArchive - writes the files into the tar object 1.tar of st in the provided order and returns the archive
+func writeTestArchive(t *testing.T, st storages.Storager, names []string, files map[string][]byte) []byte {
+	ctx := context.Background()
+	w, err := NewWriter(ctx, st, "1.tar", filepath.Join(t.TempDir(), "spool"))
+	require.NoError(t, err)
+	for _, name := range names {
+		require.NoError(t, w.PutObject(ctx, name, bytes.NewReader(files[name])))
+	}
+	require.NoError(t, w.Close())
+	r, err := st.GetObject(ctx, "1.tar")
+	require.NoError(t, err)
+	defer r.Close() // nolint:errcheck
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return data
+}
+
+func newTestArchive(t *testing.T) (*bytes.Buffer, storages.Storager) {
+	files := map[string][]byte{
+		"toc.dat":          []byte("toc"),
+		"metadata.json":    []byte("{}"),
+		"1.dat.gz":         []byte("data"),
+		"blobs/blob_1.dat": []byte("blob"),
+	}
+	st, err := directory.NewStorage(&directory.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+	data := writeTestArchive(t, st, []string{"toc.dat", "metadata.json", "1.dat.gz", "blobs/blob_1.dat"}, files)
+	return bytes.NewBuffer(data), st
+}
+
+func readArchiveNames(t *testing.T, r io.Reader) []string {
+	var names []string
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+	}
+	return names
+}
+
+func TestWriter(t *testing.T) {
+	buf, _ := newTestArchive(t)
+	assert.Equal(t, []string{"toc.dat", "metadata.json", "1.dat.gz", "blobs/blob_1.dat"}, readArchiveNames(t, buf))
+}
+
+func TestWriter_deferred(t *testing.T) {
+	ctx := context.Background()
+	st, err := directory.NewStorage(&directory.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+	spoolDir := filepath.Join(t.TempDir(), "spool")
+	w, err := NewWriter(ctx, st, "1.tar", spoolDir, "toc.dat", "metadata.json", "progress.json")
+	require.NoError(t, err)
+
+	// The deferred files are overwritten and deleted until the writer is closed
+	require.NoError(t, w.PutObject(ctx, "metadata.json", bytes.NewBufferString("old")))
+	require.NoError(t, w.PutObject(ctx, "progress.json", bytes.NewBufferString("{}")))
+	require.NoError(t, w.PutObject(ctx, "1.dat.gz", bytes.NewBufferString("data")))
+	require.NoError(t, w.PutObject(ctx, "metadata.json", bytes.NewBufferString("{}")))
+	require.NoError(t, w.PutObject(ctx, "toc.dat", bytes.NewBufferString("toc")))
+	require.NoError(t, w.Delete(ctx, "progress.json"))
+
+	exists, err := w.Exists(ctx, "1.dat.gz")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = w.Exists(ctx, "progress.json")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	r, err := w.GetObject(ctx, "metadata.json")
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, "{}", string(data))
+
+	// The appended files cannot be changed
+	require.ErrorContains(t, w.PutObject(ctx, "1.dat.gz", bytes.NewBufferString("data")), "already written")
+	require.ErrorIs(t, w.Delete(ctx, "1.dat.gz"), ErrWriteOnly)
+	_, err = w.GetObject(ctx, "1.dat.gz")
+	require.ErrorIs(t, err, ErrWriteOnly)
+
+	// Only the data file is spooled at the moment, so the spool directory contains only the deferred files
+	spooled, err := os.ReadDir(spoolDir)
+	require.NoError(t, err)
+	require.Len(t, spooled, 1)
+
+	require.NoError(t, w.Close())
+	_, err = os.Stat(spoolDir)
+	require.ErrorIs(t, err, os.ErrNotExist)
+	require.ErrorIs(t, w.PutObject(ctx, "2.dat.gz", bytes.NewBufferString("data")), ErrAlreadyClosed)
+
+	s := NewStorage(st, "1.tar")
+	files, _, err := s.ListDir(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.dat.gz", "toc.dat", "metadata.json"}, files)
+	assert.Equal(t, "{}", readTestObject(t, s, "metadata.json"))
+}
+
+// failingStorage - the storage that fails the upload after reading the whole body
+type failingStorage struct {
+	storages.Storager
+	err error
+}
+
+func (s *failingStorage) PutObject(ctx context.Context, filePath string, body io.Reader) error {
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		return err
+	}
+	return s.err
+}
+
+func TestWriter_errors(t *testing.T) {
+	ctx := context.Background()
+	st, err := directory.NewStorage(&directory.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	t.Run("abort", func(t *testing.T) {
+		spoolDir := filepath.Join(t.TempDir(), "spool")
+		fs := &failingStorage{Storager: st}
+		w, err := NewWriter(ctx, fs, "1.tar", spoolDir)
+		require.NoError(t, err)
+		require.NoError(t, w.PutObject(ctx, "1.dat.gz", bytes.NewBufferString("data")))
+		abortErr := errors.New("dump failed")
+		w.Abort(abortErr)
+		require.ErrorIs(t, w.uploadErr, abortErr)
+		require.ErrorIs(t, w.PutObject(ctx, "2.dat.gz", bytes.NewBufferString("data")), ErrAlreadyClosed)
+		_, err = os.Stat(spoolDir)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("upload", func(t *testing.T) {
+		uploadErr := errors.New("upload failed")
+		w, err := NewWriter(ctx, &failingStorage{Storager: st, err: uploadErr}, "1.tar", t.TempDir())
+		require.NoError(t, err)
+		require.NoError(t, w.PutObject(ctx, "1.dat.gz", bytes.NewBufferString("data")))
+		require.ErrorIs(t, w.Close(), uploadErr)
+	})
+}
+
+func TestStorage_GetObject(t *testing.T) {
+	ctx := context.Background()
+	_, st := newTestArchive(t)
+	s := NewStorage(st, "1.tar")
+	assert.Equal(t, "1", s.Dirname())
+
+	r, err := s.GetObject(ctx, "1.dat.gz")
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, "data", string(data))
+
+	r, err = s.SubStorage("blobs", true).GetObject(ctx, "blob_1.dat")
+	require.NoError(t, err)
+	data, err = io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, "blob", string(data))
+
+	_, err = s.GetObject(ctx, "unknown")
+	require.ErrorIs(t, err, storages.ErrFileNotFound)
+}
+
+func TestStorage_Exists(t *testing.T) {
+	ctx := context.Background()
+	_, st := newTestArchive(t)
+	s := NewStorage(st, "1.tar")
+
+	exists, err := s.Exists(ctx, "metadata.json")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = s.Exists(ctx, "unknown")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	stat, err := s.Stat("toc.dat")
+	require.NoError(t, err)
+	assert.True(t, stat.Exist)
+}
+
+func TestStorage_ListDir(t *testing.T) {
+	_, st := newTestArchive(t)
+	files, dirs, err := NewStorage(st, "1.tar").ListDir(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"toc.dat", "metadata.json", "1.dat.gz"}, files)
+	require.Len(t, dirs, 1)
+	assert.Equal(t, "blobs", dirs[0].Dirname())
+	files, _, err = dirs[0].ListDir(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"blob_1.dat"}, files)
+}
+
+func TestStorage_ReadOnly(t *testing.T) {
+	_, st := newTestArchive(t)
+	s := NewStorage(st, "1.tar")
+	require.ErrorIs(t, s.PutObject(context.Background(), "new", bytes.NewReader(nil)), ErrReadOnly)
+	require.ErrorIs(t, s.Delete(context.Background(), "toc.dat"), ErrReadOnly)
+}
+
+// countingStorage - counts the opened objects and the bytes read from them
+type countingStorage struct {
+	storages.Storager
+	opened int
+	read   int64
+}
+
+func (s *countingStorage) GetObject(ctx context.Context, filePath string) (io.ReadCloser, error) {
+	r, err := s.Storager.GetObject(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	s.opened++
+	return &countingReader{ReadCloser: r, s: s}, nil
+}
+
+// rangeCountingStorage - countingStorage that supports the ranged reads
+type rangeCountingStorage struct {
+	*countingStorage
+	rr storages.RangeReader
+}
+
+func (s *rangeCountingStorage) GetObjectRange(
+	ctx context.Context, filePath string, offset, length int64,
+) (io.ReadCloser, error) {
+	r, err := s.rr.GetObjectRange(ctx, filePath, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	s.opened++
+	return &countingReader{ReadCloser: r, s: s.countingStorage}, nil
+}
+
+type countingReader struct {
+	io.ReadCloser
+	s *countingStorage
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.s.read += int64(n)
+	return n, err
+}
+
+func newLargeTestArchive(t *testing.T) (*directory.Storage, int64) {
+	files := map[string][]byte{
+		"toc.dat":       []byte("toc"),
+		"metadata.json": []byte("{}"),
+		"1.dat.gz":      bytes.Repeat([]byte("a"), 1<<20),
+		"2.dat.gz":      []byte("data"),
+	}
+	st, err := directory.NewStorage(&directory.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+	data := writeTestArchive(t, st, []string{"toc.dat", "metadata.json", "1.dat.gz", "2.dat.gz"}, files)
+	return st, int64(len(data))
+}
+
+func readTestObject(t *testing.T, s storages.Storager, name string) string {
+	r, err := s.GetObject(context.Background(), name)
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	return string(data)
+}
+
+func TestStorage_RangedReads(t *testing.T) {
+	ctx := context.Background()
+	st, size := newLargeTestArchive(t)
+	cs := &rangeCountingStorage{countingStorage: &countingStorage{Storager: st}, rr: st}
+	s := NewStorage(cs, "1.tar")
+
+	// The files at the beginning of the archive are found without reading the rest of it
+	exists, err := s.Exists(ctx, "metadata.json")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Less(t, cs.read, int64(1<<20))
+
+	// The data of the large file is skipped while the headers are read
+	assert.Equal(t, "data", readTestObject(t, s, "2.dat.gz"))
+	assert.Less(t, cs.read, int64(1<<20))
+
+	// The cached headers are not read again
+	opened, read := cs.opened, cs.read
+	_, _, err = s.ListDir(ctx)
+	require.NoError(t, err)
+	exists, err = s.Exists(ctx, "2.dat.gz")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	stat, err := s.Stat("toc.dat")
+	require.NoError(t, err)
+	assert.True(t, stat.Exist)
+	assert.LessOrEqual(t, cs.opened, opened+1)
+	assert.Less(t, cs.read, read+blockSize*4)
+
+	// The file is read with the single ranged read of its data
+	opened, read = cs.opened, cs.read
+	assert.Equal(t, "toc", readTestObject(t, s, "toc.dat"))
+	assert.Equal(t, opened+1, cs.opened)
+	assert.Equal(t, read+3, cs.read)
+	assert.Less(t, cs.read, size)
+}
+
+func TestStorage_StreamedReads(t *testing.T) {
+	ctx := context.Background()
+	st, size := newLargeTestArchive(t)
+	cs := &countingStorage{Storager: st}
+	s := NewStorage(cs, "1.tar")
+
+	_, _, err := s.ListDir(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cs.opened)
+
+	// The headers are read once
+	for _, name := range []string{"toc.dat", "metadata.json", "1.dat.gz", "2.dat.gz", "unknown"} {
+		_, err = s.Exists(ctx, name)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, cs.opened)
+	assert.LessOrEqual(t, cs.read, size)
+
+	// The object is streamed only up to the end of the requested file
+	read := cs.read
+	assert.Equal(t, "toc", readTestObject(t, s, "toc.dat"))
+	assert.Equal(t, 2, cs.opened)
+	assert.Less(t, cs.read-read, int64(2*blockSize))
+	assert.Equal(t, "data", readTestObject(t, s.SubStorage("", true), "2.dat.gz"))
+	assert.Equal(t, 3, cs.opened)
+}
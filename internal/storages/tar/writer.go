@@ -0,0 +1,341 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tar
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/greenmaskio/greenmask/internal/storages"
+	"github.com/greenmaskio/greenmask/internal/storages/domains"
+)
+
+const deferredDirName = "deferred"
+
+var (
+	ErrWriteOnly     = errors.New("tar writer storage is write-only")
+	ErrAlreadyClosed = errors.New("tar writer storage is already closed")
+)
+
+// Writer - the storage that streams the written files into the tar object of the wrapped storage while they are
+// written. The tar header contains the file size, so each file is spooled into the local directory first and then
+// appended to the archive. Only the files that are being written at the moment are kept on the local disk.
+//
+// The deferred files are kept in the local directory and may be overwritten or deleted until the writer is closed.
+// They are appended to the end of the archive on Close in the provided order. The files appended to the archive
+// cannot be read, overwritten or deleted
+type Writer struct {
+	st       storages.Storager
+	fileName string
+	spoolDir string
+	deferred []string
+
+	mx      sync.Mutex
+	tw      *tar.Writer
+	pw      *io.PipeWriter
+	written map[string]struct{}
+	closed  bool
+
+	uploadDone chan struct{}
+	uploadErr  error
+}
+
+// NewWriter - creates the tar object fileName in st and starts the upload. The files are spooled into spoolDir that
+// is removed when the writer is closed or aborted
+func NewWriter(ctx context.Context, st storages.Storager, fileName, spoolDir string, deferred ...string) (*Writer, error) {
+	if err := os.MkdirAll(filepath.Join(spoolDir, deferredDirName), 0700); err != nil {
+		return nil, fmt.Errorf("cannot create spool directory: %w", err)
+	}
+	pr, pw := io.Pipe()
+	w := &Writer{
+		st:         st,
+		fileName:   fileName,
+		spoolDir:   spoolDir,
+		deferred:   deferred,
+		tw:         tar.NewWriter(pw),
+		pw:         pw,
+		written:    make(map[string]struct{}),
+		uploadDone: make(chan struct{}),
+	}
+	go func() {
+		defer close(w.uploadDone)
+		w.uploadErr = st.PutObject(ctx, fileName, pr)
+		// Unblock the writer if the upload is stopped before the archive is read to the end
+		if w.uploadErr != nil {
+			_ = pr.CloseWithError(fmt.Errorf("upload is stopped: %w", w.uploadErr))
+		} else {
+			_ = pr.Close()
+		}
+	}()
+	return w, nil
+}
+
+func (w *Writer) GetCwd() string {
+	return path.Join(w.st.GetCwd(), w.fileName)
+}
+
+func (w *Writer) Dirname() string {
+	return strings.TrimSuffix(path.Base(w.fileName), Extension)
+}
+
+func (w *Writer) ListDir(ctx context.Context) (files []string, dirs []storages.Storager, err error) {
+	return nil, nil, ErrWriteOnly
+}
+
+// GetObject - returns the reader of the deferred file. The files appended to the archive cannot be read
+func (w *Writer) GetObject(ctx context.Context, filePath string) (io.ReadCloser, error) {
+	name := cleanName(filePath)
+	if !w.isDeferred(name) {
+		return nil, ErrWriteOnly
+	}
+	f, err := os.Open(w.deferredPath(name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", storages.ErrFileNotFound, filePath)
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// PutObject - spools the file and appends it to the archive. The deferred file is only spooled
+func (w *Writer) PutObject(ctx context.Context, filePath string, body io.Reader) error {
+	name := cleanName(filePath)
+	if w.isDeferred(name) {
+		return w.putDeferred(name, body)
+	}
+	if w.isClosed() {
+		return ErrAlreadyClosed
+	}
+
+	f, err := os.CreateTemp(w.spoolDir, "*.spool")
+	if err != nil {
+		return fmt.Errorf("cannot create spool file: %w", err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Warn().Err(err).Str("FileName", f.Name()).Msg("error closing spool file")
+		}
+		if err := os.Remove(f.Name()); err != nil {
+			log.Warn().Err(err).Str("FileName", f.Name()).Msg("error removing spool file")
+		}
+	}()
+	size, err := io.Copy(f, body)
+	if err != nil {
+		return fmt.Errorf("cannot spool file %s: %w", name, err)
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("cannot read spool file %s: %w", name, err)
+	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	if _, ok := w.written[name]; ok {
+		return fmt.Errorf("file %s is already written into the archive", name)
+	}
+	if err = w.append(name, f, size); err != nil {
+		return err
+	}
+	w.written[name] = struct{}{}
+	return nil
+}
+
+// putDeferred - replaces the deferred file in the spool directory. The file is written under the temporary name, so
+// the previous version is available until the new one is complete
+func (w *Writer) putDeferred(name string, body io.Reader) error {
+	f, err := os.CreateTemp(filepath.Join(w.spoolDir, deferredDirName), "*.spool")
+	if err != nil {
+		return fmt.Errorf("cannot create spool file: %w", err)
+	}
+	_, err = io.Copy(f, body)
+	err = errors.Join(err, f.Close())
+	if err == nil {
+		err = os.Rename(f.Name(), w.deferredPath(name))
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("cannot spool file %s: %w", name, err)
+	}
+	return nil
+}
+
+// append - writes the file into the archive. It must be called under the lock
+func (w *Writer) append(name string, r io.Reader, size int64) error {
+	if w.closed {
+		return ErrAlreadyClosed
+	}
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0600,
+		ModTime:  time.Now(),
+	}
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("cannot write file %s into the archive: %w", name, err)
+	}
+	if _, err := io.Copy(w.tw, r); err != nil {
+		return fmt.Errorf("cannot write file %s into the archive: %w", name, err)
+	}
+	return nil
+}
+
+// Delete - deletes the deferred files. The files appended to the archive cannot be deleted
+func (w *Writer) Delete(ctx context.Context, filePaths ...string) error {
+	for _, fp := range filePaths {
+		name := cleanName(fp)
+		if !w.isDeferred(name) {
+			return ErrWriteOnly
+		}
+		if err := os.Remove(w.deferredPath(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("cannot delete spooled file %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (w *Writer) DeleteAll(ctx context.Context, pathPrefix string) error {
+	return ErrWriteOnly
+}
+
+// Exists - checks the file is appended to the archive or the deferred file is spooled
+func (w *Writer) Exists(ctx context.Context, fileName string) (bool, error) {
+	name := cleanName(fileName)
+	if w.isDeferred(name) {
+		_, err := os.Stat(w.deferredPath(name))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	_, ok := w.written[name]
+	return ok, nil
+}
+
+// SubStorage - returns the writer itself. The archive is flat, so the sub storages are not supported
+func (w *Writer) SubStorage(subPath string, relative bool) storages.Storager {
+	return w
+}
+
+func (w *Writer) Stat(fileName string) (*domains.ObjectStat, error) {
+	exists, err := w.Exists(context.Background(), fileName)
+	if err != nil {
+		return nil, err
+	}
+	return &domains.ObjectStat{Name: fileName, Exist: exists}, nil
+}
+
+// Close - appends the deferred files to the archive, completes it and waits for the upload. The spool directory is
+// removed
+func (w *Writer) Close() error {
+	defer w.removeSpoolDir()
+	err := w.complete()
+	if err != nil {
+		_ = w.pw.CloseWithError(err)
+	} else {
+		_ = w.pw.Close()
+	}
+	<-w.uploadDone
+	if err != nil {
+		return err
+	}
+	if w.uploadErr != nil {
+		return fmt.Errorf("cannot upload tar object %s: %w", w.fileName, w.uploadErr)
+	}
+	return nil
+}
+
+func (w *Writer) complete() error {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	for _, name := range w.deferred {
+		f, err := os.Open(w.deferredPath(name))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return fmt.Errorf("cannot open spooled file %s: %w", name, err)
+		}
+		info, err := f.Stat()
+		if err == nil {
+			err = w.append(name, f, info.Size())
+		}
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	w.closed = true
+	if err := w.tw.Close(); err != nil {
+		return fmt.Errorf("cannot complete tar archive: %w", err)
+	}
+	return nil
+}
+
+// Abort - stops the upload with the error, so the incomplete archive is not committed by the storages that upload
+// the objects atomically. The spool directory is removed
+func (w *Writer) Abort(err error) {
+	defer w.removeSpoolDir()
+	// The pipe is closed first to unblock the file that is being appended
+	_ = w.pw.CloseWithError(err)
+	w.mx.Lock()
+	w.closed = true
+	w.mx.Unlock()
+	<-w.uploadDone
+}
+
+func (w *Writer) removeSpoolDir() {
+	if err := os.RemoveAll(w.spoolDir); err != nil {
+		log.Warn().Err(err).Str("Directory", w.spoolDir).Msg("cannot remove spool directory")
+	}
+}
+
+func (w *Writer) isClosed() bool {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	return w.closed
+}
+
+func (w *Writer) isDeferred(name string) bool {
+	return slices.Contains(w.deferred, name)
+}
+
+func (w *Writer) deferredPath(name string) string {
+	return filepath.Join(w.spoolDir, deferredDirName, name)
+}
+
+func cleanName(name string) string {
+	return path.Clean("/" + name)[1:]
+}
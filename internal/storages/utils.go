@@ -3,6 +3,7 @@ package storages
 import (
 	"context"
 	"fmt"
+	"io"
 	"path"
 )
 
@@ -33,3 +34,26 @@ func Walk(ctx context.Context, st Storager, parent string) (res []string, err er
 
 	return
 }
+
+// LimitReadCloser - returns the ReadCloser that reads up to n bytes of rc. The negative n means rc is read up to the
+// end
+func LimitReadCloser(rc io.ReadCloser, n int64) io.ReadCloser {
+	if n < 0 {
+		return rc
+	}
+	return &limitReadCloser{Reader: io.LimitReader(rc, n), Closer: rc}
+}
+
+// FormatHttpRange - returns the value of the HTTP Range header. The negative length means the object is read up to
+// the end
+func FormatHttpRange(offset, length int64) string {
+	if length < 0 {
+		return fmt.Sprintf("bytes=%d-", offset)
+	}
+	return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+}
+
+type limitReadCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,150 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bloom implements the scalable Bloom filter that is used to detect the duplicated values with the bounded
+// memory
+package bloom
+
+import (
+	"math"
+
+	"github.com/spaolacci/murmur3"
+)
+
+// tighteningRatio - the false positive rate of each next layer is multiplied by the ratio, so the total false
+// positive rate of the filter does not exceed the double rate of the first layer
+const tighteningRatio = 0.5
+
+// Filter - scalable Bloom filter. The filter never returns false for the added value, but it may return true for the
+// value that was not added with the configured false positive rate. When the number of the added values exceeds the
+// capacity a new layer of the double capacity is added, so the memory grows with the number of values by ~1.2 bytes
+// per value for 1% rate. The filter uses the deterministic hash function, so the same values added in the same order
+// give the same results in each run
+type Filter struct {
+	layers   []*layer
+	capacity uint64
+	fpRate   float64
+}
+
+type layer struct {
+	bits     []uint64
+	m        uint64
+	k        uint64
+	capacity uint64
+	count    uint64
+}
+
+// NewFilter - creates the filter with the initial capacity and the false positive rate
+func NewFilter(capacity uint64, fpRate float64) *Filter {
+	if capacity == 0 {
+		capacity = 1
+	}
+	f := &Filter{
+		capacity: capacity,
+		fpRate:   fpRate,
+	}
+	f.layers = append(f.layers, newLayer(capacity, fpRate))
+	return f
+}
+
+func newLayer(capacity uint64, fpRate float64) *layer {
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := uint64(math.Round(float64(m) / float64(capacity) * math.Ln2))
+	if k == 0 {
+		k = 1
+	}
+	return &layer{
+		bits:     make([]uint64, (m+63)/64),
+		m:        m,
+		k:        k,
+		capacity: capacity,
+	}
+}
+
+func (f *Filter) hashes(data []byte) (uint64, uint64) {
+	h1, h2 := murmur3.Sum128(data)
+	// The second hash must be odd so the probes do not cycle over the part of the bits
+	return h1, h2 | 1
+}
+
+func (l *layer) add(h1, h2 uint64) {
+	for i := uint64(0); i < l.k; i++ {
+		pos := (h1 + i*h2) % l.m
+		l.bits[pos/64] |= 1 << (pos % 64)
+	}
+	l.count++
+}
+
+func (l *layer) contains(h1, h2 uint64) bool {
+	for i := uint64(0); i < l.k; i++ {
+		pos := (h1 + i*h2) % l.m
+		if l.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Add - adds the value into the filter
+func (f *Filter) Add(data []byte) {
+	h1, h2 := f.hashes(data)
+	f.add(h1, h2)
+}
+
+func (f *Filter) add(h1, h2 uint64) {
+	last := f.layers[len(f.layers)-1]
+	if last.count >= last.capacity {
+		last = newLayer(last.capacity*2, f.fpRate*math.Pow(tighteningRatio, float64(len(f.layers))))
+		f.layers = append(f.layers, last)
+	}
+	last.add(h1, h2)
+}
+
+// Contains - checks the value might be added into the filter
+func (f *Filter) Contains(data []byte) bool {
+	h1, h2 := f.hashes(data)
+	return f.contains(h1, h2)
+}
+
+func (f *Filter) contains(h1, h2 uint64) bool {
+	for _, l := range f.layers {
+		if l.contains(h1, h2) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddIfAbsent - adds the value into the filter if it is not there yet. It returns false if the value might be
+// already added
+func (f *Filter) AddIfAbsent(data []byte) bool {
+	h1, h2 := f.hashes(data)
+	if f.contains(h1, h2) {
+		return false
+	}
+	f.add(h1, h2)
+	return true
+}
+
+// Count - returns the number of the added values
+func (f *Filter) Count() uint64 {
+	var res uint64
+	for _, l := range f.layers {
+		res += l.count
+	}
+	return res
+}
@@ -0,0 +1,56 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloom
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter(t *testing.T) {
+	f := NewFilter(100, 0.01)
+	assert.False(t, f.Contains([]byte("value")))
+	assert.True(t, f.AddIfAbsent([]byte("value")))
+	assert.True(t, f.Contains([]byte("value")))
+	assert.False(t, f.AddIfAbsent([]byte("value")))
+	assert.Equal(t, uint64(1), f.Count())
+}
+
+func TestFilter_scaling(t *testing.T) {
+	const count = 100_000
+	f := NewFilter(1000, 0.01)
+	for i := 0; i < count; i++ {
+		f.Add([]byte(fmt.Sprintf("value%d", i)))
+	}
+	require.Greater(t, len(f.layers), 1)
+	assert.Equal(t, uint64(count), f.Count())
+
+	// There are no false negatives
+	for i := 0; i < count; i++ {
+		require.True(t, f.Contains([]byte(fmt.Sprintf("value%d", i))))
+	}
+
+	// The false positive rate does not exceed the double rate of the first layer
+	var falsePositives int
+	for i := 0; i < count; i++ {
+		if f.Contains([]byte(fmt.Sprintf("other%d", i))) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, float64(falsePositives)/count, 0.02)
+}
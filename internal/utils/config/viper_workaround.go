@@ -63,26 +63,43 @@ func ParseTransformerParamsManually(cfgFilePath string, cfg *domains.Config) err
 // domains.TransformerConfig.Params
 func setTransformerParams(tmpCfg *domains.DummyConfig, cfg *domains.Config) (err error) {
 	for tableIdx, tableObj := range tmpCfg.Dump.Transformation {
-		for transformationIdx, transformationObj := range tableObj.Transformers {
+		for transformationIdx := range tableObj.Transformers {
 			transformer := cfg.Dump.Transformation[tableIdx].Transformers[transformationIdx]
 			tmpTransformer := tmpCfg.Dump.Transformation[tableIdx].Transformers[transformationIdx]
-			paramsMap := make(map[string]toolkit.ParamsValue, len(transformationObj.Params))
-			for paramName, decodedValue := range tmpTransformer.Params {
-				var encodedVal toolkit.ParamsValue
-				switch v := decodedValue.(type) {
-				case string:
-					encodedVal = toolkit.ParamsValue(v)
-				default:
-					encodedVal, err = json.Marshal(v)
-					if err != nil {
-						return fmt.Errorf("cannot convert object to json bytes: %w", err)
-					}
-				}
-				paramsMap[paramName] = encodedVal
+			if err = encodeTransformerParams(transformer, tmpTransformer.Params); err != nil {
+				return err
 			}
-			transformer.Params = paramsMap
-			transformer.MetadataParams = tmpTransformer.Params
 		}
 	}
+	for ruleIdx, ruleObj := range tmpCfg.Dump.AutoAnonymizeRules {
+		transformer := cfg.Dump.AutoAnonymizeRules[ruleIdx].Transformer
+		if transformer == nil {
+			continue
+		}
+		if err = encodeTransformerParams(transformer, ruleObj.Transformer.Params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeTransformerParams - encodes the decoded params and stores them into the transformer config
+func encodeTransformerParams(transformer *domains.TransformerConfig, params map[string]interface{}) (err error) {
+	paramsMap := make(map[string]toolkit.ParamsValue, len(params))
+	for paramName, decodedValue := range params {
+		var encodedVal toolkit.ParamsValue
+		switch v := decodedValue.(type) {
+		case string:
+			encodedVal = toolkit.ParamsValue(v)
+		default:
+			encodedVal, err = json.Marshal(v)
+			if err != nil {
+				return fmt.Errorf("cannot convert object to json bytes: %w", err)
+			}
+		}
+		paramsMap[paramName] = encodedVal
+	}
+	transformer.Params = paramsMap
+	transformer.MetadataParams = params
 	return nil
 }
@@ -79,7 +79,7 @@ func GetDumpStatusAndMetadata(ctx context.Context, st storages.Storager) (string
 			return "", nil, fmt.Errorf("failed to get metadata: %w", err)
 		}
 		return heartBeatDoneContent, md, nil
-	case cmd.HeartBeatInProgressContent:
+	case cmd.HeartBeatInProgressContent, cmd.HeartBeatResumedContent:
 		if time.Now().After(objectInfo.LastModified.Add(cmd.HeartBeatWriteInterval * failedTimeoutMultiplayer)) {
 			return FailedStatusName, nil, nil
 		}
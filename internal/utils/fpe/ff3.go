@@ -0,0 +1,266 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fpe implements FF3-1 format-preserving encryption (NIST SP 800-38G Rev. 1). The ciphertext has the same
+// length and alphabet as the plaintext
+package fpe
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+)
+
+// The common alphabets
+const (
+	AlphabetDigits            = "0123456789"
+	AlphabetLowerAlphanumeric = "0123456789abcdefghijklmnopqrstuvwxyz"
+	AlphabetUpperAlphanumeric = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	AlphabetAlphanumeric      = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+)
+
+const (
+	// TweakSize - the size of the FF3-1 tweak in bytes (56 bits)
+	TweakSize = 7
+	rounds    = 8
+	// maxRadix - the maximal radix allowed by the standard
+	maxRadix = 1 << 16
+	// minDomainSize - the minimal number of the possible values radix^minlen
+	minDomainSize = 1000000
+)
+
+var (
+	ErrInvalidLength = errors.New("invalid input length")
+	ErrInvalidSymbol = errors.New("symbol is not in the alphabet")
+)
+
+// Cipher - FF3-1 cipher over the alphabet. It is safe for the concurrent use
+type Cipher struct {
+	block    cipher.Block
+	alphabet []rune
+	index    map[rune]int
+	radix    *big.Int
+	minLen   int
+	maxLen   int
+}
+
+// NewCipher - creates the cipher with the AES key of 16, 24 or 32 bytes. The radix is the alphabet size
+func NewCipher(key []byte, alphabet string) (*Cipher, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("invalid key size %d: expected 16, 24 or 32 bytes", len(key))
+	}
+	symbols := []rune(alphabet)
+	if len(symbols) < 2 || len(symbols) > maxRadix {
+		return nil, fmt.Errorf("invalid alphabet size %d: expected from 2 to %d symbols", len(symbols), maxRadix)
+	}
+	index := make(map[rune]int, len(symbols))
+	for i, s := range symbols {
+		if _, ok := index[s]; ok {
+			return nil, fmt.Errorf("alphabet contains duplicated symbol %q", s)
+		}
+		index[s] = i
+	}
+
+	// The standard uses the key in the reversed byte order
+	revKey := slices.Clone(key)
+	slices.Reverse(revKey)
+	block, err := aes.NewCipher(revKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create AES cipher: %w", err)
+	}
+
+	radix := big.NewInt(int64(len(symbols)))
+	// minlen - the smallest length where radix^minlen >= 1000000
+	minLen := 0
+	for domain := big.NewInt(1); domain.Cmp(big.NewInt(minDomainSize)) < 0; minLen++ {
+		domain.Mul(domain, radix)
+	}
+	minLen = max(minLen, 2)
+	// maxlen = 2 * floor(log_radix(2^96))
+	limit := new(big.Int).Lsh(big.NewInt(1), 96)
+	halfLen := 0
+	for domain := new(big.Int).Set(radix); domain.Cmp(limit) <= 0; halfLen++ {
+		domain.Mul(domain, radix)
+	}
+
+	return &Cipher{
+		block:    block,
+		alphabet: symbols,
+		index:    index,
+		radix:    radix,
+		minLen:   minLen,
+		maxLen:   2 * halfLen,
+	}, nil
+}
+
+// MinLen - returns the minimal length of the input
+func (c *Cipher) MinLen() int {
+	return c.minLen
+}
+
+// MaxLen - returns the maximal length of the input
+func (c *Cipher) MaxLen() int {
+	return c.maxLen
+}
+
+// Contains - checks the symbol is in the alphabet
+func (c *Cipher) Contains(r rune) bool {
+	_, ok := c.index[r]
+	return ok
+}
+
+// Encrypt - encrypts the symbols with the 7 bytes tweak
+func (c *Cipher) Encrypt(x []rune, tweak []byte) ([]rune, error) {
+	return c.crypt(x, tweak, true)
+}
+
+// Decrypt - decrypts the symbols with the 7 bytes tweak
+func (c *Cipher) Decrypt(x []rune, tweak []byte) ([]rune, error) {
+	return c.crypt(x, tweak, false)
+}
+
+// EncryptText - encrypts the alphabet symbols of the text keeping the other symbols, for instance separators, at
+// their positions
+func (c *Cipher) EncryptText(text string, tweak []byte) (string, error) {
+	return c.cryptText(text, tweak, true)
+}
+
+// DecryptText - decrypts the text encrypted by EncryptText
+func (c *Cipher) DecryptText(text string, tweak []byte) (string, error) {
+	return c.cryptText(text, tweak, false)
+}
+
+func (c *Cipher) cryptText(text string, tweak []byte, encrypt bool) (string, error) {
+	runes := []rune(text)
+	positions := make([]int, 0, len(runes))
+	symbols := make([]rune, 0, len(runes))
+	for i, r := range runes {
+		if c.Contains(r) {
+			positions = append(positions, i)
+			symbols = append(symbols, r)
+		}
+	}
+	res, err := c.crypt(symbols, tweak, encrypt)
+	if err != nil {
+		return "", err
+	}
+	for i, pos := range positions {
+		runes[pos] = res[i]
+	}
+	return string(runes), nil
+}
+
+func (c *Cipher) crypt(x []rune, tweak []byte, encrypt bool) ([]rune, error) {
+	n := len(x)
+	if n < c.minLen || n > c.maxLen {
+		return nil, fmt.Errorf("%w %d: expected from %d to %d symbols", ErrInvalidLength, n, c.minLen, c.maxLen)
+	}
+	if len(tweak) != TweakSize {
+		return nil, fmt.Errorf("invalid tweak size %d: expected %d bytes", len(tweak), TweakSize)
+	}
+	digits := make([]int, n)
+	for i, r := range x {
+		d, ok := c.index[r]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSymbol, r)
+		}
+		digits[i] = d
+	}
+
+	u := (n + 1) / 2
+	v := n - u
+	a, b := digits[:u], digits[u:]
+	tl := [4]byte{tweak[0], tweak[1], tweak[2], tweak[3] & 0xF0}
+	tr := [4]byte{tweak[4], tweak[5], tweak[6], (tweak[3] & 0x0F) << 4}
+
+	modU := new(big.Int).Exp(c.radix, big.NewInt(int64(u)), nil)
+	modV := new(big.Int).Exp(c.radix, big.NewInt(int64(v)), nil)
+	y := new(big.Int)
+	num := new(big.Int)
+	for step := 0; step < rounds; step++ {
+		i := step
+		if !encrypt {
+			i = rounds - 1 - step
+		}
+		m, w, mod := u, tr, modU
+		if i%2 == 1 {
+			m, w, mod = v, tl, modV
+		}
+		if encrypt {
+			c.roundValue(y, w, byte(i), b)
+			c.numRev(num, a)
+			num.Add(num, y)
+		} else {
+			c.roundValue(y, w, byte(i), a)
+			c.numRev(num, b)
+			num.Sub(num, y)
+		}
+		num.Mod(num, mod)
+		res := c.strRev(num, m)
+		if encrypt {
+			a, b = b, res
+		} else {
+			a, b = res, a
+		}
+	}
+
+	res := make([]rune, 0, n)
+	for _, d := range a {
+		res = append(res, c.alphabet[d])
+	}
+	for _, d := range b {
+		res = append(res, c.alphabet[d])
+	}
+	return res, nil
+}
+
+// roundValue - computes y = NUM(REVB(CIPH(REVB(W xor [i]^4 || [NUM_radix(REV(x))]^12))))
+func (c *Cipher) roundValue(y *big.Int, w [4]byte, i byte, x []int) {
+	var p [aes.BlockSize]byte
+	copy(p[:4], w[:])
+	p[3] ^= i
+	num := new(big.Int)
+	c.numRev(num, x)
+	num.FillBytes(p[4:])
+	slices.Reverse(p[:])
+	c.block.Encrypt(p[:], p[:])
+	slices.Reverse(p[:])
+	y.SetBytes(p[:])
+}
+
+// numRev - sets res to NUM_radix(REV(x)): the last digit is the most significant
+func (c *Cipher) numRev(res *big.Int, x []int) {
+	res.SetInt64(0)
+	for i := len(x) - 1; i >= 0; i-- {
+		res.Mul(res, c.radix)
+		res.Add(res, big.NewInt(int64(x[i])))
+	}
+}
+
+// strRev - returns REV(STR^m_radix(num)): the digits from the least significant
+func (c *Cipher) strRev(num *big.Int, m int) []int {
+	res := make([]int, m)
+	n := new(big.Int).Set(num)
+	d := new(big.Int)
+	for i := 0; i < m; i++ {
+		n.DivMod(n, c.radix, d)
+		res[i] = int(d.Int64())
+	}
+	return res
+}
@@ -0,0 +1,149 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fpe
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	digits    = AlphabetDigits
+	lowercase = "abcdefghijklmnopqrstuvwxyz"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	res, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return res
+}
+
+// The test vectors of the ACVP FF3-1 AES-128 test group
+func TestCipher_TestVectors(t *testing.T) {
+	tests := []struct {
+		key        string
+		tweak      string
+		alphabet   string
+		plaintext  string
+		ciphertext string
+	}{
+		{
+			key:        "2DE79D232DF5585D68CE47882AE256D6",
+			tweak:      "CBD09280979564",
+			alphabet:   digits,
+			plaintext:  "3992520240",
+			ciphertext: "8901801106",
+		},
+		{
+			key:        "01C63017111438F7FC8E24EB16C71AB5",
+			tweak:      "C4E822DCD09F27",
+			alphabet:   digits,
+			plaintext:  "60761757463116869318437658042297305934914824457484538562",
+			ciphertext: "35637144092473838892796702739628394376915177448290847293",
+		},
+		{
+			key:        "718385E6542534604419E83CE387A437",
+			tweak:      "B6F35084FA90E1",
+			alphabet:   lowercase,
+			plaintext:  "wfmwlrorcd",
+			ciphertext: "ywowehycyd",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.plaintext, func(t *testing.T) {
+			c, err := NewCipher(mustDecodeHex(t, tt.key), tt.alphabet)
+			require.NoError(t, err)
+			tweak := mustDecodeHex(t, tt.tweak)
+			res, err := c.Encrypt([]rune(tt.plaintext), tweak)
+			require.NoError(t, err)
+			assert.Equal(t, tt.ciphertext, string(res))
+			res, err = c.Decrypt(res, tweak)
+			require.NoError(t, err)
+			assert.Equal(t, tt.plaintext, string(res))
+		})
+	}
+}
+
+func TestNewCipher_Limits(t *testing.T) {
+	key := make([]byte, 32)
+	c, err := NewCipher(key, digits)
+	require.NoError(t, err)
+	assert.Equal(t, 6, c.MinLen())
+	assert.Equal(t, 56, c.MaxLen())
+
+	c, err = NewCipher(key, AlphabetAlphanumeric)
+	require.NoError(t, err)
+	assert.Equal(t, 4, c.MinLen())
+	assert.Equal(t, 32, c.MaxLen())
+
+	_, err = NewCipher(make([]byte, 10), digits)
+	require.ErrorContains(t, err, "invalid key size")
+	_, err = NewCipher(key, "0")
+	require.ErrorContains(t, err, "invalid alphabet size")
+	_, err = NewCipher(key, "0120")
+	require.ErrorContains(t, err, "duplicated symbol")
+}
+
+func TestCipher_Errors(t *testing.T) {
+	c, err := NewCipher(make([]byte, 16), digits)
+	require.NoError(t, err)
+	tweak := make([]byte, TweakSize)
+
+	_, err = c.Encrypt([]rune("12345"), tweak)
+	require.ErrorIs(t, err, ErrInvalidLength)
+	_, err = c.Encrypt([]rune("12345a"), tweak)
+	require.ErrorIs(t, err, ErrInvalidSymbol)
+	_, err = c.Encrypt([]rune("123456"), make([]byte, 8))
+	require.ErrorContains(t, err, "invalid tweak size")
+}
+
+func TestCipher_RoundTrip(t *testing.T) {
+	c, err := NewCipher(mustDecodeHex(t, "EF4359D8D580AA4F7F036D6F04FC6A942B7E151628AED2A6ABF7158809CF4F3C"), digits)
+	require.NoError(t, err)
+	tweak := mustDecodeHex(t, "D8E7920AFA330A")
+	for _, plaintext := range []string{"123456", "4111111111111111", "0000000000000000000"} {
+		res, err := c.Encrypt([]rune(plaintext), tweak)
+		require.NoError(t, err)
+		assert.Len(t, res, len(plaintext))
+		assert.NotEqual(t, plaintext, string(res))
+		// The encryption is deterministic
+		again, err := c.Encrypt([]rune(plaintext), tweak)
+		require.NoError(t, err)
+		assert.Equal(t, res, again)
+		dec, err := c.Decrypt(res, tweak)
+		require.NoError(t, err)
+		assert.Equal(t, plaintext, string(dec))
+	}
+}
+
+func TestCipher_EncryptText(t *testing.T) {
+	c, err := NewCipher(make([]byte, 16), digits)
+	require.NoError(t, err)
+	tweak := make([]byte, TweakSize)
+
+	res, err := c.EncryptText("123-45-6789", tweak)
+	require.NoError(t, err)
+	assert.Regexp(t, `^\d{3}-\d{2}-\d{4}$`, res)
+	assert.NotEqual(t, "123-45-6789", res)
+	dec, err := c.DecryptText(res, tweak)
+	require.NoError(t, err)
+	assert.Equal(t, "123-45-6789", dec)
+
+	_, err = c.EncryptText("12-34", tweak)
+	require.ErrorIs(t, err, ErrInvalidLength)
+}
@@ -0,0 +1,136 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ioutils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ChecksumReadCloser - CountReadCloser that computes SHA-256 checksum of the read data
+type ChecksumReadCloser interface {
+	CountReadCloser
+	// GetChecksum - returns hex encoded SHA-256 checksum of the data read so far
+	GetChecksum() string
+}
+
+type ChecksumReader struct {
+	r     io.ReadCloser
+	h     hash.Hash
+	Count int64
+}
+
+func NewChecksumReader(r io.ReadCloser) *ChecksumReader {
+	return &ChecksumReader{
+		r: r,
+		h: sha256.New(),
+	}
+}
+
+func (r *ChecksumReader) Read(p []byte) (n int, err error) {
+	n, err = r.r.Read(p)
+	r.h.Write(p[:n])
+	r.Count += int64(n)
+	return n, err
+}
+
+func (r *ChecksumReader) Close() error {
+	return r.r.Close()
+}
+
+func (r *ChecksumReader) GetCount() int64 {
+	return r.Count
+}
+
+func (r *ChecksumReader) GetChecksum() string {
+	return hex.EncodeToString(r.h.Sum(nil))
+}
+
+// VerifyingReader - computes SHA-256 checksum of the read data and returns ErrChecksumMismatch instead of io.EOF
+// if it is not equal to the expected one. If the object is not read till the end, the rest of it is read and verified
+// on Close
+type VerifyingReader struct {
+	r        io.ReadCloser
+	h        hash.Hash
+	name     string
+	expected string
+	verified bool
+}
+
+func NewVerifyingReader(r io.ReadCloser, name, expected string) *VerifyingReader {
+	return &VerifyingReader{
+		r:        r,
+		h:        sha256.New(),
+		name:     name,
+		expected: expected,
+	}
+}
+
+func (r *VerifyingReader) Read(p []byte) (n int, err error) {
+	n, err = r.r.Read(p)
+	r.h.Write(p[:n])
+	if errors.Is(err, io.EOF) {
+		r.verified = true
+		if verifyErr := r.verify(); verifyErr != nil {
+			return n, verifyErr
+		}
+	}
+	return n, err
+}
+
+// Close - reads the rest of the object if the reader has not reached io.EOF and verifies the checksum. The consumer
+// might stop reading before the end of the object, for instance the COPY data reader stops at the end-of-data marker
+// and the decompressor might not read the trailing bytes
+func (r *VerifyingReader) Close() error {
+	var verifyErr error
+	if !r.verified {
+		r.verified = true
+		if _, err := io.Copy(r.h, r.r); err != nil {
+			verifyErr = fmt.Errorf("cannot read object %s till the end: %w", r.name, err)
+		} else {
+			verifyErr = r.verify()
+		}
+	}
+	return errors.Join(verifyErr, r.r.Close())
+}
+
+func (r *VerifyingReader) verify() error {
+	if actual := hex.EncodeToString(r.h.Sum(nil)); actual != r.expected {
+		return fmt.Errorf("%w: object %s expected %s got %s", ErrChecksumMismatch, r.name, r.expected, actual)
+	}
+	return nil
+}
+
+// ChecksumBytes - returns hex encoded SHA-256 checksum of data
+func ChecksumBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Checksum - reads r till the end and returns hex encoded SHA-256 checksum and the size of the data
+func Checksum(r io.Reader) (string, int64, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return "", n, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ioutils
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sha256 of "hello world"
+const helloWorldChecksum = "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+
+func TestNewCompressionPipe_Checksum(t *testing.T) {
+	w, r, err := NewCompressionPipe(&CompressionSettings{Codec: CodecGzip})
+	require.NoError(t, err)
+	go func() {
+		_, _ = w.Write([]byte("hello world"))
+		_ = w.Close()
+	}()
+	compressed, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(compressed)), r.GetCount())
+	assert.Equal(t, ChecksumBytes(compressed), r.GetChecksum())
+}
+
+func TestVerifyingReader(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		r := NewVerifyingReader(io.NopCloser(bytes.NewBufferString("hello world")), "test", helloWorldChecksum)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, "hello world", string(data))
+	})
+
+	t.Run("corrupted", func(t *testing.T) {
+		r := NewVerifyingReader(io.NopCloser(bytes.NewBufferString("hello w0rld")), "test", helloWorldChecksum)
+		_, err := io.ReadAll(r)
+		require.ErrorIs(t, err, ErrChecksumMismatch)
+		require.NoError(t, r.Close())
+	})
+
+	t.Run("partially read valid", func(t *testing.T) {
+		r := NewVerifyingReader(io.NopCloser(bytes.NewBufferString("hello world")), "test", helloWorldChecksum)
+		_, err := r.Read(make([]byte, 5))
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+	})
+
+	t.Run("partially read corrupted", func(t *testing.T) {
+		r := NewVerifyingReader(io.NopCloser(bytes.NewBufferString("hello w0rld")), "test", helloWorldChecksum)
+		_, err := r.Read(make([]byte, 5))
+		require.NoError(t, err)
+		require.ErrorIs(t, r.Close(), ErrChecksumMismatch)
+	})
+}
+
+func TestChecksum(t *testing.T) {
+	sum, n, err := Checksum(bytes.NewBufferString("hello world"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), n)
+	assert.Equal(t, helloWorldChecksum, sum)
+	assert.Equal(t, helloWorldChecksum, ChecksumBytes([]byte("hello world")))
+}
@@ -0,0 +1,214 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ioutils
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+	"github.com/pierrec/lz4/v4"
+)
+
+const (
+	CodecGzip = "gzip"
+	CodecZstd = "zstd"
+	CodecLz4  = "lz4"
+	CodecNone = "none"
+)
+
+// DefaultCompressionLevel - the codec default compression level is used
+const DefaultCompressionLevel = 0
+
+var codecFileExtensions = map[string]string{
+	CodecGzip: ".gz",
+	CodecZstd: ".zst",
+	CodecLz4:  ".lz4",
+	CodecNone: "",
+}
+
+// codecLevels - min and max compression levels of the codec
+var codecLevels = map[string][2]int{
+	CodecGzip: {gzip.BestSpeed, gzip.BestCompression},
+	CodecZstd: {1, 22},
+	CodecLz4:  {1, 9},
+	CodecNone: {0, 0},
+}
+
+// CompressionSettings - the compression settings of the dumped data files
+type CompressionSettings struct {
+	Codec    string
+	Level    int
+	UsePgzip bool
+}
+
+// GetCodec - returns the codec name. Empty codec is gzip, it is used for the dumps created before the codecs were
+// introduced
+func GetCodec(codec string) string {
+	if codec == "" {
+		return CodecGzip
+	}
+	return codec
+}
+
+// ValidateCompression - checks the codec is supported and the level is in the codec range
+func ValidateCompression(codec string, level int) error {
+	codec = GetCodec(codec)
+	levels, ok := codecLevels[codec]
+	if !ok {
+		return fmt.Errorf(
+			"unknown compression codec \"%s\": expected one of %s, %s, %s, %s",
+			codec, CodecGzip, CodecZstd, CodecLz4, CodecNone,
+		)
+	}
+	if level != DefaultCompressionLevel && (level < levels[0] || level > levels[1]) {
+		if codec == CodecNone {
+			return fmt.Errorf("compression level cannot be set for \"%s\" codec", codec)
+		}
+		return fmt.Errorf(
+			"compression level %d is out of range for \"%s\" codec: expected from %d to %d",
+			level, codec, levels[0], levels[1],
+		)
+	}
+	return nil
+}
+
+// GetCodecFileExtension - returns the file extension of the codec including the dot. It is empty for the "none" codec
+func GetCodecFileExtension(codec string) string {
+	return codecFileExtensions[GetCodec(codec)]
+}
+
+// GetCodecByFileName - detects the codec using the file extension. The files without known extension are
+// considered as uncompressed
+func GetCodecByFileName(fileName string) string {
+	for codec, ext := range codecFileExtensions {
+		if ext != "" && strings.HasSuffix(fileName, ext) {
+			return codec
+		}
+	}
+	return CodecNone
+}
+
+// NewCompressionWriter - returns the writer that compresses the data using the codec and writes it into w. usePgzip
+// is taken into account only for gzip codec
+func NewCompressionWriter(w io.WriteCloser, codec string, level int, usePgzip bool) (*CompressionWriter, error) {
+	if err := ValidateCompression(codec, level); err != nil {
+		return nil, err
+	}
+	codec = GetCodec(codec)
+
+	var enc WriteCloseFlusher
+	var err error
+	switch codec {
+	case CodecGzip:
+		if level == DefaultCompressionLevel {
+			level = gzip.DefaultCompression
+		}
+		if usePgzip {
+			enc, err = pgzip.NewWriterLevel(w, level)
+		} else {
+			enc, err = gzip.NewWriterLevel(w, level)
+		}
+	case CodecZstd:
+		var opts []zstd.EOption
+		if level != DefaultCompressionLevel {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		enc, err = zstd.NewWriter(w, opts...)
+	case CodecLz4:
+		lw := lz4.NewWriter(w)
+		if level != DefaultCompressionLevel {
+			err = lw.Apply(lz4.CompressionLevelOption(lz4.CompressionLevel(1 << (8 + level))))
+		}
+		enc = lw
+	case CodecNone:
+		enc = &plainWriter{w: w}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot create %s writer: %w", codec, err)
+	}
+
+	return &CompressionWriter{
+		w:     w,
+		gz:    enc,
+		codec: codec,
+	}, nil
+}
+
+// NewCompressionReader - returns the reader that decompresses the data of r using the codec. usePgzip is taken into
+// account only for gzip codec
+func NewCompressionReader(r io.ReadCloser, codec string, usePgzip bool) (*CompressionReader, error) {
+	dec, err := GetCompressionReadCloser(r, codec, usePgzip)
+	if err != nil {
+		return nil, err
+	}
+	return &CompressionReader{
+		gz: dec,
+		r:  r,
+	}, nil
+}
+
+// GetCompressionReadCloser - returns the decompressing reader of the codec. Closing of the returned reader does not
+// close r
+func GetCompressionReadCloser(r io.Reader, codec string, usePgzip bool) (io.ReadCloser, error) {
+	switch GetCodec(codec) {
+	case CodecGzip:
+		return GetGzipReadCloser(r, usePgzip)
+	case CodecZstd:
+		dec, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create zstd reader: %w", err)
+		}
+		return dec.IOReadCloser(), nil
+	case CodecLz4:
+		return io.NopCloser(lz4.NewReader(r)), nil
+	case CodecNone:
+		return io.NopCloser(r), nil
+	}
+	return nil, fmt.Errorf("unknown compression codec \"%s\"", codec)
+}
+
+// plainWriter - the writer of the "none" codec. The underlying writer is closed by CompressionWriter
+type plainWriter struct {
+	w io.Writer
+}
+
+func (pw *plainWriter) Write(p []byte) (int, error) {
+	return pw.w.Write(p)
+}
+
+func (pw *plainWriter) Flush() error {
+	return nil
+}
+
+func (pw *plainWriter) Close() error {
+	return nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// NopWriteCloser - returns the WriteCloser with a no-op Close method wrapping the provided Writer w
+func NopWriteCloser(w io.Writer) io.WriteCloser {
+	return nopWriteCloser{Writer: w}
+}
@@ -0,0 +1,100 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ioutils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressionWriter_Roundtrip(t *testing.T) {
+	data := strings.Repeat("20383   24ca7574-0adb-4b17-8777-93f5589dbea2    2017-12-13 13:46:49.39\n", 1000)
+	tests := []struct {
+		codec    string
+		level    int
+		usePgzip bool
+	}{
+		{codec: CodecGzip},
+		{codec: CodecGzip, level: 9},
+		{codec: CodecGzip, usePgzip: true},
+		{codec: CodecZstd},
+		{codec: CodecZstd, level: 19},
+		{codec: CodecLz4},
+		{codec: CodecLz4, level: 9},
+		{codec: CodecNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.codec, func(t *testing.T) {
+			objSrc := &writeCloserMock{}
+			w, err := NewCompressionWriter(objSrc, tt.codec, tt.level, tt.usePgzip)
+			require.NoError(t, err)
+			_, err = w.Write([]byte(data))
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+			require.Equal(t, 1, objSrc.closeCallCount)
+			if tt.codec != CodecNone {
+				assert.Less(t, len(objSrc.data), len(data))
+			}
+
+			r, err := NewCompressionReader(
+				&readCloserMock{Buffer: bytes.NewBuffer(objSrc.data)}, tt.codec, tt.usePgzip,
+			)
+			require.NoError(t, err)
+			res, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.NoError(t, r.Close())
+			assert.Equal(t, data, string(res))
+		})
+	}
+}
+
+func TestNewCompressionReader_LegacyGzip(t *testing.T) {
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	_, err := gz.Write([]byte("hello world"))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	// The dumps created before the codecs were introduced do not have the codec in the metadata
+	r, err := NewCompressionReader(&readCloserMock{Buffer: buf}, "", false)
+	require.NoError(t, err)
+	res, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(res))
+}
+
+func TestValidateCompression(t *testing.T) {
+	require.NoError(t, ValidateCompression("", DefaultCompressionLevel))
+	require.NoError(t, ValidateCompression(CodecZstd, 22))
+	require.NoError(t, ValidateCompression(CodecNone, DefaultCompressionLevel))
+	require.ErrorContains(t, ValidateCompression("brotli", DefaultCompressionLevel), "unknown compression codec")
+	require.ErrorContains(t, ValidateCompression(CodecLz4, 10), "out of range")
+	require.ErrorContains(t, ValidateCompression(CodecNone, 1), "cannot be set")
+}
+
+func TestGetCodecByFileName(t *testing.T) {
+	assert.Equal(t, CodecGzip, GetCodecByFileName("3456.dat.gz"))
+	assert.Equal(t, CodecZstd, GetCodecByFileName("3456.dat.zst"))
+	assert.Equal(t, CodecLz4, GetCodecByFileName("blob_1.dat.lz4"))
+	assert.Equal(t, CodecNone, GetCodecByFileName("3456.dat"))
+	assert.Equal(t, "3456.dat.zst", "3456.dat"+GetCodecFileExtension(CodecZstd))
+	assert.Equal(t, ".gz", GetCodecFileExtension(""))
+}
@@ -7,11 +7,14 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-type GzipReader struct {
+// CompressionReader - decompresses the data of the underlying reader and closes it on Close
+type CompressionReader struct {
 	gz io.ReadCloser
 	r  io.ReadCloser
 }
 
+type GzipReader = CompressionReader
+
 func NewGzipReader(r io.ReadCloser, usePgzip bool) (*GzipReader, error) {
 	gz, err := GetGzipReadCloser(r, usePgzip)
 	if err != nil {
@@ -30,17 +33,17 @@ func NewGzipReader(r io.ReadCloser, usePgzip bool) (*GzipReader, error) {
 
 }
 
-func (r *GzipReader) Read(p []byte) (n int, err error) {
+func (r *CompressionReader) Read(p []byte) (n int, err error) {
 	return r.gz.Read(p)
 }
 
-func (r *GzipReader) Close() error {
+func (r *CompressionReader) Close() error {
 	var lastErr error
 	if err := r.gz.Close(); err != nil {
-		lastErr = fmt.Errorf("error closing gzip reader: %w", err)
+		lastErr = fmt.Errorf("error closing decompression reader: %w", err)
 		log.Warn().
 			Err(err).
-			Msg("error closing gzip reader")
+			Msg("error closing decompression reader")
 	}
 	if err := r.r.Close(); err != nil {
 		lastErr = fmt.Errorf("error closing dump file: %w", err)
@@ -28,11 +28,15 @@ type WriteCloseFlusher interface {
 	Flush() error
 }
 
-type GzipWriter struct {
-	w  io.WriteCloser
-	gz WriteCloseFlusher
+// CompressionWriter - compresses the data using the codec encoder and closes the underlying writer on Close
+type CompressionWriter struct {
+	w     io.WriteCloser
+	gz    WriteCloseFlusher
+	codec string
 }
 
+type GzipWriter = CompressionWriter
+
 func NewGzipWriter(w io.WriteCloser, usePgzip bool) *GzipWriter {
 	var gz WriteCloseFlusher
 	if usePgzip {
@@ -41,25 +45,26 @@ func NewGzipWriter(w io.WriteCloser, usePgzip bool) *GzipWriter {
 		gz = gzip.NewWriter(w)
 	}
 	return &GzipWriter{
-		w:  w,
-		gz: gz,
+		w:     w,
+		gz:    gz,
+		codec: CodecGzip,
 	}
 }
 
-func (gw *GzipWriter) Write(p []byte) (int, error) {
+func (gw *CompressionWriter) Write(p []byte) (int, error) {
 	return gw.gz.Write(p)
 }
 
-// Close - closing method with gz buffer flushing
-func (gw *GzipWriter) Close() error {
+// Close - closing method with codec buffer flushing
+func (gw *CompressionWriter) Close() error {
 	var globalErr error
 	if err := gw.gz.Flush(); err != nil {
-		globalErr = fmt.Errorf("error flushing gzip buffer: %w", err)
-		log.Warn().Err(err).Msg("error flushing gzip buffer")
+		globalErr = fmt.Errorf("error flushing %s buffer: %w", gw.codec, err)
+		log.Warn().Err(err).Msgf("error flushing %s buffer", gw.codec)
 	}
 	if err := gw.gz.Close(); err != nil {
-		globalErr = fmt.Errorf("error closing gzip writer: %w", err)
-		log.Warn().Err(err).Msg("error closing gzip writer")
+		globalErr = fmt.Errorf("error closing %s writer: %w", gw.codec, err)
+		log.Warn().Err(err).Msgf("error closing %s writer", gw.codec)
 	}
 	if err := gw.w.Close(); err != nil {
 		globalErr = fmt.Errorf("error closing dump file: %w", err)
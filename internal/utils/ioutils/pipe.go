@@ -18,10 +18,13 @@ import (
 	"io"
 )
 
-// NewGzipPipe - returns wrapped PipeWriter into (GzipWriter && Writer) and PipeReader into (Reader)
-func NewGzipPipe(usePgzip bool) (CountWriteCloser, CountReadCloser) {
+// NewCompressionPipe - returns wrapped PipeWriter into (CompressionWriter && Writer) and PipeReader into
+// (ChecksumReader) that counts and hashes the compressed data
+func NewCompressionPipe(cs *CompressionSettings) (CountWriteCloser, ChecksumReadCloser, error) {
 	pr, pw := io.Pipe()
-	// Wrapping writer pipe into count writer and gzip writer and reader pipe
-	// into count reader
-	return NewWriter(NewGzipWriter(pw, usePgzip)), NewReader(pr)
+	cw, err := NewCompressionWriter(pw, cs.Codec, cs.Level, cs.UsePgzip)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewWriter(cw), NewChecksumReader(pr), nil
 }
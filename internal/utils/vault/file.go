@@ -0,0 +1,234 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// fileSyncInterval - the interval of syncing the written records to the disk. The records are written to the file
+// immediately, so they survive the process crash, and are synced periodically to survive the system crash
+const fileSyncInterval = time.Second
+
+// fileRecord - the line of the vault file
+type fileRecord struct {
+	Namespace string `json:"namespace"`
+	Key       string `json:"key"`
+	Token     string `json:"token"`
+}
+
+type fileNamespace struct {
+	tokens map[string]string
+	keys   map[string]string
+}
+
+// ErrVaultFileIsLocked - the vault file is opened by another process. The tokens generated by the processes that
+// share the file would not be consistent, so the concurrent dumps must use the postgres vault
+var ErrVaultFileIsLocked = errors.New(
+	"vault file is used by another process: use the postgres vault for the concurrent dumps",
+)
+
+// FileVault - vault stored in the local JSON Lines file. The file is loaded into the memory on open and the new
+// tokens are appended to its end. The file is locked exclusively while it is opened, so only one process can use it
+type FileVault struct {
+	mx         sync.RWMutex
+	f          *os.File
+	namespaces map[string]*fileNamespace
+	// synced - the time of the last sync. dirty is true if the records were written after it
+	synced time.Time
+	dirty  bool
+}
+
+// NewFileVault - opens the vault file. The file is created if it does not exist
+func NewFileVault(path string) (*FileVault, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open vault file: %w", err)
+	}
+	if err = lockFile(f); err != nil {
+		if err := f.Close(); err != nil {
+			log.Warn().Err(err).Msg("error closing vault file")
+		}
+		return nil, fmt.Errorf("cannot lock vault file %s: %w", path, err)
+	}
+	fv := &FileVault{
+		f:          f,
+		namespaces: make(map[string]*fileNamespace),
+		synced:     time.Now(),
+	}
+	if err = fv.load(); err != nil {
+		if err := f.Close(); err != nil {
+			log.Warn().Err(err).Msg("error closing vault file")
+		}
+		return nil, err
+	}
+	return fv, nil
+}
+
+// load - reads the records of the file. The last line may be torn if the process crashed while writing it. Such a
+// line is truncated, the other invalid lines cause the error
+func (fv *FileVault) load() error {
+	r := bufio.NewReader(fv.f)
+	var offset int64
+	for lineNum := 1; ; lineNum++ {
+		line, err := r.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("cannot read vault file: %w", err)
+		}
+		if len(line) == 0 {
+			return nil
+		}
+		complete := line[len(line)-1] == '\n'
+		var rec fileRecord
+		if decodeErr := json.Unmarshal(line, &rec); decodeErr != nil {
+			if complete {
+				return fmt.Errorf("cannot decode vault file line %d: %w", lineNum, decodeErr)
+			}
+			log.Warn().
+				Err(decodeErr).
+				Str("FileName", fv.f.Name()).
+				Int("LineNum", lineNum).
+				Msg("vault file ends with the torn record: the record is truncated")
+			if err = fv.f.Truncate(offset); err != nil {
+				return fmt.Errorf("cannot truncate torn vault record: %w", err)
+			}
+			return nil
+		}
+		fv.namespace(rec.Namespace).set(rec.Key, rec.Token)
+		if !complete {
+			// The record was written but the line separator was not
+			if _, err = fv.f.Write([]byte{'\n'}); err != nil {
+				return fmt.Errorf("cannot write vault file: %w", err)
+			}
+			return nil
+		}
+		offset += int64(len(line))
+	}
+}
+
+func (fv *FileVault) namespace(name string) *fileNamespace {
+	ns, ok := fv.namespaces[name]
+	if !ok {
+		ns = &fileNamespace{
+			tokens: make(map[string]string),
+			keys:   make(map[string]string),
+		}
+		fv.namespaces[name] = ns
+	}
+	return ns
+}
+
+func (ns *fileNamespace) set(key, token string) {
+	ns.tokens[key] = token
+	ns.keys[token] = key
+}
+
+func (fv *FileVault) Get(ctx context.Context, namespace string, key []byte) (string, bool, error) {
+	fv.mx.RLock()
+	defer fv.mx.RUnlock()
+	if fv.f == nil {
+		return "", false, ErrVaultIsClosed
+	}
+	ns, ok := fv.namespaces[namespace]
+	if !ok {
+		return "", false, nil
+	}
+	token, ok := ns.tokens[base64.StdEncoding.EncodeToString(key)]
+	return token, ok, nil
+}
+
+func (fv *FileVault) Put(ctx context.Context, namespace string, key []byte, token string) (string, error) {
+	fv.mx.Lock()
+	defer fv.mx.Unlock()
+	if fv.f == nil {
+		return "", ErrVaultIsClosed
+	}
+	encodedKey := base64.StdEncoding.EncodeToString(key)
+	ns := fv.namespace(namespace)
+	if existing, ok := ns.tokens[encodedKey]; ok {
+		return existing, nil
+	}
+	if _, ok := ns.keys[token]; ok {
+		return "", ErrTokenIsNotUnique
+	}
+
+	data, err := json.Marshal(&fileRecord{Namespace: namespace, Key: encodedKey, Token: token})
+	if err != nil {
+		return "", fmt.Errorf("cannot encode vault record: %w", err)
+	}
+	if _, err = fv.f.Write(append(data, '\n')); err != nil {
+		return "", fmt.Errorf("cannot write vault record: %w", err)
+	}
+	ns.set(encodedKey, token)
+	fv.dirty = true
+	if time.Since(fv.synced) >= fileSyncInterval {
+		if err = fv.sync(); err != nil {
+			return "", err
+		}
+	}
+	return token, nil
+}
+
+// Flush - syncs the written tokens to the disk
+func (fv *FileVault) Flush(ctx context.Context) error {
+	fv.mx.Lock()
+	defer fv.mx.Unlock()
+	if fv.f == nil {
+		return ErrVaultIsClosed
+	}
+	return fv.sync()
+}
+
+func (fv *FileVault) sync() error {
+	if !fv.dirty {
+		return nil
+	}
+	if err := fv.f.Sync(); err != nil {
+		return fmt.Errorf("cannot sync vault file: %w", err)
+	}
+	fv.dirty = false
+	fv.synced = time.Now()
+	return nil
+}
+
+// Close - syncs the written tokens and closes the file
+func (fv *FileVault) Close() error {
+	fv.mx.Lock()
+	defer fv.mx.Unlock()
+	if fv.f == nil {
+		return nil
+	}
+	err := fv.sync()
+	f := fv.f
+	fv.f = nil
+	if closeErr := f.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("cannot close vault file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileVault(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "vault.jsonl")
+
+	v, err := NewFileVault(path)
+	require.NoError(t, err)
+
+	_, ok, err := v.Get(ctx, "email", []byte("key1"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	token, err := v.Put(ctx, "email", []byte("key1"), "token1")
+	require.NoError(t, err)
+	assert.Equal(t, "token1", token)
+
+	// The existing token of the key is returned
+	token, err = v.Put(ctx, "email", []byte("key1"), "token2")
+	require.NoError(t, err)
+	assert.Equal(t, "token1", token)
+
+	_, err = v.Put(ctx, "email", []byte("key2"), "token1")
+	require.ErrorIs(t, err, ErrTokenIsNotUnique)
+
+	// The namespaces are independent
+	token, err = v.Put(ctx, "phone", []byte("key2"), "token1")
+	require.NoError(t, err)
+	assert.Equal(t, "token1", token)
+	require.NoError(t, v.Close())
+
+	_, _, err = v.Get(ctx, "email", []byte("key1"))
+	require.ErrorIs(t, err, ErrVaultIsClosed)
+
+	// The tokens are loaded on reopen
+	v, err = NewFileVault(path)
+	require.NoError(t, err)
+	defer v.Close()
+	token, ok, err = v.Get(ctx, "email", []byte("key1"))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "token1", token)
+	_, err = v.Put(ctx, "phone", []byte("key3"), "token1")
+	require.ErrorIs(t, err, ErrTokenIsNotUnique)
+}
+
+func TestFileVault_locked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.jsonl")
+	v, err := NewFileVault(path)
+	require.NoError(t, err)
+
+	// The file is locked by the opened vault, as it would be by another process
+	_, err = NewFileVault(path)
+	require.ErrorIs(t, err, ErrVaultFileIsLocked)
+
+	// The lock is released on close
+	require.NoError(t, v.Close())
+	v, err = NewFileVault(path)
+	require.NoError(t, err)
+	require.NoError(t, v.Close())
+}
+
+func TestFileVault_Put_written(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "vault.jsonl")
+	v, err := NewFileVault(path)
+	require.NoError(t, err)
+	defer v.Close()
+
+	// The record is written to the file before the vault is closed
+	_, err = v.Put(ctx, "email", []byte("key1"), "token1")
+	require.NoError(t, err)
+	require.NoError(t, v.Flush(ctx))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{"namespace":"email","key":"a2V5MQ==","token":"token1"}`+"\n", string(data))
+}
+
+func TestFileVault_load(t *testing.T) {
+	ctx := context.Background()
+	record := `{"namespace":"email","key":"a2V5MQ==","token":"token1"}`
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+		token    bool
+	}{
+		{
+			name:     "torn last record",
+			content:  record + "\n" + `{"namespace":"email","key":"a2V5Mg=`,
+			expected: record + "\n" + `{"namespace":"email","key":"a2V5Mw==","token":"token3"}` + "\n",
+			token:    true,
+		},
+		{
+			name:     "last record without line separator",
+			content:  record,
+			expected: record + "\n" + `{"namespace":"email","key":"a2V5Mw==","token":"token3"}` + "\n",
+			token:    true,
+		},
+		{
+			name:     "torn single record",
+			content:  `{"namespace":"em`,
+			expected: `{"namespace":"email","key":"a2V5Mw==","token":"token3"}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "vault.jsonl")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0600))
+			v, err := NewFileVault(path)
+			require.NoError(t, err)
+			_, ok, err := v.Get(ctx, "email", []byte("key1"))
+			require.NoError(t, err)
+			assert.Equal(t, tt.token, ok)
+			_, err = v.Put(ctx, "email", []byte("key3"), "token3")
+			require.NoError(t, err)
+			require.NoError(t, v.Close())
+
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(data))
+		})
+	}
+
+	t.Run("corrupted record", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "vault.jsonl")
+		require.NoError(t, os.WriteFile(path, []byte("{\n"+record+"\n"), 0600))
+		_, err := NewFileVault(path)
+		require.ErrorContains(t, err, "line 1")
+	})
+}
+
+func TestOpen_shared(t *testing.T) {
+	ctx := context.Background()
+	uri := "file://" + filepath.Join(t.TempDir(), "vault.jsonl")
+
+	v1, err := Open(ctx, uri)
+	require.NoError(t, err)
+	v2, err := Open(ctx, uri)
+	require.NoError(t, err)
+	assert.Same(t, v1, v2)
+
+	_, err = v1.Put(ctx, "email", []byte("key1"), "token1")
+	require.NoError(t, err)
+	require.NoError(t, v1.Close())
+
+	// The vault is still opened by the second user
+	token, ok, err := v2.Get(ctx, "email", []byte("key1"))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "token1", token)
+	require.NoError(t, v2.Close())
+
+	_, _, err = v2.Get(ctx, "email", []byte("key1"))
+	require.ErrorIs(t, err, ErrVaultIsClosed)
+}
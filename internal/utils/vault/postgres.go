@@ -0,0 +1,116 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DefaultTableName - the table of the PostgreSQL vault. It is created in the first schema of the search_path if it
+// does not exist
+const DefaultTableName = "greenmask_token_vault"
+
+const uniqueViolationCode = "23505"
+
+// PostgresVault - vault stored in the PostgreSQL table. The table constraints guarantee the tokens uniqueness, so the
+// vault can be shared by the concurrent greenmask processes
+type PostgresVault struct {
+	pool       *pgxpool.Pool
+	table      string
+	selectStmt string
+	insertStmt string
+}
+
+// NewPostgresVault - connects to the database and creates the vault table if it does not exist
+func NewPostgresVault(ctx context.Context, dsn, table string) (*PostgresVault, error) {
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to vault database: %w", err)
+	}
+	quotedTable := pgx.Identifier{table}.Sanitize()
+	createStmt := fmt.Sprintf(
+		`CREATE TABLE IF NOT EXISTS %s (
+    namespace TEXT NOT NULL,
+    key       BYTEA NOT NULL,
+    token     TEXT NOT NULL,
+    PRIMARY KEY (namespace, key),
+    UNIQUE (namespace, token)
+)`, quotedTable,
+	)
+	if _, err = pool.Exec(ctx, createStmt); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("cannot create vault table: %w", err)
+	}
+	return &PostgresVault{
+		pool:       pool,
+		table:      table,
+		selectStmt: fmt.Sprintf(`SELECT token FROM %s WHERE namespace = $1 AND key = $2`, quotedTable),
+		insertStmt: fmt.Sprintf(
+			`INSERT INTO %s (namespace, key, token) VALUES ($1, $2, $3) ON CONFLICT (namespace, key) DO NOTHING`,
+			quotedTable,
+		),
+	}, nil
+}
+
+func (pv *PostgresVault) Get(ctx context.Context, namespace string, key []byte) (string, bool, error) {
+	var token string
+	err := pv.pool.QueryRow(ctx, pv.selectStmt, namespace, key).Scan(&token)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("cannot get token: %w", err)
+	}
+	return token, true, nil
+}
+
+func (pv *PostgresVault) Put(ctx context.Context, namespace string, key []byte, token string) (string, error) {
+	tag, err := pv.pool.Exec(ctx, pv.insertStmt, namespace, key, token)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return "", ErrTokenIsNotUnique
+		}
+		return "", fmt.Errorf("cannot put token: %w", err)
+	}
+	if tag.RowsAffected() == 1 {
+		return token, nil
+	}
+	// The key is stored by the concurrent process
+	existing, ok, err := pv.Get(ctx, namespace, key)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("token of the key is not found in table %s after insert conflict", pv.table)
+	}
+	return existing, nil
+}
+
+// Flush - does nothing because each token is committed on Put
+func (pv *PostgresVault) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (pv *PostgresVault) Close() error {
+	pv.pool.Close()
+	return nil
+}
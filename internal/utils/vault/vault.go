@@ -0,0 +1,96 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package vault implements the persistent mapping store of the original values to the generated tokens. The same
+// vault can be shared by several dumps and databases, so the same value is replaced by the same token everywhere
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrTokenIsNotUnique - the token is already mapped to another value in the namespace
+	ErrTokenIsNotUnique = errors.New("token is not unique")
+	ErrVaultIsClosed    = errors.New("vault is closed")
+)
+
+// Vault - stores the tokens of the values. The value is identified by its key, for instance HMAC of the original
+// value, so the original values are not stored. The tokens are unique within the namespace
+type Vault interface {
+	// Get - returns the token of the key. ok is false if the key is not found
+	Get(ctx context.Context, namespace string, key []byte) (token string, ok bool, err error)
+	// Put - stores the token of the key and returns the token that is stored in the vault. If the key is already
+	// stored, for instance by the concurrent transformer, the existing token is returned. It returns
+	// ErrTokenIsNotUnique if the token is mapped to another key
+	Put(ctx context.Context, namespace string, key []byte, token string) (string, error)
+	// Flush - persists the stored tokens. It is called when the transformer is done, while the vault may still be
+	// used by the other transformers
+	Flush(ctx context.Context) error
+	Close() error
+}
+
+var (
+	openedMx sync.Mutex
+	opened   = make(map[string]*sharedVault)
+)
+
+// sharedVault - vault that is shared by the transformers of the process. The vault is closed when the last
+// transformer releases it
+type sharedVault struct {
+	Vault
+	uri  string
+	refs int
+}
+
+func (sv *sharedVault) Close() error {
+	openedMx.Lock()
+	defer openedMx.Unlock()
+	sv.refs--
+	if sv.refs > 0 {
+		return nil
+	}
+	delete(opened, sv.uri)
+	return sv.Vault.Close()
+}
+
+// Open - opens the vault by URI. The postgres:// and postgresql:// URIs open the vault stored in the PostgreSQL
+// table, the other URIs are treated as the path of the vault file (file:// prefix is optional). The same URI
+// returns the same vault instance until all the instances are closed
+func Open(ctx context.Context, uri string) (Vault, error) {
+	openedMx.Lock()
+	defer openedMx.Unlock()
+	if v, ok := opened[uri]; ok {
+		v.refs++
+		return v, nil
+	}
+
+	var v Vault
+	var err error
+	if strings.HasPrefix(uri, "postgres://") || strings.HasPrefix(uri, "postgresql://") {
+		v, err = NewPostgresVault(ctx, uri, DefaultTableName)
+	} else {
+		v, err = NewFileVault(strings.TrimPrefix(uri, "file://"))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot open vault: %w", err)
+	}
+	sv := &sharedVault{Vault: v, uri: uri, refs: 1}
+	opened[uri] = sv
+	return sv, nil
+}
@@ -30,6 +30,8 @@ type Column struct {
 	OverriddenTypeName string `json:"overridden_type_name"`
 	OverriddenTypeOid  Oid    `json:"overridden_type_oid"`
 	OverriddenTypeSize int    `json:"overridden_type_size"`
+	// Comment - column comment set by COMMENT ON COLUMN
+	Comment string `json:"comment,omitempty"`
 }
 
 func (c *Column) GetColumnSize() int {
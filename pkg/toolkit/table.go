@@ -35,6 +35,9 @@ type Table struct {
 	Children   []Oid     `json:"children"`
 	Size       int64     `json:"size"`
 	PrimaryKey []string  `json:"primary_key"`
+	// RowsEstimate - estimated number of the table rows from pg_class.reltuples. It is -1 if the table has never
+	// been analyzed
+	RowsEstimate int64 `json:"rows_estimate"`
 	// RootPtSchema, RootPtName, RootPtOid - the first parent of the partitioned table
 	RootPtSchema string       `json:"root_pt_schema"`
 	RootPtName   string       `json:"root_pt_name"`
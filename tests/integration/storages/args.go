@@ -30,6 +30,16 @@ var (
 	storageS3AccessKeyId     string
 	storageS3SecretAccessKey string
 	storageS3Prefix          string
+
+	storageGcsEndpoint string
+	storageGcsBucket   string
+	storageGcsPrefix   string
+
+	storageAzureEndpoint    string
+	storageAzureAccountName string
+	storageAzureAccountKey  string
+	storageAzureContainer   string
+	storageAzurePrefix      string
 )
 
 const (
@@ -39,6 +49,16 @@ const (
 	storageS3AccessKeyIdEnvVarName     = "STORAGE_S3_ACCESS_KEY_ID"
 	storageS3SecretAccessKeyEnvVarName = "STORAGE_S3_SECRET_KEY"
 	storageS3PrefixEnvVarName          = "STORAGE_S3_PREFIX"
+
+	storageGcsEndpointEnvVarName = "STORAGE_GCS_ENDPOINT"
+	storageGcsBucketEnvVarName   = "STORAGE_GCS_BUCKET"
+	storageGcsPrefixEnvVarName   = "STORAGE_GCS_PREFIX"
+
+	storageAzureEndpointEnvVarName    = "STORAGE_AZURE_ENDPOINT"
+	storageAzureAccountNameEnvVarName = "STORAGE_AZURE_ACCOUNT_NAME"
+	storageAzureAccountKeyEnvVarName  = "STORAGE_AZURE_ACCOUNT_KEY"
+	storageAzureContainerEnvVarName   = "STORAGE_AZURE_CONTAINER"
+	storageAzurePrefixEnvVarName      = "STORAGE_AZURE_PREFIX"
 )
 
 func init() {
@@ -68,6 +88,42 @@ func init() {
 		storageS3Prefix = v
 	}
 
+	flag.StringVar(&storageGcsEndpoint, "storageGcsEndpoint", "", "gcs endpoint")
+	flag.StringVar(&storageGcsBucket, "storageGcsBucket", "", "gcs bucket name")
+	flag.StringVar(&storageGcsPrefix, "storageGcsPrefix", "", "prefix in gcs bucket path")
+
+	if v := os.Getenv(storageGcsEndpointEnvVarName); v != "" {
+		storageGcsEndpoint = v
+	}
+	if v := os.Getenv(storageGcsBucketEnvVarName); v != "" {
+		storageGcsBucket = v
+	}
+	if v := os.Getenv(storageGcsPrefixEnvVarName); v != "" {
+		storageGcsPrefix = v
+	}
+
+	flag.StringVar(&storageAzureEndpoint, "storageAzureEndpoint", "", "azure blob service url")
+	flag.StringVar(&storageAzureAccountName, "storageAzureAccountName", "", "azure storage account name")
+	flag.StringVar(&storageAzureAccountKey, "storageAzureAccountKey", "", "azure storage account key")
+	flag.StringVar(&storageAzureContainer, "storageAzureContainer", "", "azure container name")
+	flag.StringVar(&storageAzurePrefix, "storageAzurePrefix", "", "prefix in azure container path")
+
+	if v := os.Getenv(storageAzureEndpointEnvVarName); v != "" {
+		storageAzureEndpoint = v
+	}
+	if v := os.Getenv(storageAzureAccountNameEnvVarName); v != "" {
+		storageAzureAccountName = v
+	}
+	if v := os.Getenv(storageAzureAccountKeyEnvVarName); v != "" {
+		storageAzureAccountKey = v
+	}
+	if v := os.Getenv(storageAzureContainerEnvVarName); v != "" {
+		storageAzureContainer = v
+	}
+	if v := os.Getenv(storageAzurePrefixEnvVarName); v != "" {
+		storageAzurePrefix = v
+	}
+
 }
 
 func init() {
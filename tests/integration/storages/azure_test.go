@@ -0,0 +1,63 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storages
+
+import (
+	"context"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/greenmaskio/greenmask/internal/storages/azure"
+)
+
+type AzureStorageSuite struct {
+	suite.Suite
+	cfg *azure.Config
+	st  *azure.Storage
+}
+
+func (suite *AzureStorageSuite) SetupSuite() {
+	suite.Require().NotEmpty(storageAzureEndpoint, "-storageAzureEndpoint non-empty flag required")
+	suite.Require().NotEmpty(storageAzureAccountName, "-storageAzureAccountName non-empty flag required")
+	suite.Require().NotEmpty(storageAzureAccountKey, "-storageAzureAccountKey non-empty flag required")
+	suite.Require().NotEmpty(storageAzureContainer, "-storageAzureContainer non-empty flag required")
+	suite.cfg = azure.NewConfig()
+	suite.cfg.Endpoint = storageAzureEndpoint
+	suite.cfg.AccountName = storageAzureAccountName
+	suite.cfg.AccountKey = storageAzureAccountKey
+	suite.cfg.Container = storageAzureContainer
+	suite.cfg.Prefix = storageAzurePrefix
+	suite.Require().NoError(suite.cfg.Validate())
+
+	// Azurite starts without containers
+	cred, err := azblob.NewSharedKeyCredential(suite.cfg.AccountName, suite.cfg.AccountKey)
+	suite.Require().NoError(err)
+	client, err := azblob.NewClientWithSharedKeyCredential(suite.cfg.Endpoint, cred, nil)
+	suite.Require().NoError(err)
+	_, err = client.CreateContainer(context.Background(), suite.cfg.Container, nil)
+	if err != nil && !bloberror.HasCode(err, bloberror.ContainerAlreadyExists) {
+		suite.Require().NoError(err)
+	}
+
+	suite.st, err = azure.NewStorage(context.Background(), suite.cfg, zerolog.LevelDebugValue)
+	suite.Require().NoError(err)
+}
+
+func (suite *AzureStorageSuite) TestAzureOps() {
+	runStoragerOps(&suite.Suite, suite.st, suite.cfg.Prefix)
+}
@@ -0,0 +1,48 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storages
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/greenmaskio/greenmask/internal/storages/gcs"
+)
+
+type GcsStorageSuite struct {
+	suite.Suite
+	cfg *gcs.Config
+	st  *gcs.Storage
+}
+
+func (suite *GcsStorageSuite) SetupSuite() {
+	suite.Require().NotEmpty(storageGcsEndpoint, "-storageGcsEndpoint non-empty flag required")
+	suite.Require().NotEmpty(storageGcsBucket, "-storageGcsBucket non-empty flag required")
+	suite.cfg = gcs.NewConfig()
+	suite.cfg.Endpoint = storageGcsEndpoint
+	suite.cfg.Bucket = storageGcsBucket
+	suite.cfg.Prefix = storageGcsPrefix
+	suite.cfg.WithoutAuthentication = true
+	suite.Require().NoError(suite.cfg.Validate())
+
+	var err error
+	suite.st, err = gcs.NewStorage(context.Background(), suite.cfg)
+	suite.Require().NoError(err)
+}
+
+func (suite *GcsStorageSuite) TestGcsOps() {
+	runStoragerOps(&suite.Suite, suite.st, suite.cfg.Prefix)
+}
@@ -23,3 +23,11 @@ import (
 func TestS3Storage(t *testing.T) {
 	suite.Run(t, new(S3StorageSuite))
 }
+
+func TestGcsStorage(t *testing.T) {
+	suite.Run(t, new(GcsStorageSuite))
+}
+
+func TestAzureStorage(t *testing.T) {
+	suite.Run(t, new(AzureStorageSuite))
+}
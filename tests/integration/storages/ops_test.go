@@ -0,0 +1,125 @@
+// Copyright 2023 Greenmask
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storages
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path"
+	"slices"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/greenmaskio/greenmask/internal/storages"
+)
+
+// runStoragerOps - checks the Storager interface semantics that are common for all the object storages
+func runStoragerOps(s *suite.Suite, st storages.Storager, prefix string) {
+	ctx := context.Background()
+
+	s.Run("put object", func() {
+		err := st.PutObject(ctx, "/test.txt", bytes.NewBuffer([]byte("1234567890")))
+		s.Require().NoError(err)
+		err = st.PutObject(ctx, "/testdb/test.txt", bytes.NewBuffer([]byte("1234567890")))
+		s.Require().NoError(err)
+	})
+
+	s.Run("get object", func() {
+		obj, err := st.GetObject(ctx, "/test.txt")
+		s.Require().NoError(err)
+		data, err := io.ReadAll(obj)
+		s.Require().NoError(err)
+		s.Require().NoError(obj.Close())
+		s.Require().Equal([]byte("1234567890"), data)
+
+		_, err = st.GetObject(ctx, "/unknown.txt")
+		s.Require().ErrorIs(err, storages.ErrFileNotFound)
+	})
+
+	s.Run("exists and stat", func() {
+		exists, err := st.Exists(ctx, "test.txt")
+		s.Require().NoError(err)
+		s.Require().True(exists)
+		exists, err = st.Exists(ctx, "unknown.txt")
+		s.Require().NoError(err)
+		s.Require().False(exists)
+
+		stat, err := st.Stat("test.txt")
+		s.Require().NoError(err)
+		s.Require().True(stat.Exist)
+		s.Require().False(stat.LastModified.IsZero())
+		stat, err = st.Stat("unknown.txt")
+		s.Require().NoError(err)
+		s.Require().False(stat.Exist)
+	})
+
+	s.Run("walking", func() {
+		files, dirs, err := st.ListDir(ctx)
+		s.Require().NoError(err)
+		s.Require().Equal([]string{"test.txt"}, files)
+		s.Require().Len(dirs, 1)
+		s.Require().Equal(path.Join(prefix, "testdb")+"/", dirs[0].GetCwd())
+
+		files, dirs, err = dirs[0].ListDir(ctx)
+		s.Require().NoError(err)
+		s.Require().Equal([]string{"test.txt"}, files)
+		s.Require().Empty(dirs)
+
+		exists, err := st.SubStorage("testdb", true).Exists(ctx, "test.txt")
+		s.Require().NoError(err)
+		s.Require().True(exists)
+	})
+
+	s.Run("delete", func() {
+		err := st.PutObject(ctx, "/test_to_del.txt", bytes.NewBuffer([]byte("1234567890")))
+		s.Require().NoError(err)
+
+		err = st.Delete(ctx, "/test_to_del.txt")
+		s.Require().NoError(err)
+
+		files, _, err := st.ListDir(ctx)
+		s.Require().NoError(err)
+		s.Require().NotContains(files, "test_to_del.txt")
+	})
+
+	s.Run("delete_all", func() {
+		err := st.PutObject(ctx, "/dir1/test_to_del2.txt", bytes.NewBuffer([]byte("1234567890")))
+		s.Require().NoError(err)
+		err = st.PutObject(ctx, "/dir1/subdir2/test_to_del3.txt", bytes.NewBuffer([]byte("1234567890")))
+		s.Require().NoError(err)
+
+		_, dirs, err := st.ListDir(ctx)
+		s.Require().NoError(err)
+		s.Require().True(slices.ContainsFunc(dirs, func(d storages.Storager) bool {
+			return d.Dirname() == "dir1"
+		}))
+
+		err = st.DeleteAll(ctx, "dir1")
+		s.Require().NoError(err)
+		_, dirs, err = st.ListDir(ctx)
+		s.Require().NoError(err)
+		s.Require().False(slices.ContainsFunc(dirs, func(d storages.Storager) bool {
+			return d.Dirname() == "dir1"
+		}))
+
+		err = st.DeleteAll(ctx, "/")
+		s.Require().NoError(err)
+		files, dirs, err := st.ListDir(ctx)
+		s.Require().NoError(err)
+		s.Require().Empty(files)
+		s.Require().Empty(dirs)
+	})
+}